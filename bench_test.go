@@ -15,6 +15,7 @@
 package zipkin_test
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"sync/atomic"
@@ -100,6 +101,43 @@ func BenchmarkSpan_1000Tags(b *testing.B) {
 	benchmarkWithOps(b, 0, 1000)
 }
 
+func benchmarkStartSpanFromContext(b *testing.B, child bool, opts ...zipkin.TracerOption) {
+	var (
+		r    countingRecorder
+		t, _ = zipkin.NewTracer(&r, opts...)
+		ctx  = context.Background()
+	)
+	if child {
+		parent := t.StartSpan("parent")
+		defer parent.Finish()
+		ctx = zipkin.NewContext(ctx, parent)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		sp, _ := t.StartSpanFromContext(ctx, "test", zipkin.Kind(model.Client))
+		sp.Finish()
+	}
+}
+
+func BenchmarkStartSpanFromContext_Root_Sampled(b *testing.B) {
+	benchmarkStartSpanFromContext(b, false)
+}
+
+func BenchmarkStartSpanFromContext_Child_Sampled(b *testing.B) {
+	benchmarkStartSpanFromContext(b, true)
+}
+
+func BenchmarkStartSpanFromContext_Root_Unsampled(b *testing.B) {
+	benchmarkStartSpanFromContext(b, false, zipkin.WithSampler(zipkin.NeverSample), zipkin.WithNoopSpan(true))
+}
+
+func BenchmarkStartSpanFromContext_Child_Unsampled(b *testing.B) {
+	benchmarkStartSpanFromContext(b, true, zipkin.WithSampler(zipkin.NeverSample), zipkin.WithNoopSpan(true))
+}
+
 func benchmarkInject(b *testing.B, propagationType string) {
 	var (
 		r         countingRecorder
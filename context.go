@@ -16,9 +16,11 @@ package zipkin
 
 import (
 	"context"
+
+	"github.com/openzipkin/zipkin-go/model"
 )
 
-var defaultNoopSpan = &noopSpan{}
+var defaultNoopSpan = &NoopSpan{}
 
 // SpanFromContext retrieves a Zipkin Span from Go's context propagation
 // mechanism if found. If not found, returns nil.
@@ -30,7 +32,7 @@ func SpanFromContext(ctx context.Context) Span {
 }
 
 // SpanOrNoopFromContext retrieves a Zipkin Span from Go's context propagation
-// mechanism if found. If not found, returns a noopSpan.
+// mechanism if found. If not found, returns a NoopSpan.
 // This function typically is used for modules that want to provide existing
 // Zipkin spans with additional data, but can't guarantee that spans are
 // properly propagated. It is preferred to use SpanFromContext() and test for
@@ -47,6 +49,61 @@ func NewContext(ctx context.Context, s Span) context.Context {
 	return context.WithValue(ctx, spanKey, s)
 }
 
+// NewContextFromSpanContext stores a Span into Go's context propagation
+// mechanism which only carries the provided SpanContext and records no data.
+// This allows middleware to propagate the context of unsampled requests
+// without the overhead of creating and tagging a real Span. Spans started from
+// the returned context using StartSpanFromContext use sc as their parent.
+func NewContextFromSpanContext(ctx context.Context, sc model.SpanContext) context.Context {
+	return context.WithValue(ctx, spanKey, &NoopSpan{SpanContext: sc})
+}
+
+// SetTraceTag records a tag which is added to the Span found in ctx and to all
+// Spans of the same trace within this process which finish afterwards, e.g. to
+// stamp a customer id on every span of a request. The trace consists of the
+// local root Span and the Spans started from its context, directly or
+// indirectly, using StartSpanFromContext. Tags set on a Span itself take
+// precedence. Trace tags are not propagated to other processes.
+func SetTraceTag(ctx context.Context, key, value string) {
+	if s, ok := ctx.Value(spanKey).(*spanImpl); ok {
+		s.root().setTraceTag(key, value)
+	}
+}
+
+// ForceSample returns a copy of ctx which overrides the sampling decision of
+// the current trace to be sampled. The Span found in ctx, if not yet finished,
+// is switched to sampled and all Spans started from the returned context using
+// StartSpanFromContext will be sampled, propagating the decision downstream.
+// A Span that was created as NoopSpan can not be made to collect data.
+func ForceSample(ctx context.Context) context.Context {
+	return withSamplingOverride(ctx, true)
+}
+
+// Suppress returns a copy of ctx which overrides the sampling decision of the
+// current trace to not be sampled. The Span found in ctx will not be reported
+// and all Spans started from the returned context using StartSpanFromContext
+// will not be sampled, propagating the decision downstream.
+func Suppress(ctx context.Context) context.Context {
+	return withSamplingOverride(ctx, false)
+}
+
+func withSamplingOverride(ctx context.Context, sampled bool) context.Context {
+	if s, ok := ctx.Value(spanKey).(*spanImpl); ok {
+		s.setSampled(sampled)
+	}
+	return context.WithValue(ctx, samplingKey, sampled)
+}
+
+func samplingOverrideFromContext(ctx context.Context) (sampled bool, found bool) {
+	sampled, found = ctx.Value(samplingKey).(bool)
+	return
+}
+
 type ctxKey struct{}
 
-var spanKey = ctxKey{}
+type samplingCtxKey struct{}
+
+var (
+	spanKey     = ctxKey{}
+	samplingKey = samplingCtxKey{}
+)
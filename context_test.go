@@ -17,6 +17,10 @@ package zipkin
 import (
 	"context"
 	"testing"
+	"time"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter/recorder"
 )
 
 func TestSpanOrNoopFromContext(t *testing.T) {
@@ -37,3 +41,228 @@ func TestSpanOrNoopFromContext(t *testing.T) {
 	}
 
 }
+
+func TestForceSample(t *testing.T) {
+	rec := recorder.NewReporter()
+	defer rec.Close()
+
+	tr, _ := NewTracer(rec, WithSampler(NeverSample))
+
+	parent, ctx := tr.StartSpanFromContext(context.Background(), "parent")
+	if sampled := parent.Context().Sampled; sampled == nil || *sampled {
+		t.Fatalf("expected parent span to not be sampled, have %+v", sampled)
+	}
+
+	ctx = ForceSample(ctx)
+
+	if sampled := parent.Context().Sampled; sampled == nil || !*sampled {
+		t.Errorf("expected parent span to be sampled, have %+v", sampled)
+	}
+
+	child, ctx := tr.StartSpanFromContext(ctx, "child")
+	if sampled := child.Context().Sampled; sampled == nil || !*sampled {
+		t.Errorf("expected child span to be sampled, have %+v", sampled)
+	}
+
+	grandChild, _ := tr.StartSpanFromContext(ctx, "grandchild")
+	grandChild.Finish()
+	child.Finish()
+	parent.Finish()
+
+	if want, have := 3, len(rec.Flush()); want != have {
+		t.Errorf("reported spans want %d, have %d", want, have)
+	}
+}
+
+func TestForceSampleFinished(t *testing.T) {
+	rec := recorder.NewReporter()
+	defer rec.Close()
+
+	tr, _ := NewTracer(rec, WithSampler(AlwaysSample))
+
+	span, ctx := tr.StartSpanFromContext(context.Background(), "finished")
+	span.Finish()
+	ForceSample(ctx)
+	span.Finish()
+
+	if want, have := 1, len(rec.Flush()); want != have {
+		t.Errorf("reported spans want %d, have %d", want, have)
+	}
+
+	// an unsampled span which already finished must not be reported either
+	tr, _ = NewTracer(rec, WithSampler(NeverSample))
+
+	span, ctx = tr.StartSpanFromContext(context.Background(), "unsampled")
+	span.Finish()
+	ForceSample(ctx)
+	span.Finish()
+
+	if want, have := 0, len(rec.Flush()); want != have {
+		t.Errorf("reported spans want %d, have %d", want, have)
+	}
+	if sampled := span.Context().Sampled; sampled == nil || *sampled {
+		t.Errorf("expected finished span to keep its sampling decision, have %+v", sampled)
+	}
+}
+
+func TestForceSampleConcurrentFinish(t *testing.T) {
+	rec := recorder.NewReporter()
+	defer rec.Close()
+
+	tr, _ := NewTracer(rec, WithSampler(NeverSample))
+
+	for i := 0; i < 100; i++ {
+		span, ctx := tr.StartSpanFromContext(context.Background(), "race")
+		done := make(chan struct{})
+		go func() {
+			ForceSample(ctx)
+			close(done)
+		}()
+		span.Finish()
+		span.Flush()
+		<-done
+	}
+
+	// each span is reported at most once by Finish, and at most once by Flush
+	if have := len(rec.Flush()); have > 200 {
+		t.Errorf("reported spans want at most %d, have %d", 200, have)
+	}
+}
+
+func TestSuppress(t *testing.T) {
+	rec := recorder.NewReporter()
+	defer rec.Close()
+
+	tr, _ := NewTracer(rec, WithSampler(AlwaysSample))
+
+	parent, ctx := tr.StartSpanFromContext(context.Background(), "parent")
+
+	ctx = Suppress(ctx)
+
+	child, _ := tr.StartSpanFromContext(ctx, "child")
+	if sampled := child.Context().Sampled; sampled == nil || *sampled {
+		t.Errorf("expected child span to not be sampled, have %+v", sampled)
+	}
+
+	child.Finish()
+	parent.Finish()
+
+	if want, have := 0, len(rec.Flush()); want != have {
+		t.Errorf("reported spans want %d, have %d", want, have)
+	}
+
+	// a suppressed root context must also override debug traces
+	root := Suppress(context.Background())
+	debug, _ := tr.StartSpanFromContext(root, "debug", Parent(model.SpanContext{
+		TraceID: model.TraceID{Low: 1},
+		ID:      model.ID(1),
+		Debug:   true,
+	}))
+	if debug.Context().Debug {
+		t.Error("expected debug flag to be cleared")
+	}
+}
+
+func TestSetTraceTag(t *testing.T) {
+	rec := recorder.NewReporter()
+	defer rec.Close()
+
+	tr, _ := NewTracer(rec)
+
+	root, ctx := tr.StartSpanFromContext(context.Background(), "root")
+	early, _ := tr.StartSpanFromContext(ctx, "early")
+	child, childCtx := tr.StartSpanFromContext(ctx, "child")
+
+	SetTraceTag(childCtx, "customer_id", "42")
+	child.Tag("tier", "gold")
+	SetTraceTag(childCtx, "tier", "silver")
+
+	grandChild, _ := tr.StartSpanFromContext(childCtx, "grandchild")
+	other := tr.StartSpan("other")
+
+	for _, span := range []Span{grandChild, child, early, root, other} {
+		span.Finish()
+	}
+
+	spans := rec.Flush()
+	if want, have := 5, len(spans); want != have {
+		t.Fatalf("reported spans want %d, have %d", want, have)
+	}
+	for _, span := range spans {
+		want := "42"
+		if span.Name == "other" {
+			want = ""
+		}
+		if have := span.Tags["customer_id"]; want != have {
+			t.Errorf("%s: trace tag want %q, have %q", span.Name, want, have)
+		}
+	}
+	if want, have := "gold", spans[1].Tags["tier"]; want != have {
+		t.Errorf("span tag must take precedence, want %q, have %q", want, have)
+	}
+	if want, have := "silver", spans[0].Tags["tier"]; want != have {
+		t.Errorf("trace tag want %q, have %q", want, have)
+	}
+}
+
+func TestWithContextTags(t *testing.T) {
+	rec := recorder.NewReporter()
+	defer rec.Close()
+
+	tr, _ := NewTracer(rec, WithContextTags(true))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	span, _ := tr.StartSpanFromContext(ctx, "canceled")
+	cancel()
+	span.Finish()
+
+	span, _ = tr.StartSpanFromContext(context.Background(), "regular")
+	span.Finish()
+
+	spans := rec.Flush()
+	if want, have := 2, len(spans); want != have {
+		t.Fatalf("reported spans want %d, have %d", want, have)
+	}
+	if want, have := context.Canceled.Error(), spans[0].Tags[string(TagContextError)]; want != have {
+		t.Errorf("context error want %q, have %q", want, have)
+	}
+	remaining, err := time.ParseDuration(spans[0].Tags[string(TagContextDeadlineRemaining)])
+	if err != nil || remaining <= 59*time.Minute || remaining > time.Hour {
+		t.Errorf("unexpected remaining time %q (%v)", spans[0].Tags[string(TagContextDeadlineRemaining)], err)
+	}
+	if want, have := 0, len(spans[1].Tags); want != have {
+		t.Errorf("regular span tags want %d, have %d", want, have)
+	}
+
+	// disabled by default
+	tr, _ = NewTracer(rec)
+	span, _ = tr.StartSpanFromContext(ctx, "disabled")
+	span.Finish()
+	if want, have := 0, len(rec.Flush()[0].Tags); want != have {
+		t.Errorf("tags want %d, have %d", want, have)
+	}
+}
+
+func TestNewContextFromSpanContext(t *testing.T) {
+	rec := recorder.NewReporter()
+	defer rec.Close()
+
+	tr, _ := NewTracer(rec)
+
+	sampled := false
+	sc := model.SpanContext{TraceID: model.TraceID{Low: 1}, ID: 2, Sampled: &sampled}
+	ctx := NewContextFromSpanContext(context.Background(), sc)
+
+	if want, have := sc, SpanFromContext(ctx).Context(); want != have {
+		t.Errorf("SpanContext want %+v, have %+v", want, have)
+	}
+
+	child, _ := tr.StartSpanFromContext(ctx, "child")
+	child.Finish()
+	if want, have := sc.ID, *child.Context().ParentID; want != have {
+		t.Errorf("ParentID want %s, have %s", want, have)
+	}
+	if spans := rec.Flush(); len(spans) != 0 {
+		t.Errorf("unexpected spans reported: %d", len(spans))
+	}
+}
@@ -0,0 +1,39 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zipkin
+
+import "sync/atomic"
+
+// truncatedSpan is returned by StartSpanFromContext in place of spans exceeding
+// the maximum trace depth. It records nothing and carries the context of the
+// deepest span of the trace, which counts the spans not created.
+type truncatedSpan struct {
+	NoopSpan
+	deepest *spanImpl
+}
+
+func newTruncatedSpan(deepest *spanImpl) *truncatedSpan {
+	ts := &truncatedSpan{
+		NoopSpan: NoopSpan{SpanContext: deepest.Context()},
+		deepest:  deepest,
+	}
+	ts.add()
+	return ts
+}
+
+// add counts a span not created on the deepest span.
+func (ts *truncatedSpan) add() {
+	atomic.AddInt32(&ts.deepest.truncated, 1)
+}
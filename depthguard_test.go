@@ -0,0 +1,76 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zipkin
+
+import (
+	"context"
+	"testing"
+
+	"github.com/openzipkin/zipkin-go/reporter/recorder"
+)
+
+func TestMaxTraceDepth(t *testing.T) {
+	rec := recorder.NewReporter()
+	defer rec.Close()
+
+	if _, err := NewTracer(rec, WithMaxTraceDepth(0)); err != ErrInvalidMaxTraceDepth {
+		t.Errorf("tracer creation error want %+v, have %+v", ErrInvalidMaxTraceDepth, err)
+	}
+
+	tr, err := NewTracer(rec, WithMaxTraceDepth(3))
+	if err != nil {
+		t.Fatalf("unexpected tracer creation failure: %+v", err)
+	}
+
+	var recurse func(ctx context.Context, n int)
+	recurse = func(ctx context.Context, n int) {
+		if n == 0 {
+			return
+		}
+		span, ctx := tr.StartSpanFromContext(ctx, "recurse")
+		recurse(ctx, n-1)
+		span.Finish()
+	}
+	recurse(context.Background(), 10)
+
+	// a sibling of the deepest span is created
+	root, ctx := tr.StartSpanFromContext(context.Background(), "root")
+	child, ctx := tr.StartSpanFromContext(ctx, "child")
+	tr.StartSpanFromContext(ctx, "grandchild-1")
+	grandchild, grandchildCtx := tr.StartSpanFromContext(ctx, "grandchild-2")
+	truncated, _ := tr.StartSpanFromContext(grandchildCtx, "truncated")
+	if want, have := grandchild.Context(), truncated.Context(); want.ID != have.ID {
+		t.Errorf("truncated span context want %+v, have %+v", want, have)
+	}
+	grandchild.Finish()
+	child.Finish()
+	root.Finish()
+
+	spans := rec.Flush()
+	if want, have := 6, len(spans); want != have {
+		t.Fatalf("spans want %d, have %d", want, have)
+	}
+	if want, have := "7", spans[0].Tags[string(TagTruncatedSpans)]; want != have {
+		t.Errorf("truncated spans of the deepest span want %q, have %q", want, have)
+	}
+	for _, span := range spans[1:3] {
+		if _, found := span.Tags[string(TagTruncatedSpans)]; found {
+			t.Errorf("unexpected truncated spans tag on span %q", span.Name)
+		}
+	}
+	if want, have := "1", spans[3].Tags[string(TagTruncatedSpans)]; want != have {
+		t.Errorf("truncated spans want %q, have %q", want, have)
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zipkin
+
+// Severity is the classification of the outcome of an instrumented operation.
+type Severity int
+
+// Available severities
+const (
+	// SeverityNone marks a successful operation, nothing is recorded.
+	SeverityNone Severity = iota
+	// SeverityExpected marks a failure which is part of the regular behavior,
+	// e.g. a 404 response. It is recorded using the TagErrorExpected tag, so
+	// the span is not flagged as failed.
+	SeverityExpected
+	// SeverityError marks a failed operation, recorded using the TagError tag.
+	SeverityError
+)
+
+// ErrorClassifier maps the outcome of an operation, an error and a protocol
+// status code such as a HTTP status code or gRPC status code, to a Severity.
+// Middlewares accept an ErrorClassifier so conventions like treating 4xx
+// responses or specific business errors as expected are configured once.
+type ErrorClassifier interface {
+	Classify(err error, statusCode int) Severity
+}
+
+// ErrorClassifierFunc is an adapter to use ordinary functions as
+// ErrorClassifier.
+type ErrorClassifierFunc func(err error, statusCode int) Severity
+
+// Classify calls fn(err, statusCode).
+func (fn ErrorClassifierFunc) Classify(err error, statusCode int) Severity {
+	return fn(err, statusCode)
+}
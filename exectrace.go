@@ -0,0 +1,50 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zipkin
+
+import (
+	"context"
+	"runtime/trace"
+)
+
+// Categories of the execution trace log messages recorded for spans by
+// WithExecutionTrace.
+const (
+	ExecutionTraceTraceID = "zipkin.traceId"
+	ExecutionTraceSpanID  = "zipkin.spanId"
+)
+
+// WithExecutionTrace creates a runtime/trace task for every sampled span
+// started by StartSpanFromContext while a Go execution trace is being
+// recorded, so the trace shown by go tool trace can be correlated with Zipkin
+// spans. The task is named after the span, logs the trace and span id and is
+// ended when the span finishes. Tasks of child spans are nested within the
+// task of their parent and regions started with runtime/trace using the
+// context returned by StartSpanFromContext are attributed to the span's task.
+func WithExecutionTrace(enabled bool) TracerOption {
+	return func(o *Tracer) error {
+		o.executionTrace = enabled
+		return nil
+	}
+}
+
+// startTask creates the execution trace task of the span and returns the
+// context holding it.
+func (s *spanImpl) startTask(ctx context.Context) context.Context {
+	ctx, s.task = trace.NewTask(ctx, s.Name)
+	trace.Log(ctx, ExecutionTraceTraceID, s.TraceID.String())
+	trace.Log(ctx, ExecutionTraceSpanID, s.ID.String())
+	return ctx
+}
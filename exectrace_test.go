@@ -0,0 +1,60 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zipkin_test
+
+import (
+	"bytes"
+	"context"
+	"runtime/trace"
+	"testing"
+
+	"github.com/openzipkin/zipkin-go"
+	"github.com/openzipkin/zipkin-go/reporter/recorder"
+)
+
+func TestWithExecutionTrace(t *testing.T) {
+	rec := recorder.NewReporter()
+	defer rec.Close()
+
+	tracer, err := zipkin.NewTracer(rec, zipkin.WithExecutionTrace(true))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err = trace.Start(&buf); err != nil {
+		t.Skipf("execution trace not available: %v", err)
+	}
+
+	parent, ctx := tracer.StartSpanFromContext(context.Background(), "exectrace-parent")
+	child, childCtx := tracer.StartSpanFromContext(ctx, "exectrace-child")
+	trace.WithRegion(childCtx, "exectrace-region", func() {})
+	child.Finish()
+	parent.Finish()
+
+	trace.Stop()
+
+	for _, want := range []string{
+		"exectrace-parent", "exectrace-child", "exectrace-region",
+		zipkin.ExecutionTraceTraceID, parent.Context().TraceID.String(),
+	} {
+		if !bytes.Contains(buf.Bytes(), []byte(want)) {
+			t.Errorf("expected %q in execution trace", want)
+		}
+	}
+	if want, have := 2, len(rec.Flush()); want != have {
+		t.Errorf("reported spans want %d, have %d", want, have)
+	}
+}
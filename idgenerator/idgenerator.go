@@ -42,6 +42,16 @@ type IDGenerator interface {
 	TraceID() model.TraceID                // Generates a new Trace ID
 }
 
+// ParentAwareIDGenerator is an optional extension of IDGenerator. If implemented,
+// the Zipkin Tracer uses ChildSpanID to generate the span ID of child spans,
+// providing the SpanContext of the parent. This allows implementations to
+// generate IDs correlated with the trace, e.g. by encoding a prefix derived
+// from the trace ID for downstream sharding schemes.
+type ParentAwareIDGenerator interface {
+	IDGenerator
+	ChildSpanID(parent model.SpanContext) model.ID // Generates a new child Span ID
+}
+
 // NewRandom64 returns an ID Generator which can generate 64 bit trace and span
 // id's
 func NewRandom64() IDGenerator {
@@ -60,6 +70,21 @@ func NewRandomTimestamped() IDGenerator {
 	return &randomTimestamped{}
 }
 
+// NewRandomPrefixed returns an ID Generator which can generate 128 bit trace
+// id's carrying the provided prefix in their 16 most significant bits and 64
+// bit span id's. Multi-region deployments can use a region or site code as
+// prefix to attribute traces to their origin region without extra tags, see
+// TraceIDPrefix.
+func NewRandomPrefixed(prefix uint16) IDGenerator {
+	return &randomPrefixed{prefix: uint64(prefix) << 48}
+}
+
+// TraceIDPrefix returns the prefix of a trace id generated by the
+// NewRandomPrefixed ID Generator.
+func TraceIDPrefix(traceID model.TraceID) uint16 {
+	return uint16(traceID.High >> 48)
+}
+
 // randomID64 can generate 64 bit traceid's and 64 bit spanid's.
 type randomID64 struct{}
 
@@ -128,3 +153,29 @@ func (t *randomTimestamped) SpanID(traceID model.TraceID) (id model.ID) {
 	seededIDLock.Unlock()
 	return
 }
+
+// randomPrefixed can generate 128 bit traceid's with a fixed 16 bit prefix and
+// 64 bit spanid's.
+type randomPrefixed struct {
+	prefix uint64
+}
+
+func (p *randomPrefixed) TraceID() (id model.TraceID) {
+	seededIDLock.Lock()
+	id = model.TraceID{
+		High: p.prefix | uint64(seededIDGen.Int63())>>16,
+		Low:  uint64(seededIDGen.Int63()),
+	}
+	seededIDLock.Unlock()
+	return
+}
+
+func (p *randomPrefixed) SpanID(traceID model.TraceID) (id model.ID) {
+	if !traceID.Empty() {
+		return model.ID(traceID.Low)
+	}
+	seededIDLock.Lock()
+	id = model.ID(seededIDGen.Int63())
+	seededIDLock.Unlock()
+	return
+}
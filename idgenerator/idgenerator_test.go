@@ -128,3 +128,29 @@ func TestRandomTimeStamped(t *testing.T) {
 	}
 
 }
+
+func TestRandomPrefixed(t *testing.T) {
+	for _, prefix := range []uint16{0, 1, 0x0e1, 0xffff} {
+		gen := idgenerator.NewRandomPrefixed(prefix)
+
+		for i := 0; i < 100; i++ {
+			traceID := gen.TraceID()
+
+			if traceID.Low == 0 {
+				t.Error("Expected TraceID.Low to have value, got 0")
+			}
+
+			if want, have := prefix, idgenerator.TraceIDPrefix(traceID); want != have {
+				t.Errorf("Expected TraceID prefix %x, got %x", want, have)
+			}
+
+			if want, have := model.ID(traceID.Low), gen.SpanID(traceID); want != have {
+				t.Errorf("Expected root span to have span ID %d, got %d", want, have)
+			}
+		}
+
+		if spanID := gen.SpanID(model.TraceID{}); spanID == 0 {
+			t.Errorf("Expected child span to have a valid span ID, got 0")
+		}
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zipkin
+
+import (
+	"log"
+	"sync"
+
+	"github.com/openzipkin/zipkin-go/model"
+)
+
+// spanIDTracker remembers the most recently generated span IDs to detect
+// duplicates. Once full, the oldest IDs are evicted.
+type spanIDTracker struct {
+	mtx         sync.Mutex
+	ids         map[model.ID]struct{}
+	ring        []model.ID
+	next        int
+	onDuplicate func(model.SpanContext)
+}
+
+func newSpanIDTracker(size int, onDuplicate func(model.SpanContext)) *spanIDTracker {
+	if onDuplicate == nil {
+		onDuplicate = func(sc model.SpanContext) {
+			log.Printf("zipkin: duplicate span id %s generated in trace %s", sc.ID, sc.TraceID)
+		}
+	}
+	return &spanIDTracker{
+		ids:         make(map[model.ID]struct{}, size),
+		ring:        make([]model.ID, 0, size),
+		onDuplicate: onDuplicate,
+	}
+}
+
+// track registers the span ID of sc and invokes the duplicate handler if the
+// ID was seen before.
+func (t *spanIDTracker) track(sc model.SpanContext) {
+	t.mtx.Lock()
+	_, found := t.ids[sc.ID]
+	if !found {
+		if len(t.ring) < cap(t.ring) {
+			t.ring = append(t.ring, sc.ID)
+		} else {
+			delete(t.ids, t.ring[t.next])
+			t.ring[t.next] = sc.ID
+			t.next = (t.next + 1) % len(t.ring)
+		}
+		t.ids[sc.ID] = struct{}{}
+	}
+	t.mtx.Unlock()
+
+	if found {
+		t.onDuplicate(sc)
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zipkin
+
+import (
+	"testing"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter"
+)
+
+type sequenceIDs struct {
+	ids []model.ID
+	pos int
+}
+
+func (s *sequenceIDs) TraceID() model.TraceID {
+	return model.TraceID{Low: 1}
+}
+
+func (s *sequenceIDs) SpanID(model.TraceID) model.ID {
+	id := s.ids[s.pos%len(s.ids)]
+	s.pos++
+	return id
+}
+
+func TestDuplicateSpanIDDetection(t *testing.T) {
+	rep := reporter.NewNoopReporter()
+	defer rep.Close()
+
+	if _, err := NewTracer(rep, WithDuplicateSpanIDDetection(0, nil)); err != ErrInvalidTrackerSize {
+		t.Errorf("tracer creation error want %+v, have %+v", ErrInvalidTrackerSize, err)
+	}
+
+	var duplicates []model.ID
+	tr, err := NewTracer(
+		rep,
+		WithIDGenerator(&sequenceIDs{ids: []model.ID{1, 2, 3, 1, 4, 5, 6, 2}}),
+		WithDuplicateSpanIDDetection(3, func(sc model.SpanContext) {
+			duplicates = append(duplicates, sc.ID)
+		}),
+	)
+	if err != nil {
+		t.Fatalf("unexpected tracer creation failure: %+v", err)
+	}
+
+	for i := 0; i < 8; i++ {
+		tr.StartSpan("root").Finish()
+	}
+
+	// id 1 is detected while id 2 was evicted by the time it reappears
+	if want, have := 1, len(duplicates); want != have {
+		t.Fatalf("duplicates want %d, have %d", want, have)
+	}
+	if want, have := model.ID(1), duplicates[0]; want != have {
+		t.Errorf("duplicate id want %d, have %d", want, have)
+	}
+
+	// joined server spans reuse the client's span id and must not be flagged
+	parent := tr.StartSpan("parent").Context()
+	duplicates = nil
+	tr.StartSpan("server", Kind(model.Server), Parent(parent)).Finish()
+	if len(duplicates) != 0 {
+		t.Errorf("unexpected duplicates for shared span: %+v", duplicates)
+	}
+}
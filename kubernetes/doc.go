@@ -0,0 +1,47 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package kubernetes implements helpers for enriching spans with workload
+metadata made available to a pod through the Kubernetes downward API.
+
+The metadata is read from environment variables and files which are typically
+set up in the pod spec like this:
+
+	env:
+	  - name: POD_NAME
+	    valueFrom:
+	      fieldRef:
+	        fieldPath: metadata.name
+	  - name: POD_NAMESPACE
+	    valueFrom:
+	      fieldRef:
+	        fieldPath: metadata.namespace
+	  - name: NODE_NAME
+	    valueFrom:
+	      fieldRef:
+	        fieldPath: spec.nodeName
+	volumes:
+	  - name: podinfo
+	    downwardAPI:
+	      items:
+	        - path: "labels"
+	          fieldRef:
+	            fieldPath: metadata.labels
+
+If POD_NAMESPACE is not set, the namespace is read from the service account
+token mount. If DEPLOYMENT_NAME is not set, the deployment name is derived from
+the pod name when it follows the naming scheme of ReplicaSet managed pods.
+*/
+package kubernetes
@@ -0,0 +1,207 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	zipkin "github.com/openzipkin/zipkin-go"
+)
+
+// Tag keys used for the Kubernetes metadata.
+const (
+	TagNamespace   = "k8s.namespace.name"
+	TagPodName     = "k8s.pod.name"
+	TagNodeName    = "k8s.node.name"
+	TagDeployment  = "k8s.deployment.name"
+	TagLabelPrefix = "k8s.pod.label."
+)
+
+// Default environment variables and files the metadata is read from.
+const (
+	EnvPodName           = "POD_NAME"
+	EnvNamespace         = "POD_NAMESPACE"
+	EnvNodeName          = "NODE_NAME"
+	EnvDeployment        = "DEPLOYMENT_NAME"
+	DefaultNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+	DefaultLabelsFile    = "/etc/podinfo/labels"
+)
+
+var (
+	defaultOnce sync.Once
+	defaultTags map[string]string
+)
+
+type config struct {
+	namespaceFile string
+	labelsFile    string
+	labels        map[string]bool
+}
+
+// Option allows for functional options to adjust which metadata is read.
+type Option func(c *config)
+
+// NamespaceFile sets the file to read the namespace from if the namespace
+// environment variable is not set.
+func NamespaceFile(path string) Option {
+	return func(c *config) {
+		c.namespaceFile = path
+	}
+}
+
+// LabelsFile sets the downward API volume file holding the pod labels.
+func LabelsFile(path string) Option {
+	return func(c *config) {
+		c.labelsFile = path
+	}
+}
+
+// Labels sets the pod label keys to add as tags. By default no labels are
+// added as the label set of a pod is usually large.
+func Labels(keys ...string) Option {
+	return func(c *config) {
+		for _, key := range keys {
+			c.labels[key] = true
+		}
+	}
+}
+
+// Tags returns the Kubernetes metadata of the current pod as span tags. Only
+// metadata which could be found is returned. When called without options the
+// metadata is read once and cached for the lifetime of the process.
+func Tags(opts ...Option) map[string]string {
+	if len(opts) > 0 {
+		return load(opts...)
+	}
+	defaultOnce.Do(func() {
+		defaultTags = load()
+	})
+	tags := make(map[string]string, len(defaultTags))
+	for k, v := range defaultTags {
+		tags[k] = v
+	}
+	return tags
+}
+
+// WithTags returns a TracerOption which adds the Kubernetes metadata of the
+// current pod as default tags to each span created by the tracer.
+func WithTags(opts ...Option) zipkin.TracerOption {
+	return zipkin.WithTags(Tags(opts...))
+}
+
+func load(opts ...Option) map[string]string {
+	c := &config{
+		namespaceFile: DefaultNamespaceFile,
+		labelsFile:    DefaultLabelsFile,
+		labels:        make(map[string]bool),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	tags := make(map[string]string)
+
+	namespace := os.Getenv(EnvNamespace)
+	if namespace == "" && c.namespaceFile != "" {
+		if b, err := ioutil.ReadFile(c.namespaceFile); err == nil {
+			namespace = strings.TrimSpace(string(b))
+		}
+	}
+	setTag(tags, TagNamespace, namespace)
+
+	podName := os.Getenv(EnvPodName)
+	setTag(tags, TagPodName, podName)
+	setTag(tags, TagNodeName, os.Getenv(EnvNodeName))
+
+	deployment := os.Getenv(EnvDeployment)
+	if deployment == "" {
+		deployment = deploymentFromPodName(podName)
+	}
+	setTag(tags, TagDeployment, deployment)
+
+	if len(c.labels) > 0 && c.labelsFile != "" {
+		for k, v := range readLabels(c.labelsFile) {
+			if c.labels[k] {
+				setTag(tags, TagLabelPrefix+k, v)
+			}
+		}
+	}
+
+	return tags
+}
+
+func setTag(tags map[string]string, key, value string) {
+	if value != "" {
+		tags[key] = value
+	}
+}
+
+// deploymentFromPodName strips the pod template hash and the random suffix
+// from the name of a pod managed by a Deployment's ReplicaSet, e.g.
+// "checkout-5d8f7c9b6-x2b4k" yields "checkout".
+func deploymentFromPodName(podName string) string {
+	parts := strings.Split(podName, "-")
+	if len(parts) < 3 {
+		return ""
+	}
+	suffix, hash := parts[len(parts)-1], parts[len(parts)-2]
+	if len(suffix) != 5 || len(hash) < 6 || len(hash) > 10 {
+		return ""
+	}
+	if !isAlphaNumeric(suffix) || !isAlphaNumeric(hash) {
+		return ""
+	}
+	return strings.Join(parts[:len(parts)-2], "-")
+}
+
+func isAlphaNumeric(s string) bool {
+	for _, r := range s {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// readLabels parses the downward API labels file which holds one key="value"
+// pair per line.
+func readLabels(path string) map[string]string {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	labels := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		idx := strings.IndexByte(line, '=')
+		if idx < 1 {
+			continue
+		}
+		value, err := strconv.Unquote(line[idx+1:])
+		if err != nil {
+			value = line[idx+1:]
+		}
+		labels[line[:idx]] = value
+	}
+	return labels
+}
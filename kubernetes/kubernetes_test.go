@@ -0,0 +1,101 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDeploymentFromPodName(t *testing.T) {
+	for podName, want := range map[string]string{
+		"checkout-5d8f7c9b6-x2b4k":     "checkout",
+		"payment-api-7c9d8f5b4d-9qwer": "payment-api",
+		"standalone":                   "",
+		"statefulset-0":                "",
+		"job-name-abc-x2b4k":           "",
+		"":                             "",
+	} {
+		if have := deploymentFromPodName(podName); want != have {
+			t.Errorf("deployment for %q want %q, have %q", podName, want, have)
+		}
+	}
+}
+
+func TestTags(t *testing.T) {
+	dir, err := ioutil.TempDir("", "podinfo")
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	namespaceFile := filepath.Join(dir, "namespace")
+	if err = ioutil.WriteFile(namespaceFile, []byte("shop\n"), 0644); err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	labelsFile := filepath.Join(dir, "labels")
+	labels := "app=\"checkout\"\nteam=\"payments\"\npod-template-hash=\"5d8f7c9b6\"\n"
+	if err = ioutil.WriteFile(labelsFile, []byte(labels), 0644); err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+
+	os.Setenv(EnvPodName, "checkout-5d8f7c9b6-x2b4k")
+	os.Setenv(EnvNodeName, "node-1")
+	defer os.Unsetenv(EnvPodName)
+	defer os.Unsetenv(EnvNodeName)
+
+	have := Tags(
+		NamespaceFile(namespaceFile),
+		LabelsFile(labelsFile),
+		Labels("app", "team", "missing"),
+	)
+
+	want := map[string]string{
+		TagNamespace:            "shop",
+		TagPodName:              "checkout-5d8f7c9b6-x2b4k",
+		TagNodeName:             "node-1",
+		TagDeployment:           "checkout",
+		TagLabelPrefix + "app":  "checkout",
+		TagLabelPrefix + "team": "payments",
+	}
+
+	if !reflect.DeepEqual(want, have) {
+		t.Errorf("tags want %+v, have %+v", want, have)
+	}
+
+	os.Setenv(EnvNamespace, "override")
+	os.Setenv(EnvDeployment, "explicit")
+	defer os.Unsetenv(EnvNamespace)
+	defer os.Unsetenv(EnvDeployment)
+
+	have = Tags(NamespaceFile(namespaceFile))
+
+	if want, have := "override", have[TagNamespace]; want != have {
+		t.Errorf("namespace want %q, have %q", want, have)
+	}
+	if want, have := "explicit", have[TagDeployment]; want != have {
+		t.Errorf("deployment want %q, have %q", want, have)
+	}
+}
+
+func TestTagsOutsideKubernetes(t *testing.T) {
+	have := Tags(NamespaceFile(""), LabelsFile(""))
+	if len(have) != 0 {
+		t.Errorf("expected no tags, have %+v", have)
+	}
+}
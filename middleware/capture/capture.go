@@ -0,0 +1,160 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capture
+
+import (
+	"io"
+	"mime"
+	"strings"
+	"sync"
+	"time"
+
+	zipkin "github.com/openzipkin/zipkin-go"
+	"github.com/openzipkin/zipkin-go/model"
+)
+
+// DefaultMaxSize is the maximum number of payload bytes recorded if
+// Config.MaxSize is not set.
+const DefaultMaxSize = 1024
+
+// Annotation prefixes of the recorded payloads.
+const (
+	AnnotationRequest  = "request.payload"
+	AnnotationResponse = "response.payload"
+)
+
+// truncated marks payloads exceeding the maximum size.
+const truncated = "..."
+
+// DefaultContentTypes are the content types captured if Config.ContentTypes
+// is not set.
+var DefaultContentTypes = []string{"application/json", "text/*"}
+
+// Redactor returns payload with sensitive data removed. The payload passed is
+// already truncated to the maximum size, so it may end in the middle of a
+// value to redact.
+type Redactor func(contentType string, payload []byte) []byte
+
+// Config configures payload capturing.
+type Config struct {
+	// MaxSize is the maximum number of bytes recorded per payload. Defaults to
+	// DefaultMaxSize.
+	MaxSize int
+	// ContentTypes holds the media types of the payloads captured, e.g.
+	// "application/json", or a type with a wildcard subtype, e.g. "text/*".
+	// Defaults to DefaultContentTypes.
+	ContentTypes []string
+	// Redact is called for every payload before it is recorded, if set.
+	Redact Redactor
+}
+
+// Enabled reports whether payloads are captured for spans with context sc,
+// i.e. whether the trace has the debug flag.
+func Enabled(sc model.SpanContext) bool {
+	return sc.Debug
+}
+
+// Allowed reports whether payloads of contentType are captured.
+func (c Config) Allowed(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	allowed := c.ContentTypes
+	if len(allowed) == 0 {
+		allowed = DefaultContentTypes
+	}
+	for _, t := range allowed {
+		t = strings.ToLower(t)
+		if t == mediaType || (strings.HasSuffix(t, "/*") && strings.HasPrefix(mediaType, t[:len(t)-1])) {
+			return true
+		}
+	}
+	return false
+}
+
+// Annotate records payload on span as annotation named name at t, truncated
+// to the maximum size and redacted. Empty payloads are not recorded.
+func (c Config) Annotate(span zipkin.Span, t time.Time, name, contentType string, payload []byte) {
+	if len(payload) == 0 {
+		return
+	}
+	var suffix string
+	if max := c.maxSize(); len(payload) > max {
+		payload, suffix = payload[:max], truncated
+	}
+	if c.Redact != nil {
+		payload = c.Redact(contentType, append([]byte(nil), payload...))
+	}
+	span.Annotate(t, name+": "+string(payload)+suffix)
+}
+
+// NewBuffer returns a Buffer keeping as many bytes as needed by Annotate.
+func (c Config) NewBuffer() *Buffer {
+	return &Buffer{max: c.maxSize() + 1}
+}
+
+func (c Config) maxSize() int {
+	if c.MaxSize > 0 {
+		return c.MaxSize
+	}
+	return DefaultMaxSize
+}
+
+// Buffer is an io.Writer keeping the first bytes written to it, used to
+// capture streamed payloads. It is safe for concurrent use.
+type Buffer struct {
+	mtx sync.Mutex
+	max int
+	buf []byte
+}
+
+// Write keeps the bytes of p fitting into the buffer and discards the rest.
+// It never fails.
+func (b *Buffer) Write(p []byte) (int, error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if n := b.max - len(b.buf); n > 0 {
+		if len(p) < n {
+			n = len(p)
+		}
+		b.buf = append(b.buf, p[:n]...)
+	}
+	return len(p), nil
+}
+
+// Peek reads from r until the buffer is full or r is exhausted and returns the
+// bytes read, so they can be handed on together with the rest of r.
+func (b *Buffer) Peek(r io.Reader) ([]byte, error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	p := make([]byte, b.max-len(b.buf))
+	n, err := io.ReadFull(r, p)
+	b.buf = append(b.buf, p[:n]...)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = nil
+	}
+	return p[:n], err
+}
+
+// Bytes returns the bytes kept.
+func (b *Buffer) Bytes() []byte {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	return b.buf
+}
@@ -0,0 +1,73 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capture_test
+
+import (
+	"testing"
+	"time"
+
+	zipkin "github.com/openzipkin/zipkin-go"
+	"github.com/openzipkin/zipkin-go/middleware/capture"
+	"github.com/openzipkin/zipkin-go/reporter/recorder"
+)
+
+func TestAllowed(t *testing.T) {
+	for _, tc := range []struct {
+		types       []string
+		contentType string
+		want        bool
+	}{
+		{nil, "application/json", true},
+		{nil, "text/plain; charset=utf-8", true},
+		{nil, "application/octet-stream", false},
+		{nil, "", false},
+		{[]string{"application/grpc"}, "application/grpc", true},
+		{[]string{"application/grpc"}, "application/json", false},
+		{[]string{"Application/*"}, "application/xml", true},
+	} {
+		c := capture.Config{ContentTypes: tc.types}
+		if want, have := tc.want, c.Allowed(tc.contentType); want != have {
+			t.Errorf("%v %q: want %t, have %t", tc.types, tc.contentType, want, have)
+		}
+	}
+}
+
+func TestBuffer(t *testing.T) {
+	c := capture.Config{MaxSize: 4}
+	b := c.NewBuffer()
+	for _, p := range []string{"ab", "cdef", "gh"} {
+		if n, err := b.Write([]byte(p)); n != len(p) || err != nil {
+			t.Errorf("write %q: unexpected result %d, %v", p, n, err)
+		}
+	}
+	if want, have := "abcde", string(b.Bytes()); want != have {
+		t.Errorf("bytes want %q, have %q", want, have)
+	}
+
+	rec := recorder.NewReporter()
+	tr, _ := zipkin.NewTracer(rec)
+	span := tr.StartSpan("capture")
+	c.Annotate(span, time.Now(), capture.AnnotationResponse, "text/plain", b.Bytes())
+	c.Annotate(span, time.Now(), capture.AnnotationRequest, "text/plain", nil)
+	span.Finish()
+
+	spans := rec.Flush()
+	if want, have := 1, len(spans[0].Annotations); want != have {
+		t.Fatalf("annotations want %d, have %d", want, have)
+	}
+	if want, have := "response.payload: abcd...", spans[0].Annotations[0].Value; want != have {
+		t.Errorf("annotation want %q, have %q", want, have)
+	}
+}
@@ -0,0 +1,25 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package capture records request and response payloads of debug traces as span
+annotations, for deep debugging of specific requests flagged with the B3 debug
+flag. It is used by the http and grpc middleware, where capturing is disabled
+unless a Config is passed using their capture options.
+
+Payloads are only captured for allowed content types and truncated to a
+maximum size. A Redactor can remove sensitive data before payloads are
+recorded.
+*/
+package capture
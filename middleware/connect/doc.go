@@ -0,0 +1,29 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package connect contains middlewares for instrumenting Connect RPC
+(https://connectrpc.com) clients and handlers with Zipkin.
+
+As Connect is built on plain HTTP, the middlewares wrap the http.Handler
+returned by the generated handler constructors and the http.RoundTripper of
+the http.Client handed to the generated client constructors. They support the
+Connect, gRPC and gRPC-Web protocols for unary as well as streaming calls and
+tag the resulting Connect status code.
+
+For applications already depending on connect-go, the interceptor subpackage,
+a separate module, provides a connect.Interceptor to register with
+connect.WithInterceptors instead.
+*/
+package connect
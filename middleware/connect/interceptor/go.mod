@@ -0,0 +1,15 @@
+module github.com/openzipkin/zipkin-go/middleware/connect/interceptor
+
+require (
+	connectrpc.com/connect v1.16.2
+	github.com/openzipkin/zipkin-go v0.1.6
+)
+
+require (
+	google.golang.org/grpc v1.20.0 // indirect
+	google.golang.org/protobuf v1.33.0 // indirect
+)
+
+replace github.com/openzipkin/zipkin-go => ../../..
+
+go 1.19
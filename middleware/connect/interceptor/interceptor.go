@@ -0,0 +1,225 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package interceptor implements a connect-go (https://connectrpc.com)
+interceptor instrumenting Connect clients and handlers with Zipkin for unary
+and streaming calls. Register it using connect.WithInterceptors when
+constructing generated clients and handlers.
+
+The package is a separate module to avoid adding the connect-go dependency to
+all users of zipkin-go. For instrumentation at the HTTP level without the
+dependency see the parent connect package.
+*/
+package interceptor
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"connectrpc.com/connect"
+
+	zipkin "github.com/openzipkin/zipkin-go"
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/propagation/b3"
+	"github.com/openzipkin/zipkin-go/propagation/w3c"
+)
+
+// ErrValidTracerRequired error
+var ErrValidTracerRequired = errors.New("valid tracer required")
+
+type interceptor struct {
+	tracer            zipkin.TracerInterface
+	defaultTags       map[string]string
+	remoteServiceName string
+	injectW3C         bool
+}
+
+// Option allows the interceptor to be optionally configured.
+type Option func(*interceptor)
+
+// Tags adds default Tags to inject into client and server spans.
+func Tags(tags map[string]string) Option {
+	return func(i *interceptor) {
+		i.defaultTags = tags
+	}
+}
+
+// WithRemoteServiceName will set the value for the remote endpoint's service
+// name on client spans.
+func WithRemoteServiceName(name string) Option {
+	return func(i *interceptor) {
+		i.remoteServiceName = name
+	}
+}
+
+// InjectW3C enables injecting the W3C traceparent header next to the B3
+// headers on client calls.
+func InjectW3C(enabled bool) Option {
+	return func(i *interceptor) {
+		i.injectW3C = enabled
+	}
+}
+
+// NewInterceptor returns a connect.Interceptor creating client spans for calls
+// made by clients and server spans for calls handled by handlers. Spans are
+// named package.Service.Method and failed calls are tagged with their Connect
+// error code.
+func NewInterceptor(tracer zipkin.TracerInterface, options ...Option) (connect.Interceptor, error) {
+	if tracer == nil {
+		return nil, ErrValidTracerRequired
+	}
+
+	i := &interceptor{tracer: tracer}
+	for _, option := range options {
+		option(i)
+	}
+	return i, nil
+}
+
+func (i *interceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		var sp zipkin.Span
+		if req.Spec().IsClient {
+			sp, ctx = i.startClientSpan(ctx, req.Spec(), req.Header())
+		} else {
+			sp, ctx = i.startServerSpan(ctx, req.Spec(), req.Peer(), req.Header())
+		}
+		res, err := next(ctx, req)
+		tagError(sp, err)
+		sp.Finish()
+		return res, err
+	}
+}
+
+func (i *interceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return func(ctx context.Context, spec connect.Spec) connect.StreamingClientConn {
+		sp, ctx := i.startSpan(ctx, spec, model.Client)
+		conn := next(ctx, spec)
+		// request headers are sent with the first message
+		i.inject(conn.RequestHeader(), sp.Context())
+		return &streamingClientConn{StreamingClientConn: conn, sp: sp}
+	}
+}
+
+func (i *interceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return func(ctx context.Context, conn connect.StreamingHandlerConn) error {
+		sp, ctx := i.startServerSpan(ctx, conn.Spec(), conn.Peer(), conn.RequestHeader())
+		err := next(ctx, conn)
+		tagError(sp, err)
+		sp.Finish()
+		return err
+	}
+}
+
+func (i *interceptor) startClientSpan(
+	ctx context.Context, spec connect.Spec, header http.Header,
+) (zipkin.Span, context.Context) {
+	sp, ctx := i.startSpan(ctx, spec, model.Client)
+	i.inject(header, sp.Context())
+	return sp, ctx
+}
+
+func (i *interceptor) startServerSpan(
+	ctx context.Context, spec connect.Spec, peer connect.Peer, header http.Header,
+) (zipkin.Span, context.Context) {
+	// the propagation packages operate on requests
+	r := &http.Request{Header: header}
+	sc := i.tracer.Extract(b3.ExtractHTTP(r))
+	if sc.TraceID.Empty() && sc.Err == nil && header.Get(w3c.TraceParent) != "" {
+		sc = i.tracer.Extract(w3c.ExtractHTTP(r))
+	}
+
+	options := []zipkin.SpanOption{zipkin.Kind(model.Server), zipkin.Parent(sc)}
+	if remoteEndpoint, err := zipkin.NewEndpoint("", peer.Addr); err == nil {
+		options = append(options, zipkin.RemoteEndpoint(remoteEndpoint))
+	}
+
+	sp := i.tracer.StartSpan(spanName(spec.Procedure), options...)
+	for k, v := range i.defaultTags {
+		sp.Tag(k, v)
+	}
+	return sp, zipkin.NewContext(ctx, sp)
+}
+
+func (i *interceptor) startSpan(
+	ctx context.Context, spec connect.Spec, kind model.Kind,
+) (zipkin.Span, context.Context) {
+	sp, ctx := i.tracer.StartSpanFromContext(ctx, spanName(spec.Procedure), zipkin.Kind(kind))
+	if i.remoteServiceName != "" {
+		sp.SetRemoteEndpoint(&model.Endpoint{ServiceName: i.remoteServiceName})
+	}
+	for k, v := range i.defaultTags {
+		sp.Tag(k, v)
+	}
+	return sp, ctx
+}
+
+func (i *interceptor) inject(header http.Header, sc model.SpanContext) {
+	r := &http.Request{Header: header}
+	_ = b3.InjectHTTP(r)(sc)
+	if i.injectW3C {
+		_ = w3c.InjectHTTP(r)(sc)
+	}
+}
+
+// streamingClientConn finishes the span of a streaming call once the response
+// has been fully received or closed.
+type streamingClientConn struct {
+	connect.StreamingClientConn
+	sp   zipkin.Span
+	once sync.Once
+}
+
+func (c *streamingClientConn) Receive(msg any) error {
+	err := c.StreamingClientConn.Receive(msg)
+	if errors.Is(err, io.EOF) {
+		c.finish(nil)
+	} else if err != nil {
+		c.finish(err)
+	}
+	return err
+}
+
+func (c *streamingClientConn) CloseResponse() error {
+	err := c.StreamingClientConn.CloseResponse()
+	c.finish(nil)
+	return err
+}
+
+func (c *streamingClientConn) finish(err error) {
+	c.once.Do(func() {
+		tagError(c.sp, err)
+		c.sp.Finish()
+	})
+}
+
+func spanName(procedure string) string {
+	name := strings.TrimPrefix(procedure, "/")
+	return strings.Replace(name, "/", ".", -1)
+}
+
+func tagError(sp zipkin.Span, err error) {
+	if err == nil {
+		return
+	}
+	// Uppercase for consistency with the gRPC middleware
+	code := strings.ToUpper(connect.CodeOf(err).String())
+	zipkin.TagGRPCStatusCode.Set(sp, code)
+	zipkin.TagError.Set(sp, code)
+}
@@ -0,0 +1,73 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interceptor_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"connectrpc.com/connect"
+
+	zipkin "github.com/openzipkin/zipkin-go"
+	"github.com/openzipkin/zipkin-go/middleware/connect/interceptor"
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/propagation/b3"
+	"github.com/openzipkin/zipkin-go/reporter/recorder"
+)
+
+type greeting struct{}
+
+func TestUnaryHandler(t *testing.T) {
+	rec := recorder.NewReporter()
+	tr, _ := zipkin.NewTracer(rec)
+	i, err := interceptor.NewInterceptor(tr)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var sc model.SpanContext
+	call := i.WrapUnary(func(ctx context.Context, _ connect.AnyRequest) (connect.AnyResponse, error) {
+		sc = zipkin.SpanFromContext(ctx).Context()
+		return nil, connect.NewError(connect.CodeNotFound, errors.New("no greeting"))
+	})
+
+	req := connect.NewRequest(&greeting{})
+	req.Header().Set(b3.TraceID, "000000000000007b")
+	req.Header().Set(b3.SpanID, "00000000000001c8")
+	if _, err = call(context.Background(), req); connect.CodeOf(err) != connect.CodeNotFound {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if want, have := (model.TraceID{Low: 123}), sc.TraceID; want != have {
+		t.Errorf("trace id want %s, have %s", want, have)
+	}
+	spans := rec.Flush()
+	if want, have := 1, len(spans); want != have {
+		t.Fatalf("spans want %d, have %d", want, have)
+	}
+	if want, have := model.Server, spans[0].Kind; want != have {
+		t.Errorf("span kind want %q, have %q", want, have)
+	}
+	if want, have := "NOT_FOUND", spans[0].Tags[string(zipkin.TagError)]; want != have {
+		t.Errorf("error tag want %q, have %q", want, have)
+	}
+}
+
+func TestTracerRequired(t *testing.T) {
+	if _, err := interceptor.NewInterceptor(nil); err != interceptor.ErrValidTracerRequired {
+		t.Errorf("want %v, have %v", interceptor.ErrValidTracerRequired, err)
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connect
+
+import (
+	"net/http"
+
+	zipkin "github.com/openzipkin/zipkin-go"
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/propagation/b3"
+	"github.com/openzipkin/zipkin-go/propagation/w3c"
+)
+
+type handler struct {
+	tracer      zipkin.TracerInterface
+	next        http.Handler
+	defaultTags map[string]string
+}
+
+// ServerOption allows Middleware to be optionally configured.
+type ServerOption func(*handler)
+
+// ServerTags adds default Tags to inject into server spans.
+func ServerTags(tags map[string]string) ServerOption {
+	return func(h *handler) {
+		h.defaultTags = tags
+	}
+}
+
+// NewServerMiddleware returns a http.Handler middleware with Zipkin tracing
+// for Connect handlers. The procedure name is used as span name. Upstream
+// context is extracted from B3 headers and if not found from the W3C
+// traceparent header.
+func NewServerMiddleware(t zipkin.TracerInterface, options ...ServerOption) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		h := &handler{
+			tracer: t,
+			next:   next,
+		}
+		for _, option := range options {
+			option(h)
+		}
+		return h
+	}
+}
+
+// ServeHTTP implements http.Handler.
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	sc := h.tracer.Extract(b3.ExtractHTTP(r))
+	if sc.TraceID.Empty() && sc.Err == nil && r.Header.Get(w3c.TraceParent) != "" {
+		sc = h.tracer.Extract(w3c.ExtractHTTP(r))
+	}
+
+	remoteEndpoint, _ := zipkin.NewEndpoint("", r.RemoteAddr)
+
+	sp := h.tracer.StartSpan(
+		spanName(r.URL.Path),
+		zipkin.Kind(model.Server),
+		zipkin.Parent(sc),
+		zipkin.RemoteEndpoint(remoteEndpoint),
+	)
+
+	for k, v := range h.defaultTags {
+		sp.Tag(k, v)
+	}
+
+	rw := &responseWriter{
+		ResponseWriter: w,
+		protocol:       protocolFromContentType(r.Header.Get("Content-Type")),
+		statusCode:     http.StatusOK,
+	}
+
+	defer func() {
+		tagCode(sp, rw.code())
+		sp.Finish()
+	}()
+
+	h.next.ServeHTTP(rw, r.WithContext(zipkin.NewContext(r.Context(), sp)))
+}
+
+// responseWriter intercepts the response to find the resulting status code
+// of the procedure call.
+type responseWriter struct {
+	http.ResponseWriter
+	protocol   protocol
+	statusCode int
+	errBody    []byte
+	envelopes  envelopeScanner
+}
+
+func (w *responseWriter) WriteHeader(statusCode int) {
+	w.statusCode = statusCode
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	switch w.protocol {
+	case protocolConnectStream:
+		w.envelopes.scan(b)
+	case protocolConnectUnary:
+		if w.statusCode != http.StatusOK && len(w.errBody)+len(b) <= maxErrorPayload {
+			w.errBody = append(w.errBody, b...)
+		}
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher as required for streaming calls.
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap allows http.ResponseController to access the original writer.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *responseWriter) code() string {
+	switch w.protocol {
+	case protocolGRPC:
+		header := w.Header()
+		status := header.Get("Grpc-Status")
+		if status == "" {
+			status = header.Get(http.TrailerPrefix + "Grpc-Status")
+		}
+		if status == "" {
+			return ""
+		}
+		return codeFromGRPCStatus(status)
+	case protocolConnectStream:
+		if w.statusCode != http.StatusOK {
+			return codeFromHTTPStatus(w.statusCode)
+		}
+		return w.envelopes.code()
+	default:
+		if w.statusCode == http.StatusOK {
+			return ""
+		}
+		if code := codeFromUnaryError(w.errBody); code != "" {
+			return code
+		}
+		return codeFromHTTPStatus(w.statusCode)
+	}
+}
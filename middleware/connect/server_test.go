@@ -0,0 +1,151 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connect_test
+
+import (
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	zipkin "github.com/openzipkin/zipkin-go"
+	"github.com/openzipkin/zipkin-go/middleware/connect"
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/propagation/b3"
+	"github.com/openzipkin/zipkin-go/propagation/w3c"
+	"github.com/openzipkin/zipkin-go/reporter/recorder"
+)
+
+const procedure = "/acme.greet.v1.GreetService/Greet"
+
+func envelope(flags byte, payload string) []byte {
+	b := make([]byte, 5, 5+len(payload))
+	b[0] = flags
+	binary.BigEndian.PutUint32(b[1:], uint32(len(payload)))
+	return append(b, payload...)
+}
+
+// rpcHandler mimics the responses of a Connect handler for the various
+// protocols.
+var rpcHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	fail := r.URL.Query().Get("fail") != ""
+	switch contentType := r.Header.Get("Content-Type"); {
+	case strings.HasPrefix(contentType, "application/grpc"):
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Trailer", "Grpc-Status")
+		_, _ = w.Write(envelope(0, "hello"))
+		if fail {
+			w.Header().Set("Grpc-Status", "5")
+		} else {
+			w.Header().Set("Grpc-Status", "0")
+		}
+	case strings.HasPrefix(contentType, "application/connect+"):
+		w.Header().Set("Content-Type", contentType)
+		_, _ = w.Write(envelope(0, "hello"))
+		if fail {
+			_, _ = w.Write(envelope(2, `{"error":{"code":"resource_exhausted","message":"slow down"}}`))
+		} else {
+			_, _ = w.Write(envelope(2, `{}`))
+		}
+	default:
+		if fail {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"code":"invalid_argument","message":"bad name"}`))
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		_, _ = w.Write([]byte("hello"))
+	}
+})
+
+func TestServerMiddleware(t *testing.T) {
+	for _, tc := range []struct {
+		contentType string
+		fail        bool
+		code        string
+	}{
+		{"application/proto", false, ""},
+		{"application/proto", true, "INVALID_ARGUMENT"},
+		{"application/connect+proto", false, ""},
+		{"application/connect+proto", true, "RESOURCE_EXHAUSTED"},
+		{"application/grpc", false, ""},
+		{"application/grpc", true, "NOT_FOUND"},
+	} {
+		rec := recorder.NewReporter()
+		tr, _ := zipkin.NewTracer(rec)
+		handler := connect.NewServerMiddleware(tr, connect.ServerTags(map[string]string{"a": "b"}))(rpcHandler)
+
+		url := procedure
+		if tc.fail {
+			url += "?fail=1"
+		}
+		req := httptest.NewRequest("POST", url, nil)
+		req.Header.Set("Content-Type", tc.contentType)
+
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		spans := rec.Flush()
+		if want, have := 1, len(spans); want != have {
+			t.Fatalf("[%s] unexpected number of spans, want %d, have %d", tc.contentType, want, have)
+		}
+
+		span := spans[0]
+		if want, have := "acme.greet.v1.GreetService.Greet", span.Name; want != have {
+			t.Errorf("[%s] unexpected span name, want %q, have %q", tc.contentType, want, have)
+		}
+		if want, have := model.Server, span.Kind; want != have {
+			t.Errorf("[%s] unexpected span kind, want %q, have %q", tc.contentType, want, have)
+		}
+		if want, have := tc.code, span.Tags[string(zipkin.TagGRPCStatusCode)]; want != have {
+			t.Errorf("[%s] unexpected status code, want %q, have %q", tc.contentType, want, have)
+		}
+		if want, have := tc.code, span.Tags[string(zipkin.TagError)]; want != have {
+			t.Errorf("[%s] unexpected error tag, want %q, have %q", tc.contentType, want, have)
+		}
+		if want, have := "b", span.Tags["a"]; want != have {
+			t.Errorf("[%s] unexpected default tag, want %q, have %q", tc.contentType, want, have)
+		}
+	}
+}
+
+func TestServerMiddlewareExtract(t *testing.T) {
+	rec := recorder.NewReporter()
+	tr, _ := zipkin.NewTracer(rec, zipkin.WithSharedSpans(false))
+	handler := connect.NewServerMiddleware(tr)(rpcHandler)
+
+	for _, header := range []string{b3.Context, w3c.TraceParent} {
+		req := httptest.NewRequest("POST", procedure, nil)
+		if header == b3.Context {
+			req.Header.Set(b3.Context, "000000000000007b00000000000001c8-0000000000000315-1")
+		} else {
+			req.Header.Set(w3c.TraceParent, "00-000000000000007b00000000000001c8-0000000000000315-01")
+		}
+
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		spans := rec.Flush()
+		if want, have := 1, len(spans); want != have {
+			t.Fatalf("[%s] unexpected number of spans, want %d, have %d", header, want, have)
+		}
+		if want, have := (model.TraceID{High: 123, Low: 456}), spans[0].TraceID; want != have {
+			t.Errorf("[%s] unexpected trace id, want %s, have %s", header, want, have)
+		}
+		if spans[0].ParentID == nil || *spans[0].ParentID != model.ID(789) {
+			t.Errorf("[%s] unexpected parent id, want %d, have %v", header, 789, spans[0].ParentID)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connect
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	zipkin "github.com/openzipkin/zipkin-go"
+)
+
+// maxErrorPayload caps the amount of bytes buffered to find the error code in
+// an unary error response or end of stream message.
+const maxErrorPayload = 64 * 1024
+
+// codeNames holds the Connect status codes indexed by their numeric value.
+var codeNames = []string{
+	"ok",
+	"canceled",
+	"unknown",
+	"invalid_argument",
+	"deadline_exceeded",
+	"not_found",
+	"already_exists",
+	"permission_denied",
+	"resource_exhausted",
+	"failed_precondition",
+	"aborted",
+	"out_of_range",
+	"unimplemented",
+	"internal",
+	"unavailable",
+	"data_loss",
+	"unauthenticated",
+}
+
+type protocol int
+
+const (
+	protocolConnectUnary protocol = iota
+	protocolConnectStream
+	protocolGRPC
+)
+
+// protocolFromContentType detects the RPC protocol in use.
+func protocolFromContentType(contentType string) protocol {
+	switch {
+	case strings.HasPrefix(contentType, "application/grpc"):
+		// covers both gRPC and gRPC-Web
+		return protocolGRPC
+	case strings.HasPrefix(contentType, "application/connect+"):
+		return protocolConnectStream
+	default:
+		return protocolConnectUnary
+	}
+}
+
+// spanName returns the span name for the procedure found in the URL path,
+// e.g. "/acme.foo.v1.FooService/Bar" yields "acme.foo.v1.FooService.Bar".
+func spanName(path string) string {
+	if idx := strings.LastIndexByte(path, '/'); idx > 0 {
+		// strip a possible routing prefix in front of the procedure
+		if start := strings.LastIndexByte(path[:idx], '/'); start >= 0 {
+			path = path[start:]
+		}
+	}
+	name := strings.TrimPrefix(path, "/")
+	return strings.Replace(name, "/", ".", -1)
+}
+
+// codeFromGRPCStatus returns the Connect code for a grpc-status value.
+func codeFromGRPCStatus(status string) string {
+	n, err := strconv.Atoi(status)
+	if err != nil || n < 0 || n >= len(codeNames) {
+		return "unknown"
+	}
+	return codeNames[n]
+}
+
+// codeFromHTTPStatus returns the Connect code matching the HTTP status of an
+// unary error response if the error body does not hold a code.
+func codeFromHTTPStatus(status int) string {
+	switch status {
+	case http.StatusOK:
+		return "ok"
+	case http.StatusBadRequest:
+		return "internal"
+	case http.StatusUnauthorized:
+		return "unauthenticated"
+	case http.StatusForbidden:
+		return "permission_denied"
+	case http.StatusNotFound:
+		return "unimplemented"
+	case http.StatusTooManyRequests, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return "unavailable"
+	default:
+		return "unknown"
+	}
+}
+
+// codeFromUnaryError returns the code found in a Connect unary error body.
+func codeFromUnaryError(body []byte) string {
+	var e struct {
+		Code string `json:"code"`
+	}
+	if err := json.Unmarshal(body, &e); err != nil {
+		return ""
+	}
+	return e.Code
+}
+
+// codeFromEndStream returns the code found in a Connect end of stream message.
+func codeFromEndStream(body []byte) string {
+	var e struct {
+		Error *struct {
+			Code string `json:"code"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &e); err != nil {
+		return "unknown"
+	}
+	if e.Error == nil {
+		return "ok"
+	}
+	if e.Error.Code == "" {
+		return "unknown"
+	}
+	return e.Error.Code
+}
+
+// tagCode tags the span with the status code if it signals an error.
+func tagCode(sp zipkin.Span, code string) {
+	if code == "" || code == "ok" {
+		return
+	}
+	// Uppercase for consistency with the gRPC middleware
+	c := strings.ToUpper(code)
+	zipkin.TagGRPCStatusCode.Set(sp, c)
+	zipkin.TagError.Set(sp, c)
+}
+
+// envelopeScanner follows the enveloped messages of a Connect stream and holds
+// on to the payload of the end of stream message.
+type envelopeScanner struct {
+	prefix    [5]byte
+	nPrefix   int
+	remaining uint32
+	flags     byte
+	endStream []byte
+	done      bool
+}
+
+const (
+	flagCompressed = 0x01
+	flagEndStream  = 0x02
+)
+
+func (e *envelopeScanner) scan(p []byte) {
+	for len(p) > 0 && !e.done {
+		if e.nPrefix < len(e.prefix) {
+			n := copy(e.prefix[e.nPrefix:], p)
+			e.nPrefix += n
+			p = p[n:]
+			if e.nPrefix < len(e.prefix) {
+				return
+			}
+			e.flags = e.prefix[0]
+			e.remaining = binary.BigEndian.Uint32(e.prefix[1:])
+		}
+
+		n := uint32(len(p))
+		if n > e.remaining {
+			n = e.remaining
+		}
+		if e.flags&flagEndStream != 0 && len(e.endStream)+int(n) <= maxErrorPayload {
+			e.endStream = append(e.endStream, p[:n]...)
+		}
+		e.remaining -= n
+		p = p[n:]
+
+		if e.remaining == 0 {
+			if e.flags&flagEndStream != 0 {
+				e.done = true
+				return
+			}
+			e.nPrefix = 0
+		}
+	}
+}
+
+// code returns the status code of the stream once the end of stream message
+// has been seen. If the stream did not complete or the end of stream message
+// is compressed an empty code is returned.
+func (e *envelopeScanner) code() string {
+	if !e.done || e.flags&flagCompressed != 0 {
+		return ""
+	}
+	return codeFromEndStream(e.endStream)
+}
+
+// replayBody returns a response body holding the consumed part of body
+// followed by the remainder of the original body, or the read error if
+// consuming failed.
+func replayBody(consumed []byte, err error, body io.ReadCloser) io.ReadCloser {
+	var rest io.Reader = body
+	if err != nil {
+		rest = errReader{err}
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(consumed), rest), body}
+}
+
+type errReader struct {
+	err error
+}
+
+func (e errReader) Read([]byte) (int, error) {
+	return 0, e.err
+}
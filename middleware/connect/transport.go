@@ -0,0 +1,209 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connect
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	zipkin "github.com/openzipkin/zipkin-go"
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/propagation/b3"
+	"github.com/openzipkin/zipkin-go/propagation/w3c"
+	"github.com/openzipkin/zipkin-go/reporter"
+)
+
+// ErrValidTracerRequired error
+var ErrValidTracerRequired = errors.New("valid tracer required")
+
+type transport struct {
+	tracer            zipkin.TracerInterface
+	rt                http.RoundTripper
+	defaultTags       map[string]string
+	remoteServiceName string
+	injectW3C         bool
+}
+
+// TransportOption allows one to configure optional transport configuration.
+type TransportOption func(*transport)
+
+// RoundTripper adds the Transport RoundTripper to wrap.
+func RoundTripper(rt http.RoundTripper) TransportOption {
+	return func(t *transport) {
+		if rt != nil {
+			t.rt = rt
+		}
+	}
+}
+
+// TransportTags adds default Tags to inject into client spans.
+func TransportTags(tags map[string]string) TransportOption {
+	return func(t *transport) {
+		t.defaultTags = tags
+	}
+}
+
+// WithRemoteServiceName will set the value for the remote endpoint's service
+// name on all client spans.
+func WithRemoteServiceName(name string) TransportOption {
+	return func(t *transport) {
+		t.remoteServiceName = name
+	}
+}
+
+// InjectW3C if set to true will inject the W3C traceparent header next to the
+// B3 headers, for servers only understanding W3C Trace Context.
+func InjectW3C(enabled bool) TransportOption {
+	return func(t *transport) {
+		t.injectW3C = enabled
+	}
+}
+
+// NewTransport returns a new Zipkin instrumented http RoundTripper to use with
+// the http.Client passed to Connect client constructors.
+func NewTransport(tracer zipkin.TracerInterface, options ...TransportOption) (http.RoundTripper, error) {
+	if tracer == nil {
+		return nil, ErrValidTracerRequired
+	}
+
+	t := &transport{
+		tracer: tracer,
+		rt:     http.DefaultTransport,
+	}
+	for _, option := range options {
+		option(t)
+	}
+
+	return t, nil
+}
+
+// RoundTrip satisfies the RoundTripper interface.
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if reporter.IsUntracedContext(req.Context()) {
+		// request was flagged to not be traced, e.g. span delivery by a reporter
+		return t.rt.RoundTrip(req)
+	}
+
+	sp, _ := t.tracer.StartSpanFromContext(
+		req.Context(), spanName(req.URL.Path), zipkin.Kind(model.Client),
+	)
+
+	if t.remoteServiceName != "" {
+		sp.SetRemoteEndpoint(&model.Endpoint{ServiceName: t.remoteServiceName})
+	}
+
+	for k, v := range t.defaultTags {
+		sp.Tag(k, v)
+	}
+
+	// RoundTrippers should not modify the original request
+	r := new(http.Request)
+	*r = *req
+	r.Header = make(http.Header, len(req.Header)+5)
+	for k, v := range req.Header {
+		r.Header[k] = v
+	}
+
+	_ = b3.InjectHTTP(r)(sp.Context())
+	if t.injectW3C {
+		_ = w3c.InjectHTTP(r)(sp.Context())
+	}
+
+	res, err := t.rt.RoundTrip(r)
+	if err != nil {
+		zipkin.TagError.Set(sp, err.Error())
+		sp.Finish()
+		return nil, err
+	}
+
+	switch protocolFromContentType(r.Header.Get("Content-Type")) {
+	case protocolGRPC:
+		if status := res.Header.Get("Grpc-Status"); status != "" {
+			// trailers-only response
+			tagCode(sp, codeFromGRPCStatus(status))
+			sp.Finish()
+			return res, nil
+		}
+		res.Body = &bodyCloser{ReadCloser: res.Body, sp: sp, code: func() string {
+			if status := res.Trailer.Get("Grpc-Status"); status != "" {
+				return codeFromGRPCStatus(status)
+			}
+			return ""
+		}}
+	case protocolConnectStream:
+		if res.StatusCode != http.StatusOK {
+			tagCode(sp, codeFromHTTPStatus(res.StatusCode))
+			sp.Finish()
+			return res, nil
+		}
+		scanner := &envelopeScanner{}
+		res.Body = &bodyCloser{ReadCloser: res.Body, sp: sp, scanner: scanner, code: scanner.code}
+	default:
+		if res.StatusCode != http.StatusOK {
+			code := codeFromHTTPStatus(res.StatusCode)
+			body, err := ioutil.ReadAll(io.LimitReader(res.Body, maxErrorPayload))
+			if c := codeFromUnaryError(body); err == nil && c != "" {
+				code = c
+			}
+			res.Body = replayBody(body, err, res.Body)
+			tagCode(sp, code)
+		}
+		sp.Finish()
+	}
+
+	return res, nil
+}
+
+// bodyCloser finishes the span of a streaming call once the response body has
+// been consumed or closed.
+type bodyCloser struct {
+	io.ReadCloser
+	sp      zipkin.Span
+	scanner *envelopeScanner
+	code    func() string
+	once    sync.Once
+}
+
+func (b *bodyCloser) Read(p []byte) (n int, err error) {
+	n, err = b.ReadCloser.Read(p)
+	if b.scanner != nil {
+		b.scanner.scan(p[:n])
+	}
+	if err == io.EOF {
+		b.finish()
+	} else if err != nil {
+		b.once.Do(func() {
+			zipkin.TagError.Set(b.sp, err.Error())
+			b.sp.Finish()
+		})
+	}
+	return
+}
+
+func (b *bodyCloser) Close() error {
+	err := b.ReadCloser.Close()
+	b.finish()
+	return err
+}
+
+func (b *bodyCloser) finish() {
+	b.once.Do(func() {
+		tagCode(b.sp, b.code())
+		b.sp.Finish()
+	})
+}
@@ -0,0 +1,195 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connect_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	zipkin "github.com/openzipkin/zipkin-go"
+	"github.com/openzipkin/zipkin-go/middleware/connect"
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/propagation/b3"
+	"github.com/openzipkin/zipkin-go/propagation/w3c"
+	"github.com/openzipkin/zipkin-go/reporter"
+	"github.com/openzipkin/zipkin-go/reporter/recorder"
+)
+
+func TestTransport(t *testing.T) {
+	srv := httptest.NewServer(rpcHandler)
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		contentType string
+		fail        bool
+		code        string
+	}{
+		{"application/proto", false, ""},
+		{"application/proto", true, "INVALID_ARGUMENT"},
+		{"application/connect+proto", false, ""},
+		{"application/connect+proto", true, "RESOURCE_EXHAUSTED"},
+		{"application/grpc", false, ""},
+		{"application/grpc", true, "NOT_FOUND"},
+	} {
+		rec := recorder.NewReporter()
+		tr, _ := zipkin.NewTracer(rec)
+		transport, err := connect.NewTransport(tr, connect.WithRemoteServiceName("greeter"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		client := &http.Client{Transport: transport}
+
+		url := srv.URL + procedure
+		if tc.fail {
+			url += "?fail=1"
+		}
+		req, _ := http.NewRequest("POST", url, strings.NewReader("hi"))
+		req.Header.Set("Content-Type", tc.contentType)
+
+		res, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("[%s] unexpected error: %v", tc.contentType, err)
+		}
+		body, _ := ioutil.ReadAll(res.Body)
+		_ = res.Body.Close()
+
+		if tc.fail && tc.contentType == "application/proto" {
+			// error body must still be readable by the Connect client
+			if !strings.Contains(string(body), "invalid_argument") {
+				t.Errorf("unexpected error body %q", body)
+			}
+		}
+
+		spans := rec.Flush()
+		if want, have := 1, len(spans); want != have {
+			t.Fatalf("[%s] unexpected number of spans, want %d, have %d", tc.contentType, want, have)
+		}
+
+		span := spans[0]
+		if want, have := "acme.greet.v1.GreetService.Greet", span.Name; want != have {
+			t.Errorf("[%s] unexpected span name, want %q, have %q", tc.contentType, want, have)
+		}
+		if want, have := model.Client, span.Kind; want != have {
+			t.Errorf("[%s] unexpected span kind, want %q, have %q", tc.contentType, want, have)
+		}
+		if want, have := "greeter", span.RemoteEndpoint.ServiceName; want != have {
+			t.Errorf("[%s] unexpected remote service name, want %q, have %q", tc.contentType, want, have)
+		}
+		if want, have := tc.code, span.Tags[string(zipkin.TagError)]; want != have {
+			t.Errorf("[%s] unexpected error tag, want %q, have %q", tc.contentType, want, have)
+		}
+	}
+}
+
+func TestTransportInject(t *testing.T) {
+	var header http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		header = r.Header
+	}))
+	defer srv.Close()
+
+	tr, _ := zipkin.NewTracer(recorder.NewReporter())
+	transport, _ := connect.NewTransport(tr, connect.InjectW3C(true))
+
+	req, _ := http.NewRequest("POST", srv.URL+procedure, nil)
+	res, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_ = res.Body.Close()
+
+	if len(req.Header) != 0 {
+		t.Errorf("original request headers must not be modified, have %+v", req.Header)
+	}
+	if header.Get(b3.TraceID) == "" {
+		t.Error("expected B3 headers to be injected")
+	}
+	if header.Get(w3c.TraceParent) == "" {
+		t.Error("expected traceparent header to be injected")
+	}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+type brokenBody struct {
+	data []byte
+	err  error
+}
+
+func (b *brokenBody) Read(p []byte) (int, error) {
+	if len(b.data) == 0 {
+		return 0, b.err
+	}
+	n := copy(p, b.data)
+	b.data = b.data[n:]
+	return n, nil
+}
+
+func (b *brokenBody) Close() error { return nil }
+
+func TestTransportBrokenErrorBody(t *testing.T) {
+	failure := errors.New("connection reset")
+	rt := roundTripperFunc(func(*http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusBadRequest,
+			Header:     http.Header{},
+			Body:       &brokenBody{data: []byte(`{"code":`), err: failure},
+		}, nil
+	})
+
+	tr, _ := zipkin.NewTracer(recorder.NewReporter())
+	transport, _ := connect.NewTransport(tr, connect.RoundTripper(rt))
+
+	req, _ := http.NewRequest("POST", "http://localhost"+procedure, nil)
+	res, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// the consumed part of the body is handed back along with the read error
+	body, err := ioutil.ReadAll(res.Body)
+	if want, have := `{"code":`, string(body); want != have {
+		t.Errorf("body want %q, have %q", want, have)
+	}
+	if want, have := failure, err; want != have {
+		t.Errorf("read error want %v, have %v", want, have)
+	}
+}
+
+func TestTransportUntraced(t *testing.T) {
+	srv := httptest.NewServer(rpcHandler)
+	defer srv.Close()
+
+	rec := recorder.NewReporter()
+	tr, _ := zipkin.NewTracer(rec)
+	transport, _ := connect.NewTransport(tr)
+
+	req, _ := http.NewRequest("POST", srv.URL+procedure, nil)
+	req = req.WithContext(reporter.NewUntracedContext(req.Context()))
+	res, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_ = res.Body.Close()
+
+	if spans := rec.Flush(); len(spans) != 0 {
+		t.Errorf("expected untraced request to not create spans, have %d", len(spans))
+	}
+}
@@ -21,13 +21,19 @@ import (
 	"google.golang.org/grpc/stats"
 
 	"github.com/openzipkin/zipkin-go"
+	"github.com/openzipkin/zipkin-go/middleware/capture"
 	"github.com/openzipkin/zipkin-go/model"
 	"github.com/openzipkin/zipkin-go/propagation/b3"
+	"github.com/openzipkin/zipkin-go/reporter"
 )
 
 type clientHandler struct {
-	tracer            *zipkin.Tracer
+	tracer            zipkin.TracerInterface
 	remoteServiceName string
+	errClassifier     zipkin.ErrorClassifier
+	capture           *capture.Config
+	traceID64Bit      bool
+	skipMethods       map[string]bool
 }
 
 // A ClientOption can be passed to NewClientHandler to customize the returned handler.
@@ -41,12 +47,54 @@ func WithRemoteServiceName(name string) ClientOption {
 	}
 }
 
+// WithClientErrorClassifier sets the ErrorClassifier deciding which failed
+// calls are tagged as errors and which are tagged as expected. The status code
+// passed to the classifier is the gRPC status code of the call. By default all
+// failed calls are errors.
+func WithClientErrorClassifier(ec zipkin.ErrorClassifier) ClientOption {
+	return func(c *clientHandler) {
+		if ec != nil {
+			c.errClassifier = ec
+		}
+	}
+}
+
+// WithClientTraceID64Bit when enabled propagates 128-bit trace ids to the
+// server as 64-bit trace ids, for legacy services which fail on 128-bit trace
+// ids. Spans with a downgraded trace id are tagged with
+// zipkin.TagTraceIDDowngraded.
+func WithClientTraceID64Bit(enabled bool) ClientOption {
+	return func(c *clientHandler) {
+		c.traceID64Bit = enabled
+	}
+}
+
+// WithClientCapture enables capturing of the messages of calls with the debug
+// flag, recorded as annotations of the client span according to config, see
+// package capture. Messages have the content type application/grpc, which
+// needs to be allowed by config. Capturing is disabled by default.
+func WithClientCapture(config capture.Config) ClientOption {
+	return func(c *clientHandler) {
+		c.capture = &config
+	}
+}
+
+// WithClientSkipMethods excludes calls to the given full method names, e.g.
+// HealthCheckMethods, from tracing. No spans are created for these calls and
+// no context is propagated to the server.
+func WithClientSkipMethods(methods ...string) ClientOption {
+	return func(c *clientHandler) {
+		c.skipMethods = methodSet(methods)
+	}
+}
+
 // NewClientHandler returns a stats.Handler which can be used with grpc.WithStatsHandler to add
 // tracing to a gRPC client. The gRPC method name is used as the span name and by default the only
 // tags are the gRPC status code if the call fails.
-func NewClientHandler(tracer *zipkin.Tracer, options ...ClientOption) stats.Handler {
+func NewClientHandler(tracer zipkin.TracerInterface, options ...ClientOption) stats.Handler {
 	c := &clientHandler{
-		tracer: tracer,
+		tracer:        tracer,
+		errClassifier: defaultErrorClassifier,
 	}
 	for _, option := range options {
 		option(c)
@@ -67,11 +115,23 @@ func (c *clientHandler) TagConn(ctx context.Context, cti *stats.ConnTagInfo) con
 
 // HandleRPC implements per-RPC tracing and stats instrumentation.
 func (c *clientHandler) HandleRPC(ctx context.Context, rs stats.RPCStats) {
-	handleRPC(ctx, rs)
+	if reporter.IsUntracedContext(ctx) || ctx.Value(skipKey{}) != nil {
+		return
+	}
+	handleRPC(ctx, rs, c.errClassifier, c.capture)
 }
 
 // TagRPC implements per-RPC context management.
 func (c *clientHandler) TagRPC(ctx context.Context, rti *stats.RPCTagInfo) context.Context {
+	if reporter.IsUntracedContext(ctx) {
+		// call was flagged to not be traced, e.g. span delivery by a reporter
+		return ctx
+	}
+	if c.skipMethods[rti.FullMethodName] {
+		// call is excluded from tracing
+		return context.WithValue(ctx, skipKey{}, true)
+	}
+
 	var span zipkin.Span
 
 	ep := remoteEndpointFromContext(ctx, c.remoteServiceName)
@@ -85,7 +145,14 @@ func (c *clientHandler) TagRPC(ctx context.Context, rti *stats.RPCTagInfo) conte
 	} else {
 		md = metadata.New(nil)
 	}
-	_ = b3.InjectGRPC(&md)(span.Context())
+	sc := span.Context()
+	if c.traceID64Bit {
+		var downgraded bool
+		if sc, downgraded = b3.Downgrade(sc); downgraded {
+			zipkin.TagTraceIDDowngraded.Set(span, "true")
+		}
+	}
+	_ = b3.InjectGRPC(&md)(sc)
 	ctx = metadata.NewOutgoingContext(ctx, md)
 	return ctx
 }
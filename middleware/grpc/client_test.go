@@ -133,4 +133,31 @@ var _ = ginkgo.Describe("gRPC Client", func() {
 			gomega.Expect(spans[0].RemoteEndpoint.ServiceName).To(gomega.Equal("remoteService"))
 		})
 	})
+
+	ginkgo.Context("with skipped methods", func() {
+		ginkgo.BeforeEach(func() {
+			var err error
+
+			conn, err = grpc.Dial(
+				serverAddr,
+				grpc.WithInsecure(),
+				grpc.WithStatsHandler(zipkingrpc.NewClientHandler(
+					tracer,
+					zipkingrpc.WithClientSkipMethods("/zipkin.testing.HelloService/Hello"))))
+			gomega.Expect(conn, err).ToNot(gomega.BeNil())
+			client = service.NewHelloServiceClient(conn)
+		})
+
+		ginkgo.It("does not trace skipped calls", func() {
+			parent, ctx := tracer.StartSpanFromContext(context.Background(), "parent")
+			resp, err := client.Hello(ctx, &service.HelloRequest{Payload: "Hello"})
+			gomega.Expect(resp, err).ToNot(gomega.BeNil())
+			gomega.Expect(resp.GetMetadata()).ToNot(gomega.HaveKey(b3.TraceID))
+			parent.Finish()
+
+			spans := reporter.Flush()
+			gomega.Expect(spans).To(gomega.HaveLen(1))
+			gomega.Expect(spans[0].Name).To(gomega.Equal("parent"))
+		})
+	})
 })
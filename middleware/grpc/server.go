@@ -16,8 +16,10 @@ package grpc
 
 import (
 	"context"
+	"math/rand"
 
 	"github.com/openzipkin/zipkin-go"
+	"github.com/openzipkin/zipkin-go/middleware/capture"
 	"github.com/openzipkin/zipkin-go/model"
 	"github.com/openzipkin/zipkin-go/propagation/b3"
 	"google.golang.org/grpc/metadata"
@@ -25,8 +27,14 @@ import (
 )
 
 type serverHandler struct {
-	tracer      *zipkin.Tracer
-	defaultTags map[string]string
+	tracer         zipkin.TracerInterface
+	defaultTags    map[string]string
+	lazySpans      bool
+	extractOptions []b3.ExtractOption
+	errClassifier  zipkin.ErrorClassifier
+	capture        *capture.Config
+	skipMethods    map[string]bool
+	methodSamplers map[string]zipkin.Sampler
 }
 
 // A ServerOption can be passed to NewServerHandler to customize the returned handler.
@@ -39,13 +47,87 @@ func ServerTags(tags map[string]string) ServerOption {
 	}
 }
 
+// WithServerErrorClassifier sets the ErrorClassifier deciding which failed
+// calls are tagged as errors and which are tagged as expected. The status code
+// passed to the classifier is the gRPC status code of the call. By default all
+// failed calls are errors.
+func WithServerErrorClassifier(ec zipkin.ErrorClassifier) ServerOption {
+	return func(h *serverHandler) {
+		if ec != nil {
+			h.errClassifier = ec
+		}
+	}
+}
+
+// LazySpans when enabled skips recording of calls which are not sampled. The
+// SpanContext of these calls is still propagated through the call context, so
+// downstream calls carry the sampling decision. If the decision was made
+// upstream no span is created. Otherwise a span is started to consult the
+// tracer's sampler, but no tags or events are recorded on it when it turns out
+// unsampled. This reduces overhead at low sample rates.
+func LazySpans(enabled bool) ServerOption {
+	return func(h *serverHandler) {
+		h.lazySpans = enabled
+	}
+}
+
+// StrictParsing when enabled rejects malformed or oversized B3 metadata of
+// incoming calls using the strict B3 parsers, see b3.WithStrictParsing. Calls
+// with rejected metadata start a new trace.
+func StrictParsing(enabled bool) ServerOption {
+	return func(h *serverHandler) {
+		h.extractOptions = nil
+		if enabled {
+			h.extractOptions = []b3.ExtractOption{b3.WithStrictParsing()}
+		}
+	}
+}
+
+// WithServerCapture enables capturing of the messages of calls with the debug
+// flag, recorded as annotations of the server span according to c, see package
+// capture. Messages have the content type application/grpc, which needs to be
+// allowed by c. Capturing is disabled by default.
+func WithServerCapture(c capture.Config) ServerOption {
+	return func(h *serverHandler) {
+		h.capture = &c
+	}
+}
+
+// SkipMethods excludes calls to the given full method names, e.g.
+// HealthCheckMethods and ReflectionMethods, from tracing. No spans are created
+// for these calls. The incoming context is still propagated, with a decision
+// not to sample unless upstream decided otherwise, so calls made by the
+// handler are not traced either.
+func SkipMethods(methods ...string) ServerOption {
+	return func(h *serverHandler) {
+		h.skipMethods = methodSet(methods)
+	}
+}
+
+// SampleMethods sets the sampler deciding if traces started by calls to the
+// given full method names are sampled instead of the sampler of the tracer,
+// e.g. to down-sample health checks dominating the span volume. Decisions
+// propagated by the caller are honored. SampleMethods can be used multiple
+// times for different samplers.
+func SampleMethods(sampler zipkin.Sampler, methods ...string) ServerOption {
+	return func(h *serverHandler) {
+		if h.methodSamplers == nil {
+			h.methodSamplers = make(map[string]zipkin.Sampler)
+		}
+		for _, method := range methods {
+			h.methodSamplers[method] = sampler
+		}
+	}
+}
+
 // NewServerHandler returns a stats.Handler which can be used with grpc.WithStatsHandler to add
 // tracing to a gRPC server. The gRPC method name is used as the span name and by default the only
 // tags are the gRPC status code if the call fails. Use ServerTags to add additional tags that
 // should be applied to all spans.
-func NewServerHandler(tracer *zipkin.Tracer, options ...ServerOption) stats.Handler {
+func NewServerHandler(tracer zipkin.TracerInterface, options ...ServerOption) stats.Handler {
 	c := &serverHandler{
-		tracer: tracer,
+		tracer:        tracer,
+		errClassifier: defaultErrorClassifier,
 	}
 	for _, option := range options {
 		option(c)
@@ -66,7 +148,7 @@ func (s *serverHandler) TagConn(ctx context.Context, cti *stats.ConnTagInfo) con
 
 // HandleRPC implements per-RPC tracing and stats instrumentation.
 func (s *serverHandler) HandleRPC(ctx context.Context, rs stats.RPCStats) {
-	handleRPC(ctx, rs)
+	handleRPC(ctx, rs, s.errClassifier, s.capture)
 }
 
 // TagRPC implements per-RPC context management.
@@ -79,9 +161,45 @@ func (s *serverHandler) TagRPC(ctx context.Context, rti *stats.RPCTagInfo) conte
 
 	name := spanName(rti)
 
-	sc := s.tracer.Extract(b3.ExtractGRPC(&md))
+	sc := s.tracer.Extract(b3.ExtractGRPC(&md, s.extractOptions...))
 
-	span := s.tracer.StartSpan(name, zipkin.Kind(model.Server), zipkin.Parent(sc), zipkin.RemoteEndpoint(remoteEndpointFromContext(ctx, "")))
+	if s.skipMethods[rti.FullMethodName] {
+		// call is excluded from tracing, propagate the context only
+		if !sc.Debug && sc.Sampled == nil {
+			sampled := false
+			sc.Sampled = &sampled
+		}
+		return zipkin.NewContextFromSpanContext(ctx, sc)
+	}
+
+	if sampler, ok := s.methodSamplers[rti.FullMethodName]; ok && !sc.Debug && sc.Sampled == nil {
+		// the trace id is generated when starting the span, decide on a
+		// random id for new traces
+		id := sc.TraceID.Low
+		if sc.TraceID.Empty() {
+			id = rand.Uint64()
+		}
+		sampled := sampler(id)
+		sc.Sampled = &sampled
+	}
+
+	if s.lazySpans && isUnsampled(sc) {
+		// upstream decided not to sample, propagate the context only
+		return zipkin.NewContextFromSpanContext(ctx, sc)
+	}
+
+	span := s.tracer.StartSpan(
+		name,
+		zipkin.Kind(model.Server),
+		zipkin.Parent(sc),
+		zipkin.RemoteEndpoint(remoteEndpointFromContext(ctx, "")),
+		zipkin.WatchContext(ctx),
+	)
+
+	if s.lazySpans && isUnsampled(span.Context()) {
+		// sampler decided not to sample, skip recording span details
+		return zipkin.NewContextFromSpanContext(ctx, span.Context())
+	}
 
 	for k, v := range s.defaultTags {
 		span.Tag(k, v)
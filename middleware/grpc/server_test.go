@@ -20,13 +20,19 @@ import (
 	"github.com/onsi/ginkgo"
 	"github.com/onsi/gomega"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
 
 	"github.com/openzipkin/zipkin-go"
+	"github.com/openzipkin/zipkin-go/middleware/capture"
+	zipkingrpc "github.com/openzipkin/zipkin-go/middleware/grpc"
 	"github.com/openzipkin/zipkin-go/model"
 	"github.com/openzipkin/zipkin-go/propagation/b3"
 	service "github.com/openzipkin/zipkin-go/proto/testing"
 	"github.com/openzipkin/zipkin-go/reporter"
+	"github.com/openzipkin/zipkin-go/reporter/recorder"
 )
 
 var _ = ginkgo.Describe("gRPC Server", func() {
@@ -173,4 +179,174 @@ var _ = ginkgo.Describe("gRPC Server", func() {
 			gomega.Expect(spanCtx).To(gomega.HaveKeyWithValue(b3.SpanID, "0000000000000001"))
 		})
 	})
+
+	ginkgo.Context("with lazy spans", func() {
+		ginkgo.It("propagates context of unsampled calls without spans", func() {
+			rec := recorder.NewReporter()
+			tracer, err := zipkin.NewTracer(rec, zipkin.WithSampler(zipkin.NeverSample))
+			gomega.Expect(err).ToNot(gomega.HaveOccurred())
+			handler := zipkingrpc.NewServerHandler(tracer, zipkingrpc.LazySpans(true))
+			info := &stats.RPCTagInfo{FullMethodName: "/zipkin.testing.HelloService/Hello"}
+
+			// upstream decided not to sample
+			md := metadata.New(map[string]string{
+				b3.TraceID: "0000000000000001",
+				b3.SpanID:  "0000000000000002",
+				b3.Sampled: "0",
+			})
+			ctx := handler.TagRPC(metadata.NewIncomingContext(context.Background(), md), info)
+			sc := zipkin.SpanFromContext(ctx).Context()
+			gomega.Expect(sc.TraceID).To(gomega.Equal(model.TraceID{Low: 1}))
+			gomega.Expect(sc.ID).To(gomega.Equal(model.ID(2)))
+			handler.HandleRPC(ctx, &stats.End{})
+
+			// sampler decided not to sample
+			ctx = handler.TagRPC(metadata.NewIncomingContext(context.Background(), metadata.New(nil)), info)
+			sc = zipkin.SpanFromContext(ctx).Context()
+			gomega.Expect(sc.TraceID.Empty()).To(gomega.BeFalse())
+			gomega.Expect(*sc.Sampled).To(gomega.BeFalse())
+			handler.HandleRPC(ctx, &stats.End{})
+
+			gomega.Expect(rec.Flush()).To(gomega.BeEmpty())
+		})
+	})
+
+	ginkgo.Context("with strict parsing", func() {
+		ginkgo.It("rejects malformed metadata", func() {
+			tracer, err := zipkin.NewTracer(recorder.NewReporter())
+			gomega.Expect(err).ToNot(gomega.HaveOccurred())
+			info := &stats.RPCTagInfo{FullMethodName: "/zipkin.testing.HelloService/Hello"}
+			md := metadata.New(map[string]string{
+				b3.TraceID: "000000000000007B",
+				b3.SpanID:  "0000000000000002",
+			})
+
+			handler := zipkingrpc.NewServerHandler(tracer)
+			ctx := handler.TagRPC(metadata.NewIncomingContext(context.Background(), md), info)
+			sc := zipkin.SpanFromContext(ctx).Context()
+			gomega.Expect(sc.TraceID).To(gomega.Equal(model.TraceID{Low: 123}))
+
+			handler = zipkingrpc.NewServerHandler(tracer, zipkingrpc.StrictParsing(true))
+			ctx = handler.TagRPC(metadata.NewIncomingContext(context.Background(), md), info)
+			sc = zipkin.SpanFromContext(ctx).Context()
+			gomega.Expect(sc.TraceID).ToNot(gomega.Equal(model.TraceID{Low: 123}))
+		})
+	})
+
+	ginkgo.Context("with error classifier", func() {
+		ginkgo.It("tags expected errors", func() {
+			rec := recorder.NewReporter()
+			tracer, err := zipkin.NewTracer(rec)
+			gomega.Expect(err).ToNot(gomega.HaveOccurred())
+			info := &stats.RPCTagInfo{FullMethodName: "/zipkin.testing.HelloService/Hello"}
+
+			handler := zipkingrpc.NewServerHandler(tracer, zipkingrpc.WithServerErrorClassifier(
+				zipkin.ErrorClassifierFunc(func(err error, statusCode int) zipkin.Severity {
+					if codes.Code(statusCode) == codes.NotFound {
+						return zipkin.SeverityExpected
+					}
+					return zipkin.SeverityError
+				}),
+			))
+			for _, code := range []codes.Code{codes.NotFound, codes.Internal} {
+				ctx := handler.TagRPC(context.Background(), info)
+				handler.HandleRPC(ctx, &stats.End{Error: status.Error(code, "failed")})
+			}
+
+			spans := rec.Flush()
+			gomega.Expect(spans).To(gomega.HaveLen(2))
+			gomega.Expect(spans[0].Tags).To(gomega.HaveKeyWithValue(string(zipkin.TagErrorExpected), "NOTFOUND"))
+			gomega.Expect(spans[0].Tags).ToNot(gomega.HaveKey(string(zipkin.TagError)))
+			gomega.Expect(spans[1].Tags).To(gomega.HaveKeyWithValue(string(zipkin.TagError), "INTERNAL"))
+		})
+	})
+
+	ginkgo.Context("with payload capture", func() {
+		ginkgo.It("records messages of debug calls", func() {
+			rec := recorder.NewReporter()
+			tracer, err := zipkin.NewTracer(rec)
+			gomega.Expect(err).ToNot(gomega.HaveOccurred())
+			info := &stats.RPCTagInfo{FullMethodName: "/zipkin.testing.HelloService/Hello"}
+
+			handler := zipkingrpc.NewServerHandler(tracer, zipkingrpc.WithServerCapture(capture.Config{
+				MaxSize:      20,
+				ContentTypes: []string{"application/grpc"},
+			}))
+			for _, flags := range []string{"1", "0"} {
+				md := metadata.Pairs(b3.Flags, flags)
+				ctx := handler.TagRPC(metadata.NewIncomingContext(context.Background(), md), info)
+				handler.HandleRPC(ctx, &stats.InPayload{Payload: &service.HelloRequest{Payload: "Hello"}})
+				handler.HandleRPC(ctx, &stats.OutPayload{Payload: &service.HelloResponse{Payload: "Hello back to you"}})
+				handler.HandleRPC(ctx, &stats.End{})
+			}
+
+			spans := rec.Flush()
+			gomega.Expect(spans).To(gomega.HaveLen(2))
+			gomega.Expect(spans[0].Annotations).To(gomega.HaveLen(2))
+			gomega.Expect(spans[0].Annotations[0].Value).To(gomega.HavePrefix(capture.AnnotationRequest + ": "))
+			gomega.Expect(spans[0].Annotations[0].Value).To(gomega.ContainSubstring("Hello"))
+			gomega.Expect(spans[0].Annotations[1].Value).To(gomega.HavePrefix(capture.AnnotationResponse + ": "))
+			gomega.Expect(spans[0].Annotations[1].Value).To(gomega.HaveSuffix("..."))
+			gomega.Expect(spans[1].Annotations).To(gomega.BeEmpty())
+		})
+	})
+
+	ginkgo.Context("with skipped methods", func() {
+		ginkgo.It("propagates context of skipped calls without spans", func() {
+			rec := recorder.NewReporter()
+			tracer, err := zipkin.NewTracer(rec)
+			gomega.Expect(err).ToNot(gomega.HaveOccurred())
+			handler := zipkingrpc.NewServerHandler(tracer, zipkingrpc.SkipMethods(zipkingrpc.HealthCheckMethods...))
+			info := &stats.RPCTagInfo{FullMethodName: "/grpc.health.v1.Health/Check"}
+
+			// no upstream context, calls made by the handler are not sampled
+			ctx := handler.TagRPC(metadata.NewIncomingContext(context.Background(), metadata.New(nil)), info)
+			sc := zipkin.SpanFromContext(ctx).Context()
+			gomega.Expect(sc.TraceID.Empty()).To(gomega.BeTrue())
+			gomega.Expect(*sc.Sampled).To(gomega.BeFalse())
+			child, _ := tracer.StartSpanFromContext(ctx, "child")
+			child.Finish()
+			handler.HandleRPC(ctx, &stats.End{})
+
+			// upstream decided to sample
+			md := metadata.New(map[string]string{
+				b3.TraceID: "0000000000000001",
+				b3.SpanID:  "0000000000000002",
+				b3.Sampled: "1",
+			})
+			ctx = handler.TagRPC(metadata.NewIncomingContext(context.Background(), md), info)
+			sc = zipkin.SpanFromContext(ctx).Context()
+			gomega.Expect(sc.ID).To(gomega.Equal(model.ID(2)))
+			gomega.Expect(*sc.Sampled).To(gomega.BeTrue())
+			handler.HandleRPC(ctx, &stats.End{})
+
+			gomega.Expect(rec.Flush()).To(gomega.BeEmpty())
+		})
+	})
+
+	ginkgo.Context("with method samplers", func() {
+		ginkgo.It("samples new traces of methods using their sampler", func() {
+			rec := recorder.NewReporter()
+			tracer, err := zipkin.NewTracer(rec)
+			gomega.Expect(err).ToNot(gomega.HaveOccurred())
+			handler := zipkingrpc.NewServerHandler(tracer, zipkingrpc.SampleMethods(zipkin.NeverSample, zipkingrpc.HealthCheckMethods...))
+
+			for _, method := range []string{"/grpc.health.v1.Health/Check", "/zipkin.testing.HelloService/Hello"} {
+				info := &stats.RPCTagInfo{FullMethodName: method}
+				ctx := handler.TagRPC(metadata.NewIncomingContext(context.Background(), metadata.New(nil)), info)
+				handler.HandleRPC(ctx, &stats.End{})
+			}
+
+			// upstream decisions are honored
+			md := metadata.Pairs(b3.Flags, "1")
+			info := &stats.RPCTagInfo{FullMethodName: "/grpc.health.v1.Health/Check"}
+			ctx := handler.TagRPC(metadata.NewIncomingContext(context.Background(), md), info)
+			handler.HandleRPC(ctx, &stats.End{})
+
+			spans := rec.Flush()
+			gomega.Expect(spans).To(gomega.HaveLen(2))
+			gomega.Expect(spans[0].Name).To(gomega.Equal("zipkin.testing.HelloService.Hello"))
+			gomega.Expect(spans[1].Name).To(gomega.Equal("grpc.health.v1.Health.Check"))
+		})
+	})
 })
@@ -16,7 +16,9 @@ package grpc
 
 import (
 	"context"
+	"fmt"
 	"strings"
+	"time"
 
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/peer"
@@ -24,23 +26,73 @@ import (
 	"google.golang.org/grpc/status"
 
 	"github.com/openzipkin/zipkin-go"
+	"github.com/openzipkin/zipkin-go/middleware/capture"
 	"github.com/openzipkin/zipkin-go/model"
 )
 
+// payloadContentType is the content type of captured messages.
+const payloadContentType = "application/grpc"
+
 // A RPCHandler can be registered using WithClientRPCHandler or WithServerRPCHandler to intercept calls to HandleRPC of
 // a handler for additional span customization.
 type RPCHandler func(span zipkin.Span, rpcStats stats.RPCStats)
 
+// HealthCheckMethods are the methods of the gRPC health checking protocol,
+// e.g. to be excluded from tracing using SkipMethods.
+var HealthCheckMethods = []string{
+	"/grpc.health.v1.Health/Check",
+	"/grpc.health.v1.Health/Watch",
+}
+
+// ReflectionMethods are the methods of the gRPC server reflection protocol,
+// e.g. to be excluded from tracing using SkipMethods.
+var ReflectionMethods = []string{
+	"/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo",
+	"/grpc.reflection.v1.ServerReflection/ServerReflectionInfo",
+}
+
+// skipKey flags the context of a call which is excluded from tracing.
+type skipKey struct{}
+
+func methodSet(methods []string) map[string]bool {
+	set := make(map[string]bool, len(methods))
+	for _, method := range methods {
+		set[method] = true
+	}
+	return set
+}
+
 func spanName(rti *stats.RPCTagInfo) string {
 	name := strings.TrimPrefix(rti.FullMethodName, "/")
 	name = strings.Replace(name, "/", ".", -1)
 	return name
 }
 
-func handleRPC(ctx context.Context, rs stats.RPCStats) {
+// defaultErrorClassifier classifies calls with a status code other than OK as
+// errors.
+var defaultErrorClassifier = zipkin.ErrorClassifierFunc(func(err error, statusCode int) zipkin.Severity {
+	if err != nil || codes.Code(statusCode) != codes.OK {
+		return zipkin.SeverityError
+	}
+	return zipkin.SeverityNone
+})
+
+func handleRPC(ctx context.Context, rs stats.RPCStats, classifier zipkin.ErrorClassifier, c *capture.Config) {
 	span := zipkin.SpanFromContext(ctx)
 
 	switch rs := rs.(type) {
+	case *stats.InPayload:
+		if rs.Client {
+			capturePayload(span, c, rs.RecvTime, capture.AnnotationResponse, rs.Payload)
+		} else {
+			capturePayload(span, c, rs.RecvTime, capture.AnnotationRequest, rs.Payload)
+		}
+	case *stats.OutPayload:
+		if rs.Client {
+			capturePayload(span, c, rs.SentTime, capture.AnnotationRequest, rs.Payload)
+		} else {
+			capturePayload(span, c, rs.SentTime, capture.AnnotationResponse, rs.Payload)
+		}
 	case *stats.End:
 		s, ok := status.FromError(rs.Error)
 		// rs.Error should always be convertable to a status, this is just a defensive check.
@@ -49,15 +101,42 @@ func handleRPC(ctx context.Context, rs stats.RPCStats) {
 				// Uppercase for consistency with Brave
 				c := strings.ToUpper(s.Code().String())
 				span.Tag("grpc.status_code", c)
-				zipkin.TagError.Set(span, c)
+				recordError(span, classifier.Classify(rs.Error, int(s.Code())), c)
 			}
 		} else {
-			zipkin.TagError.Set(span, rs.Error.Error())
+			recordError(span, classifier.Classify(rs.Error, int(codes.Unknown)), rs.Error.Error())
 		}
 		span.Finish()
 	}
 }
 
+// capturePayload records the message on span if capturing is enabled for
+// application/grpc and the trace has the debug flag. Messages are recorded in
+// their String form, e.g. the text format of protobuf messages.
+func capturePayload(span zipkin.Span, c *capture.Config, t time.Time, name string, msg interface{}) {
+	if c == nil || span == nil || !capture.Enabled(span.Context()) || !c.Allowed(payloadContentType) {
+		return
+	}
+	var payload string
+	if s, ok := msg.(fmt.Stringer); ok {
+		payload = s.String()
+	} else {
+		payload = fmt.Sprintf("%+v", msg)
+	}
+	c.Annotate(span, t, name, payloadContentType, []byte(payload))
+}
+
+// recordError tags the span with the description of a failed call according to
+// its severity.
+func recordError(span zipkin.Span, severity zipkin.Severity, description string) {
+	switch severity {
+	case zipkin.SeverityError:
+		zipkin.TagError.Set(span, description)
+	case zipkin.SeverityExpected:
+		zipkin.TagErrorExpected.Set(span, description)
+	}
+}
+
 func remoteEndpointFromContext(ctx context.Context, name string) *model.Endpoint {
 	remoteAddr := ""
 
@@ -69,3 +148,9 @@ func remoteEndpointFromContext(ctx context.Context, name string) *model.Endpoint
 	ep, _ := zipkin.NewEndpoint(name, remoteAddr)
 	return ep
 }
+
+// isUnsampled reports whether a sampling decision was made to not sample the
+// trace of sc.
+func isUnsampled(sc model.SpanContext) bool {
+	return !sc.Debug && sc.Sampled != nil && !*sc.Sampled
+}
@@ -0,0 +1,112 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"time"
+
+	zipkin "github.com/openzipkin/zipkin-go"
+	"github.com/openzipkin/zipkin-go/middleware/capture"
+)
+
+// ServerCapture enables capturing of the request and response bodies of
+// traces with the debug flag, recorded as annotations of the server span
+// according to c, see package capture. Bodies written using io.ReaderFrom are
+// not captured. Capturing is disabled by default.
+func ServerCapture(c capture.Config) ServerOption {
+	return func(h *handler) {
+		h.capture = &c
+	}
+}
+
+// TransportCapture enables capturing of the request and response bodies of
+// traces with the debug flag, recorded as annotations of the client span
+// according to c, see package capture. The first bytes of captured response
+// bodies are read before RoundTrip returns. Capturing is disabled by default.
+func TransportCapture(c capture.Config) TransportOption {
+	return func(t *transport) {
+		t.capture = &c
+	}
+}
+
+// payloadCapture holds the bodies captured for a span.
+type payloadCapture struct {
+	config   *capture.Config
+	request  *capture.Buffer
+	response *capture.Buffer
+}
+
+// newPayloadCapture returns a payloadCapture for sp if capturing is enabled
+// and the span has the debug flag, else nil.
+func newPayloadCapture(c *capture.Config, sp zipkin.Span) *payloadCapture {
+	if c == nil || !capture.Enabled(sp.Context()) {
+		return nil
+	}
+	return &payloadCapture{config: c, response: c.NewBuffer()}
+}
+
+// captureRequest replaces the body of req with one copying the bytes read to
+// the request buffer if its content type is allowed.
+func (p *payloadCapture) captureRequest(req *http.Request) {
+	if req.Body == nil || req.Body == http.NoBody || !p.config.Allowed(req.Header.Get("Content-Type")) {
+		return
+	}
+	p.request = p.config.NewBuffer()
+	req.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.TeeReader(req.Body, p.request), req.Body}
+}
+
+// peekResponse reads the first bytes of the response body into the response
+// buffer, keeping them readable from the body.
+func (p *payloadCapture) peekResponse(res *http.Response) {
+	if res.Body == nil || res.Body == http.NoBody {
+		return
+	}
+	b, err := p.response.Peek(res.Body)
+	var rest io.Reader = res.Body
+	if err != nil {
+		// hand the read error to the caller after the bytes read
+		rest = &errReader{err: err}
+	}
+	res.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(b), rest), res.Body}
+}
+
+// annotate records the captured bodies on sp.
+func (p *payloadCapture) annotate(sp zipkin.Span, requestType, responseType string) {
+	now := time.Now()
+	if p.request != nil {
+		p.config.Annotate(sp, now, capture.AnnotationRequest, requestType, p.request.Bytes())
+	}
+	if p.config.Allowed(responseType) {
+		p.config.Annotate(sp, now, capture.AnnotationResponse, responseType, p.response.Bytes())
+	}
+}
+
+// errReader returns err on every read.
+type errReader struct {
+	err error
+}
+
+func (r *errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
@@ -30,7 +30,7 @@ var ErrValidTracerRequired = errors.New("valid tracer required")
 // Client holds a Zipkin instrumented HTTP Client.
 type Client struct {
 	*http.Client
-	tracer           *zipkin.Tracer
+	tracer           zipkin.TracerInterface
 	httpTrace        bool
 	defaultTags      map[string]string
 	transportOptions []TransportOption
@@ -73,7 +73,7 @@ func TransportOptions(options ...TransportOption) ClientOption {
 
 // NewClient returns an HTTP Client adding Zipkin instrumentation around an
 // embedded standard Go http.Client.
-func NewClient(tracer *zipkin.Tracer, options ...ClientOption) (*Client, error) {
+func NewClient(tracer zipkin.TracerInterface, options ...ClientOption) (*Client, error) {
 	if tracer == nil {
 		return nil, ErrValidTracerRequired
 	}
@@ -15,11 +15,17 @@
 package http_test
 
 import (
+	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	zipkin "github.com/openzipkin/zipkin-go"
 	httpclient "github.com/openzipkin/zipkin-go/middleware/http"
+	"github.com/openzipkin/zipkin-go/model"
 	"github.com/openzipkin/zipkin-go/reporter/recorder"
 )
 
@@ -93,3 +99,80 @@ func TestHTTPClient(t *testing.T) {
 	res.Body.Close()
 
 }
+
+func TestHTTPClientDoHedged(t *testing.T) {
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			// the first attempt is slow and gets canceled
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+			return
+		}
+		_, _ = w.Write([]byte("hedged"))
+	}))
+	defer ts.Close()
+
+	reporter := recorder.NewReporter()
+	defer reporter.Close()
+
+	tracer, _ := zipkin.NewTracer(reporter)
+	client, err := httpclient.NewClient(tracer)
+	if err != nil {
+		t.Fatalf("unable to create http client: %+v", err)
+	}
+
+	req, _ := http.NewRequest("GET", ts.URL, nil)
+	res, err := client.DoHedged(req, "hedged", 10*time.Millisecond, 3)
+	if err != nil {
+		t.Fatalf("unable to execute hedged request: %+v", err)
+	}
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if want, have := "hedged", string(body); want != have {
+		t.Errorf("body want %q, have %q", want, have)
+	}
+
+	// app span, two attempt spans and their client spans
+	var spans []model.SpanModel
+	for deadline := time.Now().Add(time.Second); len(spans) < 5 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+		spans = append(spans, reporter.Flush()...)
+	}
+	if want, have := 5, len(spans); want != have {
+		t.Fatalf("spans want %d, have %d", want, have)
+	}
+
+	attempts := map[string]model.SpanModel{}
+	for _, span := range spans {
+		switch span.Name {
+		case "hedged":
+			if want, have := "2", span.Tags["hedge.winner"]; want != have {
+				t.Errorf("winner want %q, have %q", want, have)
+			}
+			if want, have := "2", span.Tags["hedge.attempts"]; want != have {
+				t.Errorf("attempts want %q, have %q", want, have)
+			}
+		case "hedged attempt":
+			attempts[span.Tags["hedge.attempt"]] = span
+		}
+	}
+	if want, have := "true", attempts["1"].Tags["hedge.canceled"]; want != have {
+		t.Errorf("first attempt canceled want %q, have %q", want, have)
+	}
+	if want, have := "true", attempts["2"].Tags["hedge.winner"]; want != have {
+		t.Errorf("second attempt winner want %q, have %q", want, have)
+	}
+}
+
+func TestHTTPClientDoHedgedBody(t *testing.T) {
+	tracer, _ := zipkin.NewTracer(recorder.NewReporter())
+	client, _ := httpclient.NewClient(tracer)
+
+	req, _ := http.NewRequest("POST", "http://localhost", ioutil.NopCloser(strings.NewReader("body")))
+	if _, err := client.DoHedged(req, "hedged", time.Millisecond, 2); err != httpclient.ErrHedgeBody {
+		t.Errorf("error want %v, have %v", httpclient.ErrHedgeBody, err)
+	}
+}
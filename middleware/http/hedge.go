@@ -0,0 +1,201 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	zipkin "github.com/openzipkin/zipkin-go"
+	"github.com/openzipkin/zipkin-go/model"
+)
+
+// ErrHedgeBody is returned by DoHedged for requests with a body which can not
+// be sent more than once, as http.Request.GetBody is not set.
+var ErrHedgeBody = errors.New("hedged requests require GetBody to be set for requests with a body")
+
+// tags recorded on hedged requests
+const (
+	tagHedgeAttempt  = "hedge.attempt"
+	tagHedgeAttempts = "hedge.attempts"
+	tagHedgeWinner   = "hedge.winner"
+	tagHedgeCanceled = "hedge.canceled"
+)
+
+// hedgeResult holds the outcome of an attempt of a hedged request.
+type hedgeResult struct {
+	attempt int
+	res     *http.Response
+	err     error
+}
+
+// DoHedged sends req and, if no response arrived after delay, sends it again,
+// up to attempts times in total, returning the first response received. Slow
+// attempts are canceled once a response arrived. Failed attempts immediately
+// trigger the next attempt. If all attempts fail the last error is returned.
+//
+// The hedged request is traced using an application span named name, which
+// records the number of attempts in the hedge.attempts tag and the winning
+// attempt in the hedge.winner tag. Every attempt gets a child span tagged with
+// its number in hedge.attempt, parent of the client span created by the
+// instrumented transport. Canceled attempts are tagged with hedge.canceled.
+// The spans of the winning attempt and the application span are finished when
+// the response body is closed.
+//
+// Only idempotent requests should be hedged. Requests with a body require
+// GetBody to be set, as done by http.NewRequest for common body types.
+func (c *Client) DoHedged(req *http.Request, name string, delay time.Duration, attempts int) (*http.Response, error) {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return nil, ErrHedgeBody
+	}
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var parentContext model.SpanContext
+	if span := zipkin.SpanFromContext(req.Context()); span != nil {
+		parentContext = span.Context()
+	}
+	appSpan := c.tracer.StartSpan(name, zipkin.Parent(parentContext))
+	zipkin.TagHTTPMethod.Set(appSpan, req.Method)
+	zipkin.TagHTTPPath.Set(appSpan, req.URL.Path)
+
+	var (
+		results = make(chan hedgeResult, attempts)
+		spans   = make([]zipkin.Span, 0, attempts)
+		cancels = make([]context.CancelFunc, 0, attempts)
+		lastErr error
+		pending int
+	)
+	launch := func() {
+		n := len(spans) + 1
+		sp := c.tracer.StartSpan(name+" attempt", zipkin.Parent(appSpan.Context()))
+		sp.Tag(tagHedgeAttempt, strconv.Itoa(n))
+		ctx, cancel := context.WithCancel(zipkin.NewContext(req.Context(), sp))
+		spans, cancels = append(spans, sp), append(cancels, cancel)
+		pending++
+
+		// every attempt needs headers of its own as the transport injects the
+		// span context of the attempt
+		r := req.WithContext(ctx)
+		r.Header = make(http.Header, len(req.Header))
+		for k, v := range req.Header {
+			r.Header[k] = append([]string(nil), v...)
+		}
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				results <- hedgeResult{attempt: n, err: err}
+				return
+			}
+			r.Body = body
+		}
+		go func() {
+			res, err := c.Client.Do(r)
+			results <- hedgeResult{attempt: n, res: res, err: err}
+		}()
+	}
+
+	launch()
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	for pending > 0 {
+		var hedge <-chan time.Time
+		if len(spans) < attempts {
+			hedge = timer.C
+		}
+		select {
+		case <-hedge:
+			launch()
+			timer.Reset(delay)
+		case result := <-results:
+			pending--
+			sp := spans[result.attempt-1]
+			if result.err != nil {
+				zipkin.TagError.Set(sp, result.err.Error())
+				sp.Finish()
+				cancels[result.attempt-1]()
+				lastErr = result.err
+				if pending == 0 && len(spans) < attempts {
+					launch()
+				}
+				continue
+			}
+
+			appSpan.Tag(tagHedgeAttempts, strconv.Itoa(len(spans)))
+			appSpan.Tag(tagHedgeWinner, strconv.Itoa(result.attempt))
+			sp.Tag(tagHedgeWinner, "true")
+			if code := result.res.StatusCode; code < 200 || code > 299 {
+				zipkin.TagHTTPStatusCode.Set(appSpan, strconv.Itoa(code))
+			}
+			for i, cancel := range cancels {
+				if i != result.attempt-1 {
+					cancel()
+				}
+			}
+			go drainHedged(results, pending, spans)
+
+			winner := cancels[result.attempt-1]
+			result.res.Body = &hedgeCloser{
+				ReadCloser: result.res.Body,
+				done: func() {
+					sp.Finish()
+					appSpan.Finish()
+					winner()
+				},
+			}
+			return result.res, nil
+		}
+	}
+
+	appSpan.Tag(tagHedgeAttempts, strconv.Itoa(len(spans)))
+	zipkin.TagError.Set(appSpan, lastErr.Error())
+	appSpan.Finish()
+	return nil, lastErr
+}
+
+// drainHedged closes the responses of the attempts still pending after a
+// hedged request has been won and finishes their spans.
+func drainHedged(results <-chan hedgeResult, pending int, spans []zipkin.Span) {
+	for ; pending > 0; pending-- {
+		result := <-results
+		sp := spans[result.attempt-1]
+		sp.Tag(tagHedgeCanceled, "true")
+		if result.res != nil {
+			_ = result.res.Body.Close()
+		}
+		sp.Finish()
+	}
+}
+
+// hedgeCloser finishes the spans of a hedged request once the response body
+// of the winning attempt is closed.
+type hedgeCloser struct {
+	io.ReadCloser
+	once sync.Once
+	done func()
+}
+
+func (h *hedgeCloser) Close() (err error) {
+	err = h.ReadCloser.Close()
+	h.once.Do(h.done)
+	return
+}
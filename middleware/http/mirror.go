@@ -0,0 +1,209 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	zipkin "github.com/openzipkin/zipkin-go"
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/propagation/b3"
+)
+
+// mirror defaults
+const (
+	defaultMirrorTimeout     = 10 * time.Second
+	defaultMirrorMaxBodySize = 1 << 20
+)
+
+// tags recorded on mirrored requests
+const (
+	tagShadow      = "shadow"
+	tagShadowError = "shadow.error"
+)
+
+type mirror struct {
+	tracer      zipkin.TracerInterface
+	shadow      *url.URL
+	sample      zipkin.Sampler
+	client      *http.Client
+	maxBodySize int64
+	next        http.Handler
+}
+
+// MirrorOption allows one to configure optional mirror configuration.
+type MirrorOption func(*mirror)
+
+// MirrorClient sets the http.Client sending the mirrored requests. It should
+// not be instrumented, as the mirror middleware traces the requests itself.
+// The default client times out after 10 seconds.
+func MirrorClient(client *http.Client) MirrorOption {
+	return func(m *mirror) {
+		if client != nil {
+			m.client = client
+		}
+	}
+}
+
+// MirrorMaxBodySize sets the size of the largest request body mirrored.
+// Requests with larger bodies are not mirrored. Defaults to 1 MiB.
+func MirrorMaxBodySize(n int64) MirrorOption {
+	return func(m *mirror) {
+		m.maxBodySize = n
+	}
+}
+
+// NewMirrorMiddleware returns a http.Handler middleware mirroring rate (0.0 to
+// 1.0) of the traced requests to the shadow backend, for traffic shadowing
+// experiments. The middleware needs to be placed behind the middleware
+// returned by NewServerMiddleware. Requests are selected by trace id, so all
+// requests of a trace are either mirrored or not.
+//
+// Mirrored requests are sent asynchronously with the path, query, headers and
+// body of the original request, and their responses are discarded. They are
+// traced with a client span tagged shadow=true, child of the server span. A
+// failure of the shadow backend is recorded in the shadow.error tag and never
+// tags the span as error, so experiments do not pollute error views in Zipkin.
+func NewMirrorMiddleware(t zipkin.TracerInterface, shadow string, rate float64, options ...MirrorOption) (func(http.Handler) http.Handler, error) {
+	if t == nil {
+		return nil, ErrValidTracerRequired
+	}
+	u, err := url.Parse(shadow)
+	if err != nil {
+		return nil, err
+	}
+	sample, err := zipkin.NewBoundarySampler(rate, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	m := mirror{
+		tracer:      t,
+		shadow:      u,
+		sample:      sample,
+		client:      &http.Client{Timeout: defaultMirrorTimeout},
+		maxBodySize: defaultMirrorMaxBodySize,
+	}
+	for _, option := range options {
+		option(&m)
+	}
+
+	return func(next http.Handler) http.Handler {
+		h := m
+		h.next = next
+		return h
+	}, nil
+}
+
+// ServeHTTP implements http.Handler.
+func (m mirror) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if parent := zipkin.SpanFromContext(r.Context()); parent != nil && m.sample(parent.Context().TraceID.Low) {
+		if req, ok := m.shadowRequest(r); ok {
+			go m.send(req, parent.Context())
+		}
+	}
+	m.next.ServeHTTP(w, r)
+}
+
+// shadowRequest returns a copy of r targeting the shadow backend, reading the
+// body of r if it does not exceed the max body size.
+func (m mirror) shadowRequest(r *http.Request) (*http.Request, bool) {
+	var body []byte
+	if r.Body != nil && r.Body != http.NoBody {
+		if r.ContentLength > m.maxBodySize {
+			return nil, false
+		}
+		b, err := ioutil.ReadAll(io.LimitReader(r.Body, m.maxBodySize+1))
+		// whatever was read is handed back to the handler
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(b), r.Body), r.Body}
+		if err != nil || int64(len(b)) > m.maxBodySize {
+			return nil, false
+		}
+		body = b
+	}
+
+	u := *r.URL
+	u.Scheme, u.Host, u.User = m.shadow.Scheme, m.shadow.Host, m.shadow.User
+	u.Path = singleJoiningSlash(m.shadow.Path, r.URL.Path)
+
+	// the mirrored request may outlive the original one, so it does not use
+	// its context
+	req, err := http.NewRequest(r.Method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, false
+	}
+	if body == nil {
+		req.Body = http.NoBody
+	}
+	for k, v := range r.Header {
+		req.Header[k] = append([]string(nil), v...)
+	}
+	return req, true
+}
+
+// send sends the mirrored request, discarding its response.
+func (m mirror) send(req *http.Request, parent model.SpanContext) {
+	sp := m.tracer.StartSpan("shadow/"+req.Method, zipkin.Kind(model.Client), zipkin.Parent(parent))
+	defer sp.Finish()
+
+	sp.Tag(tagShadow, "true")
+	zipkin.TagHTTPMethod.Set(sp, req.Method)
+	zipkin.TagHTTPPath.Set(sp, req.URL.Path)
+	if ep, err := zipkin.NewEndpoint("", req.URL.Host); err == nil {
+		sp.SetRemoteEndpoint(ep)
+	}
+
+	req = req.WithContext(zipkin.NewContext(context.Background(), sp))
+	_ = b3.InjectHTTP(req)(sp.Context())
+
+	res, err := m.client.Do(req)
+	if err != nil {
+		sp.Tag(tagShadowError, err.Error())
+		return
+	}
+	_, _ = io.Copy(ioutil.Discard, res.Body)
+	_ = res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		code := strconv.Itoa(res.StatusCode)
+		zipkin.TagHTTPStatusCode.Set(sp, code)
+		if res.StatusCode > 399 {
+			sp.Tag(tagShadowError, code)
+		}
+	}
+}
+
+func singleJoiningSlash(a, b string) string {
+	aslash := len(a) > 0 && a[len(a)-1] == '/'
+	bslash := len(b) > 0 && b[0] == '/'
+	switch {
+	case aslash && bslash:
+		return a + b[1:]
+	case !aslash && !bslash && a != "" && b != "":
+		return a + "/" + b
+	case a == "":
+		return b
+	}
+	return a + b
+}
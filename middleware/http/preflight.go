@@ -0,0 +1,100 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	zipkin "github.com/openzipkin/zipkin-go"
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/propagation/b3"
+	"github.com/openzipkin/zipkin-go/reporter"
+)
+
+// PreflightPolicy decides how the server middleware handles CORS preflight
+// requests, which for browser facing APIs can double the span volume.
+type PreflightPolicy int
+
+// Available preflight policies.
+const (
+	// PreflightTrace traces preflight requests like any other request.
+	PreflightTrace PreflightPolicy = iota
+	// PreflightSkip does not trace preflight requests.
+	PreflightSkip
+	// PreflightReduced traces preflight requests with the http.method and
+	// http.status_code tags only.
+	PreflightReduced
+	// PreflightCount does not trace preflight requests but counts them in the
+	// zipkin.TagHTTPPreflights tag of the next sampled request.
+	PreflightCount
+)
+
+// Preflight sets the policy for CORS preflight requests, i.e. OPTIONS requests
+// holding an Access-Control-Request-Method header. By default preflight
+// requests are traced like any other request.
+func Preflight(policy PreflightPolicy) ServerOption {
+	return func(h *handler) {
+		h.preflight = policy
+		if policy == PreflightCount && h.preflights == nil {
+			h.preflights = new(reporter.Counter)
+		}
+	}
+}
+
+// isPreflight reports whether r is a CORS preflight request.
+func isPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// servePreflight handles a preflight request according to the preflight
+// policy, reporting false if it is to be traced like any other request.
+func (h handler) servePreflight(w http.ResponseWriter, r *http.Request) bool {
+	switch h.preflight {
+	case PreflightSkip:
+		h.next.ServeHTTP(w, r)
+	case PreflightCount:
+		h.preflights.Inc()
+		h.next.ServeHTTP(w, r)
+	case PreflightReduced:
+		sc := h.tracer.Extract(b3.ExtractHTTP(r, h.extractOptions...))
+		name := h.name
+		if len(name) == 0 {
+			name = r.Method
+		}
+		sp := h.tracer.StartSpan(name, zipkin.Kind(model.Server), zipkin.Parent(sc))
+		ri := &rwInterceptor{w: w, statusCode: 200}
+		defer func() {
+			zipkin.TagHTTPMethod.Set(sp, r.Method)
+			zipkin.TagHTTPStatusCode.Set(sp, strconv.Itoa(ri.getStatusCode()))
+			sp.Finish()
+		}()
+		h.next.ServeHTTP(ri.wrap(), r.WithContext(zipkin.NewContext(r.Context(), sp)))
+	default:
+		return false
+	}
+	return true
+}
+
+// tagPreflights tags sp with the number of preflight requests counted since
+// the last tagged request.
+func (h handler) tagPreflights(sp zipkin.Span) {
+	if h.preflights == nil {
+		return
+	}
+	if n := h.preflights.Reset(); n > 0 {
+		zipkin.TagHTTPPreflights.Set(sp, strconv.FormatUint(n, 10))
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+
+	zipkin "github.com/openzipkin/zipkin-go"
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/propagation/b3"
+)
+
+// ProxySpans will instruct the middleware to create a client span for the
+// upstream hop next to the server span, for handlers proxying requests like
+// CONNECT tunnels or an httputil.ReverseProxy. The client span is a child of
+// the server span and its context is injected into the request headers handed
+// to the next handler, so it is propagated to the upstream when the headers are
+// forwarded. Do not combine this option with an instrumented proxy transport,
+// as both would create a client span for the upstream hop.
+func ProxySpans(enabled bool) ServerOption {
+	return func(h *handler) {
+		h.proxySpans = enabled
+	}
+}
+
+// startProxySpan creates the client span for the upstream hop of a proxied
+// request and returns the request to hand to the next handler.
+func (h handler) startProxySpan(parent zipkin.Span, r *http.Request) (zipkin.Span, *http.Request) {
+	var upstream string
+	if r.Method == http.MethodConnect {
+		upstream = r.Host
+	} else if r.URL.IsAbs() {
+		upstream = r.URL.Host
+	}
+
+	sp := h.tracer.StartSpan(
+		r.Method,
+		zipkin.Kind(model.Client),
+		zipkin.Parent(parent.Context()),
+		zipkin.RemoteEndpoint(upstreamEndpoint(upstream)),
+	)
+
+	zipkin.TagHTTPMethod.Set(sp, r.Method)
+	if r.Method == http.MethodConnect {
+		// tunneled traffic is opaque, there are no headers to propagate to
+		return sp, r
+	}
+	zipkin.TagHTTPPath.Set(sp, r.URL.Path)
+
+	// copy the headers so the incoming request is left untouched
+	r = r.WithContext(r.Context())
+	header := make(http.Header, len(r.Header))
+	for k, v := range r.Header {
+		header[k] = append([]string(nil), v...)
+	}
+	r.Header = header
+	_ = b3.InjectHTTP(r)(sp.Context())
+
+	return sp, r
+}
+
+// upstreamEndpoint returns the endpoint of the upstream host without resolving
+// host names, hosts which are not an IP address are used as service name.
+func upstreamEndpoint(hostPort string) *model.Endpoint {
+	if hostPort == "" {
+		return nil
+	}
+
+	host, port, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		host, port = hostPort, ""
+	}
+
+	e := &model.Endpoint{}
+	if p, err := strconv.ParseUint(port, 10, 16); err == nil {
+		e.Port = uint16(p)
+	}
+	if ip := net.ParseIP(host); ip == nil {
+		e.ServiceName = host
+	} else if ip4 := ip.To4(); ip4 != nil {
+		e.IPv4 = ip4
+	} else {
+		e.IPv6 = ip
+	}
+	return e
+}
@@ -0,0 +1,38 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.23
+// +build go1.23
+
+package http
+
+import (
+	"net/http"
+	"strings"
+)
+
+// routePattern returns the route template matched by a http.ServeMux as found
+// in the request's Pattern field, available as of Go 1.23. The optional host
+// part of the pattern is omitted and the method is stripped so it can be used
+// as http.route tag.
+func routePattern(r *http.Request) string {
+	pattern := r.Pattern
+	if idx := strings.IndexByte(pattern, ' '); idx >= 0 {
+		pattern = strings.TrimLeft(pattern[idx+1:], " \t")
+	}
+	if idx := strings.IndexByte(pattern, '/'); idx > 0 {
+		pattern = pattern[idx:]
+	}
+	return pattern
+}
@@ -0,0 +1,68 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.23
+// +build go1.23
+
+// enable the Go 1.22 ServeMux routing as this module targets older versions.
+//go:debug httpmuxgo121=0
+
+package http_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	zipkin "github.com/openzipkin/zipkin-go"
+	mw "github.com/openzipkin/zipkin-go/middleware/http"
+	"github.com/openzipkin/zipkin-go/reporter/recorder"
+)
+
+func TestHTTPRoutePatternSpanName(t *testing.T) {
+	var (
+		spanRecorder = &recorder.ReporterRecorder{}
+		tr, _        = zipkin.NewTracer(spanRecorder, zipkin.WithLocalEndpoint(lep))
+		mux          = http.NewServeMux()
+	)
+
+	mux.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, _ *http.Request) {})
+	mux.HandleFunc("/static/", func(w http.ResponseWriter, _ *http.Request) {})
+
+	handler := mw.NewServerMiddleware(
+		tr, mw.SpanNamer(func(r *http.Request) string { return "fallback" }),
+	)(mux)
+
+	for path, want := range map[string]struct{ name, route string }{
+		"/users/123":      {"GET /users/{id}", "/users/{id}"},
+		"/static/a.css":   {"GET /static/", "/static/"},
+		"/does/not/exist": {"fallback", ""},
+	} {
+		request, _ := http.NewRequest("GET", path, nil)
+		handler.ServeHTTP(httptest.NewRecorder(), request)
+
+		spans := spanRecorder.Flush()
+		if len(spans) != 1 {
+			t.Fatalf("[%s] Expected 1 span, got %d", path, len(spans))
+		}
+
+		if have := spans[0].Name; want.name != have {
+			t.Errorf("[%s] Expected span name %s, got %s", path, want.name, have)
+		}
+
+		if have := spans[0].Tags[string(zipkin.TagHTTPRoute)]; want.route != have {
+			t.Errorf("[%s] Expected route %s, got %s", path, want.route, have)
+		}
+	}
+}
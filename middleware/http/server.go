@@ -18,21 +18,30 @@ import (
 	"io"
 	"net/http"
 	"strconv"
-	"sync/atomic"
 
 	zipkin "github.com/openzipkin/zipkin-go"
+	"github.com/openzipkin/zipkin-go/middleware/capture"
 	"github.com/openzipkin/zipkin-go/model"
 	"github.com/openzipkin/zipkin-go/propagation/b3"
+	"github.com/openzipkin/zipkin-go/reporter"
 )
 
 type handler struct {
-	tracer          *zipkin.Tracer
+	tracer          zipkin.TracerInterface
 	name            string
+	spanNamer       func(*http.Request) string
 	next            http.Handler
 	tagResponseSize bool
+	proxySpans      bool
+	lazySpans       bool
+	extractOptions  []b3.ExtractOption
 	defaultTags     map[string]string
 	requestSampler  RequestSamplerFunc
 	errHandler      ErrHandler
+	errClassifier   zipkin.ErrorClassifier
+	preflight       PreflightPolicy
+	preflights      *reporter.Counter // preflight requests counted, see PreflightCount
+	capture         *capture.Config
 }
 
 // ServerOption allows Middleware to be optionally configured.
@@ -55,14 +64,25 @@ func TagResponseSize(enabled bool) ServerOption {
 
 // SpanName sets the name of the spans the middleware creates. Use this if
 // wrapping each endpoint with its own Middleware.
-// If omitting the SpanName option, the middleware will use the http request
-// method as span name.
+// If omitting the SpanName option, the middleware will use the route pattern
+// matched by a http.ServeMux on Go 1.23+ combined with the http request method
+// as span name. If no route pattern is available the SpanNamer function is
+// used and as last resort the http request method.
 func SpanName(name string) ServerOption {
 	return func(h *handler) {
 		h.name = name
 	}
 }
 
+// SpanNamer sets a function to derive the span name from the incoming request.
+// It is used as fallback if no route pattern is available, e.g. when routing
+// with a third party router or on Go versions before 1.23.
+func SpanNamer(namer func(r *http.Request) string) ServerOption {
+	return func(h *handler) {
+		h.spanNamer = namer
+	}
+}
+
 // RequestSampler allows one to set the sampling decision based on the details
 // found in the http.Request. If wanting to keep the existing sampling decision
 // from upstream as is, this function should return nil.
@@ -79,13 +99,49 @@ func ServerErrHandler(eh ErrHandler) ServerOption {
 	}
 }
 
+// ServerErrorClassifier sets the ErrorClassifier deciding which response status
+// codes are tagged as errors by the ErrHandler and which are tagged as
+// expected. By default status codes above 399 are errors.
+func ServerErrorClassifier(c zipkin.ErrorClassifier) ServerOption {
+	return func(h *handler) {
+		if c != nil {
+			h.errClassifier = c
+		}
+	}
+}
+
+// LazySpans when enabled skips recording of requests which are not sampled.
+// The SpanContext of these requests is still propagated through the request
+// context, so downstream calls carry the sampling decision. If the decision
+// was made upstream no span is created. Otherwise a span is started to consult
+// the tracer's sampler, but no tags or annotations are recorded on it when it
+// turns out unsampled. This reduces overhead at low sample rates.
+func LazySpans(enabled bool) ServerOption {
+	return func(h *handler) {
+		h.lazySpans = enabled
+	}
+}
+
+// StrictParsing when enabled rejects malformed or oversized B3 headers of
+// incoming requests using the strict B3 parsers, see b3.WithStrictParsing.
+// Requests with rejected headers start a new trace.
+func StrictParsing(enabled bool) ServerOption {
+	return func(h *handler) {
+		h.extractOptions = nil
+		if enabled {
+			h.extractOptions = []b3.ExtractOption{b3.WithStrictParsing()}
+		}
+	}
+}
+
 // NewServerMiddleware returns a http.Handler middleware with Zipkin tracing.
-func NewServerMiddleware(t *zipkin.Tracer, options ...ServerOption) func(http.Handler) http.Handler {
+func NewServerMiddleware(t zipkin.TracerInterface, options ...ServerOption) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		h := &handler{
-			tracer:     t,
-			next:       next,
-			errHandler: defaultErrHandler,
+			tracer:        t,
+			next:          next,
+			errHandler:    defaultErrHandler,
+			errClassifier: defaultErrorClassifier,
 		}
 		for _, option := range options {
 			option(h)
@@ -98,8 +154,12 @@ func NewServerMiddleware(t *zipkin.Tracer, options ...ServerOption) func(http.Ha
 func (h handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	var spanName string
 
+	if h.preflight != PreflightTrace && isPreflight(r) && h.servePreflight(w, r) {
+		return
+	}
+
 	// try to extract B3 Headers from upstream
-	sc := h.tracer.Extract(b3.ExtractHTTP(r))
+	sc := h.tracer.Extract(b3.ExtractHTTP(r, h.extractOptions...))
 
 	if h.requestSampler != nil {
 		if sample := h.requestSampler(r); sample != nil {
@@ -107,12 +167,21 @@ func (h handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if h.lazySpans && isUnsampled(sc) {
+		// upstream decided not to sample, propagate the context only
+		h.next.ServeHTTP(w, r.WithContext(zipkin.NewContextFromSpanContext(r.Context(), sc)))
+		return
+	}
+
 	remoteEndpoint, _ := zipkin.NewEndpoint("", r.RemoteAddr)
 
-	if len(h.name) == 0 {
-		spanName = r.Method
-	} else {
+	if len(h.name) != 0 {
 		spanName = h.name
+	} else if h.spanNamer != nil {
+		spanName = h.spanNamer(r)
+	}
+	if len(spanName) == 0 {
+		spanName = r.Method
 	}
 
 	// create Span using SpanContext if found
@@ -121,11 +190,21 @@ func (h handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		zipkin.Kind(model.Server),
 		zipkin.Parent(sc),
 		zipkin.RemoteEndpoint(remoteEndpoint),
+		zipkin.WatchContext(r.Context()),
 	)
 
+	if h.lazySpans && isUnsampled(sp.Context()) {
+		// sampler decided not to sample, skip recording span details
+		h.next.ServeHTTP(w, r.WithContext(zipkin.NewContextFromSpanContext(r.Context(), sp.Context())))
+		return
+	}
+
 	for k, v := range h.defaultTags {
 		sp.Tag(k, v)
 	}
+	if !isUnsampled(sp.Context()) {
+		h.tagPreflights(sp)
+	}
 
 	// add our span to context
 	ctx := zipkin.NewContext(r.Context(), sp)
@@ -141,30 +220,55 @@ func (h handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// status code.
 	ri := &rwInterceptor{w: w, statusCode: 200}
 
+	// the request handed to the next handler which gets routed by ServeMux
+	req := r.WithContext(ctx)
+
+	if ri.capture = newPayloadCapture(h.capture, sp); ri.capture != nil {
+		ri.capture.captureRequest(req)
+	}
+
+	var proxySpan zipkin.Span
+	if h.proxySpans {
+		proxySpan, req = h.startProxySpan(sp, req)
+	}
+
 	// tag found response size and status code on exit
 	defer func() {
+		if route := routePattern(req); route != "" {
+			zipkin.TagHTTPRoute.Set(sp, route)
+			if len(h.name) == 0 {
+				sp.SetName(r.Method + " " + route)
+			}
+		}
 		code := ri.getStatusCode()
 		sCode := strconv.Itoa(code)
-		if code > 399 {
-			h.errHandler(sp, nil, code)
-		}
+		recordError(sp, h.errClassifier, h.errHandler, nil, code)
 		zipkin.TagHTTPStatusCode.Set(sp, sCode)
-		if h.tagResponseSize && atomic.LoadUint64(&ri.size) > 0 {
+		if h.tagResponseSize && ri.size.Load() > 0 {
 			zipkin.TagHTTPResponseSize.Set(sp, ri.getResponseSize())
 		}
+		if ri.capture != nil {
+			ri.capture.annotate(sp, r.Header.Get("Content-Type"), ri.Header().Get("Content-Type"))
+		}
+		if proxySpan != nil {
+			recordError(proxySpan, h.errClassifier, h.errHandler, nil, code)
+			zipkin.TagHTTPStatusCode.Set(proxySpan, sCode)
+			proxySpan.Finish()
+		}
 		sp.Finish()
 	}()
 
 	// call next http Handler func using our updated context.
-	h.next.ServeHTTP(ri.wrap(), r.WithContext(ctx))
+	h.next.ServeHTTP(ri.wrap(), req)
 }
 
 // rwInterceptor intercepts the ResponseWriter so it can track response size
 // and returned status code.
 type rwInterceptor struct {
 	w          http.ResponseWriter
-	size       uint64
+	size       reporter.Counter
 	statusCode int
+	capture    *payloadCapture
 }
 
 func (r *rwInterceptor) Header() http.Header {
@@ -173,7 +277,10 @@ func (r *rwInterceptor) Header() http.Header {
 
 func (r *rwInterceptor) Write(b []byte) (n int, err error) {
 	n, err = r.w.Write(b)
-	atomic.AddUint64(&r.size, uint64(n))
+	r.size.Add(uint64(n))
+	if r.capture != nil {
+		_, _ = r.capture.response.Write(b[:n])
+	}
 	return
 }
 
@@ -187,7 +294,7 @@ func (r *rwInterceptor) getStatusCode() int {
 }
 
 func (r *rwInterceptor) getResponseSize() string {
-	return strconv.FormatUint(atomic.LoadUint64(&r.size), 10)
+	return strconv.FormatUint(r.size.Load(), 10)
 }
 
 func (r *rwInterceptor) wrap() http.ResponseWriter {
@@ -414,3 +521,9 @@ func (r *rwInterceptor) wrap() http.ResponseWriter {
 		}{r}
 	}
 }
+
+// isUnsampled reports whether a sampling decision was made to not sample the
+// trace of sc.
+func isUnsampled(sc model.SpanContext) bool {
+	return !sc.Debug && sc.Sampled != nil && !*sc.Sampled
+}
@@ -16,13 +16,21 @@ package http_test
 
 import (
 	"bytes"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"reflect"
 	"strconv"
 	"testing"
+	"time"
 
 	zipkin "github.com/openzipkin/zipkin-go"
+	"github.com/openzipkin/zipkin-go/middleware/capture"
 	mw "github.com/openzipkin/zipkin-go/middleware/http"
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/propagation/b3"
 	"github.com/openzipkin/zipkin-go/reporter/recorder"
 )
 
@@ -270,3 +278,412 @@ func TestHTTPRequestSampler(t *testing.T) {
 	}
 
 }
+
+func TestHTTPSpanNamer(t *testing.T) {
+	var (
+		spanRecorder = &recorder.ReporterRecorder{}
+		tr, _        = zipkin.NewTracer(spanRecorder, zipkin.WithLocalEndpoint(lep))
+		httpRecorder = httptest.NewRecorder()
+	)
+
+	request, err := http.NewRequest("GET", "/orders/42", nil)
+	if err != nil {
+		t.Fatalf("unable to create request")
+	}
+
+	httpHandlerFunc := http.HandlerFunc(httpHandler(200, nil, bytes.NewBufferString("")))
+
+	handler := mw.NewServerMiddleware(tr, mw.SpanNamer(func(r *http.Request) string {
+		return r.Method + " orders"
+	}))(httpHandlerFunc)
+
+	handler.ServeHTTP(httpRecorder, request)
+
+	spans := spanRecorder.Flush()
+
+	if want, have := 1, len(spans); want != have {
+		t.Fatalf("Expected %d spans, got %d", want, have)
+	}
+
+	if want, have := "GET orders", spans[0].Name; want != have {
+		t.Errorf("Expected span name %s, got %s", want, have)
+	}
+}
+
+func TestHTTPProxySpans(t *testing.T) {
+	var (
+		spanRecorder = &recorder.ReporterRecorder{}
+		tr, _        = zipkin.NewTracer(spanRecorder, zipkin.WithLocalEndpoint(lep))
+		upstreamB3   http.Header
+	)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamB3 = r.Header
+		w.WriteHeader(202)
+	}))
+	defer upstream.Close()
+
+	target, _ := url.Parse(upstream.URL)
+	proxy := mw.NewServerMiddleware(tr, mw.ProxySpans(true))(httputil.NewSingleHostReverseProxy(target))
+
+	request := httptest.NewRequest("GET", "/api", nil)
+	proxy.ServeHTTP(httptest.NewRecorder(), request)
+
+	spans := spanRecorder.Flush()
+	if want, have := 2, len(spans); want != have {
+		t.Fatalf("Expected %d spans, got %d", want, have)
+	}
+
+	client, server := spans[0], spans[1]
+	if want, have := model.Client, client.Kind; want != have {
+		t.Errorf("Expected kind %s, got %s", want, have)
+	}
+	if want, have := model.Server, server.Kind; want != have {
+		t.Errorf("Expected kind %s, got %s", want, have)
+	}
+	if client.ParentID == nil || *client.ParentID != server.ID {
+		t.Errorf("Expected client span to be a child of the server span")
+	}
+	if want, have := client.ID.String(), upstreamB3.Get(b3.SpanID); want != have {
+		t.Errorf("Expected upstream span id %s, got %s", want, have)
+	}
+	if want, have := "202", client.Tags[string(zipkin.TagHTTPStatusCode)]; want != have {
+		t.Errorf("Expected status code %s, got %s", want, have)
+	}
+	if have := request.Header.Get(b3.SpanID); have != "" {
+		t.Errorf("Expected incoming request headers to be untouched, got span id %s", have)
+	}
+}
+
+func TestHTTPProxySpansConnect(t *testing.T) {
+	var (
+		spanRecorder = &recorder.ReporterRecorder{}
+		tr, _        = zipkin.NewTracer(spanRecorder, zipkin.WithLocalEndpoint(lep))
+		tunnelB3     string
+	)
+
+	request := httptest.NewRequest("CONNECT", "10.0.0.1:443", nil)
+
+	handler := mw.NewServerMiddleware(tr, mw.ProxySpans(true))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tunnelB3 = r.Header.Get(b3.SpanID)
+		w.WriteHeader(200)
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), request)
+
+	spans := spanRecorder.Flush()
+	if want, have := 2, len(spans); want != have {
+		t.Fatalf("Expected %d spans, got %d", want, have)
+	}
+
+	client := spans[0]
+	if want, have := "CONNECT", client.Name; want != have {
+		t.Errorf("Expected span name %s, got %s", want, have)
+	}
+	if client.RemoteEndpoint == nil || client.RemoteEndpoint.IPv4.String() != "10.0.0.1" || client.RemoteEndpoint.Port != 443 {
+		t.Errorf("Expected remote endpoint 10.0.0.1:443, got %+v", client.RemoteEndpoint)
+	}
+	if tunnelB3 != "" {
+		t.Errorf("Expected no propagation for CONNECT tunnels, got span id %s", tunnelB3)
+	}
+}
+
+func TestHTTPStrictParsing(t *testing.T) {
+	tr, _ := zipkin.NewTracer(&recorder.ReporterRecorder{}, zipkin.WithLocalEndpoint(lep))
+
+	for _, strict := range []bool{false, true} {
+		var sc model.SpanContext
+		handler := mw.NewServerMiddleware(tr, mw.StrictParsing(strict))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sc = zipkin.SpanFromContext(r.Context()).Context()
+		}))
+
+		request, err := http.NewRequest("GET", "/test", nil)
+		if err != nil {
+			t.Fatalf("unable to create request")
+		}
+		// upper case hex is accepted by the lenient parser only
+		request.Header.Set(b3.TraceID, "000000000000007B")
+		request.Header.Set(b3.SpanID, "0000000000000002")
+
+		handler.ServeHTTP(httptest.NewRecorder(), request)
+
+		if want, have := !strict, sc.TraceID == (model.TraceID{Low: 123}); want != have {
+			t.Errorf("[strict=%t] expected upstream trace id %t, have %s", strict, want, sc.TraceID)
+		}
+	}
+}
+
+func TestHTTPErrorClassifier(t *testing.T) {
+	spanRecorder := &recorder.ReporterRecorder{}
+	tr, _ := zipkin.NewTracer(spanRecorder, zipkin.WithLocalEndpoint(lep))
+
+	classifier := zipkin.ErrorClassifierFunc(func(_ error, statusCode int) zipkin.Severity {
+		switch {
+		case statusCode > 499:
+			return zipkin.SeverityError
+		case statusCode > 399:
+			return zipkin.SeverityExpected
+		}
+		return zipkin.SeverityNone
+	})
+
+	for _, code := range []int{http.StatusOK, http.StatusNotFound, http.StatusBadGateway} {
+		handler := mw.NewServerMiddleware(tr, mw.ServerErrorClassifier(classifier))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(code)
+		}))
+
+		request, err := http.NewRequest("GET", "/test", nil)
+		if err != nil {
+			t.Fatalf("unable to create request")
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), request)
+	}
+
+	spans := spanRecorder.Flush()
+	if want, have := 3, len(spans); want != have {
+		t.Fatalf("reported spans want %d, have %d", want, have)
+	}
+	for i, tc := range []struct{ expected, error string }{
+		{"", ""},
+		{"404", ""},
+		{"", "502"},
+	} {
+		if want, have := tc.expected, spans[i].Tags[string(zipkin.TagErrorExpected)]; want != have {
+			t.Errorf("span %d expected error want %q, have %q", i, want, have)
+		}
+		if want, have := tc.error, spans[i].Tags[string(zipkin.TagError)]; want != have {
+			t.Errorf("span %d error want %q, have %q", i, want, have)
+		}
+	}
+}
+
+func TestHTTPLazySpans(t *testing.T) {
+	spanRecorder := &recorder.ReporterRecorder{}
+	tr, _ := zipkin.NewTracer(spanRecorder, zipkin.WithLocalEndpoint(lep), zipkin.WithSampler(zipkin.NeverSample))
+
+	var sc model.SpanContext
+	handler := mw.NewServerMiddleware(tr, mw.LazySpans(true))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sc = zipkin.SpanFromContext(r.Context()).Context()
+	}))
+
+	for _, sampled := range []string{"0", "1", ""} {
+		request, err := http.NewRequest("GET", "/test", nil)
+		if err != nil {
+			t.Fatalf("unable to create request")
+		}
+		if sampled != "" {
+			request.Header.Set(b3.TraceID, "0000000000000001")
+			request.Header.Set(b3.SpanID, "0000000000000002")
+			request.Header.Set(b3.Sampled, sampled)
+		}
+
+		handler.ServeHTTP(httptest.NewRecorder(), request)
+
+		if sc.TraceID.Empty() {
+			t.Errorf("[%q] expected span context in request context", sampled)
+		}
+		if sampled == "0" && sc.ID != 2 {
+			t.Errorf("[%q] expected upstream span context, have %s", sampled, sc.ID)
+		}
+
+		reported := 0
+		if sampled == "1" {
+			reported = 1
+		}
+		if want, have := reported, len(spanRecorder.Flush()); want != have {
+			t.Errorf("[%q] expected %d spans, got %d", sampled, want, have)
+		}
+	}
+}
+
+// countingTracer decorates a tracer counting the spans started.
+type countingTracer struct {
+	zipkin.TracerInterface
+	started int
+}
+
+func (c *countingTracer) StartSpan(name string, options ...zipkin.SpanOption) zipkin.Span {
+	c.started++
+	return c.TracerInterface.StartSpan(name, options...)
+}
+
+func TestHTTPTracerDecorator(t *testing.T) {
+	var (
+		spanRecorder = &recorder.ReporterRecorder{}
+		tr, _        = zipkin.NewTracer(spanRecorder, zipkin.WithLocalEndpoint(lep))
+		decorated    = &countingTracer{TracerInterface: tr}
+	)
+
+	handler := mw.NewServerMiddleware(decorated)(httpHandler(200, nil, bytes.NewBufferString("")))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/test", nil))
+
+	if want, have := 1, decorated.started; want != have {
+		t.Errorf("started spans want %d, have %d", want, have)
+	}
+	if want, have := 1, len(spanRecorder.Flush()); want != have {
+		t.Errorf("reported spans want %d, have %d", want, have)
+	}
+
+	handler = mw.NewServerMiddleware(zipkin.NoopTracer{})(httpHandler(200, nil, bytes.NewBufferString("")))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/test", nil))
+}
+
+func TestHTTPPreflight(t *testing.T) {
+	preflight := func() *http.Request {
+		r := httptest.NewRequest("OPTIONS", "/test", nil)
+		r.Header.Set("Access-Control-Request-Method", "POST")
+		return r
+	}
+
+	for _, tc := range []struct {
+		policy mw.PreflightPolicy
+		spans  int
+		tags   map[string]string
+	}{
+		{policy: mw.PreflightTrace, spans: 3},
+		{policy: mw.PreflightSkip, spans: 1},
+		{policy: mw.PreflightReduced, spans: 3, tags: map[string]string{"http.method": "OPTIONS", "http.status_code": "200"}},
+		{policy: mw.PreflightCount, spans: 1},
+	} {
+		var (
+			spanRecorder = &recorder.ReporterRecorder{}
+			tr, _        = zipkin.NewTracer(spanRecorder, zipkin.WithLocalEndpoint(lep))
+			handler      = mw.NewServerMiddleware(tr, mw.Preflight(tc.policy))(httpHandler(200, nil, bytes.NewBufferString("")))
+		)
+
+		handler.ServeHTTP(httptest.NewRecorder(), preflight())
+		handler.ServeHTTP(httptest.NewRecorder(), preflight())
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/test", nil))
+
+		spans := spanRecorder.Flush()
+		if want, have := tc.spans, len(spans); want != have {
+			t.Fatalf("policy %d: spans want %d, have %d", tc.policy, want, have)
+		}
+		if tc.tags != nil {
+			if want, have := tc.tags, spans[0].Tags; !reflect.DeepEqual(want, have) {
+				t.Errorf("policy %d: preflight tags want %v, have %v", tc.policy, want, have)
+			}
+		}
+		want := ""
+		if tc.policy == mw.PreflightCount {
+			want = "2"
+		}
+		if have := spans[len(spans)-1].Tags[string(zipkin.TagHTTPPreflights)]; want != have {
+			t.Errorf("policy %d: preflights tag want %q, have %q", tc.policy, want, have)
+		}
+	}
+}
+
+func TestHTTPMirror(t *testing.T) {
+	type shadowRequest struct {
+		path, body, parentID string
+	}
+	shadowC := make(chan shadowRequest, 1)
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		shadowC <- shadowRequest{r.URL.Path, string(body), r.Header.Get(b3.ParentSpanID)}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer shadow.Close()
+
+	rec := recorder.NewReporter()
+	defer rec.Close()
+	tr, _ := zipkin.NewTracer(rec, zipkin.WithLocalEndpoint(lep))
+
+	mirror, err := mw.NewMirrorMiddleware(tr, shadow.URL+"/v2", 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var primaryBody string
+	h := mw.NewServerMiddleware(tr)(mirror(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		primaryBody = string(body)
+	})))
+
+	req := httptest.NewRequest("POST", "/orders", bytes.NewBufferString("payload"))
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if want, have := "payload", primaryBody; want != have {
+		t.Errorf("primary body want %q, have %q", want, have)
+	}
+
+	var sr shadowRequest
+	select {
+	case sr = <-shadowC:
+	case <-time.After(time.Second):
+		t.Fatal("expected request to be mirrored")
+	}
+	if want, have := "/v2/orders", sr.path; want != have {
+		t.Errorf("shadow path want %q, have %q", want, have)
+	}
+	if want, have := "payload", sr.body; want != have {
+		t.Errorf("shadow body want %q, have %q", want, have)
+	}
+
+	var spans []model.SpanModel
+	for deadline := time.Now().Add(time.Second); len(spans) < 2 && time.Now().Before(deadline); {
+		spans = append(spans, rec.Flush()...)
+		time.Sleep(time.Millisecond)
+	}
+	if want, have := 2, len(spans); want != have {
+		t.Fatalf("spans want %d, have %d", want, have)
+	}
+	sp, server := spans[0], spans[1]
+	if sp.Kind != model.Client {
+		sp, server = server, sp
+	}
+	if want, have := server.ID.String(), sr.parentID; want != have {
+		t.Errorf("shadow span parent want %s, have %s", want, have)
+	}
+	if want, have := "true", sp.Tags["shadow"]; want != have {
+		t.Errorf("shadow tag want %q, have %q", want, have)
+	}
+	if want, have := "500", sp.Tags["shadow.error"]; want != have {
+		t.Errorf("shadow.error tag want %q, have %q", want, have)
+	}
+	if _, ok := sp.Tags[string(zipkin.TagError)]; ok {
+		t.Errorf("expected shadow span not to be tagged as error")
+	}
+}
+
+func TestHTTPServerCapture(t *testing.T) {
+	rec := recorder.NewReporter()
+	defer rec.Close()
+	tr, _ := zipkin.NewTracer(rec)
+
+	h := mw.NewServerMiddleware(tr, mw.ServerCapture(capture.Config{
+		MaxSize: 12,
+		Redact: func(_ string, payload []byte) []byte {
+			return bytes.Replace(payload, []byte("secret"), []byte("***"), -1)
+		},
+	}))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if want, have := `{"a":"secret"}`, string(body); want != have {
+			t.Errorf("body want %q, have %q", want, have)
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = w.Write([]byte("accepted"))
+	}))
+
+	for _, flags := range []string{"1", "0"} {
+		req := httptest.NewRequest("POST", "/", bytes.NewBufferString(`{"a":"secret"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(b3.Flags, flags)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	spans := rec.Flush()
+	if want, have := 2, len(spans); want != have {
+		t.Fatalf("spans want %d, have %d", want, have)
+	}
+	var have []string
+	for _, a := range spans[0].Annotations {
+		have = append(have, a.Value)
+	}
+	want := []string{`request.payload: {"a":"***...`, "response.payload: accepted"}
+	if !reflect.DeepEqual(want, have) {
+		t.Errorf("annotations want %q, have %q", want, have)
+	}
+	if want, have := 0, len(spans[1].Annotations); want != have {
+		t.Errorf("annotations of non debug span want %d, have %d", want, have)
+	}
+}
@@ -25,8 +25,10 @@ import (
 	"strconv"
 
 	zipkin "github.com/openzipkin/zipkin-go"
+	"github.com/openzipkin/zipkin-go/middleware/capture"
 	"github.com/openzipkin/zipkin-go/model"
 	"github.com/openzipkin/zipkin-go/propagation/b3"
+	"github.com/openzipkin/zipkin-go/reporter"
 )
 
 // ErrHandler allows instrumentations to decide how to tag errors
@@ -44,20 +46,50 @@ func defaultErrHandler(sp zipkin.Span, err error, statusCode int) {
 	zipkin.TagError.Set(sp, statusCodeVal)
 }
 
+// defaultErrorClassifier classifies transport errors and status codes above
+// 399 as errors.
+var defaultErrorClassifier = zipkin.ErrorClassifierFunc(func(err error, statusCode int) zipkin.Severity {
+	if err != nil || statusCode > 399 {
+		return zipkin.SeverityError
+	}
+	return zipkin.SeverityNone
+})
+
+// recordError records the outcome of a request on sp according to its
+// severity and returns the severity. Errors are tagged by eh.
+func recordError(sp zipkin.Span, c zipkin.ErrorClassifier, eh ErrHandler, err error, statusCode int) zipkin.Severity {
+	severity := c.Classify(err, statusCode)
+	switch severity {
+	case zipkin.SeverityError:
+		eh(sp, err, statusCode)
+	case zipkin.SeverityExpected:
+		if err != nil {
+			zipkin.TagErrorExpected.Set(sp, err.Error())
+		} else {
+			zipkin.TagErrorExpected.Set(sp, strconv.Itoa(statusCode))
+		}
+	}
+	return severity
+}
+
 // ErrResponseReader allows instrumentations to read the error body
 // and decide to obtain information to it and add it to the span i.e.
 // tag the span with a more meaningful error code or with error details.
 type ErrResponseReader func(sp zipkin.Span, body io.Reader)
 
 type transport struct {
-	tracer            *zipkin.Tracer
+	tracer            zipkin.TracerInterface
 	rt                http.RoundTripper
 	httpTrace         bool
 	defaultTags       map[string]string
 	errHandler        ErrHandler
+	errClassifier     zipkin.ErrorClassifier
 	errResponseReader *ErrResponseReader
 	logger            *log.Logger
 	requestSampler    RequestSamplerFunc
+	spanNamer         func(*http.Request) string
+	traceID64Bit      bool
+	capture           *capture.Config
 }
 
 // TransportOption allows one to configure optional transport configuration.
@@ -93,6 +125,18 @@ func TransportErrHandler(h ErrHandler) TransportOption {
 	}
 }
 
+// TransportErrorClassifier sets the ErrorClassifier deciding which round trip
+// errors and response status codes are tagged as errors by the ErrHandler and
+// which are tagged as expected. By default transport errors and status codes
+// above 399 are errors.
+func TransportErrorClassifier(c zipkin.ErrorClassifier) TransportOption {
+	return func(t *transport) {
+		if c != nil {
+			t.errClassifier = c
+		}
+	}
+}
+
 // TransportErrResponseReader allows to pass a custom ErrResponseReader
 func TransportErrResponseReader(r ErrResponseReader) TransportOption {
 	return func(t *transport) {
@@ -118,19 +162,39 @@ func TransportRequestSampler(sampleFunc RequestSamplerFunc) TransportOption {
 	}
 }
 
+// SpanNameFromRequest allows one to set a function deriving the client span
+// name from the outgoing http.Request, e.g. to name spans by logical operation
+// like "GET payments:charge". If the function returns an empty string or if
+// this option is omitted, the request scheme and method are used as span name.
+func SpanNameFromRequest(namer func(*http.Request) string) TransportOption {
+	return func(t *transport) {
+		t.spanNamer = namer
+	}
+}
+
+// TransportTraceID64Bit when enabled propagates 128-bit trace ids downstream
+// as 64-bit trace ids, for legacy services which fail on 128-bit trace ids.
+// Spans with a downgraded trace id are tagged with zipkin.TagTraceIDDowngraded.
+func TransportTraceID64Bit(enabled bool) TransportOption {
+	return func(t *transport) {
+		t.traceID64Bit = enabled
+	}
+}
+
 // NewTransport returns a new Zipkin instrumented http RoundTripper which can be
 // used with a standard library http Client.
-func NewTransport(tracer *zipkin.Tracer, options ...TransportOption) (http.RoundTripper, error) {
+func NewTransport(tracer zipkin.TracerInterface, options ...TransportOption) (http.RoundTripper, error) {
 	if tracer == nil {
 		return nil, ErrValidTracerRequired
 	}
 
 	t := &transport{
-		tracer:     tracer,
-		rt:         http.DefaultTransport,
-		httpTrace:  false,
-		errHandler: defaultErrHandler,
-		logger:     log.New(os.Stderr, "", log.LstdFlags),
+		tracer:        tracer,
+		rt:            http.DefaultTransport,
+		httpTrace:     false,
+		errHandler:    defaultErrHandler,
+		errClassifier: defaultErrorClassifier,
+		logger:        log.New(os.Stderr, "", log.LstdFlags),
 	}
 
 	for _, option := range options {
@@ -142,8 +206,21 @@ func NewTransport(tracer *zipkin.Tracer, options ...TransportOption) (http.Round
 
 // RoundTrip satisfies the RoundTripper interface.
 func (t *transport) RoundTrip(req *http.Request) (res *http.Response, err error) {
+	if reporter.IsUntracedContext(req.Context()) {
+		// request was flagged to not be traced, e.g. span delivery by a reporter
+		return t.rt.RoundTrip(req)
+	}
+
+	var spanName string
+	if t.spanNamer != nil {
+		spanName = t.spanNamer(req)
+	}
+	if len(spanName) == 0 {
+		spanName = req.URL.Scheme + "/" + req.Method
+	}
+
 	sp, _ := t.tracer.StartSpanFromContext(
-		req.Context(), req.URL.Scheme+"/"+req.Method, zipkin.Kind(model.Client),
+		req.Context(), spanName, zipkin.Kind(model.Client),
 	)
 
 	for k, v := range t.defaultTags {
@@ -186,14 +263,36 @@ func (t *transport) RoundTrip(req *http.Request) (res *http.Response, err error)
 		}
 	}
 
+	if t.traceID64Bit {
+		var downgraded bool
+		if spCtx, downgraded = b3.Downgrade(spCtx); downgraded {
+			zipkin.TagTraceIDDowngraded.Set(sp, "true")
+		}
+	}
 	_ = b3.InjectHTTP(req)(spCtx)
 
+	pc := newPayloadCapture(t.capture, sp)
+	if pc != nil {
+		// do not modify the body of the caller's request
+		req = req.WithContext(req.Context())
+		pc.captureRequest(req)
+	}
+
 	res, err = t.rt.RoundTrip(req)
 	if err != nil {
-		t.errHandler(sp, err, 0)
+		recordError(sp, t.errClassifier, t.errHandler, err, 0)
+		if pc != nil {
+			pc.annotate(sp, req.Header.Get("Content-Type"), "")
+		}
 		sp.Finish()
 		return
 	}
+	if pc != nil {
+		if pc.config.Allowed(res.Header.Get("Content-Type")) {
+			pc.peekResponse(res)
+		}
+		pc.annotate(sp, req.Header.Get("Content-Type"), res.Header.Get("Content-Type"))
+	}
 
 	if res.ContentLength > 0 {
 		zipkin.TagHTTPResponseSize.Set(sp, strconv.FormatInt(res.ContentLength, 10))
@@ -201,18 +300,16 @@ func (t *transport) RoundTrip(req *http.Request) (res *http.Response, err error)
 	if res.StatusCode < 200 || res.StatusCode > 299 {
 		statusCode := strconv.FormatInt(int64(res.StatusCode), 10)
 		zipkin.TagHTTPStatusCode.Set(sp, statusCode)
-		if res.StatusCode > 399 {
-			t.errHandler(sp, nil, res.StatusCode)
-
-			if t.errResponseReader != nil {
-				sBody, err := ioutil.ReadAll(res.Body)
-				if err == nil {
-					res.Body.Close()
-					(*t.errResponseReader)(sp, ioutil.NopCloser(bytes.NewBuffer(sBody)))
-					res.Body = ioutil.NopCloser(bytes.NewBuffer(sBody))
-				} else {
-					t.logger.Printf("failed to read the response body in the ErrResponseReader: %v", err)
-				}
+	}
+	if recordError(sp, t.errClassifier, t.errHandler, nil, res.StatusCode) == zipkin.SeverityError {
+		if t.errResponseReader != nil {
+			sBody, err := ioutil.ReadAll(res.Body)
+			if err == nil {
+				res.Body.Close()
+				(*t.errResponseReader)(sp, ioutil.NopCloser(bytes.NewBuffer(sBody)))
+				res.Body = ioutil.NopCloser(bytes.NewBuffer(sBody))
+			} else {
+				t.logger.Printf("failed to read the response body in the ErrResponseReader: %v", err)
 			}
 		}
 	}
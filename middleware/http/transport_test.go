@@ -7,9 +7,15 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strings"
 	"testing"
 
 	zipkin "github.com/openzipkin/zipkin-go"
+	"github.com/openzipkin/zipkin-go/middleware/capture"
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/propagation/b3"
+	"github.com/openzipkin/zipkin-go/reporter"
 	"github.com/openzipkin/zipkin-go/reporter/recorder"
 )
 
@@ -210,3 +216,152 @@ func TestTransportRequestSamplerOverridesSamplingFromContext(t *testing.T) {
 		srv.Close()
 	}
 }
+
+func TestRoundTripUntracedContext(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		if have := r.Header.Get("x-b3-traceid"); have != "" {
+			t.Errorf("unexpected trace propagation, have %q", have)
+		}
+	}))
+	defer srv.Close()
+
+	rep := recorder.NewReporter()
+	defer rep.Close()
+
+	tracer, err := zipkin.NewTracer(rep)
+	if err != nil {
+		t.Fatalf("unexpected error when creating tracer: %v", err)
+	}
+
+	transport, _ := NewTransport(tracer)
+
+	req, _ := http.NewRequest("GET", srv.URL, nil)
+	ctx := reporter.NewUntracedContext(context.Background())
+	if _, err = transport.RoundTrip(req.WithContext(ctx)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if want, have := 0, len(rep.Flush()); want != have {
+		t.Errorf("unexpected number of spans, want %d, have %d", want, have)
+	}
+}
+
+func TestRoundTripSpanNameFromRequest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {}))
+	defer srv.Close()
+
+	rep := recorder.NewReporter()
+	defer rep.Close()
+
+	tracer, err := zipkin.NewTracer(rep)
+	if err != nil {
+		t.Fatalf("unexpected error when creating tracer: %v", err)
+	}
+
+	cases := []struct {
+		namer func(*http.Request) string
+		want  string
+	}{
+		{nil, "http/POST"},
+		{func(r *http.Request) string { return r.Method + " payments:charge" }, "POST payments:charge"},
+		{func(_ *http.Request) string { return "" }, "http/POST"},
+	}
+
+	for i, c := range cases {
+		transport, _ := NewTransport(tracer, SpanNameFromRequest(c.namer))
+
+		req, _ := http.NewRequest("POST", srv.URL, nil)
+		if _, err = transport.RoundTrip(req); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		spans := rep.Flush()
+		if want, have := 1, len(spans); want != have {
+			t.Fatalf("[%d] unexpected number of spans, want %d, have %d", i, want, have)
+		}
+		if want, have := c.want, spans[0].Name; want != have {
+			t.Errorf("[%d] unexpected span name, want %q, have %q", i, want, have)
+		}
+	}
+}
+
+func TestRoundTripTraceID64Bit(t *testing.T) {
+	var traceID string
+	srv := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		traceID = r.Header.Get(b3.TraceID)
+	}))
+	defer srv.Close()
+
+	rep := recorder.NewReporter()
+	defer rep.Close()
+
+	tracer, err := zipkin.NewTracer(rep, zipkin.WithTraceID128Bit(true))
+	if err != nil {
+		t.Fatalf("unexpected error when creating tracer: %v", err)
+	}
+
+	transport, _ := NewTransport(tracer, TransportTraceID64Bit(true))
+	req, _ := http.NewRequest("GET", srv.URL, nil)
+	if _, err = transport.RoundTrip(req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	spans := rep.Flush()
+	if want, have := 1, len(spans); want != have {
+		t.Fatalf("unexpected number of spans, want %d, have %d", want, have)
+	}
+	if spans[0].TraceID.High == 0 {
+		t.Fatal("expected 128-bit trace id")
+	}
+	if want, have := (model.TraceID{Low: spans[0].TraceID.Low}).String(), traceID; want != have {
+		t.Errorf("propagated trace id want %s, have %s", want, have)
+	}
+	if want, have := "true", spans[0].Tags[string(zipkin.TagTraceIDDowngraded)]; want != have {
+		t.Errorf("downgraded tag want %q, have %q", want, have)
+	}
+}
+
+func TestRoundTripCapture(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(append(body, `,"status":"created"}`...))
+	}))
+	defer srv.Close()
+
+	rep := recorder.NewReporter()
+	defer rep.Close()
+
+	tracer, err := zipkin.NewTracer(rep)
+	if err != nil {
+		t.Fatalf("unexpected error when creating tracer: %v", err)
+	}
+	transport, _ := NewTransport(tracer, TransportCapture(capture.Config{MaxSize: 16}))
+
+	sc := model.SpanContext{TraceID: model.TraceID{Low: 1}, ID: 1, Debug: true}
+	ctx := zipkin.NewContextFromSpanContext(context.Background(), sc)
+	req, _ := http.NewRequest("POST", srv.URL, strings.NewReader(`{"id":1`))
+	req.Header.Set("Content-Type", "application/json")
+	res, err := transport.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	body, _ := ioutil.ReadAll(res.Body)
+	_ = res.Body.Close()
+	if want, have := `{"id":1,"status":"created"}`, string(body); want != have {
+		t.Errorf("body want %q, have %q", want, have)
+	}
+
+	spans := rep.Flush()
+	if want, have := 1, len(spans); want != have {
+		t.Fatalf("unexpected number of spans, want %d, have %d", want, have)
+	}
+	var have []string
+	for _, a := range spans[0].Annotations {
+		have = append(have, a.Value)
+	}
+	want := []string{`request.payload: {"id":1`, `response.payload: {"id":1,"status"...`}
+	if !reflect.DeepEqual(want, have) {
+		t.Errorf("annotations want %q, have %q", want, have)
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package messaging
+
+import (
+	"context"
+	"strconv"
+
+	zipkin "github.com/openzipkin/zipkin-go"
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/propagation"
+)
+
+// Tags set on the spans of a batch.
+const (
+	TagBatchSize    zipkin.Tag = "messaging.batch.size"
+	TagBatchTraceID zipkin.Tag = "messaging.batch.trace_id"
+	TagBatchSpanID  zipkin.Tag = "messaging.batch.span_id"
+)
+
+// Batch holds the spans started for a batch of messages.
+type Batch struct {
+	// Span is the umbrella local span for processing the batch.
+	Span zipkin.Span
+	// Messages holds the consumer span of each message, in message order.
+	Messages []zipkin.Span
+}
+
+// Finish finishes the consumer spans and the umbrella span of the batch.
+func (b Batch) Finish() {
+	for _, span := range b.Messages {
+		span.Finish()
+	}
+	b.Span.Finish()
+}
+
+// StartConsumerSpans starts a consumer span named name for each of the
+// messages, continuing the trace found by the message's extractor, and an
+// umbrella local span named name for processing the batch. The umbrella span
+// is a child of the span found in ctx, if any, and is stored in the returned
+// context so spans created while processing the batch become its children.
+// The provided options are applied to the consumer spans, e.g. to set the
+// remote service name of the broker.
+func StartConsumerSpans(ctx context.Context, tracer zipkin.TracerInterface, name string, msgs []propagation.Extractor, options ...zipkin.SpanOption) (Batch, context.Context) {
+	span, ctx := tracer.StartSpanFromContext(ctx, name)
+	span.Tag(string(TagBatchSize), strconv.Itoa(len(msgs)))
+
+	sc := span.Context()
+	batch := Batch{Span: span, Messages: make([]zipkin.Span, 0, len(msgs))}
+	for _, extract := range msgs {
+		opts := append([]zipkin.SpanOption{
+			zipkin.Kind(model.Consumer),
+			zipkin.Parent(tracer.Extract(extract)),
+		}, options...)
+		msgSpan := tracer.StartSpan(name, opts...)
+		msgSpan.Tag(string(TagBatchTraceID), sc.TraceID.String())
+		msgSpan.Tag(string(TagBatchSpanID), sc.ID.String())
+		batch.Messages = append(batch.Messages, msgSpan)
+	}
+
+	return batch, ctx
+}
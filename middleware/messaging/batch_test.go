@@ -0,0 +1,92 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package messaging_test
+
+import (
+	"context"
+	"testing"
+
+	zipkin "github.com/openzipkin/zipkin-go"
+	"github.com/openzipkin/zipkin-go/middleware/messaging"
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/propagation"
+	"github.com/openzipkin/zipkin-go/propagation/b3"
+	"github.com/openzipkin/zipkin-go/reporter/recorder"
+)
+
+func TestStartConsumerSpans(t *testing.T) {
+	rec := recorder.NewReporter()
+	defer rec.Close()
+
+	tracer, err := zipkin.NewTracer(rec)
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+
+	producers := []model.SpanContext{
+		tracer.StartSpan("produce-1").Context(),
+		tracer.StartSpan("produce-2").Context(),
+	}
+	var msgs []propagation.Extractor
+	for _, sc := range producers {
+		headers := b3.Map{}
+		if err := headers.Inject()(sc); err != nil {
+			t.Fatalf("unexpected error: %+v", err)
+		}
+		msgs = append(msgs, headers.Extract)
+	}
+
+	batch, ctx := messaging.StartConsumerSpans(
+		context.Background(), tracer, "process", msgs, zipkin.RemoteEndpoint(&model.Endpoint{ServiceName: "kafka"}),
+	)
+	if want, have := batch.Span, zipkin.SpanFromContext(ctx); want != have {
+		t.Errorf("context span want %+v, have %+v", want, have)
+	}
+	batch.Finish()
+
+	spans := rec.Flush()
+	if want, have := 3, len(spans); want != have {
+		t.Fatalf("reported spans want %d, have %d", want, have)
+	}
+
+	umbrella := spans[2]
+	if want, have := model.Undetermined, umbrella.Kind; want != have {
+		t.Errorf("umbrella kind want %q, have %q", want, have)
+	}
+	if want, have := "2", umbrella.Tags[string(messaging.TagBatchSize)]; want != have {
+		t.Errorf("batch size want %q, have %q", want, have)
+	}
+
+	for i, span := range spans[:2] {
+		if want, have := model.Consumer, span.Kind; want != have {
+			t.Errorf("span %d kind want %q, have %q", i, want, have)
+		}
+		if want, have := producers[i].TraceID, span.TraceID; want != have {
+			t.Errorf("span %d trace id want %s, have %s", i, want, have)
+		}
+		if span.ParentID == nil || *span.ParentID != producers[i].ID {
+			t.Errorf("span %d parent id want %s, have %v", i, producers[i].ID, span.ParentID)
+		}
+		if want, have := "kafka", span.RemoteEndpoint.ServiceName; want != have {
+			t.Errorf("span %d remote service want %q, have %q", i, want, have)
+		}
+		if want, have := umbrella.TraceID.String(), span.Tags[string(messaging.TagBatchTraceID)]; want != have {
+			t.Errorf("span %d batch trace id want %s, have %s", i, want, have)
+		}
+		if want, have := umbrella.ID.String(), span.Tags[string(messaging.TagBatchSpanID)]; want != have {
+			t.Errorf("span %d batch span id want %s, have %s", i, want, have)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package messaging contains Zipkin instrumentation helpers for message consumers
+processing batches of messages from many traces, e.g. Kafka consumers polling
+records of multiple producers.
+
+StartConsumerSpans starts a consumer span for each message, continuing the
+trace of its producer, and an umbrella local span for processing the batch as
+a whole. As the Zipkin model has no span links, the consumer spans are tagged
+with the trace and span id of the umbrella span to correlate the traces.
+
+	var extractors []propagation.Extractor
+	for _, m := range msgs {
+		headers := b3.Map(headersOf(m))
+		extractors = append(extractors, headers.Extract)
+	}
+	batch, ctx := messaging.StartConsumerSpans(ctx, tracer, "process", extractors)
+	defer batch.Finish()
+*/
+package messaging
@@ -0,0 +1,32 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package reverseproxy contains a Zipkin instrumentation for httputil.ReverseProxy
+based API gateways.
+
+Every attempt to reach an upstream creates a client span named after the
+target service, tagged with the selected backend and, if the request is
+retried, the attempt number. The span context is propagated to the upstream
+using B3 headers.
+
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	handler, err := reverseproxy.Instrument(tracer, proxy)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// trace the incoming request as server span
+	handler = zipkinhttp.NewServerMiddleware(tracer)(handler)
+*/
+package reverseproxy
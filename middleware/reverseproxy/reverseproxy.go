@@ -0,0 +1,181 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reverseproxy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httputil"
+	"strconv"
+	"sync/atomic"
+
+	zipkin "github.com/openzipkin/zipkin-go"
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/propagation/b3"
+)
+
+// Tags set on upstream spans.
+const (
+	TagBackend zipkin.Tag = "proxy.backend"
+	TagAttempt zipkin.Tag = "proxy.attempt"
+	TagRetry   zipkin.Tag = "proxy.retry"
+)
+
+// Common errors
+var (
+	ErrValidTracerRequired = errors.New("valid tracer required")
+	ErrValidProxyRequired  = errors.New("valid reverse proxy required")
+)
+
+type attemptsKey struct{}
+
+type transport struct {
+	tracer      zipkin.TracerInterface
+	rt          http.RoundTripper
+	serviceName func(*http.Request) string
+	retries     func(http.RoundTripper) http.RoundTripper
+	defaultTags map[string]string
+}
+
+// Option allows one to configure the instrumentation.
+type Option func(*transport)
+
+// RoundTripper sets the RoundTripper used to reach the upstreams. Defaults to
+// the Transport of the proxy or http.DefaultTransport if not set.
+func RoundTripper(rt http.RoundTripper) Option {
+	return func(t *transport) {
+		if rt != nil {
+			t.rt = rt
+		}
+	}
+}
+
+// ServiceName sets a function deriving the target service name from the
+// outgoing request. The service name is used as remote service name and as
+// part of the span name. Defaults to the host name of the request URL.
+func ServiceName(fn func(*http.Request) string) Option {
+	return func(t *transport) {
+		if fn != nil {
+			t.serviceName = fn
+		}
+	}
+}
+
+// Retries installs a retrying RoundTripper on top of the instrumented
+// RoundTripper, so every attempt creates its own span tagged with the attempt
+// number.
+func Retries(wrap func(next http.RoundTripper) http.RoundTripper) Option {
+	return func(t *transport) {
+		t.retries = wrap
+	}
+}
+
+// Tags adds default Tags to inject into upstream spans.
+func Tags(tags map[string]string) Option {
+	return func(t *transport) {
+		t.defaultTags = tags
+	}
+}
+
+// Instrument replaces the Transport of the proxy with an instrumented one and
+// returns a handler serving the proxy which tracks the attempts made for each
+// incoming request. Upstream spans are children of the span found in the
+// request context.
+func Instrument(tracer zipkin.TracerInterface, proxy *httputil.ReverseProxy, options ...Option) (http.Handler, error) {
+	if tracer == nil {
+		return nil, ErrValidTracerRequired
+	}
+	if proxy == nil {
+		return nil, ErrValidProxyRequired
+	}
+
+	t := &transport{
+		tracer:      tracer,
+		rt:          proxy.Transport,
+		serviceName: func(r *http.Request) string { return r.URL.Hostname() },
+	}
+	if t.rt == nil {
+		t.rt = http.DefaultTransport
+	}
+
+	for _, option := range options {
+		option(t)
+	}
+
+	proxy.Transport = t
+	if t.retries != nil {
+		proxy.Transport = t.retries(t)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), attemptsKey{}, new(int32))
+		proxy.ServeHTTP(w, r.WithContext(ctx))
+	}), nil
+}
+
+// RoundTrip satisfies the RoundTripper interface.
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	service := t.serviceName(req)
+
+	name := req.Method
+	if service != "" {
+		name += " " + service
+	}
+
+	var remoteEndpoint *model.Endpoint
+	if service != "" {
+		remoteEndpoint = &model.Endpoint{ServiceName: service}
+	}
+
+	sp, _ := t.tracer.StartSpanFromContext(
+		req.Context(), name,
+		zipkin.Kind(model.Client),
+		zipkin.RemoteEndpoint(remoteEndpoint),
+	)
+
+	for k, v := range t.defaultTags {
+		sp.Tag(k, v)
+	}
+
+	zipkin.TagHTTPMethod.Set(sp, req.Method)
+	zipkin.TagHTTPPath.Set(sp, req.URL.Path)
+	TagBackend.Set(sp, req.URL.Host)
+
+	if attempts, ok := req.Context().Value(attemptsKey{}).(*int32); ok {
+		attempt := atomic.AddInt32(attempts, 1)
+		TagAttempt.Set(sp, strconv.Itoa(int(attempt)))
+		if attempt > 1 {
+			TagRetry.Set(sp, "true")
+		}
+	}
+
+	_ = b3.InjectHTTP(req)(sp.Context())
+
+	res, err := t.rt.RoundTrip(req)
+	if err != nil {
+		zipkin.TagError.Set(sp, err.Error())
+		sp.Finish()
+		return nil, err
+	}
+
+	zipkin.TagHTTPStatusCode.Set(sp, strconv.Itoa(res.StatusCode))
+	if res.StatusCode > 399 {
+		zipkin.TagError.Set(sp, strconv.Itoa(res.StatusCode))
+	}
+	sp.Finish()
+
+	return res, nil
+}
@@ -0,0 +1,147 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reverseproxy_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"testing"
+
+	zipkin "github.com/openzipkin/zipkin-go"
+	mw "github.com/openzipkin/zipkin-go/middleware/http"
+	"github.com/openzipkin/zipkin-go/middleware/reverseproxy"
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/propagation/b3"
+	"github.com/openzipkin/zipkin-go/reporter/recorder"
+)
+
+// failover retries requests answered with 502 on the next backend.
+type failover struct {
+	next     http.RoundTripper
+	backends []string
+}
+
+func (f failover) RoundTrip(req *http.Request) (res *http.Response, err error) {
+	for _, backend := range f.backends {
+		req.URL.Host = backend
+		if res, err = f.next.RoundTrip(req); err == nil && res.StatusCode != http.StatusBadGateway {
+			return res, nil
+		}
+		if err == nil && backend != f.backends[len(f.backends)-1] {
+			_ = res.Body.Close()
+		}
+	}
+	return res, err
+}
+
+func TestReverseProxy(t *testing.T) {
+	var (
+		spanRecorder = &recorder.ReporterRecorder{}
+		tr, _        = zipkin.NewTracer(spanRecorder)
+		spanIDs      []string
+	)
+
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		spanIDs = append(spanIDs, r.Header.Get(b3.SpanID))
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer broken.Close()
+
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		spanIDs = append(spanIDs, r.Header.Get(b3.SpanID))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer healthy.Close()
+
+	brokenURL, _ := url.Parse(broken.URL)
+	healthyURL, _ := url.Parse(healthy.URL)
+
+	proxy := httputil.NewSingleHostReverseProxy(brokenURL)
+	handler, err := reverseproxy.Instrument(
+		tr, proxy,
+		reverseproxy.ServiceName(func(*http.Request) string { return "orders" }),
+		reverseproxy.Retries(func(next http.RoundTripper) http.RoundTripper {
+			return failover{next: next, backends: []string{brokenURL.Host, healthyURL.Host}}
+		}),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	handler = mw.NewServerMiddleware(tr)(handler)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/orders/1", nil))
+
+	if want, have := http.StatusOK, rec.Code; want != have {
+		t.Fatalf("status code want %d, have %d", want, have)
+	}
+
+	spans := spanRecorder.Flush()
+	if want, have := 3, len(spans); want != have {
+		t.Fatalf("spans want %d, have %d", want, have)
+	}
+
+	server := spans[2]
+	for i, backend := range []string{brokenURL.Host, healthyURL.Host} {
+		sp := spans[i]
+		if want, have := model.Client, sp.Kind; want != have {
+			t.Errorf("kind want %s, have %s", want, have)
+		}
+		if want, have := "GET orders", sp.Name; want != have {
+			t.Errorf("name want %q, have %q", want, have)
+		}
+		if want, have := "orders", sp.RemoteEndpoint.ServiceName; want != have {
+			t.Errorf("remote service want %q, have %q", want, have)
+		}
+		if sp.ParentID == nil || *sp.ParentID != server.ID {
+			t.Errorf("expected upstream span to be a child of the server span")
+		}
+		if want, have := backend, sp.Tags[string(reverseproxy.TagBackend)]; want != have {
+			t.Errorf("backend want %q, have %q", want, have)
+		}
+		if want, have := sp.ID.String(), spanIDs[i]; want != have {
+			t.Errorf("propagated span id want %s, have %s", want, have)
+		}
+	}
+
+	if want, have := "1", spans[0].Tags[string(reverseproxy.TagAttempt)]; want != have {
+		t.Errorf("attempt want %q, have %q", want, have)
+	}
+	if _, ok := spans[0].Tags[string(reverseproxy.TagRetry)]; ok {
+		t.Errorf("expected first attempt not to be tagged as retry")
+	}
+	if want, have := "502", spans[0].Tags[string(zipkin.TagError)]; want != have {
+		t.Errorf("error want %q, have %q", want, have)
+	}
+	if want, have := "2", spans[1].Tags[string(reverseproxy.TagAttempt)]; want != have {
+		t.Errorf("attempt want %q, have %q", want, have)
+	}
+	if want, have := "true", spans[1].Tags[string(reverseproxy.TagRetry)]; want != have {
+		t.Errorf("retry want %q, have %q", want, have)
+	}
+}
+
+func TestInstrumentValidation(t *testing.T) {
+	tr, _ := zipkin.NewTracer(nil)
+
+	if _, err := reverseproxy.Instrument(nil, &httputil.ReverseProxy{}); err != reverseproxy.ErrValidTracerRequired {
+		t.Errorf("want %v, have %v", reverseproxy.ErrValidTracerRequired, err)
+	}
+	if _, err := reverseproxy.Instrument(tr, nil); err != reverseproxy.ErrValidProxyRequired {
+		t.Errorf("want %v, have %v", reverseproxy.ErrValidProxyRequired, err)
+	}
+}
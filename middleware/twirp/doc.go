@@ -0,0 +1,27 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package twirp contains middlewares for instrumenting Twirp
+(https://twitchtv.github.io/twirp) servers and clients with Zipkin.
+
+Twirp runs on plain HTTP, so the middlewares wrap the http.Handler of the
+generated server and the http.RoundTripper of the http.Client handed to the
+generated client constructors. Spans are named "package.Service/Method" and
+Twirp error codes are tagged on failed calls.
+
+For servers the hooks subpackage, a separate module, provides twirp.ServerHooks
+taking the error codes directly from Twirp instead of the error responses.
+*/
+package twirp
@@ -0,0 +1,11 @@
+module github.com/openzipkin/zipkin-go/middleware/twirp/hooks
+
+require (
+	github.com/openzipkin/zipkin-go v0.1.6
+	github.com/pkg/errors v0.9.1 // indirect
+	github.com/twitchtv/twirp v8.1.3+incompatible
+)
+
+replace github.com/openzipkin/zipkin-go => ../../..
+
+go 1.12
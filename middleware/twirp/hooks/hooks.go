@@ -0,0 +1,143 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package hooks implements Twirp (https://twitchtv.github.io/twirp) server hooks
+instrumenting Twirp servers with Zipkin. Spans are named after the Twirp
+method as package.Service/Method and failed requests are tagged with their
+Twirp error code.
+
+Twirp hooks have no access to the incoming request headers, so the generated
+server must be wrapped with ExtractHTTP to propagate upstream span context:
+
+	tracer, _ := zipkin.NewTracer(reporter)
+	server := haberdasher.NewHaberdasherServer(impl, hooks.NewServerHooks(tracer))
+	http.ListenAndServe(":8080", hooks.ExtractHTTP(tracer, server))
+
+The package is a separate module to avoid adding the Twirp dependency to all
+users of zipkin-go. For instrumentation at the HTTP level without the
+dependency see the parent twirp package.
+*/
+package hooks
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/twitchtv/twirp"
+
+	zipkin "github.com/openzipkin/zipkin-go"
+	zipkintwirp "github.com/openzipkin/zipkin-go/middleware/twirp"
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/propagation/b3"
+)
+
+// context keys of the upstream span context, remote endpoint and server span
+type (
+	ctxKey      struct{}
+	endpointKey struct{}
+	spanKey     struct{}
+)
+
+type hooks struct {
+	tracer      zipkin.TracerInterface
+	defaultTags map[string]string
+}
+
+// Option allows the server hooks to be optionally configured.
+type Option func(*hooks)
+
+// ServerTags adds default Tags to inject into server spans.
+func ServerTags(tags map[string]string) Option {
+	return func(h *hooks) {
+		h.defaultTags = tags
+	}
+}
+
+// ExtractHTTP returns a http.Handler extracting the upstream span context of
+// incoming requests for the server hooks before calling next.
+func ExtractHTTP(tracer zipkin.TracerInterface, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sc := tracer.Extract(b3.ExtractHTTP(r))
+		ctx := context.WithValue(r.Context(), ctxKey{}, sc)
+		if remoteEndpoint, err := zipkin.NewEndpoint("", r.RemoteAddr); err == nil {
+			ctx = context.WithValue(ctx, endpointKey{}, remoteEndpoint)
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// NewServerHooks returns Twirp server hooks creating a server span for every
+// routed request.
+func NewServerHooks(tracer zipkin.TracerInterface, options ...Option) *twirp.ServerHooks {
+	h := &hooks{tracer: tracer}
+	for _, option := range options {
+		option(h)
+	}
+
+	return &twirp.ServerHooks{
+		RequestRouted: h.requestRouted,
+		Error:         h.error,
+		ResponseSent:  h.responseSent,
+	}
+}
+
+func (h *hooks) requestRouted(ctx context.Context) (context.Context, error) {
+	pkg, _ := twirp.PackageName(ctx)
+	service, _ := twirp.ServiceName(ctx)
+	method, _ := twirp.MethodName(ctx)
+	name := service + "/" + method
+	if pkg != "" {
+		name = pkg + "." + name
+	}
+
+	sc, _ := ctx.Value(ctxKey{}).(model.SpanContext)
+	options := []zipkin.SpanOption{zipkin.Kind(model.Server), zipkin.Parent(sc)}
+	if remoteEndpoint, ok := ctx.Value(endpointKey{}).(*model.Endpoint); ok {
+		options = append(options, zipkin.RemoteEndpoint(remoteEndpoint))
+	}
+
+	sp := h.tracer.StartSpan(name, options...)
+	for k, v := range h.defaultTags {
+		sp.Tag(k, v)
+	}
+	return context.WithValue(zipkin.NewContext(ctx, sp), spanKey{}, sp), nil
+}
+
+// serverSpan returns the span created by requestRouted. Spans found in ctx by
+// zipkin.SpanFromContext may be started by outer middleware, e.g. if routing
+// failed, and are not to be tagged or finished by the hooks.
+func serverSpan(ctx context.Context) zipkin.Span {
+	sp, _ := ctx.Value(spanKey{}).(zipkin.Span)
+	return sp
+}
+
+func (h *hooks) error(ctx context.Context, err twirp.Error) context.Context {
+	// errors before routing, e.g. bad routes, have no span
+	if sp := serverSpan(ctx); sp != nil {
+		code := string(err.Code())
+		zipkintwirp.TagErrorCode.Set(sp, code)
+		zipkin.TagError.Set(sp, code)
+	}
+	return ctx
+}
+
+func (h *hooks) responseSent(ctx context.Context) {
+	if sp := serverSpan(ctx); sp != nil {
+		if status, ok := twirp.StatusCode(ctx); ok && status != "200" {
+			zipkin.TagHTTPStatusCode.Set(sp, status)
+		}
+		sp.Finish()
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hooks_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/twitchtv/twirp"
+	"github.com/twitchtv/twirp/ctxsetters"
+
+	zipkin "github.com/openzipkin/zipkin-go"
+	zipkintwirp "github.com/openzipkin/zipkin-go/middleware/twirp"
+	"github.com/openzipkin/zipkin-go/middleware/twirp/hooks"
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/propagation/b3"
+	"github.com/openzipkin/zipkin-go/reporter/recorder"
+)
+
+func TestServerHooks(t *testing.T) {
+	rec := recorder.NewReporter()
+	tr, _ := zipkin.NewTracer(rec)
+	h := hooks.NewServerHooks(tr, hooks.ServerTags(map[string]string{"env": "test"}))
+
+	var ctx context.Context
+	handler := hooks.ExtractHTTP(tr, http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		ctx = r.Context()
+	}))
+	req := httptest.NewRequest("POST", "/twirp/acme.Haberdasher/MakeHat", nil)
+	req.Header.Set(b3.TraceID, "000000000000007b")
+	req.Header.Set(b3.SpanID, "00000000000001c8")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	ctx = ctxsetters.WithPackageName(ctx, "acme")
+	ctx = ctxsetters.WithServiceName(ctx, "Haberdasher")
+	ctx = ctxsetters.WithMethodName(ctx, "MakeHat")
+
+	ctx, err := h.RequestRouted(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ctx = h.Error(ctx, twirp.NotFoundError("no hats"))
+	ctx = ctxsetters.WithStatusCode(ctx, http.StatusNotFound)
+	h.ResponseSent(ctx)
+
+	spans := rec.Flush()
+	if want, have := 1, len(spans); want != have {
+		t.Fatalf("spans want %d, have %d", want, have)
+	}
+	span := spans[0]
+	if want, have := "acme.Haberdasher/MakeHat", span.Name; want != have {
+		t.Errorf("span name want %q, have %q", want, have)
+	}
+	if want, have := model.Server, span.Kind; want != have {
+		t.Errorf("span kind want %q, have %q", want, have)
+	}
+	if want, have := (model.TraceID{Low: 123}), span.TraceID; want != have {
+		t.Errorf("trace id want %s, have %s", want, have)
+	}
+	for key, want := range map[string]string{
+		string(zipkintwirp.TagErrorCode): "not_found",
+		string(zipkin.TagError):          "not_found",
+		string(zipkin.TagHTTPStatusCode): "404",
+		"env":                            "test",
+	} {
+		if have := span.Tags[key]; want != have {
+			t.Errorf("tag %q want %q, have %q", key, want, have)
+		}
+	}
+}
+
+func TestServerHooksRoutingError(t *testing.T) {
+	rec := recorder.NewReporter()
+	tr, _ := zipkin.NewTracer(rec)
+	h := hooks.NewServerHooks(tr)
+
+	// span of an outer http middleware
+	outer := tr.StartSpan("outer")
+	ctx := zipkin.NewContext(context.Background(), outer)
+
+	// bad routes fail before RequestRouted
+	ctx = h.Error(ctx, twirp.NewError(twirp.BadRoute, "no such method"))
+	ctx = ctxsetters.WithStatusCode(ctx, http.StatusNotFound)
+	h.ResponseSent(ctx)
+
+	if want, have := 0, len(rec.Flush()); want != have {
+		t.Fatalf("spans want %d, have %d", want, have)
+	}
+
+	outer.Finish()
+	spans := rec.Flush()
+	if want, have := 1, len(spans); want != have {
+		t.Fatalf("spans want %d, have %d", want, have)
+	}
+	if want, have := 0, len(spans[0].Tags); want != have {
+		t.Errorf("outer span tags want %d, have %+v", want, spans[0].Tags)
+	}
+}
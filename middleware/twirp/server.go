@@ -0,0 +1,104 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package twirp
+
+import (
+	"net/http"
+	"strconv"
+
+	zipkin "github.com/openzipkin/zipkin-go"
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/propagation/b3"
+)
+
+type handler struct {
+	tracer      zipkin.TracerInterface
+	next        http.Handler
+	defaultTags map[string]string
+}
+
+// ServerOption allows Middleware to be optionally configured.
+type ServerOption func(*handler)
+
+// ServerTags adds default Tags to inject into server spans.
+func ServerTags(tags map[string]string) ServerOption {
+	return func(h *handler) {
+		h.defaultTags = tags
+	}
+}
+
+// NewServerMiddleware returns a http.Handler middleware with Zipkin tracing
+// for Twirp servers.
+func NewServerMiddleware(t zipkin.TracerInterface, options ...ServerOption) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		h := &handler{
+			tracer: t,
+			next:   next,
+		}
+		for _, option := range options {
+			option(h)
+		}
+		return h
+	}
+}
+
+// ServeHTTP implements http.Handler.
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	sc := h.tracer.Extract(b3.ExtractHTTP(r))
+
+	remoteEndpoint, _ := zipkin.NewEndpoint("", r.RemoteAddr)
+
+	sp := h.tracer.StartSpan(
+		spanName(r.URL.Path),
+		zipkin.Kind(model.Server),
+		zipkin.Parent(sc),
+		zipkin.RemoteEndpoint(remoteEndpoint),
+	)
+
+	for k, v := range h.defaultTags {
+		sp.Tag(k, v)
+	}
+
+	rw := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+
+	defer func() {
+		if rw.statusCode != http.StatusOK {
+			zipkin.TagHTTPStatusCode.Set(sp, strconv.Itoa(rw.statusCode))
+			tagError(sp, rw.statusCode, rw.errBody)
+		}
+		sp.Finish()
+	}()
+
+	h.next.ServeHTTP(rw, r.WithContext(zipkin.NewContext(r.Context(), sp)))
+}
+
+// responseWriter intercepts the response to find the Twirp error code.
+type responseWriter struct {
+	http.ResponseWriter
+	statusCode int
+	errBody    []byte
+}
+
+func (w *responseWriter) WriteHeader(statusCode int) {
+	w.statusCode = statusCode
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.statusCode != http.StatusOK && len(w.errBody)+len(b) <= maxErrorPayload {
+		w.errBody = append(w.errBody, b...)
+	}
+	return w.ResponseWriter.Write(b)
+}
@@ -0,0 +1,85 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package twirp_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	zipkin "github.com/openzipkin/zipkin-go"
+	"github.com/openzipkin/zipkin-go/middleware/twirp"
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/propagation/b3"
+	"github.com/openzipkin/zipkin-go/reporter/recorder"
+)
+
+const method = "/twirp/acme.haberdasher.Haberdasher/MakeHat"
+
+// twirpHandler mimics the responses of a generated Twirp server.
+var twirpHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("fail") != "" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"code":"not_found","msg":"no hat in size 0"}`))
+		return
+	}
+	w.Header().Set("Content-Type", "application/protobuf")
+	_, _ = w.Write([]byte("hat"))
+})
+
+func TestServerMiddleware(t *testing.T) {
+	for _, fail := range []bool{false, true} {
+		rec := recorder.NewReporter()
+		tr, _ := zipkin.NewTracer(rec, zipkin.WithSharedSpans(false))
+		handler := twirp.NewServerMiddleware(tr)(twirpHandler)
+
+		url := method
+		if fail {
+			url += "?fail=1"
+		}
+		req := httptest.NewRequest("POST", url, nil)
+		req.Header.Set(b3.Context, "000000000000007b00000000000001c8-0000000000000315-1")
+
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		spans := rec.Flush()
+		if want, have := 1, len(spans); want != have {
+			t.Fatalf("unexpected number of spans, want %d, have %d", want, have)
+		}
+
+		span := spans[0]
+		if want, have := "acme.haberdasher.Haberdasher/MakeHat", span.Name; want != have {
+			t.Errorf("unexpected span name, want %q, have %q", want, have)
+		}
+		if want, have := model.Server, span.Kind; want != have {
+			t.Errorf("unexpected span kind, want %q, have %q", want, have)
+		}
+		if span.ParentID == nil || *span.ParentID != model.ID(789) {
+			t.Errorf("unexpected parent id, want %d, have %v", 789, span.ParentID)
+		}
+
+		var wantCode string
+		if fail {
+			wantCode = "not_found"
+		}
+		if want, have := wantCode, span.Tags[string(twirp.TagErrorCode)]; want != have {
+			t.Errorf("unexpected error code, want %q, have %q", want, have)
+		}
+		if want, have := wantCode, span.Tags[string(zipkin.TagError)]; want != have {
+			t.Errorf("unexpected error tag, want %q, have %q", want, have)
+		}
+	}
+}
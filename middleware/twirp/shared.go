@@ -0,0 +1,109 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package twirp
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	zipkin "github.com/openzipkin/zipkin-go"
+)
+
+// TagErrorCode holds the Twirp error code of a failed call.
+const TagErrorCode zipkin.Tag = "twirp.error_code"
+
+// maxErrorPayload caps the amount of bytes buffered to find the error code in
+// a Twirp error response.
+const maxErrorPayload = 64 * 1024
+
+// spanName returns the span name for the method found in the URL path, e.g.
+// "/twirp/acme.haberdasher.Haberdasher/MakeHat" yields
+// "acme.haberdasher.Haberdasher/MakeHat".
+func spanName(path string) string {
+	idx := strings.LastIndexByte(path, '/')
+	if idx <= 0 {
+		return strings.TrimPrefix(path, "/")
+	}
+	if start := strings.LastIndexByte(path[:idx], '/'); start >= 0 {
+		return path[start+1:]
+	}
+	return path
+}
+
+// codeFromError returns the code found in a Twirp error response body.
+func codeFromError(body []byte) string {
+	var e struct {
+		Code string `json:"code"`
+	}
+	if err := json.Unmarshal(body, &e); err != nil {
+		return ""
+	}
+	return e.Code
+}
+
+// codeFromHTTPStatus returns the Twirp error code for the HTTP status of a
+// failed response which does not hold a Twirp error, e.g. returned by a proxy.
+func codeFromHTTPStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "internal"
+	case http.StatusUnauthorized:
+		return "unauthenticated"
+	case http.StatusForbidden:
+		return "permission_denied"
+	case http.StatusNotFound:
+		return "bad_route"
+	case http.StatusTooManyRequests, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return "unavailable"
+	default:
+		return "unknown"
+	}
+}
+
+// tagError tags a failed call with its Twirp error code.
+func tagError(sp zipkin.Span, status int, body []byte) {
+	code := codeFromError(body)
+	if code == "" {
+		code = codeFromHTTPStatus(status)
+	}
+	TagErrorCode.Set(sp, code)
+	zipkin.TagError.Set(sp, code)
+}
+
+// replayBody returns a response body holding the consumed part of body
+// followed by the remainder of the original body, or the read error if
+// consuming failed.
+func replayBody(consumed []byte, err error, body io.ReadCloser) io.ReadCloser {
+	var rest io.Reader = body
+	if err != nil {
+		rest = errReader{err}
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(consumed), rest), body}
+}
+
+type errReader struct {
+	err error
+}
+
+func (e errReader) Read([]byte) (int, error) {
+	return 0, e.err
+}
@@ -0,0 +1,130 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package twirp
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	zipkin "github.com/openzipkin/zipkin-go"
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/propagation/b3"
+	"github.com/openzipkin/zipkin-go/reporter"
+)
+
+// ErrValidTracerRequired error
+var ErrValidTracerRequired = errors.New("valid tracer required")
+
+type transport struct {
+	tracer            zipkin.TracerInterface
+	rt                http.RoundTripper
+	defaultTags       map[string]string
+	remoteServiceName string
+}
+
+// TransportOption allows one to configure optional transport configuration.
+type TransportOption func(*transport)
+
+// RoundTripper adds the Transport RoundTripper to wrap.
+func RoundTripper(rt http.RoundTripper) TransportOption {
+	return func(t *transport) {
+		if rt != nil {
+			t.rt = rt
+		}
+	}
+}
+
+// TransportTags adds default Tags to inject into client spans.
+func TransportTags(tags map[string]string) TransportOption {
+	return func(t *transport) {
+		t.defaultTags = tags
+	}
+}
+
+// WithRemoteServiceName will set the value for the remote endpoint's service
+// name on all client spans.
+func WithRemoteServiceName(name string) TransportOption {
+	return func(t *transport) {
+		t.remoteServiceName = name
+	}
+}
+
+// NewTransport returns a new Zipkin instrumented http RoundTripper to use with
+// the http.Client passed to Twirp client constructors.
+func NewTransport(tracer zipkin.TracerInterface, options ...TransportOption) (http.RoundTripper, error) {
+	if tracer == nil {
+		return nil, ErrValidTracerRequired
+	}
+
+	t := &transport{
+		tracer: tracer,
+		rt:     http.DefaultTransport,
+	}
+	for _, option := range options {
+		option(t)
+	}
+
+	return t, nil
+}
+
+// RoundTrip satisfies the RoundTripper interface.
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if reporter.IsUntracedContext(req.Context()) {
+		// request was flagged to not be traced, e.g. span delivery by a reporter
+		return t.rt.RoundTrip(req)
+	}
+
+	sp, _ := t.tracer.StartSpanFromContext(
+		req.Context(), spanName(req.URL.Path), zipkin.Kind(model.Client),
+	)
+	defer sp.Finish()
+
+	if t.remoteServiceName != "" {
+		sp.SetRemoteEndpoint(&model.Endpoint{ServiceName: t.remoteServiceName})
+	}
+
+	for k, v := range t.defaultTags {
+		sp.Tag(k, v)
+	}
+
+	// RoundTrippers should not modify the original request
+	r := new(http.Request)
+	*r = *req
+	r.Header = make(http.Header, len(req.Header)+4)
+	for k, v := range req.Header {
+		r.Header[k] = v
+	}
+
+	_ = b3.InjectHTTP(r)(sp.Context())
+
+	res, err := t.rt.RoundTrip(r)
+	if err != nil {
+		zipkin.TagError.Set(sp, err.Error())
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		zipkin.TagHTTPStatusCode.Set(sp, strconv.Itoa(res.StatusCode))
+		body, err := ioutil.ReadAll(io.LimitReader(res.Body, maxErrorPayload))
+		// hand the consumed part of the body back to the Twirp client
+		res.Body = replayBody(body, err, res.Body)
+		tagError(sp, res.StatusCode, body)
+	}
+
+	return res, nil
+}
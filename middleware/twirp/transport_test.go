@@ -0,0 +1,164 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package twirp_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	zipkin "github.com/openzipkin/zipkin-go"
+	"github.com/openzipkin/zipkin-go/middleware/twirp"
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/propagation/b3"
+	"github.com/openzipkin/zipkin-go/reporter"
+	"github.com/openzipkin/zipkin-go/reporter/recorder"
+)
+
+func TestTransport(t *testing.T) {
+	var traceID string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceID = r.Header.Get(b3.TraceID)
+		twirpHandler(w, r)
+	}))
+	defer srv.Close()
+
+	for _, fail := range []bool{false, true} {
+		rec := recorder.NewReporter()
+		tr, _ := zipkin.NewTracer(rec)
+		transport, err := twirp.NewTransport(tr, twirp.WithRemoteServiceName("haberdasher"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		client := &http.Client{Transport: transport}
+
+		url := srv.URL + method
+		if fail {
+			url += "?fail=1"
+		}
+		res, err := client.Post(url, "application/protobuf", strings.NewReader("size"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		body, _ := ioutil.ReadAll(res.Body)
+		_ = res.Body.Close()
+
+		if fail && !strings.Contains(string(body), "not_found") {
+			t.Errorf("error body must still be readable, have %q", body)
+		}
+
+		spans := rec.Flush()
+		if want, have := 1, len(spans); want != have {
+			t.Fatalf("unexpected number of spans, want %d, have %d", want, have)
+		}
+
+		span := spans[0]
+		if want, have := span.TraceID.String(), traceID; want != have {
+			t.Errorf("unexpected propagated trace id, want %q, have %q", want, have)
+		}
+		if want, have := "acme.haberdasher.Haberdasher/MakeHat", span.Name; want != have {
+			t.Errorf("unexpected span name, want %q, have %q", want, have)
+		}
+		if want, have := model.Client, span.Kind; want != have {
+			t.Errorf("unexpected span kind, want %q, have %q", want, have)
+		}
+		if want, have := "haberdasher", span.RemoteEndpoint.ServiceName; want != have {
+			t.Errorf("unexpected remote service name, want %q, have %q", want, have)
+		}
+
+		var wantCode string
+		if fail {
+			wantCode = "not_found"
+		}
+		if want, have := wantCode, span.Tags[string(twirp.TagErrorCode)]; want != have {
+			t.Errorf("unexpected error code, want %q, have %q", want, have)
+		}
+	}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+type brokenBody struct {
+	data []byte
+	err  error
+}
+
+func (b *brokenBody) Read(p []byte) (int, error) {
+	if len(b.data) == 0 {
+		return 0, b.err
+	}
+	n := copy(p, b.data)
+	b.data = b.data[n:]
+	return n, nil
+}
+
+func (b *brokenBody) Close() error { return nil }
+
+func TestTransportBrokenErrorBody(t *testing.T) {
+	failure := errors.New("connection reset")
+	rt := roundTripperFunc(func(*http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusNotFound,
+			Header:     http.Header{},
+			Body:       &brokenBody{data: []byte(`{"code":`), err: failure},
+		}, nil
+	})
+
+	rec := recorder.NewReporter()
+	tr, _ := zipkin.NewTracer(rec)
+	transport, _ := twirp.NewTransport(tr, twirp.RoundTripper(rt))
+
+	req, _ := http.NewRequest("POST", "http://localhost/twirp/acme.Haberdasher/MakeHat", nil)
+	res, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// the consumed part of the body is handed back along with the read error
+	body, err := ioutil.ReadAll(res.Body)
+	if want, have := `{"code":`, string(body); want != have {
+		t.Errorf("body want %q, have %q", want, have)
+	}
+	if want, have := failure, err; want != have {
+		t.Errorf("read error want %v, have %v", want, have)
+	}
+	if want, have := "bad_route", rec.Flush()[0].Tags[string(twirp.TagErrorCode)]; want != have {
+		t.Errorf("error code want %q, have %q", want, have)
+	}
+}
+
+func TestTransportUntraced(t *testing.T) {
+	rec := recorder.NewReporter()
+	tr, _ := zipkin.NewTracer(rec)
+	transport, _ := twirp.NewTransport(tr, twirp.RoundTripper(roundTripperFunc(
+		func(*http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+		},
+	)))
+
+	req, _ := http.NewRequest("POST", "http://localhost/twirp/acme.Haberdasher/MakeHat", nil)
+	req = req.WithContext(reporter.NewUntracedContext(req.Context()))
+	if _, err := transport.RoundTrip(req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if spans := rec.Flush(); len(spans) != 0 {
+		t.Errorf("expected untraced request to not create spans, have %d", len(spans))
+	}
+}
@@ -35,6 +35,10 @@ type SpanContext struct {
 	Debug    bool    `json:"debug,omitempty"`
 	Sampled  *bool   `json:"-"`
 	Err      error   `json:"-"`
+	// Remote is set by the tracer for SpanContexts extracted from incoming
+	// requests. Like Err it is not part of the Zipkin model, but it needs to
+	// travel with the SpanContext from extraction to the Parent span option.
+	Remote bool `json:"-"`
 }
 
 // SpanModel structure.
@@ -0,0 +1,172 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidSpanContext is returned by ParseSpanContext if the provided value
+// is neither a valid B3 single header nor a W3C traceparent value.
+var ErrInvalidSpanContext = errors.New("invalid span context")
+
+// IsValid reports whether the SpanContext holds a non-zero trace and span id
+// and no extraction error.
+func (sc SpanContext) IsValid() bool {
+	return !sc.TraceID.Empty() && sc.ID != 0 && sc.Err == nil
+}
+
+// IsRemote reports whether the SpanContext was extracted from an incoming
+// request as opposed to being created locally.
+func (sc SpanContext) IsRemote() bool {
+	return sc.Remote
+}
+
+// Equal reports whether both SpanContexts identify the same span with the same
+// parent and sampling state. Extraction errors and the remote flag are
+// ignored.
+func (sc SpanContext) Equal(other SpanContext) bool {
+	if sc.TraceID != other.TraceID || sc.ID != other.ID || sc.Debug != other.Debug {
+		return false
+	}
+	if (sc.ParentID == nil) != (other.ParentID == nil) ||
+		(sc.ParentID != nil && *sc.ParentID != *other.ParentID) {
+		return false
+	}
+	if (sc.Sampled == nil) != (other.Sampled == nil) ||
+		(sc.Sampled != nil && *sc.Sampled != *other.Sampled) {
+		return false
+	}
+	return true
+}
+
+// B3String returns the SpanContext in B3 single header format:
+// {TraceId}-{SpanId}-{SamplingState}-{ParentSpanId}, where the sampling state
+// and parent span id are omitted if not set. The method is not named String as
+// it would be promoted to SpanModel and replace its default formatting.
+func (sc SpanContext) B3String() string {
+	s := sc.TraceID.String() + "-" + sc.ID.String()
+	switch {
+	case sc.Debug:
+		s += "-d"
+	case sc.Sampled != nil && *sc.Sampled:
+		s += "-1"
+	case sc.Sampled != nil:
+		s += "-0"
+	default:
+		if sc.ParentID != nil {
+			// the parent span id can not be expressed without sampling state
+			return s
+		}
+	}
+	if sc.ParentID != nil {
+		s += "-" + sc.ParentID.String()
+	}
+	return s
+}
+
+// W3CString returns the SpanContext in W3C traceparent format:
+// 00-{TraceId}-{SpanId}-{Flags}. As traceparent can not express a deferred
+// sampling decision, a SpanContext without sampling decision is formatted as
+// not sampled. The debug flag is formatted as sampled.
+func (sc SpanContext) W3CString() string {
+	flags := "00"
+	if sc.Debug || (sc.Sampled != nil && *sc.Sampled) {
+		flags = "01"
+	}
+	return fmt.Sprintf(
+		"00-%016x%016x-%016x-%s", sc.TraceID.High, sc.TraceID.Low, uint64(sc.ID), flags,
+	)
+}
+
+// ParseSpanContext parses a SpanContext formatted by B3String or W3CString, e.g.
+// from log lines or test fixtures. The format is detected automatically.
+func ParseSpanContext(s string) (SpanContext, error) {
+	parts := strings.Split(s, "-")
+	if len(parts[0]) == 2 {
+		return parseTraceParent(parts)
+	}
+	return parseB3(parts)
+}
+
+func parseB3(parts []string) (sc SpanContext, err error) {
+	if len(parts) < 2 || len(parts) > 4 {
+		return sc, ErrInvalidSpanContext
+	}
+	if l := len(parts[0]); l != 16 && l != 32 {
+		return sc, ErrInvalidSpanContext
+	}
+	if sc.TraceID, err = TraceIDFromHex(parts[0]); err != nil || sc.TraceID.Empty() {
+		return SpanContext{}, ErrInvalidSpanContext
+	}
+	if sc.ID, err = parseID(parts[1]); err != nil {
+		return SpanContext{}, ErrInvalidSpanContext
+	}
+	if len(parts) > 2 {
+		switch parts[2] {
+		case "d":
+			sc.Debug = true
+		case "1", "0":
+			sampled := parts[2] == "1"
+			sc.Sampled = &sampled
+		default:
+			return SpanContext{}, ErrInvalidSpanContext
+		}
+	}
+	if len(parts) > 3 {
+		parentID, err := parseID(parts[3])
+		if err != nil {
+			return SpanContext{}, ErrInvalidSpanContext
+		}
+		sc.ParentID = &parentID
+	}
+	return sc, nil
+}
+
+func parseTraceParent(parts []string) (sc SpanContext, err error) {
+	if len(parts) != 4 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[3]) != 2 {
+		return sc, ErrInvalidSpanContext
+	}
+	if _, err = strconv.ParseUint(parts[0], 16, 8); err != nil {
+		return sc, ErrInvalidSpanContext
+	}
+	if sc.TraceID, err = TraceIDFromHex(parts[1]); err != nil || sc.TraceID.Empty() {
+		return SpanContext{}, ErrInvalidSpanContext
+	}
+	if sc.ID, err = parseID(parts[2]); err != nil {
+		return SpanContext{}, ErrInvalidSpanContext
+	}
+	flags, err := strconv.ParseUint(parts[3], 16, 8)
+	if err != nil {
+		return SpanContext{}, ErrInvalidSpanContext
+	}
+	sampled := flags&0x01 == 0x01
+	sc.Sampled = &sampled
+	return sc, nil
+}
+
+func parseID(s string) (ID, error) {
+	if len(s) != 16 {
+		return 0, ErrInvalidSpanContext
+	}
+	id, err := strconv.ParseUint(s, 16, 64)
+	if err != nil || id == 0 {
+		return 0, ErrInvalidSpanContext
+	}
+	return ID(id), nil
+}
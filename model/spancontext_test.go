@@ -0,0 +1,141 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSpanContextFormatting(t *testing.T) {
+	var (
+		sampled  = true
+		parentID = ID(3)
+	)
+
+	tests := []struct {
+		sc  SpanContext
+		b3  string
+		w3c string
+	}{
+		{
+			SpanContext{TraceID: TraceID{Low: 1}, ID: 2},
+			"0000000000000001-0000000000000002",
+			"00-00000000000000000000000000000001-0000000000000002-00",
+		},
+		{
+			SpanContext{TraceID: TraceID{High: 1, Low: 1}, ID: 2, Sampled: &sampled, ParentID: &parentID},
+			"00000000000000010000000000000001-0000000000000002-1-0000000000000003",
+			"00-00000000000000010000000000000001-0000000000000002-01",
+		},
+		{
+			SpanContext{TraceID: TraceID{Low: 1}, ID: 2, Debug: true},
+			"0000000000000001-0000000000000002-d",
+			"00-00000000000000000000000000000001-0000000000000002-01",
+		},
+	}
+
+	for i, test := range tests {
+		if want, have := test.b3, test.sc.B3String(); want != have {
+			t.Errorf("%d: String want %q, have %q", i, want, have)
+		}
+		if want, have := test.w3c, test.sc.W3CString(); want != have {
+			t.Errorf("%d: W3CString want %q, have %q", i, want, have)
+		}
+
+		sc, err := ParseSpanContext(test.b3)
+		if err != nil {
+			t.Fatalf("%d: unexpected error: %v", i, err)
+		}
+		if !sc.Equal(test.sc) {
+			t.Errorf("%d: parsed B3 want %+v, have %+v", i, test.sc, sc)
+		}
+
+		if sc, err = ParseSpanContext(test.w3c); err != nil {
+			t.Fatalf("%d: unexpected error: %v", i, err)
+		}
+		if want, have := test.sc.TraceID, sc.TraceID; want != have {
+			t.Errorf("%d: TraceID want %s, have %s", i, want, have)
+		}
+		if want, have := test.sc.ID, sc.ID; want != have {
+			t.Errorf("%d: ID want %s, have %s", i, want, have)
+		}
+	}
+}
+
+func TestParseSpanContextInvalid(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"1",
+		"0000000000000001",
+		"000000000000001-0000000000000002",
+		"0000000000000000-0000000000000002",
+		"0000000000000001-0000000000000000",
+		"0000000000000001-0000000000000002-x",
+		"0000000000000001-0000000000000002-1-0000000000000003-1",
+		"0000000000000001-000000000000000g",
+		"00-00000000000000000000000000000001-0000000000000002",
+		"ff-00000000000000000000000000000001-0000000000000002-01",
+		"00-00000000000000000000000000000000-0000000000000002-01",
+		"00-00000000000000000000000000000001-0000000000000002-zz",
+	} {
+		if _, err := ParseSpanContext(s); err != ErrInvalidSpanContext {
+			t.Errorf("%q: want %v, have %v", s, ErrInvalidSpanContext, err)
+		}
+	}
+}
+
+func TestSpanContextEqual(t *testing.T) {
+	var (
+		yes, no  = true, false
+		p1, p2   = ID(1), ID(1)
+		sc       = SpanContext{TraceID: TraceID{Low: 1}, ID: 2, ParentID: &p1, Sampled: &yes}
+		sameVals = SpanContext{TraceID: TraceID{Low: 1}, ID: 2, ParentID: &p2, Sampled: &yes, Remote: true}
+	)
+
+	if !sc.Equal(sameVals) {
+		t.Error("expected equal span contexts")
+	}
+	for i, other := range []SpanContext{
+		{TraceID: TraceID{Low: 1}, ID: 2, ParentID: &p1},
+		{TraceID: TraceID{Low: 1}, ID: 2, ParentID: &p1, Sampled: &no},
+		{TraceID: TraceID{Low: 1}, ID: 2, Sampled: &yes},
+		{TraceID: TraceID{Low: 1}, ID: 3, ParentID: &p1, Sampled: &yes},
+		{TraceID: TraceID{Low: 1}, ID: 2, ParentID: &p1, Sampled: &yes, Debug: true},
+	} {
+		if sc.Equal(other) {
+			t.Errorf("%d: expected different span contexts", i)
+		}
+	}
+
+	if !sc.IsValid() {
+		t.Error("expected valid span context")
+	}
+	if (SpanContext{ID: 1}).IsValid() {
+		t.Error("expected invalid span context")
+	}
+	if sc.IsRemote() || !sameVals.IsRemote() {
+		t.Error("unexpected remote flag")
+	}
+}
+
+func TestSpanModelFormatting(t *testing.T) {
+	// SpanContext helpers must not change the default formatting of spans
+	span := SpanModel{SpanContext: SpanContext{TraceID: TraceID{Low: 1}, ID: 2}, Name: "get"}
+	if have := fmt.Sprintf("%+v", span); !strings.Contains(have, "Name:get") {
+		t.Errorf("expected span fields to be formatted, have %q", have)
+	}
+}
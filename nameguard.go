@@ -0,0 +1,75 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zipkin
+
+import (
+	"log"
+	"sync"
+)
+
+// DefaultOverflowSpanName is the span name used by WithSpanNameLimit once the
+// limit of distinct span names has been reached.
+const DefaultOverflowSpanName = "other"
+
+// spanNameGuard tracks distinct span names and rewrites new names to a bucket
+// once the limit of distinct names has been reached.
+type spanNameGuard struct {
+	mtx        sync.RWMutex
+	names      map[string]struct{}
+	limit      int
+	bucket     string
+	onOverflow func(name string)
+}
+
+func newSpanNameGuard(limit int, bucket string, onOverflow func(name string)) *spanNameGuard {
+	if bucket == "" {
+		bucket = DefaultOverflowSpanName
+	}
+	if onOverflow == nil {
+		onOverflow = func(name string) {
+			log.Printf("zipkin: span name limit reached, reporting %q as %q", name, bucket)
+		}
+	}
+	return &spanNameGuard{
+		names:      make(map[string]struct{}, limit),
+		limit:      limit,
+		bucket:     bucket,
+		onOverflow: onOverflow,
+	}
+}
+
+// guard returns name if it is known or can still be registered and the
+// overflow bucket otherwise.
+func (g *spanNameGuard) guard(name string) string {
+	g.mtx.RLock()
+	_, found := g.names[name]
+	g.mtx.RUnlock()
+	if found {
+		return name
+	}
+
+	g.mtx.Lock()
+	if _, found = g.names[name]; !found && len(g.names) < g.limit {
+		g.names[name] = struct{}{}
+		found = true
+	}
+	g.mtx.Unlock()
+
+	if found {
+		return name
+	}
+	g.onOverflow(name)
+	return g.bucket
+}
@@ -0,0 +1,64 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zipkin
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/openzipkin/zipkin-go/reporter/recorder"
+)
+
+func TestSpanNameLimit(t *testing.T) {
+	rec := recorder.NewReporter()
+	defer rec.Close()
+
+	if _, err := NewTracer(rec, WithSpanNameLimit(0, "", nil)); err != ErrInvalidSpanNameLimit {
+		t.Errorf("tracer creation error want %+v, have %+v", ErrInvalidSpanNameLimit, err)
+	}
+
+	var overflows []string
+	tr, err := NewTracer(rec, WithSpanNameLimit(2, "", func(name string) {
+		overflows = append(overflows, name)
+	}))
+	if err != nil {
+		t.Fatalf("unexpected tracer creation failure: %+v", err)
+	}
+
+	for _, name := range []string{"get /", "get /", "get /user/1", "get /user/2", "get /"} {
+		tr.StartSpan(name).Finish()
+	}
+	span := tr.StartSpan("get /")
+	span.SetName("get /user/3")
+	span.Finish()
+
+	var names []string
+	for _, s := range rec.Flush() {
+		names = append(names, s.Name)
+	}
+	if want, have := []string{"get /", "get /", "get /user/1", DefaultOverflowSpanName, "get /", DefaultOverflowSpanName}, names; !reflect.DeepEqual(want, have) {
+		t.Errorf("span names want %v, have %v", want, have)
+	}
+	if want, have := []string{"get /user/2", "get /user/3"}, overflows; !reflect.DeepEqual(want, have) {
+		t.Errorf("overflowed names want %v, have %v", want, have)
+	}
+
+	tr, _ = NewTracer(rec, WithSpanNameLimit(1, "bucket", func(string) {}))
+	tr.StartSpan("a").Finish()
+	tr.StartSpan("b").Finish()
+	if want, have := "bucket", rec.Flush()[1].Name; want != have {
+		t.Errorf("span name want %q, have %q", want, have)
+	}
+}
@@ -15,27 +15,89 @@
 package zipkin
 
 import (
+	"context"
 	"time"
 
 	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/propagation"
 )
 
-type noopSpan struct {
+// NoopTracer is a tracer which does not trace. Its methods do not allocate
+// and do not record anything, which allows libraries to be instrumented using
+// the zipkin-go types unconditionally while applications opt in to tracing by
+// providing a Tracer. The zero value is ready to use.
+type NoopTracer struct{}
+
+// StartSpan returns a NoopSpan.
+func (NoopTracer) StartSpan(string, ...SpanOption) Span { return defaultNoopSpan }
+
+// StartSpanFromContext returns a NoopSpan and the unmodified context.
+func (NoopTracer) StartSpanFromContext(ctx context.Context, _ string, _ ...SpanOption) (Span, context.Context) {
+	return defaultNoopSpan, ctx
+}
+
+// Extract returns an empty SpanContext without invoking the extractor.
+func (NoopTracer) Extract(propagation.Extractor) model.SpanContext { return model.SpanContext{} }
+
+// SetNoop exists for parity with Tracer, a NoopTracer is always noop.
+func (NoopTracer) SetNoop(bool) {}
+
+// LocalEndpoint returns nil.
+func (NoopTracer) LocalEndpoint() *model.Endpoint { return nil }
+
+// NoopSpan is a Span which records nothing. It is returned by NoopTracer and by
+// a Tracer which is set to noop or does not sample the span when configured
+// with WithNoopSpan.
+type NoopSpan struct {
 	model.SpanContext
 }
 
-func (n *noopSpan) Context() model.SpanContext { return n.SpanContext }
+func (n *NoopSpan) Context() model.SpanContext { return n.SpanContext }
 
-func (n *noopSpan) SetName(string) {}
+func (n *NoopSpan) SetName(string) {}
 
-func (*noopSpan) SetRemoteEndpoint(*model.Endpoint) {}
+func (*NoopSpan) SetRemoteEndpoint(*model.Endpoint) {}
 
-func (*noopSpan) Annotate(time.Time, string) {}
+func (*NoopSpan) Annotate(time.Time, string) {}
 
-func (*noopSpan) Tag(string, string) {}
+func (*NoopSpan) Tag(string, string) {}
 
-func (*noopSpan) Finish() {}
+func (*NoopSpan) Finish() {}
 
-func (*noopSpan) FinishedWithDuration(duration time.Duration) {}
+func (*NoopSpan) FinishedWithDuration(duration time.Duration) {}
 
-func (*noopSpan) Flush() {}
+func (*NoopSpan) Flush() {}
+
+// noopSpanContext holds a NoopSpan of an unsampled trace together with the
+// fields referenced by its SpanContext and, if started by
+// StartSpanFromContext, the context it is stored in, so the span and its
+// context are allocated at once.
+type noopSpanContext struct {
+	context.Context
+	span     NoopSpan
+	sampled  bool
+	parentID model.ID
+}
+
+// newNoopSpanContext returns a NoopSpan carrying the SpanContext of s, which
+// may not reference s anymore as s is released.
+func newNoopSpanContext(s *spanImpl) *noopSpanContext {
+	c := &noopSpanContext{span: NoopSpan{SpanContext: s.SpanContext}}
+	if s.Sampled == &s.sampled {
+		c.sampled = s.sampled
+		c.span.Sampled = &c.sampled
+	}
+	if s.ParentID == &s.parentID {
+		c.parentID = s.parentID
+		c.span.ParentID = &c.parentID
+	}
+	return c
+}
+
+// Value returns the NoopSpan for the span key, like context.WithValue.
+func (c *noopSpanContext) Value(key interface{}) interface{} {
+	if key == spanKey {
+		return &c.span
+	}
+	return c.Context.Value(key)
+}
@@ -15,6 +15,7 @@
 package zipkin
 
 import (
+	"context"
 	"reflect"
 	"testing"
 	"time"
@@ -46,7 +47,7 @@ func TestNoopContext(t *testing.T) {
 
 	span = tr.StartSpan("testNoop", Parent(sc), Kind(model.Server))
 
-	noop, ok := span.(*noopSpan)
+	noop, ok := span.(*NoopSpan)
 	if !ok {
 		t.Fatalf("Span type want %s, have %s", reflect.TypeOf(&spanImpl{}), reflect.TypeOf(span))
 	}
@@ -61,3 +62,33 @@ func TestNoopContext(t *testing.T) {
 	span.SetRemoteEndpoint(nil)
 	span.Flush()
 }
+
+func TestNoopTracerType(t *testing.T) {
+	var (
+		tr  NoopTracer
+		ctx = context.Background()
+	)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		span, spanCtx := tr.StartSpanFromContext(ctx, "noop")
+		span.Tag("key", "value")
+		span.Finish()
+		if spanCtx != ctx {
+			t.Fatal("expected context to be unmodified")
+		}
+		_ = tr.Extract(func() (*model.SpanContext, error) {
+			t.Fatal("expected extractor not to be invoked")
+			return nil, nil
+		})
+	})
+	if allocs != 0 {
+		t.Errorf("allocations want 0, have %v", allocs)
+	}
+
+	if _, ok := tr.StartSpan("noop").(*NoopSpan); !ok {
+		t.Errorf("Span type want %s, have %s", reflect.TypeOf(&NoopSpan{}), reflect.TypeOf(tr.StartSpan("noop")))
+	}
+	if tr.LocalEndpoint() != nil {
+		t.Error("expected no local endpoint")
+	}
+}
@@ -23,7 +23,12 @@ import (
 
 // ExtractGRPC will extract a span.Context from the gRPC Request metadata if
 // found in B3 header format.
-func ExtractGRPC(md *metadata.MD) propagation.Extractor {
+func ExtractGRPC(md *metadata.MD, opts ...ExtractOption) propagation.Extractor {
+	var options ExtractOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
 	return func() (*model.SpanContext, error) {
 		var (
 			traceIDHeader      = GetGRPCHeader(md, TraceID)
@@ -33,19 +38,29 @@ func ExtractGRPC(md *metadata.MD) propagation.Extractor {
 			flagsHeader        = GetGRPCHeader(md, Flags)
 		)
 
-		return ParseHeaders(
+		return options.parseHeaders(
 			traceIDHeader, spanIDHeader, parentSpanIDHeader, sampledHeader,
 			flagsHeader,
 		)
 	}
 }
 
-// InjectGRPC will inject a span.Context into gRPC metadata.
-func InjectGRPC(md *metadata.MD) propagation.Injector {
+// InjectGRPC will inject a span.Context into gRPC metadata. Of the inject
+// options only With64BitTraceID applies as gRPC metadata always uses multiple
+// headers.
+func InjectGRPC(md *metadata.MD, opts ...InjectOption) propagation.Injector {
+	var options InjectOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
 	return func(sc model.SpanContext) error {
 		if (model.SpanContext{}) == sc {
 			return ErrEmptyContext
 		}
+		if options.traceID64Bit {
+			sc, _ = Downgrade(sc)
+		}
 
 		if sc.Debug {
 			setGRPCHeader(md, Flags, "1")
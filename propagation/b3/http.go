@@ -26,6 +26,7 @@ type InjectOption func(opts *InjectOptions)
 type InjectOptions struct {
 	shouldInjectSingleHeader bool
 	shouldInjectMultiHeader  bool
+	traceID64Bit             bool
 }
 
 // WithSingleAndMultiHeader allows to include both single and multiple
@@ -46,9 +47,35 @@ func WithSingleHeaderOnly() InjectOption {
 	}
 }
 
+// With64BitTraceID downgrades 128-bit trace ids to their lower 64 bits when
+// injecting, for legacy downstream services like old Finagle or Brave versions
+// which fail on 128-bit trace ids. The trace is continued downstream with the
+// downgraded trace id, so the downstream spans will not join the upstream
+// trace in Zipkin unless the server is configured to treat trace ids as 64-bit.
+func With64BitTraceID() InjectOption {
+	return func(opts *InjectOptions) {
+		opts.traceID64Bit = true
+	}
+}
+
+// Downgrade returns sc with the trace id downgraded to its lower 64 bits, and
+// whether sc held a 128-bit trace id.
+func Downgrade(sc model.SpanContext) (model.SpanContext, bool) {
+	if sc.TraceID.High == 0 {
+		return sc, false
+	}
+	sc.TraceID.High = 0
+	return sc, true
+}
+
 // ExtractHTTP will extract a span.Context from the HTTP Request if found in
 // B3 header format.
-func ExtractHTTP(r *http.Request) propagation.Extractor {
+func ExtractHTTP(r *http.Request, opts ...ExtractOption) propagation.Extractor {
+	var options ExtractOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
 	return func() (*model.SpanContext, error) {
 		var (
 			traceIDHeader      = r.Header.Get(TraceID)
@@ -65,13 +92,13 @@ func ExtractHTTP(r *http.Request) propagation.Extractor {
 			mErr error
 		)
 		if singleHeader != "" {
-			sc, sErr = ParseSingleHeader(singleHeader)
+			sc, sErr = options.parseSingleHeader(singleHeader)
 			if sErr == nil {
 				return sc, nil
 			}
 		}
 
-		sc, mErr = ParseHeaders(
+		sc, mErr = options.parseHeaders(
 			traceIDHeader, spanIDHeader, parentSpanIDHeader,
 			sampledHeader, flagsHeader,
 		)
@@ -95,6 +122,9 @@ func InjectHTTP(r *http.Request, opts ...InjectOption) propagation.Injector {
 		if (model.SpanContext{}) == sc {
 			return ErrEmptyContext
 		}
+		if options.traceID64Bit {
+			sc, _ = Downgrade(sc)
+		}
 
 		if options.shouldInjectMultiHeader {
 			if sc.Debug {
@@ -411,6 +411,32 @@ func TestHTTPInjectWithBothSingleAndMultipleHeaders(t *testing.T) {
 	}
 }
 
+func TestHTTPInjectWith64BitTraceID(t *testing.T) {
+	r := newHTTPRequest(t)
+
+	sc := model.SpanContext{
+		TraceID: model.TraceID{High: 1, Low: 2},
+		ID:      model.ID(3),
+	}
+
+	b3.InjectHTTP(r, b3.WithSingleAndMultiHeader(), b3.With64BitTraceID())(sc)
+
+	if want, have := "0000000000000002", r.Header.Get(b3.TraceID); want != have {
+		t.Errorf("Trace ID want %s, have %s", want, have)
+	}
+	if want, have := "0000000000000002-0000000000000003", r.Header.Get(b3.Context); want != have {
+		t.Errorf("Context want %s, have %s", want, have)
+	}
+
+	if _, downgraded := b3.Downgrade(sc); !downgraded {
+		t.Error("expected 128-bit trace id to be downgraded")
+	}
+	sc.TraceID.High = 0
+	if have, downgraded := b3.Downgrade(sc); downgraded || have != sc {
+		t.Errorf("expected 64-bit trace id to be kept, have %+v", have)
+	}
+}
+
 func newHTTPRequest(t *testing.T) *http.Request {
 	r, err := http.NewRequest("test", "", nil)
 	if err != nil {
@@ -69,6 +69,9 @@ func (m *Map) Inject(opts ...InjectOption) propagation.Injector {
 		if (model.SpanContext{}) == sc {
 			return ErrEmptyContext
 		}
+		if options.traceID64Bit {
+			sc, _ = Downgrade(sc)
+		}
 
 		if options.shouldInjectMultiHeader {
 			if sc.Debug {
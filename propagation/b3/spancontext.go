@@ -120,7 +120,7 @@ func ParseSingleHeader(contextHeader string) (*model.SpanContext, error) {
 			pos = 16
 		}
 
-		low, err := strconv.ParseUint(contextHeader[pos+1:pos+16], 16, 64)
+		low, err := strconv.ParseUint(contextHeader[pos:pos+16], 16, 64)
 		if err != nil {
 			return nil, ErrInvalidTraceIDValue
 		}
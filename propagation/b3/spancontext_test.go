@@ -20,6 +20,22 @@ import (
 	"github.com/openzipkin/zipkin-go/model"
 )
 
+func TestParseSingleHeaderLowTraceID(t *testing.T) {
+	// the first hex character of the low trace id must not be skipped
+	for header, want := range map[string]model.TraceID{
+		"1000000000000000-000000000000007b":                 {Low: 1 << 60},
+		"00000000000000011000000000000000-000000000000007b": {High: 1, Low: 1 << 60},
+	} {
+		sc, err := ParseSingleHeader(header)
+		if err != nil {
+			t.Fatalf("unexpected error for header %q: %v", header, err)
+		}
+		if have := sc.TraceID; want != have {
+			t.Errorf("header %q trace id want %s, have %s", header, want, have)
+		}
+	}
+}
+
 func TestParseHeaderSuccess(t *testing.T) {
 	trueVal := true
 	falseVal := false
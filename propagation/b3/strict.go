@@ -0,0 +1,275 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package b3
+
+import (
+	"errors"
+
+	"github.com/openzipkin/zipkin-go/model"
+)
+
+// ErrHeaderTooLong is returned by the strict parsers for header values
+// exceeding the maximum length allowed by the B3 specification.
+var ErrHeaderTooLong = errors.New("B3 header value exceeds maximum length")
+
+// maximum header value lengths
+const (
+	maxTraceIDLen      = 32
+	spanIDLen          = 16
+	maxSingleHeaderLen = maxTraceIDLen + 1 + spanIDLen + 1 + 1 + 1 + spanIDLen
+)
+
+// ExtractOption allows to customize the extraction of B3 headers.
+type ExtractOption func(opts *ExtractOptions)
+
+// ExtractOptions holds the B3 header extraction settings.
+type ExtractOptions struct {
+	strict bool
+}
+
+// WithStrictParsing makes extraction use ParseHeadersStrict and
+// ParseSingleHeaderStrict instead of the lenient parsers.
+func WithStrictParsing() ExtractOption {
+	return func(opts *ExtractOptions) {
+		opts.strict = true
+	}
+}
+
+func (o ExtractOptions) parseHeaders(
+	hdrTraceID, hdrSpanID, hdrParentSpanID, hdrSampled, hdrFlags string,
+) (*model.SpanContext, error) {
+	if o.strict {
+		return ParseHeadersStrict(hdrTraceID, hdrSpanID, hdrParentSpanID, hdrSampled, hdrFlags)
+	}
+	return ParseHeaders(hdrTraceID, hdrSpanID, hdrParentSpanID, hdrSampled, hdrFlags)
+}
+
+func (o ExtractOptions) parseSingleHeader(contextHeader string) (*model.SpanContext, error) {
+	if o.strict {
+		return ParseSingleHeaderStrict(contextHeader)
+	}
+	return ParseSingleHeader(contextHeader)
+}
+
+// ParseHeadersStrict is the strict variant of ParseHeaders. Header values must
+// adhere to the B3 specification exactly: identifiers must be lower-hex encoded
+// with a length of 16 (or 32 for trace ids) characters and must not be zero,
+// sampled must be "0" or "1" and flags must be "1" if present. Header lengths are checked before
+// parsing and malformed values are rejected without allocating.
+func ParseHeadersStrict(
+	hdrTraceID, hdrSpanID, hdrParentSpanID, hdrSampled, hdrFlags string,
+) (*model.SpanContext, error) {
+	if len(hdrTraceID) > maxTraceIDLen || len(hdrSpanID) > spanIDLen ||
+		len(hdrParentSpanID) > spanIDLen || len(hdrSampled) > 1 || len(hdrFlags) > 1 {
+		return nil, ErrHeaderTooLong
+	}
+
+	var sampled, debug, sampledSet bool
+	switch hdrSampled {
+	case "0":
+		sampledSet = true
+	case "1":
+		sampled, sampledSet = true, true
+	case "":
+	default:
+		return nil, ErrInvalidSampledHeader
+	}
+
+	switch hdrFlags {
+	case "1":
+		debug, sampledSet = true, false
+	case "":
+	default:
+		return nil, ErrInvalidFlagsHeader
+	}
+
+	if (hdrTraceID == "") != (hdrSpanID == "") {
+		return nil, ErrInvalidScope
+	}
+	if hdrParentSpanID != "" && hdrTraceID == "" {
+		return nil, ErrInvalidScopeParent
+	}
+
+	var (
+		traceID  model.TraceID
+		spanID   uint64
+		parentID uint64
+		ok       bool
+	)
+	if hdrTraceID != "" {
+		if traceID, ok = parseTraceIDStrict(hdrTraceID); !ok || traceID.Empty() {
+			return nil, ErrInvalidTraceIDHeader
+		}
+		if spanID, ok = parseSpanIDStrict(hdrSpanID); !ok || spanID == 0 {
+			return nil, ErrInvalidSpanIDHeader
+		}
+	}
+	if hdrParentSpanID != "" {
+		if parentID, ok = parseSpanIDStrict(hdrParentSpanID); !ok || parentID == 0 {
+			return nil, ErrInvalidParentSpanIDHeader
+		}
+	}
+
+	sc := &model.SpanContext{TraceID: traceID, ID: model.ID(spanID), Debug: debug}
+	if sampledSet {
+		// copy so sampled does not escape on the rejection paths
+		v := sampled
+		sc.Sampled = &v
+	}
+	if hdrParentSpanID != "" {
+		p := model.ID(parentID)
+		sc.ParentID = &p
+	}
+	return sc, nil
+}
+
+// ParseSingleHeaderStrict is the strict variant of ParseSingleHeader.
+// Identifiers must be lower-hex encoded with a length of 16 (or 32 for trace
+// ids) characters and must not be zero. The sampling state must be "0", "1" or
+// "d". The header
+// length is checked before parsing and malformed values are rejected without
+// allocating.
+func ParseSingleHeaderStrict(contextHeader string) (*model.SpanContext, error) {
+	h := contextHeader
+	if h == "" {
+		return nil, ErrEmptyContext
+	}
+	if len(h) > maxSingleHeaderLen {
+		return nil, ErrHeaderTooLong
+	}
+
+	var (
+		traceID     model.TraceID
+		spanID      uint64
+		parentID    uint64
+		hasParent   bool
+		sampling    byte
+		hasSampling bool
+		ok          bool
+	)
+
+	if len(h) == 1 {
+		sampling, hasSampling = h[0], true
+	} else {
+		var tl int
+		switch {
+		case len(h) > 16 && h[16] == '-':
+			tl = 16
+		case len(h) > 32 && h[32] == '-':
+			tl = 32
+		default:
+			return nil, ErrInvalidTraceIDValue
+		}
+		if traceID, ok = parseTraceIDStrict(h[:tl]); !ok || traceID.Empty() {
+			return nil, ErrInvalidTraceIDValue
+		}
+
+		rest := h[tl+1:]
+		if len(rest) < spanIDLen {
+			return nil, ErrInvalidSpanIDValue
+		}
+		if spanID, ok = parseSpanIDStrict(rest[:spanIDLen]); !ok || spanID == 0 {
+			return nil, ErrInvalidSpanIDValue
+		}
+		rest = rest[spanIDLen:]
+
+		if len(rest) > 0 {
+			if len(rest) == 1+spanIDLen {
+				return nil, ErrInvalidScopeParentSingle
+			}
+			if len(rest) < 2 || rest[0] != '-' {
+				return nil, ErrInvalidSampledByte
+			}
+			sampling, hasSampling = rest[1], true
+			rest = rest[2:]
+		}
+
+		if len(rest) > 0 {
+			if len(rest) != 1+spanIDLen || rest[0] != '-' {
+				return nil, ErrInvalidParentSpanIDValue
+			}
+			if parentID, ok = parseSpanIDStrict(rest[1:]); !ok || parentID == 0 {
+				return nil, ErrInvalidParentSpanIDValue
+			}
+			hasParent = true
+		}
+	}
+
+	var sampled, sampledSet, debug bool
+	if hasSampling {
+		switch sampling {
+		case 'd':
+			debug = true
+		case '1':
+			sampled, sampledSet = true, true
+		case '0':
+			sampledSet = true
+		default:
+			return nil, ErrInvalidSampledByte
+		}
+	}
+
+	sc := &model.SpanContext{TraceID: traceID, ID: model.ID(spanID), Debug: debug}
+	if sampledSet {
+		// copy so sampled does not escape on the rejection paths
+		v := sampled
+		sc.Sampled = &v
+	}
+	if hasParent {
+		p := model.ID(parentID)
+		sc.ParentID = &p
+	}
+	return sc, nil
+}
+
+func parseTraceIDStrict(s string) (model.TraceID, bool) {
+	switch len(s) {
+	case 16:
+		low, ok := parseHexStrict(s)
+		return model.TraceID{Low: low}, ok
+	case 32:
+		high, ok := parseHexStrict(s[:16])
+		if !ok {
+			return model.TraceID{}, false
+		}
+		low, ok := parseHexStrict(s[16:])
+		return model.TraceID{High: high, Low: low}, ok
+	}
+	return model.TraceID{}, false
+}
+
+func parseSpanIDStrict(s string) (uint64, bool) {
+	if len(s) != spanIDLen {
+		return 0, false
+	}
+	return parseHexStrict(s)
+}
+
+// parseHexStrict parses up to 16 lower-hex characters.
+func parseHexStrict(s string) (uint64, bool) {
+	var v uint64
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case '0' <= c && c <= '9':
+			v = v<<4 | uint64(c-'0')
+		case 'a' <= c && c <= 'f':
+			v = v<<4 | uint64(c-'a'+10)
+		default:
+			return 0, false
+		}
+	}
+	return v, true
+}
@@ -0,0 +1,76 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.18
+// +build go1.18
+
+package b3
+
+import (
+	"reflect"
+	"testing"
+)
+
+func FuzzParseSingleHeaderStrict(f *testing.F) {
+	for _, seed := range []string{
+		"d",
+		strictTraceID + "-" + strictSpanID,
+		strictTraceID[16:] + "-" + strictSpanID + "-1",
+		strictTraceID + "-" + strictSpanID + "-1-" + strictParentID,
+		strictTraceID + "-" + strictSpanID + "-" + strictParentID,
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, header string) {
+		sc, err := ParseSingleHeaderStrict(header)
+		if err != nil {
+			return
+		}
+		// anything accepted in strict mode must be accepted by the lenient
+		// parser with the same outcome and survive a round trip
+		lenient, err := ParseSingleHeader(header)
+		if err != nil {
+			t.Fatalf("header %q accepted in strict mode only: %+v", header, err)
+		}
+		if !reflect.DeepEqual(sc, lenient) {
+			t.Fatalf("header %q strict %+v, lenient %+v", header, sc, lenient)
+		}
+		if sc.TraceID.High != 0 || len(header) == 1 {
+			if rebuilt := BuildSingleHeader(*sc); rebuilt != header {
+				t.Fatalf("header %q rebuilt as %q", header, rebuilt)
+			}
+		}
+	})
+}
+
+func FuzzParseHeadersStrict(f *testing.F) {
+	f.Add(strictTraceID, strictSpanID, strictParentID, "1", "")
+	f.Add(strictTraceID[16:], strictSpanID, "", "0", "1")
+	f.Add("", "", "", "", "1")
+
+	f.Fuzz(func(t *testing.T, traceID, spanID, parentID, sampled, flags string) {
+		sc, err := ParseHeadersStrict(traceID, spanID, parentID, sampled, flags)
+		if err != nil {
+			return
+		}
+		lenient, err := ParseHeaders(traceID, spanID, parentID, sampled, flags)
+		if err != nil {
+			t.Fatalf("headers accepted in strict mode only: %+v", err)
+		}
+		if !reflect.DeepEqual(sc, lenient) {
+			t.Fatalf("strict %+v, lenient %+v", sc, lenient)
+		}
+	})
+}
@@ -0,0 +1,163 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package b3
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	strictTraceID  = "000000000000007b00000000000001c8"
+	strictSpanID   = "000000000000007b"
+	strictParentID = "00000000000001c8"
+)
+
+func TestParseSingleHeaderStrict(t *testing.T) {
+	// valid headers must parse identical to the lenient parser
+	for _, header := range []string{
+		"d",
+		"1",
+		"0",
+		strictTraceID + "-" + strictSpanID,
+		strictTraceID[16:] + "-" + strictSpanID,
+		strictTraceID + "-" + strictSpanID + "-0",
+		strictTraceID + "-" + strictSpanID + "-d",
+		strictTraceID + "-" + strictSpanID + "-1-" + strictParentID,
+		strictTraceID[16:] + "-" + strictSpanID + "-1-" + strictParentID,
+	} {
+		want, err := ParseSingleHeader(header)
+		if err != nil {
+			t.Fatalf("unexpected error for header %q: %+v", header, err)
+		}
+		have, err := ParseSingleHeaderStrict(header)
+		if err != nil {
+			t.Fatalf("unexpected error for header %q: %+v", header, err)
+		}
+		if !reflect.DeepEqual(want, have) {
+			t.Errorf("header %q want %+v, have %+v", header, want, have)
+		}
+	}
+
+	testCases := []struct {
+		header string
+		err    error
+	}{
+		{"", ErrEmptyContext},
+		{"x", ErrInvalidSampledByte},
+		{strings.Repeat("a", maxSingleHeaderLen+1), ErrHeaderTooLong},
+		{strings.ToUpper(strictTraceID) + "-" + strictSpanID, ErrInvalidTraceIDValue},
+		{"000000000000007b00000000-" + strictSpanID, ErrInvalidTraceIDValue},
+		{strictTraceID + "-" + "000000000000007", ErrInvalidSpanIDValue},
+		{strictTraceID + "-" + "000000000000007g", ErrInvalidSpanIDValue},
+		{strictTraceID + "-" + "0000000000000000", ErrInvalidSpanIDValue},
+		{"0000000000000000-" + strictSpanID, ErrInvalidTraceIDValue},
+		{strictTraceID + "-" + strictSpanID + "-", ErrInvalidSampledByte},
+		{strictTraceID + "-" + strictSpanID + "-x", ErrInvalidSampledByte},
+		{strictTraceID + "-" + strictSpanID + "+1", ErrInvalidSampledByte},
+		{strictTraceID + "-" + strictSpanID + "-" + strictParentID, ErrInvalidScopeParentSingle},
+		{strictTraceID + "-" + strictSpanID + "-1-00000000000001c", ErrInvalidParentSpanIDValue},
+		{strictTraceID + "-" + strictSpanID + "-1-00000000000001C8", ErrInvalidParentSpanIDValue},
+	}
+
+	for _, testCase := range testCases {
+		if _, have := ParseSingleHeaderStrict(testCase.header); testCase.err != have {
+			t.Errorf("header %q want error %v, have %v", testCase.header, testCase.err, have)
+		}
+	}
+}
+
+func TestParseHeadersStrict(t *testing.T) {
+	// valid headers must parse identical to the lenient parser
+	for _, headers := range [][5]string{
+		{"", "", "", "", ""},
+		{"", "", "", "1", ""},
+		{"", "", "", "", "1"},
+		{strictTraceID, strictSpanID, "", "", ""},
+		{strictTraceID[16:], strictSpanID, strictParentID, "0", ""},
+		{strictTraceID, strictSpanID, strictParentID, "1", "1"},
+	} {
+		want, err := ParseHeaders(headers[0], headers[1], headers[2], headers[3], headers[4])
+		if err != nil {
+			t.Fatalf("unexpected error for headers %q: %+v", headers, err)
+		}
+		have, err := ParseHeadersStrict(headers[0], headers[1], headers[2], headers[3], headers[4])
+		if err != nil {
+			t.Fatalf("unexpected error for headers %q: %+v", headers, err)
+		}
+		if !reflect.DeepEqual(want, have) {
+			t.Errorf("headers %q want %+v, have %+v", headers, want, have)
+		}
+	}
+
+	testCases := []struct {
+		headers [5]string
+		err     error
+	}{
+		{[5]string{strictTraceID + "0", strictSpanID, "", "", ""}, ErrHeaderTooLong},
+		{[5]string{strictTraceID, strictSpanID + "0", "", "", ""}, ErrHeaderTooLong},
+		{[5]string{strictTraceID, strictSpanID, strictParentID + "0", "", ""}, ErrHeaderTooLong},
+		{[5]string{"", "", "", "true", ""}, ErrHeaderTooLong},
+		{[5]string{"", "", "", "2", ""}, ErrInvalidSampledHeader},
+		{[5]string{"", "", "", "", "0"}, ErrInvalidFlagsHeader},
+		{[5]string{strictTraceID, "", "", "", ""}, ErrInvalidScope},
+		{[5]string{"", "", strictParentID, "", ""}, ErrInvalidScopeParent},
+		{[5]string{"7b", strictSpanID, "", "", ""}, ErrInvalidTraceIDHeader},
+		{[5]string{strictTraceID, "7b", "", "", ""}, ErrInvalidSpanIDHeader},
+		{[5]string{strictTraceID, "0000000000000000", "", "", ""}, ErrInvalidSpanIDHeader},
+		{[5]string{strictTraceID, strictSpanID, "1C8", "", ""}, ErrInvalidParentSpanIDHeader},
+	}
+
+	for _, testCase := range testCases {
+		h := testCase.headers
+		if _, have := ParseHeadersStrict(h[0], h[1], h[2], h[3], h[4]); testCase.err != have {
+			t.Errorf("headers %q want error %v, have %v", h, testCase.err, have)
+		}
+	}
+}
+
+func TestStrictRejectionAllocations(t *testing.T) {
+	long := strings.Repeat("f", 4096)
+	malformed := strictTraceID + "-" + strictSpanID + "-1-00000000000001cX"
+
+	allocs := testing.AllocsPerRun(100, func() {
+		_, _ = ParseSingleHeaderStrict(long)
+		_, _ = ParseSingleHeaderStrict(malformed)
+		_, _ = ParseHeadersStrict(long, long, "", "", "")
+		_, _ = ParseHeadersStrict(strictTraceID, "000000000000007X", "", "", "")
+	})
+	if allocs != 0 {
+		t.Errorf("want 0 allocations, have %f", allocs)
+	}
+}
+
+func TestExtractHTTPStrict(t *testing.T) {
+	r, _ := http.NewRequest("GET", "http://localhost", nil)
+	r.Header.Set(TraceID, strings.ToUpper(strictTraceID))
+	r.Header.Set(SpanID, strictSpanID)
+
+	if _, err := ExtractHTTP(r)(); err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+
+	if want, have := ErrInvalidTraceIDHeader, func() error {
+		_, err := ExtractHTTP(r, WithStrictParsing())()
+		return err
+	}(); want != have {
+		t.Errorf("want %v, have %v", want, have)
+	}
+}
@@ -0,0 +1,32 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package jwt implements propagation of the span context through JSON Web Token
+claims, for architectures where headers are stripped but tokens pass through.
+
+The span context is stored as B3 single header value in the "b3" claim. The
+claim helpers work on generic map claims, as supported by most JWT libraries.
+Signing the token and verifying its signature and time claims is left to an
+established JWT library, e.g. using github.com/golang-jwt/jwt:
+
+	claims := jwt.MapClaims{"sub": user}
+	err := zipkinjwt.InjectClaims(claims)(span.Context())
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(key)
+
+	token, err := jwt.Parse(signed, keyFunc)
+	claims, _ := token.Claims.(jwt.MapClaims)
+	sc := tracer.Extract(zipkinjwt.ExtractClaims(claims))
+*/
+package jwt
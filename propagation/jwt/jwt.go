@@ -0,0 +1,65 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jwt
+
+import (
+	"errors"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/propagation"
+	"github.com/openzipkin/zipkin-go/propagation/b3"
+)
+
+// ClaimB3 is the name of the claim holding the span context.
+const ClaimB3 = "b3"
+
+// Common Token Extraction / Injection errors
+var (
+	ErrEmptyContext = errors.New("empty span context")
+	ErrInvalidClaim = errors.New("invalid b3 claim found")
+)
+
+// InjectClaims will inject a span.Context into the provided claims.
+func InjectClaims(claims map[string]interface{}) propagation.Injector {
+	return func(sc model.SpanContext) error {
+		if sc.TraceID.Empty() || sc.ID == 0 {
+			return ErrEmptyContext
+		}
+		claims[ClaimB3] = b3.BuildSingleHeader(sc)
+		return nil
+	}
+}
+
+// ExtractClaims will extract a span.Context from the provided claims if found.
+// The claim value is validated using strict B3 parsing, as tokens may originate
+// from untrusted parties.
+func ExtractClaims(claims map[string]interface{}) propagation.Extractor {
+	return func() (*model.SpanContext, error) {
+		v, ok := claims[ClaimB3]
+		if !ok {
+			// no upstream context found, start a new trace
+			return nil, nil
+		}
+		s, ok := v.(string)
+		if !ok {
+			return nil, ErrInvalidClaim
+		}
+		sc, err := b3.ParseSingleHeaderStrict(s)
+		if err != nil || sc.TraceID.Empty() {
+			return nil, ErrInvalidClaim
+		}
+		return sc, nil
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jwt_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/propagation/jwt"
+)
+
+func makeContext() model.SpanContext {
+	sampled := true
+	parentID := model.ID(2)
+	return model.SpanContext{
+		TraceID:  model.TraceID{High: 1, Low: 2},
+		ID:       3,
+		ParentID: &parentID,
+		Sampled:  &sampled,
+	}
+}
+
+func TestClaims(t *testing.T) {
+	claims := map[string]interface{}{}
+
+	if err := jwt.InjectClaims(claims)(makeContext()); err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+
+	if want, have := "00000000000000010000000000000002-0000000000000003-1-0000000000000002", claims[jwt.ClaimB3]; want != have {
+		t.Errorf("claim want %q, have %v", want, have)
+	}
+
+	sc, err := jwt.ExtractClaims(claims)()
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	if want, have := makeContext(), *sc; !reflect.DeepEqual(want, have) {
+		t.Errorf("span context want %+v, have %+v", want, have)
+	}
+
+	if err = jwt.InjectClaims(claims)(model.SpanContext{}); err != jwt.ErrEmptyContext {
+		t.Errorf("want %v, have %v", jwt.ErrEmptyContext, err)
+	}
+}
+
+func TestExtractClaimsInvalid(t *testing.T) {
+	if sc, err := jwt.ExtractClaims(map[string]interface{}{})(); sc != nil || err != nil {
+		t.Errorf("expected no span context and no error, have %+v, %v", sc, err)
+	}
+
+	for _, value := range []interface{}{1, "1", "not-a-context", strings.Repeat("a", 1024)} {
+		claims := map[string]interface{}{jwt.ClaimB3: value}
+		if _, err := jwt.ExtractClaims(claims)(); err != jwt.ErrInvalidClaim {
+			t.Errorf("claim %v want %v, have %v", value, jwt.ErrInvalidClaim, err)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package w3c
+
+import (
+	"net/http"
+	"strings"
+)
+
+// maxTraceStateMemberSize is the size above which tracestate list members are
+// the first to be removed when truncating, as recommended by the W3C Trace
+// Context specification.
+const maxTraceStateMemberSize = 128
+
+// TruncateHeaders makes the traceparent, tracestate and baggage headers found
+// in h fit in budget bytes, counting the length of every header name and
+// value. Baggage is truncated first, followed by tracestate. List members are
+// removed from the end of a header, except for tracestate members larger than
+// 128 bytes which are removed first. Headers left without members are removed.
+// The traceparent header is never truncated, if it does not fit in budget by
+// itself the tracestate and baggage headers are removed and
+// ErrHeaderBudgetExceeded is returned. A budget of zero or less disables
+// truncation.
+func TruncateHeaders(h http.Header, budget int) error {
+	if budget <= 0 {
+		return nil
+	}
+	var (
+		traceParent = joined(h, TraceParent)
+		traceState  = members(joined(h, TraceState))
+		baggage     = members(joined(h, Baggage))
+	)
+	size := func() int {
+		return headerSize(TraceParent, traceParent) +
+			listSize(TraceState, traceState) + listSize(Baggage, baggage)
+	}
+	for len(baggage) > 0 && size() > budget {
+		baggage = baggage[:len(baggage)-1]
+	}
+	for len(traceState) > 0 && size() > budget {
+		traceState = dropTraceStateMember(traceState)
+	}
+	setList(h, TraceState, traceState)
+	setList(h, Baggage, baggage)
+	if size() > budget {
+		return ErrHeaderBudgetExceeded
+	}
+	return nil
+}
+
+// dropTraceStateMember removes the last tracestate member larger than 128
+// bytes, or the last member if all members are smaller.
+func dropTraceStateMember(m []string) []string {
+	idx := len(m) - 1
+	for i := len(m) - 1; i >= 0; i-- {
+		if len(m[i]) > maxTraceStateMemberSize {
+			idx = i
+			break
+		}
+	}
+	return append(m[:idx], m[idx+1:]...)
+}
+
+// joined returns the values of header key combined into a single list.
+func joined(h http.Header, key string) string {
+	return strings.Join(h[http.CanonicalHeaderKey(key)], ",")
+}
+
+// members splits a list header value in its non-empty members.
+func members(v string) []string {
+	var m []string
+	for _, member := range strings.Split(v, ",") {
+		if member = strings.TrimSpace(member); member != "" {
+			m = append(m, member)
+		}
+	}
+	return m
+}
+
+func headerSize(key, value string) int {
+	if value == "" {
+		return 0
+	}
+	return len(key) + len(value)
+}
+
+func listSize(key string, m []string) int {
+	if len(m) == 0 {
+		return 0
+	}
+	size := len(key) + len(m) - 1
+	for _, member := range m {
+		size += len(member)
+	}
+	return size
+}
+
+func setList(h http.Header, key string, m []string) {
+	if len(m) == 0 {
+		h.Del(key)
+		return
+	}
+	h.Set(key, strings.Join(m, ","))
+}
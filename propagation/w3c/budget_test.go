@@ -0,0 +1,87 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package w3c_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/propagation/w3c"
+)
+
+func TestInjectHTTPWithHeaderBudget(t *testing.T) {
+	sc := model.SpanContext{
+		TraceID: model.TraceID{Low: 456},
+		ID:      model.ID(789),
+	}
+
+	// traceparent takes 66 bytes, tracestate 17 bytes and baggage 18 bytes
+	testCases := []struct {
+		budget     int
+		traceState string
+		baggage    string
+		err        error
+	}{
+		{budget: 0, traceState: "a=1, b=2", baggage: "k1=v1,k2=v2"},
+		{budget: 101, traceState: "a=1,b=2", baggage: "k1=v1,k2=v2"},
+		{budget: 95, traceState: "a=1,b=2", baggage: "k1=v1"},
+		{budget: 83, traceState: "a=1,b=2"},
+		{budget: 80, traceState: "a=1"},
+		{budget: 70},
+		{budget: 60, err: w3c.ErrHeaderBudgetExceeded},
+	}
+
+	for _, tc := range testCases {
+		r, _ := http.NewRequest("GET", "http://localhost", nil)
+		r.Header.Add(w3c.TraceState, "a=1, b=2")
+		r.Header.Add(w3c.Baggage, "k1=v1")
+		r.Header.Add(w3c.Baggage, "k2=v2")
+
+		if want, have := tc.err, w3c.InjectHTTP(r, w3c.WithHeaderBudget(tc.budget))(sc); want != have {
+			t.Errorf("budget %d: unexpected error, want %v, have %v", tc.budget, want, have)
+		}
+		if r.Header.Get(w3c.TraceParent) == "" {
+			t.Errorf("budget %d: expected traceparent header", tc.budget)
+		}
+		if want, have := tc.traceState, r.Header.Get(w3c.TraceState); want != have {
+			t.Errorf("budget %d: unexpected tracestate, want %q, have %q", tc.budget, want, have)
+		}
+		if want, have := tc.baggage, strings.Join(r.Header[http.CanonicalHeaderKey(w3c.Baggage)], ","); want != have {
+			t.Errorf("budget %d: unexpected baggage, want %q, have %q", tc.budget, want, have)
+		}
+	}
+}
+
+func TestTruncateHeadersLargeTraceStateMember(t *testing.T) {
+	large := "large=" + strings.Repeat("x", 130)
+	h := http.Header{}
+	h.Set(w3c.TraceState, "a=1,"+large+",b=2,c=3")
+
+	if err := w3c.TruncateHeaders(h, 30); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want, have := "a=1,b=2,c=3", h.Get(w3c.TraceState); want != have {
+		t.Errorf("unexpected tracestate, want %q, have %q", want, have)
+	}
+
+	if err := w3c.TruncateHeaders(h, 20); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want, have := "a=1,b=2", h.Get(w3c.TraceState); want != have {
+		t.Errorf("unexpected tracestate, want %q, have %q", want, have)
+	}
+}
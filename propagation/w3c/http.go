@@ -0,0 +1,70 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package w3c
+
+import (
+	"net/http"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/propagation"
+)
+
+// ExtractHTTP will extract a span.Context from the HTTP Request if found in
+// W3C traceparent header format.
+func ExtractHTTP(r *http.Request) propagation.Extractor {
+	return func() (*model.SpanContext, error) {
+		header := r.Header.Get(TraceParent)
+		if header == "" {
+			// no upstream context found, start a new trace
+			return nil, nil
+		}
+		return ParseTraceParent(header)
+	}
+}
+
+// InjectOption allows to customize the injection of a span.Context.
+type InjectOption func(opts *InjectOptions)
+
+// InjectOptions holds the injection settings.
+type InjectOptions struct {
+	headerBudget int
+}
+
+// WithHeaderBudget limits the traceparent, tracestate and baggage headers of
+// the request to budget bytes, truncating the tracestate and baggage headers
+// already present on the request as described by TruncateHeaders, so
+// propagation does not push requests over the header size limits of proxies.
+func WithHeaderBudget(budget int) InjectOption {
+	return func(opts *InjectOptions) {
+		opts.headerBudget = budget
+	}
+}
+
+// InjectHTTP will inject a span.Context into a HTTP Request as W3C traceparent
+// header.
+func InjectHTTP(r *http.Request, opts ...InjectOption) propagation.Injector {
+	var options InjectOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return func(sc model.SpanContext) error {
+		if sc.TraceID.Empty() || sc.ID == 0 {
+			return ErrEmptyContext
+		}
+		r.Header.Set(TraceParent, BuildTraceParent(sc))
+		return TruncateHeaders(r.Header, options.headerBudget)
+	}
+}
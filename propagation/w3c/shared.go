@@ -0,0 +1,35 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package w3c
+
+import "errors"
+
+// Common Header Extraction / Injection errors
+var (
+	ErrEmptyContext         = errors.New("empty request context")
+	ErrInvalidTraceParent   = errors.New("invalid traceparent header found")
+	ErrInvalidVersion       = errors.New("invalid traceparent version found")
+	ErrInvalidTraceIDValue  = errors.New("invalid traceparent TraceID value found")
+	ErrInvalidParentIDValue = errors.New("invalid traceparent ParentID value found")
+	ErrInvalidFlagsValue    = errors.New("invalid traceparent flags value found")
+	ErrHeaderBudgetExceeded = errors.New("traceparent header exceeds header budget")
+)
+
+// Default W3C Trace Context header keys
+const (
+	TraceParent = "traceparent"
+	TraceState  = "tracestate"
+	Baggage     = "baggage"
+)
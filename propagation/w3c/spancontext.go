@@ -0,0 +1,103 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package w3c
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/openzipkin/zipkin-go/model"
+)
+
+const (
+	traceParentLength = 2 + 1 + 32 + 1 + 16 + 1 + 2
+	flagSampled       = 0x01
+)
+
+// ParseTraceParent takes the value found in a traceparent header and tries to
+// reconstruct a SpanContext. The parent-id of the header holds the span id of
+// the caller which becomes the ID of the returned SpanContext.
+func ParseTraceParent(header string) (*model.SpanContext, error) {
+	if header == "" {
+		return nil, ErrEmptyContext
+	}
+
+	// future versions may append fields, so only version 00 needs exact length
+	if len(header) < traceParentLength ||
+		(len(header) > traceParentLength && header[traceParentLength] != '-') ||
+		header[2] != '-' || header[35] != '-' || header[52] != '-' {
+		return nil, ErrInvalidTraceParent
+	}
+
+	version, err := parseHex(header[0:2])
+	if err != nil || version == 0xff {
+		return nil, ErrInvalidVersion
+	}
+	if version == 0 && len(header) != traceParentLength {
+		return nil, ErrInvalidTraceParent
+	}
+
+	var sc model.SpanContext
+
+	if sc.TraceID.High, err = parseHex(header[3:19]); err != nil {
+		return nil, ErrInvalidTraceIDValue
+	}
+	if sc.TraceID.Low, err = parseHex(header[19:35]); err != nil {
+		return nil, ErrInvalidTraceIDValue
+	}
+	if sc.TraceID.Empty() {
+		return nil, ErrInvalidTraceIDValue
+	}
+
+	id, err := parseHex(header[36:52])
+	if err != nil || id == 0 {
+		return nil, ErrInvalidParentIDValue
+	}
+	sc.ID = model.ID(id)
+
+	flags, err := parseHex(header[53:55])
+	if err != nil {
+		return nil, ErrInvalidFlagsValue
+	}
+	sampled := flags&flagSampled == flagSampled
+	sc.Sampled = &sampled
+
+	return &sc, nil
+}
+
+// BuildTraceParent takes the values from the SpanContext and builds the
+// traceparent header. As traceparent can not express a deferred sampling
+// decision, a SpanContext without sampling decision is encoded as not sampled.
+// The debug flag is encoded as sampled.
+func BuildTraceParent(sc model.SpanContext) string {
+	var flags byte
+	if sc.Debug || (sc.Sampled != nil && *sc.Sampled) {
+		flags |= flagSampled
+	}
+	return fmt.Sprintf(
+		"00-%016x%016x-%016x-%02x",
+		sc.TraceID.High, sc.TraceID.Low, uint64(sc.ID), flags,
+	)
+}
+
+// parseHex parses lowercase hex values as mandated by the specification.
+func parseHex(s string) (uint64, error) {
+	for i := 0; i < len(s); i++ {
+		if (s[i] < '0' || s[i] > '9') && (s[i] < 'a' || s[i] > 'f') {
+			return 0, strconv.ErrSyntax
+		}
+	}
+	return strconv.ParseUint(s, 16, 64)
+}
@@ -0,0 +1,101 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package w3c_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/propagation/w3c"
+)
+
+func TestParseTraceParent(t *testing.T) {
+	testCases := []struct {
+		header  string
+		traceID model.TraceID
+		id      model.ID
+		sampled bool
+		err     error
+	}{
+		{"00-000000000000007b00000000000001c8-0000000000000315-01", model.TraceID{High: 123, Low: 456}, 789, true, nil},
+		{"00-000000000000007b00000000000001c8-0000000000000315-00", model.TraceID{High: 123, Low: 456}, 789, false, nil},
+		{"01-000000000000007b00000000000001c8-0000000000000315-01-future", model.TraceID{High: 123, Low: 456}, 789, true, nil},
+		{"", model.TraceID{}, 0, false, w3c.ErrEmptyContext},
+		{"00-000000000000007b00000000000001c8-0000000000000315", model.TraceID{}, 0, false, w3c.ErrInvalidTraceParent},
+		{"00-000000000000007b00000000000001c8-0000000000000315-01-x", model.TraceID{}, 0, false, w3c.ErrInvalidTraceParent},
+		{"ff-000000000000007b00000000000001c8-0000000000000315-01", model.TraceID{}, 0, false, w3c.ErrInvalidVersion},
+		{"00-00000000000000000000000000000000-0000000000000315-01", model.TraceID{}, 0, false, w3c.ErrInvalidTraceIDValue},
+		{"00-000000000000007B00000000000001C8-0000000000000315-01", model.TraceID{}, 0, false, w3c.ErrInvalidTraceIDValue},
+		{"00-000000000000007b00000000000001c8-0000000000000000-01", model.TraceID{}, 0, false, w3c.ErrInvalidParentIDValue},
+		{"00-000000000000007b00000000000001c8-0000000000000315-0x", model.TraceID{}, 0, false, w3c.ErrInvalidFlagsValue},
+	}
+
+	for _, tc := range testCases {
+		sc, err := w3c.ParseTraceParent(tc.header)
+		if want, have := tc.err, err; want != have {
+			t.Errorf("unexpected error for header %q, want: %v, have %v", tc.header, want, have)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if want, have := tc.traceID, sc.TraceID; want != have {
+			t.Errorf("unexpected TraceID for header %q, want: %v, have %v", tc.header, want, have)
+		}
+		if want, have := tc.id, sc.ID; want != have {
+			t.Errorf("unexpected ID for header %q, want: %v, have %v", tc.header, want, have)
+		}
+		if sc.Sampled == nil || *sc.Sampled != tc.sampled {
+			t.Errorf("unexpected Sampled for header %q, want: %t, have %v", tc.header, tc.sampled, sc.Sampled)
+		}
+	}
+}
+
+func TestHTTPRoundTrip(t *testing.T) {
+	sampled := true
+	sc := model.SpanContext{
+		TraceID: model.TraceID{Low: 456},
+		ID:      model.ID(789),
+		Sampled: &sampled,
+	}
+
+	r, _ := http.NewRequest("GET", "http://localhost", nil)
+
+	if err := w3c.InjectHTTP(r)(sc); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if want, have := "00-000000000000000000000000000001c8-0000000000000315-01", r.Header.Get(w3c.TraceParent); want != have {
+		t.Errorf("unexpected header, want %q, have %q", want, have)
+	}
+
+	have, err := w3c.ExtractHTTP(r)()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if have.TraceID != sc.TraceID || have.ID != sc.ID || !*have.Sampled {
+		t.Errorf("unexpected context, want %+v, have %+v", sc, *have)
+	}
+
+	if err = w3c.InjectHTTP(r)(model.SpanContext{}); err != w3c.ErrEmptyContext {
+		t.Errorf("unexpected error, want %v, have %v", w3c.ErrEmptyContext, err)
+	}
+
+	r.Header.Del(w3c.TraceParent)
+	if sc, err := w3c.ExtractHTTP(r)(); sc != nil || err != nil {
+		t.Errorf("expected no context and no error, have %+v, %v", sc, err)
+	}
+}
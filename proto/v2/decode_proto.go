@@ -33,6 +33,7 @@ import (
 // ParseSpans parses zipkinmodel.SpanModel values from data serialized by Protobuf3.
 // debugWasSet is a boolean that toggles the Debug field of each Span. Its value
 // is usually retrieved from the transport headers when the "X-B3-Flags" header has a value of 1.
+// Spans encoded with their debug field set are flagged as Debug regardless.
 func ParseSpans(protoBlob []byte, debugWasSet bool) (zss []*zipkinmodel.SpanModel, err error) {
 	var listOfSpans ListOfSpans
 	if err := proto.Unmarshal(protoBlob, &listOfSpans); err != nil {
@@ -79,7 +80,7 @@ func protoSpanToModelSpan(s *Span, debugWasSet bool) (*zipkinmodel.SpanModel, er
 		TraceID:  traceID,
 		ID:       *spanIDPtr,
 		ParentID: parentSpanID,
-		Debug:    debugWasSet,
+		Debug:    debugWasSet || s.Debug,
 	}
 	zms := &zipkinmodel.SpanModel{
 		SpanContext:    zmsc,
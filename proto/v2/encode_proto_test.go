@@ -101,3 +101,40 @@ func TestExportSpans(t *testing.T) {
 		t.Errorf("conversion error!\nWANT:\n%s\n\nGOT:\n%s\n", w, g)
 	}
 }
+
+func TestFlagsRoundTrip(t *testing.T) {
+	var want []*zipkinmodel.SpanModel
+	for i, flags := range [][2]bool{{false, false}, {true, false}, {false, true}, {true, true}} {
+		want = append(want, &zipkinmodel.SpanModel{
+			SpanContext: zipkinmodel.SpanContext{
+				TraceID: zipkinmodel.TraceID{Low: 1},
+				ID:      zipkinmodel.ID(i + 1),
+				Debug:   flags[0],
+			},
+			Name:      "flags",
+			Timestamp: now,
+			Shared:    flags[1],
+		})
+	}
+
+	protoBlob, err := zipkin_proto3.SpanSerializer{}.Serialize(want)
+	if err != nil {
+		t.Fatalf("Failed to serialize spans: %v", err)
+	}
+
+	have, err := zipkin_proto3.ParseSpans(protoBlob, false)
+	if err != nil {
+		t.Fatalf("Failed to parse spans from protobuf blob: %v", err)
+	}
+	if len(have) != len(want) {
+		t.Fatalf("spans want %d, have %d", len(want), len(have))
+	}
+	for i := range want {
+		if want, have := want[i].Debug, have[i].Debug; want != have {
+			t.Errorf("span %d: Debug want %t, have %t", i, want, have)
+		}
+		if want, have := want[i].Shared, have[i].Shared; want != have {
+			t.Errorf("span %d: Shared want %t, have %t", i, want, have)
+		}
+	}
+}
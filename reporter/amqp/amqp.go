@@ -5,9 +5,11 @@ package amqp
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"github.com/streadway/amqp"
 
@@ -22,22 +24,35 @@ const (
 	defaultExchangeKind  = "direct"
 )
 
+// ErrUnroutable is reported to the OnDrop and OnBatchFailed callbacks for
+// spans returned by RabbitMQ because no queue is bound to their routing key.
+var ErrUnroutable = errors.New("amqp: message returned as unroutable")
+
 // rmqReporter implements Reporter by publishing spans to a RabbitMQ exchange
 type rmqReporter struct {
-	e        chan error
-	channel  *amqp.Channel
-	conn     *amqp.Connection
-	exchange string
-	queue    string
-	logger   *log.Logger
+	e            chan error
+	channel      *amqp.Channel
+	conn         *amqp.Connection
+	exchange     string
+	exchangeKind string
+	queue        string
+	bindingKey   string
+	logger       reporter.Logger
+	onDrop       func(model.SpanModel, error)
+
+	routingKey func(model.SpanModel) string
+
+	onBatchSent   func(count, bytes int, duration time.Duration)
+	onBatchFailed func(err error, count int)
 }
 
 // ReporterOption sets a parameter for the rmqReporter
 type ReporterOption func(c *rmqReporter)
 
 // Logger sets the logger used to report errors in the collection
-// process.
-func Logger(logger *log.Logger) ReporterOption {
+// process. It accepts a *log.Logger or any other reporter.Logger, e.g. one
+// returned by reporter.SlogLogger.
+func Logger(logger reporter.Logger) ReporterOption {
 	return func(c *rmqReporter) {
 		c.logger = logger
 	}
@@ -52,6 +67,23 @@ func Exchange(exchange string) ReporterOption {
 	}
 }
 
+// ExchangeKind sets the kind of the declared exchange, e.g. "topic" when
+// routing spans by patterns of routing keys computed by the RoutingKey option.
+// Default is "direct".
+func ExchangeKind(kind string) ReporterOption {
+	return func(c *rmqReporter) {
+		c.exchangeKind = kind
+	}
+}
+
+// BindingKey sets the key binding the queue to the exchange, e.g. "zipkin.#"
+// for a topic exchange. Default is "zipkin".
+func BindingKey(key string) ReporterOption {
+	return func(c *rmqReporter) {
+		c.bindingKey = key
+	}
+}
+
 // Queue sets the Queue used to send messages
 func Queue(queue string) ReporterOption {
 	return func(c *rmqReporter) {
@@ -73,13 +105,42 @@ func Connection(conn *amqp.Connection) ReporterOption {
 	}
 }
 
+// OnDrop registers a callback function which is invoked for every span the
+// reporter fails to deliver, together with the reason, e.g. on serialization
+// failures or when publishing the message fails.
+func OnDrop(fn func(span model.SpanModel, reason error)) ReporterOption {
+	return func(c *rmqReporter) {
+		c.onDrop = fn
+	}
+}
+
+// OnBatchSent registers a callback function which is invoked for every
+// published message with the number of spans, the size of the message and the
+// duration of the publish call.
+func OnBatchSent(fn func(count, bytes int, duration time.Duration)) ReporterOption {
+	return func(c *rmqReporter) {
+		c.onBatchSent = fn
+	}
+}
+
+// OnBatchFailed registers a callback function which is invoked for every
+// message the reporter fails to publish with the reason and the number of
+// spans.
+func OnBatchFailed(fn func(err error, count int)) ReporterOption {
+	return func(c *rmqReporter) {
+		c.onBatchFailed = fn
+	}
+}
+
 // NewReporter returns a new RabbitMq-backed Reporter. address should be as described here: https://www.rabbitmq.com/uri-spec.html
 func NewReporter(address string, options ...ReporterOption) (reporter.Reporter, error) {
 	r := &rmqReporter{
-		logger:   log.New(os.Stderr, "", log.LstdFlags),
-		queue:    defaultRmqRoutingKey,
-		exchange: defaultRmqExchange,
-		e:        make(chan error),
+		logger:       log.New(os.Stderr, "", log.LstdFlags),
+		queue:        defaultRmqRoutingKey,
+		exchange:     defaultRmqExchange,
+		exchangeKind: defaultExchangeKind,
+		bindingKey:   defaultRmqRoutingKey,
+		e:            make(chan error),
 	}
 
 	for _, option := range options {
@@ -116,12 +177,34 @@ func NewReporter(address string, options ...ReporterOption) (reporter.Reporter,
 
 	go r.logErrors()
 
+	if r.routingKey != nil {
+		// computed routing keys might not match any binding, have RabbitMQ
+		// return those messages instead of silently discarding them
+		go r.handleReturns(r.channel.NotifyReturn(make(chan amqp.Return, 1)))
+	}
+
 	return r, nil
 }
 
+func (r *rmqReporter) handleReturns(returns <-chan amqp.Return) {
+	for ret := range returns {
+		r.logger.Printf("span message returned by the broker: %s\n", ret.ReplyText)
+		var ss []model.SpanModel
+		_ = json.Unmarshal(ret.Body, &ss)
+		if r.onBatchFailed != nil {
+			r.onBatchFailed(ErrUnroutable, len(ss))
+		}
+		if r.onDrop != nil {
+			for _, s := range ss {
+				r.onDrop(s, ErrUnroutable)
+			}
+		}
+	}
+}
+
 func (r *rmqReporter) logErrors() {
 	for err := range r.e {
-		r.logger.Print("msg", err.Error())
+		r.logger.Printf("msg %s\n", err.Error())
 	}
 }
 
@@ -131,6 +214,12 @@ func (r *rmqReporter) Send(s model.SpanModel) {
 	m, err := json.Marshal(ss)
 	if err != nil {
 		r.e <- fmt.Errorf("failed when marshalling the span: %s\n", err.Error())
+		if r.onBatchFailed != nil {
+			r.onBatchFailed(err, len(ss))
+		}
+		if r.onDrop != nil {
+			r.onDrop(s, err)
+		}
 		return
 	}
 
@@ -138,25 +227,47 @@ func (r *rmqReporter) Send(s model.SpanModel) {
 		Body: m,
 	}
 
-	err = r.channel.Publish(defaultRmqExchange, defaultRmqRoutingKey, false, false, msg)
+	key := defaultRmqRoutingKey
+	if r.routingKey != nil {
+		if k := r.routingKey(s); k != "" {
+			key = k
+		}
+	}
+
+	start := time.Now()
+	err = r.channel.Publish(r.exchange, key, r.routingKey != nil, false, msg)
 	if err != nil {
 		r.e <- fmt.Errorf("failed when publishing the span: %s\n", err.Error())
+		if r.onBatchFailed != nil {
+			r.onBatchFailed(err, len(ss))
+		}
+		if r.onDrop != nil {
+			r.onDrop(s, err)
+		}
+		return
+	}
+	if r.onBatchSent != nil {
+		r.onBatchSent(len(ss), len(m), time.Since(start))
 	}
 }
 
+// Flush implements reporter.Flusher. Spans are published by Send, so there is
+// nothing to flush.
+func (r *rmqReporter) Flush() error { return nil }
+
 func (r *rmqReporter) queueBindVerify() error {
 	return r.channel.QueueBind(
-		defaultRmqRoutingKey,
-		defaultRmqRoutingKey,
-		defaultRmqExchange,
+		r.queue,
+		r.bindingKey,
+		r.exchange,
 		false,
 		nil)
 }
 
 func (r *rmqReporter) exchangeVerify() error {
 	err := r.channel.ExchangeDeclare(
-		defaultRmqExchange,
-		defaultExchangeKind,
+		r.exchange,
+		r.exchangeKind,
 		true,
 		false,
 		false,
@@ -173,7 +284,7 @@ func (r *rmqReporter) exchangeVerify() error {
 
 func (r *rmqReporter) queueVerify() error {
 	_, err := r.channel.QueueDeclare(
-		defaultRmqExchange,
+		r.queue,
 		true,
 		false,
 		false,
@@ -0,0 +1,68 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package amqp
+
+import (
+	"strings"
+
+	"github.com/openzipkin/zipkin-go/model"
+)
+
+// RoutingKey sets a function computing the routing key of each span from its
+// fields, enabling topical routing of spans inside RabbitMQ, e.g. using a topic
+// exchange bound to queues per service, see the ExchangeKind and BindingKey
+// options. If fn returns an empty string the default routing key is used.
+//
+// Messages are published as mandatory, spans with a routing key not matching
+// any binding are returned by RabbitMQ and reported to the OnDrop and
+// OnBatchFailed callbacks with ErrUnroutable. As returns arrive asynchronously
+// OnBatchSent has been invoked for these spans before.
+func RoutingKey(fn func(span model.SpanModel) string) ReporterOption {
+	return func(c *rmqReporter) {
+		c.routingKey = fn
+	}
+}
+
+// RoutingKeyTemplate returns a routing key function for the RoutingKey option
+// which replaces the following placeholders in template with span fields:
+//
+//	{service}  local endpoint service name
+//	{kind}     span kind in lowercase, "undetermined" if not set
+//	{name}     span name
+//	{tier}     "debug" for debug spans, "sampled" otherwise
+//
+// E.g. "zipkin.{service}.{kind}" results in "zipkin.orders.server".
+func RoutingKeyTemplate(template string) func(span model.SpanModel) string {
+	return func(span model.SpanModel) string {
+		var service string
+		if span.LocalEndpoint != nil {
+			service = span.LocalEndpoint.ServiceName
+		}
+		kind := strings.ToLower(string(span.Kind))
+		if kind == "" {
+			kind = "undetermined"
+		}
+		tier := "sampled"
+		if span.Debug {
+			tier = "debug"
+		}
+		return strings.NewReplacer(
+			"{service}", service,
+			"{kind}", kind,
+			"{name}", span.Name,
+			"{tier}", tier,
+		).Replace(template)
+	}
+}
@@ -0,0 +1,40 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package amqp_test
+
+import (
+	"testing"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter/amqp"
+)
+
+func TestRoutingKeyTemplate(t *testing.T) {
+	fn := amqp.RoutingKeyTemplate("zipkin.{service}.{kind}.{tier}.{name}")
+
+	span := model.SpanModel{
+		Name:          "get",
+		Kind:          model.Server,
+		LocalEndpoint: &model.Endpoint{ServiceName: "orders"},
+	}
+	if want, have := "zipkin.orders.server.sampled.get", fn(span); want != have {
+		t.Errorf("routing key want %q, have %q", want, have)
+	}
+
+	span = model.SpanModel{SpanContext: model.SpanContext{Debug: true}}
+	if want, have := "zipkin..undetermined.debug.", fn(span); want != have {
+		t.Errorf("routing key want %q, have %q", want, have)
+	}
+}
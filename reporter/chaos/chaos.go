@@ -0,0 +1,307 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package chaos implements fault injection for reporters, to verify services
+behave correctly, e.g. do not block and keep memory bounded, when the tracing
+backend misbehaves.
+
+NewReporter wraps a reporter, delaying and dropping spans passed to Send.
+NewTransport wraps the http.RoundTripper of the HTTP reporter, delaying and
+failing requests to the collector and dropping part of the spans of batches:
+
+	rt := chaos.NewTransport(http.DefaultTransport,
+		chaos.Latency(100*time.Millisecond, 2*time.Second),
+		chaos.FailureRate(0.3),
+	)
+	rep := zipkinhttp.NewReporter(url, zipkinhttp.Client(&http.Client{Transport: rt}))
+
+Faults are injected at random using the configured rates, fault injection is
+never meant for production use.
+*/
+package chaos
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter"
+)
+
+// ErrInjected is the error of injected failures.
+var ErrInjected = errors.New("chaos: injected failure")
+
+// faults holds the faults to inject.
+type faults struct {
+	minLatency, maxLatency time.Duration
+	failureRate            float64
+	failureStatus          int
+	partialRate            float64
+	onFault                func(err error)
+
+	mtx sync.Mutex
+	rnd *rand.Rand
+}
+
+// Option configures the faults to inject.
+type Option func(f *faults)
+
+// Latency delays every span passed to Send, or every request, by a random
+// duration between min and max.
+func Latency(min, max time.Duration) Option {
+	return func(f *faults) {
+		if max < min {
+			max = min
+		}
+		f.minLatency, f.maxLatency = min, max
+	}
+}
+
+// FailureRate sets the share of spans passed to Send which are dropped, or the
+// share of requests which fail, between 0 and 1.
+func FailureRate(rate float64) Option {
+	return func(f *faults) { f.failureRate = rate }
+}
+
+// FailureStatus makes failing requests return a response with statusCode
+// instead of failing with ErrInjected, e.g. http.StatusServiceUnavailable or
+// http.StatusTooManyRequests. It only applies to the transport.
+func FailureStatus(statusCode int) Option {
+	return func(f *faults) { f.failureStatus = statusCode }
+}
+
+// PartialFailureRate sets the share of spans of a batch which are silently
+// dropped from requests forwarded to the collector, between 0 and 1,
+// simulating collectors accepting batches they only partially ingest. It only
+// applies to the transport and to JSON encoded batches, optionally gzip
+// compressed.
+func PartialFailureRate(rate float64) Option {
+	return func(f *faults) { f.partialRate = rate }
+}
+
+// OnFault registers a callback function which is invoked with ErrInjected for
+// every span dropped and failed request and for every batch partially dropped.
+func OnFault(fn func(err error)) Option {
+	return func(f *faults) { f.onFault = fn }
+}
+
+// Seed sets the seed of the random faults, for reproducible test runs.
+func Seed(seed int64) Option {
+	return func(f *faults) { f.rnd = rand.New(rand.NewSource(seed)) }
+}
+
+func newFaults(options []Option) *faults {
+	f := &faults{rnd: rand.New(rand.NewSource(time.Now().UnixNano()))}
+	for _, option := range options {
+		option(f)
+	}
+	return f
+}
+
+// chance returns true with probability rate.
+func (f *faults) chance(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	return f.rnd.Float64() < rate
+}
+
+// latency returns the delay to inject.
+func (f *faults) latency() time.Duration {
+	if f.maxLatency <= 0 {
+		return 0
+	}
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	return f.minLatency + time.Duration(f.rnd.Int63n(int64(f.maxLatency-f.minLatency)+1))
+}
+
+func (f *faults) fault() {
+	if f.onFault != nil {
+		f.onFault(ErrInjected)
+	}
+}
+
+// chaosReporter injects faults before passing spans to the next reporter.
+type chaosReporter struct {
+	next   reporter.Reporter
+	faults *faults
+}
+
+// NewReporter returns a Reporter delaying and dropping spans before sending
+// them to next. Latency is injected synchronously in Send, simulating
+// reporters blocking their callers.
+func NewReporter(next reporter.Reporter, options ...Option) reporter.Reporter {
+	return &chaosReporter{next: next, faults: newFaults(options)}
+}
+
+// Send sends s to the next reporter unless it is dropped.
+func (r *chaosReporter) Send(s model.SpanModel) {
+	if d := r.faults.latency(); d > 0 {
+		time.Sleep(d)
+	}
+	if r.faults.chance(r.faults.failureRate) {
+		r.faults.fault()
+		return
+	}
+	r.next.Send(s)
+}
+
+// Close closes the next reporter.
+func (r *chaosReporter) Close() error {
+	return r.next.Close()
+}
+
+// transport injects faults into requests to the collector.
+type transport struct {
+	rt     http.RoundTripper
+	faults *faults
+}
+
+// NewTransport returns a http.RoundTripper delaying, failing and partially
+// dropping requests before passing them to rt, or http.DefaultTransport if
+// nil.
+func NewTransport(rt http.RoundTripper, options ...Option) http.RoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	return &transport{rt: rt, faults: newFaults(options)}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if d := t.faults.latency(); d > 0 {
+		timer := time.NewTimer(d)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			closeBody(req)
+			return nil, req.Context().Err()
+		}
+	}
+
+	if t.faults.chance(t.faults.failureRate) {
+		t.faults.fault()
+		closeBody(req)
+		if t.faults.failureStatus == 0 {
+			return nil, ErrInjected
+		}
+		return &http.Response{
+			Status:     strconv.Itoa(t.faults.failureStatus) + " " + http.StatusText(t.faults.failureStatus),
+			StatusCode: t.faults.failureStatus,
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     make(http.Header),
+			Body:       ioutil.NopCloser(strings.NewReader("")),
+			Request:    req,
+		}, nil
+	}
+
+	if t.faults.partialRate > 0 && req.Body != nil &&
+		strings.HasPrefix(req.Header.Get("Content-Type"), "application/json") {
+		var err error
+		if req, err = t.dropPartial(req); err != nil {
+			return nil, err
+		}
+	}
+	return t.rt.RoundTrip(req)
+}
+
+// dropPartial returns a copy of req with part of the spans of its batch
+// dropped.
+func (t *transport) dropPartial(req *http.Request) (*http.Request, error) {
+	body, err := ioutil.ReadAll(req.Body)
+	closeBody(req)
+	if err != nil {
+		return nil, err
+	}
+	gzipped := req.Header.Get("Content-Encoding") == "gzip"
+	if gzipped {
+		if body, err = gunzip(body); err != nil {
+			return nil, err
+		}
+	}
+
+	var spans []json.RawMessage
+	if err := json.Unmarshal(body, &spans); err != nil {
+		return nil, err
+	}
+	kept := spans[:0]
+	for _, span := range spans {
+		if !t.faults.chance(t.faults.partialRate) {
+			kept = append(kept, span)
+		}
+	}
+	if len(kept) < len(spans) {
+		t.faults.fault()
+	}
+	if body, err = json.Marshal(kept); err != nil {
+		return nil, err
+	}
+	if gzipped {
+		if body, err = gzipBytes(body); err != nil {
+			return nil, err
+		}
+	}
+
+	req = req.WithContext(req.Context())
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(body)), nil
+	}
+	return req, nil
+}
+
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		_ = req.Body.Close()
+	}
+}
+
+func gunzip(b []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+func gzipBytes(b []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(b); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,152 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chaos_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter/chaos"
+	zipkinhttp "github.com/openzipkin/zipkin-go/reporter/http"
+	"github.com/openzipkin/zipkin-go/reporter/recorder"
+	"github.com/openzipkin/zipkin-go/zipkintest"
+)
+
+func TestReporterFaults(t *testing.T) {
+	var faults int32
+	rec := recorder.NewReporter()
+	rep := chaos.NewReporter(rec,
+		chaos.FailureRate(0.5),
+		chaos.Latency(time.Millisecond, 2*time.Millisecond),
+		chaos.Seed(1),
+		chaos.OnFault(func(err error) {
+			if err != chaos.ErrInjected {
+				t.Errorf("fault error want %v, have %v", chaos.ErrInjected, err)
+			}
+			atomic.AddInt32(&faults, 1)
+		}),
+	)
+
+	const n = 100
+	start := time.Now()
+	for i := 0; i < n; i++ {
+		rep.Send(model.SpanModel{
+			SpanContext: model.SpanContext{TraceID: model.TraceID{Low: 1}, ID: model.ID(i + 1)},
+			Name:        "name",
+			Timestamp:   time.Now(),
+		})
+	}
+	if elapsed := time.Since(start); elapsed < n*time.Millisecond {
+		t.Errorf("expected latency of at least %s, have %s", n*time.Millisecond, elapsed)
+	}
+
+	sent := len(rec.Flush())
+	if sent == 0 || sent == n {
+		t.Errorf("expected part of the spans to be dropped, have %d sent", sent)
+	}
+	if want, have := n, sent+int(atomic.LoadInt32(&faults)); want != have {
+		t.Errorf("sent and dropped spans want %d, have %d", want, have)
+	}
+	if err := rep.Close(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestTransportFailures(t *testing.T) {
+	c := zipkintest.NewCollector()
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	client := &http.Client{Transport: chaos.NewTransport(nil, chaos.FailureRate(1))}
+	if _, err := client.Post(srv.URL, "application/json", bytes.NewBufferString("[]")); err == nil {
+		t.Error("expected injected error")
+	}
+
+	client = &http.Client{Transport: chaos.NewTransport(nil,
+		chaos.FailureRate(1), chaos.FailureStatus(http.StatusServiceUnavailable),
+	)}
+	res, err := client.Post(srv.URL, "application/json", bytes.NewBufferString("[]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if want, have := http.StatusServiceUnavailable, res.StatusCode; want != have {
+		t.Errorf("status code want %d, have %d", want, have)
+	}
+	c.AssertRequests(t, 0)
+}
+
+func TestTransportPartialFailure(t *testing.T) {
+	c := zipkintest.NewCollector()
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	spans := make([]model.SpanModel, 100)
+	for i := range spans {
+		spans[i] = model.SpanModel{
+			SpanContext: model.SpanContext{TraceID: model.TraceID{Low: 1}, ID: model.ID(i + 1)},
+			Name:        "name",
+			Timestamp:   time.Now(),
+		}
+	}
+	b, _ := json.Marshal(spans)
+
+	client := &http.Client{Transport: chaos.NewTransport(nil, chaos.PartialFailureRate(0.5), chaos.Seed(1))}
+	res, err := client.Post(srv.URL, "application/json", bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if want, have := http.StatusAccepted, res.StatusCode; want != have {
+		t.Errorf("status code want %d, have %d", want, have)
+	}
+
+	received := len(c.Spans())
+	if received == 0 || received == len(spans) {
+		t.Errorf("expected part of the spans to be dropped, have %d received", received)
+	}
+}
+
+func TestTransportLatencyDoesNotBlockReporter(t *testing.T) {
+	c := zipkintest.NewCollector()
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	rt := chaos.NewTransport(nil, chaos.Latency(200*time.Millisecond, 200*time.Millisecond))
+	rep := zipkinhttp.NewReporter(srv.URL,
+		zipkinhttp.Client(&http.Client{Transport: rt}),
+		zipkinhttp.BatchSize(1),
+		zipkinhttp.MaxBacklog(10),
+	)
+	defer rep.Close()
+
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		rep.Send(model.SpanModel{
+			SpanContext: model.SpanContext{TraceID: model.TraceID{Low: 1}, ID: model.ID(i + 1)},
+			Name:        "name",
+			Timestamp:   time.Now(),
+		})
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("expected Send not to block on collector latency, took %s", elapsed)
+	}
+}
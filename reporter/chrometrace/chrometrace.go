@@ -0,0 +1,225 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package chrometrace converts spans to the Chrome trace event format, so the
+spans of a single process can be inspected in chrome://tracing or the Perfetto
+UI without a Zipkin server.
+
+Spans are written as complete events and annotations as instant events. Every
+local service is shown as a process and spans are laid out on as few threads
+per service as possible while keeping the events of a thread properly nested.
+The SpanSerializer encodes a set of spans, while the Reporter collects all
+spans in memory and writes them as a single trace file when closed:
+
+	f, _ := os.Create("trace.json")
+	rep := chrometrace.NewReporter(f)
+	defer rep.Close()
+*/
+package chrometrace
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter"
+	"github.com/openzipkin/zipkin-go/reporter/internal/lanes"
+)
+
+// SpanSerializer implements reporter.SpanSerializer producing a trace event
+// JSON object.
+type SpanSerializer struct{}
+
+// event holds a trace event.
+type event struct {
+	Name  string                 `json:"name"`
+	Cat   string                 `json:"cat,omitempty"`
+	Ph    string                 `json:"ph"`
+	Ts    float64                `json:"ts"`
+	Dur   *float64               `json:"dur,omitempty"`
+	Pid   int                    `json:"pid"`
+	Tid   int                    `json:"tid"`
+	Scope string                 `json:"s,omitempty"`
+	Args  map[string]interface{} `json:"args,omitempty"`
+}
+
+// traceFile holds the JSON object format of the trace event format.
+type traceFile struct {
+	TraceEvents     []event `json:"traceEvents"`
+	DisplayTimeUnit string  `json:"displayTimeUnit"`
+}
+
+// Serialize returns the spans as trace event JSON object.
+func (SpanSerializer) Serialize(spans []*model.SpanModel) ([]byte, error) {
+	return json.Marshal(traceFile{
+		TraceEvents:     convert(spans),
+		DisplayTimeUnit: "ms",
+	})
+}
+
+// ContentType returns the ContentType needed for this encoding.
+func (SpanSerializer) ContentType() string {
+	return "application/json"
+}
+
+// convert returns the trace events of the spans.
+func convert(spans []*model.SpanModel) []event {
+	// spans sorted by start time with enclosing spans first
+	sorted := make([]*model.SpanModel, len(spans))
+	copy(sorted, spans)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if !sorted[i].Timestamp.Equal(sorted[j].Timestamp) {
+			return sorted[i].Timestamp.Before(sorted[j].Timestamp)
+		}
+		return sorted[i].Duration > sorted[j].Duration
+	})
+
+	var (
+		events   = make([]event, 0, len(spans))
+		services = serviceIDs(sorted)
+		layouts  = make(map[int]*lanes.Set)
+	)
+	for name, pid := range services {
+		events = append(events, event{
+			Name: "process_name",
+			Ph:   "M",
+			Pid:  pid,
+			Args: map[string]interface{}{"name": name},
+		})
+	}
+	// sort metadata events for a stable output
+	sort.Slice(events, func(i, j int) bool { return events[i].Pid < events[j].Pid })
+
+	for _, s := range sorted {
+		pid := services[serviceName(s)]
+		if layouts[pid] == nil {
+			layouts[pid] = &lanes.Set{}
+		}
+		start, end := s.Timestamp.UnixNano(), s.Timestamp.Add(s.Duration).UnixNano()
+		tid := layouts[pid].Place(start, end)
+		dur := micros(end - start)
+
+		events = append(events, event{
+			Name: s.Name,
+			Cat:  string(s.Kind),
+			Ph:   "X",
+			Ts:   micros(start),
+			Dur:  &dur,
+			Pid:  pid,
+			Tid:  tid,
+			Args: args(s),
+		})
+		for _, a := range s.Annotations {
+			events = append(events, event{
+				Name:  a.Value,
+				Ph:    "i",
+				Ts:    micros(a.Timestamp.UnixNano()),
+				Pid:   pid,
+				Tid:   tid,
+				Scope: "t",
+			})
+		}
+	}
+	return events
+}
+
+// serviceIDs returns the process ids of the local services, assigned in order
+// of the service names.
+func serviceIDs(spans []*model.SpanModel) map[string]int {
+	var names []string
+	ids := make(map[string]int)
+	for _, s := range spans {
+		name := serviceName(s)
+		if _, ok := ids[name]; !ok {
+			ids[name] = 0
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		ids[name] = i + 1
+	}
+	return ids
+}
+
+func serviceName(s *model.SpanModel) string {
+	if s.LocalEndpoint == nil || s.LocalEndpoint.ServiceName == "" {
+		return "unknown"
+	}
+	return s.LocalEndpoint.ServiceName
+}
+
+func args(s *model.SpanModel) map[string]interface{} {
+	a := map[string]interface{}{
+		"traceId": s.TraceID.String(),
+		"spanId":  s.ID.String(),
+	}
+	if s.ParentID != nil {
+		a["parentId"] = s.ParentID.String()
+	}
+	if s.RemoteEndpoint != nil && s.RemoteEndpoint.ServiceName != "" {
+		a["remoteServiceName"] = s.RemoteEndpoint.ServiceName
+	}
+	for k, v := range s.Tags {
+		a[k] = v
+	}
+	return a
+}
+
+func micros(ns int64) float64 {
+	return float64(ns) / 1e3
+}
+
+// chromeReporter collects spans and writes them as trace file on Close.
+type chromeReporter struct {
+	mtx    sync.Mutex
+	w      io.Writer
+	spans  []*model.SpanModel
+	closed bool
+}
+
+// NewReporter returns a Reporter collecting all spans in memory and writing
+// them to w as a trace event JSON file when closed.
+func NewReporter(w io.Writer) reporter.Reporter {
+	return &chromeReporter{w: w}
+}
+
+// Send collects the span.
+func (r *chromeReporter) Send(s model.SpanModel) {
+	r.mtx.Lock()
+	if !r.closed {
+		r.spans = append(r.spans, &s)
+	}
+	r.mtx.Unlock()
+}
+
+// Close writes the collected spans. Spans sent after closing are discarded.
+func (r *chromeReporter) Close() error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	b, err := SpanSerializer{}.Serialize(r.spans)
+	if err == nil {
+		_, err = r.w.Write(b)
+	}
+	r.spans = nil
+	return err
+}
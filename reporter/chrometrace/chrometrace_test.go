@@ -0,0 +1,173 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chrometrace_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter/chrometrace"
+)
+
+type event struct {
+	Name string                 `json:"name"`
+	Cat  string                 `json:"cat"`
+	Ph   string                 `json:"ph"`
+	Ts   float64                `json:"ts"`
+	Dur  float64                `json:"dur"`
+	Pid  int                    `json:"pid"`
+	Tid  int                    `json:"tid"`
+	S    string                 `json:"s"`
+	Args map[string]interface{} `json:"args"`
+}
+
+func makeSpans() []*model.SpanModel {
+	var (
+		start    = time.Unix(1000, 0)
+		parentID = model.ID(1)
+		frontend = &model.Endpoint{ServiceName: "frontend"}
+		backend  = &model.Endpoint{ServiceName: "backend"}
+	)
+	span := func(id model.ID, name string, ep *model.Endpoint, offset, duration time.Duration) *model.SpanModel {
+		s := &model.SpanModel{
+			SpanContext:   model.SpanContext{TraceID: model.TraceID{Low: 1}, ID: id},
+			Name:          name,
+			Kind:          model.Server,
+			LocalEndpoint: ep,
+			Timestamp:     start.Add(offset),
+			Duration:      duration,
+			Tags:          map[string]string{"k": "v"},
+		}
+		if id != parentID {
+			s.ParentID = &parentID
+			s.Kind = model.Client
+		}
+		return s
+	}
+	spans := []*model.SpanModel{
+		span(2, "child-1", frontend, 10*time.Millisecond, 50*time.Millisecond),
+		span(1, "root", frontend, 0, 100*time.Millisecond),
+		// overlaps with child-1 without being nested
+		span(3, "child-2", frontend, 40*time.Millisecond, 50*time.Millisecond),
+		span(4, "backend", backend, 15*time.Millisecond, 10*time.Millisecond),
+	}
+	spans[1].Annotations = []model.Annotation{{Timestamp: start.Add(5 * time.Millisecond), Value: "ready"}}
+	return spans
+}
+
+func decode(t *testing.T, b []byte) map[string]event {
+	var file struct {
+		TraceEvents     []event `json:"traceEvents"`
+		DisplayTimeUnit string  `json:"displayTimeUnit"`
+	}
+	if err := json.Unmarshal(b, &file); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want, have := "ms", file.DisplayTimeUnit; want != have {
+		t.Errorf("display time unit want %s, have %s", want, have)
+	}
+	events := make(map[string]event)
+	for _, e := range file.TraceEvents {
+		if e.Ph == "M" {
+			events["process:"+e.Args["name"].(string)] = e
+			continue
+		}
+		events[e.Name] = e
+	}
+	return events
+}
+
+func TestSerialize(t *testing.T) {
+	b, err := chrometrace.SpanSerializer{}.Serialize(makeSpans())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	events := decode(t, b)
+
+	if want, have := 7, len(events); want != have {
+		t.Fatalf("events want %d, have %d: %+v", want, have, events)
+	}
+	// processes are numbered in order of the service names
+	if want, have := 1, events["process:backend"].Pid; want != have {
+		t.Errorf("backend pid want %d, have %d", want, have)
+	}
+	if want, have := 2, events["process:frontend"].Pid; want != have {
+		t.Errorf("frontend pid want %d, have %d", want, have)
+	}
+
+	root := events["root"]
+	if want, have := "X", root.Ph; want != have {
+		t.Errorf("phase want %s, have %s", want, have)
+	}
+	if want, have := float64(1000e6), root.Ts; want != have {
+		t.Errorf("timestamp want %f, have %f", want, have)
+	}
+	if want, have := float64(100e3), root.Dur; want != have {
+		t.Errorf("duration want %f, have %f", want, have)
+	}
+	if want, have := "SERVER", root.Cat; want != have {
+		t.Errorf("category want %s, have %s", want, have)
+	}
+	if want, have := "0000000000000001", root.Args["spanId"]; want != have {
+		t.Errorf("span id want %s, have %v", want, have)
+	}
+	if want, have := "v", root.Args["k"]; want != have {
+		t.Errorf("tag want %s, have %v", want, have)
+	}
+
+	// nested spans share the thread, overlapping spans do not
+	if want, have := root.Tid, events["child-1"].Tid; want != have {
+		t.Errorf("child-1 tid want %d, have %d", want, have)
+	}
+	if have := events["child-2"].Tid; have == root.Tid {
+		t.Errorf("expected child-2 on a different thread than %d", root.Tid)
+	}
+	if want, have := 1, events["backend"].Tid; want != have {
+		t.Errorf("backend tid want %d, have %d", want, have)
+	}
+
+	ready := events["ready"]
+	if ready.Ph != "i" || ready.S != "t" || ready.Tid != root.Tid || ready.Ts != 1000005e3 {
+		t.Errorf("unexpected annotation event %+v", ready)
+	}
+}
+
+func TestReporter(t *testing.T) {
+	var buf bytes.Buffer
+	rep := chrometrace.NewReporter(&buf)
+	for _, s := range makeSpans() {
+		rep.Send(*s)
+	}
+	if want, have := 0, buf.Len(); want != have {
+		t.Fatalf("expected nothing written before close, have %d bytes", have)
+	}
+
+	if err := rep.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want, have := 7, len(decode(t, buf.Bytes())); want != have {
+		t.Errorf("events want %d, have %d", want, have)
+	}
+
+	// spans sent after close are discarded
+	rep.Send(*makeSpans()[0])
+	n := buf.Len()
+	if err := rep.Close(); err != nil || buf.Len() != n {
+		t.Errorf("expected second close to be a noop, have %v", err)
+	}
+}
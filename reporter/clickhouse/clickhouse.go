@@ -0,0 +1,362 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clickhouse
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter"
+)
+
+// defaults
+const (
+	defaultTable         = "zipkin_spans"
+	defaultBatchInterval = time.Second * 1
+	defaultBatchSize     = 10000
+)
+
+// Configuration errors returned by NewReporter.
+var (
+	// ErrNoColumns is returned when the reporter is configured without columns.
+	ErrNoColumns = errors.New("at least one column is required")
+	// ErrInvalidTable is returned when the table name is not a valid
+	// identifier.
+	ErrInvalidTable = errors.New("invalid table name")
+	// ErrInvalidColumn is returned when a column name is not a valid
+	// identifier.
+	ErrInvalidColumn = errors.New("invalid column name")
+)
+
+// validIdentifier matches plain identifiers optionally qualified with dots,
+// e.g. database qualified tables or nested columns.
+var validIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*$`)
+
+// Field identifies the span data stored in a column.
+type Field int
+
+// Available span fields.
+const (
+	// FieldTraceID holds the hex encoded trace id.
+	FieldTraceID Field = iota
+	// FieldID holds the hex encoded span id.
+	FieldID
+	// FieldParentID holds the hex encoded parent span id or an empty string.
+	FieldParentID
+	// FieldName holds the span name.
+	FieldName
+	// FieldKind holds the span kind.
+	FieldKind
+	// FieldLocalService holds the local endpoint service name.
+	FieldLocalService
+	// FieldRemoteService holds the remote endpoint service name.
+	FieldRemoteService
+	// FieldTimestamp holds the span timestamp as time.Time.
+	FieldTimestamp
+	// FieldTimestampMicros holds the span timestamp in epoch microseconds.
+	FieldTimestampMicros
+	// FieldDuration holds the span duration in microseconds.
+	FieldDuration
+	// FieldTags holds the span tags as map[string]string.
+	FieldTags
+	// FieldTagKeys holds the sorted span tag keys, e.g. for Nested columns.
+	FieldTagKeys
+	// FieldTagValues holds the span tag values in the order of FieldTagKeys.
+	FieldTagValues
+	// FieldAnnotations holds the JSON encoded span annotations.
+	FieldAnnotations
+	// FieldSpan holds the JSON V2 encoded span.
+	FieldSpan
+)
+
+// Column maps a table column to the span field it holds.
+type Column struct {
+	Name  string
+	Field Field
+}
+
+// DefaultColumns holds the default column layout.
+var DefaultColumns = []Column{
+	{"trace_id", FieldTraceID},
+	{"span_id", FieldID},
+	{"parent_id", FieldParentID},
+	{"name", FieldName},
+	{"kind", FieldKind},
+	{"service_name", FieldLocalService},
+	{"remote_service_name", FieldRemoteService},
+	{"timestamp", FieldTimestamp},
+	{"duration", FieldDuration},
+	{"tags", FieldTags},
+	{"annotations", FieldAnnotations},
+}
+
+// clickhouseReporter inserts spans into a ClickHouse table.
+type clickhouseReporter struct {
+	db            *sql.DB
+	table         string
+	columns       []Column
+	insert        string
+	logger        *log.Logger
+	batchInterval time.Duration
+	batchSize     int
+	spanC         chan *model.SpanModel
+	quit          chan struct{}
+	shutdown      chan error
+	closeOnce     sync.Once
+	closeErr      error
+}
+
+// ReporterOption sets a parameter for the ClickHouse Reporter
+type ReporterOption func(r *clickhouseReporter)
+
+// Table sets the name of the table to insert spans into, optionally qualified
+// with the database name. The default table name is "zipkin_spans".
+func Table(name string) ReporterOption {
+	return func(r *clickhouseReporter) { r.table = name }
+}
+
+// Columns sets the column layout of the table. The default layout is
+// DefaultColumns.
+func Columns(columns ...Column) ReporterOption {
+	return func(r *clickhouseReporter) { r.columns = columns }
+}
+
+// BatchSize sets the maximum number of spans inserted within a single
+// batch. The default batch size is 10000 spans.
+func BatchSize(n int) ReporterOption {
+	return func(r *clickhouseReporter) { r.batchSize = n }
+}
+
+// BatchInterval sets the maximum duration we will buffer spans before
+// inserting them. The default batch interval is 1 second.
+func BatchInterval(d time.Duration) ReporterOption {
+	return func(r *clickhouseReporter) { r.batchInterval = d }
+}
+
+// Logger sets the logger used to report errors in the collection
+// process.
+func Logger(l *log.Logger) ReporterOption {
+	return func(r *clickhouseReporter) { r.logger = l }
+}
+
+// NewReporter returns a new ClickHouse Reporter inserting spans using db.
+func NewReporter(db *sql.DB, opts ...ReporterOption) (reporter.Reporter, error) {
+	r := &clickhouseReporter{
+		db:            db,
+		table:         defaultTable,
+		columns:       DefaultColumns,
+		logger:        log.New(os.Stderr, "", log.LstdFlags),
+		batchInterval: defaultBatchInterval,
+		batchSize:     defaultBatchSize,
+		quit:          make(chan struct{}),
+		shutdown:      make(chan error, 1),
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	if !validIdentifier.MatchString(r.table) {
+		return nil, ErrInvalidTable
+	}
+	if len(r.columns) == 0 {
+		return nil, ErrNoColumns
+	}
+
+	names := make([]string, len(r.columns))
+	for i, c := range r.columns {
+		if !validIdentifier.MatchString(c.Name) {
+			return nil, ErrInvalidColumn
+		}
+		names[i] = c.Name
+	}
+	r.insert = fmt.Sprintf(
+		"INSERT INTO %s (%s) VALUES (%s)",
+		r.table,
+		strings.Join(names, ", "),
+		strings.TrimSuffix(strings.Repeat("?, ", len(names)), ", "),
+	)
+
+	r.spanC = make(chan *model.SpanModel, r.batchSize)
+
+	go r.loop()
+
+	return r, nil
+}
+
+// Send implements reporter
+func (r *clickhouseReporter) Send(s model.SpanModel) {
+	r.spanC <- &s
+}
+
+// Close implements reporter
+func (r *clickhouseReporter) Close() error {
+	r.closeOnce.Do(func() {
+		close(r.quit)
+		r.closeErr = <-r.shutdown
+	})
+	return r.closeErr
+}
+
+func (r *clickhouseReporter) loop() {
+	var (
+		batch  = make([]*model.SpanModel, 0, r.batchSize)
+		ticker = time.NewTicker(r.batchInterval)
+	)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case span := <-r.spanC:
+			batch = append(batch, span)
+			if len(batch) >= r.batchSize {
+				_ = r.send(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			_ = r.send(batch)
+			batch = batch[:0]
+		case <-r.quit:
+			// drain spans sent before Close was called
+			for {
+				select {
+				case span := <-r.spanC:
+					batch = append(batch, span)
+				default:
+					r.shutdown <- r.send(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+func (r *clickhouseReporter) send(batch []*model.SpanModel) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	err := r.sendBatch(batch)
+	if err != nil {
+		r.logger.Printf("failed to insert %d spans: %s\n", len(batch), err.Error())
+	}
+	return err
+}
+
+func (r *clickhouseReporter) sendBatch(batch []*model.SpanModel) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare(r.insert)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+
+	args := make([]interface{}, len(r.columns))
+	for _, span := range batch {
+		for i, c := range r.columns {
+			args[i] = value(span, c.Field)
+		}
+		if _, err = stmt.Exec(args...); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// value returns the column value of a span field. Absent string fields are
+// returned as empty strings as ClickHouse columns are not nullable by default.
+func value(span *model.SpanModel, field Field) interface{} {
+	switch field {
+	case FieldTraceID:
+		return span.TraceID.String()
+	case FieldID:
+		return span.ID.String()
+	case FieldParentID:
+		if span.ParentID == nil {
+			return ""
+		}
+		return span.ParentID.String()
+	case FieldName:
+		return span.Name
+	case FieldKind:
+		return string(span.Kind)
+	case FieldLocalService:
+		if span.LocalEndpoint == nil {
+			return ""
+		}
+		return span.LocalEndpoint.ServiceName
+	case FieldRemoteService:
+		if span.RemoteEndpoint == nil {
+			return ""
+		}
+		return span.RemoteEndpoint.ServiceName
+	case FieldTimestamp:
+		return span.Timestamp
+	case FieldTimestampMicros:
+		if span.Timestamp.IsZero() {
+			return int64(0)
+		}
+		return span.Timestamp.UnixNano() / 1e3
+	case FieldDuration:
+		return span.Duration.Nanoseconds() / 1e3
+	case FieldTags:
+		tags := span.Tags
+		if tags == nil {
+			tags = map[string]string{}
+		}
+		return tags
+	case FieldTagKeys, FieldTagValues:
+		keys := make([]string, 0, len(span.Tags))
+		for k := range span.Tags {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		if field == FieldTagKeys {
+			return keys
+		}
+		values := make([]string, len(keys))
+		for i, k := range keys {
+			values[i] = span.Tags[k]
+		}
+		return values
+	case FieldAnnotations:
+		if len(span.Annotations) == 0 {
+			return "[]"
+		}
+		b, _ := json.Marshal(span.Annotations)
+		return string(b)
+	case FieldSpan:
+		b, _ := json.Marshal(span)
+		return string(b)
+	}
+	return nil
+}
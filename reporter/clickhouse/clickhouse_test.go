@@ -0,0 +1,210 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clickhouse_test
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter/clickhouse"
+)
+
+// fakeDriver is a minimal database/sql driver recording prepared statements,
+// inserted rows and committed transactions.
+type fakeDriver struct {
+	mtx     sync.Mutex
+	prepare []string
+	rows    [][]driver.Value
+	commits int
+}
+
+func (d *fakeDriver) Open(string) (driver.Conn, error) { return &fakeConn{d: d}, nil }
+
+type fakeConn struct{ d *fakeDriver }
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	c.d.mtx.Lock()
+	c.d.prepare = append(c.d.prepare, query)
+	c.d.mtx.Unlock()
+	return &fakeStmt{d: c.d}, nil
+}
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return c, nil }
+func (c *fakeConn) Rollback() error           { return nil }
+func (c *fakeConn) Commit() error {
+	c.d.mtx.Lock()
+	c.d.commits++
+	c.d.mtx.Unlock()
+	return nil
+}
+
+// CheckNamedValue accepts maps and slices like ClickHouse drivers do.
+func (c *fakeConn) CheckNamedValue(*driver.NamedValue) error { return nil }
+
+type fakeStmt struct{ d *fakeDriver }
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.d.mtx.Lock()
+	s.d.rows = append(s.d.rows, args)
+	s.d.mtx.Unlock()
+	return driver.RowsAffected(1), nil
+}
+func (s *fakeStmt) Query([]driver.Value) (driver.Rows, error) { return nil, driver.ErrSkip }
+
+var driverCount int
+
+func openDB(t *testing.T) (*sql.DB, *fakeDriver) {
+	d := &fakeDriver{}
+	driverCount++
+	name := "fake-clickhouse-" + string(rune('a'+driverCount))
+	sql.Register(name, d)
+	db, err := sql.Open(name, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	return db, d
+}
+
+func makeSpan(id model.ID) model.SpanModel {
+	return model.SpanModel{
+		SpanContext: model.SpanContext{
+			TraceID: model.TraceID{Low: 1},
+			ID:      id,
+		},
+		Name:           "get",
+		Kind:           model.Client,
+		Timestamp:      time.Unix(1500000000, 0),
+		Duration:       3 * time.Millisecond,
+		LocalEndpoint:  &model.Endpoint{ServiceName: "frontend"},
+		RemoteEndpoint: &model.Endpoint{ServiceName: "backend"},
+		Tags:           map[string]string{"b": "2", "a": "1"},
+	}
+}
+
+func TestClickHouseReporter(t *testing.T) {
+	db, d := openDB(t)
+
+	rep, err := clickhouse.NewReporter(db, clickhouse.BatchSize(2), clickhouse.BatchInterval(time.Hour))
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+
+	for i := 1; i <= 3; i++ {
+		rep.Send(makeSpan(model.ID(i)))
+	}
+
+	if err = rep.Close(); err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	// closing again must not block
+	if err = rep.Close(); err != nil {
+		t.Fatalf("unexpected error on second close: %+v", err)
+	}
+
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	if want, have := 2, d.commits; want != have {
+		t.Errorf("commits want %d, have %d", want, have)
+	}
+
+	if want, have := "INSERT INTO zipkin_spans (trace_id, span_id, parent_id, name, kind, "+
+		"service_name, remote_service_name, timestamp, duration, tags, annotations) "+
+		"VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", d.prepare[0]; want != have {
+		t.Errorf("insert statement want %q, have %q", want, have)
+	}
+
+	if want, have := 3, len(d.rows); want != have {
+		t.Fatalf("rows want %d, have %d", want, have)
+	}
+
+	want := []driver.Value{
+		"0000000000000001", "0000000000000001", "", "get", "CLIENT", "frontend",
+		"backend", time.Unix(1500000000, 0), int64(3000),
+		map[string]string{"a": "1", "b": "2"}, "[]",
+	}
+	if have := d.rows[0]; !reflect.DeepEqual(want, have) {
+		t.Errorf("row want %+v, have %+v", want, have)
+	}
+}
+
+func TestClickHouseColumns(t *testing.T) {
+	db, d := openDB(t)
+
+	rep, err := clickhouse.NewReporter(
+		db,
+		clickhouse.Table("traces"),
+		clickhouse.Columns(
+			clickhouse.Column{Name: "traceId", Field: clickhouse.FieldTraceID},
+			clickhouse.Column{Name: "tags.key", Field: clickhouse.FieldTagKeys},
+			clickhouse.Column{Name: "tags.value", Field: clickhouse.FieldTagValues},
+		),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+
+	rep.Send(makeSpan(1))
+
+	if err = rep.Close(); err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	if want, have := "INSERT INTO traces (traceId, tags.key, tags.value) VALUES (?, ?, ?)", d.prepare[0]; want != have {
+		t.Errorf("insert statement want %q, have %q", want, have)
+	}
+
+	want := []driver.Value{"0000000000000001", []string{"a", "b"}, []string{"1", "2"}}
+	if have := d.rows[0]; !reflect.DeepEqual(want, have) {
+		t.Errorf("row want %+v, have %+v", want, have)
+	}
+}
+
+func TestClickHouseNoColumns(t *testing.T) {
+	db, _ := openDB(t)
+
+	if _, err := clickhouse.NewReporter(db, clickhouse.Columns()); err != clickhouse.ErrNoColumns {
+		t.Errorf("want %v, have %v", clickhouse.ErrNoColumns, err)
+	}
+}
+
+func TestClickHouseInvalidIdentifiers(t *testing.T) {
+	db, _ := openDB(t)
+
+	for _, table := range []string{"", "spans; DROP TABLE spans", "1spans", "db.", "`spans`"} {
+		if _, err := clickhouse.NewReporter(db, clickhouse.Table(table)); err != clickhouse.ErrInvalidTable {
+			t.Errorf("table %q want %v, have %v", table, clickhouse.ErrInvalidTable, err)
+		}
+	}
+
+	column := clickhouse.Column{Name: "name) VALUES (1", Field: clickhouse.FieldName}
+	if _, err := clickhouse.NewReporter(db, clickhouse.Columns(column)); err != clickhouse.ErrInvalidColumn {
+		t.Errorf("want %v, have %v", clickhouse.ErrInvalidColumn, err)
+	}
+
+	if _, err := clickhouse.NewReporter(db, clickhouse.Table("zipkin.spans")); err != nil {
+		t.Errorf("database qualified table: unexpected error: %v", err)
+	}
+}
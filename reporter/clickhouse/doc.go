@@ -0,0 +1,44 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package clickhouse implements a reporter which inserts spans directly into a
+ClickHouse table, bypassing the Zipkin collector for very high ingest volumes.
+
+The reporter works on a *sql.DB opened with a ClickHouse database/sql driver
+such as github.com/ClickHouse/clickhouse-go. Spans are inserted in batches
+using a prepared statement within a transaction, which these drivers turn into
+a single block insert.
+
+The default column layout matches the following table:
+
+	CREATE TABLE zipkin_spans (
+		trace_id            String,
+		span_id             String,
+		parent_id           String,
+		name                LowCardinality(String),
+		kind                LowCardinality(String),
+		service_name        LowCardinality(String),
+		remote_service_name LowCardinality(String),
+		timestamp           DateTime64(6),
+		duration            UInt64,
+		tags                Map(String, String),
+		annotations         String
+	) ENGINE = MergeTree
+	PARTITION BY toDate(timestamp)
+	ORDER BY (service_name, name, timestamp)
+
+Other layouts can be targeted using the Table and Columns options.
+*/
+package clickhouse
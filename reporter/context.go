@@ -0,0 +1,35 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reporter
+
+import "context"
+
+type ctxKey struct{}
+
+var untracedKey = ctxKey{}
+
+// NewUntracedContext returns a copy of ctx flagged to not be traced by the
+// client middlewares. Reporters use it for their own outbound requests so that
+// a globally instrumented transport does not create spans for the delivery of
+// spans, which would otherwise result in an endless feedback loop.
+func NewUntracedContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, untracedKey, true)
+}
+
+// IsUntracedContext returns true if ctx was flagged to not be traced.
+func IsUntracedContext(ctx context.Context) bool {
+	untraced, _ := ctx.Value(untracedKey).(bool)
+	return untraced
+}
@@ -0,0 +1,109 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reporter
+
+import (
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/openzipkin/zipkin-go/model"
+)
+
+// Counter is a 64-bit counter safe for concurrent use. Unlike a uint64 updated
+// with package sync/atomic, which panics on 32-bit platforms like ARMv7 and 386
+// unless it is 64-bit aligned, a Counter can be placed anywhere, e.g. embedded
+// in a struct after fields of other sizes. The zero value is ready to use. A
+// Counter must not be copied after first use.
+type Counter struct {
+	// holds the 64-bit aligned value at v[0:2] or v[1:3], whichever is aligned
+	v [3]uint32
+}
+
+// ptr returns the 64-bit aligned value of c.
+func (c *Counter) ptr() *uint64 {
+	if uintptr(unsafe.Pointer(&c.v))%8 == 0 {
+		return (*uint64)(unsafe.Pointer(&c.v[0]))
+	}
+	return (*uint64)(unsafe.Pointer(&c.v[1]))
+}
+
+// Add adds delta to c and returns the new value.
+func (c *Counter) Add(delta uint64) uint64 {
+	return atomic.AddUint64(c.ptr(), delta)
+}
+
+// Inc increments c by one and returns the new value.
+func (c *Counter) Inc() uint64 {
+	return atomic.AddUint64(c.ptr(), 1)
+}
+
+// Load returns the value of c.
+func (c *Counter) Load() uint64 {
+	return atomic.LoadUint64(c.ptr())
+}
+
+// Reset sets c to zero and returns its previous value.
+func (c *Counter) Reset() uint64 {
+	return atomic.SwapUint64(c.ptr(), 0)
+}
+
+// Counters holds the span counts of a reporter, see NewCountingReporter.
+type Counters struct {
+	Sent    Counter // spans sent to the reporter
+	Dropped Counter // spans dropped by the reporter, see Drop
+}
+
+// Drop counts a dropped span. It can be passed to the OnDrop option of the
+// reporters in the subpackages of package reporter:
+//
+//	var counters reporter.Counters
+//	rep := reporter.NewCountingReporter(
+//		http.NewReporter(url, http.OnDrop(counters.Drop)), &counters,
+//	)
+func (c *Counters) Drop(span model.SpanModel, reason error) {
+	c.Dropped.Inc()
+}
+
+// countingReporter counts the spans sent to the next reporter.
+type countingReporter struct {
+	next     Reporter
+	counters *Counters
+}
+
+// NewCountingReporter returns a Reporter which counts the spans sent to next in
+// counters.Sent before passing them on. Dropped spans are counted if
+// counters.Drop is registered as drop callback of next.
+func NewCountingReporter(next Reporter, counters *Counters) Reporter {
+	return &countingReporter{next: next, counters: counters}
+}
+
+// Send counts s and sends it to the next reporter.
+func (r *countingReporter) Send(s model.SpanModel) {
+	r.counters.Sent.Inc()
+	r.next.Send(s)
+}
+
+// Flush flushes the next reporter if it implements Flusher.
+func (r *countingReporter) Flush() error {
+	if f, ok := r.next.(Flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// Close closes the next reporter.
+func (r *countingReporter) Close() error {
+	return r.next.Close()
+}
@@ -0,0 +1,85 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reporter_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter"
+	"github.com/openzipkin/zipkin-go/reporter/recorder"
+)
+
+func TestCounter(t *testing.T) {
+	// counters following a 32-bit field are not 64-bit aligned on 32-bit
+	// platforms when used as plain uint64
+	var s struct {
+		flag uint32
+		c    [2]reporter.Counter
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				s.c[0].Inc()
+				s.c[1].Add(2)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if want, have := uint64(8000), s.c[0].Load(); want != have {
+		t.Errorf("counter want %d, have %d", want, have)
+	}
+	if want, have := uint64(16000), s.c[1].Reset(); want != have {
+		t.Errorf("reset want %d, have %d", want, have)
+	}
+	if want, have := uint64(0), s.c[1].Load(); want != have {
+		t.Errorf("counter after reset want %d, have %d", want, have)
+	}
+	if want, have := uint64(1<<32+1), s.c[1].Add(1<<32+1); want != have {
+		t.Errorf("counter want %d, have %d", want, have)
+	}
+}
+
+func TestCountingReporter(t *testing.T) {
+	var counters reporter.Counters
+	rec := recorder.NewReporter()
+	rep := reporter.NewCountingReporter(rec, &counters)
+	defer rep.Close()
+
+	for i := 0; i < 3; i++ {
+		rep.Send(model.SpanModel{})
+	}
+	counters.Drop(model.SpanModel{}, errors.New("dropped"))
+
+	if want, have := uint64(3), counters.Sent.Load(); want != have {
+		t.Errorf("sent want %d, have %d", want, have)
+	}
+	if want, have := uint64(1), counters.Dropped.Load(); want != have {
+		t.Errorf("dropped want %d, have %d", want, have)
+	}
+	if want, have := 3, len(rec.Flush()); want != have {
+		t.Errorf("recorded spans want %d, have %d", want, have)
+	}
+	if err := rep.(reporter.Flusher).Flush(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reporter
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/openzipkin/zipkin-go/model"
+)
+
+// DictionaryContentType is the content type of the dictionary encoding.
+const DictionaryContentType = "application/x-zipkin-dict+json"
+
+// ErrInvalidDictionaryIndex is returned when a dictionary encoded span refers
+// to a missing dictionary entry.
+var ErrInvalidDictionaryIndex = errors.New("invalid dictionary index")
+
+// dictionaryBatch is the wire format of the dictionary encoding.
+type dictionaryBatch struct {
+	Dictionary []string          `json:"dictionary"`
+	Spans      []json.RawMessage `json:"spans"`
+}
+
+// dictionaryTags holds the dictionary indexes of a span's tags as alternating
+// key and value indexes.
+type dictionaryTags struct {
+	Tags []int `json:"dictTags"`
+}
+
+// DictionarySerializer implements a JSON based SpanSerializer which
+// deduplicates repeated tag keys and values across the spans of a batch. Every
+// distinct string is stored once in the batch dictionary and the spans refer
+// to their tags by dictionary index:
+//
+//	{
+//	  "dictionary": ["http.method", "GET", "http.path", "/"],
+//	  "spans": [{"traceId": "...", "id": "...", "dictTags": [0, 1, 2, 3]}]
+//	}
+//
+// This cuts payload sizes considerably for homogeneous workloads but requires
+// cooperating collectors or consumers, which can decode batches using
+// DeserializeDictionary.
+type DictionarySerializer struct{}
+
+// Serialize takes an array of Zipkin SpanModel objects and returns a
+// dictionary encoding of it.
+func (DictionarySerializer) Serialize(spans []*model.SpanModel) ([]byte, error) {
+	var (
+		batch   = dictionaryBatch{Dictionary: []string{}, Spans: make([]json.RawMessage, 0, len(spans))}
+		indexes = map[string]int{}
+	)
+
+	index := func(s string) int {
+		i, ok := indexes[s]
+		if !ok {
+			i = len(batch.Dictionary)
+			indexes[s] = i
+			batch.Dictionary = append(batch.Dictionary, s)
+		}
+		return i
+	}
+
+	for _, span := range spans {
+		s := *span
+		s.Tags = nil
+		b, err := json.Marshal(s)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(span.Tags) > 0 {
+			keys := make([]string, 0, len(span.Tags))
+			for k := range span.Tags {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+
+			var buf bytes.Buffer
+			buf.Write(b[:len(b)-1])
+			buf.WriteString(`,"dictTags":[`)
+			for i, k := range keys {
+				if i > 0 {
+					buf.WriteByte(',')
+				}
+				buf.WriteString(strconv.Itoa(index(k)))
+				buf.WriteByte(',')
+				buf.WriteString(strconv.Itoa(index(span.Tags[k])))
+			}
+			buf.WriteString("]}")
+			b = buf.Bytes()
+		}
+
+		batch.Spans = append(batch.Spans, b)
+	}
+
+	return json.Marshal(batch)
+}
+
+// ContentType returns the ContentType needed for this encoding.
+func (DictionarySerializer) ContentType() string {
+	return DictionaryContentType
+}
+
+// DeserializeDictionary decodes a batch of spans encoded by the
+// DictionarySerializer.
+func DeserializeDictionary(b []byte) ([]*model.SpanModel, error) {
+	var batch dictionaryBatch
+	if err := json.Unmarshal(b, &batch); err != nil {
+		return nil, err
+	}
+
+	spans := make([]*model.SpanModel, 0, len(batch.Spans))
+	for _, raw := range batch.Spans {
+		var (
+			span model.SpanModel
+			tags dictionaryTags
+		)
+		if err := json.Unmarshal(raw, &span); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(raw, &tags); err != nil {
+			return nil, err
+		}
+		if len(tags.Tags)%2 != 0 {
+			return nil, fmt.Errorf("uneven number of tag indexes: %d", len(tags.Tags))
+		}
+		if len(tags.Tags) > 0 {
+			span.Tags = make(map[string]string, len(tags.Tags)/2)
+		}
+		for i := 0; i < len(tags.Tags); i += 2 {
+			k, v := tags.Tags[i], tags.Tags[i+1]
+			if k < 0 || k >= len(batch.Dictionary) || v < 0 || v >= len(batch.Dictionary) {
+				return nil, ErrInvalidDictionaryIndex
+			}
+			span.Tags[batch.Dictionary[k]] = batch.Dictionary[v]
+		}
+		spans = append(spans, &span)
+	}
+	return spans, nil
+}
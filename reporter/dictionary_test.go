@@ -0,0 +1,112 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reporter_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter"
+)
+
+func makeSpans(n int) []*model.SpanModel {
+	spans := make([]*model.SpanModel, 0, n)
+	for i := 1; i <= n; i++ {
+		spans = append(spans, &model.SpanModel{
+			SpanContext: model.SpanContext{
+				TraceID: model.TraceID{Low: uint64(i)},
+				ID:      model.ID(i),
+			},
+			Name:      "get /api",
+			Kind:      model.Server,
+			Timestamp: time.Unix(1500000000, 0).UTC(),
+			Duration:  time.Millisecond,
+			Tags: map[string]string{
+				"http.method":      "GET",
+				"http.path":        "/api",
+				"http.status_code": "200",
+				"component":        "net/http",
+				"request.tenant":   fmt.Sprintf("tenant-%d", i%3),
+			},
+		})
+	}
+	return spans
+}
+
+func mustJSON(t *testing.T, spans []*model.SpanModel) string {
+	b, err := json.Marshal(spans)
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	return string(b)
+}
+
+func TestDictionarySerializer(t *testing.T) {
+	spans := makeSpans(100)
+
+	b, err := reporter.DictionarySerializer{}.Serialize(spans)
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+
+	plain, err := reporter.JSONSerializer{}.Serialize(spans)
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	if len(b) >= len(plain) {
+		t.Errorf("expected dictionary encoding (%d bytes) to be smaller than JSON (%d bytes)", len(b), len(plain))
+	}
+
+	decoded, err := reporter.DeserializeDictionary(b)
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	if want, have := mustJSON(t, spans), mustJSON(t, decoded); want != have {
+		t.Errorf("spans want %s, have %s", want, have)
+	}
+
+	if want, have := reporter.DictionaryContentType, (reporter.DictionarySerializer{}).ContentType(); want != have {
+		t.Errorf("content type want %q, have %q", want, have)
+	}
+}
+
+func TestDictionaryWithoutTags(t *testing.T) {
+	spans := makeSpans(1)
+	spans[0].Tags = nil
+
+	b, err := reporter.DictionarySerializer{}.Serialize(spans)
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+
+	decoded, err := reporter.DeserializeDictionary(b)
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	if want, have := mustJSON(t, spans), mustJSON(t, decoded); want != have {
+		t.Errorf("spans want %s, have %s", want, have)
+	}
+}
+
+func TestDictionaryInvalidIndex(t *testing.T) {
+	b := []byte(`{"dictionary":["a"],"spans":[{"traceId":"1","id":"1","dictTags":[0,1]}]}`)
+
+	if _, err := reporter.DeserializeDictionary(b); err != reporter.ErrInvalidDictionaryIndex {
+		t.Errorf("want %v, have %v", reporter.ErrInvalidDictionaryIndex, err)
+	}
+}
@@ -0,0 +1,429 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package eventhubs implements a reporter to publish spans to Azure Event Hubs.
+
+The reporter publishes batches of events using the Event Hubs HTTPS send batch
+API. Every event holds the list of spans of a partition key, by default the
+trace id, in the format of the serializer. Requests are authorized with the
+Azure AD access tokens returned by the TokenFunc option, so authentication is
+left to the Azure SDK, e.g. a managed identity credential of the azidentity
+package:
+
+	cred, err := azidentity.NewManagedIdentityCredential(nil)
+	...
+	rep, err := eventhubs.NewReporter("my-namespace", "zipkin",
+		eventhubs.TokenFunc(func() (string, error) {
+			tok, err := cred.GetToken(context.Background(), policy.TokenRequestOptions{
+				Scopes: []string{"https://eventhubs.azure.net/.default"},
+			})
+			return tok.Token, err
+		}),
+	)
+
+The AMQP 1.0 protocol and the Kafka-compatible endpoint of Event Hubs are not
+supported. Publishing over AMQP requires the azeventhubs module, which is not a
+dependency of zipkin-go, and the Kafka reporter cannot authenticate with Azure
+AD tokens.
+*/
+package eventhubs
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter"
+	"github.com/openzipkin/zipkin-go/reporter/internal/batch"
+)
+
+// defaults
+const (
+	defaultDomain        = ".servicebus.windows.net"
+	defaultTimeout       = time.Second * 5 // timeout for the send batch call
+	defaultBatchInterval = time.Second * 1 // BatchInterval in seconds
+	defaultBatchSize     = 100
+	defaultMaxBacklog    = 1000
+	defaultRetryAttempts = 3
+	defaultRetryBackoff  = 100 * time.Millisecond
+)
+
+// maxBatchSize is the maximum size of a batch of events.
+const maxBatchSize = 1 << 20
+
+// Errors returned or reported by the Event Hubs reporter.
+var (
+	ErrInvalidEventHub    = errors.New("eventhubs: namespace and event hub required")
+	ErrMissingCredentials = errors.New("eventhubs: credentials required")
+	ErrEventTooLarge      = errors.New("eventhubs: event too large")
+)
+
+// event is an Event Hubs event and the spans it holds.
+type event struct {
+	body         string
+	partitionKey string
+	spans        []*model.SpanModel
+}
+
+// eventHubsReporter will publish spans to an event hub.
+type eventHubsReporter struct {
+	endpoint      string
+	authorization func() (string, error)
+	client        *http.Client
+	partitionKey  func(model.SpanModel) string
+	retryAttempts int
+	retryBackoff  time.Duration
+	logger        reporter.Logger
+	batchInterval time.Duration
+	batchSize     int
+	maxBacklog    int
+	serializer    reporter.SpanSerializer
+	onDrop        func(model.SpanModel, error)
+	batcher       *batch.Batcher
+}
+
+// ReporterOption sets a parameter for the Event Hubs Reporter.
+type ReporterOption func(r *eventHubsReporter)
+
+// TokenFunc authorizes requests using the Azure AD access tokens returned by
+// fn for the https://eventhubs.azure.net/.default scope, e.g. retrieved using a
+// credential of the azidentity package. It is invoked for every request, so
+// fn should cache tokens until they expire, as azidentity credentials do. The
+// option is required.
+func TokenFunc(fn func() (string, error)) ReporterOption {
+	return func(r *eventHubsReporter) {
+		r.authorization = func() (string, error) {
+			token, err := fn()
+			return "Bearer " + token, err
+		}
+	}
+}
+
+// PartitionKey sets the function returning the partition key of the event
+// holding a span. Event Hubs assigns events with the same key to the same
+// partition. Spans of a batch sharing a key are sent as a single event. By
+// default the hex encoded trace id is used, so all spans of a trace are
+// delivered to a single consumer in order. A nil function disables partition
+// keys, distributing events round robin.
+func PartitionKey(fn func(s model.SpanModel) string) ReporterOption {
+	return func(r *eventHubsReporter) { r.partitionKey = fn }
+}
+
+// Endpoint sets the base url of the Event Hubs API, e.g. for private endpoints
+// or local emulators. By default https://<namespace> is used.
+func Endpoint(url string) ReporterOption {
+	return func(r *eventHubsReporter) { r.endpoint = strings.TrimSuffix(url, "/") }
+}
+
+// Client sets a custom http client to use.
+func Client(client *http.Client) ReporterOption {
+	return func(r *eventHubsReporter) { r.client = client }
+}
+
+// Retry sets the number of attempts for requests failing due to throttling or
+// service errors, waiting an exponential backoff starting at backoff between
+// attempts. By default requests are attempted 3 times starting with a backoff
+// of 100ms.
+func Retry(attempts int, backoff time.Duration) ReporterOption {
+	return func(r *eventHubsReporter) {
+		r.retryAttempts = attempts
+		if backoff > 0 {
+			r.retryBackoff = backoff
+		}
+	}
+}
+
+// Timeout sets maximum timeout for send batch calls.
+func Timeout(duration time.Duration) ReporterOption {
+	return func(r *eventHubsReporter) { r.client.Timeout = duration }
+}
+
+// BatchSize sets the maximum batch size, after which a collect will be
+// triggered. The default batch size is 100 traces.
+func BatchSize(n int) ReporterOption {
+	return func(r *eventHubsReporter) { r.batchSize = n }
+}
+
+// MaxBacklog sets the maximum backlog size. When batch size reaches this
+// threshold, spans from the beginning of the batch will be disposed.
+func MaxBacklog(n int) ReporterOption {
+	return func(r *eventHubsReporter) { r.maxBacklog = n }
+}
+
+// BatchInterval sets the maximum duration we will buffer traces before
+// emitting them to the event hub. The default batch interval is 1 second.
+func BatchInterval(d time.Duration) ReporterOption {
+	return func(r *eventHubsReporter) { r.batchInterval = d }
+}
+
+// Serializer sets the serialization function to use for sending span data to
+// Zipkin.
+func Serializer(serializer reporter.SpanSerializer) ReporterOption {
+	return func(r *eventHubsReporter) {
+		if serializer != nil {
+			r.serializer = serializer
+		}
+	}
+}
+
+// Logger sets the logger used to report errors in the collection
+// process. It accepts a *log.Logger or any other reporter.Logger, e.g. one
+// returned by reporter.SlogLogger.
+func Logger(l reporter.Logger) ReporterOption {
+	return func(r *eventHubsReporter) { r.logger = l }
+}
+
+// OnDrop registers a callback function which is invoked for every span the
+// reporter fails to deliver, together with the reason. Spans are dropped when
+// the backlog overflows, on serialization failures and when their event is
+// rejected or fails after all attempts. The callback is invoked synchronously
+// from the reporter's goroutines so it should not block.
+func OnDrop(fn func(span model.SpanModel, reason error)) ReporterOption {
+	return func(r *eventHubsReporter) { r.onDrop = fn }
+}
+
+// traceIDKey returns the hex encoded trace id of the span.
+func traceIDKey(s model.SpanModel) string {
+	return s.TraceID.String()
+}
+
+// NewReporter returns a new Event Hubs Reporter publishing spans to hub of
+// namespace. The namespace is the host name of the namespace or its name, in
+// which case the host name in the Azure public cloud is used. The TokenFunc
+// option is required.
+func NewReporter(namespace, hub string, opts ...ReporterOption) (reporter.Reporter, error) {
+	if namespace == "" || hub == "" {
+		return nil, ErrInvalidEventHub
+	}
+	if !strings.Contains(namespace, ".") {
+		namespace += defaultDomain
+	}
+
+	r := &eventHubsReporter{
+		endpoint:      "https://" + namespace,
+		client:        &http.Client{Timeout: defaultTimeout},
+		partitionKey:  traceIDKey,
+		retryAttempts: defaultRetryAttempts,
+		retryBackoff:  defaultRetryBackoff,
+		logger:        log.New(os.Stderr, "", log.LstdFlags),
+		batchInterval: defaultBatchInterval,
+		batchSize:     defaultBatchSize,
+		maxBacklog:    defaultMaxBacklog,
+		serializer:    reporter.JSONSerializer{},
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	if r.authorization == nil {
+		return nil, ErrMissingCredentials
+	}
+	r.endpoint += "/" + hub + "/messages"
+
+	r.batcher = batch.New(batch.Options{
+		Interval:   r.batchInterval,
+		Size:       r.batchSize,
+		MaxBacklog: r.maxBacklog,
+		Logger:     r.logger,
+		OnDrop:     r.onDrop,
+	}, r.sendBatch)
+
+	return r, nil
+}
+
+// Send implements reporter
+func (r *eventHubsReporter) Send(s model.SpanModel) {
+	r.batcher.Send(s)
+}
+
+// Flush implements reporter.Flusher. It publishes the buffered spans and
+// returns the first error encountered. Flush does nothing once the reporter is
+// closed.
+func (r *eventHubsReporter) Flush() error {
+	return r.batcher.Flush()
+}
+
+// Close implements reporter
+func (r *eventHubsReporter) Close() error {
+	return r.batcher.Close()
+}
+
+func (r *eventHubsReporter) sendBatch(spans []*model.SpanModel) error {
+	events, err := r.events(spans)
+
+	var (
+		pending []event
+		size    int
+	)
+	for _, e := range events {
+		if len(pending) > 0 && size+len(e.body) > maxBatchSize {
+			if serr := r.send(pending); err == nil {
+				err = serr
+			}
+			pending, size = nil, 0
+		}
+		pending = append(pending, e)
+		size += len(e.body)
+	}
+	if len(pending) > 0 {
+		if serr := r.send(pending); err == nil {
+			err = serr
+		}
+	}
+	return err
+}
+
+// events builds the events holding the spans of each partition key.
+func (r *eventHubsReporter) events(spans []*model.SpanModel) ([]event, error) {
+	var (
+		groups [][]*model.SpanModel
+		keys   []string
+		index  = make(map[string]int)
+	)
+	for _, span := range spans {
+		if r.partitionKey == nil {
+			groups = append(groups, []*model.SpanModel{span})
+			keys = append(keys, "")
+			continue
+		}
+		key := r.partitionKey(*span)
+		if i, ok := index[key]; ok {
+			groups[i] = append(groups[i], span)
+			continue
+		}
+		index[key] = len(groups)
+		groups = append(groups, []*model.SpanModel{span})
+		keys = append(keys, key)
+	}
+
+	var (
+		events   []event
+		firstErr error
+	)
+	for i, group := range groups {
+		body, err := r.serializer.Serialize(group)
+		if err == nil && len(body) > maxBatchSize {
+			err = ErrEventTooLarge
+		}
+		if err != nil {
+			r.logger.Printf("failed when marshalling the spans batch: %s\n", err.Error())
+			r.batcher.Drop(group, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		text := string(body)
+		if !utf8.Valid(body) {
+			// event bodies of the send batch API are strings
+			text = base64.StdEncoding.EncodeToString(body)
+		}
+		events = append(events, event{body: text, partitionKey: keys[i], spans: group})
+	}
+	return events, firstErr
+}
+
+type brokerProperties struct {
+	PartitionKey string `json:"PartitionKey,omitempty"`
+}
+
+type batchEvent struct {
+	Body             string            `json:"Body"`
+	UserProperties   map[string]string `json:"UserProperties"`
+	BrokerProperties *brokerProperties `json:"BrokerProperties,omitempty"`
+}
+
+// send publishes events, retrying failed calls.
+func (r *eventHubsReporter) send(events []event) error {
+	var (
+		err   error
+		retry bool
+	)
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 {
+			time.Sleep(r.retryBackoff << uint(attempt-2))
+		}
+		retry, err = r.sendRequest(events)
+		if err == nil || !retry || attempt >= r.retryAttempts {
+			break
+		}
+	}
+	if err != nil {
+		for _, e := range events {
+			r.batcher.Drop(e.spans, err)
+		}
+	}
+	return err
+}
+
+// sendRequest publishes events and returns whether a failed call might
+// succeed when retried.
+func (r *eventHubsReporter) sendRequest(events []event) (bool, error) {
+	batch := make([]batchEvent, len(events))
+	for i, e := range events {
+		batch[i] = batchEvent{
+			Body:           e.body,
+			UserProperties: map[string]string{"Content-Type": r.serializer.ContentType()},
+		}
+		if e.partitionKey != "" {
+			batch[i].BrokerProperties = &brokerProperties{PartitionKey: e.partitionKey}
+		}
+	}
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return false, err
+	}
+
+	authorization, err := r.authorization()
+	if err != nil {
+		r.logger.Printf("failed to retrieve credentials: %s\n", err.Error())
+		return true, err
+	}
+	req, err := http.NewRequest("POST", r.endpoint+"?api-version=2014-01&timeout=60", bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	// make sure instrumented transports do not trace the delivery of spans
+	req = req.WithContext(reporter.NewUntracedContext(context.Background()))
+	req.Header.Set("Content-Type", "application/vnd.microsoft.servicebus.json")
+	req.Header.Set("Authorization", authorization)
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		r.logger.Printf("failed to send the request: %s\n", err.Error())
+		return true, err
+	}
+	defer resp.Body.Close()
+	respBody, _ := ioutil.ReadAll(resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		err = fmt.Errorf("eventhubs: send batch failed with status code %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+		r.logger.Printf("%s\n", err.Error())
+		return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode > 499, err
+	}
+	return false, nil
+}
@@ -0,0 +1,233 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eventhubs_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter"
+	"github.com/openzipkin/zipkin-go/reporter/eventhubs"
+)
+
+// token authorizes requests to the fake event hub
+func token() (string, error) { return "token", nil }
+
+type batchEvent struct {
+	Body             string            `json:"Body"`
+	UserProperties   map[string]string `json:"UserProperties"`
+	BrokerProperties *struct {
+		PartitionKey string `json:"PartitionKey"`
+	} `json:"BrokerProperties"`
+}
+
+type fakeEventHub struct {
+	mtx      sync.Mutex
+	batches  [][]batchEvent
+	requests []*http.Request
+	// status returns the status code of the response to the given attempt.
+	status func(attempt int) int
+}
+
+func (f *fakeEventHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	var batch []batchEvent
+	if err := json.Unmarshal(body, &batch); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	f.mtx.Lock()
+	f.batches = append(f.batches, batch)
+	f.requests = append(f.requests, r)
+	attempt := len(f.batches)
+	f.mtx.Unlock()
+
+	if f.status != nil {
+		if status := f.status(attempt); status != http.StatusCreated {
+			w.WriteHeader(status)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (f *fakeEventHub) Batches() [][]batchEvent {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	return append([][]batchEvent(nil), f.batches...)
+}
+
+func TestSendBatch(t *testing.T) {
+	fake := &fakeEventHub{}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	rep, err := eventhubs.NewReporter("acme", "zipkin",
+		eventhubs.Endpoint(srv.URL),
+		eventhubs.TokenFunc(token),
+		eventhubs.BatchInterval(time.Hour),
+		eventhubs.Retry(3, time.Millisecond),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer rep.Close()
+
+	// spans sharing a partition key are sent as one event
+	for i, traceID := range []uint64{1, 2, 1} {
+		rep.Send(model.SpanModel{
+			SpanContext: model.SpanContext{TraceID: model.TraceID{Low: traceID}, ID: model.ID(i + 1)},
+		})
+	}
+	if err := rep.(reporter.Flusher).Flush(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	batches := fake.Batches()
+	if want, have := 1, len(batches); want != have {
+		t.Fatalf("batches want %d, have %d", want, have)
+	}
+	req := fake.requests[0]
+	if want, have := "/zipkin/messages", req.URL.Path; want != have {
+		t.Errorf("path want %q, have %q", want, have)
+	}
+	if want, have := "application/vnd.microsoft.servicebus.json", req.Header.Get("Content-Type"); want != have {
+		t.Errorf("content type want %q, have %q", want, have)
+	}
+	if want, have := "Bearer token", req.Header.Get("Authorization"); want != have {
+		t.Errorf("authorization want %q, have %q", want, have)
+	}
+
+	events := batches[0]
+	if want, have := 2, len(events); want != have {
+		t.Fatalf("events want %d, have %d", want, have)
+	}
+	wantKeys := []string{"0000000000000001", "0000000000000002"}
+	wantSpans := []int{2, 1}
+	for i, e := range events {
+		if e.BrokerProperties == nil || e.BrokerProperties.PartitionKey != wantKeys[i] {
+			t.Errorf("partition key want %q, have %+v", wantKeys[i], e.BrokerProperties)
+		}
+		if want, have := "application/json", e.UserProperties["Content-Type"]; want != have {
+			t.Errorf("content type property want %q, have %q", want, have)
+		}
+		var spans []model.SpanModel
+		if err := json.Unmarshal([]byte(e.Body), &spans); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if want, have := wantSpans[i], len(spans); want != have {
+			t.Errorf("spans want %d, have %d", want, have)
+		}
+	}
+}
+
+func TestNoPartitionKey(t *testing.T) {
+	fake := &fakeEventHub{}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	rep, err := eventhubs.NewReporter("acme", "zipkin",
+		eventhubs.Endpoint(srv.URL),
+		eventhubs.TokenFunc(token),
+		eventhubs.BatchInterval(time.Hour),
+		eventhubs.Retry(3, time.Millisecond),
+		eventhubs.PartitionKey(nil),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer rep.Close()
+
+	for i := 1; i <= 2; i++ {
+		rep.Send(model.SpanModel{SpanContext: model.SpanContext{TraceID: model.TraceID{Low: 1}, ID: model.ID(i)}})
+	}
+	if err := rep.(reporter.Flusher).Flush(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	events := fake.Batches()[0]
+	if want, have := 2, len(events); want != have {
+		t.Fatalf("events want %d, have %d", want, have)
+	}
+	for _, e := range events {
+		if e.BrokerProperties != nil {
+			t.Errorf("unexpected broker properties %+v", e.BrokerProperties)
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		status   int
+		requests int
+		dropped  int
+		err      bool
+	}{
+		{"server busy", http.StatusServiceUnavailable, 2, 0, false},
+		{"unauthorized", http.StatusUnauthorized, 1, 1, true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			fake := &fakeEventHub{status: func(attempt int) int {
+				if attempt == 1 {
+					return test.status
+				}
+				return http.StatusCreated
+			}}
+			srv := httptest.NewServer(fake)
+			defer srv.Close()
+
+			var dropped int
+			rep, err := eventhubs.NewReporter("acme", "zipkin",
+				eventhubs.Endpoint(srv.URL),
+				eventhubs.TokenFunc(token),
+				eventhubs.BatchInterval(time.Hour),
+				eventhubs.Retry(3, time.Millisecond),
+				eventhubs.OnDrop(func(model.SpanModel, error) { dropped++ }),
+			)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer rep.Close()
+
+			rep.Send(model.SpanModel{SpanContext: model.SpanContext{TraceID: model.TraceID{Low: 1}, ID: 1}})
+			if want, have := test.err, rep.(reporter.Flusher).Flush() != nil; want != have {
+				t.Errorf("error want %t, have %t", want, have)
+			}
+			if want, have := test.requests, len(fake.Batches()); want != have {
+				t.Errorf("requests want %d, have %d", want, have)
+			}
+			if want, have := test.dropped, dropped; want != have {
+				t.Errorf("dropped want %d, have %d", want, have)
+			}
+		})
+	}
+}
+
+func TestMissingCredentials(t *testing.T) {
+	if _, err := eventhubs.NewReporter("acme", "zipkin"); err != eventhubs.ErrMissingCredentials {
+		t.Errorf("want %v, have %v", eventhubs.ErrMissingCredentials, err)
+	}
+	if _, err := eventhubs.NewReporter("acme", ""); err != eventhubs.ErrInvalidEventHub {
+		t.Errorf("want %v, have %v", eventhubs.ErrInvalidEventHub, err)
+	}
+}
@@ -0,0 +1,23 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reporter
+
+// Flusher is implemented by Reporters buffering spans. Flush synchronously
+// delivers the spans sent before the call without closing the reporter, e.g.
+// before a serverless function or short-lived process exits, and returns the
+// error if they could not be delivered.
+type Flusher interface {
+	Flush() error
+}
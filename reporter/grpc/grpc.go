@@ -0,0 +1,352 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package grpc implements a gRPC reporter to send proto3 encoded spans to the
+SpanService of Zipkin collectors.
+*/
+package grpc
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/openzipkin/zipkin-go/model"
+	zipkinproto "github.com/openzipkin/zipkin-go/proto/v2"
+	"github.com/openzipkin/zipkin-go/reporter"
+)
+
+// defaults
+const (
+	defaultTimeout       = time.Second * 5 // timeout for the Report call
+	defaultBatchInterval = time.Second * 1 // BatchInterval in seconds
+	defaultBatchSize     = 100
+	defaultMaxBacklog    = 1000
+)
+
+// reportMethod is the full name of the SpanService method receiving spans.
+const reportMethod = "/zipkin.proto3.SpanService/Report"
+
+// grpcReporter will send spans to a Zipkin gRPC Collector.
+type grpcReporter struct {
+	conn          *grpc.ClientConn
+	ownConn       bool
+	dialOptions   []grpc.DialOption
+	creds         credentials.TransportCredentials
+	rpcCreds      credentials.PerRPCCredentials
+	headers       metadata.MD
+	timeout       time.Duration
+	logger        reporter.Logger
+	batchInterval time.Duration
+	batchSize     int
+	maxBacklog    int
+	batchMtx      *sync.Mutex
+	batch         []*model.SpanModel
+	spanC         chan *model.SpanModel
+	sendC         chan struct{}
+	flushC        chan struct{}
+	sendMtx       sync.Mutex
+	quit          chan struct{}
+	shutdown      chan error
+	serializer    reporter.SpanSerializer
+	method        string
+	onDrop        func(model.SpanModel, error)
+}
+
+// ReporterOption sets a parameter for the gRPC Reporter.
+type ReporterOption func(r *grpcReporter)
+
+// Timeout sets the maximum duration of a Report call.
+func Timeout(duration time.Duration) ReporterOption {
+	return func(r *grpcReporter) { r.timeout = duration }
+}
+
+// BatchSize sets the maximum batch size, after which a collect will be
+// triggered. The default batch size is 100 traces.
+func BatchSize(n int) ReporterOption {
+	return func(r *grpcReporter) { r.batchSize = n }
+}
+
+// MaxBacklog sets the maximum backlog size. When batch size reaches this
+// threshold, spans from the beginning of the batch will be disposed.
+func MaxBacklog(n int) ReporterOption {
+	return func(r *grpcReporter) { r.maxBacklog = n }
+}
+
+// BatchInterval sets the maximum duration we will buffer traces before
+// emitting them to the collector. The default batch interval is 1 second.
+func BatchInterval(d time.Duration) ReporterOption {
+	return func(r *grpcReporter) { r.batchInterval = d }
+}
+
+// Logger sets the logger used to report errors in the collection
+// process. It accepts a *log.Logger or any other reporter.Logger, e.g. one
+// returned by reporter.SlogLogger.
+func Logger(l reporter.Logger) ReporterOption {
+	return func(r *grpcReporter) { r.logger = l }
+}
+
+// TLSConfig sets the TLS configuration used to connect to the collector. The
+// connection is not encrypted by default.
+func TLSConfig(config *tls.Config) ReporterOption {
+	return func(r *grpcReporter) { r.creds = credentials.NewTLS(config) }
+}
+
+// Credentials sets the transport credentials used to connect to the
+// collector, taking precedence over TLSConfig.
+func Credentials(creds credentials.TransportCredentials) ReporterOption {
+	return func(r *grpcReporter) { r.creds = creds }
+}
+
+// PerRPCCredentials sets credentials attached to every Report call, e.g. OAuth
+// tokens.
+func PerRPCCredentials(creds credentials.PerRPCCredentials) ReporterOption {
+	return func(r *grpcReporter) { r.rpcCreds = creds }
+}
+
+// Headers sets metadata sent with every Report call, e.g. to authenticate
+// against collectors behind a gateway.
+func Headers(headers map[string]string) ReporterOption {
+	return func(r *grpcReporter) { r.headers = metadata.New(headers) }
+}
+
+// DialOptions adds options used to dial the collector, e.g. to tune keepalive
+// or load balancing.
+func DialOptions(options ...grpc.DialOption) ReporterOption {
+	return func(r *grpcReporter) { r.dialOptions = append(r.dialOptions, options...) }
+}
+
+// ClientConn sets an existing connection to the collector to use instead of
+// dialing one. The connection is not closed by Close.
+func ClientConn(conn *grpc.ClientConn) ReporterOption {
+	return func(r *grpcReporter) { r.conn = conn }
+}
+
+// Serializer sets the serializer encoding the batches and Method the full name
+// of the unary method receiving them, e.g. to export spans to other backends
+// like OpenTelemetry Collectors. The serializer needs to produce the protocol
+// buffer message expected by the method. By default batches are sent as
+// zipkin.proto3.ListOfSpans to the Report method of the Zipkin SpanService.
+func Serializer(serializer reporter.SpanSerializer, method string) ReporterOption {
+	return func(r *grpcReporter) {
+		r.serializer = serializer
+		r.method = method
+	}
+}
+
+// OnDrop registers a callback function which is invoked for every span the
+// reporter fails to deliver, together with the reason. Spans are dropped when
+// the backlog overflows, on serialization failures and when the Report call
+// fails. The callback is invoked synchronously from the reporter's goroutines
+// so it should not block.
+func OnDrop(fn func(span model.SpanModel, reason error)) ReporterOption {
+	return func(r *grpcReporter) { r.onDrop = fn }
+}
+
+// NewReporter returns a new gRPC Reporter.
+// target should be the address of the collector's gRPC endpoint, e.g.
+// localhost:9411. The connection is established in the background and
+// re-established by gRPC when lost.
+func NewReporter(target string, opts ...ReporterOption) (reporter.Reporter, error) {
+	r := &grpcReporter{
+		timeout:       defaultTimeout,
+		logger:        log.New(os.Stderr, "", log.LstdFlags),
+		batchInterval: defaultBatchInterval,
+		batchSize:     defaultBatchSize,
+		maxBacklog:    defaultMaxBacklog,
+		batch:         []*model.SpanModel{},
+		spanC:         make(chan *model.SpanModel),
+		sendC:         make(chan struct{}, 1),
+		flushC:        make(chan struct{}),
+		quit:          make(chan struct{}, 1),
+		shutdown:      make(chan error, 1),
+		batchMtx:      &sync.Mutex{},
+		serializer:    zipkinproto.SpanSerializer{},
+		method:        reportMethod,
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	if r.conn == nil {
+		options := []grpc.DialOption{grpc.WithInsecure()}
+		if r.creds != nil {
+			options[0] = grpc.WithTransportCredentials(r.creds)
+		}
+		if r.rpcCreds != nil {
+			options = append(options, grpc.WithPerRPCCredentials(r.rpcCreds))
+		}
+		conn, err := grpc.Dial(target, append(options, r.dialOptions...)...)
+		if err != nil {
+			return nil, err
+		}
+		r.conn, r.ownConn = conn, true
+	}
+
+	go r.loop()
+	go r.sendLoop()
+
+	return r, nil
+}
+
+// Send implements reporter
+func (r *grpcReporter) Send(s model.SpanModel) {
+	r.spanC <- &s
+}
+
+// Flush implements reporter.Flusher. It sends the buffered spans to the
+// collector and returns the error of the Report call if it fails. Flush does
+// nothing once the reporter is closed.
+func (r *grpcReporter) Flush() error {
+	select {
+	case r.flushC <- struct{}{}:
+	case <-r.quit:
+		return nil
+	}
+
+	r.sendMtx.Lock()
+	defer r.sendMtx.Unlock()
+	return r.sendBatch()
+}
+
+// Close implements reporter
+func (r *grpcReporter) Close() error {
+	close(r.quit)
+	err := <-r.shutdown
+	if r.ownConn {
+		if cerr := r.conn.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+func (r *grpcReporter) loop() {
+	var (
+		nextSend   = time.Now().Add(r.batchInterval)
+		ticker     = time.NewTicker(r.batchInterval / 10)
+		tickerChan = ticker.C
+	)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case span := <-r.spanC:
+			currentBatchSize := r.append(span)
+			if currentBatchSize >= r.batchSize {
+				nextSend = time.Now().Add(r.batchInterval)
+				r.enqueueSend()
+			}
+		case <-tickerChan:
+			if time.Now().After(nextSend) {
+				nextSend = time.Now().Add(r.batchInterval)
+				r.enqueueSend()
+			}
+		case <-r.flushC:
+			// spans sent before the Flush call have been batched
+		case <-r.quit:
+			close(r.sendC)
+			return
+		}
+	}
+}
+
+func (r *grpcReporter) sendLoop() {
+	for range r.sendC {
+		r.sendMtx.Lock()
+		_ = r.sendBatch()
+		r.sendMtx.Unlock()
+	}
+	r.sendMtx.Lock()
+	defer r.sendMtx.Unlock()
+	r.shutdown <- r.sendBatch()
+}
+
+func (r *grpcReporter) enqueueSend() {
+	select {
+	case r.sendC <- struct{}{}:
+	default:
+		// Do nothing if there's a pending send request already
+	}
+}
+
+func (r *grpcReporter) append(span *model.SpanModel) (newBatchSize int) {
+	r.batchMtx.Lock()
+
+	r.batch = append(r.batch, span)
+	if len(r.batch) > r.maxBacklog {
+		dispose := len(r.batch) - r.maxBacklog
+		r.logger.Printf("backlog too long, disposing %d spans", dispose)
+		r.drop(r.batch[:dispose], reporter.ErrQueueFull)
+		r.batch = r.batch[dispose:]
+	}
+	newBatchSize = len(r.batch)
+
+	r.batchMtx.Unlock()
+	return
+}
+
+func (r *grpcReporter) drop(spans []*model.SpanModel, reason error) {
+	if r.onDrop == nil {
+		return
+	}
+	for _, span := range spans {
+		r.onDrop(*span, reason)
+	}
+}
+
+func (r *grpcReporter) sendBatch() error {
+	// Select all current spans in the batch to be sent
+	r.batchMtx.Lock()
+	sendBatch := r.batch
+	r.batch = []*model.SpanModel{}
+	r.batchMtx.Unlock()
+
+	if len(sendBatch) == 0 {
+		return nil
+	}
+
+	body, err := r.serializer.Serialize(sendBatch)
+	if err != nil {
+		r.logger.Printf("failed when marshalling the spans batch: %s\n", err.Error())
+		r.drop(sendBatch, err)
+		return err
+	}
+
+	// make sure instrumented connections do not trace the delivery of spans
+	ctx, cancel := context.WithTimeout(reporter.NewUntracedContext(context.Background()), r.timeout)
+	defer cancel()
+	if r.headers != nil {
+		ctx = metadata.NewOutgoingContext(ctx, r.headers)
+	}
+
+	err = r.conn.Invoke(ctx, r.method, rawMessage(body), &rawMessage{}, grpc.ForceCodec(rawCodec{}))
+	if err != nil {
+		r.logger.Printf("failed to report the spans batch: %s\n", err.Error())
+		err = fmt.Errorf("failed to report the spans batch: %v", err)
+		r.drop(sendBatch, err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpc_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/openzipkin/zipkin-go"
+	zipkingrpc "github.com/openzipkin/zipkin-go/middleware/grpc"
+	"github.com/openzipkin/zipkin-go/model"
+	zipkin_proto3 "github.com/openzipkin/zipkin-go/proto/v2"
+	zipkinreporter "github.com/openzipkin/zipkin-go/reporter"
+	grpcreporter "github.com/openzipkin/zipkin-go/reporter/grpc"
+	"github.com/openzipkin/zipkin-go/reporter/recorder"
+)
+
+// spanService is a fake Zipkin collector SpanService.
+type spanService struct {
+	mtx     sync.Mutex
+	spans   []*zipkin_proto3.Span
+	headers []string
+	err     error
+}
+
+func (s *spanService) report(ctx context.Context, spans *zipkin_proto3.ListOfSpans) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.spans = append(s.spans, spans.Spans...)
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		s.headers = append(s.headers, md.Get("x-tenant")...)
+	}
+	return nil
+}
+
+func (s *spanService) received() []*zipkin_proto3.Span {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return append([]*zipkin_proto3.Span(nil), s.spans...)
+}
+
+var spanServiceDesc = grpc.ServiceDesc{
+	ServiceName: "zipkin.proto3.SpanService",
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{{
+		MethodName: "Report",
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, _ grpc.UnaryServerInterceptor) (interface{}, error) {
+			in := &zipkin_proto3.ListOfSpans{}
+			if err := dec(in); err != nil {
+				return nil, err
+			}
+			if err := srv.(*spanService).report(ctx, in); err != nil {
+				return nil, err
+			}
+			// an empty message encodes like the empty ReportResponse
+			return &zipkin_proto3.ListOfSpans{}, nil
+		},
+	}},
+	Streams: []grpc.StreamDesc{},
+}
+
+func startCollector(t *testing.T) (*spanService, string, func()) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	service := &spanService{}
+	server := grpc.NewServer()
+	server.RegisterService(&spanServiceDesc, service)
+	go func() { _ = server.Serve(lis) }()
+	return service, lis.Addr().String(), server.Stop
+}
+
+func newSpan(name string, id uint64) model.SpanModel {
+	return model.SpanModel{
+		SpanContext: model.SpanContext{
+			TraceID: model.TraceID{High: 1, Low: id},
+			ID:      model.ID(id),
+		},
+		Name:      name,
+		Timestamp: time.Now(),
+		Duration:  time.Millisecond,
+	}
+}
+
+func TestReport(t *testing.T) {
+	service, addr, stop := startCollector(t)
+	defer stop()
+
+	// the delivery of spans must not be traced by instrumented connections
+	rec := recorder.NewReporter()
+	tracer, _ := zipkin.NewTracer(rec)
+
+	rep, err := grpcreporter.NewReporter(addr,
+		grpcreporter.BatchSize(2),
+		grpcreporter.Headers(map[string]string{"x-tenant": "acme"}),
+		grpcreporter.DialOptions(grpc.WithStatsHandler(zipkingrpc.NewClientHandler(tracer))),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rep.Send(newSpan("a", 1))
+	rep.Send(newSpan("b", 2))
+	rep.Send(newSpan("c", 3))
+	if err := rep.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	spans := service.received()
+	if want, have := 3, len(spans); want != have {
+		t.Fatalf("spans want %d, have %d", want, have)
+	}
+	for i, want := range []string{"a", "b", "c"} {
+		if have := spans[i].Name; want != have {
+			t.Errorf("span %d name want %q, have %q", i, want, have)
+		}
+	}
+	if len(service.headers) == 0 {
+		t.Error("expected headers to be sent")
+	}
+	for _, have := range service.headers {
+		if want := "acme"; want != have {
+			t.Errorf("header want %q, have %q", want, have)
+		}
+	}
+	if want, have := 0, len(rec.Flush()); want != have {
+		t.Errorf("traced span deliveries want %d, have %d", want, have)
+	}
+}
+
+func TestFlushAndDrop(t *testing.T) {
+	service, addr, stop := startCollector(t)
+	defer stop()
+
+	var dropped []string
+	rep, err := grpcreporter.NewReporter(addr,
+		grpcreporter.BatchInterval(time.Hour),
+		grpcreporter.OnDrop(func(span model.SpanModel, _ error) { dropped = append(dropped, span.Name) }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rep.Close()
+
+	rep.Send(newSpan("a", 1))
+	if err := rep.(zipkinreporter.Flusher).Flush(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want, have := 1, len(service.received()); want != have {
+		t.Fatalf("spans want %d, have %d", want, have)
+	}
+
+	service.mtx.Lock()
+	service.err = errors.New("unavailable")
+	service.mtx.Unlock()
+
+	rep.Send(newSpan("b", 2))
+	if err := rep.(zipkinreporter.Flusher).Flush(); err == nil {
+		t.Error("expected error")
+	}
+	if want, have := []string{"b"}, dropped; len(have) != 1 || want[0] != have[0] {
+		t.Errorf("dropped spans want %v, have %v", want, have)
+	}
+}
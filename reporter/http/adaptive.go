@@ -0,0 +1,129 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import "time"
+
+// adaptive defaults
+const (
+	defaultMinBatchSize     = 10
+	defaultMinBatchInterval = time.Millisecond * 100
+	defaultMaxBatchInterval = time.Second * 10
+	defaultTargetLatency    = time.Millisecond * 500
+)
+
+// AdaptiveLimits holds the bounds within which adaptive batching tunes the
+// batch size and batch interval of the reporter. Zero values are replaced by
+// defaults.
+type AdaptiveLimits struct {
+	// MinBatchSize is the lower bound of the batch size and the step by which
+	// it is decreased. Defaults to 10 spans.
+	MinBatchSize int
+	// MaxBatchSize is the upper bound of the batch size. Defaults to half of
+	// the max backlog.
+	MaxBatchSize int
+	// MinBatchInterval is the lower bound of the batch interval and the step
+	// by which it is decreased. Defaults to 100 milliseconds.
+	MinBatchInterval time.Duration
+	// MaxBatchInterval is the upper bound of the batch interval. Defaults to
+	// 10 seconds.
+	MaxBatchInterval time.Duration
+	// TargetLatency is the send latency above which the collector is
+	// considered congested. Defaults to 500 milliseconds.
+	TargetLatency time.Duration
+}
+
+// Adaptive enables adaptive batching. After every request to the collector the
+// batch size and batch interval are adjusted AIMD style: slow or failed
+// requests multiplicatively increase both, so fewer but larger requests are
+// made, while fast requests additively decrease them again to deliver spans
+// sooner. As long as spans pile up in the backlog during requests the batch
+// size is kept. BatchSize and BatchInterval provide the starting values.
+func Adaptive(limits AdaptiveLimits) ReporterOption {
+	return func(r *httpReporter) { r.adaptive = &limits }
+}
+
+// init applies the defaults and clamps the reporter's initial batch size and
+// interval to the limits.
+func (l *AdaptiveLimits) init(r *httpReporter) {
+	if l.MinBatchSize <= 0 {
+		l.MinBatchSize = defaultMinBatchSize
+	}
+	if l.MaxBatchSize <= 0 {
+		l.MaxBatchSize = r.maxBacklog / 2
+	}
+	if l.MaxBatchSize < l.MinBatchSize {
+		l.MaxBatchSize = l.MinBatchSize
+	}
+	if l.MinBatchInterval <= 0 {
+		l.MinBatchInterval = defaultMinBatchInterval
+	}
+	if l.MaxBatchInterval <= 0 {
+		l.MaxBatchInterval = defaultMaxBatchInterval
+	}
+	if l.MaxBatchInterval < l.MinBatchInterval {
+		l.MaxBatchInterval = l.MinBatchInterval
+	}
+	if l.TargetLatency <= 0 {
+		l.TargetLatency = defaultTargetLatency
+	}
+	r.batchSize = clampSize(r.batchSize, l.MinBatchSize, l.MaxBatchSize)
+	r.batchInterval = clampInterval(r.batchInterval, l.MinBatchInterval, l.MaxBatchInterval)
+}
+
+// tune adjusts the batch size and interval after a request to the collector
+// which took latency to complete.
+func (r *httpReporter) tune(latency time.Duration, failed bool) {
+	l := r.adaptive
+	if l == nil {
+		return
+	}
+
+	r.batchMtx.Lock()
+	defer r.batchMtx.Unlock()
+
+	if failed || latency > l.TargetLatency {
+		// congestion: multiplicative increase
+		r.batchSize = clampSize(r.batchSize*2, l.MinBatchSize, l.MaxBatchSize)
+		r.batchInterval = clampInterval(r.batchInterval*2, l.MinBatchInterval, l.MaxBatchInterval)
+		return
+	}
+
+	// healthy: additive decrease, unless spans queued up during the request
+	if r.queue.Len() < r.batchSize {
+		r.batchSize = clampSize(r.batchSize-l.MinBatchSize, l.MinBatchSize, l.MaxBatchSize)
+	}
+	r.batchInterval = clampInterval(r.batchInterval-l.MinBatchInterval, l.MinBatchInterval, l.MaxBatchInterval)
+}
+
+func clampSize(n, min, max int) int {
+	if n < min {
+		return min
+	}
+	if n > max {
+		return max
+	}
+	return n
+}
+
+func clampInterval(d, min, max time.Duration) time.Duration {
+	if d < min {
+		return min
+	}
+	if d > max {
+		return max
+	}
+	return d
+}
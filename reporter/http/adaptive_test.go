@@ -0,0 +1,101 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter"
+)
+
+func TestAdaptiveTune(t *testing.T) {
+	r := &httpReporter{
+		batchSize:     100,
+		batchInterval: time.Second,
+		maxBacklog:    1000,
+		batchMtx:      &sync.Mutex{},
+		queue:         reporter.NewBoundedQueue(1000),
+		adaptive:      &AdaptiveLimits{},
+	}
+	r.adaptive.init(r)
+
+	if want, have := 500, r.adaptive.MaxBatchSize; want != have {
+		t.Errorf("max batch size want %d, have %d", want, have)
+	}
+
+	// congestion
+	r.tune(time.Second, false)
+	if want, have := 200, r.batchSize; want != have {
+		t.Errorf("batch size want %d, have %d", want, have)
+	}
+	if want, have := 2*time.Second, r.batchInterval; want != have {
+		t.Errorf("batch interval want %s, have %s", want, have)
+	}
+
+	// failure, bounded by the max batch size
+	r.tune(time.Millisecond, true)
+	r.tune(time.Millisecond, true)
+	if want, have := 500, r.batchSize; want != have {
+		t.Errorf("batch size want %d, have %d", want, have)
+	}
+	if want, have := 8*time.Second, r.batchInterval; want != have {
+		t.Errorf("batch interval want %s, have %s", want, have)
+	}
+
+	// healthy
+	r.tune(time.Millisecond, false)
+	if want, have := 490, r.batchSize; want != have {
+		t.Errorf("batch size want %d, have %d", want, have)
+	}
+	if want, have := 7900*time.Millisecond, r.batchInterval; want != have {
+		t.Errorf("batch interval want %s, have %s", want, have)
+	}
+
+	// healthy but spans queued up during the request
+	for i := 0; i < 900; i++ {
+		r.queue.Push(&model.SpanModel{})
+	}
+	r.tune(time.Millisecond, false)
+	if want, have := 490, r.batchSize; want != have {
+		t.Errorf("batch size want %d, have %d", want, have)
+	}
+	if want, have := 7800*time.Millisecond, r.batchInterval; want != have {
+		t.Errorf("batch interval want %s, have %s", want, have)
+	}
+}
+
+func TestAdaptiveInitClamps(t *testing.T) {
+	r := &httpReporter{
+		batchSize:     1,
+		batchInterval: time.Minute,
+		maxBacklog:    10,
+		batchMtx:      &sync.Mutex{},
+		adaptive:      &AdaptiveLimits{MinBatchSize: 20},
+	}
+	r.adaptive.init(r)
+
+	if want, have := 20, r.adaptive.MaxBatchSize; want != have {
+		t.Errorf("max batch size want %d, have %d", want, have)
+	}
+	if want, have := 20, r.batchSize; want != have {
+		t.Errorf("batch size want %d, have %d", want, have)
+	}
+	if want, have := 10*time.Second, r.batchInterval; want != have {
+		t.Errorf("batch interval want %s, have %s", want, have)
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"time"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter"
+)
+
+// BackpressureStrategy defines how the reporter handles spans sent while its
+// backlog holds MaxBacklog spans.
+type BackpressureStrategy int
+
+// Available backpressure strategies.
+const (
+	// BackpressureDropOldest disposes of the oldest queued spans to make room
+	// for new spans. It is the default strategy.
+	BackpressureDropOldest BackpressureStrategy = iota
+	// BackpressureDropNewest discards the spans sent while the backlog is full.
+	BackpressureDropNewest
+	// BackpressureBlock blocks Send until the backlog has room again or, if
+	// set, the timeout expires, discarding the span.
+	BackpressureBlock
+)
+
+// Backpressure sets the strategy applied when spans are sent faster than they
+// can be delivered and the backlog is full. The timeout limits how long Send
+// blocks with BackpressureBlock, zero blocks until the backlog has room again.
+// Discarded spans are reported to the OnDrop callback with
+// reporter.ErrQueueFull and counted by the OnDiscard callback. With a custom
+// Queue the strategies apply to the queue length reaching MaxBacklog, the
+// queue itself may still dispose of spans.
+func Backpressure(strategy BackpressureStrategy, timeout time.Duration) ReporterOption {
+	return func(r *httpReporter) {
+		r.backpressure = strategy
+		r.blockTimeout = timeout
+	}
+}
+
+// OnDiscard registers a callback function which is invoked with the number of
+// spans discarded at once because the backlog was full. The callback is
+// invoked synchronously from the reporter's goroutines or from Send so it
+// should not block.
+func OnDiscard(fn func(count int)) ReporterOption {
+	return func(r *httpReporter) { r.onDiscard = fn }
+}
+
+// sendBlocking hands s to the loop, waiting up to the block timeout.
+func (r *httpReporter) sendBlocking(s *model.SpanModel) {
+	timer := time.NewTimer(r.blockTimeout)
+	defer timer.Stop()
+
+	select {
+	case r.spanC <- s:
+	case <-timer.C:
+		r.batchMtx.Lock()
+		r.discard([]*model.SpanModel{s})
+		r.batchMtx.Unlock()
+	}
+}
+
+// blocked reports whether the loop stops accepting spans as the backlog is
+// full.
+func (r *httpReporter) blocked() bool {
+	if r.backpressure != BackpressureBlock {
+		return false
+	}
+	r.batchMtx.Lock()
+	defer r.batchMtx.Unlock()
+	return r.queue.Len() >= r.maxBacklog
+}
+
+// notifyRoom wakes up the loop waiting for room in the backlog.
+func (r *httpReporter) notifyRoom() {
+	select {
+	case r.roomC <- struct{}{}:
+	default:
+	}
+}
+
+// discard reports spans discarded because the backlog was full.
+func (r *httpReporter) discard(spans []*model.SpanModel) {
+	r.logger.Printf("backlog too long, disposing %d spans", len(spans))
+	r.drop(spans, reporter.ErrQueueFull)
+	if r.onDiscard != nil {
+		r.onDiscard(len(spans))
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter"
+)
+
+// ErrCircuitOpen is the reason given to drop callbacks for spans dropped while
+// the circuit breaker set by the CircuitBreaker option is open.
+var ErrCircuitOpen = errors.New("collector circuit breaker open")
+
+// CircuitStats holds the state and counters of the circuit breaker.
+type CircuitStats struct {
+	// Open reports whether spans are currently dropped.
+	Open bool
+	// Trips counts how often the circuit opened.
+	Trips uint64
+	// Dropped counts the spans dropped because the circuit was open.
+	Dropped uint64
+}
+
+// CircuitReporter is implemented by the Reporter returned by NewReporter and
+// reports the state and counters of its circuit breaker.
+type CircuitReporter interface {
+	reporter.Reporter
+	CircuitStats() CircuitStats
+}
+
+// CircuitBreaker opens the circuit after failures consecutive batches failed
+// to be delivered, e.g. because the collector is down. While open, the
+// buffered spans and all spans sent to the reporter are dropped right away
+// with ErrCircuitOpen instead of being held in memory and retried. Every
+// probeInterval the reporter accepts spans again and the next batch probes
+// the collector, closing the circuit on success and opening it again on
+// failure. The circuit breaker is disabled by default.
+func CircuitBreaker(failures int, probeInterval time.Duration) ReporterOption {
+	return func(r *httpReporter) {
+		if failures > 0 && probeInterval > 0 {
+			r.breaker = &breaker{threshold: failures, probeInterval: probeInterval}
+		}
+	}
+}
+
+type breaker struct {
+	mtx           sync.Mutex
+	threshold     int
+	probeInterval time.Duration
+	failures      int
+	open          bool
+	nextProbe     time.Time
+	trips         uint64
+	dropped       uint64
+}
+
+// allow reports whether spans are accepted, i.e. the circuit is closed or
+// the next batch probes the collector.
+func (b *breaker) allow() bool {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	return !b.open || !time.Now().Before(b.nextProbe)
+}
+
+// record registers the outcome of a delivered batch and reports whether the
+// buffered spans are to be dropped as the circuit is opened or a probe failed.
+func (b *breaker) record(failed bool) bool {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if !failed {
+		b.failures, b.open = 0, false
+		return false
+	}
+	b.failures++
+	if !b.open && b.failures < b.threshold {
+		return false
+	}
+	if !b.open {
+		b.open = true
+		b.trips++
+	}
+	b.nextProbe = time.Now().Add(b.probeInterval)
+	return true
+}
+
+func (b *breaker) count(dropped int) {
+	b.mtx.Lock()
+	b.dropped += uint64(dropped)
+	b.mtx.Unlock()
+}
+
+func (b *breaker) stats() CircuitStats {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	return CircuitStats{
+		Open:    b.open && time.Now().Before(b.nextProbe),
+		Trips:   b.trips,
+		Dropped: b.dropped,
+	}
+}
+
+// reject drops span if the circuit is open and reports whether it did.
+func (r *httpReporter) reject(span *model.SpanModel) bool {
+	if r.breaker == nil || r.breaker.allow() {
+		return false
+	}
+	r.breaker.count(1)
+	r.drop([]*model.SpanModel{span}, ErrCircuitOpen)
+	return true
+}
+
+// recordDelivery registers the outcome of a request with the circuit breaker
+// and drops the buffered spans if the circuit opens.
+func (r *httpReporter) recordDelivery(failed bool) {
+	if r.breaker == nil || !r.breaker.record(failed) {
+		return
+	}
+	r.batchMtx.Lock()
+	spans := append(r.pending, r.queue.PopBatch(r.queue.Len())...)
+	r.pending = nil
+	r.batchMtx.Unlock()
+
+	r.breaker.count(len(spans))
+	r.drop(spans, ErrCircuitOpen)
+}
+
+// CircuitStats returns the state and counters of the circuit breaker set with
+// the CircuitBreaker option, or zero CircuitStats if the option is not set.
+func (r *httpReporter) CircuitStats() CircuitStats {
+	if r.breaker == nil {
+		return CircuitStats{}
+	}
+	return r.breaker.stats()
+}
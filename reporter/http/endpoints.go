@@ -0,0 +1,200 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openzipkin/zipkin-go/reporter"
+)
+
+// Selection is the strategy for spreading batches over multiple collector
+// endpoints.
+type Selection int
+
+// Available endpoint selection strategies
+const (
+	// RoundRobin sends batches to each of the healthy endpoints in turn.
+	RoundRobin Selection = iota
+	// LeastFailures sends batches to the healthy endpoint with the fewest
+	// consecutive failures, rotating between endpoints on ties.
+	LeastFailures
+)
+
+const (
+	maxEndpointFailures = 3                // consecutive failures marking an endpoint unhealthy
+	endpointCooldown    = 10 * time.Second // time an unhealthy endpoint is skipped
+	resolveTimeout      = 5 * time.Second  // timeout for resolving a collector hostname
+)
+
+// endpoint holds a collector URL and its health. Endpoints resolved to an IP
+// address hold the original host to send in the Host header.
+type endpoint struct {
+	url       string
+	host      string
+	failures  int
+	downUntil time.Time
+
+	// serializer negotiated with the collector, only accessed by the send
+	// loop
+	serializer reporter.SpanSerializer
+}
+
+// endpointPool selects the collector endpoint to send a batch to. Endpoints
+// failing maxEndpointFailures times in a row are skipped for endpointCooldown
+// unless no healthy endpoint remains.
+type endpointPool struct {
+	mtx       sync.Mutex
+	urls      []string
+	endpoints []*endpoint
+	selection Selection
+	next      int
+}
+
+func newEndpointPool(urls []string, selection Selection) *endpointPool {
+	p := &endpointPool{urls: urls, selection: selection}
+	for _, url := range urls {
+		p.endpoints = append(p.endpoints, &endpoint{url: url})
+	}
+	return p
+}
+
+// pick returns the endpoint to send the next batch to.
+func (p *endpointPool) pick() *endpoint {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	var (
+		now      = time.Now()
+		selected *endpoint
+		index    int
+	)
+	for i := 0; i < len(p.endpoints); i++ {
+		idx := (p.next + i) % len(p.endpoints)
+		e := p.endpoints[idx]
+		if e.downUntil.After(now) {
+			continue
+		}
+		if selected == nil || (p.selection == LeastFailures && e.failures < selected.failures) {
+			selected, index = e, idx
+		}
+		if p.selection == RoundRobin {
+			break
+		}
+	}
+	if selected == nil {
+		// no healthy endpoints, try the one recovering first
+		for idx, e := range p.endpoints {
+			if selected == nil || e.downUntil.Before(selected.downUntil) {
+				selected, index = e, idx
+			}
+		}
+	}
+	p.next = (index + 1) % len(p.endpoints)
+	return selected
+}
+
+// size returns the number of endpoints in the pool.
+func (p *endpointPool) size() int {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	return len(p.endpoints)
+}
+
+// report records the outcome of a request to e.
+func (p *endpointPool) report(e *endpoint, failed bool) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if !failed {
+		e.failures, e.downUntil = 0, time.Time{}
+		return
+	}
+	e.failures++
+	if e.failures >= maxEndpointFailures {
+		e.downUntil = time.Now().Add(endpointCooldown)
+	}
+}
+
+// resolve replaces the endpoints of plain HTTP collector URLs holding a
+// hostname by an endpoint for each of the IP addresses the hostname resolves
+// to. The health of endpoints found before is retained. If resolving fails the
+// endpoints of the URL are kept as is.
+func (p *endpointPool) resolve(lookup func(ctx context.Context, host string) ([]string, error)) {
+	p.mtx.Lock()
+	previous := p.endpoints
+	current := make(map[string]*endpoint, len(previous))
+	for _, e := range previous {
+		current[e.url] = e
+	}
+	p.mtx.Unlock()
+
+	var endpoints []*endpoint
+	for _, rawURL := range p.urls {
+		u, err := url.Parse(rawURL)
+		if err != nil || u.Scheme != "http" || net.ParseIP(u.Hostname()) != nil {
+			// TLS verification requires the hostname, so only plain HTTP
+			// collectors are rotated
+			endpoints = append(endpoints, existing(current, &endpoint{url: rawURL}))
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+		addrs, err := lookup(ctx, u.Hostname())
+		cancel()
+		if err != nil || len(addrs) == 0 {
+			// keep the previous endpoints in order to not disturb rotation
+			for _, e := range previous {
+				if e.url == rawURL || e.host == u.Host {
+					endpoints = append(endpoints, e)
+				}
+			}
+			continue
+		}
+
+		for _, addr := range addrs {
+			ipURL := *u
+			if strings.Contains(addr, ":") {
+				addr = "[" + addr + "]"
+			}
+			if port := u.Port(); port != "" {
+				addr += ":" + port
+			}
+			ipURL.Host = addr
+			endpoints = append(endpoints, existing(current, &endpoint{url: ipURL.String(), host: u.Host}))
+		}
+	}
+
+	p.mtx.Lock()
+	p.endpoints = endpoints
+	if p.next >= len(endpoints) {
+		p.next = 0
+	}
+	p.mtx.Unlock()
+}
+
+// existing returns the endpoint found in current for the URL of e, or e if not
+// found.
+func existing(current map[string]*endpoint, e *endpoint) *endpoint {
+	if found, ok := current[e.url]; ok {
+		return found
+	}
+	return e
+}
@@ -0,0 +1,139 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEndpointPoolRoundRobin(t *testing.T) {
+	p := newEndpointPool([]string{"a", "b", "c"}, RoundRobin)
+
+	var have []string
+	for i := 0; i < 4; i++ {
+		have = append(have, p.pick().url)
+	}
+	if want := "a b c a"; want != strings.Join(have, " ") {
+		t.Errorf("picked endpoints want %q, have %q", want, strings.Join(have, " "))
+	}
+
+	// b becomes unhealthy
+	b := p.endpoints[1]
+	for i := 0; i < maxEndpointFailures; i++ {
+		p.report(b, true)
+	}
+	have = nil
+	for i := 0; i < 4; i++ {
+		have = append(have, p.pick().url)
+	}
+	if want := "c a c a"; want != strings.Join(have, " ") {
+		t.Errorf("picked endpoints want %q, have %q", want, strings.Join(have, " "))
+	}
+
+	// all endpoints unhealthy, the one recovering first is picked
+	for _, e := range p.endpoints {
+		e.downUntil = time.Now().Add(time.Minute)
+	}
+	b.downUntil = time.Now().Add(time.Second)
+	if want, have := "b", p.pick().url; want != have {
+		t.Errorf("picked endpoint want %q, have %q", want, have)
+	}
+
+	// success restores health
+	p.report(b, false)
+	if b.failures != 0 || !b.downUntil.IsZero() {
+		t.Errorf("expected healthy endpoint, have %d failures", b.failures)
+	}
+}
+
+func TestEndpointPoolLeastFailures(t *testing.T) {
+	p := newEndpointPool([]string{"a", "b", "c"}, LeastFailures)
+
+	p.report(p.endpoints[0], true)
+	p.report(p.endpoints[0], true)
+	p.report(p.endpoints[1], true)
+
+	if want, have := "c", p.pick().url; want != have {
+		t.Errorf("picked endpoint want %q, have %q", want, have)
+	}
+	p.report(p.endpoints[2], true)
+
+	// b and c tie, rotation continues after c
+	if want, have := "b", p.pick().url; want != have {
+		t.Errorf("picked endpoint want %q, have %q", want, have)
+	}
+}
+
+func TestEndpointPoolResolve(t *testing.T) {
+	var (
+		addrs []string
+		err   error
+	)
+	lookup := func(_ context.Context, host string) ([]string, error) {
+		if host != "collector" {
+			t.Errorf("unexpected host lookup: %s", host)
+		}
+		return addrs, err
+	}
+
+	p := newEndpointPool([]string{
+		"http://collector:9411/api/v2/spans",
+		"https://collector:9411/api/v2/spans",
+		"http://10.0.0.9/api/v2/spans",
+	}, RoundRobin)
+
+	urls := func() string {
+		var s []string
+		for _, e := range p.endpoints {
+			s = append(s, e.url+"|"+e.host)
+		}
+		return strings.Join(s, " ")
+	}
+
+	addrs = []string{"10.0.0.1", "fe80::1"}
+	p.resolve(lookup)
+	want := "http://10.0.0.1:9411/api/v2/spans|collector:9411 " +
+		"http://[fe80::1]:9411/api/v2/spans|collector:9411 " +
+		"https://collector:9411/api/v2/spans| " +
+		"http://10.0.0.9/api/v2/spans|"
+	if have := urls(); want != have {
+		t.Errorf("endpoints want %q, have %q", want, have)
+	}
+
+	// health is retained for known addresses
+	p.report(p.endpoints[0], true)
+	addrs = []string{"10.0.0.1", "10.0.0.2"}
+	p.resolve(lookup)
+	if want, have := 1, p.endpoints[0].failures; want != have {
+		t.Errorf("failures want %d, have %d", want, have)
+	}
+	if want, have := "http://10.0.0.2:9411/api/v2/spans", p.endpoints[1].url; want != have {
+		t.Errorf("endpoint want %q, have %q", want, have)
+	}
+
+	// failed lookups keep the current endpoints in order
+	want = urls()
+	err = errors.New("no such host")
+	for i := 0; i < 10; i++ {
+		p.resolve(lookup)
+		if have := urls(); want != have {
+			t.Fatalf("endpoints want %q, have %q", want, have)
+		}
+	}
+}
@@ -19,7 +19,12 @@ package http
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"sync"
@@ -39,27 +44,91 @@ const (
 
 // httpReporter will send spans to a Zipkin HTTP Collector using Zipkin V2 API.
 type httpReporter struct {
-	url           string
-	client        *http.Client
-	logger        *log.Logger
-	batchInterval time.Duration
-	batchSize     int
-	maxBacklog    int
-	batchMtx      *sync.Mutex
-	batch         []*model.SpanModel
-	spanC         chan *model.SpanModel
-	sendC         chan struct{}
-	quit          chan struct{}
-	shutdown      chan error
-	reqCallback   RequestCallbackFn
-	serializer    reporter.SpanSerializer
+	url              string
+	urls             []string
+	selection        Selection
+	endpoints        *endpointPool
+	resolveEvery     time.Duration
+	lookupHost       func(ctx context.Context, host string) ([]string, error)
+	sockets          map[string]unixSocket
+	client           *http.Client
+	transport        http.RoundTripper
+	tlsConfig        *tls.Config
+	dial             DialFunc
+	headers          http.Header
+	logger           reporter.Logger
+	batchInterval    time.Duration
+	batchSize        int
+	maxBacklog       int
+	batchMtx         *sync.Mutex
+	queue            reporter.Queue
+	pending          []*model.SpanModel
+	pendingOldest    time.Time
+	spanC            chan *model.SpanModel
+	sendC            chan struct{}
+	flushC           chan struct{}
+	roomC            chan struct{}
+	sendMtx          sync.Mutex
+	quit             chan struct{}
+	shutdown         chan error
+	reqCallback      RequestCallbackFn
+	serializer       reporter.SpanSerializer
+	compressionLevel int
+	retryAttempts    int
+	retryBackoff     time.Duration
+	retryBackoffMax  time.Duration
+	breaker          *breaker
+	backpressure     BackpressureStrategy
+	blockTimeout     time.Duration
+	onDiscard        func(count int)
+	compressor       *compressor
+	negotiate        []reporter.SpanSerializer
+	onDrop           func(model.SpanModel, error)
+	adaptive         *AdaptiveLimits
+	metrics          func(BatchMetrics)
+	onBatchSent      func(count, bytes int, duration time.Duration)
+	onBatchFailed    func(err error, count int)
+	oldest           time.Time
 }
 
 // Send implements reporter
 func (r *httpReporter) Send(s model.SpanModel) {
+	if r.backpressure == BackpressureBlock && r.blockTimeout > 0 {
+		r.sendBlocking(&s)
+		return
+	}
 	r.spanC <- &s
 }
 
+// Flush implements reporter.Flusher. It sends the queued spans to the
+// collectors, using as many requests as needed, and returns the error of the
+// first failing request. Spans of failed requests are kept for the next batch
+// or dropped as with batches sent in the background. Flush does nothing once
+// the reporter is closed.
+func (r *httpReporter) Flush() error {
+	select {
+	case r.flushC <- struct{}{}:
+	case <-r.quit:
+		return nil
+	}
+
+	r.sendMtx.Lock()
+	defer r.sendMtx.Unlock()
+	for r.queued() > 0 {
+		if err := r.sendBatch(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// queued returns the number of spans waiting to be sent.
+func (r *httpReporter) queued() int {
+	r.batchMtx.Lock()
+	defer r.batchMtx.Unlock()
+	return r.queue.Len() + len(r.pending)
+}
+
 // Close implements reporter
 func (r *httpReporter) Close() error {
 	close(r.quit)
@@ -67,26 +136,42 @@ func (r *httpReporter) Close() error {
 }
 
 func (r *httpReporter) loop() {
+	tick := r.batchInterval / 10
+	if r.adaptive != nil && r.adaptive.MinBatchInterval/10 < tick {
+		tick = r.adaptive.MinBatchInterval / 10
+	}
+
 	var (
-		nextSend   = time.Now().Add(r.batchInterval)
-		ticker     = time.NewTicker(r.batchInterval / 10)
+		nextSend   = time.Now().Add(r.interval())
+		ticker     = time.NewTicker(tick)
 		tickerChan = ticker.C
 	)
 	defer ticker.Stop()
 
 	for {
+		spanC := r.spanC
+		if r.blocked() {
+			// stop accepting spans until a batch made room in the backlog
+			spanC = nil
+			r.enqueueSend()
+		}
 		select {
-		case span := <-r.spanC:
-			currentBatchSize := r.append(span)
-			if currentBatchSize >= r.batchSize {
-				nextSend = time.Now().Add(r.batchInterval)
+		case <-r.roomC:
+		case span := <-spanC:
+			if r.reject(span) {
+				continue
+			}
+			if full := r.append(span); full {
+				nextSend = time.Now().Add(r.interval())
 				r.enqueueSend()
 			}
 		case <-tickerChan:
 			if time.Now().After(nextSend) {
-				nextSend = time.Now().Add(r.batchInterval)
+				nextSend = time.Now().Add(r.interval())
 				r.enqueueSend()
 			}
+		case <-r.flushC:
+			// spans sent before the Flush call have been queued
 		case <-r.quit:
 			close(r.sendC)
 			return
@@ -94,11 +179,47 @@ func (r *httpReporter) loop() {
 	}
 }
 
+func (r *httpReporter) resolveLoop() {
+	ticker := time.NewTicker(r.resolveEvery)
+	defer ticker.Stop()
+
+	for {
+		r.endpoints.resolve(r.lookupHost)
+		select {
+		case <-ticker.C:
+		case <-r.quit:
+			return
+		}
+	}
+}
+
 func (r *httpReporter) sendLoop() {
 	for range r.sendC {
+		r.sendMtx.Lock()
 		_ = r.sendBatch()
+		r.sendMtx.Unlock()
+	}
+	r.sendMtx.Lock()
+	defer r.sendMtx.Unlock()
+	err := r.sendBatch()
+	if err != nil {
+		// reporter is shutting down, spans still pending or queued are lost
+		r.batchMtx.Lock()
+		r.drop(r.pending, err)
+		r.drop(r.queue.PopBatch(r.queue.Len()), err)
+		r.pending = nil
+		r.batchMtx.Unlock()
+	}
+	r.shutdown <- err
+}
+
+func (r *httpReporter) drop(spans []*model.SpanModel, reason error) {
+	if r.onDrop == nil {
+		return
+	}
+	for _, span := range spans {
+		r.onDrop(*span, reason)
 	}
-	r.shutdown <- r.sendBatch()
 }
 
 func (r *httpReporter) enqueueSend() {
@@ -109,63 +230,182 @@ func (r *httpReporter) enqueueSend() {
 	}
 }
 
-func (r *httpReporter) append(span *model.SpanModel) (newBatchSize int) {
+// interval returns the current batch interval.
+func (r *httpReporter) interval() time.Duration {
+	r.batchMtx.Lock()
+	defer r.batchMtx.Unlock()
+	return r.batchInterval
+}
+
+func (r *httpReporter) append(span *model.SpanModel) (full bool) {
 	r.batchMtx.Lock()
 
-	r.batch = append(r.batch, span)
-	if len(r.batch) > r.maxBacklog {
-		dispose := len(r.batch) - r.maxBacklog
-		r.logger.Printf("backlog too long, disposing %d spans", dispose)
-		r.batch = r.batch[dispose:]
+	if r.queue.Len() == 0 {
+		r.oldest = time.Now()
 	}
-	newBatchSize = len(r.batch)
+	var dropped []*model.SpanModel
+	if r.backpressure == BackpressureDropNewest && r.queue.Len() >= r.maxBacklog {
+		dropped = []*model.SpanModel{span}
+	} else {
+		dropped = r.queue.Push(span)
+	}
+	if len(dropped) > 0 {
+		r.discard(dropped)
+	}
+	full = r.queue.Len() >= r.batchSize
 
 	r.batchMtx.Unlock()
 	return
 }
 
 func (r *httpReporter) sendBatch() error {
-	// Select all current spans in the batch to be sent
+	// Select the spans of a failed previous attempt and the queued spans to
+	// be sent, up to the maximum backlog
 	r.batchMtx.Lock()
-	sendBatch := r.batch[:]
+	sendBatch, oldest := r.pending, r.oldest
+	if len(sendBatch) > 0 {
+		oldest = r.pendingOldest
+	}
+	r.pending = nil
+	if n := r.maxBacklog - len(sendBatch); n > 0 {
+		sendBatch = append(sendBatch, r.queue.PopBatch(n)...)
+	}
+	if r.queue.Len() > 0 {
+		r.oldest = time.Now()
+	}
 	r.batchMtx.Unlock()
+	r.notifyRoom()
 
 	if len(sendBatch) == 0 {
 		return nil
 	}
 
-	body, err := r.serializer.Serialize(sendBatch)
+	start := time.Now()
+	m := BatchMetrics{Spans: len(sendBatch), QueueTime: start.Sub(oldest)}
+	var requested bool
+	defer func() {
+		if requested {
+			r.recordDelivery(m.Err != nil)
+		}
+		if r.metrics != nil {
+			r.metrics(m)
+		}
+		if m.Err != nil {
+			if r.onBatchFailed != nil {
+				r.onBatchFailed(m.Err, m.Spans)
+			}
+		} else if r.onBatchSent != nil {
+			r.onBatchSent(m.Spans, m.Bytes, m.TransportTime)
+		}
+	}()
+
+	ep := r.endpoints.pick()
+	serializer := r.serializerFor(ep)
+	body, err := serializer.Serialize(sendBatch)
+	m.SerializeTime, m.Bytes, m.Err = time.Since(start), len(body), err
 	if err != nil {
 		r.logger.Printf("failed when marshalling the spans batch: %s\n", err.Error())
+		// serialization will not succeed on retry so drop the spans
+		r.drop(sendBatch, err)
 		return err
 	}
 
-	req, err := http.NewRequest("POST", r.url, bytes.NewReader(body))
+	if r.compressor != nil {
+		if body, err = r.compressor.compress(body); err != nil {
+			r.logger.Printf("failed when compressing the spans batch: %s\n", err.Error())
+			m.Err = err
+			r.drop(sendBatch, err)
+			return err
+		}
+		m.CompressedBytes = len(body)
+	}
+
+	target, host := ep.url, ep.host
+	if s, ok := r.sockets[ep.url]; ok {
+		target, host = s.url, unixHost
+	}
+	req, err := r.newRequest(target, host, serializer, body)
 	if err != nil {
 		r.logger.Printf("failed when creating the request: %s\n", err.Error())
+		m.Err = err
+		r.retry(sendBatch, oldest)
 		return err
 	}
-	req.Header.Set("Content-Type", r.serializer.ContentType())
-	if r.reqCallback != nil {
-		r.reqCallback(req)
-	}
 
+	start, requested = time.Now(), true
 	resp, err := r.client.Do(req)
+	for attempt := 1; attempt < r.retryAttempts && retryable(resp, err); attempt++ {
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+		time.Sleep(r.backoffDuration(attempt))
+		if req, err = r.newRequest(target, host, serializer, body); err != nil {
+			break
+		}
+		resp, err = r.client.Do(req)
+	}
+	m.TransportTime = time.Since(start)
 	if err != nil {
 		r.logger.Printf("failed to send the request: %s\n", err.Error())
+		r.endpoints.report(ep, true)
+		r.tune(m.TransportTime, true)
+		m.Err = err
+		r.retry(sendBatch, oldest)
 		return err
 	}
 	_ = resp.Body.Close()
-	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+	failed := resp.StatusCode < 200 || resp.StatusCode > 299
+	// failing or overloaded collectors might accept the batch on another
+	// endpoint
+	retryable := resp.StatusCode > 499 || resp.StatusCode == http.StatusTooManyRequests
+	r.endpoints.report(ep, retryable)
+	r.tune(m.TransportTime, failed)
+	if failed {
 		r.logger.Printf("failed the request with status code %d\n", resp.StatusCode)
+		m.Err = fmt.Errorf("failed the request with status code %d", resp.StatusCode)
+		if resp.StatusCode == http.StatusUnsupportedMediaType && r.rejected(ep, serializer) {
+			r.retry(sendBatch, oldest)
+			return m.Err
+		}
+		if retryable && r.endpoints.size() > 1 {
+			r.retry(sendBatch, oldest)
+			return m.Err
+		}
+		r.drop(sendBatch, m.Err)
+		return m.Err
+	}
+
+	return nil
+}
+
+// newRequest creates the request posting body to the collector.
+func (r *httpReporter) newRequest(target, host string, serializer reporter.SpanSerializer, body []byte) (*http.Request, error) {
+	req, err := http.NewRequest("POST", target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
 	}
+	// make sure instrumented transports do not trace the delivery of spans
+	req = req.WithContext(reporter.NewUntracedContext(context.Background()))
+	if host != "" {
+		req.Host = host
+	}
+	r.setHeaders(req)
+	req.Header.Set("Content-Type", serializer.ContentType())
+	if r.compressor != nil {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	if r.reqCallback != nil {
+		r.reqCallback(req)
+	}
+	return req, nil
+}
 
-	// Remove sent spans from the batch even if they were not saved
+// retry keeps the spans of a failed request to be sent again with the next
+// batch.
+func (r *httpReporter) retry(spans []*model.SpanModel, oldest time.Time) {
 	r.batchMtx.Lock()
-	r.batch = r.batch[len(sendBatch):]
+	r.pending, r.pendingOldest = spans, oldest
 	r.batchMtx.Unlock()
-
-	return nil
 }
 
 // RequestCallbackFn receives the initialized request from the Collector before
@@ -199,6 +439,29 @@ func BatchInterval(d time.Duration) ReporterOption {
 	return func(r *httpReporter) { r.batchInterval = d }
 }
 
+// Endpoints adds collector URLs to spread batches over, in addition to the URL
+// passed to NewReporter, using the provided selection strategy. This allows
+// reporting to a pool of collectors without an external load balancer.
+// Endpoints failing repeatedly due to transport errors, 5xx or 429 responses
+// are skipped for a while. Batches failing with these errors are retried with
+// the next endpoint.
+func Endpoints(urls []string, selection Selection) ReporterOption {
+	return func(r *httpReporter) {
+		r.urls = append(r.urls, urls...)
+		r.selection = selection
+	}
+}
+
+// ResolveInterval enables periodic re-resolution of collector hostnames. Each
+// batch is sent to one of the IP addresses a hostname resolves to, following
+// the selection strategy set by Endpoints, instead of pinning all traffic to a
+// single collector instance, e.g. behind a headless Kubernetes service. As TLS
+// verification requires the hostname, only plain HTTP collector URLs are
+// rotated. Resolution is disabled by default.
+func ResolveInterval(d time.Duration) ReporterOption {
+	return func(r *httpReporter) { r.resolveEvery = d }
+}
+
 // Client sets a custom http client to use.
 func Client(client *http.Client) ReporterOption {
 	return func(r *httpReporter) { r.client = client }
@@ -211,11 +474,22 @@ func RequestCallback(rc RequestCallbackFn) ReporterOption {
 }
 
 // Logger sets the logger used to report errors in the collection
-// process.
-func Logger(l *log.Logger) ReporterOption {
+// process. It accepts a *log.Logger or any other reporter.Logger, e.g. one
+// returned by reporter.SlogLogger.
+func Logger(l reporter.Logger) ReporterOption {
 	return func(r *httpReporter) { r.logger = l }
 }
 
+// Queue sets the queue holding spans until they are sent, allowing custom
+// implementations like disk backed queues or queues bounded by bytes, or
+// reporter.NewPriorityQueue to keep error spans when overloaded. When
+// set, MaxBacklog only limits the number of spans sent per request. The
+// default queue holds up to MaxBacklog spans, disposing of the oldest spans
+// when full.
+func Queue(q reporter.Queue) ReporterOption {
+	return func(r *httpReporter) { r.queue = q }
+}
+
 // Serializer sets the serialization function to use for sending span data to
 // Zipkin.
 func Serializer(serializer reporter.SpanSerializer) ReporterOption {
@@ -226,9 +500,50 @@ func Serializer(serializer reporter.SpanSerializer) ReporterOption {
 	}
 }
 
+// Negotiate enables content type negotiation with the collectors, simplifying
+// rollouts where collectors are upgraded gradually. Before sending the first
+// batch to a collector, the reporter probes it with an OPTIONS request and
+// uses the first of the provided serializers, in order of preference, whose
+// content type the collector lists in its Accept-Post or Accept response
+// header. Collectors not advertising a supported content type receive batches
+// encoded by the Serializer option. A batch rejected with status 415 is sent
+// again using the Serializer option.
+func Negotiate(serializers ...reporter.SpanSerializer) ReporterOption {
+	return func(r *httpReporter) { r.negotiate = serializers }
+}
+
+// OnDrop registers a callback function which is invoked for every span the
+// reporter fails to deliver, together with the reason. Spans are dropped when
+// the backlog overflows, on serialization failures, when the collector responds
+// with a non 2xx status code and when the reporter can not deliver the last
+// batch on Close. The callback is invoked synchronously from the reporter's
+// goroutines so it should not block.
+func OnDrop(fn func(span model.SpanModel, reason error)) ReporterOption {
+	return func(r *httpReporter) { r.onDrop = fn }
+}
+
+// OnBatchSent registers a callback function which is invoked for every batch
+// accepted by the collector with the number of spans, the size of the payload
+// and the duration of the request.
+func OnBatchSent(fn func(count, bytes int, duration time.Duration)) ReporterOption {
+	return func(r *httpReporter) { r.onBatchSent = fn }
+}
+
+// OnBatchFailed registers a callback function which is invoked for every batch
+// the reporter fails to deliver with the reason and the number of spans.
+func OnBatchFailed(fn func(err error, count int)) ReporterOption {
+	return func(r *httpReporter) { r.onBatchFailed = fn }
+}
+
 // NewReporter returns a new HTTP Reporter.
 // url should be the endpoint to send the spans to, e.g.
 // http://localhost:9411/api/v2/spans
+//
+// Collectors listening on a unix domain socket are addressed by URLs of the
+// form unix:///var/run/zipkin.sock:/api/v2/spans, holding the socket path and
+// optionally the request path which defaults to /api/v2/spans. Sockets are
+// dialed by the default transport, so a custom client set by the Client option
+// should leave its Transport unset when using them.
 func NewReporter(url string, opts ...ReporterOption) reporter.Reporter {
 	r := httpReporter{
 		url:           url,
@@ -237,21 +552,56 @@ func NewReporter(url string, opts ...ReporterOption) reporter.Reporter {
 		batchInterval: defaultBatchInterval,
 		batchSize:     defaultBatchSize,
 		maxBacklog:    defaultMaxBacklog,
-		batch:         []*model.SpanModel{},
 		spanC:         make(chan *model.SpanModel),
 		sendC:         make(chan struct{}, 1),
+		flushC:        make(chan struct{}),
+		roomC:         make(chan struct{}, 1),
 		quit:          make(chan struct{}, 1),
 		shutdown:      make(chan error, 1),
 		batchMtx:      &sync.Mutex{},
 		serializer:    reporter.JSONSerializer{},
+		lookupHost:    net.DefaultResolver.LookupHost,
 	}
 
 	for _, opt := range opts {
 		opt(&r)
 	}
 
+	urls := append([]string{r.url}, r.urls...)
+	for idx, u := range urls {
+		if s, ok := parseUnixURL(u, idx); ok {
+			if r.sockets == nil {
+				r.sockets = make(map[string]unixSocket)
+			}
+			r.sockets[u] = s
+		}
+	}
+	r.configureClient()
+
+	r.endpoints = newEndpointPool(urls, r.selection)
+
+	if r.compressionLevel != gzip.NoCompression {
+		c, err := newCompressor(r.compressionLevel)
+		if err != nil {
+			r.logger.Printf("invalid compression level %d, using default compression\n", r.compressionLevel)
+			c, _ = newCompressor(gzip.DefaultCompression)
+		}
+		r.compressor = c
+	}
+
+	if r.queue == nil {
+		r.queue = reporter.NewBoundedQueue(r.maxBacklog)
+	}
+
+	if r.adaptive != nil {
+		r.adaptive.init(&r)
+	}
+
 	go r.loop()
 	go r.sendLoop()
+	if r.resolveEvery > 0 {
+		go r.resolveLoop()
+	}
 
 	return &r
 }
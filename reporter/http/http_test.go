@@ -16,16 +16,30 @@ package http_test
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"io/ioutil"
+	"log"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/openzipkin/zipkin-go/idgenerator"
 	"github.com/openzipkin/zipkin-go/model"
+	zipkinproto "github.com/openzipkin/zipkin-go/proto/v2"
 	"github.com/openzipkin/zipkin-go/reporter"
 	zipkinhttp "github.com/openzipkin/zipkin-go/reporter/http"
 )
@@ -167,3 +181,878 @@ func TestSpanIsReportedAfterBatchSize(t *testing.T) {
 		t.Errorf("unexpected number of spans received\nhave: %d, want: %d", aNumSpans, eNumSpans)
 	}
 }
+
+type untracedRoundTripper struct {
+	untraced int32
+}
+
+func (rt *untracedRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if reporter.IsUntracedContext(req.Context()) {
+		atomic.StoreInt32(&rt.untraced, 1)
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestRequestIsFlaggedUntraced(t *testing.T) {
+	serializer := reporter.JSONSerializer{}
+
+	spans := generateSpans(1)
+	ts := newTestServer(t, spans, serializer, func(int) {})
+	defer ts.Close()
+
+	rt := &untracedRoundTripper{}
+	rep := zipkinhttp.NewReporter(ts.URL,
+		zipkinhttp.Serializer(serializer),
+		zipkinhttp.Client(&http.Client{Transport: rt}),
+	)
+	rep.Send(*spans[0])
+	rep.Close()
+
+	if atomic.LoadInt32(&rt.untraced) != 1 {
+		t.Error("expected reporter request to be flagged as untraced")
+	}
+}
+
+type errSerializer struct{}
+
+func (errSerializer) Serialize([]*model.SpanModel) ([]byte, error) {
+	return nil, errors.New("serialization failed")
+}
+
+func (errSerializer) ContentType() string { return "application/json" }
+
+func TestOnDrop(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	cases := []struct {
+		serializer reporter.SpanSerializer
+		maxBacklog int
+		numSpans   int
+	}{
+		{reporter.JSONSerializer{}, 100, 3},
+		{errSerializer{}, 100, 3},
+		{reporter.JSONSerializer{}, 1, 3},
+	}
+
+	for idx, c := range cases {
+		var numDropped int64
+		rep := zipkinhttp.NewReporter(ts.URL,
+			zipkinhttp.Serializer(c.serializer),
+			zipkinhttp.MaxBacklog(c.maxBacklog),
+			zipkinhttp.BatchInterval(time.Hour),
+			zipkinhttp.Logger(log.New(ioutil.Discard, "", 0)),
+			zipkinhttp.OnDrop(func(_ model.SpanModel, reason error) {
+				if reason == nil {
+					t.Errorf("[%d] expected drop reason", idx)
+				}
+				atomic.AddInt64(&numDropped, 1)
+			}),
+		)
+		for _, span := range generateSpans(c.numSpans) {
+			rep.Send(*span)
+		}
+		rep.Close()
+
+		if want, have := int64(c.numSpans), atomic.LoadInt64(&numDropped); want != have {
+			t.Errorf("[%d] unexpected number of dropped spans, want %d, have %d", idx, want, have)
+		}
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	var status int32 = http.StatusAccepted
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+		w.WriteHeader(int(atomic.LoadInt32(&status)))
+	}))
+	defer ts.Close()
+
+	for _, code := range []int32{http.StatusAccepted, http.StatusInternalServerError} {
+		atomic.StoreInt32(&status, code)
+
+		var metrics []zipkinhttp.BatchMetrics
+		rep := zipkinhttp.NewReporter(ts.URL,
+			zipkinhttp.BatchInterval(time.Hour),
+			zipkinhttp.Logger(log.New(ioutil.Discard, "", 0)),
+			zipkinhttp.Metrics(func(m zipkinhttp.BatchMetrics) {
+				metrics = append(metrics, m)
+			}),
+		)
+		spans := generateSpans(5)
+		for _, span := range spans {
+			rep.Send(*span)
+		}
+		rep.Close()
+
+		if want, have := 1, len(metrics); want != have {
+			t.Fatalf("[%d] batches want %d, have %d", code, want, have)
+		}
+		m := metrics[0]
+		if want, have := len(spans), m.Spans; want != have {
+			t.Errorf("[%d] spans want %d, have %d", code, want, have)
+		}
+		body, _ := reporter.JSONSerializer{}.Serialize(spans)
+		if want, have := len(body), m.Bytes; want != have {
+			t.Errorf("[%d] bytes want %d, have %d", code, want, have)
+		}
+		if m.TransportTime < 10*time.Millisecond {
+			t.Errorf("[%d] expected transport time of at least 10ms, have %s", code, m.TransportTime)
+		}
+		if m.QueueTime <= 0 {
+			t.Errorf("[%d] expected queue time, have %s", code, m.QueueTime)
+		}
+		if want, have := code != http.StatusAccepted, m.Err != nil; want != have {
+			t.Errorf("[%d] error want %t, have %v", code, want, m.Err)
+		}
+	}
+}
+
+func TestBatchCallbacks(t *testing.T) {
+	var status int32 = http.StatusAccepted
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(int(atomic.LoadInt32(&status)))
+	}))
+	defer ts.Close()
+
+	var (
+		sent, failed int
+		sentBytes    int
+		reason       error
+	)
+	newReporter := func() reporter.Reporter {
+		return zipkinhttp.NewReporter(ts.URL,
+			zipkinhttp.BatchInterval(time.Hour),
+			zipkinhttp.Logger(log.New(ioutil.Discard, "", 0)),
+			zipkinhttp.OnBatchSent(func(count, bytes int, duration time.Duration) {
+				sent, sentBytes = count, bytes
+				if duration <= 0 {
+					t.Errorf("expected positive duration, have %s", duration)
+				}
+			}),
+			zipkinhttp.OnBatchFailed(func(err error, count int) {
+				reason, failed = err, count
+			}),
+		)
+	}
+
+	spans := generateSpans(3)
+	rep := newReporter()
+	for _, span := range spans {
+		rep.Send(*span)
+	}
+	rep.Close()
+
+	body, _ := reporter.JSONSerializer{}.Serialize(spans)
+	if want, have := 3, sent; want != have {
+		t.Errorf("sent spans want %d, have %d", want, have)
+	}
+	if want, have := len(body), sentBytes; want != have {
+		t.Errorf("sent bytes want %d, have %d", want, have)
+	}
+	if failed != 0 || reason != nil {
+		t.Errorf("unexpected failed batch: %d spans, %v", failed, reason)
+	}
+
+	atomic.StoreInt32(&status, http.StatusServiceUnavailable)
+	sent = 0
+	rep = newReporter()
+	for _, span := range spans {
+		rep.Send(*span)
+	}
+	rep.Close()
+
+	if want, have := 3, failed; want != have {
+		t.Errorf("failed spans want %d, have %d", want, have)
+	}
+	if reason == nil {
+		t.Error("expected failure reason")
+	}
+	if sent != 0 {
+		t.Errorf("unexpected sent batch of %d spans", sent)
+	}
+}
+
+type countingQueue struct {
+	reporter.Queue
+	pushed int
+}
+
+func (q *countingQueue) Push(span *model.SpanModel) []*model.SpanModel {
+	q.pushed++
+	return q.Queue.Push(span)
+}
+
+func TestCustomQueue(t *testing.T) {
+	serializer := reporter.JSONSerializer{}
+
+	var numSpans int64
+	spans := generateSpans(5)
+	ts := newTestServer(t, spans, serializer, func(num int) { atomic.AddInt64(&numSpans, int64(num)) })
+	defer ts.Close()
+
+	q := &countingQueue{Queue: reporter.NewBoundedQueue(10)}
+	rep := zipkinhttp.NewReporter(ts.URL, zipkinhttp.Queue(q))
+	for _, span := range spans {
+		rep.Send(*span)
+	}
+	rep.Close()
+
+	if want, have := len(spans), q.pushed; want != have {
+		t.Errorf("pushed spans want %d, have %d", want, have)
+	}
+	if want, have := len(spans), int(atomic.LoadInt64(&numSpans)); want != have {
+		t.Errorf("received spans want %d, have %d", want, have)
+	}
+}
+
+type failingRoundTripper struct {
+	failures int32
+}
+
+func (rt *failingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if atomic.AddInt32(&rt.failures, -1) >= 0 {
+		return nil, errors.New("connection refused")
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestFailedBatchIsRetried(t *testing.T) {
+	serializer := reporter.JSONSerializer{}
+
+	var numSpans int64
+	spans := generateSpans(4)
+	ts := newTestServer(t, spans, serializer, func(num int) { atomic.AddInt64(&numSpans, int64(num)) })
+	defer ts.Close()
+
+	rep := zipkinhttp.NewReporter(ts.URL,
+		zipkinhttp.BatchSize(2),
+		zipkinhttp.Logger(log.New(ioutil.Discard, "", 0)),
+		zipkinhttp.Client(&http.Client{Transport: &failingRoundTripper{failures: 1}}),
+	)
+	for _, span := range spans[:2] {
+		rep.Send(*span)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if have := atomic.LoadInt64(&numSpans); have != 0 {
+		t.Errorf("unexpected spans received: %d", have)
+	}
+
+	for _, span := range spans[2:] {
+		rep.Send(*span)
+	}
+	rep.Close()
+
+	if want, have := len(spans), int(atomic.LoadInt64(&numSpans)); want != have {
+		t.Errorf("received spans want %d, have %d", want, have)
+	}
+}
+
+func TestEndpoints(t *testing.T) {
+	var received [2]int32
+	newServer := func(idx int) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			atomic.AddInt32(&received[idx], 1)
+		}))
+	}
+	ts1, ts2 := newServer(0), newServer(1)
+	defer ts1.Close()
+	defer ts2.Close()
+
+	rep := zipkinhttp.NewReporter(ts1.URL,
+		zipkinhttp.BatchSize(1),
+		zipkinhttp.Endpoints([]string{ts2.URL}, zipkinhttp.RoundRobin),
+	)
+	for _, span := range generateSpans(4) {
+		rep.Send(*span)
+		time.Sleep(20 * time.Millisecond)
+	}
+	rep.Close()
+
+	have := [2]int32{atomic.LoadInt32(&received[0]), atomic.LoadInt32(&received[1])}
+	if want := [2]int32{2, 2}; want != have {
+		t.Errorf("requests per endpoint want %v, have %v", want, have)
+	}
+}
+
+func TestEndpointsRetryStatus(t *testing.T) {
+	for _, code := range []int{http.StatusServiceUnavailable, http.StatusTooManyRequests} {
+		failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(code)
+		}))
+		var received int64
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var spans []model.SpanModel
+			_ = json.NewDecoder(r.Body).Decode(&spans)
+			atomic.AddInt64(&received, int64(len(spans)))
+		}))
+
+		var dropped int64
+		rep := zipkinhttp.NewReporter(failing.URL,
+			zipkinhttp.BatchSize(1),
+			zipkinhttp.Endpoints([]string{ts.URL}, zipkinhttp.RoundRobin),
+			zipkinhttp.Logger(log.New(ioutil.Discard, "", 0)),
+			zipkinhttp.OnDrop(func(model.SpanModel, error) {
+				atomic.AddInt64(&dropped, 1)
+			}),
+		)
+		for _, span := range generateSpans(2) {
+			rep.Send(*span)
+			time.Sleep(20 * time.Millisecond)
+		}
+		rep.Close()
+		failing.Close()
+		ts.Close()
+
+		if want, have := int64(2), atomic.LoadInt64(&received); want != have {
+			t.Errorf("[%d] spans received want %d, have %d", code, want, have)
+		}
+		if have := atomic.LoadInt64(&dropped); have != 0 {
+			t.Errorf("[%d] unexpected dropped spans: %d", code, have)
+		}
+	}
+}
+
+func TestResolveInterval(t *testing.T) {
+	var hosts = make(chan string, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hosts <- r.Host
+	}))
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	rep := zipkinhttp.NewReporter("http://localhost:"+u.Port(),
+		zipkinhttp.ResolveInterval(time.Minute),
+	)
+	time.Sleep(50 * time.Millisecond)
+	rep.Send(*generateSpans(1)[0])
+	rep.Close()
+
+	select {
+	case host := <-hosts:
+		if want, have := "localhost:"+u.Port(), host; want != have {
+			t.Errorf("host header want %q, have %q", want, have)
+		}
+	default:
+		t.Fatal("expected batch to be received")
+	}
+}
+
+func TestUnixSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zipkin")
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "collector.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("unable to listen: %+v", err)
+	}
+
+	var (
+		numSpans int64
+		path     atomic.Value
+		host     atomic.Value
+	)
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var spans []*model.SpanModel
+		if err := json.NewDecoder(r.Body).Decode(&spans); err != nil {
+			t.Errorf("failed to parse json payload: %v", err)
+		}
+		path.Store(r.URL.Path)
+		host.Store(r.Host)
+		atomic.AddInt64(&numSpans, int64(len(spans)))
+	})}
+	go func() { _ = srv.Serve(l) }()
+	defer srv.Close()
+
+	for _, tc := range []struct{ url, path string }{
+		{"unix://" + socket, "/api/v2/spans"},
+		{"unix://" + socket + ":/custom/spans", "/custom/spans"},
+	} {
+		atomic.StoreInt64(&numSpans, 0)
+
+		rep := zipkinhttp.NewReporter(tc.url)
+		for _, span := range generateSpans(2) {
+			rep.Send(*span)
+		}
+		_ = rep.Close()
+
+		if want, have := int64(2), atomic.LoadInt64(&numSpans); want != have {
+			t.Errorf("%s: spans received want %d, have %d", tc.url, want, have)
+		}
+		if want, have := tc.path, path.Load(); want != have {
+			t.Errorf("%s: request path want %q, have %v", tc.url, want, have)
+		}
+		if want, have := "localhost", host.Load(); want != have {
+			t.Errorf("%s: host want %q, have %v", tc.url, want, have)
+		}
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	testCases := []struct {
+		name        string
+		accept      string
+		protoStatus int
+		want        []string
+	}{
+		{"proto advertised", "application/x-protobuf, application/json", http.StatusAccepted, []string{"application/x-protobuf"}},
+		{"json advertised", "application/json; charset=utf-8", http.StatusAccepted, []string{"application/json"}},
+		{"nothing advertised", "", http.StatusAccepted, []string{"application/json"}},
+		{"proto rejected", "application/x-protobuf", http.StatusUnsupportedMediaType, []string{"application/x-protobuf", "application/json"}},
+	}
+
+	for _, tc := range testCases {
+		var (
+			mtx          sync.Mutex
+			contentTypes []string
+			probes       int32
+		)
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == "OPTIONS" {
+				atomic.AddInt32(&probes, 1)
+				if tc.accept != "" {
+					w.Header().Set("Accept-Post", tc.accept)
+				}
+				return
+			}
+			contentType := r.Header.Get("Content-Type")
+			mtx.Lock()
+			contentTypes = append(contentTypes, contentType)
+			mtx.Unlock()
+			if contentType == "application/x-protobuf" {
+				w.WriteHeader(tc.protoStatus)
+				return
+			}
+			w.WriteHeader(http.StatusAccepted)
+		}))
+
+		rep := zipkinhttp.NewReporter(
+			ts.URL,
+			zipkinhttp.BatchInterval(10*time.Millisecond),
+			zipkinhttp.Negotiate(zipkinproto.SpanSerializer{}, reporter.JSONSerializer{}),
+		)
+		rep.Send(*generateSpans(1)[0])
+		for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+			mtx.Lock()
+			n := len(contentTypes)
+			mtx.Unlock()
+			if n >= len(tc.want) {
+				break
+			}
+		}
+		_ = rep.Close()
+		ts.Close()
+
+		if want, have := tc.want, contentTypes; !reflect.DeepEqual(want, have) {
+			t.Errorf("%s: content types want %v, have %v", tc.name, want, have)
+		}
+		if want, have := int32(1), atomic.LoadInt32(&probes); want != have {
+			t.Errorf("%s: probes want %d, have %d", tc.name, want, have)
+		}
+	}
+}
+
+func TestCompression(t *testing.T) {
+	var (
+		spans   = generateSpans(10)
+		payload []byte
+		metrics zipkinhttp.BatchMetrics
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if want, have := "gzip", r.Header.Get("Content-Encoding"); want != have {
+			t.Errorf("Content-Encoding want %q, have %q", want, have)
+		}
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+		if payload, err = ioutil.ReadAll(zr); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}))
+	defer ts.Close()
+
+	rep := zipkinhttp.NewReporter(
+		ts.URL,
+		zipkinhttp.Compression(gzip.BestSpeed),
+		zipkinhttp.Metrics(func(m zipkinhttp.BatchMetrics) { metrics = m }),
+	)
+	for _, span := range spans {
+		rep.Send(*span)
+	}
+	rep.Close()
+
+	want, _ := reporter.JSONSerializer{}.Serialize(spans)
+	if !bytes.Equal(want, payload) {
+		t.Errorf("unexpected span payload\nhave %s\nwant %s", payload, want)
+	}
+	if metrics.CompressedBytes == 0 || metrics.CompressedBytes >= metrics.Bytes {
+		t.Errorf("expected compressed size below %d, have %d", metrics.Bytes, metrics.CompressedBytes)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	for _, tc := range []struct {
+		failures int
+		requests int32
+		drops    int
+	}{
+		{failures: 2, requests: 3, drops: 0},
+		{failures: 5, requests: 3, drops: 2},
+	} {
+		var requests, drops int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&requests, 1) <= int32(tc.failures) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		}))
+
+		rep := zipkinhttp.NewReporter(
+			ts.URL,
+			zipkinhttp.Logger(log.New(ioutil.Discard, "", log.LstdFlags)),
+			zipkinhttp.Retry(3, time.Millisecond, 5*time.Millisecond),
+			zipkinhttp.OnDrop(func(model.SpanModel, error) { atomic.AddInt32(&drops, 1) }),
+		)
+		for _, span := range generateSpans(2) {
+			rep.Send(*span)
+		}
+		rep.Close()
+		ts.Close()
+
+		if want, have := tc.requests, atomic.LoadInt32(&requests); want != have {
+			t.Errorf("failures %d: requests want %d, have %d", tc.failures, want, have)
+		}
+		if want, have := int32(tc.drops), atomic.LoadInt32(&drops); want != have {
+			t.Errorf("failures %d: drops want %d, have %d", tc.failures, want, have)
+		}
+	}
+}
+
+func TestTLSConfigAndHeaders(t *testing.T) {
+	var auth atomic.Value
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth.Store(r.Header.Get("Authorization"))
+	}))
+	defer ts.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ts.Certificate())
+
+	rep := zipkinhttp.NewReporter(
+		ts.URL,
+		zipkinhttp.TLSConfig(&tls.Config{RootCAs: pool}),
+		zipkinhttp.Headers(http.Header{"Authorization": []string{"Bearer token"}}),
+	)
+	rep.Send(*generateSpans(1)[0])
+	if err := rep.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if want, have := "Bearer token", auth.Load(); want != have {
+		t.Errorf("Authorization want %q, have %v", want, have)
+	}
+}
+
+type countingRoundTripper struct {
+	requests int32
+}
+
+func (rt *countingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&rt.requests, 1)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestTransport(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	rt := &countingRoundTripper{}
+	rep := zipkinhttp.NewReporter(ts.URL, zipkinhttp.Transport(rt))
+	rep.Send(*generateSpans(1)[0])
+	if err := rep.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if want, have := int32(1), atomic.LoadInt32(&rt.requests); want != have {
+		t.Errorf("requests want %d, have %d", want, have)
+	}
+}
+
+func TestFlush(t *testing.T) {
+	var (
+		numSpans int64
+		fail     int32
+	)
+	spans := generateSpans(3)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&fail) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		var batch []*model.SpanModel
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		atomic.AddInt64(&numSpans, int64(len(batch)))
+	}))
+	defer ts.Close()
+
+	rep := zipkinhttp.NewReporter(
+		ts.URL,
+		zipkinhttp.Logger(log.New(ioutil.Discard, "", log.LstdFlags)),
+		zipkinhttp.BatchInterval(time.Hour),
+	)
+	defer rep.Close()
+
+	flusher, ok := rep.(reporter.Flusher)
+	if !ok {
+		t.Fatal("expected reporter to implement reporter.Flusher")
+	}
+
+	for _, span := range spans {
+		rep.Send(*span)
+	}
+	if err := flusher.Flush(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want, have := int64(3), atomic.LoadInt64(&numSpans); want != have {
+		t.Errorf("spans want %d, have %d", want, have)
+	}
+
+	atomic.StoreInt32(&fail, 1)
+	rep.Send(*spans[0])
+	if err := flusher.Flush(); err == nil {
+		t.Error("expected error from failing collector")
+	}
+
+	// the rejected span is dropped
+	atomic.StoreInt32(&fail, 0)
+	rep.Send(*spans[1])
+	if err := flusher.Flush(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want, have := int64(4), atomic.LoadInt64(&numSpans); want != have {
+		t.Errorf("spans want %d, have %d", want, have)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	var (
+		requests, healthy int32
+		reasons           = make(chan error, 10)
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if atomic.LoadInt32(&healthy) == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+
+	rep := zipkinhttp.NewReporter(
+		ts.URL,
+		zipkinhttp.Logger(log.New(ioutil.Discard, "", log.LstdFlags)),
+		zipkinhttp.BatchInterval(time.Hour),
+		zipkinhttp.CircuitBreaker(2, 50*time.Millisecond),
+		zipkinhttp.OnDrop(func(_ model.SpanModel, reason error) { reasons <- reason }),
+	)
+	defer rep.Close()
+
+	var (
+		flusher = rep.(reporter.Flusher)
+		circuit = rep.(zipkinhttp.CircuitReporter)
+		spans   = generateSpans(4)
+	)
+
+	// two failing batches open the circuit
+	for _, span := range spans[:2] {
+		rep.Send(*span)
+		_ = flusher.Flush()
+	}
+	if want, have := (zipkinhttp.CircuitStats{Open: true, Trips: 1}), circuit.CircuitStats(); want != have {
+		t.Errorf("stats want %+v, have %+v", want, have)
+	}
+
+	// spans are dropped while open
+	rep.Send(*spans[2])
+	_ = flusher.Flush()
+	if want, have := int32(2), atomic.LoadInt32(&requests); want != have {
+		t.Errorf("requests want %d, have %d", want, have)
+	}
+	if want, have := (zipkinhttp.CircuitStats{Open: true, Trips: 1, Dropped: 1}), circuit.CircuitStats(); want != have {
+		t.Errorf("stats want %+v, have %+v", want, have)
+	}
+	if want, have := 3, len(reasons); want != have {
+		t.Fatalf("dropped spans want %d, have %d", want, have)
+	}
+	<-reasons
+	<-reasons
+	if want, have := zipkinhttp.ErrCircuitOpen, <-reasons; want != have {
+		t.Errorf("drop reason want %v, have %v", want, have)
+	}
+
+	// a successful probe closes the circuit
+	atomic.StoreInt32(&healthy, 1)
+	time.Sleep(60 * time.Millisecond)
+	rep.Send(*spans[3])
+	if err := flusher.Flush(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want, have := int32(3), atomic.LoadInt32(&requests); want != have {
+		t.Errorf("requests want %d, have %d", want, have)
+	}
+	if want, have := (zipkinhttp.CircuitStats{Trips: 1, Dropped: 1}), circuit.CircuitStats(); want != have {
+		t.Errorf("stats want %+v, have %+v", want, have)
+	}
+}
+
+func TestDialer(t *testing.T) {
+	var numSpans int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var spans []*model.SpanModel
+		if err := json.NewDecoder(r.Body).Decode(&spans); err != nil {
+			t.Errorf("failed to parse json payload: %v", err)
+		}
+		atomic.AddInt64(&numSpans, int64(len(spans)))
+	}))
+	defer ts.Close()
+
+	for _, tc := range []struct{ url, network, addr string }{
+		{"http://collector.invalid/api/v2/spans", "tcp", "collector.invalid:80"},
+		{"unix:///var/run/collector.sock", "unix", "/var/run/collector.sock"},
+	} {
+		atomic.StoreInt64(&numSpans, 0)
+
+		var dialed []string
+		dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+			dialed = append(dialed, network+" "+addr)
+			var d net.Dialer
+			return d.DialContext(ctx, "tcp", ts.Listener.Addr().String())
+		}
+		rep := zipkinhttp.NewReporter(tc.url, zipkinhttp.Dialer(dial))
+		for _, span := range generateSpans(2) {
+			rep.Send(*span)
+		}
+		_ = rep.Close()
+
+		if want, have := int64(2), atomic.LoadInt64(&numSpans); want != have {
+			t.Errorf("%s: spans received want %d, have %d", tc.url, want, have)
+		}
+		if want, have := []string{tc.network + " " + tc.addr}, dialed; !reflect.DeepEqual(want, have) {
+			t.Errorf("%s: dialed want %v, have %v", tc.url, want, have)
+		}
+	}
+}
+
+func TestBackpressure(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		strategy  zipkinhttp.BackpressureStrategy
+		timeout   time.Duration
+		received  []int
+		discarded []int
+	}{
+		{"drop oldest", zipkinhttp.BackpressureDropOldest, 0, []int{0, 2, 3}, []int{1}},
+		{"drop newest", zipkinhttp.BackpressureDropNewest, 0, []int{0, 1, 2}, []int{3}},
+		{"block with timeout", zipkinhttp.BackpressureBlock, 20 * time.Millisecond, []int{0, 1, 2}, []int{3}},
+		{"block", zipkinhttp.BackpressureBlock, 0, []int{0, 1, 2, 3}, nil},
+	} {
+		var (
+			spans    = generateSpans(4)
+			index    = make(map[string]int)
+			received = make(chan int, 4)
+			started  = make(chan struct{}, 4)
+			release  = make(chan struct{})
+		)
+		for i, span := range spans {
+			span.Name = "span" + strconv.Itoa(i)
+			index[span.Name] = i
+		}
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			started <- struct{}{}
+			<-release
+			var batch []*model.SpanModel
+			if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+				t.Errorf("failed to parse json payload: %v", err)
+			}
+			for _, span := range batch {
+				received <- index[span.Name]
+			}
+		}))
+
+		var (
+			mtx       sync.Mutex
+			discarded []int
+			count     int
+		)
+		rep := zipkinhttp.NewReporter(
+			ts.URL,
+			zipkinhttp.Logger(log.New(ioutil.Discard, "", log.LstdFlags)),
+			zipkinhttp.BatchSize(1),
+			zipkinhttp.MaxBacklog(2),
+			zipkinhttp.BatchInterval(time.Hour),
+			zipkinhttp.Backpressure(tc.strategy, tc.timeout),
+			zipkinhttp.OnDrop(func(span model.SpanModel, _ error) {
+				mtx.Lock()
+				discarded = append(discarded, index[span.Name])
+				mtx.Unlock()
+			}),
+			zipkinhttp.OnDiscard(func(n int) {
+				mtx.Lock()
+				count += n
+				mtx.Unlock()
+			}),
+		)
+
+		// the first span is sent while the collector stalls, filling the
+		// backlog with the next two
+		rep.Send(*spans[0])
+		<-started
+		rep.Send(*spans[1])
+		rep.Send(*spans[2])
+
+		sent := make(chan struct{})
+		go func() {
+			rep.Send(*spans[3])
+			close(sent)
+		}()
+		select {
+		case <-sent:
+			if tc.strategy == zipkinhttp.BackpressureBlock && tc.timeout == 0 {
+				t.Errorf("%s: expected Send to block", tc.name)
+			}
+		case <-time.After(50 * time.Millisecond):
+			if tc.strategy != zipkinhttp.BackpressureBlock || tc.timeout > 0 {
+				t.Errorf("%s: expected Send not to block", tc.name)
+			}
+		}
+		close(release)
+		<-sent
+		_ = rep.Close()
+		ts.Close()
+		close(received)
+
+		var have []int
+		for i := range received {
+			have = append(have, i)
+		}
+		if want := tc.received; !reflect.DeepEqual(want, have) {
+			t.Errorf("%s: received want %v, have %v", tc.name, want, have)
+		}
+		mtx.Lock()
+		if want, have := tc.discarded, discarded; !reflect.DeepEqual(want, have) {
+			t.Errorf("%s: discarded want %v, have %v", tc.name, want, have)
+		}
+		if want, have := len(tc.discarded), count; want != have {
+			t.Errorf("%s: discard count want %d, have %d", tc.name, want, have)
+		}
+		mtx.Unlock()
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import "time"
+
+// BatchMetrics holds the overhead of delivering a batch of spans, allowing
+// operators to quantify the cost of tracing itself.
+type BatchMetrics struct {
+	// Spans holds the number of spans in the batch.
+	Spans int
+	// Bytes holds the size of the serialized batch.
+	Bytes int
+	// CompressedBytes holds the size of the request body if the Compression
+	// option is set.
+	CompressedBytes int
+	// QueueTime holds the time the oldest span of the batch spent queued in
+	// the reporter. When spans were disposed from the backlog, it is an upper
+	// bound.
+	QueueTime time.Duration
+	// SerializeTime holds the time spent serializing the batch.
+	SerializeTime time.Duration
+	// TransportTime holds the time spent sending the batch to the collector.
+	TransportTime time.Duration
+	// Err holds the error if the batch could not be delivered.
+	Err error
+}
+
+// Metrics registers a callback function which is invoked with the metrics of
+// every batch the reporter attempts to deliver. The callback is invoked
+// synchronously from the reporter's send goroutine so it should not block.
+func Metrics(fn func(BatchMetrics)) ReporterOption {
+	return func(r *httpReporter) { r.metrics = fn }
+}
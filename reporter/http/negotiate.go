@@ -0,0 +1,96 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"context"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/openzipkin/zipkin-go/reporter"
+)
+
+// serializerFor returns the serializer to encode batches sent to ep with. If
+// content type negotiation is enabled, the collector is probed for the
+// content types it accepts the first time a batch is sent to it.
+func (r *httpReporter) serializerFor(ep *endpoint) reporter.SpanSerializer {
+	if len(r.negotiate) == 0 {
+		return r.serializer
+	}
+	if ep.serializer == nil {
+		ep.serializer = r.probe(ep)
+	}
+	return ep.serializer
+}
+
+// probe sends an OPTIONS request to the collector and returns the first of the
+// negotiable serializers whose content type is listed in the Accept-Post or
+// Accept response header. If the collector does not advertise a supported
+// content type the serializer set by the Serializer option is returned.
+func (r *httpReporter) probe(ep *endpoint) reporter.SpanSerializer {
+	target, host := ep.url, ep.host
+	if s, ok := r.sockets[ep.url]; ok {
+		target, host = s.url, unixHost
+	}
+	req, err := http.NewRequest("OPTIONS", target, nil)
+	if err != nil {
+		return r.serializer
+	}
+	req = req.WithContext(reporter.NewUntracedContext(context.Background()))
+	if host != "" {
+		req.Host = host
+	}
+	r.setHeaders(req)
+	resp, err := r.client.Do(req)
+	if err != nil {
+		r.logger.Printf("failed to probe the collector content types: %s\n", err.Error())
+		return r.serializer
+	}
+	_ = resp.Body.Close()
+
+	accepted := map[string]bool{}
+	for _, header := range []string{"Accept-Post", "Accept"} {
+		for _, value := range resp.Header[header] {
+			for _, mediaType := range strings.Split(value, ",") {
+				if t, _, err := mime.ParseMediaType(mediaType); err == nil {
+					accepted[t] = true
+				}
+			}
+		}
+	}
+	for _, serializer := range r.negotiate {
+		if t, _, err := mime.ParseMediaType(serializer.ContentType()); err == nil && accepted[t] {
+			return serializer
+		}
+	}
+	return r.serializer
+}
+
+// rejected handles a collector at ep rejecting the content type of serializer.
+// It returns true if the batch should be retried with the serializer set by
+// the Serializer option. Otherwise the collector is probed again for the next
+// batch.
+func (r *httpReporter) rejected(ep *endpoint, serializer reporter.SpanSerializer) bool {
+	if len(r.negotiate) == 0 {
+		return false
+	}
+	if serializer != r.serializer {
+		ep.serializer = r.serializer
+		return true
+	}
+	ep.serializer = nil
+	return false
+}
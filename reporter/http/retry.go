@@ -0,0 +1,70 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// defaults for retrying failed requests
+const (
+	defaultRetryBackoff    = 100 * time.Millisecond
+	defaultRetryBackoffMax = 5 * time.Second
+)
+
+// Retry retries requests failing with transport errors, 5xx or 429 responses
+// up to maxAttempts attempts in total, keeping the batch in memory until the
+// attempts are exhausted, so transient collector failures do not drop spans.
+// Attempts are delayed by an exponential backoff starting at base, capped at
+// max and randomized by up to half its duration to spread retries of many
+// reporters. Zero durations select a base of 100ms and a cap of 5s. Close
+// waits for the retries of the last batch. Requests are not retried by
+// default.
+func Retry(maxAttempts int, base, max time.Duration) ReporterOption {
+	return func(r *httpReporter) {
+		r.retryAttempts = maxAttempts
+		r.retryBackoff = base
+		r.retryBackoffMax = max
+	}
+}
+
+// retryable reports whether a request failed in a way that might succeed when
+// retried.
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode > 499 || resp.StatusCode == http.StatusTooManyRequests
+}
+
+// backoffDuration returns the delay before the retry following attempt.
+func (r *httpReporter) backoffDuration(attempt int) time.Duration {
+	base, max := r.retryBackoff, r.retryBackoffMax
+	if base <= 0 {
+		base = defaultRetryBackoff
+	}
+	if max <= 0 {
+		max = defaultRetryBackoffMax
+	}
+	d := max
+	if shift := uint(attempt - 1); shift < 32 && base<<shift < max && base<<shift > 0 {
+		d = base << shift
+	}
+	// equal jitter: keep half of the delay and randomize the other half
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(d-half)+1))
+}
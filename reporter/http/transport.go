@@ -0,0 +1,85 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+)
+
+// DialFunc dials the connection to addr on the named network, with the
+// signature of net.Dialer.DialContext.
+type DialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// TLSConfig sets the TLS configuration used to connect to collectors, e.g. to
+// present client certificates or trust a private certificate authority. It has
+// no effect if a transport is set using the Transport option or the client
+// passed using the Client option has a transport.
+func TLSConfig(config *tls.Config) ReporterOption {
+	return func(r *httpReporter) { r.tlsConfig = config }
+}
+
+// Headers sets static headers sent with every request to the collectors, e.g.
+// an Authorization header for managed, authenticated Zipkin endpoints. The
+// RequestCallback option can override them per request.
+func Headers(h http.Header) ReporterOption {
+	return func(r *httpReporter) { r.headers = h }
+}
+
+// Transport sets the http.RoundTripper used to send requests, e.g. to sign
+// requests or route them through a custom proxy, without providing a complete
+// http.Client. The timeout set using the Timeout option still applies.
+func Transport(rt http.RoundTripper) ReporterOption {
+	return func(r *httpReporter) { r.transport = rt }
+}
+
+// Dialer sets the function dialing connections to collectors, e.g. to reach a
+// sidecar collector through a vsock or an in-process pipe instead of TCP.
+// Connections to collectors with unix:// URLs are dialed on the "unix" network
+// with the socket path as address. It has no effect if a transport is set
+// using the Transport option or the client passed using the Client option has
+// a transport.
+func Dialer(dial DialFunc) ReporterOption {
+	return func(r *httpReporter) { r.dial = dial }
+}
+
+// setHeaders sets the static headers on req.
+func (r *httpReporter) setHeaders(req *http.Request) {
+	for key, values := range r.headers {
+		req.Header[http.CanonicalHeaderKey(key)] = append([]string(nil), values...)
+	}
+}
+
+// configureClient applies the Transport, TLSConfig, Dialer and unix socket
+// settings to the client without modifying a client passed using the Client
+// option.
+func (r *httpReporter) configureClient() {
+	if r.transport != nil {
+		client := *r.client
+		client.Transport = r.transport
+		r.client = &client
+		return
+	}
+	if r.client.Transport != nil || (len(r.sockets) == 0 && r.tlsConfig == nil && r.dial == nil) {
+		return
+	}
+	t := unixTransport(r.sockets, r.dial)
+	t.TLSClientConfig = r.tlsConfig
+	client := *r.client
+	client.Transport = t
+	r.client = &client
+}
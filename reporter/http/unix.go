@@ -0,0 +1,87 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	unixPrefix      = "unix://"
+	unixDefaultPath = "/api/v2/spans"
+	unixHost        = "localhost" // Host header sent to collectors on a socket
+)
+
+// unixSocket holds a collector listening on a unix domain socket.
+type unixSocket struct {
+	path string // path of the socket file
+	host string // host of the request URL identifying the socket
+	url  string // request URL
+}
+
+// parseUnixURL parses collector URLs of the form
+// unix:///var/run/zipkin.sock:/api/v2/spans into the socket path and the
+// request path. If no request path is given, /api/v2/spans is used.
+func parseUnixURL(rawURL string, idx int) (s unixSocket, ok bool) {
+	if !strings.HasPrefix(rawURL, unixPrefix) {
+		return s, false
+	}
+	path, reqPath := rawURL[len(unixPrefix):], unixDefaultPath
+	if i := strings.Index(path, ":/"); i >= 0 {
+		path, reqPath = path[:i], path[i+1:]
+	}
+	// every socket gets its own host so pooled connections are not shared
+	host := fmt.Sprintf("unix-socket-%d", idx)
+	return unixSocket{path: path, host: host, url: "http://" + host + reqPath}, true
+}
+
+// unixTransport returns a transport dialing the sockets by the host of the
+// request URL and dialing TCP for all other hosts. Without sockets it is a
+// plain TCP transport. Connections are dialed using dial if set.
+func unixTransport(sockets map[string]unixSocket, dial DialFunc) *http.Transport {
+	paths := make(map[string]string, len(sockets))
+	for _, s := range sockets {
+		paths[s.host] = s.path
+	}
+	if dial == nil {
+		dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+		dial = dialer.DialContext
+	}
+	return &http.Transport{
+		Proxy: func(req *http.Request) (*url.URL, error) {
+			if _, ok := paths[req.URL.Hostname()]; ok {
+				return nil, nil
+			}
+			return http.ProxyFromEnvironment(req)
+		},
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			host, _, _ := net.SplitHostPort(addr)
+			if path, ok := paths[host]; ok {
+				return dial(ctx, "unix", path)
+			}
+			return dial(ctx, network, addr)
+		},
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package batch implements the span buffering shared by the reporters sending
+spans in batches. Spans are collected until the batch size or byte threshold
+is reached or the batch interval elapsed and then handed to the send function
+of the reporter, which is never invoked concurrently.
+*/
+package batch
+
+import (
+	"sync"
+	"time"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter"
+)
+
+// Options holds the batching parameters of a reporter.
+type Options struct {
+	// Interval is the maximum duration spans are buffered.
+	Interval time.Duration
+	// Size is the number of spans triggering a send.
+	Size int
+	// MaxBacklog is the maximum number of buffered spans. The oldest spans
+	// are dropped with reporter.ErrQueueFull once exceeded.
+	MaxBacklog int
+	// ByteThreshold if positive is the total size of the buffered spans as
+	// returned by SpanSize triggering a send.
+	ByteThreshold int
+	SpanSize      func(span *model.SpanModel) int
+	// Logger logs the disposal of spans.
+	Logger reporter.Logger
+	// OnDrop is invoked for every dropped span, if set.
+	OnDrop func(span model.SpanModel, reason error)
+}
+
+// Batcher buffers spans for a reporter. Send, Flush and Close implement the
+// corresponding methods of reporter.Reporter and reporter.Flusher.
+type Batcher struct {
+	opts       Options
+	send       func(spans []*model.SpanModel) error
+	batchMtx   sync.Mutex
+	batch      []*model.SpanModel
+	sizes      []int
+	batchBytes int
+	spanC      chan *model.SpanModel
+	sendC      chan struct{}
+	flushC     chan struct{}
+	sendMtx    sync.Mutex
+	quit       chan struct{}
+	shutdown   chan error
+}
+
+// New returns a Batcher handing the buffered spans to send, which returns
+// the first error encountered delivering them. Spans failing to be delivered
+// are to be passed to Drop by send.
+func New(opts Options, send func(spans []*model.SpanModel) error) *Batcher {
+	b := &Batcher{
+		opts:     opts,
+		send:     send,
+		spanC:    make(chan *model.SpanModel),
+		sendC:    make(chan struct{}, 1),
+		flushC:   make(chan struct{}),
+		quit:     make(chan struct{}, 1),
+		shutdown: make(chan error, 1),
+	}
+
+	go b.loop()
+	go b.sendLoop()
+
+	return b
+}
+
+// Send adds s to the batch.
+func (b *Batcher) Send(s model.SpanModel) {
+	b.spanC <- &s
+}
+
+// Flush sends the spans added before the call and returns the first error
+// encountered. Flush does nothing once the Batcher is closed.
+func (b *Batcher) Flush() error {
+	select {
+	case b.flushC <- struct{}{}:
+	case <-b.quit:
+		return nil
+	}
+
+	b.sendMtx.Lock()
+	defer b.sendMtx.Unlock()
+	return b.sendBatch()
+}
+
+// Close sends the remaining spans and stops the Batcher.
+func (b *Batcher) Close() error {
+	close(b.quit)
+	return <-b.shutdown
+}
+
+// Drop invokes the OnDrop callback for spans.
+func (b *Batcher) Drop(spans []*model.SpanModel, reason error) {
+	if b.opts.OnDrop == nil {
+		return
+	}
+	for _, span := range spans {
+		b.opts.OnDrop(*span, reason)
+	}
+}
+
+func (b *Batcher) loop() {
+	var (
+		nextSend   = time.Now().Add(b.opts.Interval)
+		ticker     = time.NewTicker(b.opts.Interval / 10)
+		tickerChan = ticker.C
+	)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case span := <-b.spanC:
+			currentBatchSize, currentBatchBytes := b.append(span)
+			if currentBatchSize >= b.opts.Size ||
+				(b.opts.ByteThreshold > 0 && currentBatchBytes >= b.opts.ByteThreshold) {
+				nextSend = time.Now().Add(b.opts.Interval)
+				b.enqueueSend()
+			}
+		case <-tickerChan:
+			if time.Now().After(nextSend) {
+				nextSend = time.Now().Add(b.opts.Interval)
+				b.enqueueSend()
+			}
+		case <-b.flushC:
+			// spans sent before the Flush call have been batched
+		case <-b.quit:
+			close(b.sendC)
+			return
+		}
+	}
+}
+
+func (b *Batcher) sendLoop() {
+	for range b.sendC {
+		b.sendMtx.Lock()
+		_ = b.sendBatch()
+		b.sendMtx.Unlock()
+	}
+	b.sendMtx.Lock()
+	defer b.sendMtx.Unlock()
+	b.shutdown <- b.sendBatch()
+}
+
+func (b *Batcher) enqueueSend() {
+	select {
+	case b.sendC <- struct{}{}:
+	default:
+		// Do nothing if there's a pending send request already
+	}
+}
+
+func (b *Batcher) append(span *model.SpanModel) (newBatchSize, newBatchBytes int) {
+	var size int
+	if b.opts.ByteThreshold > 0 {
+		size = b.opts.SpanSize(span)
+	}
+
+	b.batchMtx.Lock()
+
+	b.batch = append(b.batch, span)
+	b.sizes = append(b.sizes, size)
+	b.batchBytes += size
+	if len(b.batch) > b.opts.MaxBacklog {
+		dispose := len(b.batch) - b.opts.MaxBacklog
+		b.opts.Logger.Printf("backlog too long, disposing %d spans", dispose)
+		b.Drop(b.batch[:dispose], reporter.ErrQueueFull)
+		for _, size := range b.sizes[:dispose] {
+			b.batchBytes -= size
+		}
+		b.batch, b.sizes = b.batch[dispose:], b.sizes[dispose:]
+	}
+	newBatchSize, newBatchBytes = len(b.batch), b.batchBytes
+
+	b.batchMtx.Unlock()
+	return
+}
+
+func (b *Batcher) sendBatch() error {
+	// Select all current spans in the batch to be sent
+	b.batchMtx.Lock()
+	sendBatch := b.batch
+	b.batch, b.sizes, b.batchBytes = nil, nil, 0
+	b.batchMtx.Unlock()
+
+	if len(sendBatch) == 0 {
+		return nil
+	}
+	return b.send(sendBatch)
+}
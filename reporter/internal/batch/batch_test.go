@@ -0,0 +1,224 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"log"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openzipkin/zipkin-go/idgenerator"
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter"
+	"github.com/openzipkin/zipkin-go/reporter/internal/batch"
+)
+
+func generateSpans(n int) []*model.SpanModel {
+	spans := make([]*model.SpanModel, n)
+	idGen := idgenerator.NewRandom64()
+	traceID := idGen.TraceID()
+
+	for i := 0; i < n; i++ {
+		spans[i] = &model.SpanModel{
+			SpanContext: model.SpanContext{
+				TraceID: traceID,
+				ID:      idGen.SpanID(traceID),
+			},
+			Name:      "name",
+			Kind:      model.Client,
+			Timestamp: time.Now(),
+		}
+	}
+
+	return spans
+}
+
+// sender records the batches handed to its send method.
+type sender struct {
+	mtx     sync.Mutex
+	batches [][]*model.SpanModel
+	err     error
+}
+
+func (s *sender) send(spans []*model.SpanModel) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.batches = append(s.batches, spans)
+	return s.err
+}
+
+func (s *sender) numSpans() int {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	var n int
+	for _, b := range s.batches {
+		n += len(b)
+	}
+	return n
+}
+
+func options() batch.Options {
+	return batch.Options{
+		Interval:   time.Hour,
+		Size:       100,
+		MaxBacklog: 1000,
+		Logger:     log.New(ioutil.Discard, "", 0),
+	}
+}
+
+func TestSpansAreSentOnClose(t *testing.T) {
+	var s sender
+	b := batch.New(options(), s.send)
+
+	spans := generateSpans(2)
+	for _, span := range spans {
+		b.Send(*span)
+	}
+	if err := b.Close(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if want, have := 2, s.numSpans(); want != have {
+		t.Errorf("unexpected number of spans sent\nhave: %d, want: %d", have, want)
+	}
+}
+
+func TestSpansAreSentOnTime(t *testing.T) {
+	var s sender
+	opts := options()
+	opts.Interval = 200 * time.Millisecond
+	b := batch.New(opts, s.send)
+	defer b.Close()
+
+	for _, span := range generateSpans(2) {
+		b.Send(*span)
+	}
+
+	time.Sleep(3 * opts.Interval / 2)
+
+	if want, have := 2, s.numSpans(); want != have {
+		t.Errorf("unexpected number of spans sent\nhave: %d, want: %d", have, want)
+	}
+}
+
+func TestSpansAreSentAfterBatchSize(t *testing.T) {
+	var s sender
+	opts := options()
+	opts.Size = 2
+	b := batch.New(opts, s.send)
+	defer b.Close()
+
+	for _, span := range generateSpans(2) {
+		b.Send(*span)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	if want, have := 2, s.numSpans(); want != have {
+		t.Errorf("unexpected number of spans sent\nhave: %d, want: %d", have, want)
+	}
+}
+
+func TestSpansAreSentAfterByteThreshold(t *testing.T) {
+	var s sender
+	opts := options()
+	opts.ByteThreshold = 30
+	opts.SpanSize = func(*model.SpanModel) int { return 10 }
+	b := batch.New(opts, s.send)
+	defer b.Close()
+
+	for _, span := range generateSpans(3) {
+		b.Send(*span)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	if want, have := 3, s.numSpans(); want != have {
+		t.Errorf("unexpected number of spans sent\nhave: %d, want: %d", have, want)
+	}
+}
+
+func TestFlush(t *testing.T) {
+	s := sender{err: errors.New("send failed")}
+	b := batch.New(options(), s.send)
+
+	for _, span := range generateSpans(3) {
+		b.Send(*span)
+	}
+	if err := b.Flush(); err != s.err {
+		t.Errorf("want %v, have %v", s.err, err)
+	}
+	if want, have := 3, s.numSpans(); want != have {
+		t.Errorf("unexpected number of spans sent\nhave: %d, want: %d", have, want)
+	}
+
+	// nothing left to send
+	if err := b.Flush(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := b.Close(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := b.Flush(); err != nil {
+		t.Errorf("unexpected error after close: %v", err)
+	}
+	if want, have := 1, len(s.batches); want != have {
+		t.Errorf("unexpected number of batches\nhave: %d, want: %d", have, want)
+	}
+}
+
+func TestBacklogOverflow(t *testing.T) {
+	var (
+		s       sender
+		dropped []model.SpanModel
+	)
+	opts := options()
+	opts.MaxBacklog = 2
+	opts.ByteThreshold = 100
+	opts.SpanSize = func(*model.SpanModel) int { return 40 }
+	opts.OnDrop = func(span model.SpanModel, reason error) {
+		if reason != reporter.ErrQueueFull {
+			t.Errorf("drop reason want %v, have %v", reporter.ErrQueueFull, reason)
+		}
+		dropped = append(dropped, span)
+	}
+	b := batch.New(opts, s.send)
+
+	spans := generateSpans(3)
+	for _, span := range spans {
+		b.Send(*span)
+	}
+	if err := b.Flush(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	b.Close()
+
+	// spans dropped from the backlog do not count towards the byte threshold
+	if want, have := 1, len(s.batches); want != have {
+		t.Fatalf("unexpected number of batches\nhave: %d, want: %d", have, want)
+	}
+	if want, have := 1, len(dropped); want != have {
+		t.Fatalf("unexpected number of spans dropped\nhave: %d, want: %d", have, want)
+	}
+	if want, have := spans[0].ID, dropped[0].ID; want != have {
+		t.Errorf("dropped span want %s, have %s", want, have)
+	}
+	if want, have := spans[1].ID, s.batches[0][0].ID; want != have {
+		t.Errorf("first sent span want %s, have %s", want, have)
+	}
+}
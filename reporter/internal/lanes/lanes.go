@@ -0,0 +1,48 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package lanes assigns spans to lanes, e.g. threads or tracks of trace viewers,
+such that the spans of a lane are properly nested.
+*/
+package lanes
+
+// Set holds the lanes of a layout.
+type Set struct {
+	// lanes holds the end times of the open spans per lane
+	lanes [][]int64
+}
+
+// Place returns the 1 based lane of a span starting at start and ending at end.
+// Spans must be placed in order of start time, enclosing spans first.
+func (s *Set) Place(start, end int64) int {
+	for i, open := range s.lanes {
+		// close the spans which ended before the span starts
+		for len(open) > 0 && open[len(open)-1] <= start {
+			open = open[:len(open)-1]
+		}
+		if len(open) == 0 || end <= open[len(open)-1] {
+			s.lanes[i] = append(open, end)
+			return i + 1
+		}
+		s.lanes[i] = open
+	}
+	s.lanes = append(s.lanes, []int64{end})
+	return len(s.lanes)
+}
+
+// Len returns the number of lanes.
+func (s *Set) Len() int {
+	return len(s.lanes)
+}
@@ -0,0 +1,57 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sigv4
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	// get-vanilla of the AWS Signature Version 4 test suite
+	req, _ := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	creds := Credentials{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	}
+	Sign(req, nil, creds, "us-east-1", "service", now)
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date, " +
+		"Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if have := req.Header.Get("Authorization"); want != have {
+		t.Errorf("authorization want %q, have %q", want, have)
+	}
+	if want, have := "20150830T123600Z", req.Header.Get("X-Amz-Date"); want != have {
+		t.Errorf("date want %q, have %q", want, have)
+	}
+}
+
+func TestSignSessionToken(t *testing.T) {
+	req, _ := http.NewRequest("POST", "https://kinesis.eu-west-1.amazonaws.com/", nil)
+	Sign(req, []byte("{}"), Credentials{AccessKeyID: "id", SecretAccessKey: "secret", SessionToken: "token"},
+		"eu-west-1", "kinesis", time.Now())
+
+	if want, have := "token", req.Header.Get("X-Amz-Security-Token"); want != have {
+		t.Errorf("security token want %q, have %q", want, have)
+	}
+	const signedHeaders = "SignedHeaders=host;x-amz-date;x-amz-security-token,"
+	if have := req.Header.Get("Authorization"); !strings.Contains(have, signedHeaders) {
+		t.Errorf("expected %q in authorization %q", signedHeaders, have)
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kafka
+
+// DeadLetterTopic sets a topic the raw encoded message is produced to when
+// producing it to the span topic fails, so operators can recover or inspect
+// undeliverable spans. The message keeps its key and headers. The spans are
+// still reported as failed to the OnDrop, OnBatchFailed and OnDelivery
+// callbacks, failures to produce to the dead-letter topic are logged.
+func DeadLetterTopic(topic string) ReporterOption {
+	return func(c *kafkaReporter) {
+		c.deadLetterTopic = topic
+	}
+}
+
+// OnDeadLetter registers a callback function which is invoked with the record
+// of every message the reporter fails to produce, holding the raw encoded
+// spans, and the reason. The callback is invoked from the goroutines of the
+// producer and must not block.
+func OnDeadLetter(fn func(r Record, err error)) ReporterOption {
+	return func(c *kafkaReporter) {
+		c.onDeadLetter = fn
+	}
+}
+
+// deadLetter hands off a record which failed to be produced to the
+// OnDeadLetter callback and the dead-letter topic.
+func (r *kafkaReporter) deadLetter(rec Record, err error) {
+	if r.onDeadLetter != nil {
+		r.onDeadLetter(rec, err)
+	}
+	if r.deadLetterTopic == "" {
+		return
+	}
+
+	r.deadLetterMtx.Lock()
+	if r.closing {
+		r.deadLetterMtx.Unlock()
+		r.logger.Printf("reporter closing, not producing msg of %d bytes to dead-letter topic\n", len(rec.Value))
+		return
+	}
+	r.deadLetterWG.Add(1)
+	r.deadLetterMtx.Unlock()
+
+	// produce from a goroutine of our own as producers may invoke the
+	// callbacks of failed messages from goroutines needed to accept new ones
+	rec.Topic = r.deadLetterTopic
+	go func() {
+		defer r.deadLetterWG.Done()
+		r.produceRecord(rec, func(err error) {
+			if err != nil {
+				r.logger.Printf("failed to produce msg of %d bytes to dead-letter topic: %s\n", len(rec.Value), err.Error())
+			}
+		})
+	}()
+}
+
+// stopDeadLetters stops producing to the dead-letter topic and waits for the
+// messages being handed to the producer.
+func (r *kafkaReporter) stopDeadLetters() {
+	r.deadLetterMtx.Lock()
+	r.closing = true
+	r.deadLetterMtx.Unlock()
+	r.deadLetterWG.Wait()
+}
@@ -0,0 +1,114 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kafka
+
+import (
+	"errors"
+	"time"
+
+	"github.com/openzipkin/zipkin-go/model"
+)
+
+// ErrCloseTimeout is the drop reason of spans whose messages were not
+// acknowledged within the timeout set by the CloseTimeout option.
+var ErrCloseTimeout = errors.New("kafka message not acknowledged before close timeout")
+
+// CloseTimeout enables tracking of the messages in flight and sets the maximum
+// duration Close waits for them to be acknowledged before closing the
+// producer. Spans of messages not acknowledged in time are reported as dropped
+// with ErrCloseTimeout. The producer created by the reporter is configured to
+// report acknowledgements, a sarama producer passed using the Producer option
+// must be configured with Producer.Return.Successes enabled. By default Close
+// closes the producer right away.
+func CloseTimeout(d time.Duration) ReporterOption {
+	return func(c *kafkaReporter) {
+		c.closeTimeout = d
+	}
+}
+
+// track registers the spans of a message in flight and returns its id.
+func (r *kafkaReporter) track(ss []model.SpanModel) uint64 {
+	r.inFlightMtx.Lock()
+	defer r.inFlightMtx.Unlock()
+
+	if r.inFlight == nil {
+		r.inFlight = make(map[uint64][]model.SpanModel)
+	}
+	r.inFlightID++
+	r.inFlight[r.inFlightID] = ss
+	return r.inFlightID
+}
+
+// untrack removes a message acknowledged or failed by the producer and
+// reports whether it was still in flight, i.e. not given up on by drain.
+func (r *kafkaReporter) untrack(id uint64) bool {
+	r.inFlightMtx.Lock()
+	defer r.inFlightMtx.Unlock()
+
+	if _, ok := r.inFlight[id]; !ok {
+		return false
+	}
+	delete(r.inFlight, id)
+	if len(r.inFlight) == 0 && r.drained != nil {
+		close(r.drained)
+		r.drained = nil
+	}
+	return true
+}
+
+// waitInFlight waits up to timeout for the messages in flight to be
+// acknowledged and reports whether they were.
+func (r *kafkaReporter) waitInFlight(timeout time.Duration) bool {
+	r.inFlightMtx.Lock()
+	if len(r.inFlight) == 0 {
+		r.inFlightMtx.Unlock()
+		return true
+	}
+	if r.drained == nil {
+		r.drained = make(chan struct{})
+	}
+	drained := r.drained
+	r.inFlightMtx.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-drained:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// drain waits for the messages in flight to be acknowledged, up to the close
+// timeout, and drops the spans of the messages still in flight afterwards.
+func (r *kafkaReporter) drain() {
+	if r.waitInFlight(r.closeTimeout) {
+		return
+	}
+
+	r.inFlightMtx.Lock()
+	remaining := r.inFlight
+	r.inFlight, r.drained = nil, nil
+	r.inFlightMtx.Unlock()
+
+	if len(remaining) > 0 {
+		r.logger.Printf("%d messages not acknowledged before close timeout\n", len(remaining))
+	}
+	for _, ss := range remaining {
+		r.failed(ss, ErrCloseTimeout)
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kafka
+
+import "errors"
+
+// ErrFlushTimeout is returned by Flush if the messages in flight are not
+// acknowledged within the timeout set by the CloseTimeout option.
+var ErrFlushTimeout = errors.New("kafka messages not acknowledged before flush timeout")
+
+// Flush implements reporter.Flusher. It hands the spans queued by the Queue
+// option and buffered by the BatchSize option to the producer. If the
+// CloseTimeout option is set, Flush then waits up to the close timeout for the
+// messages in flight to be acknowledged and returns ErrFlushTimeout if they are
+// not. Flush does nothing once the reporter is closed.
+func (r *kafkaReporter) Flush() error {
+	if r.queue != nil {
+		r.queue.waitIdle()
+	}
+	if r.flushC != nil {
+		done := make(chan struct{})
+		select {
+		case r.flushC <- done:
+			<-done
+		case <-r.shutdown:
+			return nil
+		}
+	}
+	if r.closeTimeout > 0 && !r.waitInFlight(r.closeTimeout) {
+		return ErrFlushTimeout
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package franz implements a Kafka reporter using the franz-go client for teams
+not using sarama. The package is a separate module to avoid adding the
+dependency to all users of zipkin-go.
+*/
+package franz
+
+import (
+	"context"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+
+	"github.com/openzipkin/zipkin-go/reporter"
+	"github.com/openzipkin/zipkin-go/reporter/kafka"
+)
+
+// producer adapts a franz-go client to the kafka.RecordProducer interface.
+type producer struct {
+	client *kgo.Client
+}
+
+// Producer returns a kafka.MessageProducer producing messages using client,
+// for use with the kafka.Client option.
+func Producer(client *kgo.Client) kafka.MessageProducer {
+	return producer{client: client}
+}
+
+// NewReporter returns a new Kafka Reporter using a franz-go client connected to
+// the provided seed brokers of the form "host:port".
+func NewReporter(brokers []string, options ...kafka.ReporterOption) (reporter.Reporter, error) {
+	client, err := kgo.NewClient(kgo.SeedBrokers(brokers...))
+	if err != nil {
+		return nil, err
+	}
+	return kafka.NewReporter(brokers, append(options, kafka.Client(Producer(client)))...)
+}
+
+func (p producer) Produce(topic string, value []byte, done func(err error)) {
+	p.ProduceRecord(kafka.Record{Topic: topic, Value: value}, done)
+}
+
+func (p producer) ProduceRecord(r kafka.Record, done func(err error)) {
+	record := &kgo.Record{Topic: r.Topic, Key: r.Key, Value: r.Value}
+	for _, h := range r.Headers {
+		record.Headers = append(record.Headers, kgo.RecordHeader{Key: h.Key, Value: h.Value})
+	}
+	p.client.Produce(
+		context.Background(),
+		record,
+		func(_ *kgo.Record, err error) { done(err) },
+	)
+}
+
+func (p producer) Close() error {
+	err := p.client.Flush(context.Background())
+	p.client.Close()
+	return err
+}
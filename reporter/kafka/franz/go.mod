@@ -0,0 +1,24 @@
+module github.com/openzipkin/zipkin-go/reporter/kafka/franz
+
+require (
+	github.com/openzipkin/zipkin-go v0.1.6
+	github.com/twmb/franz-go v1.22.1
+)
+
+require (
+	github.com/Shopify/sarama v1.19.0 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/eapache/go-resiliency v1.1.0 // indirect
+	github.com/eapache/go-xerial-snappy v0.0.0-20180814174437-776d5712da21 // indirect
+	github.com/eapache/queue v1.1.0 // indirect
+	github.com/golang/snappy v0.0.0-20180518054509-2e65f85255db // indirect
+	github.com/klauspost/compress v1.20.0 // indirect
+	github.com/pierrec/lz4 v1.0.2-0.20190131084431-473cd7ce01a1 // indirect
+	github.com/pierrec/lz4/v4 v4.1.30 // indirect
+	github.com/rcrowley/go-metrics v0.0.0-20181016184325-3113b8401b8a // indirect
+	github.com/twmb/franz-go/pkg/kmsg v1.14.0 // indirect
+)
+
+replace github.com/openzipkin/zipkin-go => ../../..
+
+go 1.26.0
@@ -0,0 +1,64 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kafka
+
+import (
+	"sort"
+)
+
+// ContentTypeHeader is the record header holding the content type of the
+// serialized spans if headers are enabled with the Headers option.
+const ContentTypeHeader = "Content-Type"
+
+// Header holds a Kafka record header.
+type Header struct {
+	Key   string
+	Value []byte
+}
+
+// Headers enables Kafka record headers, letting consumers like the Zipkin
+// collector detect the encoding of messages. Every message holds the content
+// type of the serializer in the ContentTypeHeader header and the provided
+// static headers, which may override the content type. Headers require Kafka
+// 0.11 or newer. The producer created by the reporter is configured
+// accordingly, a sarama producer passed using the Producer option needs to be
+// configured with Version V0_11_0_0 or newer. Headers are only set if the
+// MessageProducer implements RecordProducer.
+func Headers(headers map[string]string) ReporterOption {
+	return func(c *kafkaReporter) {
+		c.headersEnabled = true
+		c.staticHeaders = headers
+	}
+}
+
+// recordHeaders returns the headers set on every message, sorted by key.
+func (r *kafkaReporter) recordHeaders() []Header {
+	headers := map[string]string{ContentTypeHeader: r.serializer.ContentType()}
+	for k, v := range r.staticHeaders {
+		headers[k] = v
+	}
+
+	keys := make([]string, 0, len(headers))
+	for k := range headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	hs := make([]Header, len(keys))
+	for i, k := range keys {
+		hs[i] = Header{Key: k, Value: []byte(headers[k])}
+	}
+	return hs
+}
@@ -18,9 +18,10 @@ Package kafka implements a Kafka reporter to send spans to a Kafka server/cluste
 package kafka
 
 import (
-	"encoding/json"
 	"log"
 	"os"
+	"sync"
+	"time"
 
 	"github.com/Shopify/sarama"
 	"github.com/openzipkin/zipkin-go/model"
@@ -32,30 +33,99 @@ import (
 // https://github.com/openzipkin/zipkin/tree/master/zipkin-receiver-kafka
 const defaultKafkaTopic = "zipkin"
 
+// defaults for batching spans into messages
+const (
+	defaultBatchSize     = 1
+	defaultBatchInterval = time.Second * 1
+)
+
 // kafkaReporter implements Reporter by publishing spans to a Kafka
 // broker.
 type kafkaReporter struct {
-	producer   sarama.AsyncProducer
-	logger     *log.Logger
-	topic      string
-	serializer reporter.SpanSerializer
+	producer      MessageProducer
+	asyncProducer sarama.AsyncProducer
+	logger        reporter.Logger
+	topic         string
+	serializer    reporter.SpanSerializer
+	onDrop        func(model.SpanModel, error)
+
+	onBatchSent   func(count, bytes int, duration time.Duration)
+	onBatchFailed func(err error, count int)
+	onDelivery    func(spans []model.SpanModel, err error)
+	onDeadLetter  func(r Record, err error)
+	topicOptions  topicOptions
+	security      securityOptions
+
+	compression      sarama.CompressionCodec
+	compressionLevel int
+
+	partitionKey   func(model.SpanModel) []byte
+	staticHeaders  map[string]string
+	headersEnabled bool
+	headers        []Header
+	batchSize      int
+	batchInterval  time.Duration
+	spanC          chan model.SpanModel
+	flushC         chan chan struct{}
+	quit           chan struct{}
+	shutdown       chan struct{}
+
+	queueCapacity int
+	dropPolicy    DropPolicy
+	queue         *spanQueue
+	forwarded     chan struct{}
+
+	deadLetterTopic string
+	deadLetterMtx   sync.Mutex
+	deadLetterWG    sync.WaitGroup
+	closing         bool
+
+	closeTimeout time.Duration
+	inFlightMtx  sync.Mutex
+	inFlight     map[uint64][]model.SpanModel
+	inFlightID   uint64
+	drained      chan struct{}
 }
 
 // ReporterOption sets a parameter for the kafkaReporter
 type ReporterOption func(c *kafkaReporter)
 
 // Logger sets the logger used to report errors in the collection
-// process.
-func Logger(logger *log.Logger) ReporterOption {
+// process. It accepts a *log.Logger or any other reporter.Logger, e.g. one
+// returned by reporter.SlogLogger.
+func Logger(logger reporter.Logger) ReporterOption {
 	return func(c *kafkaReporter) {
 		c.logger = logger
 	}
 }
 
-// Producer sets the producer used to produce to Kafka.
+// Producer sets the sarama producer used to produce to Kafka. To use other
+// Kafka clients see the Client option.
 func Producer(p sarama.AsyncProducer) ReporterOption {
 	return func(c *kafkaReporter) {
-		c.producer = p
+		c.asyncProducer = p
+	}
+}
+
+// Compression sets the compression codec used by the producer created by the
+// reporter, e.g. sarama.CompressionSnappy. Messages are sent uncompressed by
+// default. This option has no effect on a producer passed using the Producer
+// or Client options.
+//
+// The sarama version used by this module (v1.19) supports gzip, snappy and lz4
+// but not zstd. For zstd compression use a client supporting it, e.g. the
+// franz-go adapter, with the Client option.
+func Compression(codec sarama.CompressionCodec) ReporterOption {
+	return func(c *kafkaReporter) {
+		c.compression = codec
+	}
+}
+
+// CompressionLevel sets the level of the compression codec set with the
+// Compression option. The default level of the codec is used by default.
+func CompressionLevel(level int) ReporterOption {
+	return func(c *kafkaReporter) {
+		c.compressionLevel = level
 	}
 }
 
@@ -76,53 +146,301 @@ func Serializer(serializer reporter.SpanSerializer) ReporterOption {
 	}
 }
 
+// OnDelivery registers a callback function which is invoked once for every
+// message with the spans it holds and the outcome of the delivery: nil once
+// the message has been acknowledged by Kafka, or the reason it was not
+// delivered, e.g. to drive custom retry or alerting logic. The reporter
+// consumes the Successes channel of the producer, so a producer passed using
+// the Producer option must be configured with Producer.Return.Successes
+// enabled. The callback is invoked from the goroutines of the producer and
+// must not block.
+func OnDelivery(fn func(spans []model.SpanModel, err error)) ReporterOption {
+	return func(c *kafkaReporter) {
+		c.onDelivery = fn
+	}
+}
+
+// BatchSize sets the maximum number of spans sent within a single message.
+// Spans are buffered until the batch is full or the batch interval expires and
+// sent as one message holding the list of spans, cutting the per message
+// overhead for high traffic services. The default batch size is 1, sending
+// every span as a message of its own right away.
+func BatchSize(n int) ReporterOption {
+	return func(c *kafkaReporter) {
+		c.batchSize = n
+	}
+}
+
+// BatchInterval sets the maximum duration spans are buffered before they are
+// sent if batching is enabled with the BatchSize option. The default batch
+// interval is 1 second.
+func BatchInterval(d time.Duration) ReporterOption {
+	return func(c *kafkaReporter) {
+		c.batchInterval = d
+	}
+}
+
+// OnDrop registers a callback function which is invoked for every span the
+// reporter fails to deliver, together with the reason, e.g. on serialization
+// failures or when the producer fails to produce the message.
+func OnDrop(fn func(span model.SpanModel, reason error)) ReporterOption {
+	return func(c *kafkaReporter) {
+		c.onDrop = fn
+	}
+}
+
+// OnBatchSent registers a callback function which is invoked for every message
+// acknowledged by Kafka with the number of spans, the size of the message and
+// the duration until the acknowledgement. The reporter consumes the Successes
+// channel of the producer, so a producer passed using the Producer option must
+// be configured with Producer.Return.Successes enabled.
+func OnBatchSent(fn func(count, bytes int, duration time.Duration)) ReporterOption {
+	return func(c *kafkaReporter) {
+		c.onBatchSent = fn
+	}
+}
+
+// OnBatchFailed registers a callback function which is invoked for every
+// message the reporter fails to deliver with the reason and the number of
+// spans.
+func OnBatchFailed(fn func(err error, count int)) ReporterOption {
+	return func(c *kafkaReporter) {
+		c.onBatchFailed = fn
+	}
+}
+
 // NewReporter returns a new Kafka-backed Reporter. address should be a slice of
 // TCP endpoints of the form "host:port".
+//
+// The returned Reporter implements StatsReporter.
 func NewReporter(address []string, options ...ReporterOption) (reporter.Reporter, error) {
 	r := &kafkaReporter{
 		logger:     log.New(os.Stderr, "", log.LstdFlags),
 		topic:      defaultKafkaTopic,
 		serializer: reporter.JSONSerializer{},
+
+		compressionLevel: sarama.CompressionLevelDefault,
+		batchSize:        defaultBatchSize,
+		batchInterval:    defaultBatchInterval,
 	}
 
 	for _, option := range options {
 		option(r)
 	}
+	if r.headersEnabled {
+		r.headers = r.recordHeaders()
+	}
+	if r.topicOptions.verify {
+		if err := r.ensureTopic(address); err != nil {
+			return nil, err
+		}
+	}
+	if r.asyncProducer != nil {
+		r.producer = newSaramaProducer(r.asyncProducer, r.acknowledgements())
+	}
 	if r.producer == nil {
-		p, err := sarama.NewAsyncProducer(address, nil)
+		config := sarama.NewConfig()
+		config.Producer.Return.Successes = r.acknowledgements()
+		config.Producer.Compression = r.compression
+		config.Producer.CompressionLevel = r.compressionLevel
+		if r.compression == sarama.CompressionLZ4 {
+			// minimum version supporting lz4 compression
+			config.Version = sarama.V0_10_0_0
+		}
+		if r.headersEnabled {
+			// minimum version supporting record headers
+			config.Version = sarama.V0_11_0_0
+		}
+		r.security.apply(config)
+		p, err := sarama.NewAsyncProducer(address, config)
 		if err != nil {
 			return nil, err
 		}
-		r.producer = p
+		r.producer = newSaramaProducer(p, config.Producer.Return.Successes)
 	}
 
-	go r.logErrors()
+	if r.batchSize > 1 {
+		if r.batchInterval <= 0 {
+			r.batchInterval = defaultBatchInterval
+		}
+		r.spanC = make(chan model.SpanModel, r.batchSize)
+		r.flushC = make(chan chan struct{})
+		r.quit = make(chan struct{})
+		r.shutdown = make(chan struct{})
+		go r.loop()
+	}
+	if r.queueCapacity > 0 {
+		r.queue = newSpanQueue(r.queueCapacity, r.dropPolicy)
+		r.forwarded = make(chan struct{})
+		go r.forward()
+	}
 
 	return r, nil
 }
 
-func (r *kafkaReporter) logErrors() {
-	for pe := range r.producer.Errors() {
-		r.logger.Print("msg", pe.Msg, "err", pe.Err, "result", "failed to produce msg")
+func (r *kafkaReporter) Send(s model.SpanModel) {
+	if r.queue != nil {
+		r.enqueue(s)
+		return
+	}
+	r.dispatch(s)
+}
+
+// dispatch hands s to the batching loop if batching is enabled, else to the
+// producer.
+func (r *kafkaReporter) dispatch(s model.SpanModel) {
+	if r.spanC != nil {
+		r.spanC <- s
+		return
 	}
+	r.produce([]model.SpanModel{s})
 }
 
-func (r *kafkaReporter) Send(s model.SpanModel) {
-	// Zipkin expects the message to be wrapped in an array
-	ss := []model.SpanModel{s}
-	m, err := json.Marshal(ss)
+func (r *kafkaReporter) loop() {
+	var (
+		batch  = make([]model.SpanModel, 0, r.batchSize)
+		ticker = time.NewTicker(r.batchInterval)
+	)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case s := <-r.spanC:
+			if batch = append(batch, s); len(batch) >= r.batchSize {
+				r.produce(batch)
+				batch = make([]model.SpanModel, 0, r.batchSize)
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				r.produce(batch)
+				batch = make([]model.SpanModel, 0, r.batchSize)
+			}
+		case done := <-r.flushC:
+			// send spans buffered before Flush was called
+			batch = r.drainSpans(batch)
+			if len(batch) > 0 {
+				r.produce(batch)
+				batch = make([]model.SpanModel, 0, r.batchSize)
+			}
+			close(done)
+		case <-r.quit:
+			// send spans buffered before Close was called
+			if batch = r.drainSpans(batch); len(batch) > 0 {
+				r.produce(batch)
+			}
+			close(r.shutdown)
+			return
+		}
+	}
+}
+
+// drainSpans appends the spans buffered in spanC to batch.
+func (r *kafkaReporter) drainSpans(batch []model.SpanModel) []model.SpanModel {
+	for {
+		select {
+		case s := <-r.spanC:
+			batch = append(batch, s)
+		default:
+			return batch
+		}
+	}
+}
+
+// produce sends the spans as a single message, or as a message per partition
+// key if the PartitionKey option is set.
+func (r *kafkaReporter) produce(ss []model.SpanModel) {
+	if r.partitionKey == nil {
+		r.produceMessage(nil, ss)
+		return
+	}
+	for _, g := range groupByKey(ss, r.partitionKey) {
+		r.produceMessage(g.key, g.spans)
+	}
+}
+
+// produceMessage sends the spans as a single message with the provided key.
+func (r *kafkaReporter) produceMessage(key []byte, ss []model.SpanModel) {
+	// Zipkin expects the message to be wrapped in a list of spans
+	batch := make([]*model.SpanModel, len(ss))
+	for i := range ss {
+		batch[i] = &ss[i]
+	}
+	m, err := r.serializer.Serialize(batch)
 	if err != nil {
-		r.logger.Printf("failed when marshalling the span: %s\n", err.Error())
+		r.logger.Printf("failed when marshalling the spans: %s\n", err.Error())
+		r.failed(ss, err)
+		return
+	}
+
+	var id uint64
+	if r.closeTimeout > 0 {
+		id = r.track(ss)
+	}
+	rec := Record{Topic: r.topic, Key: key, Value: m, Headers: r.headers}
+	start := time.Now()
+	done := func(err error) {
+		if r.closeTimeout > 0 && !r.untrack(id) {
+			// already dropped by Close
+			return
+		}
+		if err != nil {
+			r.logger.Printf("failed to produce msg of %d bytes: %s\n", len(m), err.Error())
+			r.deadLetter(rec, err)
+			r.failed(ss, err)
+			return
+		}
+		if r.onBatchSent != nil {
+			r.onBatchSent(len(ss), len(m), time.Since(start))
+		}
+		if r.onDelivery != nil {
+			r.onDelivery(ss, nil)
+		}
+	}
+	r.produceRecord(rec, done)
+}
+
+// produceRecord produces rec, dropping its key and headers if the producer
+// does not implement RecordProducer.
+func (r *kafkaReporter) produceRecord(rec Record, done func(err error)) {
+	if p, ok := r.producer.(RecordProducer); ok {
+		p.ProduceRecord(rec, done)
 		return
 	}
+	r.producer.Produce(rec.Topic, rec.Value, done)
+}
+
+// acknowledgements reports whether the reporter needs to be notified of
+// acknowledged messages.
+func (r *kafkaReporter) acknowledgements() bool {
+	return r.onBatchSent != nil || r.onDelivery != nil || r.closeTimeout > 0
+}
 
-	r.producer.Input() <- &sarama.ProducerMessage{
-		Topic: r.topic,
-		Key:   nil,
-		Value: sarama.ByteEncoder(m),
+func (r *kafkaReporter) failed(ss []model.SpanModel, err error) {
+	if r.onBatchFailed != nil {
+		r.onBatchFailed(err, len(ss))
+	}
+	if r.onDelivery != nil {
+		r.onDelivery(ss, err)
+	}
+	if r.onDrop != nil {
+		for _, s := range ss {
+			r.onDrop(s, err)
+		}
 	}
 }
 
 func (r *kafkaReporter) Close() error {
+	if r.queue != nil {
+		r.queue.close()
+		<-r.forwarded
+	}
+	if r.quit != nil {
+		close(r.quit)
+		<-r.shutdown
+	}
+	if r.closeTimeout > 0 {
+		r.drain()
+	}
+	r.stopDeadLetters()
 	return r.producer.Close()
 }
@@ -20,7 +20,10 @@ import (
 	"time"
 
 	"encoding/json"
+	"io/ioutil"
 	"log"
+	"reflect"
+	"sync"
 
 	"github.com/Shopify/sarama"
 	"github.com/openzipkin/zipkin-go/model"
@@ -34,6 +37,7 @@ type stubProducer struct {
 	err       chan *sarama.ProducerError
 	kafkaDown bool
 	closed    bool
+	succ      chan *sarama.ProducerMessage
 }
 
 func (p *stubProducer) AsyncClose() {}
@@ -45,7 +49,7 @@ func (p *stubProducer) Close() error {
 	return nil
 }
 func (p *stubProducer) Input() chan<- *sarama.ProducerMessage     { return p.in }
-func (p *stubProducer) Successes() <-chan *sarama.ProducerMessage { return nil }
+func (p *stubProducer) Successes() <-chan *sarama.ProducerMessage { return p.succ }
 func (p *stubProducer) Errors() <-chan *sarama.ProducerError      { return p.err }
 
 func newStubProducer(kafkaDown bool) *stubProducer {
@@ -54,6 +58,7 @@ func newStubProducer(kafkaDown bool) *stubProducer {
 		make(chan *sarama.ProducerError),
 		kafkaDown,
 		false,
+		nil,
 	}
 }
 
@@ -95,8 +100,18 @@ func TestKafkaProduceProto(t *testing.T) {
 	for _, want := range spans {
 		m := sendSpan(t, c, p, *want)
 		testMetadata(t, m)
-		have := deserializeSpan(t, m.Value)
-		testEqual(t, want, have)
+		b, err := m.Value.Encode()
+		if err != nil {
+			t.Fatalf("unexpected error in encoding: %v", err)
+		}
+		have, err := zipkin_proto3.ParseSpans(b, false)
+		if err != nil {
+			t.Fatalf("unexpected error in decoding: %v", err)
+		}
+		if want, have := 1, len(have); want != have {
+			t.Fatalf("spans per message want %d, have %d", want, have)
+		}
+		testEqual(t, want, have[0])
 	}
 }
 
@@ -173,6 +188,36 @@ func TestKafkaErrors(t *testing.T) {
 	}
 }
 
+func TestKafkaOnDrop(t *testing.T) {
+	p := newStubProducer(true)
+	dropped := make(chan model.SpanModel, len(spans))
+
+	c, err := kafka.NewReporter(
+		[]string{"192.0.2.10:9092"},
+		kafka.Producer(p),
+		kafka.Logger(log.New(ioutil.Discard, "", log.LstdFlags)),
+		kafka.OnDrop(func(s model.SpanModel, reason error) {
+			if reason == nil {
+				t.Error("expected drop reason")
+			}
+			dropped <- s
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range spans {
+		sendSpan(t, c, p, *want)
+		select {
+		case have := <-dropped:
+			testEqual(t, want, &have)
+		case <-time.After(100 * time.Millisecond):
+			t.Fatal("expected span to be dropped")
+		}
+	}
+}
+
 func sendSpan(t *testing.T, r reporter.Reporter, p *stubProducer, s model.SpanModel) *sarama.ProducerMessage {
 	var m *sarama.ProducerMessage
 	received := make(chan bool, 1)
@@ -259,3 +304,729 @@ func makeNewSpan(methodName string, traceID, spanID, parentSpanID uint64, debug
 		Timestamp: timestamp,
 	}
 }
+
+func TestKafkaBatchCallbacks(t *testing.T) {
+	p := newStubProducer(false)
+	p.succ = make(chan *sarama.ProducerMessage)
+
+	type sentBatch struct {
+		count, bytes int
+	}
+	var (
+		sent   = make(chan sentBatch, 1)
+		failed = make(chan int, 1)
+	)
+
+	c, err := kafka.NewReporter(
+		[]string{"192.0.2.10:9092"},
+		kafka.Producer(p),
+		kafka.OnBatchSent(func(count, bytes int, _ time.Duration) {
+			sent <- sentBatch{count, bytes}
+		}),
+		kafka.OnBatchFailed(func(_ error, count int) {
+			failed <- count
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := sendSpan(t, c, p, *spans[0])
+	p.succ <- m
+	select {
+	case b := <-sent:
+		if want, have := (sentBatch{1, m.Value.Length()}), b; want != have {
+			t.Errorf("sent batch want %+v, have %+v", want, have)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected OnBatchSent to be called")
+	}
+
+	m = sendSpan(t, c, p, *spans[1])
+	p.err <- &sarama.ProducerError{Msg: m, Err: errors.New("kafka is down")}
+	select {
+	case count := <-failed:
+		if want, have := 1, count; want != have {
+			t.Errorf("failed count want %d, have %d", want, have)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected OnBatchFailed to be called")
+	}
+}
+
+func TestCompression(t *testing.T) {
+	broker := newMockBroker(t, "zipkin")
+	defer broker.Close()
+
+	r, err := kafka.NewReporter([]string{broker.Addr()},
+		kafka.Compression(sarama.CompressionGZIP),
+		kafka.CompressionLevel(9),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_ = r.Close()
+
+	// the codec is applied to the producer configuration
+	_, err = kafka.NewReporter([]string{broker.Addr()},
+		kafka.Compression(sarama.CompressionGZIP),
+		kafka.CompressionLevel(42),
+	)
+	if _, ok := err.(sarama.ConfigurationError); !ok {
+		t.Errorf("expected configuration error, have %v", err)
+	}
+}
+
+type fakeProducer struct {
+	topics []string
+	values [][]byte
+	err    error
+	closed bool
+}
+
+func (p *fakeProducer) Produce(topic string, value []byte, done func(err error)) {
+	p.topics = append(p.topics, topic)
+	p.values = append(p.values, value)
+	done(p.err)
+}
+
+func (p *fakeProducer) Close() error {
+	p.closed = true
+	return nil
+}
+
+func TestKafkaClient(t *testing.T) {
+	var sent, sentBytes int
+	p := &fakeProducer{}
+	c, err := kafka.NewReporter(
+		[]string{"192.0.2.10:9092"},
+		kafka.Client(p),
+		kafka.Topic("spans"),
+		kafka.OnBatchSent(func(count, bytes int, _ time.Duration) {
+			sent, sentBytes = count, bytes
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.Send(*spans[0])
+
+	if want, have := 1, len(p.values); want != have {
+		t.Fatalf("messages want %d, have %d", want, have)
+	}
+	if want, have := "spans", p.topics[0]; want != have {
+		t.Errorf("topic want %q, have %q", want, have)
+	}
+	testEqual(t, spans[0], deserializeSpan(t, sarama.ByteEncoder(p.values[0])))
+	if sent != 1 || sentBytes != len(p.values[0]) {
+		t.Errorf("unexpected sent batch of %d spans and %d bytes", sent, sentBytes)
+	}
+
+	if err = c.Close(); err != nil || !p.closed {
+		t.Errorf("expected producer to be closed, have %v", err)
+	}
+}
+
+func TestKafkaClientFailure(t *testing.T) {
+	var failed int
+	p := &fakeProducer{err: errors.New("kafka is down")}
+	dropped := make(chan model.SpanModel, 1)
+	c, err := kafka.NewReporter(
+		[]string{"192.0.2.10:9092"},
+		kafka.Client(p),
+		kafka.Logger(log.New(ioutil.Discard, "", log.LstdFlags)),
+		kafka.OnBatchFailed(func(_ error, count int) {
+			failed += count
+		}),
+		kafka.OnDrop(func(s model.SpanModel, reason error) {
+			if reason != p.err {
+				t.Errorf("drop reason want %v, have %v", p.err, reason)
+			}
+			dropped <- s
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.Send(*spans[0])
+
+	if want, have := 1, failed; want != have {
+		t.Errorf("failed spans want %d, have %d", want, have)
+	}
+	select {
+	case s := <-dropped:
+		testEqual(t, spans[0], &s)
+	default:
+		t.Fatal("expected span to be dropped")
+	}
+}
+
+func TestKafkaBatching(t *testing.T) {
+	var sent []int
+	p := &fakeProducer{}
+	c, err := kafka.NewReporter(
+		[]string{"192.0.2.10:9092"},
+		kafka.Client(p),
+		kafka.BatchSize(2),
+		kafka.BatchInterval(time.Hour),
+		kafka.OnBatchSent(func(count, _ int, _ time.Duration) {
+			sent = append(sent, count)
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range spans {
+		c.Send(*s)
+	}
+	// the last span is sent on close
+	if err = c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if want, have := 2, len(p.values); want != have {
+		t.Fatalf("messages want %d, have %d", want, have)
+	}
+	if want, have := []int{2, 1}, sent; !reflect.DeepEqual(want, have) {
+		t.Errorf("sent batches want %v, have %v", want, have)
+	}
+	var have []*model.SpanModel
+	for _, value := range p.values {
+		var batch []*model.SpanModel
+		if err = json.Unmarshal(value, &batch); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		have = append(have, batch...)
+	}
+	for i, want := range spans {
+		testEqual(t, want, have[i])
+	}
+}
+
+func TestKafkaBatchInterval(t *testing.T) {
+	sent := make(chan int, 1)
+	c, err := kafka.NewReporter(
+		[]string{"192.0.2.10:9092"},
+		kafka.Client(&fakeProducer{}),
+		kafka.BatchSize(10),
+		kafka.BatchInterval(10*time.Millisecond),
+		kafka.OnBatchSent(func(count, _ int, _ time.Duration) {
+			sent <- count
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	c.Send(*spans[0])
+	c.Send(*spans[1])
+
+	select {
+	case count := <-sent:
+		if want, have := 2, count; want != have {
+			t.Errorf("batch size want %d, have %d", want, have)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected batch to be sent after the batch interval")
+	}
+}
+
+type fakeRecordProducer struct {
+	fakeProducer
+	keys []string
+}
+
+func (p *fakeRecordProducer) ProduceRecord(r kafka.Record, done func(err error)) {
+	p.keys = append(p.keys, string(r.Key))
+	p.Produce(r.Topic, r.Value, done)
+}
+
+func TestPartitionKey(t *testing.T) {
+	p := &fakeRecordProducer{}
+	c, err := kafka.NewReporter(
+		[]string{"192.0.2.10:9092"},
+		kafka.Client(p),
+		kafka.BatchSize(4),
+		kafka.BatchInterval(time.Hour),
+		kafka.PartitionKey(kafka.TraceIDKey),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other := makeNewSpan("other", 321, 1, 0, true)
+	c.Send(*spans[0])
+	c.Send(*other)
+	c.Send(*spans[1])
+	c.Send(*spans[2])
+
+	if err = c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a batch is sent as a message per trace
+	if want, have := []string{spans[0].TraceID.String(), other.TraceID.String()}, p.keys; !reflect.DeepEqual(want, have) {
+		t.Fatalf("keys want %v, have %v", want, have)
+	}
+	var batch []*model.SpanModel
+	if err = json.Unmarshal(p.values[0], &batch); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want, have := 3, len(batch); want != have {
+		t.Fatalf("spans of first trace want %d, have %d", want, have)
+	}
+	for i, want := range spans {
+		testEqual(t, want, batch[i])
+	}
+}
+
+func TestPartitionKeySarama(t *testing.T) {
+	p := newStubProducer(false)
+	c, err := kafka.NewReporter(
+		[]string{"192.0.2.10:9092"},
+		kafka.Producer(p),
+		kafka.PartitionKey(kafka.TraceIDKey),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := sendSpan(t, c, p, *spans[0])
+	if m.Key == nil {
+		t.Fatal("expected message key")
+	}
+	key, _ := m.Key.Encode()
+	if want, have := spans[0].TraceID.String(), string(key); want != have {
+		t.Errorf("key want %s, have %s", want, have)
+	}
+}
+
+func TestHeaders(t *testing.T) {
+	p := newStubProducer(false)
+	c, err := kafka.NewReporter(
+		[]string{"192.0.2.10:9092"},
+		kafka.Producer(p),
+		kafka.Serializer(zipkin_proto3.SpanSerializer{}),
+		kafka.Headers(map[string]string{"env": "prod"}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := sendSpan(t, c, p, *spans[0])
+	want := []sarama.RecordHeader{
+		{Key: []byte(kafka.ContentTypeHeader), Value: []byte("application/x-protobuf")},
+		{Key: []byte("env"), Value: []byte("prod")},
+	}
+	if have := m.Headers; !reflect.DeepEqual(want, have) {
+		t.Errorf("headers want %q, have %q", want, have)
+	}
+}
+
+func TestNoHeaders(t *testing.T) {
+	p := newStubProducer(false)
+	c, err := kafka.NewReporter([]string{"192.0.2.10:9092"}, kafka.Producer(p))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// headers require Kafka 0.11 so they are not set by default
+	if m := sendSpan(t, c, p, *spans[0]); m.Headers != nil {
+		t.Errorf("expected no headers, have %q", m.Headers)
+	}
+}
+
+type asyncProducer struct {
+	mtx   sync.Mutex
+	dones []func(error)
+}
+
+func (p *asyncProducer) Produce(_ string, _ []byte, done func(err error)) {
+	p.mtx.Lock()
+	p.dones = append(p.dones, done)
+	p.mtx.Unlock()
+}
+
+func (p *asyncProducer) ack(err error) {
+	p.mtx.Lock()
+	dones := p.dones
+	p.dones = nil
+	p.mtx.Unlock()
+	for _, done := range dones {
+		done(err)
+	}
+}
+
+func (p *asyncProducer) Close() error { return nil }
+
+func TestCloseTimeoutDrained(t *testing.T) {
+	var (
+		p       = &asyncProducer{}
+		sent    = make(chan int, 2)
+		dropped = make(chan model.SpanModel, 2)
+	)
+	c, err := kafka.NewReporter(
+		[]string{"192.0.2.10:9092"},
+		kafka.Client(p),
+		kafka.CloseTimeout(time.Minute),
+		kafka.OnBatchSent(func(count, _ int, _ time.Duration) { sent <- count }),
+		kafka.OnDrop(func(s model.SpanModel, _ error) { dropped <- s }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.Send(*spans[0])
+	c.Send(*spans[1])
+
+	closed := make(chan error)
+	go func() { closed <- c.Close() }()
+
+	select {
+	case <-closed:
+		t.Fatal("expected Close to wait for the messages in flight")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	p.ack(nil)
+	select {
+	case err = <-closed:
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected Close to return once messages are acknowledged")
+	}
+	if want, have := 2, len(sent); want != have {
+		t.Errorf("sent messages want %d, have %d", want, have)
+	}
+	if want, have := 0, len(dropped); want != have {
+		t.Errorf("dropped spans want %d, have %d", want, have)
+	}
+}
+
+func TestCloseTimeoutExpired(t *testing.T) {
+	var (
+		p       = &asyncProducer{}
+		reasons = make(chan error, 2)
+	)
+	c, err := kafka.NewReporter(
+		[]string{"192.0.2.10:9092"},
+		kafka.Client(p),
+		kafka.Logger(log.New(ioutil.Discard, "", log.LstdFlags)),
+		kafka.CloseTimeout(10*time.Millisecond),
+		kafka.OnDrop(func(_ model.SpanModel, reason error) { reasons <- reason }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.Send(*spans[0])
+	if err = c.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// late acknowledgements are ignored
+	p.ack(errors.New("kafka is down"))
+
+	if want, have := 1, len(reasons); want != have {
+		t.Fatalf("dropped spans want %d, have %d", want, have)
+	}
+	if want, have := kafka.ErrCloseTimeout, <-reasons; want != have {
+		t.Errorf("drop reason want %v, have %v", want, have)
+	}
+}
+
+func TestOnDelivery(t *testing.T) {
+	type delivery struct {
+		spans []model.SpanModel
+		err   error
+	}
+	var (
+		p          = newStubProducer(false)
+		deliveries = make(chan delivery, 1)
+		failure    = errors.New("kafka is down")
+	)
+	p.succ = make(chan *sarama.ProducerMessage)
+
+	c, err := kafka.NewReporter(
+		[]string{"192.0.2.10:9092"},
+		kafka.Producer(p),
+		kafka.Logger(log.New(ioutil.Discard, "", log.LstdFlags)),
+		kafka.OnDelivery(func(spans []model.SpanModel, err error) {
+			deliveries <- delivery{spans, err}
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []delivery{
+		{[]model.SpanModel{*spans[0]}, nil},
+		{[]model.SpanModel{*spans[1]}, failure},
+	} {
+		m := sendSpan(t, c, p, want.spans[0])
+		if want.err == nil {
+			p.succ <- m
+		} else {
+			p.err <- &sarama.ProducerError{Msg: m, Err: want.err}
+		}
+
+		select {
+		case have := <-deliveries:
+			if want.err != have.err {
+				t.Errorf("delivery error want %v, have %v", want.err, have.err)
+			}
+			if want, have := 1, len(have.spans); want != have {
+				t.Fatalf("delivered spans want %d, have %d", want, have)
+			}
+			testEqual(t, &want.spans[0], &have.spans[0])
+		case <-time.After(time.Second):
+			t.Fatal("expected OnDelivery to be called")
+		}
+	}
+}
+
+// blockingProducer blocks producing messages until released.
+type blockingProducer struct {
+	started chan struct{}
+	release chan struct{}
+	mtx     sync.Mutex
+	names   []string
+}
+
+func newBlockingProducer() *blockingProducer {
+	return &blockingProducer{
+		started: make(chan struct{}, 10),
+		release: make(chan struct{}),
+	}
+}
+
+func (p *blockingProducer) Produce(_ string, value []byte, done func(err error)) {
+	p.started <- struct{}{}
+	<-p.release
+
+	var batch []*model.SpanModel
+	_ = json.Unmarshal(value, &batch)
+	p.mtx.Lock()
+	for _, s := range batch {
+		p.names = append(p.names, s.Name)
+	}
+	p.mtx.Unlock()
+	done(nil)
+}
+
+func (p *blockingProducer) Close() error { return nil }
+
+func TestQueueDropPolicies(t *testing.T) {
+	extra := makeNewSpan("mul", 123, 131415, 456, true)
+
+	for _, tc := range []struct {
+		policy  kafka.DropPolicy
+		dropped string
+		names   []string
+	}{
+		{kafka.DropNewest, "mul", []string{"avg", "sum", "div"}},
+		{kafka.DropOldest, "sum", []string{"avg", "div", "mul"}},
+	} {
+		var (
+			p       = newBlockingProducer()
+			dropped []string
+		)
+		c, err := kafka.NewReporter(
+			[]string{"192.0.2.10:9092"},
+			kafka.Client(p),
+			kafka.Queue(2, tc.policy),
+			kafka.OnDrop(func(s model.SpanModel, reason error) {
+				if reason != reporter.ErrQueueFull {
+					t.Errorf("drop reason want %v, have %v", reporter.ErrQueueFull, reason)
+				}
+				dropped = append(dropped, s.Name)
+			}),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the first span is taken from the queue and blocks the producer
+		c.Send(*spans[0])
+		<-p.started
+		c.Send(*spans[1])
+		c.Send(*spans[2])
+		c.Send(*extra)
+
+		if want, have := (kafka.Stats{Queued: 2, Dropped: 1}), c.(kafka.StatsReporter).Stats(); want != have {
+			t.Errorf("policy %d: stats want %+v, have %+v", tc.policy, want, have)
+		}
+		if want, have := []string{tc.dropped}, dropped; !reflect.DeepEqual(want, have) {
+			t.Errorf("policy %d: dropped want %v, have %v", tc.policy, want, have)
+		}
+
+		close(p.release)
+		if err = c.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if want, have := tc.names, p.names; !reflect.DeepEqual(want, have) {
+			t.Errorf("policy %d: produced want %v, have %v", tc.policy, want, have)
+		}
+	}
+}
+
+func TestQueueBlock(t *testing.T) {
+	p := newBlockingProducer()
+	c, err := kafka.NewReporter(
+		[]string{"192.0.2.10:9092"},
+		kafka.Client(p),
+		kafka.Queue(1, kafka.Block),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.Send(*spans[0])
+	<-p.started
+	c.Send(*spans[1])
+
+	sent := make(chan struct{})
+	go func() {
+		c.Send(*spans[2])
+		close(sent)
+	}()
+
+	select {
+	case <-sent:
+		t.Fatal("expected Send to block while the queue is full")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(p.release)
+	select {
+	case <-sent:
+	case <-time.After(time.Second):
+		t.Fatal("expected Send to return once the queue has room")
+	}
+
+	if err = c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := []string{"avg", "sum", "div"}, p.names; !reflect.DeepEqual(want, have) {
+		t.Errorf("produced want %v, have %v", want, have)
+	}
+	if want, have := uint64(0), c.(kafka.StatsReporter).Stats().Dropped; want != have {
+		t.Errorf("dropped want %d, have %d", want, have)
+	}
+}
+
+// topicFailingProducer fails producing messages to the topic set in fail.
+type topicFailingProducer struct {
+	fail   string
+	mtx    sync.Mutex
+	topics []string
+	values [][]byte
+}
+
+func (p *topicFailingProducer) Produce(topic string, value []byte, done func(err error)) {
+	p.mtx.Lock()
+	p.topics = append(p.topics, topic)
+	p.values = append(p.values, value)
+	p.mtx.Unlock()
+	if topic == p.fail {
+		done(errors.New("kafka is down"))
+		return
+	}
+	done(nil)
+}
+
+func (p *topicFailingProducer) Close() error { return nil }
+
+func TestDeadLetter(t *testing.T) {
+	var (
+		p           = &topicFailingProducer{fail: "zipkin"}
+		deadLetters []kafka.Record
+		drops       int
+	)
+	c, err := kafka.NewReporter(
+		[]string{"192.0.2.10:9092"},
+		kafka.Client(p),
+		kafka.Logger(log.New(ioutil.Discard, "", log.LstdFlags)),
+		kafka.DeadLetterTopic("zipkin-dlt"),
+		kafka.OnDeadLetter(func(r kafka.Record, err error) {
+			if err == nil {
+				t.Error("expected dead-letter reason")
+			}
+			deadLetters = append(deadLetters, r)
+		}),
+		kafka.OnDrop(func(model.SpanModel, error) { drops++ }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.Send(*spans[0])
+	if err = c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if want, have := []string{"zipkin", "zipkin-dlt"}, p.topics; !reflect.DeepEqual(want, have) {
+		t.Fatalf("topics want %v, have %v", want, have)
+	}
+	if !reflect.DeepEqual(p.values[0], p.values[1]) {
+		t.Errorf("dead-letter message want %q, have %q", p.values[0], p.values[1])
+	}
+	if want, have := 1, len(deadLetters); want != have {
+		t.Fatalf("dead letters want %d, have %d", want, have)
+	}
+	if want, have := "zipkin", deadLetters[0].Topic; want != have {
+		t.Errorf("dead letter topic want %q, have %q", want, have)
+	}
+	if !reflect.DeepEqual(p.values[0], deadLetters[0].Value) {
+		t.Errorf("dead letter value want %q, have %q", p.values[0], deadLetters[0].Value)
+	}
+	if want, have := 1, drops; want != have {
+		t.Errorf("drops want %d, have %d", want, have)
+	}
+}
+
+func TestFlush(t *testing.T) {
+	p := &asyncProducer{}
+	c, err := kafka.NewReporter(
+		[]string{"192.0.2.10:9092"},
+		kafka.Client(p),
+		kafka.Queue(10, kafka.DropNewest),
+		kafka.BatchSize(2),
+		kafka.BatchInterval(time.Hour),
+		kafka.CloseTimeout(10*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	flusher, ok := c.(reporter.Flusher)
+	if !ok {
+		t.Fatal("expected reporter to implement reporter.Flusher")
+	}
+
+	for _, s := range spans {
+		c.Send(*s)
+	}
+	if want, have := kafka.ErrFlushTimeout, flusher.Flush(); want != have {
+		t.Errorf("error want %v, have %v", want, have)
+	}
+
+	p.mtx.Lock()
+	messages := len(p.dones)
+	p.mtx.Unlock()
+	if want, have := 2, messages; want != have {
+		t.Errorf("messages want %d, have %d", want, have)
+	}
+
+	p.ack(nil)
+	if err = flusher.Flush(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package kafkago implements a Kafka reporter using the segmentio/kafka-go
+client for teams not using sarama. The package is a separate module to avoid
+adding the dependency to all users of zipkin-go.
+*/
+package kafkago
+
+import (
+	"context"
+
+	"github.com/segmentio/kafka-go"
+
+	"github.com/openzipkin/zipkin-go/reporter"
+	zipkinkafka "github.com/openzipkin/zipkin-go/reporter/kafka"
+)
+
+// producer adapts a kafka-go writer to the kafka.RecordProducer interface.
+type producer struct {
+	writer *kafka.Writer
+}
+
+// Producer returns a kafka.MessageProducer producing messages using w, for use
+// with the kafka.Client option. The writer is switched to asynchronous mode and
+// its Completion function is replaced to report the outcome of messages, so it
+// should not be shared. The writer must not have a Topic set as the topic is
+// set per message. To partition by the PartitionKey option, the writer needs a
+// key based Balancer like kafka.Hash.
+func Producer(w *kafka.Writer) zipkinkafka.MessageProducer {
+	w.Async = true
+	w.Completion = func(messages []kafka.Message, err error) {
+		for _, m := range messages {
+			if done, ok := m.WriterData.(func(error)); ok {
+				done(err)
+			}
+		}
+	}
+	return producer{writer: w}
+}
+
+// NewReporter returns a new Kafka Reporter using a kafka-go writer connected to
+// the provided brokers of the form "host:port".
+func NewReporter(brokers []string, options ...zipkinkafka.ReporterOption) (reporter.Reporter, error) {
+	w := &kafka.Writer{
+		Addr:     kafka.TCP(brokers...),
+		Balancer: &kafka.LeastBytes{},
+	}
+	return zipkinkafka.NewReporter(brokers, append(options, zipkinkafka.Client(Producer(w)))...)
+}
+
+func (p producer) Produce(topic string, value []byte, done func(err error)) {
+	p.ProduceRecord(zipkinkafka.Record{Topic: topic, Value: value}, done)
+}
+
+func (p producer) ProduceRecord(r zipkinkafka.Record, done func(err error)) {
+	m := kafka.Message{
+		Topic:      r.Topic,
+		Key:        r.Key,
+		Value:      r.Value,
+		WriterData: done,
+	}
+	for _, h := range r.Headers {
+		m.Headers = append(m.Headers, kafka.Header{Key: h.Key, Value: h.Value})
+	}
+	err := p.writer.WriteMessages(context.Background(), m)
+	if err != nil {
+		// asynchronous writes only fail immediately if the writer is closed
+		done(err)
+	}
+}
+
+func (p producer) Close() error {
+	return p.writer.Close()
+}
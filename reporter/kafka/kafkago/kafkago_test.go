@@ -0,0 +1,48 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kafkago
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestProducerCompletion(t *testing.T) {
+	w := &kafka.Writer{}
+	_ = Producer(w)
+
+	if !w.Async {
+		t.Error("expected writer to be switched to asynchronous mode")
+	}
+
+	var have []error
+	failure := errors.New("kafka is down")
+	w.Completion([]kafka.Message{
+		{WriterData: func(err error) { have = append(have, err) }},
+		{WriterData: func(err error) { have = append(have, err) }},
+		{},
+	}, failure)
+
+	if want := 2; len(have) != want {
+		t.Fatalf("completions want %d, have %d", want, len(have))
+	}
+	for _, err := range have {
+		if err != failure {
+			t.Errorf("completion error want %v, have %v", failure, err)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kafka
+
+import (
+	"github.com/openzipkin/zipkin-go/model"
+)
+
+// PartitionKey sets the function returning the key of the message holding a
+// span. Kafka assigns messages with the same key to the same partition, so
+// using TraceIDKey all spans of a trace are delivered to a single consumer in
+// order, allowing per trace aggregation. If batching is enabled, a batch is
+// sent as a message per key. Keys are only set if the MessageProducer
+// implements RecordProducer, and producers need a key based partitioner, e.g.
+// kafka.Hash for kafka-go writers.
+func PartitionKey(fn func(s model.SpanModel) []byte) ReporterOption {
+	return func(c *kafkaReporter) {
+		c.partitionKey = fn
+	}
+}
+
+// TraceIDKey returns the hex encoded trace id of the span, for use with the
+// PartitionKey option.
+func TraceIDKey(s model.SpanModel) []byte {
+	return []byte(s.TraceID.String())
+}
+
+// keyedSpans holds the spans of a batch sharing a message key.
+type keyedSpans struct {
+	key   []byte
+	spans []model.SpanModel
+}
+
+// groupByKey groups the spans by message key in order of appearance.
+func groupByKey(ss []model.SpanModel, partitionKey func(model.SpanModel) []byte) []keyedSpans {
+	var (
+		groups []keyedSpans
+		index  = make(map[string]int)
+	)
+	for _, s := range ss {
+		key := partitionKey(s)
+		i, ok := index[string(key)]
+		if !ok {
+			i = len(groups)
+			index[string(key)] = i
+			groups = append(groups, keyedSpans{key: key})
+		}
+		groups[i].spans = append(groups[i].spans, s)
+	}
+	return groups
+}
@@ -0,0 +1,123 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kafka
+
+import (
+	"github.com/Shopify/sarama"
+)
+
+// MessageProducer describes the methods needed from a Kafka client to produce
+// span messages, allowing Kafka client libraries other than sarama to be used
+// by the reporter. Adapters for franz-go and segmentio/kafka-go are found in
+// the franz and kafkago subpackages which are separate modules.
+type MessageProducer interface {
+	// Produce asynchronously produces a message holding value to topic. The
+	// done function must be invoked with the error if producing the message
+	// fails and with nil once the message has been acknowledged.
+	Produce(topic string, value []byte, done func(err error))
+	// Close flushes pending messages and closes the producer.
+	Close() error
+}
+
+// Record holds a message to be produced.
+type Record struct {
+	Topic string
+	// Key holds the message key used for partitioning, nil if unset.
+	Key   []byte
+	Value []byte
+	// Headers holds the record headers, nil if unset. The slice is shared
+	// between records and must not be modified.
+	Headers []Header
+}
+
+// RecordProducer is implemented by MessageProducers supporting messages with
+// keys and headers. The reporter produces messages using ProduceRecord if implemented by
+// the MessageProducer, else message keys and headers are not set.
+type RecordProducer interface {
+	MessageProducer
+	// ProduceRecord asynchronously produces the record, invoking done as
+	// described for Produce.
+	ProduceRecord(r Record, done func(err error))
+}
+
+// Client sets the MessageProducer used to produce to Kafka. The Compression
+// and CompressionLevel options have no effect on a client passed using this
+// option.
+func Client(p MessageProducer) ReporterOption {
+	return func(c *kafkaReporter) {
+		c.producer = p
+	}
+}
+
+// saramaProducer adapts a sarama.AsyncProducer to the MessageProducer
+// interface. Acknowledgements are only reported if the producer is configured
+// with Producer.Return.Successes enabled.
+type saramaProducer struct {
+	producer sarama.AsyncProducer
+}
+
+func newSaramaProducer(p sarama.AsyncProducer, successes bool) saramaProducer {
+	sp := saramaProducer{producer: p}
+	go sp.reportErrors()
+	if successes {
+		go sp.reportSuccesses()
+	}
+	return sp
+}
+
+func (p saramaProducer) Produce(topic string, value []byte, done func(err error)) {
+	p.ProduceRecord(Record{Topic: topic, Value: value}, done)
+}
+
+func (p saramaProducer) ProduceRecord(r Record, done func(err error)) {
+	msg := &sarama.ProducerMessage{
+		Topic:    r.Topic,
+		Value:    sarama.ByteEncoder(r.Value),
+		Metadata: done,
+	}
+	if r.Key != nil {
+		msg.Key = sarama.ByteEncoder(r.Key)
+	}
+	if r.Headers != nil {
+		msg.Headers = make([]sarama.RecordHeader, len(r.Headers))
+		for i, h := range r.Headers {
+			msg.Headers[i] = sarama.RecordHeader{Key: []byte(h.Key), Value: h.Value}
+		}
+	}
+	p.producer.Input() <- msg
+}
+
+func (p saramaProducer) reportErrors() {
+	for pe := range p.producer.Errors() {
+		if pe.Msg == nil {
+			continue
+		}
+		if done, ok := pe.Msg.Metadata.(func(error)); ok {
+			done(pe.Err)
+		}
+	}
+}
+
+func (p saramaProducer) reportSuccesses() {
+	for msg := range p.producer.Successes() {
+		if done, ok := msg.Metadata.(func(error)); ok {
+			done(nil)
+		}
+	}
+}
+
+func (p saramaProducer) Close() error {
+	return p.producer.Close()
+}
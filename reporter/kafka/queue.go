@@ -0,0 +1,204 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kafka
+
+import (
+	"sync"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter"
+)
+
+// ErrQueueFull is the drop reason of spans discarded because the queue set
+// with the Queue option was full. It is reporter.ErrQueueFull, shared by all
+// reporters.
+var ErrQueueFull = reporter.ErrQueueFull
+
+// DropPolicy decides what happens when a span is sent while the queue set with
+// the Queue option is full.
+type DropPolicy int
+
+// Available drop policies.
+const (
+	// DropNewest discards the span being sent.
+	DropNewest DropPolicy = iota
+	// DropOldest discards the oldest span in the queue to make room for the
+	// span being sent.
+	DropOldest
+	// Block blocks Send until there is room in the queue.
+	Block
+)
+
+// Stats holds the counters of the queue set with the Queue option.
+type Stats struct {
+	// Queued holds the number of spans waiting in the queue.
+	Queued int
+	// Dropped holds the number of spans discarded because the queue was full.
+	Dropped uint64
+}
+
+// StatsReporter is implemented by the Reporter returned by NewReporter and
+// reports the counters of its queue.
+type StatsReporter interface {
+	reporter.Reporter
+	Stats() Stats
+}
+
+// Queue places a bounded queue holding up to capacity spans in front of the
+// producer, so Send does not wait for the producer. Spans sent while the queue
+// is full are handled according to policy, dropped spans are counted in the
+// Stats of the reporter and reported to the OnDrop callback with ErrQueueFull,
+// as are spans sent after Close. By default spans are handed to the producer
+// from Send.
+func Queue(capacity int, policy DropPolicy) ReporterOption {
+	return func(c *kafkaReporter) {
+		c.queueCapacity = capacity
+		c.dropPolicy = policy
+	}
+}
+
+// spanQueue is a bounded FIFO queue of spans backed by a ring buffer.
+type spanQueue struct {
+	mtx     sync.Mutex
+	cond    *sync.Cond
+	spans   []model.SpanModel
+	head    int
+	size    int
+	policy  DropPolicy
+	dropped uint64
+	busy    bool
+	closed  bool
+}
+
+func newSpanQueue(capacity int, policy DropPolicy) *spanQueue {
+	q := &spanQueue{
+		spans:  make([]model.SpanModel, capacity),
+		policy: policy,
+	}
+	q.cond = sync.NewCond(&q.mtx)
+	return q
+}
+
+// push adds s to the queue and returns the span dropped to do so, if any.
+func (q *spanQueue) push(s model.SpanModel) (model.SpanModel, bool) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	for q.policy == Block && q.size == len(q.spans) && !q.closed {
+		q.cond.Wait()
+	}
+	if q.closed {
+		q.dropped++
+		return s, true
+	}
+
+	var (
+		dropped model.SpanModel
+		ok      bool
+	)
+	if q.size == len(q.spans) {
+		q.dropped++
+		if q.policy != DropOldest {
+			return s, true
+		}
+		dropped, ok = q.spans[q.head], true
+		q.head = (q.head + 1) % len(q.spans)
+		q.size--
+	}
+	q.spans[(q.head+q.size)%len(q.spans)] = s
+	q.size++
+	q.cond.Broadcast()
+	return dropped, ok
+}
+
+// pop removes the oldest span from the queue, waiting for one to be pushed if
+// the queue is empty. It returns false once the queue is closed and empty.
+func (q *spanQueue) pop() (model.SpanModel, bool) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	// the span returned by the previous call has been forwarded
+	q.busy = false
+	q.cond.Broadcast()
+	for q.size == 0 {
+		if q.closed {
+			return model.SpanModel{}, false
+		}
+		q.cond.Wait()
+	}
+	s := q.spans[q.head]
+	q.spans[q.head] = model.SpanModel{}
+	q.head = (q.head + 1) % len(q.spans)
+	q.size--
+	q.busy = true
+	q.cond.Broadcast()
+	return s, true
+}
+
+// waitIdle waits until the spans pushed so far have been forwarded or the
+// queue is closed.
+func (q *spanQueue) waitIdle() {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	for (q.size > 0 || q.busy) && !q.closed {
+		q.cond.Wait()
+	}
+}
+
+// close makes pop return false once the queue has been emptied and makes
+// senders blocked on a full queue drop their spans.
+func (q *spanQueue) close() {
+	q.mtx.Lock()
+	q.closed = true
+	q.cond.Broadcast()
+	q.mtx.Unlock()
+}
+
+func (q *spanQueue) stats() Stats {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	return Stats{Queued: q.size, Dropped: q.dropped}
+}
+
+// enqueue adds s to the queue, reporting the span dropped to do so.
+func (r *kafkaReporter) enqueue(s model.SpanModel) {
+	if dropped, ok := r.queue.push(s); ok && r.onDrop != nil {
+		r.onDrop(dropped, ErrQueueFull)
+	}
+}
+
+// forward hands the spans from the queue to the batching loop or the producer
+// until the queue is closed and emptied.
+func (r *kafkaReporter) forward() {
+	defer close(r.forwarded)
+	for {
+		s, ok := r.queue.pop()
+		if !ok {
+			return
+		}
+		r.dispatch(s)
+	}
+}
+
+// Stats returns the counters of the queue set with the Queue option, or zero
+// Stats if the option is not set.
+func (r *kafkaReporter) Stats() Stats {
+	if r.queue == nil {
+		return Stats{}
+	}
+	return r.queue.stats()
+}
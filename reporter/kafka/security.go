@@ -0,0 +1,78 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kafka
+
+import (
+	"crypto/tls"
+
+	"github.com/Shopify/sarama"
+)
+
+// securityOptions holds the settings for connecting to secured clusters.
+type securityOptions struct {
+	tlsConfig    *tls.Config
+	saslUser     string
+	saslPassword string
+	clientID     string
+}
+
+// TLS enables TLS for the connections to the brokers using config. It applies
+// to the producer created by the reporter and to topic verification, but has
+// no effect on a producer passed using the Producer or Client options.
+func TLS(config *tls.Config) ReporterOption {
+	return func(c *kafkaReporter) {
+		c.security.tlsConfig = config
+	}
+}
+
+// SASLPlain enables SASL/PLAIN authentication with the brokers using the
+// provided credentials, usually combined with the TLS option. It applies to
+// the producer created by the reporter and to topic verification, but has no
+// effect on a producer passed using the Producer or Client options.
+//
+// The sarama version used by this module (v1.19) does not support SASL/SCRAM.
+// For SCRAM authentication configure a client supporting it, e.g. franz-go's
+// sasl/scram package, and pass it using the Client option.
+func SASLPlain(user, password string) ReporterOption {
+	return func(c *kafkaReporter) {
+		c.security.saslUser = user
+		c.security.saslPassword = password
+	}
+}
+
+// ClientID sets the client id the reporter identifies itself with to the
+// brokers, e.g. for quotas and broker side logging. It has no effect on a
+// producer passed using the Producer or Client options.
+func ClientID(id string) ReporterOption {
+	return func(c *kafkaReporter) {
+		c.security.clientID = id
+	}
+}
+
+// apply sets the security options on the sarama configuration.
+func (o securityOptions) apply(config *sarama.Config) {
+	if o.tlsConfig != nil {
+		config.Net.TLS.Enable = true
+		config.Net.TLS.Config = o.tlsConfig
+	}
+	if o.saslUser != "" || o.saslPassword != "" {
+		config.Net.SASL.Enable = true
+		config.Net.SASL.User = o.saslUser
+		config.Net.SASL.Password = o.saslPassword
+	}
+	if o.clientID != "" {
+		config.ClientID = o.clientID
+	}
+}
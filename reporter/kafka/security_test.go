@@ -0,0 +1,77 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kafka_test
+
+import (
+	"crypto/tls"
+	"net"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/openzipkin/zipkin-go/reporter/kafka"
+)
+
+func TestSecurityOptionsConfiguration(t *testing.T) {
+	// invalid options are rejected before connecting to the brokers
+	for _, option := range []kafka.ReporterOption{
+		kafka.SASLPlain("user", ""),
+		kafka.ClientID("invalid client id"),
+	} {
+		_, err := kafka.NewReporter([]string{"192.0.2.10:9092"}, option)
+		if _, ok := err.(sarama.ConfigurationError); !ok {
+			t.Errorf("expected configuration error, have %v", err)
+		}
+	}
+}
+
+func TestTLS(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer ln.Close()
+
+	handshakes := make(chan bool, 10)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			b := make([]byte, 1)
+			_, err = conn.Read(b)
+			// TLS connections start with a handshake record
+			handshakes <- err == nil && b[0] == 0x16
+			_ = conn.Close()
+		}
+	}()
+
+	_, err = kafka.NewReporter([]string{ln.Addr().String()},
+		kafka.Producer(newStubProducer(false)),
+		kafka.VerifyTopic(true),
+		kafka.TLS(&tls.Config{InsecureSkipVerify: true}),
+	)
+	if err == nil {
+		t.Error("expected topic verification to fail")
+	}
+	select {
+	case handshake := <-handshakes:
+		if !handshake {
+			t.Error("expected TLS handshake")
+		}
+	default:
+		t.Error("expected connection to the broker")
+	}
+}
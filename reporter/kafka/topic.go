@@ -0,0 +1,99 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kafka
+
+import (
+	"errors"
+
+	"github.com/Shopify/sarama"
+)
+
+// ErrTopicNotFound is returned by NewReporter if topic verification is enabled
+// and the topic does not exist.
+var ErrTopicNotFound = errors.New("kafka topic not found")
+
+// topicOptions holds the topic verification and creation settings.
+type topicOptions struct {
+	verify            bool
+	create            bool
+	partitions        int32
+	replicationFactor int16
+}
+
+// VerifyTopic enables verifying the topic exists when creating the reporter,
+// failing fast with ErrTopicNotFound instead of silently losing messages.
+func VerifyTopic(enabled bool) ReporterOption {
+	return func(c *kafkaReporter) {
+		c.topicOptions.verify = enabled
+	}
+}
+
+// CreateTopic enables verifying the topic exists when creating the reporter
+// and creates it with the provided number of partitions and replication factor
+// if it does not. Creating topics requires Kafka 0.10.1 or newer.
+func CreateTopic(partitions int32, replicationFactor int16) ReporterOption {
+	return func(c *kafkaReporter) {
+		c.topicOptions = topicOptions{
+			verify:            true,
+			create:            true,
+			partitions:        partitions,
+			replicationFactor: replicationFactor,
+		}
+	}
+}
+
+// ensureTopic verifies the topic exists on the cluster at address and creates
+// it if requested.
+func (r *kafkaReporter) ensureTopic(address []string) error {
+	config := sarama.NewConfig()
+	// minimum version supporting topic creation
+	config.Version = sarama.V0_10_1_0
+	r.security.apply(config)
+
+	client, err := sarama.NewClient(address, config)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	topics, err := client.Topics()
+	if err != nil {
+		return err
+	}
+	for _, topic := range topics {
+		if topic == r.topic {
+			return nil
+		}
+	}
+	if !r.topicOptions.create {
+		return ErrTopicNotFound
+	}
+
+	admin, err := sarama.NewClusterAdmin(address, config)
+	if err != nil {
+		return err
+	}
+	defer admin.Close()
+
+	err = admin.CreateTopic(r.topic, &sarama.TopicDetail{
+		NumPartitions:     r.topicOptions.partitions,
+		ReplicationFactor: r.topicOptions.replicationFactor,
+	}, false)
+	if err == sarama.ErrTopicAlreadyExists {
+		// created concurrently
+		return nil
+	}
+	return err
+}
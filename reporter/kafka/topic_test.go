@@ -0,0 +1,91 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kafka_test
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/openzipkin/zipkin-go/reporter/kafka"
+)
+
+func newMockBroker(t *testing.T, topics ...string) *sarama.MockBroker {
+	broker := sarama.NewMockBroker(t, 1)
+	metadata := sarama.NewMockMetadataResponse(t).
+		SetController(broker.BrokerID()).
+		SetBroker(broker.Addr(), broker.BrokerID())
+	for _, topic := range topics {
+		metadata.SetLeader(topic, 0, broker.BrokerID())
+	}
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest":     metadata,
+		"CreateTopicsRequest": sarama.NewMockCreateTopicsResponse(t),
+	})
+	return broker
+}
+
+func TestVerifyTopic(t *testing.T) {
+	broker := newMockBroker(t, "zipkin")
+	defer broker.Close()
+
+	r, err := kafka.NewReporter([]string{broker.Addr()},
+		kafka.Producer(newStubProducer(false)),
+		kafka.VerifyTopic(true),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_ = r.Close()
+
+	_, err = kafka.NewReporter([]string{broker.Addr()},
+		kafka.Producer(newStubProducer(false)),
+		kafka.Topic("spans"),
+		kafka.VerifyTopic(true),
+	)
+	if want, have := kafka.ErrTopicNotFound, err; want != have {
+		t.Errorf("error want %v, have %v", want, have)
+	}
+}
+
+func TestCreateTopic(t *testing.T) {
+	broker := newMockBroker(t)
+	defer broker.Close()
+
+	r, err := kafka.NewReporter([]string{broker.Addr()},
+		kafka.Producer(newStubProducer(false)),
+		kafka.CreateTopic(3, 1),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_ = r.Close()
+
+	var created bool
+	for _, rr := range broker.History() {
+		if req, ok := rr.Request.(*sarama.CreateTopicsRequest); ok {
+			detail := req.TopicDetails["zipkin"]
+			if detail == nil {
+				t.Fatal("expected zipkin topic to be created")
+			}
+			if detail.NumPartitions != 3 || detail.ReplicationFactor != 1 {
+				t.Errorf("unexpected topic detail: %+v", detail)
+			}
+			created = true
+		}
+	}
+	if !created {
+		t.Error("expected topic to be created")
+	}
+}
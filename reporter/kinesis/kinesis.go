@@ -0,0 +1,497 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package kinesis implements a reporter to send spans to AWS Kinesis Data
+Streams, e.g. consumed by the Kinesis collector of zipkin-aws or downstream
+analytics.
+
+Spans are batched into PutRecords calls using the trace id as partition key,
+so the spans of a trace end up on the same shard. By default the spans of a
+trace in a batch are aggregated into a single record holding the list of
+spans. Requests are signed using AWS Signature Version 4 without depending on
+the AWS SDK; credentials and region default to the AWS_ACCESS_KEY_ID,
+AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN and AWS_REGION environment variables.
+*/
+package kinesis
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter"
+	"github.com/openzipkin/zipkin-go/reporter/internal/batch"
+	"github.com/openzipkin/zipkin-go/reporter/internal/sigv4"
+)
+
+// defaults
+const (
+	defaultTimeout       = time.Second * 5 // timeout for the PutRecords call
+	defaultBatchInterval = time.Second * 1 // BatchInterval in seconds
+	defaultBatchSize     = 100
+	defaultMaxBacklog    = 1000
+	defaultRetryAttempts = 3
+	defaultRetryBackoff  = 100 * time.Millisecond
+)
+
+// Kinesis limits
+const (
+	maxRecordsPerRequest = 500
+	maxRecordSize        = 1 << 20
+	maxRequestSize       = 5 << 20
+)
+
+// Errors returned or reported by the Kinesis reporter.
+var (
+	ErrMissingRegion      = errors.New("kinesis: region required")
+	ErrMissingCredentials = errors.New("kinesis: credentials required")
+	ErrRecordTooLarge     = errors.New("kinesis: record too large")
+)
+
+// Credentials are AWS security credentials.
+type Credentials = sigv4.Credentials
+
+// record is a Kinesis record and the spans it holds.
+type record struct {
+	data         []byte
+	partitionKey string
+	spans        []*model.SpanModel
+}
+
+// kinesisReporter will send spans to a Kinesis data stream.
+type kinesisReporter struct {
+	stream        string
+	region        string
+	endpoint      string
+	credentials   func() (Credentials, error)
+	client        *http.Client
+	aggregate     bool
+	retryAttempts int
+	retryBackoff  time.Duration
+	logger        reporter.Logger
+	batchInterval time.Duration
+	batchSize     int
+	maxBacklog    int
+	serializer    reporter.SpanSerializer
+	onDrop        func(model.SpanModel, error)
+	batcher       *batch.Batcher
+}
+
+// ReporterOption sets a parameter for the Kinesis Reporter.
+type ReporterOption func(r *kinesisReporter)
+
+// Region sets the AWS region of the stream.
+func Region(region string) ReporterOption {
+	return func(r *kinesisReporter) { r.region = region }
+}
+
+// Endpoint sets the Kinesis API endpoint, e.g. for VPC endpoints or local
+// emulators. By default the regional endpoint is used.
+func Endpoint(url string) ReporterOption {
+	return func(r *kinesisReporter) { r.endpoint = url }
+}
+
+// StaticCredentials sets the credentials used to sign requests.
+func StaticCredentials(accessKeyID, secretAccessKey, sessionToken string) ReporterOption {
+	return func(r *kinesisReporter) {
+		creds := Credentials{
+			AccessKeyID:     accessKeyID,
+			SecretAccessKey: secretAccessKey,
+			SessionToken:    sessionToken,
+		}
+		r.credentials = func() (Credentials, error) { return creds, nil }
+	}
+}
+
+// CredentialsFunc sets the function returning the credentials used to sign
+// requests, e.g. temporary credentials of an assumed role retrieved and
+// refreshed by the AWS SDK. It is invoked for every request.
+func CredentialsFunc(fn func() (Credentials, error)) ReporterOption {
+	return func(r *kinesisReporter) { r.credentials = fn }
+}
+
+// Client sets a custom http client to use.
+func Client(client *http.Client) ReporterOption {
+	return func(r *kinesisReporter) { r.client = client }
+}
+
+// Aggregation when enabled aggregates the spans of a trace within a batch into
+// a single record. Otherwise every span is sent as a record holding a list of
+// one span. Aggregation is enabled by default.
+func Aggregation(enabled bool) ReporterOption {
+	return func(r *kinesisReporter) { r.aggregate = enabled }
+}
+
+// Retry sets the number of attempts for records failing due to throttling or
+// service errors, waiting an exponential backoff starting at backoff between
+// attempts. By default records are attempted 3 times starting with a backoff
+// of 100ms.
+func Retry(attempts int, backoff time.Duration) ReporterOption {
+	return func(r *kinesisReporter) {
+		r.retryAttempts = attempts
+		if backoff > 0 {
+			r.retryBackoff = backoff
+		}
+	}
+}
+
+// Timeout sets maximum timeout for PutRecords calls.
+func Timeout(duration time.Duration) ReporterOption {
+	return func(r *kinesisReporter) { r.client.Timeout = duration }
+}
+
+// BatchSize sets the maximum batch size, after which a collect will be
+// triggered. The default batch size is 100 traces.
+func BatchSize(n int) ReporterOption {
+	return func(r *kinesisReporter) { r.batchSize = n }
+}
+
+// MaxBacklog sets the maximum backlog size. When batch size reaches this
+// threshold, spans from the beginning of the batch will be disposed.
+func MaxBacklog(n int) ReporterOption {
+	return func(r *kinesisReporter) { r.maxBacklog = n }
+}
+
+// BatchInterval sets the maximum duration we will buffer traces before
+// emitting them to the stream. The default batch interval is 1 second.
+func BatchInterval(d time.Duration) ReporterOption {
+	return func(r *kinesisReporter) { r.batchInterval = d }
+}
+
+// Serializer sets the serialization function to use for sending span data to
+// Zipkin.
+func Serializer(serializer reporter.SpanSerializer) ReporterOption {
+	return func(r *kinesisReporter) {
+		if serializer != nil {
+			r.serializer = serializer
+		}
+	}
+}
+
+// Logger sets the logger used to report errors in the collection
+// process. It accepts a *log.Logger or any other reporter.Logger, e.g. one
+// returned by reporter.SlogLogger.
+func Logger(l reporter.Logger) ReporterOption {
+	return func(r *kinesisReporter) { r.logger = l }
+}
+
+// OnDrop registers a callback function which is invoked for every span the
+// reporter fails to deliver, together with the reason. Spans are dropped when
+// the backlog overflows, on serialization failures and when their record is
+// rejected or fails after all attempts. The callback is invoked synchronously
+// from the reporter's goroutines so it should not block.
+func OnDrop(fn func(span model.SpanModel, reason error)) ReporterOption {
+	return func(r *kinesisReporter) { r.onDrop = fn }
+}
+
+// NewReporter returns a new Kinesis Reporter sending spans to stream.
+func NewReporter(stream string, opts ...ReporterOption) (reporter.Reporter, error) {
+	r := &kinesisReporter{
+		stream:        stream,
+		region:        sigv4.RegionFromEnv(),
+		client:        &http.Client{Timeout: defaultTimeout},
+		aggregate:     true,
+		retryAttempts: defaultRetryAttempts,
+		retryBackoff:  defaultRetryBackoff,
+		logger:        log.New(os.Stderr, "", log.LstdFlags),
+		batchInterval: defaultBatchInterval,
+		batchSize:     defaultBatchSize,
+		maxBacklog:    defaultMaxBacklog,
+		serializer:    reporter.JSONSerializer{},
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	if r.region == "" {
+		return nil, ErrMissingRegion
+	}
+	if r.credentials == nil {
+		creds := sigv4.FromEnv()
+		if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+			return nil, ErrMissingCredentials
+		}
+		r.credentials = func() (Credentials, error) { return creds, nil }
+	}
+	if r.endpoint == "" {
+		r.endpoint = "https://kinesis." + r.region + ".amazonaws.com"
+	}
+
+	r.batcher = batch.New(batch.Options{
+		Interval:   r.batchInterval,
+		Size:       r.batchSize,
+		MaxBacklog: r.maxBacklog,
+		Logger:     r.logger,
+		OnDrop:     r.onDrop,
+	}, r.sendBatch)
+
+	return r, nil
+}
+
+// Send implements reporter
+func (r *kinesisReporter) Send(s model.SpanModel) {
+	r.batcher.Send(s)
+}
+
+// Flush implements reporter.Flusher. It sends the buffered spans to the
+// stream and returns the first error encountered. Flush does nothing once the
+// reporter is closed.
+func (r *kinesisReporter) Flush() error {
+	return r.batcher.Flush()
+}
+
+// Close implements reporter
+func (r *kinesisReporter) Close() error {
+	return r.batcher.Close()
+}
+
+func (r *kinesisReporter) sendBatch(spans []*model.SpanModel) error {
+	records, err := r.records(spans)
+	var (
+		chunk []record
+		size  int
+	)
+	for _, rec := range records {
+		recSize := len(rec.data) + len(rec.partitionKey)
+		if len(chunk) == maxRecordsPerRequest || size+recSize > maxRequestSize {
+			if perr := r.put(chunk); err == nil {
+				err = perr
+			}
+			chunk, size = nil, 0
+		}
+		chunk = append(chunk, rec)
+		size += recSize
+	}
+	if len(chunk) > 0 {
+		if perr := r.put(chunk); err == nil {
+			err = perr
+		}
+	}
+	return err
+}
+
+// records builds the records holding spans, keyed by trace id.
+func (r *kinesisReporter) records(spans []*model.SpanModel) ([]record, error) {
+	var (
+		groups [][]*model.SpanModel
+		index  = make(map[model.TraceID]int)
+	)
+	for _, span := range spans {
+		if i, ok := index[span.TraceID]; ok && r.aggregate {
+			groups[i] = append(groups[i], span)
+			continue
+		}
+		index[span.TraceID] = len(groups)
+		groups = append(groups, []*model.SpanModel{span})
+	}
+
+	var (
+		records  []record
+		firstErr error
+	)
+	for _, group := range groups {
+		data, err := r.serializer.Serialize(group)
+		if err == nil && len(data) > maxRecordSize && len(group) > 1 {
+			// send the spans of the trace as records of their own
+			for _, span := range group {
+				rec, err := r.record([]*model.SpanModel{span})
+				if err != nil {
+					if firstErr == nil {
+						firstErr = err
+					}
+					continue
+				}
+				records = append(records, rec)
+			}
+			continue
+		}
+		if err == nil && len(data) > maxRecordSize {
+			err = ErrRecordTooLarge
+		}
+		if err != nil {
+			r.logger.Printf("failed when marshalling the spans batch: %s\n", err.Error())
+			r.batcher.Drop(group, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		records = append(records, record{data: data, partitionKey: group[0].TraceID.String(), spans: group})
+	}
+	return records, firstErr
+}
+
+// record builds the record holding spans.
+func (r *kinesisReporter) record(spans []*model.SpanModel) (record, error) {
+	data, err := r.serializer.Serialize(spans)
+	if err == nil && len(data) > maxRecordSize {
+		err = ErrRecordTooLarge
+	}
+	if err != nil {
+		r.logger.Printf("failed when marshalling the spans batch: %s\n", err.Error())
+		r.batcher.Drop(spans, err)
+		return record{}, err
+	}
+	return record{data: data, partitionKey: spans[0].TraceID.String(), spans: spans}, nil
+}
+
+type putRecordsEntry struct {
+	Data         []byte `json:"Data"`
+	PartitionKey string `json:"PartitionKey"`
+}
+
+type putRecordsRequest struct {
+	StreamName string            `json:"StreamName"`
+	Records    []putRecordsEntry `json:"Records"`
+}
+
+type putRecordsResult struct {
+	FailedRecordCount int `json:"FailedRecordCount"`
+	Records           []struct {
+		ErrorCode    string `json:"ErrorCode"`
+		ErrorMessage string `json:"ErrorMessage"`
+	} `json:"Records"`
+}
+
+type errorResponse struct {
+	Type    string `json:"__type"`
+	Message string `json:"message"`
+}
+
+// retryableErrors are the error types of requests which might succeed when
+// retried.
+var retryableErrors = []string{
+	"ProvisionedThroughputExceededException",
+	"ThrottlingException",
+	"LimitExceededException",
+	"KMSThrottlingException",
+	"InternalFailure",
+	"ServiceUnavailable",
+}
+
+func retryable(errorType string) bool {
+	for _, t := range retryableErrors {
+		if strings.HasSuffix(errorType, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// put sends records using PutRecords, retrying failed records.
+func (r *kinesisReporter) put(records []record) error {
+	var err error
+	for attempt := 1; len(records) > 0; attempt++ {
+		if attempt > 1 {
+			time.Sleep(r.retryBackoff << uint(attempt-2))
+		}
+		var failed []record
+		failed, err = r.putRecords(records)
+		if err == nil || attempt >= r.retryAttempts {
+			records = failed
+			break
+		}
+		records = failed
+	}
+	for _, rec := range records {
+		r.batcher.Drop(rec.spans, err)
+	}
+	return err
+}
+
+// putRecords sends records and returns the records to retry and the reason.
+// Records failing permanently are dropped.
+func (r *kinesisReporter) putRecords(records []record) ([]record, error) {
+	req := putRecordsRequest{StreamName: r.stream, Records: make([]putRecordsEntry, len(records))}
+	for i, rec := range records {
+		req.Records[i] = putRecordsEntry{Data: rec.data, PartitionKey: rec.partitionKey}
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return records, err
+	}
+
+	creds, err := r.credentials()
+	if err != nil {
+		r.logger.Printf("failed to retrieve credentials: %s\n", err.Error())
+		return records, err
+	}
+	httpReq, err := http.NewRequest("POST", r.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return records, err
+	}
+	// make sure instrumented transports do not trace the delivery of spans
+	httpReq = httpReq.WithContext(reporter.NewUntracedContext(context.Background()))
+	httpReq.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	httpReq.Header.Set("X-Amz-Target", "Kinesis_20131202.PutRecords")
+	sigv4.Sign(httpReq, body, creds, r.region, "kinesis", time.Now())
+
+	resp, err := r.client.Do(httpReq)
+	if err != nil {
+		r.logger.Printf("failed to send the request: %s\n", err.Error())
+		return records, err
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return records, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var e errorResponse
+		_ = json.Unmarshal(respBody, &e)
+		err = fmt.Errorf("kinesis: PutRecords failed with status code %d: %s %s", resp.StatusCode, e.Type, e.Message)
+		r.logger.Printf("%s\n", err.Error())
+		if resp.StatusCode > 499 || retryable(e.Type) {
+			return records, err
+		}
+		for _, rec := range records {
+			r.batcher.Drop(rec.spans, err)
+		}
+		return nil, err
+	}
+
+	var result putRecordsResult
+	if err = json.Unmarshal(respBody, &result); err != nil {
+		return records, err
+	}
+	if result.FailedRecordCount == 0 {
+		return nil, nil
+	}
+	var failed []record
+	for i, res := range result.Records {
+		if res.ErrorCode == "" || i >= len(records) {
+			continue
+		}
+		err = fmt.Errorf("kinesis: record failed: %s %s", res.ErrorCode, res.ErrorMessage)
+		if retryable(res.ErrorCode) {
+			failed = append(failed, records[i])
+		} else {
+			r.batcher.Drop(records[i].spans, err)
+		}
+	}
+	r.logger.Printf("failed to put %d records\n", result.FailedRecordCount)
+	return failed, err
+}
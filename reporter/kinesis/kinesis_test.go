@@ -0,0 +1,354 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kinesis_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter"
+	"github.com/openzipkin/zipkin-go/reporter/kinesis"
+)
+
+type putRecords struct {
+	StreamName string `json:"StreamName"`
+	Records    []struct {
+		Data         []byte `json:"Data"`
+		PartitionKey string `json:"PartitionKey"`
+	} `json:"Records"`
+}
+
+type fakeKinesis struct {
+	mtx      sync.Mutex
+	requests []putRecords
+	headers  []http.Header
+	// respond returns the error code for the record with the given
+	// partition key in the given attempt, if any.
+	respond func(partitionKey string, attempt int) string
+	status  int
+}
+
+func (f *fakeKinesis) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	var req putRecords
+	if err := json.Unmarshal(body, &req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	f.mtx.Lock()
+	f.requests = append(f.requests, req)
+	f.headers = append(f.headers, r.Header)
+	attempt := len(f.requests)
+	status := f.status
+	f.mtx.Unlock()
+
+	if status != 0 {
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(`{"__type":"ResourceNotFoundException","message":"Stream not found"}`))
+		return
+	}
+
+	type result struct {
+		ErrorCode      string `json:",omitempty"`
+		SequenceNumber string `json:",omitempty"`
+	}
+	var (
+		results []result
+		failed  int
+	)
+	for _, rec := range req.Records {
+		var code string
+		if f.respond != nil {
+			code = f.respond(rec.PartitionKey, attempt)
+		}
+		if code != "" {
+			failed++
+			results = append(results, result{ErrorCode: code})
+			continue
+		}
+		results = append(results, result{SequenceNumber: "1"})
+	}
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"FailedRecordCount": failed,
+		"Records":           results,
+	})
+}
+
+func (f *fakeKinesis) Requests() []putRecords {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	return append([]putRecords(nil), f.requests...)
+}
+
+var spans = []*model.SpanModel{
+	makeNewSpan("a", 1, 1),
+	makeNewSpan("b", 2, 2),
+	makeNewSpan("c", 1, 3),
+	makeNewSpan("d", 3, 4),
+}
+
+func makeNewSpan(name string, traceID, spanID uint64) *model.SpanModel {
+	return &model.SpanModel{
+		SpanContext: model.SpanContext{
+			TraceID: model.TraceID{Low: traceID},
+			ID:      model.ID(spanID),
+		},
+		Name:      name,
+		Timestamp: time.Now(),
+	}
+}
+
+func TestPutRecords(t *testing.T) {
+	fake := &fakeKinesis{}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	rep, err := kinesis.NewReporter("zipkin",
+		kinesis.Region("eu-west-1"),
+		kinesis.Endpoint(srv.URL),
+		kinesis.StaticCredentials("AKID", "SECRET", "TOKEN"),
+		kinesis.BatchInterval(time.Hour),
+		kinesis.Retry(3, time.Millisecond),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer rep.Close()
+
+	for _, span := range spans[:3] {
+		rep.Send(*span)
+	}
+	if err := rep.(reporter.Flusher).Flush(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	requests := fake.Requests()
+	if want, have := 1, len(requests); want != have {
+		t.Fatalf("requests want %d, have %d", want, have)
+	}
+	req := requests[0]
+	if want, have := "zipkin", req.StreamName; want != have {
+		t.Errorf("stream name want %q, have %q", want, have)
+	}
+	if want, have := 2, len(req.Records); want != have {
+		t.Fatalf("records want %d, have %d", want, have)
+	}
+
+	wantKeys := []string{"0000000000000001", "0000000000000002"}
+	wantSpans := [][]string{{"a", "c"}, {"b"}}
+	for i, rec := range req.Records {
+		if want, have := wantKeys[i], rec.PartitionKey; want != have {
+			t.Errorf("partition key %d want %q, have %q", i, want, have)
+		}
+		var spans []model.SpanModel
+		if err := json.Unmarshal(rec.Data, &spans); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if want, have := len(wantSpans[i]), len(spans); want != have {
+			t.Fatalf("spans of record %d want %d, have %d", i, want, have)
+		}
+		for j, span := range spans {
+			if want, have := wantSpans[i][j], span.Name; want != have {
+				t.Errorf("span name want %q, have %q", want, have)
+			}
+		}
+	}
+
+	h := fake.headers[0]
+	if want, have := "Kinesis_20131202.PutRecords", h.Get("X-Amz-Target"); want != have {
+		t.Errorf("target want %q, have %q", want, have)
+	}
+	if want, have := "application/x-amz-json-1.1", h.Get("Content-Type"); want != have {
+		t.Errorf("content type want %q, have %q", want, have)
+	}
+	if want, have := "TOKEN", h.Get("X-Amz-Security-Token"); want != have {
+		t.Errorf("security token want %q, have %q", want, have)
+	}
+	auth := h.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/") ||
+		!strings.Contains(auth, "/eu-west-1/kinesis/aws4_request") {
+		t.Errorf("unexpected authorization header %q", auth)
+	}
+}
+
+func TestNoAggregation(t *testing.T) {
+	fake := &fakeKinesis{}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	rep, err := kinesis.NewReporter("zipkin",
+		kinesis.Region("eu-west-1"),
+		kinesis.Endpoint(srv.URL),
+		kinesis.StaticCredentials("AKID", "SECRET", "TOKEN"),
+		kinesis.BatchInterval(time.Hour),
+		kinesis.Retry(3, time.Millisecond),
+		kinesis.Aggregation(false),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer rep.Close()
+
+	rep.Send(*spans[0])
+	rep.Send(*spans[2])
+	if err := rep.(reporter.Flusher).Flush(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	requests := fake.Requests()
+	if want, have := 1, len(requests); want != have {
+		t.Fatalf("requests want %d, have %d", want, have)
+	}
+	if want, have := 2, len(requests[0].Records); want != have {
+		t.Fatalf("records want %d, have %d", want, have)
+	}
+	for _, rec := range requests[0].Records {
+		if want, have := "0000000000000001", rec.PartitionKey; want != have {
+			t.Errorf("partition key want %q, have %q", want, have)
+		}
+	}
+}
+
+func TestRetryFailedRecords(t *testing.T) {
+	fake := &fakeKinesis{
+		respond: func(partitionKey string, attempt int) string {
+			switch {
+			case partitionKey == "0000000000000002" && attempt == 1:
+				return "ProvisionedThroughputExceededException"
+			case partitionKey == "0000000000000003":
+				return "InternalFailure"
+			}
+			return ""
+		},
+	}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	var (
+		mtx     sync.Mutex
+		dropped []string
+	)
+	rep, err := kinesis.NewReporter("zipkin",
+		kinesis.Region("eu-west-1"),
+		kinesis.Endpoint(srv.URL),
+		kinesis.StaticCredentials("AKID", "SECRET", "TOKEN"),
+		kinesis.BatchInterval(time.Hour),
+		kinesis.Retry(3, time.Millisecond),
+		kinesis.OnDrop(func(span model.SpanModel, _ error) {
+			mtx.Lock()
+			dropped = append(dropped, span.Name)
+			mtx.Unlock()
+		}),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer rep.Close()
+
+	rep.Send(*spans[0])
+	rep.Send(*spans[1])
+	rep.Send(*spans[3])
+	if err := rep.(reporter.Flusher).Flush(); err == nil {
+		t.Fatal("expected error")
+	}
+
+	requests := fake.Requests()
+	if want, have := 3, len(requests); want != have {
+		t.Fatalf("requests want %d, have %d", want, have)
+	}
+	if want, have := 3, len(requests[0].Records); want != have {
+		t.Errorf("records of first attempt want %d, have %d", want, have)
+	}
+	if want, have := 2, len(requests[1].Records); want != have {
+		t.Errorf("records of second attempt want %d, have %d", want, have)
+	}
+	if want, have := 1, len(requests[2].Records); want != have {
+		t.Errorf("records of third attempt want %d, have %d", want, have)
+	}
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	if want, have := []string{"d"}, dropped; len(have) != 1 || want[0] != have[0] {
+		t.Errorf("dropped want %v, have %v", want, have)
+	}
+}
+
+func TestPermanentFailure(t *testing.T) {
+	fake := &fakeKinesis{status: http.StatusBadRequest}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	var (
+		mtx     sync.Mutex
+		dropped int
+	)
+	rep, err := kinesis.NewReporter("zipkin",
+		kinesis.Region("eu-west-1"),
+		kinesis.Endpoint(srv.URL),
+		kinesis.StaticCredentials("AKID", "SECRET", "TOKEN"),
+		kinesis.BatchInterval(time.Hour),
+		kinesis.Retry(3, time.Millisecond),
+		kinesis.OnDrop(func(model.SpanModel, error) {
+			mtx.Lock()
+			dropped++
+			mtx.Unlock()
+		}),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer rep.Close()
+
+	rep.Send(*spans[0])
+	rep.Send(*spans[1])
+	if err := rep.(reporter.Flusher).Flush(); err == nil {
+		t.Fatal("expected error")
+	}
+
+	if want, have := 1, len(fake.Requests()); want != have {
+		t.Errorf("requests want %d, have %d", want, have)
+	}
+	mtx.Lock()
+	defer mtx.Unlock()
+	if want, have := 2, dropped; want != have {
+		t.Errorf("dropped want %d, have %d", want, have)
+	}
+}
+
+func TestMissingConfig(t *testing.T) {
+	for _, key := range []string{"AWS_REGION", "AWS_DEFAULT_REGION", "AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY"} {
+		if value, ok := os.LookupEnv(key); ok {
+			defer os.Setenv(key, value)
+			os.Unsetenv(key)
+		}
+	}
+
+	if _, err := kinesis.NewReporter("zipkin", kinesis.StaticCredentials("a", "b", "")); err != kinesis.ErrMissingRegion {
+		t.Errorf("want %v, have %v", kinesis.ErrMissingRegion, err)
+	}
+	if _, err := kinesis.NewReporter("zipkin", kinesis.Region("eu-west-1")); err != kinesis.ErrMissingCredentials {
+		t.Errorf("want %v, have %v", kinesis.ErrMissingCredentials, err)
+	}
+}
@@ -15,13 +15,20 @@
 /*
 Package log implements a reporter to send spans in V2 JSON format to the Go
 standard Logger.
+
+Using the Writer option the reporter emits newline delimited JSON instead, one
+span per line, so spans can be shipped by existing log pipelines, e.g. using
+Fluent Bit, to Zipkin or other tracing backends.
 */
 package log
 
 import (
+	"bytes"
 	"encoding/json"
+	"io"
 	"log"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/openzipkin/zipkin-go/model"
@@ -30,25 +37,95 @@ import (
 
 // logReporter will send spans to the default Go Logger.
 type logReporter struct {
-	logger *log.Logger
+	logger  *log.Logger
+	mtx     sync.Mutex
+	writer  io.Writer
+	flatten bool
+}
+
+// ReporterOption sets a parameter for the log reporter.
+type ReporterOption func(r *logReporter)
+
+// Writer makes the reporter write spans as newline delimited JSON to w instead
+// of the logger, one compact JSON object per span and line.
+func Writer(w io.Writer) ReporterOption {
+	return func(r *logReporter) {
+		r.writer = w
+	}
+}
+
+// Flatten when enabled flattens the nested objects of spans written by the
+// Writer option into top level fields with dot separated names, e.g.
+// "localEndpoint.serviceName" and "tags.http.method", for log pipelines which
+// do not handle nested fields well. Annotations are kept as array.
+func Flatten(enabled bool) ReporterOption {
+	return func(r *logReporter) {
+		r.flatten = enabled
+	}
 }
 
 // NewReporter returns a new log reporter.
-func NewReporter(l *log.Logger) reporter.Reporter {
+func NewReporter(l *log.Logger, options ...ReporterOption) reporter.Reporter {
 	if l == nil {
 		// use standard type of log setup
 		l = log.New(os.Stderr, "", log.LstdFlags)
 	}
-	return &logReporter{
+	r := &logReporter{
 		logger: l,
 	}
+	for _, option := range options {
+		option(r)
+	}
+	return r
 }
 
-// Send outputs a span to the Go logger.
+// Send outputs a span to the Go logger or the writer.
 func (r *logReporter) Send(s model.SpanModel) {
-	if b, err := json.MarshalIndent(s, "", "  "); err == nil {
-		r.logger.Printf("%s:\n%s\n\n", time.Now(), string(b))
+	if r.writer == nil {
+		if b, err := json.MarshalIndent(s, "", "  "); err == nil {
+			r.logger.Printf("%s:\n%s\n\n", time.Now(), string(b))
+		}
+		return
 	}
+
+	b, err := json.Marshal(s)
+	if err != nil {
+		return
+	}
+	if r.flatten {
+		if b, err = flatten(b); err != nil {
+			return
+		}
+	}
+
+	r.mtx.Lock()
+	_, _ = r.writer.Write(append(b, '\n'))
+	r.mtx.Unlock()
+}
+
+// flatten flattens the nested objects of the JSON object b.
+func flatten(b []byte) ([]byte, error) {
+	var fields map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&fields); err != nil {
+		return nil, err
+	}
+
+	flat := make(map[string]interface{}, len(fields))
+	var walk func(prefix string, fields map[string]interface{})
+	walk = func(prefix string, fields map[string]interface{}) {
+		for k, v := range fields {
+			if nested, ok := v.(map[string]interface{}); ok {
+				walk(prefix+k+".", nested)
+				continue
+			}
+			flat[prefix+k] = v
+		}
+	}
+	walk("", fields)
+
+	return json.Marshal(flat)
 }
 
 // Close closes the reporter
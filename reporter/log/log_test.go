@@ -0,0 +1,87 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/openzipkin/zipkin-go/model"
+	zipkinlog "github.com/openzipkin/zipkin-go/reporter/log"
+)
+
+var span = model.SpanModel{
+	SpanContext: model.SpanContext{
+		TraceID: model.TraceID{Low: 123},
+		ID:      model.ID(456),
+	},
+	Name:          "get",
+	Kind:          model.Server,
+	Timestamp:     time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC),
+	Duration:      time.Millisecond,
+	LocalEndpoint: &model.Endpoint{ServiceName: "frontend"},
+	Tags:          map[string]string{"http.method": "GET"},
+}
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	rep := zipkinlog.NewReporter(nil, zipkinlog.Writer(&buf))
+	rep.Send(span)
+	rep.Send(span)
+	_ = rep.Close()
+
+	scanner := bufio.NewScanner(&buf)
+	lines := 0
+	for scanner.Scan() {
+		lines++
+		var s model.SpanModel
+		if err := json.Unmarshal(scanner.Bytes(), &s); err != nil {
+			t.Fatalf("line %d: unexpected error: %+v", lines, err)
+		}
+		if want, have := span.Name, s.Name; want != have {
+			t.Errorf("line %d: name want %q, have %q", lines, want, have)
+		}
+	}
+	if want, have := 2, lines; want != have {
+		t.Errorf("lines want %d, have %d", want, have)
+	}
+}
+
+func TestFlatten(t *testing.T) {
+	var buf bytes.Buffer
+	rep := zipkinlog.NewReporter(nil, zipkinlog.Writer(&buf), zipkinlog.Flatten(true))
+	rep.Send(span)
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &fields); err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	for key, want := range map[string]interface{}{
+		"traceId":                   "000000000000007b",
+		"localEndpoint.serviceName": "frontend",
+		"tags.http.method":          "GET",
+		"timestamp":                 float64(1546300800000000),
+	} {
+		if have := fields[key]; want != have {
+			t.Errorf("field %s want %v, have %v", key, want, have)
+		}
+	}
+	if _, found := fields["tags"]; found {
+		t.Error("expected nested tags to be flattened")
+	}
+}
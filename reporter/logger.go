@@ -0,0 +1,32 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reporter
+
+// Logger is the interface reporters use to log errors encountered while
+// reporting spans. It is implemented by the standard library *log.Logger. Use
+// LoggerFunc to adapt other logging libraries, e.g. the Errorf method of a zap
+// SugaredLogger, or SlogLogger for log/slog.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// LoggerFunc adapts a printf style logging function to the Logger interface,
+// e.g. reporter.LoggerFunc(sugar.Errorf) for a zap SugaredLogger.
+type LoggerFunc func(format string, v ...interface{})
+
+// Printf calls f(format, v...).
+func (f LoggerFunc) Printf(format string, v ...interface{}) {
+	f(format, v...)
+}
@@ -0,0 +1,39 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.21
+// +build go1.21
+
+package reporter
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+type slogLogger struct {
+	logger *slog.Logger
+	level  slog.Level
+}
+
+// SlogLogger returns a Logger writing messages to l at the provided level.
+func SlogLogger(l *slog.Logger, level slog.Level) Logger {
+	return slogLogger{logger: l, level: level}
+}
+
+func (l slogLogger) Printf(format string, v ...interface{}) {
+	l.logger.Log(context.Background(), l.level, strings.TrimSuffix(fmt.Sprintf(format, v...), "\n"))
+}
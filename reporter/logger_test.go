@@ -0,0 +1,51 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.21
+// +build go1.21
+
+package reporter_test
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/openzipkin/zipkin-go/reporter"
+)
+
+func TestSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := reporter.SlogLogger(slog.New(slog.NewTextHandler(&buf, nil)), slog.LevelError)
+
+	l.Printf("failed to send %d spans: %s\n", 2, "timeout")
+
+	if want, have := `level=ERROR msg="failed to send 2 spans: timeout"`, strings.TrimSpace(buf.String()); !strings.HasSuffix(have, want) {
+		t.Errorf("log line want suffix %q, have %q", want, have)
+	}
+}
+
+func TestLoggerFunc(t *testing.T) {
+	var have string
+	l := reporter.LoggerFunc(func(format string, v ...interface{}) {
+		have = format
+	})
+
+	l.Printf("msg")
+
+	if want := "msg"; want != have {
+		t.Errorf("format want %q, have %q", want, have)
+	}
+}
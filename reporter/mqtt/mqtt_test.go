@@ -0,0 +1,314 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mqtt
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/openzipkin/zipkin-go/model"
+)
+
+var span = model.SpanModel{
+	SpanContext: model.SpanContext{
+		TraceID: model.TraceID{Low: 123},
+		ID:      model.ID(456),
+	},
+	Name:      "read",
+	Timestamp: time.Now(),
+}
+
+type message struct {
+	topic   string
+	qos     byte
+	dup     bool
+	payload []byte
+}
+
+// fakeBroker implements the broker side of the MQTT packets used by the
+// reporter.
+type fakeBroker struct {
+	ln        net.Listener
+	connects  chan []byte
+	messages  chan message
+	dropFirst int32
+}
+
+func newFakeBroker(t *testing.T, config *tls.Config) *fakeBroker {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config != nil {
+		ln = tls.NewListener(ln, config)
+	}
+	b := &fakeBroker{
+		ln:       ln,
+		connects: make(chan []byte, 10),
+		messages: make(chan message, 10),
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go b.handle(conn)
+		}
+	}()
+	return b
+}
+
+func (b *fakeBroker) handle(conn net.Conn) {
+	defer conn.Close()
+	rd := bufio.NewReader(conn)
+
+	header, body, err := readPacket(rd)
+	if err != nil || header != packetConnect {
+		return
+	}
+	b.connects <- body
+	if _, err = conn.Write([]byte{packetConnack, 2, 0, 0}); err != nil {
+		return
+	}
+
+	for {
+		header, body, err = readPacket(rd)
+		if err != nil {
+			return
+		}
+		switch header & 0xf0 {
+		case packetPublish:
+			m := message{qos: header >> 1 & 0x03, dup: header&0x08 != 0}
+			n := int(binary.BigEndian.Uint16(body))
+			m.topic, body = string(body[2:2+n]), body[2+n:]
+			var id []byte
+			if m.qos > 0 {
+				id, body = body[:2], body[2:]
+			}
+			m.payload = body
+			if atomic.CompareAndSwapInt32(&b.dropFirst, 1, 0) {
+				return
+			}
+			b.messages <- m
+			if m.qos > 0 {
+				_, _ = conn.Write(append([]byte{packetPuback, 2}, id...))
+			}
+		case packetPingreq:
+			_, _ = conn.Write([]byte{packetPingresp, 0})
+		case packetDisconnect:
+			return
+		}
+	}
+}
+
+func (b *fakeBroker) receive(t *testing.T) message {
+	select {
+	case m := <-b.messages:
+		return m
+	case <-time.After(time.Second):
+		t.Fatal("expected message to be published")
+	}
+	return message{}
+}
+
+func testPayload(t *testing.T, m message) {
+	var spans []model.SpanModel
+	if err := json.Unmarshal(m.payload, &spans); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want, have := 1, len(spans); want != have {
+		t.Fatalf("span count want %d, have %d", want, have)
+	}
+	if want, have := span.ID, spans[0].ID; want != have {
+		t.Errorf("span id want %s, have %s", want, have)
+	}
+}
+
+var discard = Logger(log.New(ioutil.Discard, "", 0))
+
+func TestPublish(t *testing.T) {
+	b := newFakeBroker(t, nil)
+	defer b.ln.Close()
+
+	sent := make(chan int, 1)
+	r, err := NewReporter(
+		"tcp://user:secret@"+b.ln.Addr().String(),
+		Topic("edge/spans"),
+		ClientID("edge-1"),
+		OnBatchSent(func(count, _ int, _ time.Duration) {
+			sent <- count
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	r.Send(span)
+
+	m := b.receive(t)
+	if want, have := "edge/spans", m.topic; want != have {
+		t.Errorf("topic want %q, have %q", want, have)
+	}
+	if m.qos != 0 || m.dup {
+		t.Errorf("unexpected qos %d or dup flag", m.qos)
+	}
+	testPayload(t, m)
+
+	connect := <-b.connects
+	for _, s := range []string{"MQTT", "edge-1", "user", "secret"} {
+		if !bytes.Contains(connect, []byte(s)) {
+			t.Errorf("expected %q in connect packet", s)
+		}
+	}
+	if want, have := byte(0xc2), connect[7]; want != have {
+		t.Errorf("connect flags want %x, have %x", want, have)
+	}
+
+	select {
+	case count := <-sent:
+		if want, have := 1, count; want != have {
+			t.Errorf("sent count want %d, have %d", want, have)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected OnBatchSent to be called")
+	}
+}
+
+func TestQoS1Reconnect(t *testing.T) {
+	b := newFakeBroker(t, nil)
+	defer b.ln.Close()
+	b.dropFirst = 1
+
+	r, err := NewReporter(
+		"mqtt://"+b.ln.Addr().String(),
+		QoS(1),
+		ReconnectInterval(10*time.Millisecond),
+		OnDrop(func(model.SpanModel, error) {
+			t.Error("unexpected dropped span")
+		}),
+		discard,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r.Send(span)
+
+	m := b.receive(t)
+	if want, have := byte(1), m.qos; want != have {
+		t.Errorf("qos want %d, have %d", want, have)
+	}
+	if !m.dup {
+		t.Error("expected republished message to have the dup flag set")
+	}
+	testPayload(t, m)
+
+	if want, have := 2, len(b.connects); want != have {
+		t.Errorf("connect count want %d, have %d", want, have)
+	}
+	if err = r.Close(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestTLS(t *testing.T) {
+	ts := httptest.NewTLSServer(http.NotFoundHandler())
+	certs := ts.TLS.Certificates
+	roots := ts.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs
+	ts.Close()
+
+	b := newFakeBroker(t, &tls.Config{Certificates: certs})
+	defer b.ln.Close()
+
+	r, err := NewReporter(
+		"ssl://"+b.ln.Addr().String(),
+		TLSConfig(&tls.Config{RootCAs: roots}),
+		discard,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	r.Send(span)
+	testPayload(t, b.receive(t))
+}
+
+func TestCloseUnreachable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	dropped := make(chan error, 2)
+	r, err := NewReporter(
+		"tcp://"+addr,
+		ReconnectInterval(time.Hour),
+		OnDrop(func(_ model.SpanModel, reason error) {
+			dropped <- reason
+		}),
+		discard,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r.Send(span)
+
+	done := make(chan struct{})
+	go func() {
+		_ = r.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected Close to return")
+	}
+	select {
+	case <-dropped:
+	default:
+		t.Fatal("expected span to be dropped")
+	}
+
+	r.Send(span)
+	if want, have := ErrClosed, <-dropped; want != have {
+		t.Errorf("drop reason want %v, have %v", want, have)
+	}
+}
+
+func TestNewReporterErrors(t *testing.T) {
+	if _, err := NewReporter("http://127.0.0.1:1883"); err != ErrUnsupportedScheme {
+		t.Errorf("want %v, have %v", ErrUnsupportedScheme, err)
+	}
+	if _, err := NewReporter("tcp://127.0.0.1:1883", QoS(2)); err != ErrInvalidQoS {
+		t.Errorf("want %v, have %v", ErrInvalidQoS, err)
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// MQTT 3.1.1 control packet types as used in the fixed header.
+const (
+	packetConnect    byte = 0x10
+	packetConnack    byte = 0x20
+	packetPublish    byte = 0x30
+	packetPuback     byte = 0x40
+	packetPingreq    byte = 0xc0
+	packetPingresp   byte = 0xd0
+	packetDisconnect byte = 0xe0
+)
+
+// maxRemainingLength is the largest remaining length MQTT can encode.
+const maxRemainingLength = 268435455
+
+var errMalformedPacket = errors.New("mqtt: malformed packet")
+
+// connectPacket encodes a CONNECT packet starting a clean session.
+func connectPacket(clientID, username, password string, keepAlive uint16) []byte {
+	flags := byte(0x02) // clean session
+	body := appendString(nil, "MQTT")
+	body = append(body, 4) // protocol level 3.1.1
+	if username != "" {
+		flags |= 0x80
+		if password != "" {
+			flags |= 0x40
+		}
+	}
+	body = append(body, flags, byte(keepAlive>>8), byte(keepAlive))
+	body = appendString(body, clientID)
+	if username != "" {
+		body = appendString(body, username)
+		if password != "" {
+			body = appendString(body, password)
+		}
+	}
+	return packet(packetConnect, body)
+}
+
+// publishPacket encodes a PUBLISH packet. The packet id is only included for
+// QoS levels above 0.
+func publishPacket(topic string, qos byte, dup bool, id uint16, payload []byte) []byte {
+	header := packetPublish | qos<<1
+	if dup {
+		header |= 0x08
+	}
+	body := appendString(make([]byte, 0, len(topic)+4+len(payload)), topic)
+	if qos > 0 {
+		body = append(body, byte(id>>8), byte(id))
+	}
+	return packet(header, append(body, payload...))
+}
+
+func packet(header byte, body []byte) []byte {
+	p := make([]byte, 0, len(body)+5)
+	p = append(p, header)
+	for n := len(body); ; {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		p = append(p, b)
+		if n == 0 {
+			break
+		}
+	}
+	return append(p, body...)
+}
+
+func appendString(b []byte, s string) []byte {
+	b = append(b, byte(len(s)>>8), byte(len(s)))
+	return append(b, s...)
+}
+
+// readPacket reads a single control packet returning its fixed header byte
+// and the remaining bytes.
+func readPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	var length, multiplier int = 0, 1
+	for i := 0; ; i++ {
+		if i == 4 {
+			return 0, nil, errMalformedPacket
+		}
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		length += int(b&0x7f) * multiplier
+		multiplier *= 128
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	body := make([]byte, length)
+	if _, err = io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return header, body, nil
+}
+
+// packetID returns the packet identifier of a PUBACK packet body.
+func packetID(body []byte) (uint16, error) {
+	if len(body) != 2 {
+		return 0, errMalformedPacket
+	}
+	return binary.BigEndian.Uint16(body), nil
+}
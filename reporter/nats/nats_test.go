@@ -0,0 +1,274 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nats_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter/nats"
+)
+
+func newSpan(name string) model.SpanModel {
+	return model.SpanModel{
+		SpanContext: model.SpanContext{
+			TraceID: model.TraceID{Low: 123},
+			ID:      model.ID(456),
+		},
+		Name:      name,
+		Timestamp: time.Now(),
+	}
+}
+
+type message struct {
+	subject string
+	reply   string
+	spans   []model.SpanModel
+}
+
+// fakeServer implements the server side of the NATS protocol messages used
+// by the reporter.
+type fakeServer struct {
+	ln       net.Listener
+	connects chan map[string]interface{}
+	messages chan message
+	pongs    chan struct{}
+
+	// ackMode is "" for core NATS, "ack" and "noresponders" for JetStream
+	ackMode   string
+	dropFirst int32
+}
+
+func newFakeServer(t *testing.T, ackMode string) *fakeServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeServer{
+		ln:       ln,
+		connects: make(chan map[string]interface{}, 10),
+		messages: make(chan message, 10),
+		pongs:    make(chan struct{}, 10),
+		ackMode:  ackMode,
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.handle(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeServer) url() string {
+	return "nats://user:secret@" + s.ln.Addr().String()
+}
+
+func (s *fakeServer) handle(conn net.Conn) {
+	defer conn.Close()
+
+	var wmtx sync.Mutex
+	write := func(p string) {
+		wmtx.Lock()
+		_, _ = io.WriteString(conn, p)
+		wmtx.Unlock()
+	}
+	write(`INFO {"server_id":"fake","max_payload":1048576,"headers":true}` + "\r\n")
+
+	r := bufio.NewReader(conn)
+	sid := ""
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "CONNECT":
+			var connect map[string]interface{}
+			_ = json.Unmarshal([]byte(strings.TrimPrefix(strings.TrimSpace(line), "CONNECT ")), &connect)
+			s.connects <- connect
+		case "PING":
+			write("PONG\r\n")
+			// ping the client once connected
+			write("PING\r\n")
+		case "PONG":
+			s.pongs <- struct{}{}
+		case "SUB":
+			sid = fields[2]
+		case "PUB":
+			size, _ := strconv.Atoi(fields[len(fields)-1])
+			payload := make([]byte, size+2)
+			if _, err = io.ReadFull(r, payload); err != nil {
+				return
+			}
+			if atomic.CompareAndSwapInt32(&s.dropFirst, 1, 0) {
+				return
+			}
+			msg := message{subject: fields[1]}
+			if len(fields) == 4 {
+				msg.reply = fields[2]
+			}
+			_ = json.Unmarshal(payload[:size], &msg.spans)
+			s.messages <- msg
+
+			switch {
+			case msg.reply == "" || s.ackMode == "":
+			case s.ackMode == "noresponders":
+				write(fmt.Sprintf("HMSG %s %s 16 16\r\nNATS/1.0 503\r\n\r\n\r\n", msg.reply, sid))
+			default:
+				ack := `{"stream":"ZIPKIN","seq":1}`
+				write(fmt.Sprintf("MSG %s %s %d\r\n%s\r\n", msg.reply, sid, len(ack), ack))
+			}
+		}
+	}
+}
+
+func (s *fakeServer) message(t *testing.T) message {
+	t.Helper()
+	select {
+	case msg := <-s.messages:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for message")
+		return message{}
+	}
+}
+
+func TestPublishBatches(t *testing.T) {
+	s := newFakeServer(t, "")
+	defer s.ln.Close()
+
+	rep, err := nats.NewReporter(s.url(), nats.Subject("traces"), nats.BatchSize(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rep.Send(newSpan("a"))
+	rep.Send(newSpan("b"))
+	rep.Send(newSpan("c"))
+
+	msg := s.message(t)
+	if want, have := "traces", msg.subject; want != have {
+		t.Errorf("subject want %q, have %q", want, have)
+	}
+	if want, have := 2, len(msg.spans); want != have {
+		t.Errorf("batch size want %d, have %d", want, have)
+	}
+
+	if err := rep.Close(); err != nil {
+		t.Fatal(err)
+	}
+	msg = s.message(t)
+	if want, have := "c", msg.spans[0].Name; want != have {
+		t.Errorf("span name want %q, have %q", want, have)
+	}
+
+	connect := <-s.connects
+	if want, have := "user", connect["user"]; want != have {
+		t.Errorf("user want %v, have %v", want, have)
+	}
+	if want, have := "secret", connect["pass"]; want != have {
+		t.Errorf("password want %v, have %v", want, have)
+	}
+	select {
+	case <-s.pongs:
+	case <-time.After(time.Second):
+		t.Error("expected reporter to answer the server ping")
+	}
+}
+
+func TestJetStream(t *testing.T) {
+	s := newFakeServer(t, "ack")
+	defer s.ln.Close()
+	atomic.StoreInt32(&s.dropFirst, 1)
+
+	var dropped int32
+	rep, err := nats.NewReporter(s.url(),
+		nats.JetStream(true),
+		nats.BatchSize(1),
+		nats.ReconnectInterval(10*time.Millisecond),
+		nats.OnDrop(func(model.SpanModel, error) { atomic.AddInt32(&dropped, 1) }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rep.Send(newSpan("a"))
+
+	// the first attempt is lost with the connection and published again
+	msg := s.message(t)
+	if msg.reply == "" {
+		t.Error("expected reply subject for the acknowledgement")
+	}
+	if want, have := "a", msg.spans[0].Name; want != have {
+		t.Errorf("span name want %q, have %q", want, have)
+	}
+	if err := rep.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 2, len(s.connects); want != have {
+		t.Errorf("connects want %d, have %d", want, have)
+	}
+	if want, have := int32(0), atomic.LoadInt32(&dropped); want != have {
+		t.Errorf("dropped spans want %d, have %d", want, have)
+	}
+}
+
+func TestJetStreamNoResponders(t *testing.T) {
+	s := newFakeServer(t, "noresponders")
+	defer s.ln.Close()
+
+	reasons := make(chan error, 1)
+	rep, err := nats.NewReporter(s.url(),
+		nats.JetStream(true),
+		nats.BatchSize(1),
+		nats.OnDrop(func(_ model.SpanModel, reason error) { reasons <- reason }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rep.Close()
+	rep.Send(newSpan("a"))
+
+	select {
+	case reason := <-reasons:
+		if want, have := nats.ErrNoResponders, reason; want != have {
+			t.Errorf("drop reason want %v, have %v", want, have)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected span to be dropped")
+	}
+}
+
+func TestUnsupportedScheme(t *testing.T) {
+	if _, err := nats.NewReporter("http://localhost:4222"); err != nats.ErrUnsupportedScheme {
+		t.Errorf("error want %v, have %v", nats.ErrUnsupportedScheme, err)
+	}
+}
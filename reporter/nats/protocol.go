@@ -0,0 +1,246 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nats
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var errProtocol = errors.New("nats: protocol error")
+
+// serverInfo holds the fields of the INFO message used by the reporter.
+type serverInfo struct {
+	TLSRequired bool  `json:"tls_required"`
+	MaxPayload  int64 `json:"max_payload"`
+	Headers     bool  `json:"headers"`
+}
+
+// connectInfo is the CONNECT message sent to the server.
+type connectInfo struct {
+	Verbose      bool   `json:"verbose"`
+	Pedantic     bool   `json:"pedantic"`
+	User         string `json:"user,omitempty"`
+	Pass         string `json:"pass,omitempty"`
+	AuthToken    string `json:"auth_token,omitempty"`
+	Name         string `json:"name,omitempty"`
+	Lang         string `json:"lang"`
+	Version      string `json:"version"`
+	Protocol     int    `json:"protocol"`
+	Headers      bool   `json:"headers"`
+	NoResponders bool   `json:"no_responders"`
+}
+
+// message is a message received on a subscription.
+type message struct {
+	subject string
+	status  string
+	data    []byte
+}
+
+// conn is a connection to a NATS server. A reader goroutine answers pings of
+// the server and delivers the messages of the subscriptions.
+type conn struct {
+	net.Conn
+	info    serverInfo
+	timeout time.Duration
+
+	wmtx sync.Mutex
+	msgs chan message
+	done chan struct{}
+	err  error
+}
+
+// readLine reads a protocol line without the trailing CRLF.
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// handshake reads the INFO message of the server, upgrades the connection
+// using upgrade if needed, authenticates and waits for the server to process
+// the CONNECT message.
+func handshake(nc net.Conn, timeout time.Duration, ci connectInfo, upgrade func(net.Conn, bool) (net.Conn, error)) (*conn, error) {
+	_ = nc.SetDeadline(time.Now().Add(timeout))
+	r := bufio.NewReader(nc)
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "INFO ") {
+		return nil, errProtocol
+	}
+	var info serverInfo
+	if err = json.Unmarshal([]byte(line[len("INFO "):]), &info); err != nil {
+		return nil, err
+	}
+	if nc, err = upgrade(nc, info.TLSRequired); err != nil {
+		return nil, err
+	}
+	_ = nc.SetDeadline(time.Now().Add(timeout))
+	r = bufio.NewReader(nc)
+
+	ci.Headers, ci.NoResponders = info.Headers, info.Headers
+	b, err := json.Marshal(ci)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = nc.Write([]byte("CONNECT " + string(b) + "\r\nPING\r\n")); err != nil {
+		return nil, err
+	}
+	for {
+		if line, err = readLine(r); err != nil {
+			return nil, err
+		}
+		if line == "PONG" {
+			break
+		}
+		if strings.HasPrefix(line, "-ERR") {
+			return nil, fmt.Errorf("nats: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+		// e.g. +OK or an updated INFO
+	}
+	_ = nc.SetDeadline(time.Time{})
+
+	c := &conn{
+		Conn:    nc,
+		info:    info,
+		timeout: timeout,
+		msgs:    make(chan message, 16),
+		done:    make(chan struct{}),
+	}
+	go c.read(r)
+	return c, nil
+}
+
+// write writes the protocol messages in p.
+func (c *conn) write(p []byte) error {
+	c.wmtx.Lock()
+	defer c.wmtx.Unlock()
+	_ = c.SetWriteDeadline(time.Now().Add(c.timeout))
+	_, err := c.Write(p)
+	return err
+}
+
+// publish publishes data to subject, with reply as reply subject if set.
+func (c *conn) publish(subject, reply string, data []byte) error {
+	var b bytes.Buffer
+	b.Grow(len(subject) + len(reply) + len(data) + 32)
+	b.WriteString("PUB ")
+	b.WriteString(subject)
+	if reply != "" {
+		b.WriteString(" ")
+		b.WriteString(reply)
+	}
+	b.WriteString(" ")
+	b.WriteString(strconv.Itoa(len(data)))
+	b.WriteString("\r\n")
+	b.Write(data)
+	b.WriteString("\r\n")
+	return c.write(b.Bytes())
+}
+
+// read processes the messages sent by the server until the connection fails.
+func (c *conn) read(r *bufio.Reader) {
+	defer close(c.done)
+	for {
+		line, err := readLine(r)
+		if err != nil {
+			c.err = err
+			return
+		}
+		switch {
+		case line == "PING":
+			if err = c.write([]byte("PONG\r\n")); err != nil {
+				c.err = err
+				return
+			}
+		case strings.HasPrefix(line, "MSG "), strings.HasPrefix(line, "HMSG "):
+			msg, err := readMessage(r, line)
+			if err != nil {
+				c.err = err
+				return
+			}
+			select {
+			case c.msgs <- msg:
+			default:
+				// nobody waits for the message, e.g. a late acknowledgement
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			c.err = fmt.Errorf("nats: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+			return
+		}
+	}
+}
+
+// readMessage reads the payload of the MSG or HMSG message starting with line.
+func readMessage(r *bufio.Reader, line string) (message, error) {
+	fields := strings.Fields(line)
+	headers := fields[0] == "HMSG"
+	// MSG <subject> <sid> [reply] <size>
+	// HMSG <subject> <sid> [reply] <header size> <total size>
+	minFields := 4
+	if headers {
+		minFields = 5
+	}
+	if len(fields) < minFields || len(fields) > minFields+1 {
+		return message{}, errProtocol
+	}
+	size, err := strconv.Atoi(fields[len(fields)-1])
+	if err != nil || size < 0 {
+		return message{}, errProtocol
+	}
+	headerSize := 0
+	if headers {
+		if headerSize, err = strconv.Atoi(fields[len(fields)-2]); err != nil || headerSize > size {
+			return message{}, errProtocol
+		}
+	}
+	data := make([]byte, size+2)
+	if _, err = io.ReadFull(r, data); err != nil {
+		return message{}, err
+	}
+
+	msg := message{subject: fields[1], data: data[headerSize:size]}
+	if headers {
+		// the first header line holds the status, e.g. NATS/1.0 503
+		status := string(data[:headerSize])
+		if i := strings.Index(status, "\r\n"); i >= 0 {
+			status = status[:i]
+		}
+		if parts := strings.Fields(status); len(parts) > 1 {
+			msg.status = parts[1]
+		}
+	}
+	return msg, nil
+}
+
+// close closes the connection and waits for the reader to exit.
+func (c *conn) close() {
+	_ = c.Close()
+	<-c.done
+}
@@ -0,0 +1,34 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package ostrace implements an experimental reporter emitting spans as events to
+the tracing facilities of the operating system, allowing system profilers to
+correlate kernel traces with application spans.
+
+On Linux spans are written as user_events, which requires a kernel with
+user_events support (6.4 or newer) and write access to the user_events_data
+file of the tracefs mount. Once registered, the event is found as
+user_events/<name> and can be recorded with perf or ftrace:
+
+	perf record -e user_events:zipkin_span
+
+On Windows spans are written as ETW string events. The provider GUID is derived
+from the name as done by EventSource and TraceLogging providers, so sessions can
+enable the provider by name, e.g. using PerfView with /Providers=*zipkin_span.
+
+Other platforms are not supported. Events are only emitted while a listener
+has enabled them.
+*/
+package ostrace
@@ -0,0 +1,44 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ostrace
+
+import (
+	"crypto/sha1"
+	"strings"
+	"unicode/utf16"
+)
+
+// providerNamespace is the namespace used by EventSource and TraceLogging to
+// derive provider GUIDs from provider names.
+var providerNamespace = []byte{
+	0x48, 0x2C, 0x2D, 0xB2, 0xC3, 0x90, 0x47, 0xC8,
+	0x87, 0xF8, 0x1A, 0x15, 0xBF, 0xC1, 0x30, 0xFB,
+}
+
+// providerGUID returns the ETW provider GUID for name, in the memory layout of
+// a Windows GUID structure on little endian platforms. The GUID is a name
+// based UUID of the upper cased name encoded as big endian UTF-16.
+func providerGUID(name string) [16]byte {
+	h := sha1.New()
+	_, _ = h.Write(providerNamespace)
+	for _, c := range utf16.Encode([]rune(strings.ToUpper(name))) {
+		_, _ = h.Write([]byte{byte(c >> 8), byte(c)})
+	}
+
+	var guid [16]byte
+	copy(guid[:], h.Sum(nil))
+	guid[7] = (guid[7] & 0x0F) | 0x50
+	return guid
+}
@@ -0,0 +1,134 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ostrace
+
+import (
+	"errors"
+	"log"
+	"os"
+	"regexp"
+	"sync"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter"
+)
+
+// DefaultName holds the default name of the event or provider.
+const DefaultName = "zipkin_span"
+
+// Errors returned by NewReporter.
+var (
+	// ErrUnsupported is returned on platforms without a supported tracing
+	// facility or if the facility is not available on the host.
+	ErrUnsupported = errors.New("ostrace: tracing facility not supported")
+	// ErrInvalidName is returned if the name is not a valid identifier.
+	ErrInvalidName = errors.New("ostrace: invalid name")
+)
+
+var validName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// emitter writes spans to the tracing facility of the operating system.
+type emitter interface {
+	// enabled reports whether a listener enabled the event.
+	enabled() bool
+	emit(s *model.SpanModel) error
+	close() error
+}
+
+// osReporter implements Reporter by emitting spans as operating system
+// tracing events.
+type osReporter struct {
+	name      string
+	logger    *log.Logger
+	onDrop    func(model.SpanModel, error)
+	emitter   emitter
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// ReporterOption sets a parameter for the osReporter
+type ReporterOption func(r *osReporter)
+
+// Name sets the name of the user_events event on Linux and the name the ETW
+// provider GUID is derived from on Windows. It needs to be a valid identifier.
+// Default is DefaultName.
+func Name(name string) ReporterOption {
+	return func(r *osReporter) {
+		r.name = name
+	}
+}
+
+// Logger sets the logger used to report errors in the collection
+// process.
+func Logger(logger *log.Logger) ReporterOption {
+	return func(r *osReporter) {
+		r.logger = logger
+	}
+}
+
+// OnDrop registers a callback function which is invoked for every span the
+// reporter fails to emit, together with the reason. Spans not emitted because
+// no listener enabled the event are not considered dropped.
+func OnDrop(fn func(span model.SpanModel, reason error)) ReporterOption {
+	return func(r *osReporter) {
+		r.onDrop = fn
+	}
+}
+
+// NewReporter registers the event with the tracing facility of the operating
+// system and returns a Reporter emitting spans as events. ErrUnsupported is
+// returned if the facility is not available.
+func NewReporter(options ...ReporterOption) (reporter.Reporter, error) {
+	r := &osReporter{
+		name:   DefaultName,
+		logger: log.New(os.Stderr, "", log.LstdFlags),
+	}
+	for _, option := range options {
+		option(r)
+	}
+
+	if !validName.MatchString(r.name) {
+		return nil, ErrInvalidName
+	}
+
+	e, err := newEmitter(r.name)
+	if err != nil {
+		return nil, err
+	}
+	r.emitter = e
+
+	return r, nil
+}
+
+// Send emits the span if a listener enabled the event.
+func (r *osReporter) Send(s model.SpanModel) {
+	if !r.emitter.enabled() {
+		return
+	}
+	if err := r.emitter.emit(&s); err != nil {
+		r.logger.Printf("failed to emit the span: %s\n", err.Error())
+		if r.onDrop != nil {
+			r.onDrop(s, err)
+		}
+	}
+}
+
+// Close unregisters the event.
+func (r *osReporter) Close() error {
+	r.closeOnce.Do(func() {
+		r.closeErr = r.emitter.close()
+	})
+	return r.closeErr
+}
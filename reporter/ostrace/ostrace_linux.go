@@ -0,0 +1,145 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ostrace
+
+import (
+	"encoding/binary"
+	"os"
+	"runtime"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+
+	"github.com/openzipkin/zipkin-go/model"
+)
+
+// user_events ABI, see Documentation/trace/user_events.rst of the kernel.
+const (
+	userRegSize = 28
+	// argument format of the registered event, the span name is a dynamic
+	// string located after the fixed size fields
+	userEventFields = " u64 trace_id_high; u64 trace_id; u64 span_id; u64 parent_id; u64 start_ns; u64 duration_ns; __rel_loc char[] name"
+)
+
+// diagIOCSReg is the DIAG_IOCSREG ioctl request number, _IOWR('*', 0,
+// struct user_reg *).
+var diagIOCSReg = 3<<30 | uintptr(unsafe.Sizeof(uintptr(0)))<<16 | '*'<<8
+
+var userEventsData = []string{
+	"/sys/kernel/tracing/user_events_data",
+	"/sys/kernel/debug/tracing/user_events_data",
+}
+
+// nativeEndian holds the byte order the kernel expects event payloads in.
+var nativeEndian binary.ByteOrder = binary.LittleEndian
+
+func init() {
+	x := uint16(1)
+	if *(*byte)(unsafe.Pointer(&x)) == 0 {
+		nativeEndian = binary.BigEndian
+	}
+}
+
+// userEvents emits spans as Linux user_events.
+type userEvents struct {
+	file       *os.File
+	writeIndex uint32
+	// state is updated by the kernel once a listener enables the event
+	state *uint32
+}
+
+func newEmitter(name string) (emitter, error) {
+	var (
+		file *os.File
+		err  error
+	)
+	for _, path := range userEventsData {
+		if file, err = os.OpenFile(path, os.O_RDWR, 0); err == nil {
+			break
+		}
+	}
+	if os.IsPermission(err) {
+		return nil, err
+	}
+	if file == nil {
+		return nil, ErrUnsupported
+	}
+
+	e := &userEvents{file: file, state: new(uint32)}
+	args := append([]byte(name+userEventFields), 0)
+
+	var reg [userRegSize]byte
+	nativeEndian.PutUint32(reg[0:], userRegSize)
+	reg[4] = 0 // enable bit
+	reg[5] = 4 // size of the enable state
+	nativeEndian.PutUint64(reg[8:], uint64(uintptr(unsafe.Pointer(e.state))))
+	nativeEndian.PutUint64(reg[16:], uint64(uintptr(unsafe.Pointer(&args[0]))))
+
+	_, _, errno := syscall.Syscall(
+		syscall.SYS_IOCTL, file.Fd(), diagIOCSReg, uintptr(unsafe.Pointer(&reg[0])),
+	)
+	runtime.KeepAlive(args)
+	if errno != 0 {
+		_ = file.Close()
+		if errno == syscall.ENOTTY || errno == syscall.EINVAL {
+			return nil, ErrUnsupported
+		}
+		return nil, errno
+	}
+	e.writeIndex = nativeEndian.Uint32(reg[24:])
+
+	return e, nil
+}
+
+func (e *userEvents) enabled() bool {
+	return atomic.LoadUint32(e.state) != 0
+}
+
+func (e *userEvents) emit(s *model.SpanModel) error {
+	_, err := e.file.Write(encodeUserEvent(e.writeIndex, s))
+	return err
+}
+
+func (e *userEvents) close() error {
+	// closing the file unregisters the enable state of the event
+	err := e.file.Close()
+	runtime.KeepAlive(e.state)
+	return err
+}
+
+// encodeUserEvent returns the write index of the event followed by its
+// payload, matching the fields of userEventFields.
+func encodeUserEvent(writeIndex uint32, s *model.SpanModel) []byte {
+	const fixed = 4 + 6*8 + 4
+	buf := make([]byte, fixed, fixed+len(s.Name)+1)
+
+	nativeEndian.PutUint32(buf[0:], writeIndex)
+	nativeEndian.PutUint64(buf[4:], s.TraceID.High)
+	nativeEndian.PutUint64(buf[12:], s.TraceID.Low)
+	nativeEndian.PutUint64(buf[20:], uint64(s.ID))
+	if s.ParentID != nil {
+		nativeEndian.PutUint64(buf[28:], uint64(*s.ParentID))
+	}
+	if !s.Timestamp.IsZero() {
+		nativeEndian.PutUint64(buf[36:], uint64(s.Timestamp.UnixNano()))
+	}
+	nativeEndian.PutUint64(buf[44:], uint64(s.Duration))
+	// the __rel_loc field holds the size of the string in the upper and its
+	// offset from the end of the field in the lower 16 bits
+	nativeEndian.PutUint32(buf[52:], uint32(len(s.Name)+1)<<16)
+
+	buf = append(buf, s.Name...)
+	return append(buf, 0)
+}
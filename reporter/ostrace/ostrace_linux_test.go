@@ -0,0 +1,70 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ostrace
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/openzipkin/zipkin-go/model"
+)
+
+func TestEncodeUserEvent(t *testing.T) {
+	parentID := model.ID(3)
+	s := &model.SpanModel{
+		SpanContext: model.SpanContext{
+			TraceID:  model.TraceID{High: 1, Low: 2},
+			ID:       4,
+			ParentID: &parentID,
+		},
+		Name:      "get",
+		Timestamp: time.Unix(0, 5),
+		Duration:  6,
+	}
+
+	b := encodeUserEvent(7, s)
+	if want, have := 4+6*8+4+4, len(b); want != have {
+		t.Fatalf("payload size want %d, have %d", want, have)
+	}
+	if want, have := uint32(7), nativeEndian.Uint32(b); want != have {
+		t.Errorf("write index want %d, have %d", want, have)
+	}
+	for i, want := range []uint64{1, 2, 4, 3, 5, 6} {
+		if have := nativeEndian.Uint64(b[4+i*8:]); want != have {
+			t.Errorf("field %d want %d, have %d", i, want, have)
+		}
+	}
+	if want, have := uint32(4<<16), nativeEndian.Uint32(b[52:]); want != have {
+		t.Errorf("rel_loc want %#x, have %#x", want, have)
+	}
+	if want, have := "get\x00", string(b[56:]); want != have {
+		t.Errorf("name want %q, have %q", want, have)
+	}
+}
+
+func TestNewReporter(t *testing.T) {
+	r, err := NewReporter()
+	if err == ErrUnsupported || os.IsPermission(err) {
+		t.Skip("user_events not available")
+	}
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	r.Send(model.SpanModel{Name: "test"})
+	if err = r.Close(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
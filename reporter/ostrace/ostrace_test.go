@@ -0,0 +1,101 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ostrace
+
+import (
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"log"
+	"testing"
+
+	"github.com/openzipkin/zipkin-go/model"
+)
+
+func TestProviderGUID(t *testing.T) {
+	// provider GUID from the TraceLogging documentation
+	guid := providerGUID("SimpleTraceLoggingProvider")
+	if want, have := "16c60502-97cf-115c-9756-56a2cee02ca7", format(guid); want != have {
+		t.Errorf("guid memory layout want %s, have %s", want, have)
+	}
+}
+
+func format(guid [16]byte) string {
+	s := hex.EncodeToString(guid[:])
+	return s[0:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:]
+}
+
+func TestInvalidName(t *testing.T) {
+	for _, name := range []string{"", "1span", "zipkin span", "zipkin-span"} {
+		if _, err := NewReporter(Name(name)); err != ErrInvalidName {
+			t.Errorf("name %q want %v, have %v", name, ErrInvalidName, err)
+		}
+	}
+}
+
+type stubEmitter struct {
+	on      bool
+	err     error
+	emitted []string
+	closed  int
+}
+
+func (e *stubEmitter) enabled() bool { return e.on }
+
+func (e *stubEmitter) emit(s *model.SpanModel) error {
+	e.emitted = append(e.emitted, s.Name)
+	return e.err
+}
+
+func (e *stubEmitter) close() error {
+	e.closed++
+	return nil
+}
+
+func TestSend(t *testing.T) {
+	var dropped []string
+	e := &stubEmitter{}
+	r := &osReporter{
+		emitter: e,
+		logger:  log.New(ioutil.Discard, "", 0),
+		onDrop:  func(s model.SpanModel, _ error) { dropped = append(dropped, s.Name) },
+	}
+
+	r.Send(model.SpanModel{Name: "disabled"})
+	if want, have := 0, len(e.emitted); want != have {
+		t.Errorf("emitted spans want %d, have %d", want, have)
+	}
+
+	e.on = true
+	r.Send(model.SpanModel{Name: "enabled"})
+	if want, have := 1, len(e.emitted); want != have {
+		t.Errorf("emitted spans want %d, have %d", want, have)
+	}
+	if want, have := 0, len(dropped); want != have {
+		t.Errorf("dropped spans want %d, have %d", want, have)
+	}
+
+	e.err = errors.New("write failed")
+	r.Send(model.SpanModel{Name: "failed"})
+	if want, have := 1, len(dropped); want != have {
+		t.Errorf("dropped spans want %d, have %d", want, have)
+	}
+
+	_ = r.Close()
+	_ = r.Close()
+	if want, have := 1, e.closed; want != have {
+		t.Errorf("close calls want %d, have %d", want, have)
+	}
+}
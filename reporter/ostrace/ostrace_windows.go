@@ -0,0 +1,98 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows && (amd64 || arm64)
+// +build windows
+// +build amd64 arm64
+
+package ostrace
+
+import (
+	"fmt"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/openzipkin/zipkin-go/model"
+)
+
+const levelInformational = 4
+
+var (
+	advapi32                 = syscall.NewLazyDLL("advapi32.dll")
+	procEventRegister        = advapi32.NewProc("EventRegister")
+	procEventUnregister      = advapi32.NewProc("EventUnregister")
+	procEventProviderEnabled = advapi32.NewProc("EventProviderEnabled")
+	procEventWriteString     = advapi32.NewProc("EventWriteString")
+)
+
+// etwProvider emits spans as ETW string events.
+type etwProvider struct {
+	handle uint64
+}
+
+func newEmitter(name string) (emitter, error) {
+	if err := advapi32.Load(); err != nil {
+		return nil, ErrUnsupported
+	}
+
+	guid := providerGUID(name)
+	p := &etwProvider{}
+	r, _, _ := procEventRegister.Call(
+		uintptr(unsafe.Pointer(&guid[0])), 0, 0, uintptr(unsafe.Pointer(&p.handle)),
+	)
+	if r != 0 {
+		return nil, syscall.Errno(r)
+	}
+	return p, nil
+}
+
+func (p *etwProvider) enabled() bool {
+	r, _, _ := procEventProviderEnabled.Call(uintptr(p.handle), levelInformational, 0)
+	return r != 0
+}
+
+func (p *etwProvider) emit(s *model.SpanModel) error {
+	msg, err := syscall.UTF16PtrFromString(formatEvent(s))
+	if err != nil {
+		return err
+	}
+	r, _, _ := procEventWriteString.Call(
+		uintptr(p.handle), levelInformational, 0, uintptr(unsafe.Pointer(msg)),
+	)
+	if r != 0 {
+		return syscall.Errno(r)
+	}
+	return nil
+}
+
+func (p *etwProvider) close() error {
+	if r, _, _ := procEventUnregister.Call(uintptr(p.handle)); r != 0 {
+		return syscall.Errno(r)
+	}
+	return nil
+}
+
+// formatEvent returns the string event of the span.
+func formatEvent(s *model.SpanModel) string {
+	parentID := ""
+	if s.ParentID != nil {
+		parentID = s.ParentID.String()
+	}
+	return fmt.Sprintf(
+		"traceId=%s spanId=%s parentId=%s kind=%s start=%s duration=%d name=%q",
+		s.TraceID, s.ID, parentID, s.Kind,
+		s.Timestamp.UTC().Format(time.RFC3339Nano), s.Duration.Nanoseconds(), s.Name,
+	)
+}
@@ -0,0 +1,57 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package otlp implements reporters exporting spans to OpenTelemetry Collectors
+and other backends accepting the OpenTelemetry Protocol (OTLP), so services
+instrumented with zipkin-go can feed them without a translation sidecar.
+
+NewHTTPReporter exports batches using OTLP/HTTP and NewGRPCReporter using
+OTLP/gRPC. Batches are built by the HTTP and gRPC reporters, which accept the
+same options as when reporting to Zipkin:
+
+	rep := otlp.NewHTTPReporter("http://localhost:4318/v1/traces",
+		zipkinhttp.BatchSize(500),
+	)
+
+Spans are converted to OTLP spans grouped by resource, with the service name
+of the local endpoint as service.name resource attribute. Tags become string
+attributes, annotations become events and the error tag sets the status of the
+span. The OTLP messages are encoded by this package, without depending on the
+OpenTelemetry protocol buffer definitions.
+*/
+package otlp
+
+import (
+	"github.com/openzipkin/zipkin-go/reporter"
+	zipkingrpc "github.com/openzipkin/zipkin-go/reporter/grpc"
+	zipkinhttp "github.com/openzipkin/zipkin-go/reporter/http"
+)
+
+// exportMethod is the full name of the OTLP/gRPC method receiving spans.
+const exportMethod = "/opentelemetry.proto.collector.trace.v1.TraceService/Export"
+
+// NewHTTPReporter returns a new Reporter exporting spans using OTLP/HTTP with
+// binary protocol buffer encoding. url should be the traces endpoint of the
+// collector, e.g. http://localhost:4318/v1/traces.
+func NewHTTPReporter(url string, opts ...zipkinhttp.ReporterOption) reporter.Reporter {
+	return zipkinhttp.NewReporter(url, append(opts, zipkinhttp.Serializer(SpanSerializer{}))...)
+}
+
+// NewGRPCReporter returns a new Reporter exporting spans using OTLP/gRPC.
+// target should be the address of the collector's OTLP/gRPC endpoint, e.g.
+// localhost:4317.
+func NewGRPCReporter(target string, opts ...zipkingrpc.ReporterOption) (reporter.Reporter, error) {
+	return zipkingrpc.NewReporter(target, append(opts, zipkingrpc.Serializer(SpanSerializer{}, exportMethod))...)
+}
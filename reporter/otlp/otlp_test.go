@@ -0,0 +1,289 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otlp_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter/otlp"
+)
+
+// field is a decoded protocol buffer field.
+type field struct {
+	num   int
+	value uint64
+	data  []byte
+}
+
+// decode decodes the fields of a protocol buffer message.
+func decode(t *testing.T, b []byte) []field {
+	t.Helper()
+
+	var fields []field
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			t.Fatal("invalid field key")
+		}
+		b = b[n:]
+		f := field{num: int(key >> 3)}
+		switch key & 7 {
+		case 0:
+			if f.value, n = binary.Uvarint(b); n <= 0 {
+				t.Fatal("invalid varint")
+			}
+			b = b[n:]
+		case 1:
+			f.value = binary.LittleEndian.Uint64(b)
+			b = b[8:]
+		case 2:
+			size, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < size {
+				t.Fatal("invalid length")
+			}
+			f.data = b[n : n+int(size)]
+			b = b[n+int(size):]
+		default:
+			t.Fatalf("unexpected wire type %d", key&7)
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// get returns the fields numbered num.
+func get(fields []field, num int) []field {
+	var found []field
+	for _, f := range fields {
+		if f.num == num {
+			found = append(found, f)
+		}
+	}
+	return found
+}
+
+// attributes returns the string attributes among fields numbered num.
+func attributes(t *testing.T, fields []field, num int) map[string]string {
+	attrs := make(map[string]string)
+	for _, kv := range get(fields, num) {
+		kvFields := decode(t, kv.data)
+		key := string(get(kvFields, 1)[0].data)
+		value := decode(t, get(kvFields, 2)[0].data)
+		if s := get(value, 1); len(s) > 0 {
+			attrs[key] = string(s[0].data)
+		}
+	}
+	return attrs
+}
+
+func newSpans() []*model.SpanModel {
+	parentID := model.ID(1)
+	ts := time.Unix(1600000000, 0)
+	return []*model.SpanModel{
+		{
+			SpanContext: model.SpanContext{
+				TraceID:  model.TraceID{High: 1, Low: 2},
+				ID:       3,
+				ParentID: &parentID,
+			},
+			Name:          "get /users",
+			Kind:          model.Client,
+			Timestamp:     ts,
+			Duration:      time.Millisecond,
+			LocalEndpoint: &model.Endpoint{ServiceName: "frontend"},
+			RemoteEndpoint: &model.Endpoint{
+				ServiceName: "users",
+				IPv4:        net.IPv4(10, 0, 0, 1),
+				Port:        8080,
+			},
+			Annotations: []model.Annotation{{Timestamp: ts, Value: "retry"}},
+			Tags:        map[string]string{"http.method": "GET", "error": "timeout"},
+		},
+		{
+			SpanContext:   model.SpanContext{TraceID: model.TraceID{Low: 2}, ID: 4},
+			Name:          "query",
+			LocalEndpoint: &model.Endpoint{ServiceName: "users"},
+		},
+	}
+}
+
+func TestSerialize(t *testing.T) {
+	b, err := otlp.SpanSerializer{}.Serialize(newSpans())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resourceSpans := get(decode(t, b), 1)
+	if want, have := 2, len(resourceSpans); want != have {
+		t.Fatalf("resource spans want %d, have %d", want, have)
+	}
+
+	rs := decode(t, resourceSpans[0].data)
+	resource := decode(t, get(rs, 1)[0].data)
+	if want, have := "frontend", attributes(t, resource, 1)["service.name"]; want != have {
+		t.Errorf("service name want %q, have %q", want, have)
+	}
+	scopeSpans := decode(t, get(rs, 2)[0].data)
+	span := decode(t, get(scopeSpans, 2)[0].data)
+
+	wantTraceID := []byte{0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 2}
+	if have := get(span, 1)[0].data; !bytes.Equal(wantTraceID, have) {
+		t.Errorf("trace id want %x, have %x", wantTraceID, have)
+	}
+	if want, have := []byte{0, 0, 0, 0, 0, 0, 0, 3}, get(span, 2)[0].data; !bytes.Equal(want, have) {
+		t.Errorf("span id want %x, have %x", want, have)
+	}
+	if want, have := []byte{0, 0, 0, 0, 0, 0, 0, 1}, get(span, 4)[0].data; !bytes.Equal(want, have) {
+		t.Errorf("parent span id want %x, have %x", want, have)
+	}
+	if want, have := "get /users", string(get(span, 5)[0].data); want != have {
+		t.Errorf("name want %q, have %q", want, have)
+	}
+	if want, have := uint64(3), get(span, 6)[0].value; want != have {
+		t.Errorf("kind want %d, have %d", want, have)
+	}
+	start, end := get(span, 7)[0].value, get(span, 8)[0].value
+	if want, have := uint64(1600000000*time.Second), start; want != have {
+		t.Errorf("start time want %d, have %d", want, have)
+	}
+	if want, have := uint64(time.Millisecond), end-start; want != have {
+		t.Errorf("duration want %d, have %d", want, have)
+	}
+
+	attrs := attributes(t, span, 9)
+	for key, want := range map[string]string{
+		"http.method":  "GET",
+		"peer.service": "users",
+		"net.peer.ip":  "10.0.0.1",
+	} {
+		if have := attrs[key]; want != have {
+			t.Errorf("attribute %s want %q, have %q", key, want, have)
+		}
+	}
+	if _, ok := attrs["error"]; ok {
+		t.Error("expected error tag to be mapped to the status")
+	}
+
+	event := decode(t, get(span, 11)[0].data)
+	if want, have := "retry", string(get(event, 2)[0].data); want != have {
+		t.Errorf("event name want %q, have %q", want, have)
+	}
+	status := decode(t, get(span, 15)[0].data)
+	if want, have := "timeout", string(get(status, 2)[0].data); want != have {
+		t.Errorf("status message want %q, have %q", want, have)
+	}
+	if want, have := uint64(2), get(status, 3)[0].value; want != have {
+		t.Errorf("status code want %d, have %d", want, have)
+	}
+
+	span = decode(t, get(decode(t, get(decode(t, resourceSpans[1].data), 2)[0].data), 2)[0].data)
+	if want, have := uint64(1), get(span, 6)[0].value; want != have {
+		t.Errorf("kind want %d, have %d", want, have)
+	}
+	if want, have := 0, len(get(span, 4)); want != have {
+		t.Errorf("parent span id fields want %d, have %d", want, have)
+	}
+}
+
+func TestHTTPReporter(t *testing.T) {
+	var (
+		contentType string
+		body        []byte
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	rep := otlp.NewHTTPReporter(srv.URL)
+	for _, span := range newSpans() {
+		rep.Send(*span)
+	}
+	if err := rep.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if want, have := "application/x-protobuf", contentType; want != have {
+		t.Errorf("content type want %q, have %q", want, have)
+	}
+	if want, have := 2, len(get(decode(t, body), 1)); want != have {
+		t.Errorf("resource spans want %d, have %d", want, have)
+	}
+}
+
+// rawCodec receives messages as raw bytes.
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error)      { return *v.(*[]byte), nil }
+func (rawCodec) Unmarshal(data []byte, v interface{}) error { *v.(*[]byte) = data; return nil }
+func (rawCodec) String() string                             { return "proto" }
+
+func TestGRPCReporter(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	requests := make(chan []byte, 1)
+	server := grpc.NewServer(grpc.CustomCodec(rawCodec{}))
+	server.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "opentelemetry.proto.collector.trace.v1.TraceService",
+		HandlerType: (*interface{})(nil),
+		Methods: []grpc.MethodDesc{{
+			MethodName: "Export",
+			Handler: func(_ interface{}, _ context.Context, dec func(interface{}) error, _ grpc.UnaryServerInterceptor) (interface{}, error) {
+				var in []byte
+				if err := dec(&in); err != nil {
+					return nil, err
+				}
+				requests <- in
+				return &[]byte{}, nil
+			},
+		}},
+	}, struct{}{})
+	go func() { _ = server.Serve(lis) }()
+	defer server.Stop()
+
+	rep, err := otlp.NewGRPCReporter(lis.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, span := range newSpans() {
+		rep.Send(*span)
+	}
+	if err := rep.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case body := <-requests:
+		if want, have := 2, len(get(decode(t, body), 1)); want != have {
+			t.Errorf("resource spans want %d, have %d", want, have)
+		}
+	default:
+		t.Fatal("expected export request")
+	}
+}
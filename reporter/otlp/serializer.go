@@ -0,0 +1,192 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otlp
+
+import (
+	"encoding/binary"
+	"sort"
+	"time"
+
+	"github.com/openzipkin/zipkin-go/model"
+)
+
+// scopeName is the name of the instrumentation scope of exported spans.
+const scopeName = "github.com/openzipkin/zipkin-go"
+
+// OTLP span kinds
+const (
+	kindInternal = 1
+	kindServer   = 2
+	kindClient   = 3
+	kindProducer = 4
+	kindConsumer = 5
+)
+
+// statusError is the OTLP status code of failed spans.
+const statusError = 2
+
+// SpanSerializer implements reporter.SpanSerializer, encoding spans as OTLP
+// ExportTraceServiceRequest protocol buffer messages.
+type SpanSerializer struct{}
+
+// Serialize converts the spans to OTLP spans and encodes them.
+func (SpanSerializer) Serialize(spans []*model.SpanModel) ([]byte, error) {
+	// group spans by the service name of their local endpoint, keeping the
+	// order of first appearance
+	var (
+		services []string
+		groups   = make(map[string][]*model.SpanModel)
+	)
+	for _, span := range spans {
+		var service string
+		if span.LocalEndpoint != nil {
+			service = span.LocalEndpoint.ServiceName
+		}
+		if _, ok := groups[service]; !ok {
+			services = append(services, service)
+		}
+		groups[service] = append(groups[service], span)
+	}
+
+	var b buffer
+	for _, service := range services {
+		b.message(1, func(b *buffer) { encodeResourceSpans(b, service, groups[service]) })
+	}
+	return b, nil
+}
+
+// ContentType returns the ContentType needed for this encoding.
+func (SpanSerializer) ContentType() string {
+	return "application/x-protobuf"
+}
+
+func encodeResourceSpans(b *buffer, service string, spans []*model.SpanModel) {
+	b.message(1, func(b *buffer) {
+		if service != "" {
+			stringAttribute(b, 1, "service.name", service)
+		}
+	})
+	b.message(2, func(b *buffer) {
+		b.message(1, func(b *buffer) { b.stringField(1, scopeName) })
+		for _, span := range spans {
+			b.message(2, func(b *buffer) { encodeSpan(b, span) })
+		}
+	})
+}
+
+func encodeSpan(b *buffer, span *model.SpanModel) {
+	var traceID [16]byte
+	binary.BigEndian.PutUint64(traceID[:8], span.TraceID.High)
+	binary.BigEndian.PutUint64(traceID[8:], span.TraceID.Low)
+	b.bytesField(1, traceID[:])
+	b.bytesField(2, spanID(span.ID))
+	if span.ParentID != nil {
+		b.bytesField(4, spanID(*span.ParentID))
+	}
+	b.stringField(5, span.Name)
+	b.varintField(6, kind(span.Kind))
+	if !span.Timestamp.IsZero() {
+		b.fixed64Field(7, unixNano(span.Timestamp))
+		b.fixed64Field(8, unixNano(span.Timestamp.Add(span.Duration)))
+	}
+
+	keys := make([]string, 0, len(span.Tags))
+	for key := range span.Tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	errorMessage, failed := "", false
+	for _, key := range keys {
+		if key == "error" {
+			errorMessage, failed = span.Tags[key], true
+			continue
+		}
+		stringAttribute(b, 9, key, span.Tags[key])
+	}
+	if ep := span.LocalEndpoint; ep != nil {
+		endpointAttributes(b, "net.host", ep)
+	}
+	if ep := span.RemoteEndpoint; ep != nil {
+		if ep.ServiceName != "" {
+			stringAttribute(b, 9, "peer.service", ep.ServiceName)
+		}
+		endpointAttributes(b, "net.peer", ep)
+	}
+
+	for _, annotation := range span.Annotations {
+		b.message(11, func(b *buffer) {
+			b.fixed64Field(1, unixNano(annotation.Timestamp))
+			b.stringField(2, annotation.Value)
+		})
+	}
+
+	if failed {
+		b.message(15, func(b *buffer) {
+			b.stringField(2, errorMessage)
+			b.varintField(3, statusError)
+		})
+	}
+}
+
+// endpointAttributes adds the ip and port attributes of ep using prefix.
+func endpointAttributes(b *buffer, prefix string, ep *model.Endpoint) {
+	if ep.IPv6 != nil {
+		stringAttribute(b, 9, prefix+".ip", ep.IPv6.String())
+	} else if ep.IPv4 != nil {
+		stringAttribute(b, 9, prefix+".ip", ep.IPv4.String())
+	}
+	if ep.Port != 0 {
+		b.message(9, func(b *buffer) {
+			b.stringField(1, prefix+".port")
+			b.message(2, func(b *buffer) { b.varintField(3, uint64(ep.Port)) })
+		})
+	}
+}
+
+// stringAttribute adds a KeyValue holding a string value as field.
+func stringAttribute(b *buffer, field int, key, value string) {
+	b.message(field, func(b *buffer) {
+		b.stringField(1, key)
+		b.message(2, func(b *buffer) { b.stringField(1, value) })
+	})
+}
+
+func spanID(id model.ID) []byte {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], uint64(id))
+	return b[:]
+}
+
+func kind(k model.Kind) uint64 {
+	switch k {
+	case model.Server:
+		return kindServer
+	case model.Client:
+		return kindClient
+	case model.Producer:
+		return kindProducer
+	case model.Consumer:
+		return kindConsumer
+	default:
+		return kindInternal
+	}
+}
+
+func unixNano(t time.Time) uint64 {
+	if t.IsZero() {
+		return 0
+	}
+	return uint64(t.UnixNano())
+}
@@ -0,0 +1,82 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otlp
+
+import "encoding/binary"
+
+// protocol buffer wire types
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+)
+
+// buffer appends fields encoded in the protocol buffer wire format. Fields
+// holding default values are omitted, as in proto3.
+type buffer []byte
+
+func (b *buffer) varint(v uint64) {
+	var tmp [binary.MaxVarintLen64]byte
+	*b = append(*b, tmp[:binary.PutUvarint(tmp[:], v)]...)
+}
+
+func (b *buffer) tag(field, wireType int) {
+	b.varint(uint64(field)<<3 | uint64(wireType))
+}
+
+func (b *buffer) varintField(field int, v uint64) {
+	if v == 0 {
+		return
+	}
+	b.tag(field, wireVarint)
+	b.varint(v)
+}
+
+func (b *buffer) fixed64Field(field int, v uint64) {
+	if v == 0 {
+		return
+	}
+	b.tag(field, wireFixed64)
+	var tmp [8]byte
+	binary.LittleEndian.PutUint64(tmp[:], v)
+	*b = append(*b, tmp[:]...)
+}
+
+func (b *buffer) bytesField(field int, v []byte) {
+	if len(v) == 0 {
+		return
+	}
+	b.tag(field, wireBytes)
+	b.varint(uint64(len(v)))
+	*b = append(*b, v...)
+}
+
+func (b *buffer) stringField(field int, v string) {
+	if v == "" {
+		return
+	}
+	b.tag(field, wireBytes)
+	b.varint(uint64(len(v)))
+	*b = append(*b, v...)
+}
+
+// message appends the embedded message encoded by encode, even if empty.
+func (b *buffer) message(field int, encode func(b *buffer)) {
+	var m buffer
+	encode(&m)
+	b.tag(field, wireBytes)
+	b.varint(uint64(len(m)))
+	*b = append(*b, m...)
+}
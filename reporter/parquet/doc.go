@@ -0,0 +1,39 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package parquet implements a reporter which writes span batches as Parquet
+files, enabling cheap long-term trace retention and analysis with tools like
+Athena or DuckDB outside of Zipkin's storage.
+
+Every batch of spans is written as a single, uncompressed Parquet file with a
+stable flat schema:
+
+	trace_id       BYTE_ARRAY (UTF8)        REQUIRED
+	id             BYTE_ARRAY (UTF8)        REQUIRED
+	parent_id      BYTE_ARRAY (UTF8)        OPTIONAL
+	name           BYTE_ARRAY (UTF8)        OPTIONAL
+	kind           BYTE_ARRAY (UTF8)        OPTIONAL
+	local_service  BYTE_ARRAY (UTF8)        OPTIONAL
+	remote_service BYTE_ARRAY (UTF8)        OPTIONAL
+	timestamp      INT64 (TIMESTAMP_MICROS) OPTIONAL
+	duration       INT64 (microseconds)     OPTIONAL
+	tags           BYTE_ARRAY (UTF8, JSON)  OPTIONAL
+	annotations    BYTE_ARRAY (UTF8, JSON)  OPTIONAL
+
+Files are created through a Sink. Dir provides a Sink for a local directory,
+object stores like S3 can be targeted by implementing Sink on top of their
+upload API.
+*/
+package parquet
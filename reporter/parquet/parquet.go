@@ -0,0 +1,193 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parquet
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter"
+)
+
+// defaults
+const (
+	defaultBatchInterval = time.Minute
+	defaultBatchSize     = 10000
+)
+
+// Sink creates the files span batches are written to. Implement Sink on top
+// of an object store upload API to write the files to e.g. S3.
+type Sink interface {
+	Create(name string) (io.WriteCloser, error)
+}
+
+type dirSink string
+
+// Dir returns a Sink creating files in the provided local directory.
+func Dir(path string) Sink {
+	return dirSink(path)
+}
+
+func (d dirSink) Create(name string) (io.WriteCloser, error) {
+	return os.Create(filepath.Join(string(d), name))
+}
+
+// parquetReporter writes span batches as parquet files.
+type parquetReporter struct {
+	sink          Sink
+	prefix        string
+	logger        *log.Logger
+	batchInterval time.Duration
+	batchSize     int
+	seq           reporter.Counter
+	spanC         chan *model.SpanModel
+	quit          chan struct{}
+	shutdown      chan error
+	closeOnce     sync.Once
+	closeErr      error
+}
+
+// ReporterOption sets a parameter for the Parquet Reporter
+type ReporterOption func(r *parquetReporter)
+
+// BatchSize sets the maximum number of spans written to a single file. The
+// default batch size is 10000 spans.
+func BatchSize(n int) ReporterOption {
+	return func(r *parquetReporter) { r.batchSize = n }
+}
+
+// BatchInterval sets the maximum duration we will buffer spans before writing
+// a file. The default batch interval is 1 minute.
+func BatchInterval(d time.Duration) ReporterOption {
+	return func(r *parquetReporter) { r.batchInterval = d }
+}
+
+// FilePrefix sets the prefix of the generated file names. The default prefix
+// is "spans".
+func FilePrefix(prefix string) ReporterOption {
+	return func(r *parquetReporter) { r.prefix = prefix }
+}
+
+// Logger sets the logger used to report errors in the collection
+// process.
+func Logger(l *log.Logger) ReporterOption {
+	return func(r *parquetReporter) { r.logger = l }
+}
+
+// NewReporter returns a new Parquet Reporter writing span batches as files
+// created through sink.
+func NewReporter(sink Sink, opts ...ReporterOption) reporter.Reporter {
+	r := &parquetReporter{
+		sink:          sink,
+		prefix:        "spans",
+		logger:        log.New(os.Stderr, "", log.LstdFlags),
+		batchInterval: defaultBatchInterval,
+		batchSize:     defaultBatchSize,
+		quit:          make(chan struct{}),
+		shutdown:      make(chan error, 1),
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	r.spanC = make(chan *model.SpanModel, r.batchSize)
+
+	go r.loop()
+
+	return r
+}
+
+// Send implements reporter
+func (r *parquetReporter) Send(s model.SpanModel) {
+	r.spanC <- &s
+}
+
+// Close implements reporter
+func (r *parquetReporter) Close() error {
+	r.closeOnce.Do(func() {
+		close(r.quit)
+		r.closeErr = <-r.shutdown
+	})
+	return r.closeErr
+}
+
+func (r *parquetReporter) loop() {
+	var (
+		batch  = make([]model.SpanModel, 0, r.batchSize)
+		ticker = time.NewTicker(r.batchInterval)
+	)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case span := <-r.spanC:
+			batch = append(batch, *span)
+			if len(batch) >= r.batchSize {
+				_ = r.write(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			_ = r.write(batch)
+			batch = batch[:0]
+		case <-r.quit:
+			// drain spans sent before Close was called
+			for {
+				select {
+				case span := <-r.spanC:
+					batch = append(batch, *span)
+				default:
+					r.shutdown <- r.write(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+func (r *parquetReporter) write(batch []model.SpanModel) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	name := fmt.Sprintf(
+		"%s-%d-%d.parquet",
+		r.prefix, time.Now().UnixNano(), r.seq.Inc(),
+	)
+
+	err := r.writeFile(name, batch)
+	if err != nil {
+		r.logger.Printf("failed to write %d spans to %s: %s\n", len(batch), name, err.Error())
+	}
+	return err
+}
+
+func (r *parquetReporter) writeFile(name string, batch []model.SpanModel) error {
+	w, err := r.sink.Create(name)
+	if err != nil {
+		return err
+	}
+	if err = WriteSpans(w, batch); err != nil {
+		_ = w.Close()
+		return err
+	}
+	return w.Close()
+}
@@ -0,0 +1,216 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parquet_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter/parquet"
+)
+
+// thriftReader decodes the thrift compact protocol into generic values.
+// Structs are decoded as map[int16]interface{}, lists as []interface{},
+// integers as int64 and binaries as string.
+type thriftReader struct {
+	r *bufio.Reader
+}
+
+func (t *thriftReader) varint() int64 {
+	u, _ := binary.ReadUvarint(t.r)
+	return int64(u>>1) ^ -int64(u&1)
+}
+
+func (t *thriftReader) value(typ byte) interface{} {
+	switch typ {
+	case 1:
+		return true
+	case 2:
+		return false
+	case 5, 6:
+		return t.varint()
+	case 8:
+		n, _ := binary.ReadUvarint(t.r)
+		b := make([]byte, n)
+		_, _ = io.ReadFull(t.r, b)
+		return string(b)
+	case 9:
+		h, _ := t.r.ReadByte()
+		size := int(h >> 4)
+		if size == 15 {
+			n, _ := binary.ReadUvarint(t.r)
+			size = int(n)
+		}
+		list := make([]interface{}, size)
+		for i := range list {
+			list[i] = t.value(h & 0x0f)
+		}
+		return list
+	case 12:
+		fields := map[int16]interface{}{}
+		var id int16
+		for {
+			h, _ := t.r.ReadByte()
+			if h == 0 {
+				return fields
+			}
+			if delta := int16(h >> 4); delta != 0 {
+				id += delta
+			} else {
+				id = int16(t.varint())
+			}
+			fields[id] = t.value(h & 0x0f)
+		}
+	}
+	panic("unsupported thrift type")
+}
+
+func readStruct(b []byte) (map[int16]interface{}, int) {
+	r := bytes.NewReader(b)
+	t := &thriftReader{r: bufio.NewReaderSize(r, 16)}
+	s := t.value(12).(map[int16]interface{})
+	return s, len(b) - r.Len() - t.r.Buffered()
+}
+
+func makeSpans() []model.SpanModel {
+	parentID := model.ID(1)
+	return []model.SpanModel{
+		{
+			SpanContext: model.SpanContext{TraceID: model.TraceID{Low: 1}, ID: 1},
+			Name:        "root",
+			Kind:        model.Server,
+			Timestamp:   time.Unix(1500000000, 0),
+			Duration:    5 * time.Millisecond,
+			Tags:        map[string]string{"http.path": "/"},
+		},
+		{
+			SpanContext: model.SpanContext{TraceID: model.TraceID{Low: 1}, ID: 2, ParentID: &parentID},
+			Timestamp:   time.Unix(1500000000, 1000),
+			Duration:    2 * time.Millisecond,
+		},
+		{
+			SpanContext: model.SpanContext{TraceID: model.TraceID{Low: 1}, ID: 3, ParentID: &parentID},
+			Name:        "child",
+		},
+	}
+}
+
+func TestWriteSpans(t *testing.T) {
+	var buf bytes.Buffer
+	if err := parquet.WriteSpans(&buf, makeSpans()); err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+
+	b := buf.Bytes()
+	if want, have := "PAR1", string(b[:4]); want != have {
+		t.Fatalf("header magic want %q, have %q", want, have)
+	}
+	if want, have := "PAR1", string(b[len(b)-4:]); want != have {
+		t.Fatalf("footer magic want %q, have %q", want, have)
+	}
+
+	footerLen := int(binary.LittleEndian.Uint32(b[len(b)-8:]))
+	meta, _ := readStruct(b[len(b)-8-footerLen : len(b)-8])
+
+	if want, have := int64(3), meta[3]; want != have {
+		t.Errorf("num rows want %d, have %v", want, have)
+	}
+
+	schema := meta[2].([]interface{})
+	if want, have := 12, len(schema); want != have {
+		t.Fatalf("schema elements want %d, have %d", want, have)
+	}
+	if want, have := int64(11), schema[0].(map[int16]interface{})[5]; want != have {
+		t.Errorf("root children want %d, have %v", want, have)
+	}
+
+	chunks := meta[4].([]interface{})[0].(map[int16]interface{})[1].([]interface{})
+	if want, have := 11, len(chunks); want != have {
+		t.Fatalf("column chunks want %d, have %d", want, have)
+	}
+
+	// column 3 holds the optional span name
+	md := chunks[3].(map[int16]interface{})[3].(map[int16]interface{})
+	if want, have := "name", md[3].([]interface{})[0]; want != have {
+		t.Fatalf("column path want %q, have %v", want, have)
+	}
+
+	offset := md[9].(int64)
+	header, n := readStruct(b[offset:])
+	page := b[int(offset)+n : int(offset)+n+int(header[3].(int64))]
+
+	if want, have := int64(3), header[5].(map[int16]interface{})[1]; want != have {
+		t.Errorf("page values want %d, have %v", want, have)
+	}
+
+	levelsLen := binary.LittleEndian.Uint32(page)
+	// RLE runs: 1 x defined, 1 x undefined, 1 x defined
+	if want, have := []byte{2, 1, 2, 0, 2, 1}, page[4:4+levelsLen]; !bytes.Equal(want, have) {
+		t.Errorf("definition levels want %v, have %v", want, have)
+	}
+
+	var names []string
+	for values := page[4+levelsLen:]; len(values) > 0; {
+		l := binary.LittleEndian.Uint32(values)
+		names = append(names, string(values[4:4+l]))
+		values = values[4+l:]
+	}
+	if want, have := []string{"root", "child"}, names; len(want) != len(have) || want[0] != have[0] || want[1] != have[1] {
+		t.Errorf("names want %v, have %v", want, have)
+	}
+}
+
+func TestParquetReporter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "parquet")
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	rep := parquet.NewReporter(
+		parquet.Dir(dir),
+		parquet.BatchSize(2),
+		parquet.BatchInterval(time.Hour),
+		parquet.FilePrefix("test"),
+	)
+
+	for _, span := range makeSpans() {
+		rep.Send(span)
+	}
+
+	if err = rep.Close(); err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	// closing again must not block
+	if err = rep.Close(); err != nil {
+		t.Fatalf("unexpected error on second close: %+v", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "test-*.parquet"))
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	if want, have := 2, len(files); want != have {
+		t.Fatalf("files want %d, have %d", want, have)
+	}
+}
@@ -0,0 +1,369 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+
+	"github.com/openzipkin/zipkin-go/model"
+)
+
+const magic = "PAR1"
+
+// parquet physical types
+const (
+	typeInt64     int32 = 2
+	typeByteArray int32 = 6
+)
+
+// parquet converted types
+const (
+	convertedNone            int32 = -1
+	convertedUTF8            int32 = 0
+	convertedTimestampMicros int32 = 10
+)
+
+// parquet encodings
+const (
+	encodingPlain int32 = 0
+	encodingRLE   int32 = 3
+)
+
+// thrift compact protocol types
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// column describes a single column of the span schema. Exactly one of str or
+// num is set, depending on the physical type of the column.
+type column struct {
+	name      string
+	typ       int32
+	converted int32
+	optional  bool
+	str       func(s *model.SpanModel) (string, bool)
+	num       func(s *model.SpanModel) (int64, bool)
+}
+
+var schema = []column{
+	{name: "trace_id", typ: typeByteArray, converted: convertedUTF8, str: func(s *model.SpanModel) (string, bool) {
+		return s.TraceID.String(), true
+	}},
+	{name: "id", typ: typeByteArray, converted: convertedUTF8, str: func(s *model.SpanModel) (string, bool) {
+		return s.ID.String(), true
+	}},
+	{name: "parent_id", typ: typeByteArray, converted: convertedUTF8, optional: true, str: func(s *model.SpanModel) (string, bool) {
+		if s.ParentID == nil {
+			return "", false
+		}
+		return s.ParentID.String(), true
+	}},
+	{name: "name", typ: typeByteArray, converted: convertedUTF8, optional: true, str: func(s *model.SpanModel) (string, bool) {
+		return s.Name, s.Name != ""
+	}},
+	{name: "kind", typ: typeByteArray, converted: convertedUTF8, optional: true, str: func(s *model.SpanModel) (string, bool) {
+		return string(s.Kind), s.Kind != model.Undetermined
+	}},
+	{name: "local_service", typ: typeByteArray, converted: convertedUTF8, optional: true, str: func(s *model.SpanModel) (string, bool) {
+		if s.LocalEndpoint == nil || s.LocalEndpoint.ServiceName == "" {
+			return "", false
+		}
+		return s.LocalEndpoint.ServiceName, true
+	}},
+	{name: "remote_service", typ: typeByteArray, converted: convertedUTF8, optional: true, str: func(s *model.SpanModel) (string, bool) {
+		if s.RemoteEndpoint == nil || s.RemoteEndpoint.ServiceName == "" {
+			return "", false
+		}
+		return s.RemoteEndpoint.ServiceName, true
+	}},
+	{name: "timestamp", typ: typeInt64, converted: convertedTimestampMicros, optional: true, num: func(s *model.SpanModel) (int64, bool) {
+		if s.Timestamp.IsZero() {
+			return 0, false
+		}
+		return s.Timestamp.UnixNano() / 1e3, true
+	}},
+	{name: "duration", typ: typeInt64, converted: convertedNone, optional: true, num: func(s *model.SpanModel) (int64, bool) {
+		return s.Duration.Nanoseconds() / 1e3, s.Duration > 0
+	}},
+	{name: "tags", typ: typeByteArray, converted: convertedUTF8, optional: true, str: func(s *model.SpanModel) (string, bool) {
+		if len(s.Tags) == 0 {
+			return "", false
+		}
+		b, err := json.Marshal(s.Tags)
+		return string(b), err == nil
+	}},
+	{name: "annotations", typ: typeByteArray, converted: convertedUTF8, optional: true, str: func(s *model.SpanModel) (string, bool) {
+		if len(s.Annotations) == 0 {
+			return "", false
+		}
+		b, err := json.Marshal(s.Annotations)
+		return string(b), err == nil
+	}},
+}
+
+// WriteSpans writes the provided spans to w as a Parquet file holding a single
+// row group.
+func WriteSpans(w io.Writer, spans []model.SpanModel) error {
+	var (
+		buf    bytes.Buffer
+		chunks = make([]chunkMeta, 0, len(schema))
+	)
+	buf.WriteString(magic)
+
+	for _, col := range schema {
+		header, data := encodeColumn(col, spans)
+		chunks = append(chunks, chunkMeta{
+			col:    col,
+			offset: int64(buf.Len()),
+			size:   int64(len(header) + len(data)),
+		})
+		buf.Write(header)
+		buf.Write(data)
+	}
+
+	footer := encodeFileMetaData(chunks, int64(len(spans)))
+	buf.Write(footer)
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(len(footer)))
+	buf.WriteString(magic)
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+type chunkMeta struct {
+	col    column
+	offset int64
+	size   int64
+}
+
+// encodeColumn returns the page header and page data of the single data page
+// holding all values of a column.
+func encodeColumn(col column, spans []model.SpanModel) (header, data []byte) {
+	var (
+		values  bytes.Buffer
+		defined = make([]bool, len(spans))
+	)
+	for i := range spans {
+		if col.num != nil {
+			v, ok := col.num(&spans[i])
+			if defined[i] = ok || !col.optional; defined[i] {
+				_ = binary.Write(&values, binary.LittleEndian, v)
+			}
+			continue
+		}
+		v, ok := col.str(&spans[i])
+		if defined[i] = ok || !col.optional; defined[i] {
+			_ = binary.Write(&values, binary.LittleEndian, uint32(len(v)))
+			values.WriteString(v)
+		}
+	}
+
+	var page bytes.Buffer
+	if col.optional {
+		levels := encodeDefinitionLevels(defined)
+		_ = binary.Write(&page, binary.LittleEndian, uint32(len(levels)))
+		page.Write(levels)
+	}
+	page.Write(values.Bytes())
+
+	t := &thriftWriter{}
+	t.i32(1, 0) // DATA_PAGE
+	t.i32(2, int32(page.Len()))
+	t.i32(3, int32(page.Len()))
+	t.structBegin(5)
+	t.i32(1, int32(len(spans)))
+	t.i32(2, encodingPlain)
+	t.i32(3, encodingRLE)
+	t.i32(4, encodingRLE)
+	t.structEnd()
+	t.stop()
+
+	return t.buf.Bytes(), page.Bytes()
+}
+
+// encodeDefinitionLevels encodes definition levels with a maximum level of 1
+// as RLE runs of the RLE / bit-packing hybrid encoding.
+func encodeDefinitionLevels(defined []bool) []byte {
+	var buf bytes.Buffer
+	for i := 0; i < len(defined); {
+		j := i
+		for j < len(defined) && defined[j] == defined[i] {
+			j++
+		}
+		writeUvarint(&buf, uint64(j-i)<<1)
+		if defined[i] {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteByte(0)
+		}
+		i = j
+	}
+	return buf.Bytes()
+}
+
+func encodeFileMetaData(chunks []chunkMeta, numRows int64) []byte {
+	var totalSize int64
+	for _, c := range chunks {
+		totalSize += c.size
+	}
+
+	t := &thriftWriter{}
+	t.i32(1, 1)
+
+	// schema: root element followed by one element per column
+	t.listBegin(2, thriftStruct, len(chunks)+1)
+	t.elemBegin()
+	t.binary(4, "schema")
+	t.i32(5, int32(len(chunks)))
+	t.elemEnd()
+	for _, c := range chunks {
+		t.elemBegin()
+		t.i32(1, c.col.typ)
+		if c.col.optional {
+			t.i32(3, 1)
+		} else {
+			t.i32(3, 0)
+		}
+		t.binary(4, c.col.name)
+		if c.col.converted != convertedNone {
+			t.i32(6, c.col.converted)
+		}
+		t.elemEnd()
+	}
+
+	t.i64(3, numRows)
+
+	// single row group
+	t.listBegin(4, thriftStruct, 1)
+	t.elemBegin()
+	t.listBegin(1, thriftStruct, len(chunks))
+	for _, c := range chunks {
+		t.elemBegin()
+		t.i64(2, c.offset)
+		t.structBegin(3)
+		t.i32(1, c.col.typ)
+		t.listBegin(2, thriftI32, 2)
+		t.varint(int64(encodingPlain))
+		t.varint(int64(encodingRLE))
+		t.listBegin(3, thriftBinary, 1)
+		t.rawBinary(c.col.name)
+		t.i32(4, 0) // UNCOMPRESSED
+		t.i64(5, numRows)
+		t.i64(6, c.size)
+		t.i64(7, c.size)
+		t.i64(9, c.offset)
+		t.structEnd()
+		t.elemEnd()
+	}
+	t.i64(2, totalSize)
+	t.i64(3, numRows)
+	t.elemEnd()
+
+	t.binary(6, "zipkin-go")
+	t.stop()
+
+	return t.buf.Bytes()
+}
+
+// thriftWriter implements the subset of the thrift compact protocol needed to
+// encode parquet page headers and file metadata.
+type thriftWriter struct {
+	buf    bytes.Buffer
+	lastID int16
+	stack  []int16
+}
+
+func (t *thriftWriter) fieldHeader(id int16, typ byte) {
+	if delta := id - t.lastID; delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		t.buf.WriteByte(typ)
+		t.varint(int64(id))
+	}
+	t.lastID = id
+}
+
+func (t *thriftWriter) varint(v int64) {
+	writeUvarint(&t.buf, uint64((v<<1)^(v>>63)))
+}
+
+func (t *thriftWriter) rawBinary(s string) {
+	writeUvarint(&t.buf, uint64(len(s)))
+	t.buf.WriteString(s)
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.fieldHeader(id, thriftI32)
+	t.varint(int64(v))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.fieldHeader(id, thriftI64)
+	t.varint(v)
+}
+
+func (t *thriftWriter) binary(id int16, s string) {
+	t.fieldHeader(id, thriftBinary)
+	t.rawBinary(s)
+}
+
+func (t *thriftWriter) listBegin(id int16, elemType byte, size int) {
+	t.fieldHeader(id, thriftList)
+	if size < 15 {
+		t.buf.WriteByte(byte(size)<<4 | elemType)
+		return
+	}
+	t.buf.WriteByte(0xf0 | elemType)
+	writeUvarint(&t.buf, uint64(size))
+}
+
+func (t *thriftWriter) structBegin(id int16) {
+	t.fieldHeader(id, thriftStruct)
+	t.elemBegin()
+}
+
+func (t *thriftWriter) structEnd() {
+	t.elemEnd()
+}
+
+// elemBegin starts a struct which is an element of a list.
+func (t *thriftWriter) elemBegin() {
+	t.stack = append(t.stack, t.lastID)
+	t.lastID = 0
+}
+
+// elemEnd ends a struct which is an element of a list.
+func (t *thriftWriter) elemEnd() {
+	t.stop()
+	t.lastID = t.stack[len(t.stack)-1]
+	t.stack = t.stack[:len(t.stack)-1]
+}
+
+func (t *thriftWriter) stop() {
+	t.buf.WriteByte(0)
+}
+
+func writeUvarint(buf *bytes.Buffer, v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], v)
+	buf.Write(b[:n])
+}
@@ -0,0 +1,101 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reporter
+
+import (
+	"net"
+
+	"github.com/openzipkin/zipkin-go/model"
+)
+
+// PeerServiceRule derives the remote service name of a span from the value of
+// one of its tags.
+type PeerServiceRule struct {
+	// Tag is the key of the tag identifying the peer, e.g. "db.instance".
+	Tag string
+	// Mapping optionally maps tag values to service names. If set, values
+	// not found in the mapping are skipped. If nil, the tag value itself is
+	// used as service name.
+	Mapping map[string]string
+}
+
+// DefaultPeerServiceRules holds the rules used by NewPeerServiceReporter if
+// none are provided.
+var DefaultPeerServiceRules = []PeerServiceRule{
+	{Tag: "peer.service"},
+	{Tag: "http.host"},
+	{Tag: "db.instance"},
+	{Tag: "messaging.destination"},
+}
+
+// peerServiceReporter infers the remote service name of spans before passing
+// them to the next reporter.
+type peerServiceReporter struct {
+	next  Reporter
+	rules []PeerServiceRule
+}
+
+// NewPeerServiceReporter returns a Reporter which sets the remote service name
+// of client and producer spans lacking one from their tags, before sending
+// them to next. The rules are evaluated in order and the first tag found with
+// a usable value wins; a port suffix of host:port values is removed. This
+// improves the Zipkin dependency diagram for calls to uninstrumented peers
+// without touching the call sites.
+func NewPeerServiceReporter(next Reporter, rules ...PeerServiceRule) Reporter {
+	if len(rules) == 0 {
+		rules = DefaultPeerServiceRules
+	}
+	return &peerServiceReporter{next: next, rules: rules}
+}
+
+// Send infers the remote service name of s and sends it to the next reporter.
+func (r *peerServiceReporter) Send(s model.SpanModel) {
+	if (s.Kind == model.Client || s.Kind == model.Producer) &&
+		(s.RemoteEndpoint == nil || s.RemoteEndpoint.ServiceName == "") {
+		if name := r.infer(s.Tags); name != "" {
+			e := model.Endpoint{}
+			if s.RemoteEndpoint != nil {
+				e = *s.RemoteEndpoint
+			}
+			e.ServiceName = name
+			s.RemoteEndpoint = &e
+		}
+	}
+	r.next.Send(s)
+}
+
+func (r *peerServiceReporter) infer(tags map[string]string) string {
+	for _, rule := range r.rules {
+		value, ok := tags[rule.Tag]
+		if !ok || value == "" {
+			continue
+		}
+		if host, _, err := net.SplitHostPort(value); err == nil {
+			value = host
+		}
+		if rule.Mapping == nil {
+			return value
+		}
+		if name, ok := rule.Mapping[value]; ok && name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// Close closes the next reporter.
+func (r *peerServiceReporter) Close() error {
+	return r.next.Close()
+}
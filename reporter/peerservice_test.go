@@ -0,0 +1,91 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reporter_test
+
+import (
+	"testing"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter"
+	"github.com/openzipkin/zipkin-go/reporter/recorder"
+)
+
+func TestPeerServiceReporter(t *testing.T) {
+	ip := model.Endpoint{Port: 5432}
+	testCases := []struct {
+		name   string
+		rules  []reporter.PeerServiceRule
+		span   model.SpanModel
+		remote string
+	}{
+		{
+			name:   "http host with port",
+			span:   model.SpanModel{Kind: model.Client, Tags: map[string]string{"http.host": "api.example.com:8080"}},
+			remote: "api.example.com",
+		},
+		{
+			name: "rule order",
+			span: model.SpanModel{Kind: model.Producer, Tags: map[string]string{
+				"http.host": "broker", "peer.service": "billing",
+			}},
+			remote: "billing",
+		},
+		{
+			name:   "existing remote service name",
+			span:   model.SpanModel{Kind: model.Client, RemoteEndpoint: &model.Endpoint{ServiceName: "users"}, Tags: map[string]string{"db.instance": "db1"}},
+			remote: "users",
+		},
+		{
+			name:   "server span",
+			span:   model.SpanModel{Kind: model.Server, Tags: map[string]string{"http.host": "api.example.com"}},
+			remote: "",
+		},
+		{
+			name:   "mapping",
+			rules:  []reporter.PeerServiceRule{{Tag: "db.instance", Mapping: map[string]string{"db1": "users-db"}}},
+			span:   model.SpanModel{Kind: model.Client, RemoteEndpoint: &ip, Tags: map[string]string{"db.instance": "db1"}},
+			remote: "users-db",
+		},
+		{
+			name:   "unmapped value",
+			rules:  []reporter.PeerServiceRule{{Tag: "db.instance", Mapping: map[string]string{"db1": "users-db"}}},
+			span:   model.SpanModel{Kind: model.Client, Tags: map[string]string{"db.instance": "db2"}},
+			remote: "",
+		},
+	}
+
+	for _, tc := range testCases {
+		rec := recorder.NewReporter()
+		rep := reporter.NewPeerServiceReporter(rec, tc.rules...)
+		rep.Send(tc.span)
+
+		spans := rec.Flush()
+		if want, have := 1, len(spans); want != have {
+			t.Fatalf("%s: spans want %d, have %d", tc.name, want, have)
+		}
+		var remote string
+		if spans[0].RemoteEndpoint != nil {
+			remote = spans[0].RemoteEndpoint.ServiceName
+		}
+		if want, have := tc.remote, remote; want != have {
+			t.Errorf("%s: remote service name want %q, have %q", tc.name, want, have)
+		}
+		_ = rep.Close()
+	}
+
+	if want, have := "", ip.ServiceName; want != have {
+		t.Errorf("remote endpoint of the reported span must not be modified, have %q", have)
+	}
+}
@@ -0,0 +1,358 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package perfetto converts spans to the Perfetto trace format, giving a local,
+high resolution viewer for captured traces in the Perfetto UI
+(https://ui.perfetto.dev).
+
+Spans are written as TrackEvent slices and annotations as instant events, with
+a track per local service. Spans overlapping other spans of the service
+without being nested within them are placed on additional tracks grouped
+under the service track. The SpanSerializer encodes a set of spans, while the
+Reporter collects all spans in memory and writes them as a single trace file
+when closed:
+
+	f, _ := os.Create("trace.perfetto-trace")
+	rep := perfetto.NewReporter(f)
+	defer rep.Close()
+
+The encoder writes the subset of the Perfetto trace protobuf messages needed
+for TrackEvents and does not depend on the Perfetto SDK.
+*/
+package perfetto
+
+import (
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter"
+	"github.com/openzipkin/zipkin-go/reporter/internal/lanes"
+)
+
+// field numbers of the Perfetto trace protobuf messages
+const (
+	tracePacket = 1 // Trace.packet
+
+	packetTimestamp       = 8  // TracePacket.timestamp
+	packetSequenceID      = 10 // TracePacket.trusted_packet_sequence_id
+	packetTrackEvent      = 11 // TracePacket.track_event
+	packetSequenceFlags   = 13 // TracePacket.sequence_flags
+	packetTrackDescriptor = 60 // TracePacket.track_descriptor
+
+	trackUUID       = 1 // TrackDescriptor.uuid
+	trackName       = 2 // TrackDescriptor.name
+	trackProcess    = 3 // TrackDescriptor.process
+	trackParentUUID = 5 // TrackDescriptor.parent_uuid
+
+	processPid  = 1 // ProcessDescriptor.pid
+	processName = 6 // ProcessDescriptor.process_name
+
+	eventDebugAnnotation = 4  // TrackEvent.debug_annotations
+	eventType            = 9  // TrackEvent.type
+	eventTrackUUID       = 11 // TrackEvent.track_uuid
+	eventCategory        = 22 // TrackEvent.categories
+	eventName            = 23 // TrackEvent.name
+
+	annotationStringValue = 6  // DebugAnnotation.string_value
+	annotationName        = 10 // DebugAnnotation.name
+)
+
+// TrackEvent.Type values
+const (
+	typeSliceBegin = 1
+	typeSliceEnd   = 2
+	typeInstant    = 3
+)
+
+// sequenceID identifies the packet sequence of the trace and
+// seqIncrementalStateCleared marks the start of the sequence.
+const (
+	sequenceID                 = 1
+	seqIncrementalStateCleared = 1
+)
+
+// SpanSerializer implements reporter.SpanSerializer producing a Perfetto
+// protobuf trace.
+type SpanSerializer struct{}
+
+// Serialize returns the spans as Perfetto trace.
+func (SpanSerializer) Serialize(spans []*model.SpanModel) ([]byte, error) {
+	return encode(spans), nil
+}
+
+// ContentType returns the ContentType needed for this encoding.
+func (SpanSerializer) ContentType() string {
+	return "application/x-protobuf"
+}
+
+// event holds a track event to be written.
+type event struct {
+	ts    int64
+	typ   int
+	order int64 // orders events of the same timestamp, see sortEvents
+	track uint64
+	span  *model.SpanModel
+	name  string
+}
+
+func encode(spans []*model.SpanModel) []byte {
+	// spans sorted by start time with enclosing spans first
+	sorted := make([]*model.SpanModel, len(spans))
+	copy(sorted, spans)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if !sorted[i].Timestamp.Equal(sorted[j].Timestamp) {
+			return sorted[i].Timestamp.Before(sorted[j].Timestamp)
+		}
+		return sorted[i].Duration > sorted[j].Duration
+	})
+
+	var (
+		services = serviceIDs(sorted)
+		layouts  = make(map[string]*lanes.Set)
+		events   = make([]event, 0, 2*len(spans))
+	)
+	for _, s := range sorted {
+		service := serviceName(s)
+		if layouts[service] == nil {
+			layouts[service] = &lanes.Set{}
+		}
+		start, end := s.Timestamp.UnixNano(), s.Timestamp.Add(s.Duration).UnixNano()
+		track := trackID(services[service], layouts[service].Place(start, end))
+
+		if start == end {
+			events = append(events, event{ts: start, typ: typeInstant, track: track, span: s, name: s.Name})
+		} else {
+			events = append(events,
+				event{ts: start, typ: typeSliceBegin, order: -end, track: track, span: s, name: s.Name},
+				event{ts: end, typ: typeSliceEnd, order: -start, track: track},
+			)
+		}
+		for _, a := range s.Annotations {
+			events = append(events, event{ts: a.Timestamp.UnixNano(), typ: typeInstant, track: track, name: a.Value})
+		}
+	}
+	sortEvents(events)
+
+	var b buffer
+	first := true
+	packet := func(fn func(p *buffer)) {
+		b.message(tracePacket, func(p *buffer) {
+			p.varint(packetSequenceID, sequenceID)
+			if first {
+				p.varint(packetSequenceFlags, seqIncrementalStateCleared)
+				first = false
+			}
+			fn(p)
+		})
+	}
+
+	for _, name := range sortedNames(services) {
+		pid := services[name]
+		packet(func(p *buffer) {
+			p.message(packetTrackDescriptor, func(d *buffer) {
+				d.varint(trackUUID, trackID(pid, 1))
+				d.message(trackProcess, func(proc *buffer) {
+					proc.varint(processPid, uint64(pid))
+					proc.string(processName, name)
+				})
+			})
+		})
+		for lane := 2; lane <= layouts[name].Len(); lane++ {
+			packet(func(p *buffer) {
+				p.message(packetTrackDescriptor, func(d *buffer) {
+					d.varint(trackUUID, trackID(pid, lane))
+					d.varint(trackParentUUID, trackID(pid, 1))
+					d.string(trackName, name+" "+strconv.Itoa(lane))
+				})
+			})
+		}
+	}
+
+	for _, e := range events {
+		packet(func(p *buffer) {
+			p.varint(packetTimestamp, uint64(e.ts))
+			p.message(packetTrackEvent, func(te *buffer) {
+				te.varint(eventType, uint64(e.typ))
+				te.varint(eventTrackUUID, e.track)
+				if e.typ == typeSliceEnd {
+					return
+				}
+				te.string(eventName, e.name)
+				if e.span == nil {
+					return
+				}
+				if e.span.Kind != model.Undetermined {
+					te.string(eventCategory, string(e.span.Kind))
+				}
+				for _, a := range spanArgs(e.span) {
+					te.message(eventDebugAnnotation, func(da *buffer) {
+						da.string(annotationName, a[0])
+						da.string(annotationStringValue, a[1])
+					})
+				}
+			})
+		})
+	}
+	return b
+}
+
+// sortEvents sorts events by timestamp. Events of the same timestamp are
+// ordered to keep slices nested: slice ends come first, the slice started last
+// ending first, followed by instant events and slice begins, the slice ending
+// last beginning first.
+func sortEvents(events []event) {
+	rank := map[int]int{typeSliceEnd: 0, typeInstant: 1, typeSliceBegin: 2}
+	sort.SliceStable(events, func(i, j int) bool {
+		a, b := events[i], events[j]
+		if a.ts != b.ts {
+			return a.ts < b.ts
+		}
+		if a.typ != b.typ {
+			return rank[a.typ] < rank[b.typ]
+		}
+		return a.order < b.order
+	})
+}
+
+// trackID returns the track uuid of the lane of a service.
+func trackID(pid, lane int) uint64 {
+	return uint64(pid)<<32 | uint64(lane)
+}
+
+// serviceIDs returns the process ids of the local services, assigned in order
+// of the service names.
+func serviceIDs(spans []*model.SpanModel) map[string]int {
+	ids := make(map[string]int)
+	for _, s := range spans {
+		ids[serviceName(s)] = 0
+	}
+	for i, name := range sortedNames(ids) {
+		ids[name] = i + 1
+	}
+	return ids
+}
+
+func sortedNames(ids map[string]int) []string {
+	names := make([]string, 0, len(ids))
+	for name := range ids {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func serviceName(s *model.SpanModel) string {
+	if s.LocalEndpoint == nil || s.LocalEndpoint.ServiceName == "" {
+		return "unknown"
+	}
+	return s.LocalEndpoint.ServiceName
+}
+
+// spanArgs returns the debug annotations of the span, tags sorted by key.
+func spanArgs(s *model.SpanModel) [][2]string {
+	args := [][2]string{
+		{"traceId", s.TraceID.String()},
+		{"spanId", s.ID.String()},
+	}
+	if s.ParentID != nil {
+		args = append(args, [2]string{"parentId", s.ParentID.String()})
+	}
+	if s.RemoteEndpoint != nil && s.RemoteEndpoint.ServiceName != "" {
+		args = append(args, [2]string{"remoteServiceName", s.RemoteEndpoint.ServiceName})
+	}
+	keys := make([]string, 0, len(s.Tags))
+	for k := range s.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		args = append(args, [2]string{k, s.Tags[k]})
+	}
+	return args
+}
+
+// buffer encodes protobuf messages.
+type buffer []byte
+
+func (b *buffer) tag(field, wireType int) {
+	b.rawVarint(uint64(field)<<3 | uint64(wireType))
+}
+
+func (b *buffer) rawVarint(v uint64) {
+	for v >= 0x80 {
+		*b = append(*b, byte(v)|0x80)
+		v >>= 7
+	}
+	*b = append(*b, byte(v))
+}
+
+func (b *buffer) varint(field int, v uint64) {
+	b.tag(field, 0)
+	b.rawVarint(v)
+}
+
+func (b *buffer) string(field int, s string) {
+	b.tag(field, 2)
+	b.rawVarint(uint64(len(s)))
+	*b = append(*b, s...)
+}
+
+func (b *buffer) message(field int, fn func(m *buffer)) {
+	var m buffer
+	fn(&m)
+	b.tag(field, 2)
+	b.rawVarint(uint64(len(m)))
+	*b = append(*b, m...)
+}
+
+// perfettoReporter collects spans and writes them as trace file on Close.
+type perfettoReporter struct {
+	mtx    sync.Mutex
+	w      io.Writer
+	spans  []*model.SpanModel
+	closed bool
+}
+
+// NewReporter returns a Reporter collecting all spans in memory and writing
+// them to w as a Perfetto trace file when closed.
+func NewReporter(w io.Writer) reporter.Reporter {
+	return &perfettoReporter{w: w}
+}
+
+// Send collects the span.
+func (r *perfettoReporter) Send(s model.SpanModel) {
+	r.mtx.Lock()
+	if !r.closed {
+		r.spans = append(r.spans, &s)
+	}
+	r.mtx.Unlock()
+}
+
+// Close writes the collected spans. Spans sent after closing are discarded.
+func (r *perfettoReporter) Close() error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	_, err := r.w.Write(encode(r.spans))
+	r.spans = nil
+	return err
+}
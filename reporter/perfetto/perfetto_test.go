@@ -0,0 +1,249 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package perfetto_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter/perfetto"
+)
+
+// fields holds the decoded fields of a protobuf message, varints as uint64
+// and length delimited fields as []byte.
+type fields map[int][]interface{}
+
+func decode(t *testing.T, b []byte) fields {
+	f := fields{}
+	for len(b) > 0 {
+		key, n := varint(b)
+		b = b[n:]
+		switch key & 7 {
+		case 0:
+			v, n := varint(b)
+			f[int(key>>3)] = append(f[int(key>>3)], v)
+			b = b[n:]
+		case 2:
+			l, n := varint(b)
+			f[int(key>>3)] = append(f[int(key>>3)], b[n:n+int(l)])
+			b = b[n+int(l):]
+		default:
+			t.Fatalf("unexpected wire type %d", key&7)
+		}
+	}
+	return f
+}
+
+func varint(b []byte) (v uint64, n int) {
+	for shift := uint(0); ; shift += 7 {
+		c := b[n]
+		n++
+		v |= uint64(c&0x7f) << shift
+		if c < 0x80 {
+			return v, n
+		}
+	}
+}
+
+func (f fields) uint(field int) uint64 {
+	if len(f[field]) == 0 {
+		return 0
+	}
+	return f[field][0].(uint64)
+}
+
+func (f fields) str(field int) string {
+	if len(f[field]) == 0 {
+		return ""
+	}
+	return string(f[field][0].([]byte))
+}
+
+func (f fields) msg(t *testing.T, field int) fields {
+	if len(f[field]) == 0 {
+		return nil
+	}
+	return decode(t, f[field][0].([]byte))
+}
+
+func makeSpans() []*model.SpanModel {
+	var (
+		start    = time.Unix(1000, 0)
+		parentID = model.ID(1)
+		frontend = &model.Endpoint{ServiceName: "frontend"}
+		backend  = &model.Endpoint{ServiceName: "backend"}
+	)
+	span := func(id model.ID, name string, ep *model.Endpoint, offset, duration time.Duration) *model.SpanModel {
+		s := &model.SpanModel{
+			SpanContext:   model.SpanContext{TraceID: model.TraceID{Low: 1}, ID: id},
+			Name:          name,
+			Kind:          model.Server,
+			LocalEndpoint: ep,
+			Timestamp:     start.Add(offset),
+			Duration:      duration,
+			Tags:          map[string]string{"k": "v"},
+		}
+		if id != parentID {
+			s.ParentID = &parentID
+		}
+		return s
+	}
+	spans := []*model.SpanModel{
+		// ends together with root
+		span(2, "child-1", frontend, 50*time.Millisecond, 50*time.Millisecond),
+		span(1, "root", frontend, 0, 100*time.Millisecond),
+		// overlaps with child-1 without being nested in it
+		span(3, "child-2", frontend, 40*time.Millisecond, 30*time.Millisecond),
+		span(4, "backend", backend, 15*time.Millisecond, 10*time.Millisecond),
+	}
+	spans[1].Annotations = []model.Annotation{{Timestamp: start.Add(5 * time.Millisecond), Value: "ready"}}
+	return spans
+}
+
+type slice struct {
+	name  string
+	track uint64
+	args  map[string]string
+	cat   string
+}
+
+func TestSerialize(t *testing.T) {
+	b, err := perfetto.SpanSerializer{}.Serialize(makeSpans())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var (
+		trace    = decode(t, b)
+		tracks   = make(map[uint64]fields)
+		open     = make(map[uint64][]string)
+		slices   = make(map[string]slice)
+		instants = make(map[string]uint64)
+		lastTs   uint64
+	)
+	for i, raw := range trace[1] {
+		p := decode(t, raw.([]byte))
+		if want, have := uint64(1), p.uint(10); want != have {
+			t.Errorf("sequence id want %d, have %d", want, have)
+		}
+		if i == 0 && p.uint(13) != 1 {
+			t.Error("expected first packet to clear the incremental state")
+		}
+		if d := p.msg(t, 60); d != nil {
+			tracks[d.uint(1)] = d
+			continue
+		}
+		te := p.msg(t, 11)
+		ts := p.uint(8)
+		if ts < lastTs {
+			t.Fatalf("events not sorted by timestamp")
+		}
+		lastTs = ts
+		track := te.uint(11)
+		if _, ok := tracks[track]; !ok {
+			t.Fatalf("event on undeclared track %d", track)
+		}
+		switch te.uint(9) {
+		case 1:
+			s := slice{name: te.str(23), track: track, cat: te.str(22), args: map[string]string{}}
+			for _, raw := range te[4] {
+				da := decode(t, raw.([]byte))
+				s.args[da.str(10)] = da.str(6)
+			}
+			slices[s.name] = s
+			open[track] = append(open[track], s.name)
+		case 2:
+			if len(open[track]) == 0 {
+				t.Fatalf("slice end without begin on track %d", track)
+			}
+			open[track] = open[track][:len(open[track])-1]
+		case 3:
+			instants[te.str(23)] = track
+		}
+	}
+
+	for track, names := range open {
+		if len(names) > 0 {
+			t.Errorf("unterminated slices %v on track %d", names, track)
+		}
+	}
+	if want, have := 3, len(tracks); want != have {
+		t.Fatalf("tracks want %d, have %d", want, have)
+	}
+
+	// services are numbered in order of their names, with a track each
+	backend, frontend := uint64(1<<32|1), uint64(2<<32|1)
+	if want, have := "backend", tracks[backend].msg(t, 3).str(6); want != have {
+		t.Errorf("process name want %s, have %s", want, have)
+	}
+	if want, have := uint64(2), tracks[frontend].msg(t, 3).uint(1); want != have {
+		t.Errorf("pid want %d, have %d", want, have)
+	}
+	lane := tracks[2<<32|2]
+	if want, have := frontend, lane.uint(5); want != have {
+		t.Errorf("parent track want %d, have %d", want, have)
+	}
+
+	root := slices["root"]
+	if want, have := frontend, root.track; want != have {
+		t.Errorf("root track want %d, have %d", want, have)
+	}
+	if want, have := "SERVER", root.cat; want != have {
+		t.Errorf("category want %s, have %s", want, have)
+	}
+	if want, have := "0000000000000001", root.args["spanId"]; want != have {
+		t.Errorf("span id want %s, have %s", want, have)
+	}
+	if want, have := "v", root.args["k"]; want != have {
+		t.Errorf("tag want %s, have %s", want, have)
+	}
+	// child-2 starts first, child-1 overlaps with it
+	if want, have := frontend, slices["child-2"].track; want != have {
+		t.Errorf("child-2 track want %d, have %d", want, have)
+	}
+	if want, have := uint64(2<<32|2), slices["child-1"].track; want != have {
+		t.Errorf("child-1 track want %d, have %d", want, have)
+	}
+	if want, have := frontend, instants["ready"]; want != have {
+		t.Errorf("annotation track want %d, have %d", want, have)
+	}
+}
+
+func TestReporter(t *testing.T) {
+	var buf bytes.Buffer
+	rep := perfetto.NewReporter(&buf)
+	for _, s := range makeSpans() {
+		rep.Send(*s)
+	}
+	if want, have := 0, buf.Len(); want != have {
+		t.Fatalf("expected nothing written before close, have %d bytes", have)
+	}
+
+	if err := rep.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want, _ := perfetto.SpanSerializer{}.Serialize(makeSpans())
+	if !bytes.Equal(want, buf.Bytes()) {
+		t.Error("expected reporter to write the serialized spans")
+	}
+
+	rep.Send(*makeSpans()[0])
+	n := buf.Len()
+	if err := rep.Close(); err != nil || buf.Len() != n {
+		t.Errorf("expected second close to be a noop, have %v", err)
+	}
+}
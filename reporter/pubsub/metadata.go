@@ -0,0 +1,85 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/openzipkin/zipkin-go/reporter"
+)
+
+// metadataTokenSource retrieves access tokens of the default service account
+// from the metadata server of the compute environment, caching them until
+// shortly before they expire.
+type metadataTokenSource struct {
+	client  *http.Client
+	url     string
+	mtx     sync.Mutex
+	value   string
+	expires time.Time
+}
+
+func newMetadataTokenSource(client *http.Client) *metadataTokenSource {
+	host := os.Getenv("GCE_METADATA_HOST")
+	if host == "" {
+		host = "metadata.google.internal"
+	}
+	return &metadataTokenSource{
+		client: client,
+		url:    "http://" + host + "/computeMetadata/v1/instance/service-accounts/default/token",
+	}
+}
+
+func (m *metadataTokenSource) token() (string, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if m.value != "" && time.Now().Before(m.expires) {
+		return m.value, nil
+	}
+
+	req, err := http.NewRequest("GET", m.url, nil)
+	if err != nil {
+		return "", err
+	}
+	req = req.WithContext(reporter.NewUntracedContext(context.Background()))
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("pubsub: metadata server returned status code %d", resp.StatusCode)
+	}
+
+	var t struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&t); err != nil {
+		return "", err
+	}
+	m.value = t.AccessToken
+	// refresh a minute before the token expires
+	m.expires = time.Now().Add(time.Duration(t.ExpiresIn)*time.Second - time.Minute)
+	return m.value, nil
+}
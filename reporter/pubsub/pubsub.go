@@ -0,0 +1,439 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package pubsub implements a reporter to publish spans to a Google Cloud Pub/Sub
+topic, e.g. consumed by the Pub/Sub collector of zipkin-gcp.
+
+Every message holds the list of spans of one trace in the format of the
+serializer, JSON by default, with the trace id as ordering key so the spans of
+a trace are delivered in order to subscriptions with message ordering enabled.
+Messages are published using the Pub/Sub REST API without depending on the
+Google Cloud SDK. By default access tokens are retrieved from the metadata
+server of the compute environment, e.g. for the service account of a GKE
+workload or Cloud Run service. If the PUBSUB_EMULATOR_HOST environment variable
+is set, messages are published to the emulator without authentication.
+*/
+package pubsub
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter"
+	"github.com/openzipkin/zipkin-go/reporter/internal/batch"
+)
+
+// defaults
+const (
+	defaultEndpoint      = "https://pubsub.googleapis.com"
+	defaultTimeout       = time.Second * 5 // timeout for the publish call
+	defaultBatchInterval = time.Second * 1 // BatchInterval in seconds
+	defaultBatchSize     = 100
+	defaultMaxBacklog    = 1000
+	defaultRetryAttempts = 3
+	defaultRetryBackoff  = 100 * time.Millisecond
+)
+
+// Pub/Sub limits
+const (
+	maxMessagesPerRequest = 1000
+	maxMessageSize        = 10 << 20
+	maxRequestSize        = 10 << 20
+)
+
+// Errors returned or reported by the Pub/Sub reporter.
+var (
+	ErrInvalidTopic    = errors.New("pubsub: project and topic required")
+	ErrMessageTooLarge = errors.New("pubsub: message too large")
+)
+
+// message is a Pub/Sub message and the spans it holds.
+type message struct {
+	data        []byte
+	orderingKey string
+	spans       []*model.SpanModel
+}
+
+// pubsubReporter will publish spans to a Pub/Sub topic.
+type pubsubReporter struct {
+	topic         string
+	endpoint      string
+	token         func() (string, error)
+	client        *http.Client
+	orderingKey   bool
+	attributes    map[string]string
+	retryAttempts int
+	retryBackoff  time.Duration
+	logger        reporter.Logger
+	batchInterval time.Duration
+	batchSize     int
+	byteThreshold int
+	maxBacklog    int
+	serializer    reporter.SpanSerializer
+	onDrop        func(model.SpanModel, error)
+	batcher       *batch.Batcher
+}
+
+// ReporterOption sets a parameter for the Pub/Sub Reporter.
+type ReporterOption func(r *pubsubReporter)
+
+// Endpoint sets the Pub/Sub API endpoint, e.g. a regional endpoint like
+// https://europe-west1-pubsub.googleapis.com, which publishing with ordering
+// keys benefits from. The default is https://pubsub.googleapis.com.
+func Endpoint(url string) ReporterOption {
+	return func(r *pubsubReporter) { r.endpoint = url }
+}
+
+// TokenFunc sets the function returning the OAuth 2.0 access token used to
+// authorize requests, e.g. the Token method of a token source of
+// golang.org/x/oauth2/google for service account keys. It is invoked for every
+// request. A nil function disables authorization.
+func TokenFunc(fn func() (string, error)) ReporterOption {
+	return func(r *pubsubReporter) {
+		if fn == nil {
+			fn = func() (string, error) { return "", nil }
+		}
+		r.token = fn
+	}
+}
+
+// Client sets a custom http client to use.
+func Client(client *http.Client) ReporterOption {
+	return func(r *pubsubReporter) { r.client = client }
+}
+
+// OrderingKey when enabled publishes messages with the trace id of their spans
+// as ordering key. It is enabled by default.
+func OrderingKey(enabled bool) ReporterOption {
+	return func(r *pubsubReporter) { r.orderingKey = enabled }
+}
+
+// Attributes sets attributes added to every message, in addition to the
+// content type of the serializer as "Content-Type" attribute.
+func Attributes(attributes map[string]string) ReporterOption {
+	return func(r *pubsubReporter) { r.attributes = attributes }
+}
+
+// Retry sets the number of attempts for publish calls failing due to
+// throttling or service errors, waiting an exponential backoff starting at
+// backoff between attempts. By default calls are attempted 3 times starting
+// with a backoff of 100ms.
+func Retry(attempts int, backoff time.Duration) ReporterOption {
+	return func(r *pubsubReporter) {
+		r.retryAttempts = attempts
+		if backoff > 0 {
+			r.retryBackoff = backoff
+		}
+	}
+}
+
+// Timeout sets maximum timeout for publish calls.
+func Timeout(duration time.Duration) ReporterOption {
+	return func(r *pubsubReporter) { r.client.Timeout = duration }
+}
+
+// BatchSize sets the maximum batch size, after which a collect will be
+// triggered. The default batch size is 100 traces.
+func BatchSize(n int) ReporterOption {
+	return func(r *pubsubReporter) { r.batchSize = n }
+}
+
+// ByteThreshold sets the size in bytes of the serialized spans in the batch
+// after which a collect will be triggered. It also limits the size of publish
+// requests. Every span is serialized when added to the batch to track its
+// size, so the threshold is disabled by default.
+func ByteThreshold(n int) ReporterOption {
+	return func(r *pubsubReporter) { r.byteThreshold = n }
+}
+
+// MaxBacklog sets the maximum backlog size. When batch size reaches this
+// threshold, spans from the beginning of the batch will be disposed.
+func MaxBacklog(n int) ReporterOption {
+	return func(r *pubsubReporter) { r.maxBacklog = n }
+}
+
+// BatchInterval sets the maximum duration we will buffer traces before
+// emitting them to the topic. The default batch interval is 1 second.
+func BatchInterval(d time.Duration) ReporterOption {
+	return func(r *pubsubReporter) { r.batchInterval = d }
+}
+
+// Serializer sets the serialization function to use for sending span data to
+// Zipkin.
+func Serializer(serializer reporter.SpanSerializer) ReporterOption {
+	return func(r *pubsubReporter) {
+		if serializer != nil {
+			r.serializer = serializer
+		}
+	}
+}
+
+// Logger sets the logger used to report errors in the collection
+// process. It accepts a *log.Logger or any other reporter.Logger, e.g. one
+// returned by reporter.SlogLogger.
+func Logger(l reporter.Logger) ReporterOption {
+	return func(r *pubsubReporter) { r.logger = l }
+}
+
+// OnDrop registers a callback function which is invoked for every span the
+// reporter fails to deliver, together with the reason. Spans are dropped when
+// the backlog overflows, on serialization failures and when their message is
+// rejected or fails after all attempts. The callback is invoked synchronously
+// from the reporter's goroutines so it should not block.
+func OnDrop(fn func(span model.SpanModel, reason error)) ReporterOption {
+	return func(r *pubsubReporter) { r.onDrop = fn }
+}
+
+// NewReporter returns a new Pub/Sub Reporter publishing spans to topic of
+// project.
+func NewReporter(project, topic string, opts ...ReporterOption) (reporter.Reporter, error) {
+	if project == "" || topic == "" {
+		return nil, ErrInvalidTopic
+	}
+
+	r := &pubsubReporter{
+		topic:         "projects/" + project + "/topics/" + topic,
+		endpoint:      defaultEndpoint,
+		client:        &http.Client{Timeout: defaultTimeout},
+		orderingKey:   true,
+		retryAttempts: defaultRetryAttempts,
+		retryBackoff:  defaultRetryBackoff,
+		logger:        log.New(os.Stderr, "", log.LstdFlags),
+		batchInterval: defaultBatchInterval,
+		batchSize:     defaultBatchSize,
+		maxBacklog:    defaultMaxBacklog,
+		serializer:    reporter.JSONSerializer{},
+	}
+	if host := os.Getenv("PUBSUB_EMULATOR_HOST"); host != "" {
+		r.endpoint = "http://" + host
+		r.token = func() (string, error) { return "", nil }
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	if r.token == nil {
+		r.token = newMetadataTokenSource(r.client).token
+	}
+
+	r.batcher = batch.New(batch.Options{
+		Interval:      r.batchInterval,
+		Size:          r.batchSize,
+		MaxBacklog:    r.maxBacklog,
+		ByteThreshold: r.byteThreshold,
+		SpanSize:      r.spanSize,
+		Logger:        r.logger,
+		OnDrop:        r.onDrop,
+	}, r.sendBatch)
+
+	return r, nil
+}
+
+// Send implements reporter
+func (r *pubsubReporter) Send(s model.SpanModel) {
+	r.batcher.Send(s)
+}
+
+// Flush implements reporter.Flusher. It publishes the buffered spans and
+// returns the first error encountered. Flush does nothing once the reporter is
+// closed.
+func (r *pubsubReporter) Flush() error {
+	return r.batcher.Flush()
+}
+
+// Close implements reporter
+func (r *pubsubReporter) Close() error {
+	return r.batcher.Close()
+}
+
+// spanSize returns the size of span as serialized, see ByteThreshold.
+func (r *pubsubReporter) spanSize(span *model.SpanModel) int {
+	b, err := r.serializer.Serialize([]*model.SpanModel{span})
+	if err != nil {
+		return 0
+	}
+	return len(b)
+}
+
+func (r *pubsubReporter) sendBatch(spans []*model.SpanModel) error {
+	messages, err := r.messages(spans)
+
+	maxSize := maxRequestSize
+	if r.byteThreshold > 0 && r.byteThreshold < maxSize {
+		maxSize = r.byteThreshold
+	}
+	var (
+		pending []message
+		size    int
+	)
+	for _, msg := range messages {
+		if len(pending) == maxMessagesPerRequest || (len(pending) > 0 && size+len(msg.data) > maxSize) {
+			if perr := r.publish(pending); err == nil {
+				err = perr
+			}
+			pending, size = nil, 0
+		}
+		pending = append(pending, msg)
+		size += len(msg.data)
+	}
+	if len(pending) > 0 {
+		if perr := r.publish(pending); err == nil {
+			err = perr
+		}
+	}
+	return err
+}
+
+// messages builds the messages holding the spans of each trace.
+func (r *pubsubReporter) messages(spans []*model.SpanModel) ([]message, error) {
+	var (
+		traces [][]*model.SpanModel
+		index  = make(map[model.TraceID]int)
+	)
+	for _, span := range spans {
+		if i, ok := index[span.TraceID]; ok {
+			traces[i] = append(traces[i], span)
+			continue
+		}
+		index[span.TraceID] = len(traces)
+		traces = append(traces, []*model.SpanModel{span})
+	}
+
+	var (
+		messages []message
+		firstErr error
+	)
+	for _, trace := range traces {
+		data, err := r.serializer.Serialize(trace)
+		if err == nil && len(data) > maxMessageSize {
+			err = ErrMessageTooLarge
+		}
+		if err != nil {
+			r.logger.Printf("failed when marshalling the spans batch: %s\n", err.Error())
+			r.batcher.Drop(trace, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		msg := message{data: data, spans: trace}
+		if r.orderingKey {
+			msg.orderingKey = trace[0].TraceID.String()
+		}
+		messages = append(messages, msg)
+	}
+	return messages, firstErr
+}
+
+type pubsubMessage struct {
+	Data        []byte            `json:"data"`
+	Attributes  map[string]string `json:"attributes,omitempty"`
+	OrderingKey string            `json:"orderingKey,omitempty"`
+}
+
+type publishRequest struct {
+	Messages []pubsubMessage `json:"messages"`
+}
+
+type errorResponse struct {
+	Error struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Status  string `json:"status"`
+	} `json:"error"`
+}
+
+// publish publishes messages, retrying failed calls.
+func (r *pubsubReporter) publish(messages []message) error {
+	var (
+		err   error
+		retry bool
+	)
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 {
+			time.Sleep(r.retryBackoff << uint(attempt-2))
+		}
+		retry, err = r.publishRequest(messages)
+		if err == nil || !retry || attempt >= r.retryAttempts {
+			break
+		}
+	}
+	if err != nil {
+		for _, msg := range messages {
+			r.batcher.Drop(msg.spans, err)
+		}
+	}
+	return err
+}
+
+// publishRequest publishes messages and returns whether a failed call might
+// succeed when retried.
+func (r *pubsubReporter) publishRequest(messages []message) (bool, error) {
+	attributes := map[string]string{"Content-Type": r.serializer.ContentType()}
+	for k, v := range r.attributes {
+		attributes[k] = v
+	}
+	req := publishRequest{Messages: make([]pubsubMessage, len(messages))}
+	for i, msg := range messages {
+		req.Messages[i] = pubsubMessage{Data: msg.data, Attributes: attributes, OrderingKey: msg.orderingKey}
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return false, err
+	}
+
+	token, err := r.token()
+	if err != nil {
+		r.logger.Printf("failed to retrieve access token: %s\n", err.Error())
+		return true, err
+	}
+	httpReq, err := http.NewRequest("POST", r.endpoint+"/v1/"+r.topic+":publish", bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	// make sure instrumented transports do not trace the delivery of spans
+	httpReq = httpReq.WithContext(reporter.NewUntracedContext(context.Background()))
+	httpReq.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := r.client.Do(httpReq)
+	if err != nil {
+		r.logger.Printf("failed to send the request: %s\n", err.Error())
+		return true, err
+	}
+	defer resp.Body.Close()
+	respBody, _ := ioutil.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		var e errorResponse
+		_ = json.Unmarshal(respBody, &e)
+		err = fmt.Errorf("pubsub: publish failed with status code %d: %s %s", resp.StatusCode, e.Error.Status, e.Error.Message)
+		r.logger.Printf("%s\n", err.Error())
+		return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode > 499, err
+	}
+	return false, nil
+}
@@ -0,0 +1,320 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pubsub_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter"
+	"github.com/openzipkin/zipkin-go/reporter/pubsub"
+)
+
+type publishRequest struct {
+	Messages []struct {
+		Data        []byte            `json:"data"`
+		Attributes  map[string]string `json:"attributes"`
+		OrderingKey string            `json:"orderingKey"`
+	} `json:"messages"`
+}
+
+type fakePubSub struct {
+	mtx      sync.Mutex
+	requests []publishRequest
+	paths    []string
+	auth     []string
+	// status returns the status code of the response to the given attempt.
+	status func(attempt int) int
+}
+
+func (f *fakePubSub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	var req publishRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	f.mtx.Lock()
+	f.requests = append(f.requests, req)
+	f.paths = append(f.paths, r.URL.Path)
+	f.auth = append(f.auth, r.Header.Get("Authorization"))
+	attempt := len(f.requests)
+	f.mtx.Unlock()
+
+	if f.status != nil {
+		if status := f.status(attempt); status != http.StatusOK {
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte(`{"error":{"code":503,"message":"unavailable","status":"UNAVAILABLE"}}`))
+			return
+		}
+	}
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"messageIds": []string{"1"}})
+}
+
+func (f *fakePubSub) Requests() []publishRequest {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	return append([]publishRequest(nil), f.requests...)
+}
+
+func TestPublish(t *testing.T) {
+	fake := &fakePubSub{}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	rep, err := pubsub.NewReporter("project", "zipkin",
+		pubsub.Endpoint(srv.URL),
+		pubsub.TokenFunc(func() (string, error) { return "token", nil }),
+		pubsub.BatchInterval(time.Hour),
+		pubsub.Retry(3, time.Millisecond),
+		pubsub.Attributes(map[string]string{"env": "test"}),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer rep.Close()
+
+	// spans of the same trace are published as one message
+	for i, traceID := range []uint64{1, 2, 1} {
+		rep.Send(model.SpanModel{
+			SpanContext: model.SpanContext{TraceID: model.TraceID{Low: traceID}, ID: model.ID(i + 1)},
+			Name:        "name",
+		})
+	}
+	if err := rep.(reporter.Flusher).Flush(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	requests := fake.Requests()
+	if want, have := 1, len(requests); want != have {
+		t.Fatalf("requests want %d, have %d", want, have)
+	}
+	if want, have := "/v1/projects/project/topics/zipkin:publish", fake.paths[0]; want != have {
+		t.Errorf("path want %q, have %q", want, have)
+	}
+	if want, have := "Bearer token", fake.auth[0]; want != have {
+		t.Errorf("authorization want %q, have %q", want, have)
+	}
+
+	messages := requests[0].Messages
+	if want, have := 2, len(messages); want != have {
+		t.Fatalf("messages want %d, have %d", want, have)
+	}
+	wantKeys := []string{"0000000000000001", "0000000000000002"}
+	wantSpans := []int{2, 1}
+	for i, msg := range messages {
+		if want, have := wantKeys[i], msg.OrderingKey; want != have {
+			t.Errorf("ordering key want %q, have %q", want, have)
+		}
+		if want, have := "application/json", msg.Attributes["Content-Type"]; want != have {
+			t.Errorf("content type attribute want %q, have %q", want, have)
+		}
+		if want, have := "test", msg.Attributes["env"]; want != have {
+			t.Errorf("env attribute want %q, have %q", want, have)
+		}
+		var spans []model.SpanModel
+		if err := json.Unmarshal(msg.Data, &spans); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if want, have := wantSpans[i], len(spans); want != have {
+			t.Errorf("spans want %d, have %d", want, have)
+		}
+	}
+}
+
+func TestNoOrderingKey(t *testing.T) {
+	fake := &fakePubSub{}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	rep, err := pubsub.NewReporter("project", "zipkin",
+		pubsub.Endpoint(srv.URL),
+		pubsub.TokenFunc(func() (string, error) { return "token", nil }),
+		pubsub.BatchInterval(time.Hour),
+		pubsub.Retry(3, time.Millisecond),
+		pubsub.OrderingKey(false),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer rep.Close()
+
+	rep.Send(model.SpanModel{SpanContext: model.SpanContext{TraceID: model.TraceID{Low: 1}, ID: 1}})
+	if err := rep.(reporter.Flusher).Flush(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if have := fake.Requests()[0].Messages[0].OrderingKey; have != "" {
+		t.Errorf("unexpected ordering key %q", have)
+	}
+}
+
+func TestByteThreshold(t *testing.T) {
+	fake := &fakePubSub{}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	rep, err := pubsub.NewReporter("project", "zipkin",
+		pubsub.Endpoint(srv.URL),
+		pubsub.TokenFunc(func() (string, error) { return "token", nil }),
+		pubsub.BatchInterval(time.Hour),
+		pubsub.Retry(3, time.Millisecond),
+		pubsub.ByteThreshold(1000),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer rep.Close()
+
+	for i := 1; i <= 10; i++ {
+		rep.Send(model.SpanModel{
+			SpanContext: model.SpanContext{TraceID: model.TraceID{Low: uint64(i)}, ID: model.ID(i)},
+			Tags:        map[string]string{"payload": strings.Repeat("x", 200)},
+		})
+	}
+	if err := rep.(reporter.Flusher).Flush(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var messages int
+	requests := fake.Requests()
+	for _, req := range requests {
+		size := 0
+		for _, msg := range req.Messages {
+			size += len(msg.Data)
+		}
+		if len(req.Messages) > 1 && size > 1000 {
+			t.Errorf("request exceeds byte threshold: %d bytes", size)
+		}
+		messages += len(req.Messages)
+	}
+	if want, have := 10, messages; want != have {
+		t.Errorf("messages want %d, have %d", want, have)
+	}
+	if len(requests) < 3 {
+		t.Errorf("want at least 3 requests, have %d", len(requests))
+	}
+}
+
+func TestRetry(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		status   int
+		requests int
+		dropped  int
+		err      bool
+	}{
+		{"unavailable", http.StatusServiceUnavailable, 2, 0, false},
+		{"forbidden", http.StatusForbidden, 1, 1, true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			fake := &fakePubSub{status: func(attempt int) int {
+				if attempt == 1 {
+					return test.status
+				}
+				return http.StatusOK
+			}}
+			srv := httptest.NewServer(fake)
+			defer srv.Close()
+
+			var dropped int
+			rep, err := pubsub.NewReporter("project", "zipkin",
+				pubsub.Endpoint(srv.URL),
+				pubsub.TokenFunc(func() (string, error) { return "token", nil }),
+				pubsub.BatchInterval(time.Hour),
+				pubsub.Retry(3, time.Millisecond),
+				pubsub.OnDrop(func(model.SpanModel, error) { dropped++ }),
+			)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer rep.Close()
+
+			rep.Send(model.SpanModel{SpanContext: model.SpanContext{TraceID: model.TraceID{Low: 1}, ID: 1}})
+			if want, have := test.err, rep.(reporter.Flusher).Flush() != nil; want != have {
+				t.Errorf("error want %t, have %t", want, have)
+			}
+			if want, have := test.requests, len(fake.Requests()); want != have {
+				t.Errorf("requests want %d, have %d", want, have)
+			}
+			if want, have := test.dropped, dropped; want != have {
+				t.Errorf("dropped want %d, have %d", want, have)
+			}
+		})
+	}
+}
+
+func TestMetadataToken(t *testing.T) {
+	var tokenRequests int
+	metadata := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		tokenRequests++
+		_, _ = w.Write([]byte(`{"access_token":"metadata-token","expires_in":3600,"token_type":"Bearer"}`))
+	}))
+	defer metadata.Close()
+
+	for _, key := range []string{"GCE_METADATA_HOST", "PUBSUB_EMULATOR_HOST"} {
+		if value, ok := os.LookupEnv(key); ok {
+			defer os.Setenv(key, value)
+		} else {
+			defer os.Unsetenv(key)
+		}
+		os.Unsetenv(key)
+	}
+	os.Setenv("GCE_METADATA_HOST", strings.TrimPrefix(metadata.URL, "http://"))
+
+	fake := &fakePubSub{}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	rep, err := pubsub.NewReporter("project", "zipkin", pubsub.Endpoint(srv.URL), pubsub.BatchInterval(time.Hour))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer rep.Close()
+
+	for i := uint64(1); i <= 2; i++ {
+		rep.Send(model.SpanModel{SpanContext: model.SpanContext{TraceID: model.TraceID{Low: i}, ID: model.ID(i)}})
+		if err := rep.(reporter.Flusher).Flush(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	for _, auth := range fake.auth {
+		if want, have := "Bearer metadata-token", auth; want != have {
+			t.Errorf("authorization want %q, have %q", want, have)
+		}
+	}
+	if want, have := 1, tokenRequests; want != have {
+		t.Errorf("token requests want %d, have %d", want, have)
+	}
+}
+
+func TestInvalidTopic(t *testing.T) {
+	if _, err := pubsub.NewReporter("project", ""); err != pubsub.ErrInvalidTopic {
+		t.Errorf("want %v, have %v", pubsub.ErrInvalidTopic, err)
+	}
+}
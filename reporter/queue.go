@@ -0,0 +1,126 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reporter
+
+import "github.com/openzipkin/zipkin-go/model"
+
+// Queue buffers spans in buffered reporters until they are sent. Custom
+// implementations, e.g. disk backed or bounded by bytes, can be injected into
+// reporters supporting them. Reporters serialize access to the queue, so
+// implementations do not need to be safe for concurrent use.
+type Queue interface {
+	// Push adds a span to the queue and returns the spans disposed of to make
+	// room for it, if any.
+	Push(span *model.SpanModel) (dropped []*model.SpanModel)
+	// PopBatch removes and returns up to max spans from the queue.
+	PopBatch(max int) []*model.SpanModel
+	// Len returns the number of queued spans.
+	Len() int
+}
+
+// boundedQueue is a FIFO Queue disposing of the oldest spans when full.
+type boundedQueue struct {
+	spans   []*model.SpanModel
+	maxSize int
+}
+
+// NewBoundedQueue returns a FIFO Queue holding up to maxSize spans. When
+// full, the oldest spans are disposed of.
+func NewBoundedQueue(maxSize int) Queue {
+	return &boundedQueue{maxSize: maxSize}
+}
+
+func (q *boundedQueue) Push(span *model.SpanModel) (dropped []*model.SpanModel) {
+	q.spans = append(q.spans, span)
+	if len(q.spans) > q.maxSize {
+		dispose := len(q.spans) - q.maxSize
+		dropped = q.spans[:dispose:dispose]
+		q.spans = q.spans[dispose:]
+	}
+	return dropped
+}
+
+func (q *boundedQueue) PopBatch(max int) []*model.SpanModel {
+	n := len(q.spans)
+	if max < n {
+		n = max
+	}
+	batch := q.spans[:n:n]
+	q.spans = q.spans[n:]
+	return batch
+}
+
+func (q *boundedQueue) Len() int {
+	return len(q.spans)
+}
+
+// priorityQueue is a Queue with separate lanes for error and other spans.
+type priorityQueue struct {
+	errors  []*model.SpanModel
+	others  []*model.SpanModel
+	maxSize int
+}
+
+// NewPriorityQueue returns a Queue holding up to maxSize spans in two lanes.
+// Spans tagged with "error" are kept in a priority lane which is sent first and
+// only shed once no other spans remain to be disposed of, so error traces
+// survive overload while bulk spans are dropped.
+func NewPriorityQueue(maxSize int) Queue {
+	return &priorityQueue{maxSize: maxSize}
+}
+
+func (q *priorityQueue) Push(span *model.SpanModel) (dropped []*model.SpanModel) {
+	if _, isError := span.Tags["error"]; isError {
+		q.errors = append(q.errors, span)
+	} else {
+		q.others = append(q.others, span)
+	}
+	dispose := q.Len() - q.maxSize
+	if dispose <= 0 {
+		return nil
+	}
+	n := dispose
+	if n > len(q.others) {
+		n = len(q.others)
+	}
+	dropped = q.others[:n:n]
+	q.others = q.others[n:]
+	if dispose -= n; dispose > 0 {
+		dropped = append(dropped, q.errors[:dispose]...)
+		q.errors = q.errors[dispose:]
+	}
+	return dropped
+}
+
+func (q *priorityQueue) PopBatch(max int) []*model.SpanModel {
+	n := len(q.errors)
+	if max < n {
+		n = max
+	}
+	batch := q.errors[:n:n]
+	q.errors = q.errors[n:]
+	if max -= n; max > 0 {
+		if max > len(q.others) {
+			max = len(q.others)
+		}
+		batch = append(batch, q.others[:max]...)
+		q.others = q.others[max:]
+	}
+	return batch
+}
+
+func (q *priorityQueue) Len() int {
+	return len(q.errors) + len(q.others)
+}
@@ -0,0 +1,105 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reporter_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter"
+)
+
+func TestBoundedQueue(t *testing.T) {
+	spans := makeSpans(5)
+	q := reporter.NewBoundedQueue(3)
+
+	for _, span := range spans[:3] {
+		if dropped := q.Push(span); len(dropped) != 0 {
+			t.Errorf("unexpected dropped spans: %d", len(dropped))
+		}
+	}
+	dropped := q.Push(spans[3])
+	if want, have := 1, len(dropped); want != have {
+		t.Fatalf("dropped spans want %d, have %d", want, have)
+	}
+	if want, have := spans[0], dropped[0]; want != have {
+		t.Errorf("dropped span want %v, have %v", want.ID, have.ID)
+	}
+	if want, have := 3, q.Len(); want != have {
+		t.Errorf("queue length want %d, have %d", want, have)
+	}
+
+	batch := q.PopBatch(2)
+	if want, have := 2, len(batch); want != have {
+		t.Fatalf("batch size want %d, have %d", want, have)
+	}
+	if batch[0] != spans[1] || batch[1] != spans[2] {
+		t.Error("expected oldest spans in batch")
+	}
+
+	// appending to a popped batch must not overwrite queued spans
+	_ = append(batch, spans[4])
+	q.Push(spans[4])
+	if batch = q.PopBatch(10); len(batch) != 2 || batch[0] != spans[3] || batch[1] != spans[4] {
+		t.Errorf("unexpected batch: %v", batch)
+	}
+	if want, have := 0, q.Len(); want != have {
+		t.Errorf("queue length want %d, have %d", want, have)
+	}
+}
+
+func TestPriorityQueue(t *testing.T) {
+	spans := makeSpans(6)
+	for _, i := range []int{1, 3, 4} {
+		spans[i].Tags = map[string]string{"error": "500"}
+	}
+	q := reporter.NewPriorityQueue(3)
+
+	var dropped []*model.SpanModel
+	for _, span := range spans {
+		dropped = append(dropped, q.Push(span)...)
+	}
+	// ok spans are shed first, followed by the oldest error span
+	if want, have := []*model.SpanModel{spans[0], spans[2], spans[5]}, dropped; !reflect.DeepEqual(want, have) {
+		t.Errorf("dropped spans want %v, have %v", want, have)
+	}
+	if want, have := 3, q.Len(); want != have {
+		t.Errorf("queue length want %d, have %d", want, have)
+	}
+
+	dropped = q.Push(spans[0])
+	if want, have := []*model.SpanModel{spans[0]}, dropped; !reflect.DeepEqual(want, have) {
+		t.Errorf("dropped spans want %v, have %v", want, have)
+	}
+	dropped = q.Push(spans[1])
+	if want, have := []*model.SpanModel{spans[1]}, dropped; !reflect.DeepEqual(want, have) {
+		t.Errorf("dropped spans want %v, have %v", want, have)
+	}
+
+	q = reporter.NewPriorityQueue(10)
+	for _, span := range spans {
+		q.Push(span)
+	}
+	// error spans are sent first
+	want := []*model.SpanModel{spans[1], spans[3], spans[4], spans[0]}
+	if have := q.PopBatch(4); !reflect.DeepEqual(want, have) {
+		t.Errorf("batch want %v, have %v", want, have)
+	}
+	want = []*model.SpanModel{spans[2], spans[5]}
+	if have := q.PopBatch(4); !reflect.DeepEqual(want, have) {
+		t.Errorf("batch want %v, have %v", want, have)
+	}
+}
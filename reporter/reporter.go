@@ -21,7 +21,15 @@ reporter implementations.
 */
 package reporter
 
-import "github.com/openzipkin/zipkin-go/model"
+import (
+	"errors"
+
+	"github.com/openzipkin/zipkin-go/model"
+)
+
+// ErrQueueFull is the reason given to drop callbacks for spans which are
+// discarded as the reporter's internal queue or backlog is full.
+var ErrQueueFull = errors.New("reporter queue full")
 
 // Reporter interface can be used to provide the Zipkin Tracer with custom
 // implementations to publish Zipkin Span data.
@@ -30,12 +38,17 @@ type Reporter interface {
 	Close() error         // Close the reporter
 }
 
-type noopReporter struct{}
+// NoopReporter is a Reporter which discards all spans. The zero value is ready
+// to use.
+type NoopReporter struct{}
+
+// Send discards the span.
+func (NoopReporter) Send(model.SpanModel) {}
 
-func (r *noopReporter) Send(model.SpanModel) {}
-func (r *noopReporter) Close() error         { return nil }
+// Close does nothing.
+func (NoopReporter) Close() error { return nil }
 
 // NewNoopReporter returns a no-op Reporter implementation.
 func NewNoopReporter() Reporter {
-	return &noopReporter{}
+	return NoopReporter{}
 }
@@ -0,0 +1,51 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reporter
+
+import "github.com/openzipkin/zipkin-go/model"
+
+// samplingReporter downsamples spans before passing them to the next
+// reporter.
+type samplingReporter struct {
+	next    Reporter
+	sampler func(traceID uint64) bool
+}
+
+// NewSamplingReporter returns a Reporter which only sends spans to next if
+// sampler returns true for their trace id, decoupling the export rate from the
+// tracer's sampling rate. E.g. the tracer can sample all traces to feed local
+// span hooks while only a tenth of them leaves the process:
+//
+//	sampler, _ := zipkin.NewBoundarySampler(0.1, salt)
+//	rep := reporter.NewSamplingReporter(httpReporter, sampler)
+//
+// As the decision is based on the lower 64 bits of the trace id, a trace is
+// either exported in full or not at all, as long as sampler is consistent by
+// trace id. Spans of debug traces are always sent.
+func NewSamplingReporter(next Reporter, sampler func(traceID uint64) bool) Reporter {
+	return &samplingReporter{next: next, sampler: sampler}
+}
+
+// Send sends s to the next reporter if its trace is sampled.
+func (r *samplingReporter) Send(s model.SpanModel) {
+	if s.Debug || r.sampler(s.TraceID.Low) {
+		r.next.Send(s)
+	}
+}
+
+// Close closes the next reporter.
+func (r *samplingReporter) Close() error {
+	return r.next.Close()
+}
@@ -0,0 +1,47 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reporter_test
+
+import (
+	"testing"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter"
+	"github.com/openzipkin/zipkin-go/reporter/recorder"
+)
+
+func TestSamplingReporter(t *testing.T) {
+	rec := recorder.NewReporter()
+	rep := reporter.NewSamplingReporter(rec, func(traceID uint64) bool { return traceID%2 == 0 })
+	defer rep.Close()
+
+	for i := uint64(1); i <= 4; i++ {
+		// two spans of each trace
+		for j := 0; j < 2; j++ {
+			rep.Send(model.SpanModel{SpanContext: model.SpanContext{TraceID: model.TraceID{Low: i}, ID: model.ID(j + 1)}})
+		}
+	}
+	rep.Send(model.SpanModel{SpanContext: model.SpanContext{TraceID: model.TraceID{Low: 5}, ID: 1, Debug: true}})
+
+	spans := rec.Flush()
+	if want, have := 5, len(spans); want != have {
+		t.Fatalf("exported spans want %d, have %d", want, have)
+	}
+	for _, span := range spans {
+		if span.TraceID.Low%2 != 0 && !span.Debug {
+			t.Errorf("unexpected span of unsampled trace %s", span.TraceID)
+		}
+	}
+}
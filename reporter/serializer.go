@@ -15,11 +15,17 @@
 package reporter
 
 import (
+	"bytes"
 	"encoding/json"
+	"sort"
 
 	"github.com/openzipkin/zipkin-go/model"
 )
 
+// TypedTagsContentType is the content type of the JSON encoding with typed tag
+// values.
+const TypedTagsContentType = "application/x-zipkin-typed+json"
+
 // SpanSerializer describes the methods needed for allowing to set Span encoding
 // type for the various Zipkin transports.
 type SpanSerializer interface {
@@ -28,15 +34,83 @@ type SpanSerializer interface {
 }
 
 // JSONSerializer implements the default JSON encoding SpanSerializer.
-type JSONSerializer struct{}
+type JSONSerializer struct {
+	// TypedTags emits numeric and boolean tag values as native JSON numbers
+	// and booleans instead of strings, e.g. {"http.status_code": 200}. This
+	// is a non-standard extension for consumers wanting typed data which
+	// Zipkin collectors do not accept, so batches are sent with the
+	// TypedTagsContentType content type.
+	TypedTags bool
+}
 
 // Serialize takes an array of Zipkin SpanModel objects and returns a JSON
 // encoding of it.
-func (JSONSerializer) Serialize(spans []*model.SpanModel) ([]byte, error) {
-	return json.Marshal(spans)
+func (j JSONSerializer) Serialize(spans []*model.SpanModel) ([]byte, error) {
+	if !j.TypedTags {
+		return json.Marshal(spans)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	for i, span := range spans {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		s := *span
+		s.Tags = nil
+		b, err := json.Marshal(s)
+		if err != nil {
+			return nil, err
+		}
+		if len(span.Tags) == 0 {
+			buf.Write(b)
+			continue
+		}
+
+		keys := make([]string, 0, len(span.Tags))
+		for k := range span.Tags {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		buf.Write(b[:len(b)-1])
+		buf.WriteString(`,"tags":{`)
+		for i, k := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			key, _ := json.Marshal(k)
+			buf.Write(key)
+			buf.WriteByte(':')
+			if v := span.Tags[k]; isTypedValue(v) {
+				buf.WriteString(v)
+			} else {
+				value, _ := json.Marshal(v)
+				buf.Write(value)
+			}
+		}
+		buf.WriteString("}}")
+	}
+	buf.WriteByte(']')
+	return buf.Bytes(), nil
 }
 
 // ContentType returns the ContentType needed for this encoding.
-func (JSONSerializer) ContentType() string {
+func (j JSONSerializer) ContentType() string {
+	if j.TypedTags {
+		return TypedTagsContentType
+	}
 	return "application/json"
 }
+
+// isTypedValue reports whether a tag value can be emitted as a native JSON
+// boolean or number.
+func isTypedValue(v string) bool {
+	if v == "true" || v == "false" {
+		return true
+	}
+	if len(v) == 0 || (v[0] != '-' && (v[0] < '0' || v[0] > '9')) || v[len(v)-1] < '0' || v[len(v)-1] > '9' {
+		return false
+	}
+	return json.Valid([]byte(v))
+}
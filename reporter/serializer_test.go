@@ -0,0 +1,93 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reporter_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter"
+)
+
+func TestJSONSerializerTypedTags(t *testing.T) {
+	span := makeSpans(1)[0]
+	span.Tags = map[string]string{
+		"bool":    "true",
+		"int":     "200",
+		"float":   "-1.5e3",
+		"zeros":   "007",
+		"text":    "GET",
+		"spaced":  " 1",
+		"quoted":  `"a"`,
+		"trailer": "1.",
+	}
+
+	serializer := reporter.JSONSerializer{TypedTags: true}
+	if want, have := reporter.TypedTagsContentType, serializer.ContentType(); want != have {
+		t.Errorf("content type want %q, have %q", want, have)
+	}
+	if want, have := "application/json", (reporter.JSONSerializer{}).ContentType(); want != have {
+		t.Errorf("content type want %q, have %q", want, have)
+	}
+
+	b, err := serializer.Serialize([]*model.SpanModel{span, makeSpans(1)[0]})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var spans []struct {
+		ID   string                 `json:"id"`
+		Tags map[string]interface{} `json:"tags"`
+	}
+	if err := json.Unmarshal(b, &spans); err != nil {
+		t.Fatalf("unexpected error: %v\n%s", err, b)
+	}
+	if want, have := 2, len(spans); want != have {
+		t.Fatalf("spans want %d, have %d", want, have)
+	}
+	if want, have := span.ID.String(), spans[0].ID; want != have {
+		t.Errorf("id want %q, have %q", want, have)
+	}
+
+	want := map[string]interface{}{
+		"bool":    true,
+		"int":     200.0,
+		"float":   -1500.0,
+		"zeros":   "007",
+		"text":    "GET",
+		"spaced":  " 1",
+		"quoted":  `"a"`,
+		"trailer": "1.",
+	}
+	for k, v := range want {
+		if have := spans[0].Tags[k]; have != v {
+			t.Errorf("tag %q want %#v, have %#v", k, v, have)
+		}
+	}
+
+	// the default serializer emits string values
+	b, err = reporter.JSONSerializer{}.Serialize([]*model.SpanModel{span})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var have []model.SpanModel
+	if err := json.Unmarshal(b, &have); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want, have := "200", have[0].Tags["int"]; want != have {
+		t.Errorf("tag want %q, have %q", want, have)
+	}
+}
@@ -0,0 +1,463 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package spill implements a reporter wrapper buffering spans on disk while the
+wrapped reporter can not deliver them, e.g. during outages of intermittent
+connections to the collector, and replaying them once it can.
+
+Spans are spilled to disk while the Available check reports the wrapped
+reporter as unavailable and when they are passed to Spill, e.g. from the drop
+callback of the wrapped reporter:
+
+	var buffer spill.Reporter
+	rep := zipkinhttp.NewReporter(url, zipkinhttp.OnDrop(
+		func(span model.SpanModel, reason error) { buffer.Spill(span) },
+	))
+	buffer, err := spill.NewReporter(rep, "/var/spool/zipkin")
+
+Spilled spans are stored as newline delimited JSON in segment files of the
+directory, so they survive restarts of the process, and are replayed in
+batches to the wrapped reporter. Delivery is at least once: spans which were
+replayed from a segment not yet fully consumed before a restart are replayed
+again.
+*/
+package spill
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter"
+)
+
+// defaults for the spill buffer
+const (
+	defaultMaxSize        = 64 << 20
+	defaultSegmentSize    = 1 << 20
+	defaultReplayInterval = time.Second
+	defaultReplayBatch    = 500
+)
+
+// segmentExt is the file name extension of segment files.
+const segmentExt = ".spill"
+
+// Reporter is a reporter.Reporter buffering spans on disk.
+type Reporter interface {
+	reporter.Reporter
+	// Spill writes span to disk to be replayed to the wrapped reporter later.
+	Spill(span model.SpanModel)
+	// Spilled returns the number of spans buffered on disk.
+	Spilled() int
+}
+
+// segment is a file holding spilled spans.
+type segment struct {
+	seq   uint64
+	path  string
+	size  int64
+	spans int
+	read  int
+}
+
+// spillReporter wraps a reporter, spilling spans to disk.
+type spillReporter struct {
+	next           reporter.Reporter
+	dir            string
+	maxSize        int64
+	segmentSize    int64
+	available      func() bool
+	replayInterval time.Duration
+	replayBatch    int
+	onDiscard      func(count int)
+	logger         reporter.Logger
+
+	mtx      sync.Mutex
+	segments []*segment
+	size     int64
+	writer   *os.File
+	reader   *bufio.Reader
+	readFile *os.File
+	closed   bool
+
+	quit chan struct{}
+	done chan struct{}
+}
+
+// ReporterOption sets a parameter for the spill reporter.
+type ReporterOption func(r *spillReporter)
+
+// MaxSize sets the maximum number of bytes of spans held on disk. When
+// exceeded, the oldest segments are discarded. Defaults to 64 MiB.
+func MaxSize(bytes int64) ReporterOption {
+	return func(r *spillReporter) { r.maxSize = bytes }
+}
+
+// SegmentSize sets the size in bytes from which a new segment file is started.
+// It is capped at a quarter of MaxSize. Defaults to 1 MiB.
+func SegmentSize(bytes int64) ReporterOption {
+	return func(r *spillReporter) { r.segmentSize = bytes }
+}
+
+// Available sets the function reporting whether the wrapped reporter can
+// deliver spans, e.g. by checking the connectivity to the collector. While it
+// returns false spans are spilled to disk instead of sent to the wrapped
+// reporter and replay is paused. By default the wrapped reporter is always
+// considered available and only spans passed to Spill are buffered.
+func Available(fn func() bool) ReporterOption {
+	return func(r *spillReporter) { r.available = fn }
+}
+
+// Replay sets the interval at which spilled spans are replayed to the wrapped
+// reporter and the maximum number of spans replayed per interval, limiting the
+// load on the wrapped reporter and the collector after an outage. Defaults to
+// 500 spans per second.
+func Replay(interval time.Duration, batch int) ReporterOption {
+	return func(r *spillReporter) {
+		if interval > 0 {
+			r.replayInterval = interval
+		}
+		if batch > 0 {
+			r.replayBatch = batch
+		}
+	}
+}
+
+// OnDiscard registers a callback function which is invoked with the number of
+// spilled spans discarded to keep the buffer within MaxSize or as they can not
+// be written to disk.
+func OnDiscard(fn func(count int)) ReporterOption {
+	return func(r *spillReporter) { r.onDiscard = fn }
+}
+
+// Logger sets the logger used to report errors in the spilling process. It
+// accepts a *log.Logger or any other reporter.Logger.
+func Logger(l reporter.Logger) ReporterOption {
+	return func(r *spillReporter) { r.logger = l }
+}
+
+// NewReporter returns a new Reporter sending spans to next and spilling them
+// to segment files in dir, which is created if needed. Spans left in dir by a
+// previous process are replayed.
+func NewReporter(next reporter.Reporter, dir string, options ...ReporterOption) (Reporter, error) {
+	r := &spillReporter{
+		next:           next,
+		dir:            dir,
+		maxSize:        defaultMaxSize,
+		segmentSize:    defaultSegmentSize,
+		replayInterval: defaultReplayInterval,
+		replayBatch:    defaultReplayBatch,
+		logger:         log.New(os.Stderr, "", log.LstdFlags),
+		quit:           make(chan struct{}),
+		done:           make(chan struct{}),
+	}
+	for _, option := range options {
+		option(r)
+	}
+	if max := r.maxSize / 4; r.segmentSize > max {
+		r.segmentSize = max
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+
+	go r.loop()
+	return r, nil
+}
+
+// load registers the segment files found in the directory.
+func (r *spillReporter) load() error {
+	files, err := ioutil.ReadDir(r.dir)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		seg := &segment{seq: seq, path: filepath.Join(r.dir, name), size: file.Size()}
+		if seg.spans, err = countLines(seg.path); err != nil {
+			return err
+		}
+		r.segments = append(r.segments, seg)
+		r.size += seg.size
+	}
+	sort.Slice(r.segments, func(i, j int) bool {
+		return r.segments[i].seq < r.segments[j].seq
+	})
+	return nil
+}
+
+// countLines returns the number of spans held by the segment file at path.
+func countLines(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var (
+		n   int
+		buf = make([]byte, 32<<10)
+	)
+	for {
+		c, err := f.Read(buf)
+		for _, b := range buf[:c] {
+			if b == '\n' {
+				n++
+			}
+		}
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+	}
+}
+
+// Send sends s to the wrapped reporter, or spills it if the wrapped reporter
+// is unavailable.
+func (r *spillReporter) Send(s model.SpanModel) {
+	if r.available != nil && !r.available() {
+		r.Spill(s)
+		return
+	}
+	r.next.Send(s)
+}
+
+// Spill writes s to disk to be replayed later.
+func (r *spillReporter) Spill(s model.SpanModel) {
+	b, err := json.Marshal(s)
+	if err != nil {
+		r.logger.Printf("failed to spill span: %+v\n", err)
+		r.discard(1)
+		return
+	}
+	b = append(b, '\n')
+
+	r.mtx.Lock()
+	if r.closed {
+		r.mtx.Unlock()
+		r.discard(1)
+		return
+	}
+	err = r.write(b)
+	discarded := r.enforceMaxSize()
+	r.mtx.Unlock()
+
+	if err != nil {
+		r.logger.Printf("failed to spill span: %+v\n", err)
+		discarded++
+	}
+	r.discard(discarded)
+}
+
+// write appends the encoded span b to the current segment, starting a new one
+// if needed.
+func (r *spillReporter) write(b []byte) error {
+	var seg *segment
+	if r.writer != nil {
+		seg = r.segments[len(r.segments)-1]
+	}
+	if seg == nil || seg.size >= r.segmentSize {
+		if err := r.rotate(); err != nil {
+			return err
+		}
+		var seq uint64
+		if len(r.segments) > 0 {
+			seq = r.segments[len(r.segments)-1].seq + 1
+		}
+		seg = &segment{seq: seq, path: filepath.Join(r.dir, fmt.Sprintf("%020d%s", seq, segmentExt))}
+		f, err := os.OpenFile(seg.path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		if err != nil {
+			return err
+		}
+		r.writer = f
+		r.segments = append(r.segments, seg)
+	}
+
+	n, err := r.writer.Write(b)
+	seg.size += int64(n)
+	r.size += int64(n)
+	if err != nil {
+		return err
+	}
+	seg.spans++
+	return nil
+}
+
+// rotate closes the segment being written, if any.
+func (r *spillReporter) rotate() error {
+	if r.writer == nil {
+		return nil
+	}
+	err := r.writer.Close()
+	r.writer = nil
+	return err
+}
+
+// enforceMaxSize removes the oldest segments while the buffer exceeds its
+// maximum size and returns the number of unreplayed spans discarded.
+func (r *spillReporter) enforceMaxSize() (discarded int) {
+	for r.size > r.maxSize && len(r.segments) > 1 {
+		discarded += r.segments[0].spans - r.segments[0].read
+		r.removeHead()
+	}
+	return discarded
+}
+
+// removeHead removes the oldest segment.
+func (r *spillReporter) removeHead() {
+	seg := r.segments[0]
+	if r.readFile != nil {
+		_ = r.readFile.Close()
+		r.readFile, r.reader = nil, nil
+	}
+	if err := os.Remove(seg.path); err != nil && !os.IsNotExist(err) {
+		r.logger.Printf("failed to remove spill segment: %+v\n", err)
+	}
+	r.size -= seg.size
+	r.segments = r.segments[1:]
+}
+
+func (r *spillReporter) discard(count int) {
+	if count > 0 && r.onDiscard != nil {
+		r.onDiscard(count)
+	}
+}
+
+// Spilled returns the number of spans buffered on disk.
+func (r *spillReporter) Spilled() int {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	var n int
+	for _, seg := range r.segments {
+		n += seg.spans - seg.read
+	}
+	return n
+}
+
+func (r *spillReporter) loop() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.replayInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if r.available != nil && !r.available() {
+				continue
+			}
+			for _, span := range r.readBatch() {
+				r.next.Send(span)
+			}
+		case <-r.quit:
+			return
+		}
+	}
+}
+
+// readBatch reads up to replayBatch spilled spans, removing the segments
+// which are fully consumed.
+func (r *spillReporter) readBatch() []model.SpanModel {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	var spans []model.SpanModel
+	for len(spans) < r.replayBatch && len(r.segments) > 0 {
+		seg := r.segments[0]
+		if len(r.segments) == 1 && r.writer != nil {
+			if seg.read == seg.spans {
+				break
+			}
+			// seal the segment being written so it can be consumed
+			if err := r.rotate(); err != nil {
+				r.logger.Printf("failed to close spill segment: %+v\n", err)
+			}
+		}
+		if r.reader == nil {
+			f, err := os.Open(seg.path)
+			if err != nil {
+				r.logger.Printf("failed to open spill segment: %+v\n", err)
+				r.removeHead()
+				continue
+			}
+			r.readFile, r.reader = f, bufio.NewReader(f)
+		}
+
+		line, err := r.reader.ReadBytes('\n')
+		if err != nil {
+			if err != io.EOF {
+				r.logger.Printf("failed to read spill segment: %+v\n", err)
+			}
+			r.removeHead()
+			continue
+		}
+		seg.read++
+
+		var span model.SpanModel
+		if err := json.Unmarshal(line, &span); err != nil {
+			r.logger.Printf("failed to decode spilled span: %+v\n", err)
+			continue
+		}
+		spans = append(spans, span)
+	}
+	return spans
+}
+
+// Close stops replaying spans and closes the wrapped reporter. Spans dropped
+// by the wrapped reporter while closing are still spilled. Spilled spans not
+// yet replayed are kept on disk.
+func (r *spillReporter) Close() error {
+	close(r.quit)
+	<-r.done
+
+	err := r.next.Close()
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.closed = true
+	if r.readFile != nil {
+		_ = r.readFile.Close()
+		r.readFile, r.reader = nil, nil
+	}
+	if rerr := r.rotate(); err == nil {
+		err = rerr
+	}
+	return err
+}
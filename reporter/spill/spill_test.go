@@ -0,0 +1,191 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spill_test
+
+import (
+	"io/ioutil"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter/recorder"
+	"github.com/openzipkin/zipkin-go/reporter/spill"
+)
+
+func newSpan(i int) model.SpanModel {
+	return model.SpanModel{
+		SpanContext: model.SpanContext{
+			TraceID: model.TraceID{Low: uint64(i + 1)},
+			ID:      model.ID(i + 1),
+		},
+		Name:      "span-" + strconv.Itoa(i),
+		Timestamp: time.Now(),
+		Duration:  time.Millisecond,
+	}
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "zipkin-spill")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// waitFor waits until have returns want or a second has passed and returns
+// the last value.
+func waitFor(want int, have func() int) int {
+	deadline := time.Now().Add(time.Second)
+	n := have()
+	for n != want && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+		n = have()
+	}
+	return n
+}
+
+func TestSpillWhileUnavailable(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	var (
+		available int32
+		rec       = recorder.NewReporter()
+		received  []model.SpanModel
+	)
+	rep, err := spill.NewReporter(rec, dir,
+		spill.Available(func() bool { return atomic.LoadInt32(&available) == 1 }),
+		spill.Replay(10*time.Millisecond, 2),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rep.Close()
+
+	for i := 0; i < 5; i++ {
+		rep.Send(newSpan(i))
+	}
+	if want, have := 5, rep.Spilled(); want != have {
+		t.Errorf("spilled want %d, have %d", want, have)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if want, have := 0, len(rec.Flush()); want != have {
+		t.Fatalf("spans sent while unavailable want %d, have %d", want, have)
+	}
+
+	atomic.StoreInt32(&available, 1)
+	if want, have := 5, waitFor(5, func() int {
+		received = append(received, rec.Flush()...)
+		return len(received)
+	}); want != have {
+		t.Fatalf("replayed spans want %d, have %d", want, have)
+	}
+	for i, span := range received {
+		if want, have := newSpan(i).Name, span.Name; want != have {
+			t.Errorf("span %d name want %q, have %q", i, want, have)
+		}
+	}
+	if want, have := 0, rep.Spilled(); want != have {
+		t.Errorf("spilled want %d, have %d", want, have)
+	}
+
+	rep.Send(newSpan(5))
+	if want, have := 1, len(rec.Flush()); want != have {
+		t.Errorf("spans sent while available want %d, have %d", want, have)
+	}
+}
+
+func TestSpillPersistence(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	rep, err := spill.NewReporter(recorder.NewReporter(), dir,
+		spill.Available(func() bool { return false }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		rep.Send(newSpan(i))
+	}
+	if err := rep.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := recorder.NewReporter()
+	rep, err = spill.NewReporter(rec, dir, spill.Replay(10*time.Millisecond, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rep.Close()
+
+	if want, have := 3, rep.Spilled(); want != have {
+		t.Errorf("spilled want %d, have %d", want, have)
+	}
+	var received []model.SpanModel
+	if want, have := 3, waitFor(3, func() int {
+		received = append(received, rec.Flush()...)
+		return len(received)
+	}); want != have {
+		t.Fatalf("replayed spans want %d, have %d", want, have)
+	}
+	if want, have := newSpan(0).Name, received[0].Name; want != have {
+		t.Errorf("span name want %q, have %q", want, have)
+	}
+	if want, have := 0, waitFor(0, rep.Spilled); want != have {
+		t.Errorf("spilled want %d, have %d", want, have)
+	}
+}
+
+func TestSpillMaxSize(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	var discarded int32
+	rep, err := spill.NewReporter(recorder.NewReporter(), dir,
+		spill.Available(func() bool { return false }),
+		spill.MaxSize(4096),
+		spill.OnDiscard(func(count int) { atomic.AddInt32(&discarded, int32(count)) }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rep.Close()
+
+	const n = 200
+	for i := 0; i < n; i++ {
+		rep.Spill(newSpan(i))
+	}
+
+	spilled := rep.Spilled()
+	if spilled == 0 || spilled == n {
+		t.Fatalf("expected part of the spans to be spilled, have %d", spilled)
+	}
+	if want, have := n, spilled+int(atomic.LoadInt32(&discarded)); want != have {
+		t.Errorf("spilled and discarded spans want %d, have %d", want, have)
+	}
+
+	var size int64
+	files, _ := ioutil.ReadDir(dir)
+	for _, file := range files {
+		size += file.Size()
+	}
+	if size > 4096+1024 {
+		t.Errorf("expected spill files to be bounded by max size, have %d bytes", size)
+	}
+}
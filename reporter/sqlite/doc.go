@@ -0,0 +1,34 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package sqlite implements a reporter which stores spans in a SQLite database
+for offline analysis, e.g. of the traces captured during a load test.
+
+The reporter works on a *sql.DB so it can be used with any SQLite driver:
+
+	import _ "github.com/mattn/go-sqlite3"
+
+	db, err := sql.Open("sqlite3", "traces.db")
+	if err != nil {
+		log.Fatal(err)
+	}
+	rep, err := sqlite.NewReporter(db)
+
+Spans are stored in a table with indexed trace_id, name and duration columns
+next to the full JSON encoded span, allowing queries like:
+
+	SELECT name, avg(duration) FROM spans GROUP BY name ORDER BY 2 DESC;
+*/
+package sqlite
@@ -0,0 +1,60 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/openzipkin/zipkin-go/model"
+)
+
+// Query runs the provided SQL query and decodes the resulting rows into spans.
+// The query must select the span column, e.g.:
+//
+//	spans, err := sqlite.Query(db, "SELECT span FROM spans WHERE duration > ?", 500000)
+func Query(db *sql.DB, query string, args ...interface{}) ([]model.SpanModel, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var spans []model.SpanModel
+	for rows.Next() {
+		var (
+			b    []byte
+			span model.SpanModel
+		)
+		if err = rows.Scan(&b); err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal(b, &span); err != nil {
+			return nil, err
+		}
+		spans = append(spans, span)
+	}
+	return spans, rows.Err()
+}
+
+// Trace returns all spans of a trace stored in the default table, ordered by
+// timestamp.
+func Trace(db *sql.DB, traceID model.TraceID) ([]model.SpanModel, error) {
+	return Query(
+		db,
+		"SELECT span FROM "+defaultTable+" WHERE trace_id = ? ORDER BY timestamp",
+		traceID.String(),
+	)
+}
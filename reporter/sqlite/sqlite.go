@@ -0,0 +1,273 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter"
+)
+
+// defaults
+const (
+	defaultTable         = "spans"
+	defaultBatchInterval = time.Second * 1
+	defaultBatchSize     = 100
+)
+
+// ErrInvalidTable is returned when the table name is not a valid identifier.
+var ErrInvalidTable = errors.New("invalid table name")
+
+var validTable = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// sqliteReporter stores spans in a SQLite database.
+type sqliteReporter struct {
+	db            *sql.DB
+	table         string
+	logger        *log.Logger
+	batchInterval time.Duration
+	batchSize     int
+	spanC         chan *model.SpanModel
+	quit          chan struct{}
+	shutdown      chan error
+	closeOnce     sync.Once
+	closeErr      error
+}
+
+// ReporterOption sets a parameter for the SQLite Reporter
+type ReporterOption func(r *sqliteReporter)
+
+// Table sets the name of the table to store spans in. The default table name
+// is "spans".
+func Table(name string) ReporterOption {
+	return func(r *sqliteReporter) { r.table = name }
+}
+
+// BatchSize sets the maximum number of spans inserted within a single
+// transaction. The default batch size is 100 spans.
+func BatchSize(n int) ReporterOption {
+	return func(r *sqliteReporter) { r.batchSize = n }
+}
+
+// BatchInterval sets the maximum duration we will buffer spans before
+// inserting them. The default batch interval is 1 second.
+func BatchInterval(d time.Duration) ReporterOption {
+	return func(r *sqliteReporter) { r.batchInterval = d }
+}
+
+// Logger sets the logger used to report errors in the collection
+// process.
+func Logger(l *log.Logger) ReporterOption {
+	return func(r *sqliteReporter) { r.logger = l }
+}
+
+// NewReporter returns a new SQLite Reporter storing spans in db. The table and
+// its indexes are created if they do not exist yet.
+func NewReporter(db *sql.DB, opts ...ReporterOption) (reporter.Reporter, error) {
+	r := &sqliteReporter{
+		db:            db,
+		table:         defaultTable,
+		logger:        log.New(os.Stderr, "", log.LstdFlags),
+		batchInterval: defaultBatchInterval,
+		batchSize:     defaultBatchSize,
+		quit:          make(chan struct{}),
+		shutdown:      make(chan error, 1),
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	if !validTable.MatchString(r.table) {
+		return nil, ErrInvalidTable
+	}
+
+	if err := createSchema(db, r.table); err != nil {
+		return nil, err
+	}
+
+	r.spanC = make(chan *model.SpanModel, r.batchSize)
+
+	go r.loop()
+
+	return r, nil
+}
+
+func createSchema(db *sql.DB, table string) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS ` + table + ` (
+			trace_id       TEXT NOT NULL,
+			id             TEXT NOT NULL,
+			parent_id      TEXT,
+			name           TEXT,
+			kind           TEXT,
+			local_service  TEXT,
+			remote_service TEXT,
+			timestamp      INTEGER,
+			duration       INTEGER,
+			error          TEXT,
+			span           TEXT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS ` + table + `_trace_id ON ` + table + ` (trace_id)`,
+		`CREATE INDEX IF NOT EXISTS ` + table + `_name ON ` + table + ` (name)`,
+		`CREATE INDEX IF NOT EXISTS ` + table + `_duration ON ` + table + ` (duration)`,
+	}
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Send implements reporter
+func (r *sqliteReporter) Send(s model.SpanModel) {
+	r.spanC <- &s
+}
+
+// Close implements reporter
+func (r *sqliteReporter) Close() error {
+	r.closeOnce.Do(func() {
+		close(r.quit)
+		r.closeErr = <-r.shutdown
+	})
+	return r.closeErr
+}
+
+func (r *sqliteReporter) loop() {
+	var (
+		batch  = make([]*model.SpanModel, 0, r.batchSize)
+		ticker = time.NewTicker(r.batchInterval)
+	)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case span := <-r.spanC:
+			batch = append(batch, span)
+			if len(batch) >= r.batchSize {
+				_ = r.insert(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			_ = r.insert(batch)
+			batch = batch[:0]
+		case <-r.quit:
+			// drain spans sent before Close was called
+			for {
+				select {
+				case span := <-r.spanC:
+					batch = append(batch, span)
+				default:
+					r.shutdown <- r.insert(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+func (r *sqliteReporter) insert(batch []*model.SpanModel) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	err := r.insertTx(batch)
+	if err != nil {
+		r.logger.Printf("failed to insert %d spans: %s\n", len(batch), err.Error())
+	}
+	return err
+}
+
+func (r *sqliteReporter) insertTx(batch []*model.SpanModel) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare(`INSERT INTO ` + r.table + ` (
+		trace_id, id, parent_id, name, kind, local_service, remote_service,
+		timestamp, duration, error, span
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+
+	for _, span := range batch {
+		b, err := json.Marshal(span)
+		if err != nil {
+			// skip spans which can not be serialized
+			r.logger.Printf("failed when marshalling the span: %s\n", err.Error())
+			continue
+		}
+		if _, err = stmt.Exec(columns(span, b)...); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("failed to insert span: %s", err.Error())
+		}
+	}
+
+	return tx.Commit()
+}
+
+// columns returns the values for the indexed columns of a span. Timestamps and
+// durations are stored in microseconds as used by Zipkin.
+func columns(span *model.SpanModel, b []byte) []interface{} {
+	var (
+		parentID      interface{}
+		localService  interface{}
+		remoteService interface{}
+		timestamp     interface{}
+		errorTag      interface{}
+	)
+	if span.ParentID != nil {
+		parentID = span.ParentID.String()
+	}
+	if span.LocalEndpoint != nil && span.LocalEndpoint.ServiceName != "" {
+		localService = span.LocalEndpoint.ServiceName
+	}
+	if span.RemoteEndpoint != nil && span.RemoteEndpoint.ServiceName != "" {
+		remoteService = span.RemoteEndpoint.ServiceName
+	}
+	if !span.Timestamp.IsZero() {
+		timestamp = span.Timestamp.UnixNano() / 1e3
+	}
+	if e, ok := span.Tags["error"]; ok {
+		errorTag = e
+	}
+	return []interface{}{
+		span.TraceID.String(),
+		span.ID.String(),
+		parentID,
+		span.Name,
+		string(span.Kind),
+		localService,
+		remoteService,
+		timestamp,
+		span.Duration.Nanoseconds() / 1e3,
+		errorTag,
+		string(b),
+	}
+}
@@ -0,0 +1,187 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlite_test
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter/sqlite"
+)
+
+// fakeDriver is a minimal database/sql driver which records inserted rows and
+// answers queries filtering on the first column by returning the span column.
+type fakeDriver struct {
+	mtx        sync.Mutex
+	statements []string
+	rows       [][]driver.Value
+}
+
+func (d *fakeDriver) Open(string) (driver.Conn, error) { return &fakeConn{d: d}, nil }
+
+type fakeConn struct{ d *fakeDriver }
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{d: c.d, query: query}, nil
+}
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return c, nil }
+func (c *fakeConn) Commit() error             { return nil }
+func (c *fakeConn) Rollback() error           { return nil }
+
+type fakeStmt struct {
+	d     *fakeDriver
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.d.mtx.Lock()
+	defer s.d.mtx.Unlock()
+	s.d.statements = append(s.d.statements, s.query)
+	if strings.HasPrefix(strings.TrimSpace(s.query), "INSERT") {
+		s.d.rows = append(s.d.rows, args)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.d.mtx.Lock()
+	defer s.d.mtx.Unlock()
+	rows := &fakeRows{}
+	for _, row := range s.d.rows {
+		if len(args) == 0 || row[0] == args[0] {
+			rows.values = append(rows.values, row[len(row)-1])
+		}
+	}
+	return rows, nil
+}
+
+type fakeRows struct{ values []driver.Value }
+
+func (r *fakeRows) Columns() []string { return []string{"span"} }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	dest[0], r.values = r.values[0], r.values[1:]
+	return nil
+}
+
+var driverCount int
+
+func openDB(t *testing.T) (*sql.DB, *fakeDriver) {
+	d := &fakeDriver{}
+	driverCount++
+	name := "fake-sqlite-" + string(rune('a'+driverCount))
+	sql.Register(name, d)
+	db, err := sql.Open(name, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	return db, d
+}
+
+func makeSpan(traceID uint64, id model.ID, name string, duration time.Duration) model.SpanModel {
+	return model.SpanModel{
+		SpanContext: model.SpanContext{
+			TraceID: model.TraceID{Low: traceID},
+			ID:      id,
+		},
+		Name:      name,
+		Kind:      model.Server,
+		Timestamp: time.Unix(1500000000, 0),
+		Duration:  duration,
+		LocalEndpoint: &model.Endpoint{
+			ServiceName: "svc",
+		},
+		Tags: map[string]string{"error": "boom"},
+	}
+}
+
+func TestSQLiteReporter(t *testing.T) {
+	db, d := openDB(t)
+
+	rep, err := sqlite.NewReporter(db, sqlite.BatchSize(2), sqlite.BatchInterval(time.Hour))
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+
+	if want, have := 4, len(d.statements); want != have {
+		t.Errorf("schema statements want %d, have %d", want, have)
+	}
+
+	rep.Send(makeSpan(1, 1, "a", 2*time.Millisecond))
+	rep.Send(makeSpan(1, 2, "b", 3*time.Millisecond))
+	rep.Send(makeSpan(2, 3, "c", 4*time.Millisecond))
+
+	if err = rep.Close(); err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	// closing again must not block
+	if err = rep.Close(); err != nil {
+		t.Fatalf("unexpected error on second close: %+v", err)
+	}
+
+	d.mtx.Lock()
+	rows := d.rows
+	d.mtx.Unlock()
+
+	if want, have := 3, len(rows); want != have {
+		t.Fatalf("rows want %d, have %d", want, have)
+	}
+
+	row := rows[0]
+	expected := []driver.Value{
+		"0000000000000001", "0000000000000001", nil, "a", "SERVER", "svc", nil,
+		int64(1500000000000000), int64(2000), "boom",
+	}
+	for i, want := range expected {
+		if have := row[i]; want != have {
+			t.Errorf("column %d want %v, have %v", i, want, have)
+		}
+	}
+
+	spans, err := sqlite.Trace(db, model.TraceID{Low: 1})
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	if want, have := 2, len(spans); want != have {
+		t.Fatalf("spans want %d, have %d", want, have)
+	}
+	if want, have := "b", spans[1].Name; want != have {
+		t.Errorf("span name want %q, have %q", want, have)
+	}
+	if want, have := 3*time.Millisecond, spans[1].Duration; want != have {
+		t.Errorf("span duration want %s, have %s", want, have)
+	}
+}
+
+func TestInvalidTable(t *testing.T) {
+	db, _ := openDB(t)
+
+	if _, err := sqlite.NewReporter(db, sqlite.Table("spans; DROP TABLE x")); err != sqlite.ErrInvalidTable {
+		t.Errorf("want %v, have %v", sqlite.ErrInvalidTable, err)
+	}
+}
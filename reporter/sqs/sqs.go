@@ -0,0 +1,488 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package sqs implements a reporter to send spans to an AWS SQS queue, e.g.
+consumed by the SQS collector of zipkin-aws.
+
+Every message holds a list of spans in the format of the serializer, JSON by
+default, with the content type as message attribute. Binary formats are base64
+encoded as message bodies need to be text. Messages are sent using
+SendMessageBatch calls signed with AWS Signature Version 4 without depending on
+the AWS SDK; credentials default to the AWS_ACCESS_KEY_ID,
+AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment variables.
+*/
+package sqs
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter"
+	"github.com/openzipkin/zipkin-go/reporter/internal/batch"
+	"github.com/openzipkin/zipkin-go/reporter/internal/sigv4"
+)
+
+// defaults
+const (
+	defaultTimeout       = time.Second * 5 // timeout for the SendMessageBatch call
+	defaultBatchInterval = time.Second * 1 // BatchInterval in seconds
+	defaultBatchSize     = 100
+	defaultMaxBacklog    = 1000
+	defaultRetryAttempts = 3
+	defaultRetryBackoff  = 100 * time.Millisecond
+)
+
+// SQS limits
+const (
+	maxEntriesPerRequest = 10
+	maxPayloadSize       = 256 << 10
+)
+
+// Errors returned or reported by the SQS reporter.
+var (
+	ErrInvalidQueueURL    = errors.New("sqs: invalid queue url")
+	ErrMissingRegion      = errors.New("sqs: region required")
+	ErrMissingCredentials = errors.New("sqs: credentials required")
+	ErrMessageTooLarge    = errors.New("sqs: message too large")
+)
+
+// Credentials are AWS security credentials.
+type Credentials = sigv4.Credentials
+
+// message is an SQS message and the spans it holds.
+type message struct {
+	body  string
+	spans []*model.SpanModel
+}
+
+// sqsReporter will send spans to an SQS queue.
+type sqsReporter struct {
+	queueURL      string
+	region        string
+	endpoint      string
+	credentials   func() (Credentials, error)
+	client        *http.Client
+	retryAttempts int
+	retryBackoff  time.Duration
+	logger        reporter.Logger
+	batchInterval time.Duration
+	batchSize     int
+	maxBacklog    int
+	serializer    reporter.SpanSerializer
+	onDrop        func(model.SpanModel, error)
+	batcher       *batch.Batcher
+}
+
+// ReporterOption sets a parameter for the SQS Reporter.
+type ReporterOption func(r *sqsReporter)
+
+// Region sets the AWS region of the queue. By default it is derived from the
+// queue url, falling back to the AWS_REGION environment variable.
+func Region(region string) ReporterOption {
+	return func(r *sqsReporter) { r.region = region }
+}
+
+// Endpoint sets the SQS API endpoint, e.g. for VPC endpoints or local
+// emulators. By default the scheme and host of the queue url are used.
+func Endpoint(url string) ReporterOption {
+	return func(r *sqsReporter) { r.endpoint = url }
+}
+
+// StaticCredentials sets the credentials used to sign requests.
+func StaticCredentials(accessKeyID, secretAccessKey, sessionToken string) ReporterOption {
+	return func(r *sqsReporter) {
+		creds := Credentials{
+			AccessKeyID:     accessKeyID,
+			SecretAccessKey: secretAccessKey,
+			SessionToken:    sessionToken,
+		}
+		r.credentials = func() (Credentials, error) { return creds, nil }
+	}
+}
+
+// CredentialsFunc sets the function returning the credentials used to sign
+// requests, e.g. temporary credentials of an IAM role retrieved and refreshed
+// by the AWS SDK. It is invoked for every request.
+func CredentialsFunc(fn func() (Credentials, error)) ReporterOption {
+	return func(r *sqsReporter) { r.credentials = fn }
+}
+
+// Client sets a custom http client to use.
+func Client(client *http.Client) ReporterOption {
+	return func(r *sqsReporter) { r.client = client }
+}
+
+// Retry sets the number of attempts for messages failing due to throttling or
+// service errors, waiting an exponential backoff starting at backoff between
+// attempts. By default messages are attempted 3 times starting with a backoff
+// of 100ms.
+func Retry(attempts int, backoff time.Duration) ReporterOption {
+	return func(r *sqsReporter) {
+		r.retryAttempts = attempts
+		if backoff > 0 {
+			r.retryBackoff = backoff
+		}
+	}
+}
+
+// Timeout sets maximum timeout for SendMessageBatch calls.
+func Timeout(duration time.Duration) ReporterOption {
+	return func(r *sqsReporter) { r.client.Timeout = duration }
+}
+
+// BatchSize sets the maximum batch size, after which a collect will be
+// triggered. The default batch size is 100 traces.
+func BatchSize(n int) ReporterOption {
+	return func(r *sqsReporter) { r.batchSize = n }
+}
+
+// MaxBacklog sets the maximum backlog size. When batch size reaches this
+// threshold, spans from the beginning of the batch will be disposed.
+func MaxBacklog(n int) ReporterOption {
+	return func(r *sqsReporter) { r.maxBacklog = n }
+}
+
+// BatchInterval sets the maximum duration we will buffer traces before
+// emitting them to the queue. The default batch interval is 1 second.
+func BatchInterval(d time.Duration) ReporterOption {
+	return func(r *sqsReporter) { r.batchInterval = d }
+}
+
+// Serializer sets the serialization function to use for sending span data to
+// Zipkin.
+func Serializer(serializer reporter.SpanSerializer) ReporterOption {
+	return func(r *sqsReporter) {
+		if serializer != nil {
+			r.serializer = serializer
+		}
+	}
+}
+
+// Logger sets the logger used to report errors in the collection
+// process. It accepts a *log.Logger or any other reporter.Logger, e.g. one
+// returned by reporter.SlogLogger.
+func Logger(l reporter.Logger) ReporterOption {
+	return func(r *sqsReporter) { r.logger = l }
+}
+
+// OnDrop registers a callback function which is invoked for every span the
+// reporter fails to deliver, together with the reason. Spans are dropped when
+// the backlog overflows, on serialization failures and when their message is
+// rejected or fails after all attempts. The callback is invoked synchronously
+// from the reporter's goroutines so it should not block.
+func OnDrop(fn func(span model.SpanModel, reason error)) ReporterOption {
+	return func(r *sqsReporter) { r.onDrop = fn }
+}
+
+// NewReporter returns a new SQS Reporter sending spans to the queue at
+// queueURL, e.g. https://sqs.us-east-1.amazonaws.com/123456789012/zipkin.
+func NewReporter(queueURL string, opts ...ReporterOption) (reporter.Reporter, error) {
+	u, err := url.Parse(queueURL)
+	if err != nil || u.Host == "" {
+		return nil, ErrInvalidQueueURL
+	}
+
+	r := &sqsReporter{
+		queueURL:      queueURL,
+		region:        regionFromHost(u.Hostname()),
+		endpoint:      u.Scheme + "://" + u.Host,
+		client:        &http.Client{Timeout: defaultTimeout},
+		retryAttempts: defaultRetryAttempts,
+		retryBackoff:  defaultRetryBackoff,
+		logger:        log.New(os.Stderr, "", log.LstdFlags),
+		batchInterval: defaultBatchInterval,
+		batchSize:     defaultBatchSize,
+		maxBacklog:    defaultMaxBacklog,
+		serializer:    reporter.JSONSerializer{},
+	}
+	if r.region == "" {
+		r.region = sigv4.RegionFromEnv()
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	if r.region == "" {
+		return nil, ErrMissingRegion
+	}
+	if r.credentials == nil {
+		creds := sigv4.FromEnv()
+		if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+			return nil, ErrMissingCredentials
+		}
+		r.credentials = func() (Credentials, error) { return creds, nil }
+	}
+
+	r.batcher = batch.New(batch.Options{
+		Interval:   r.batchInterval,
+		Size:       r.batchSize,
+		MaxBacklog: r.maxBacklog,
+		Logger:     r.logger,
+		OnDrop:     r.onDrop,
+	}, r.sendBatch)
+
+	return r, nil
+}
+
+// regionFromHost returns the region of an SQS endpoint host like
+// sqs.us-east-1.amazonaws.com, if any.
+func regionFromHost(host string) string {
+	parts := strings.Split(host, ".")
+	if len(parts) < 4 || parts[0] != "sqs" || parts[2] != "amazonaws" {
+		return ""
+	}
+	return parts[1]
+}
+
+// Send implements reporter
+func (r *sqsReporter) Send(s model.SpanModel) {
+	r.batcher.Send(s)
+}
+
+// Flush implements reporter.Flusher. It sends the buffered spans to the queue
+// and returns the first error encountered. Flush does nothing once the
+// reporter is closed.
+func (r *sqsReporter) Flush() error {
+	return r.batcher.Flush()
+}
+
+// Close implements reporter
+func (r *sqsReporter) Close() error {
+	return r.batcher.Close()
+}
+
+func (r *sqsReporter) sendBatch(spans []*model.SpanModel) error {
+	var messages []message
+	err := r.messages(spans, &messages)
+
+	var (
+		entries []message
+		size    int
+	)
+	for _, msg := range messages {
+		if len(entries) == maxEntriesPerRequest || size+len(msg.body) > maxPayloadSize {
+			if serr := r.send(entries); err == nil {
+				err = serr
+			}
+			entries, size = nil, 0
+		}
+		entries = append(entries, msg)
+		size += len(msg.body)
+	}
+	if len(entries) > 0 {
+		if serr := r.send(entries); err == nil {
+			err = serr
+		}
+	}
+	return err
+}
+
+// messages appends the messages holding spans to messages, splitting spans in
+// halves until a message does not exceed the maximum payload size.
+func (r *sqsReporter) messages(spans []*model.SpanModel, messages *[]message) error {
+	body, err := r.serializer.Serialize(spans)
+	if err != nil {
+		r.logger.Printf("failed when marshalling the spans batch: %s\n", err.Error())
+		r.batcher.Drop(spans, err)
+		return err
+	}
+
+	text := string(body)
+	if !utf8.Valid(body) {
+		text = base64.StdEncoding.EncodeToString(body)
+	}
+	if len(text) > maxPayloadSize {
+		if len(spans) == 1 {
+			r.logger.Printf("dropping span exceeding the maximum message size: %d bytes\n", len(text))
+			r.batcher.Drop(spans, ErrMessageTooLarge)
+			return ErrMessageTooLarge
+		}
+		half := len(spans) / 2
+		err := r.messages(spans[:half], messages)
+		if herr := r.messages(spans[half:], messages); err == nil {
+			err = herr
+		}
+		return err
+	}
+
+	*messages = append(*messages, message{body: text, spans: spans})
+	return nil
+}
+
+type messageAttribute struct {
+	DataType    string `json:"DataType"`
+	StringValue string `json:"StringValue"`
+}
+
+type batchEntry struct {
+	ID                string                      `json:"Id"`
+	MessageBody       string                      `json:"MessageBody"`
+	MessageAttributes map[string]messageAttribute `json:"MessageAttributes"`
+}
+
+type sendMessageBatchRequest struct {
+	QueueURL string       `json:"QueueUrl"`
+	Entries  []batchEntry `json:"Entries"`
+}
+
+type sendMessageBatchResult struct {
+	Failed []struct {
+		ID          string `json:"Id"`
+		SenderFault bool   `json:"SenderFault"`
+		Code        string `json:"Code"`
+		Message     string `json:"Message"`
+	} `json:"Failed"`
+}
+
+type errorResponse struct {
+	Type    string `json:"__type"`
+	Message string `json:"message"`
+}
+
+// retryableErrors are the error types of requests which might succeed when
+// retried.
+var retryableErrors = []string{
+	"ThrottlingException",
+	"RequestThrottled",
+	"KmsThrottled",
+	"InternalFailure",
+	"ServiceUnavailable",
+}
+
+func retryable(errorType string) bool {
+	for _, t := range retryableErrors {
+		if strings.HasSuffix(errorType, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// send sends messages using SendMessageBatch, retrying failed messages.
+func (r *sqsReporter) send(messages []message) error {
+	var err error
+	for attempt := 1; len(messages) > 0; attempt++ {
+		if attempt > 1 {
+			time.Sleep(r.retryBackoff << uint(attempt-2))
+		}
+		var failed []message
+		failed, err = r.sendMessageBatch(messages)
+		messages = failed
+		if err == nil || attempt >= r.retryAttempts {
+			break
+		}
+	}
+	for _, msg := range messages {
+		r.batcher.Drop(msg.spans, err)
+	}
+	return err
+}
+
+// sendMessageBatch sends messages and returns the messages to retry and the
+// reason. Messages failing permanently are dropped.
+func (r *sqsReporter) sendMessageBatch(messages []message) ([]message, error) {
+	req := sendMessageBatchRequest{QueueURL: r.queueURL, Entries: make([]batchEntry, len(messages))}
+	for i, msg := range messages {
+		req.Entries[i] = batchEntry{
+			ID:          strconv.Itoa(i),
+			MessageBody: msg.body,
+			MessageAttributes: map[string]messageAttribute{
+				"Content-Type": {DataType: "String", StringValue: r.serializer.ContentType()},
+			},
+		}
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return messages, err
+	}
+
+	creds, err := r.credentials()
+	if err != nil {
+		r.logger.Printf("failed to retrieve credentials: %s\n", err.Error())
+		return messages, err
+	}
+	httpReq, err := http.NewRequest("POST", r.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return messages, err
+	}
+	// make sure instrumented transports do not trace the delivery of spans
+	httpReq = httpReq.WithContext(reporter.NewUntracedContext(context.Background()))
+	httpReq.Header.Set("Content-Type", "application/x-amz-json-1.0")
+	httpReq.Header.Set("X-Amz-Target", "AmazonSQS.SendMessageBatch")
+	sigv4.Sign(httpReq, body, creds, r.region, "sqs", time.Now())
+
+	resp, err := r.client.Do(httpReq)
+	if err != nil {
+		r.logger.Printf("failed to send the request: %s\n", err.Error())
+		return messages, err
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return messages, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var e errorResponse
+		_ = json.Unmarshal(respBody, &e)
+		err = fmt.Errorf("sqs: SendMessageBatch failed with status code %d: %s %s", resp.StatusCode, e.Type, e.Message)
+		r.logger.Printf("%s\n", err.Error())
+		if resp.StatusCode > 499 || retryable(e.Type) {
+			return messages, err
+		}
+		for _, msg := range messages {
+			r.batcher.Drop(msg.spans, err)
+		}
+		return nil, err
+	}
+
+	var result sendMessageBatchResult
+	if err = json.Unmarshal(respBody, &result); err != nil {
+		return messages, err
+	}
+	if len(result.Failed) == 0 {
+		return nil, nil
+	}
+	var failed []message
+	for _, res := range result.Failed {
+		i, aerr := strconv.Atoi(res.ID)
+		if aerr != nil || i < 0 || i >= len(messages) {
+			continue
+		}
+		err = fmt.Errorf("sqs: message failed: %s %s", res.Code, res.Message)
+		if res.SenderFault {
+			r.batcher.Drop(messages[i].spans, err)
+		} else {
+			failed = append(failed, messages[i])
+		}
+	}
+	r.logger.Printf("failed to send %d messages\n", len(result.Failed))
+	return failed, err
+}
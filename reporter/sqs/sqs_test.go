@@ -0,0 +1,336 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqs_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openzipkin/zipkin-go/model"
+	zipkinproto "github.com/openzipkin/zipkin-go/proto/v2"
+	"github.com/openzipkin/zipkin-go/reporter"
+	"github.com/openzipkin/zipkin-go/reporter/sqs"
+)
+
+type entry struct {
+	ID                string `json:"Id"`
+	MessageBody       string `json:"MessageBody"`
+	MessageAttributes map[string]struct {
+		DataType    string `json:"DataType"`
+		StringValue string `json:"StringValue"`
+	} `json:"MessageAttributes"`
+}
+
+type sendMessageBatch struct {
+	QueueURL string  `json:"QueueUrl"`
+	Entries  []entry `json:"Entries"`
+}
+
+type fakeSQS struct {
+	mtx      sync.Mutex
+	requests []sendMessageBatch
+	headers  []http.Header
+	// fail returns the error code of the entry in the given attempt, if
+	// any, and whether it is the fault of the sender.
+	fail func(e entry, attempt int) (code string, senderFault bool)
+}
+
+func (f *fakeSQS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	var req sendMessageBatch
+	if err := json.Unmarshal(body, &req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	f.mtx.Lock()
+	f.requests = append(f.requests, req)
+	f.headers = append(f.headers, r.Header)
+	attempt := len(f.requests)
+	f.mtx.Unlock()
+
+	type result struct {
+		ID          string `json:"Id"`
+		SenderFault bool   `json:"SenderFault,omitempty"`
+		Code        string `json:"Code,omitempty"`
+	}
+	var successful, failed []result
+	for _, e := range req.Entries {
+		if f.fail != nil {
+			if code, senderFault := f.fail(e, attempt); code != "" {
+				failed = append(failed, result{ID: e.ID, SenderFault: senderFault, Code: code})
+				continue
+			}
+		}
+		successful = append(successful, result{ID: e.ID})
+	}
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"Successful": successful,
+		"Failed":     failed,
+	})
+}
+
+func (f *fakeSQS) Requests() []sendMessageBatch {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	return append([]sendMessageBatch(nil), f.requests...)
+}
+
+const queueURL = "https://sqs.eu-west-1.amazonaws.com/123456789012/zipkin"
+
+func TestSendMessageBatch(t *testing.T) {
+	fake := &fakeSQS{}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	rep, err := sqs.NewReporter(queueURL,
+		sqs.Endpoint(srv.URL),
+		sqs.StaticCredentials("AKID", "SECRET", ""),
+		sqs.BatchInterval(time.Hour),
+		sqs.Retry(3, time.Millisecond),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer rep.Close()
+
+	for i, name := range []string{"a", "b"} {
+		rep.Send(model.SpanModel{
+			SpanContext: model.SpanContext{TraceID: model.TraceID{Low: uint64(i + 1)}, ID: model.ID(i + 1)},
+			Name:        name,
+		})
+	}
+	if err := rep.(reporter.Flusher).Flush(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	requests := fake.Requests()
+	if want, have := 1, len(requests); want != have {
+		t.Fatalf("requests want %d, have %d", want, have)
+	}
+	req := requests[0]
+	if want, have := queueURL, req.QueueURL; want != have {
+		t.Errorf("queue url want %q, have %q", want, have)
+	}
+	if want, have := 1, len(req.Entries); want != have {
+		t.Fatalf("entries want %d, have %d", want, have)
+	}
+	e := req.Entries[0]
+	if want, have := "application/json", e.MessageAttributes["Content-Type"].StringValue; want != have {
+		t.Errorf("content type attribute want %q, have %q", want, have)
+	}
+	var spans []model.SpanModel
+	if err := json.Unmarshal([]byte(e.MessageBody), &spans); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want, have := 2, len(spans); want != have {
+		t.Fatalf("spans want %d, have %d", want, have)
+	}
+
+	h := fake.headers[0]
+	if want, have := "AmazonSQS.SendMessageBatch", h.Get("X-Amz-Target"); want != have {
+		t.Errorf("target want %q, have %q", want, have)
+	}
+	if want, have := "application/x-amz-json-1.0", h.Get("Content-Type"); want != have {
+		t.Errorf("content type want %q, have %q", want, have)
+	}
+	// region is derived from the queue url
+	auth := h.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/") ||
+		!strings.Contains(auth, "/eu-west-1/sqs/aws4_request") {
+		t.Errorf("unexpected authorization header %q", auth)
+	}
+}
+
+func TestProtobufMessages(t *testing.T) {
+	fake := &fakeSQS{}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	rep, err := sqs.NewReporter(queueURL,
+		sqs.Endpoint(srv.URL),
+		sqs.StaticCredentials("AKID", "SECRET", ""),
+		sqs.BatchInterval(time.Hour),
+		sqs.Retry(3, time.Millisecond),
+		sqs.Serializer(zipkinproto.SpanSerializer{}),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer rep.Close()
+
+	// the encoded duration is not valid UTF-8
+	rep.Send(model.SpanModel{
+		SpanContext: model.SpanContext{TraceID: model.TraceID{Low: 1}, ID: 1},
+		Name:        "a",
+		Duration:    time.Millisecond,
+	})
+	if err := rep.(reporter.Flusher).Flush(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	e := fake.Requests()[0].Entries[0]
+	if want, have := "application/x-protobuf", e.MessageAttributes["Content-Type"].StringValue; want != have {
+		t.Errorf("content type attribute want %q, have %q", want, have)
+	}
+	body, err := base64.StdEncoding.DecodeString(e.MessageBody)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	spans, err := zipkinproto.ParseSpans(body, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want, have := "a", spans[0].Name; len(spans) != 1 || want != have {
+		t.Errorf("spans want [%s], have %v", want, spans)
+	}
+}
+
+func TestSplitLargeBatches(t *testing.T) {
+	fake := &fakeSQS{}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	var dropped int
+	rep, err := sqs.NewReporter(queueURL,
+		sqs.Endpoint(srv.URL),
+		sqs.StaticCredentials("AKID", "SECRET", ""),
+		sqs.BatchInterval(time.Hour),
+		sqs.Retry(3, time.Millisecond),
+		sqs.OnDrop(func(model.SpanModel, error) { dropped++ }),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer rep.Close()
+
+	tag := strings.Repeat("x", 100<<10)
+	for i := 1; i <= 7; i++ {
+		span := model.SpanModel{
+			SpanContext: model.SpanContext{TraceID: model.TraceID{Low: uint64(i)}, ID: model.ID(i)},
+			Name:        "a",
+			Tags:        map[string]string{"payload": tag},
+		}
+		if i == 7 {
+			// exceeds the message size limit on its own
+			span.Tags["payload"] = strings.Repeat("x", 300<<10)
+		}
+		rep.Send(span)
+	}
+
+	if want, have := sqs.ErrMessageTooLarge, rep.(reporter.Flusher).Flush(); want != have {
+		t.Errorf("flush want %v, have %v", want, have)
+	}
+
+	var messages, spans int
+	for _, req := range fake.Requests() {
+		size := 0
+		for _, e := range req.Entries {
+			var s []model.SpanModel
+			if err := json.Unmarshal([]byte(e.MessageBody), &s); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			size += len(e.MessageBody)
+			messages++
+			spans += len(s)
+		}
+		if size > 256<<10 {
+			t.Errorf("request payload exceeds limit: %d bytes", size)
+		}
+	}
+	if want, have := 6, spans; want != have {
+		t.Errorf("spans want %d, have %d", want, have)
+	}
+	if messages < 3 {
+		t.Errorf("want at least 3 messages, have %d", messages)
+	}
+	if want, have := 1, dropped; want != have {
+		t.Errorf("dropped want %d, have %d", want, have)
+	}
+}
+
+func TestRetryFailedMessages(t *testing.T) {
+	fake := &fakeSQS{
+		fail: func(e entry, attempt int) (string, bool) {
+			switch {
+			case strings.Contains(e.MessageBody, `"name":"throttled"`) && attempt == 1:
+				return "InternalError", false
+			case strings.Contains(e.MessageBody, `"name":"invalid"`):
+				return "InvalidMessageContents", true
+			}
+			return "", false
+		},
+	}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	var (
+		mtx     sync.Mutex
+		dropped []string
+	)
+	rep, err := sqs.NewReporter(queueURL,
+		sqs.Endpoint(srv.URL),
+		sqs.StaticCredentials("AKID", "SECRET", ""),
+		sqs.BatchInterval(time.Hour),
+		sqs.Retry(3, time.Millisecond),
+		sqs.OnDrop(func(span model.SpanModel, _ error) {
+			mtx.Lock()
+			dropped = append(dropped, span.Name)
+			mtx.Unlock()
+		}),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer rep.Close()
+
+	rep.Send(model.SpanModel{
+		SpanContext: model.SpanContext{TraceID: model.TraceID{Low: 1}, ID: 1},
+		Name:        "throttled",
+	})
+	if err := rep.(reporter.Flusher).Flush(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	rep.Send(model.SpanModel{
+		SpanContext: model.SpanContext{TraceID: model.TraceID{Low: 2}, ID: 2},
+		Name:        "invalid",
+	})
+	if err := rep.(reporter.Flusher).Flush(); err == nil {
+		t.Fatal("expected error")
+	}
+
+	if want, have := 3, len(fake.Requests()); want != have {
+		t.Errorf("requests want %d, have %d", want, have)
+	}
+	mtx.Lock()
+	defer mtx.Unlock()
+	if want, have := []string{"invalid"}, dropped; len(have) != 1 || want[0] != have[0] {
+		t.Errorf("dropped want %v, have %v", want, have)
+	}
+}
+
+func TestInvalidQueueURL(t *testing.T) {
+	if _, err := sqs.NewReporter("zipkin"); err != sqs.ErrInvalidQueueURL {
+		t.Errorf("want %v, have %v", sqs.ErrInvalidQueueURL, err)
+	}
+}
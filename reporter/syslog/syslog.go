@@ -0,0 +1,355 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package syslog implements a reporter sending spans to a syslog server as RFC
+5424 messages, for environments where syslog is the only approved egress path.
+
+Every span is sent as one message holding the serialized span, by default JSON
+V2, as message body and the trace metadata as RFC 5424 structured data:
+
+	<134>1 2019-01-01T00:00:00.000000Z host app 42 span [zipkin@32473 traceId="000000000000007b" spanId="00000000000001c8" name="get"] [{"traceId":...}]
+
+Messages are sent over UDP, TCP, TLS or unix domain sockets. Stream transports
+use octet counting framing as described in RFC 6587.
+*/
+package syslog
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter"
+)
+
+// defaults for the syslog reporter.
+const (
+	// DefaultStructuredDataID is the SD-ID of the structured data element
+	// holding the trace metadata. It uses the enterprise number reserved for
+	// documentation by RFC 5612; organizations with their own private
+	// enterprise number should use it with the StructuredDataID option.
+	DefaultStructuredDataID = "zipkin@32473"
+
+	defaultFacility = 16 // local0
+	defaultSeverity = 6  // informational
+	defaultTimeout  = 5 * time.Second
+	msgID           = "span"
+)
+
+// Errors returned or reported by the syslog reporter.
+var (
+	ErrUnsupportedNetwork = errors.New("syslog: unsupported network")
+	ErrInvalidPriority    = errors.New("syslog: invalid facility or severity")
+	ErrClosed             = errors.New("syslog: reporter closed")
+)
+
+// syslogReporter implements Reporter by sending spans to a syslog server.
+type syslogReporter struct {
+	network    string
+	address    string
+	tlsConfig  *tls.Config
+	timeout    time.Duration
+	facility   int
+	severity   int
+	hostname   string
+	appName    string
+	procID     string
+	sdID       string
+	logger     *log.Logger
+	serializer reporter.SpanSerializer
+	onDrop     func(model.SpanModel, error)
+
+	mtx    sync.Mutex
+	conn   net.Conn
+	closed bool
+}
+
+// ReporterOption sets a parameter for the syslogReporter
+type ReporterOption func(r *syslogReporter)
+
+// Logger sets the logger used to report errors in the collection
+// process.
+func Logger(logger *log.Logger) ReporterOption {
+	return func(r *syslogReporter) {
+		r.logger = logger
+	}
+}
+
+// TLSConfig sets the TLS configuration used for connecting to the syslog
+// server over TCP, as described in RFC 5425.
+func TLSConfig(config *tls.Config) ReporterOption {
+	return func(r *syslogReporter) {
+		r.tlsConfig = config
+	}
+}
+
+// Timeout sets the timeout for connecting to the syslog server and for writing
+// messages. Default is 5 seconds.
+func Timeout(d time.Duration) ReporterOption {
+	return func(r *syslogReporter) {
+		if d > 0 {
+			r.timeout = d
+		}
+	}
+}
+
+// Facility sets the syslog facility code of the messages, between 0 and 23.
+// Default is 16 (local0).
+func Facility(facility int) ReporterOption {
+	return func(r *syslogReporter) {
+		r.facility = facility
+	}
+}
+
+// Severity sets the syslog severity code of the messages, between 0 and 7.
+// Default is 6 (informational).
+func Severity(severity int) ReporterOption {
+	return func(r *syslogReporter) {
+		r.severity = severity
+	}
+}
+
+// Hostname sets the HOSTNAME field of the messages. Defaults to the host name
+// reported by the operating system.
+func Hostname(hostname string) ReporterOption {
+	return func(r *syslogReporter) {
+		r.hostname = hostname
+	}
+}
+
+// AppName sets the APP-NAME field of the messages. Defaults to the name of the
+// executable.
+func AppName(name string) ReporterOption {
+	return func(r *syslogReporter) {
+		r.appName = name
+	}
+}
+
+// StructuredDataID sets the SD-ID of the structured data element holding the
+// trace metadata. Default is DefaultStructuredDataID.
+func StructuredDataID(id string) ReporterOption {
+	return func(r *syslogReporter) {
+		if id != "" {
+			r.sdID = id
+		}
+	}
+}
+
+// Serializer sets the serialization function to use for the message body.
+func Serializer(serializer reporter.SpanSerializer) ReporterOption {
+	return func(r *syslogReporter) {
+		if serializer != nil {
+			r.serializer = serializer
+		}
+	}
+}
+
+// OnDrop registers a callback function which is invoked for every span the
+// reporter fails to send, together with the reason, e.g. on serialization
+// failures or when writing the message fails.
+func OnDrop(fn func(span model.SpanModel, reason error)) ReporterOption {
+	return func(r *syslogReporter) {
+		r.onDrop = fn
+	}
+}
+
+// NewReporter returns a new syslog Reporter sending spans to the syslog server
+// at address. Supported networks are "udp", "tcp", "unix" and "unixgram". The
+// connection is established lazily and re-established after write failures.
+func NewReporter(network, address string, options ...ReporterOption) (reporter.Reporter, error) {
+	switch network {
+	case "udp", "udp4", "udp6", "tcp", "tcp4", "tcp6", "unix", "unixgram":
+	default:
+		return nil, ErrUnsupportedNetwork
+	}
+
+	r := &syslogReporter{
+		network:    network,
+		address:    address,
+		timeout:    defaultTimeout,
+		facility:   defaultFacility,
+		severity:   defaultSeverity,
+		procID:     strconv.Itoa(os.Getpid()),
+		appName:    filepath.Base(os.Args[0]),
+		sdID:       DefaultStructuredDataID,
+		logger:     log.New(os.Stderr, "", log.LstdFlags),
+		serializer: reporter.JSONSerializer{},
+	}
+	r.hostname, _ = os.Hostname()
+
+	for _, option := range options {
+		option(r)
+	}
+
+	if r.facility < 0 || r.facility > 23 || r.severity < 0 || r.severity > 7 {
+		return nil, ErrInvalidPriority
+	}
+
+	return r, nil
+}
+
+// Send formats the span as syslog message and writes it to the server.
+func (r *syslogReporter) Send(s model.SpanModel) {
+	body, err := r.serializer.Serialize([]*model.SpanModel{&s})
+	if err != nil {
+		r.logger.Printf("failed when marshalling the span: %s\n", err.Error())
+		r.failed(s, err)
+		return
+	}
+	msg := r.format(s, body, time.Now())
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if r.closed {
+		r.failed(s, ErrClosed)
+		return
+	}
+	if err = r.write(msg); err != nil {
+		r.logger.Printf("failed to send the span: %s\n", err.Error())
+		r.failed(s, err)
+	}
+}
+
+// format returns the RFC 5424 message of the span.
+func (r *syslogReporter) format(s model.SpanModel, body []byte, now time.Time) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "<%d>1 %s %s %s %s %s [%s",
+		r.facility*8+r.severity,
+		now.UTC().Format("2006-01-02T15:04:05.000000Z"),
+		header(r.hostname, 255),
+		header(r.appName, 48),
+		header(r.procID, 128),
+		msgID,
+		r.sdID,
+	)
+	param(&b, "traceId", s.TraceID.String())
+	param(&b, "spanId", s.ID.String())
+	if s.ParentID != nil {
+		param(&b, "parentId", s.ParentID.String())
+	}
+	if s.Name != "" {
+		param(&b, "name", s.Name)
+	}
+	if s.Kind != model.Undetermined {
+		param(&b, "kind", string(s.Kind))
+	}
+	if s.Debug {
+		param(&b, "debug", "true")
+	}
+	b.WriteString("] ")
+	b.Write(body)
+	return []byte(b.String())
+}
+
+// header returns a header field value, limited to printable US-ASCII without
+// spaces as required by RFC 5424. Empty values are replaced by the NILVALUE.
+func header(value string, max int) string {
+	value = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return -1
+		}
+		return r
+	}, value)
+	if len(value) > max {
+		value = value[:max]
+	}
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+// param writes a structured data parameter, escaping the characters RFC 5424
+// requires to be escaped in parameter values.
+func param(b *strings.Builder, name, value string) {
+	b.WriteString(" ")
+	b.WriteString(name)
+	b.WriteString(`="`)
+	for _, c := range value {
+		if c == '"' || c == '\\' || c == ']' {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(c)
+	}
+	b.WriteString(`"`)
+}
+
+// write writes msg to the server, connecting first if needed. Stream
+// transports use octet counting framing. The caller holds mtx.
+func (r *syslogReporter) write(msg []byte) error {
+	if r.conn == nil {
+		conn, err := r.dial()
+		if err != nil {
+			return err
+		}
+		r.conn = conn
+	}
+
+	if r.stream() {
+		msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+	}
+	_ = r.conn.SetWriteDeadline(time.Now().Add(r.timeout))
+	if _, err := r.conn.Write(msg); err != nil {
+		// reconnect with the next message
+		_ = r.conn.Close()
+		r.conn = nil
+		return err
+	}
+	return nil
+}
+
+func (r *syslogReporter) dial() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: r.timeout}
+	if r.tlsConfig != nil && strings.HasPrefix(r.network, "tcp") {
+		return tls.DialWithDialer(dialer, r.network, r.address, r.tlsConfig)
+	}
+	return dialer.Dial(r.network, r.address)
+}
+
+func (r *syslogReporter) stream() bool {
+	return strings.HasPrefix(r.network, "tcp") || r.network == "unix"
+}
+
+func (r *syslogReporter) failed(s model.SpanModel, err error) {
+	if r.onDrop != nil {
+		r.onDrop(s, err)
+	}
+}
+
+// Close closes the connection to the syslog server. Spans sent after closing
+// are dropped.
+func (r *syslogReporter) Close() error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	if r.conn == nil {
+		return nil
+	}
+	return r.conn.Close()
+}
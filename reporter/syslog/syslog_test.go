@@ -0,0 +1,191 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package syslog_test
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter/syslog"
+)
+
+var messagePattern = regexp.MustCompile(
+	`^<(\d+)>1 \S+Z (\S+) (\S+) \d+ span \[zipkin@32473 (.*?[^\\])\] (.*)$`,
+)
+
+func makeSpan(name string) model.SpanModel {
+	parentID := model.ID(2)
+	return model.SpanModel{
+		SpanContext: model.SpanContext{
+			TraceID:  model.TraceID{Low: 1},
+			ID:       3,
+			ParentID: &parentID,
+		},
+		Name:      name,
+		Kind:      model.Client,
+		Timestamp: time.Now(),
+		Duration:  time.Millisecond,
+	}
+}
+
+func TestSyslogUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	defer conn.Close()
+
+	rep, err := syslog.NewReporter(
+		"udp", conn.LocalAddr().String(),
+		syslog.Facility(1), syslog.Severity(5),
+		syslog.Hostname("my host"), syslog.AppName("app"),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	defer rep.Close()
+
+	rep.Send(makeSpan(`get "a]b\c"`))
+
+	buf := make([]byte, 65536)
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+
+	m := messagePattern.FindStringSubmatch(string(buf[:n]))
+	if m == nil {
+		t.Fatalf("unexpected message: %s", buf[:n])
+	}
+	if want, have := "13", m[1]; want != have {
+		t.Errorf("priority want %s, have %s", want, have)
+	}
+	if want, have := "myhost", m[2]; want != have {
+		t.Errorf("hostname want %s, have %s", want, have)
+	}
+	if want, have := "app", m[3]; want != have {
+		t.Errorf("app name want %s, have %s", want, have)
+	}
+	if want, have := `traceId="0000000000000001" spanId="0000000000000003" parentId="0000000000000002" name="get \"a\]b\\c\"" kind="CLIENT"`, m[4]; want != have {
+		t.Errorf("structured data want %s, have %s", want, have)
+	}
+	if !strings.Contains(m[5], `"traceId":"0000000000000001"`) {
+		t.Errorf("unexpected message body: %s", m[5])
+	}
+}
+
+func TestSyslogTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	defer ln.Close()
+
+	msgs := make(chan string, 2)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			size, err := r.ReadString(' ')
+			if err != nil {
+				return
+			}
+			n, err := strconv.Atoi(strings.TrimSpace(size))
+			if err != nil {
+				t.Errorf("invalid frame length %q", size)
+				return
+			}
+			msg := make([]byte, n)
+			if _, err = io.ReadFull(r, msg); err != nil {
+				return
+			}
+			msgs <- string(msg)
+		}
+	}()
+
+	rep, err := syslog.NewReporter("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	defer rep.Close()
+
+	rep.Send(makeSpan("one"))
+	rep.Send(makeSpan("two"))
+
+	for _, name := range []string{"one", "two"} {
+		select {
+		case msg := <-msgs:
+			m := messagePattern.FindStringSubmatch(msg)
+			if m == nil {
+				t.Fatalf("unexpected message: %s", msg)
+			}
+			if want, have := "134", m[1]; want != have {
+				t.Errorf("priority want %s, have %s", want, have)
+			}
+			if !strings.Contains(m[4], `name="`+name+`"`) {
+				t.Errorf("expected name %q in structured data: %s", name, m[4])
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for message")
+		}
+	}
+}
+
+func TestSyslogDropped(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	defer conn.Close()
+
+	var dropped error
+	rep, err := syslog.NewReporter(
+		"udp", conn.LocalAddr().String(),
+		syslog.OnDrop(func(_ model.SpanModel, err error) { dropped = err }),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	_ = rep.Close()
+
+	rep.Send(makeSpan("late"))
+	if want, have := syslog.ErrClosed, dropped; want != have {
+		t.Errorf("drop reason want %v, have %v", want, have)
+	}
+}
+
+func TestSyslogInvalidOptions(t *testing.T) {
+	if _, err := syslog.NewReporter("sctp", "localhost:514"); err != syslog.ErrUnsupportedNetwork {
+		t.Errorf("want %v, have %v", syslog.ErrUnsupportedNetwork, err)
+	}
+	if _, err := syslog.NewReporter("udp", "localhost:514", syslog.Facility(24)); err != syslog.ErrInvalidPriority {
+		t.Errorf("want %v, have %v", syslog.ErrInvalidPriority, err)
+	}
+	if _, err := syslog.NewReporter("udp", "localhost:514", syslog.Severity(-1)); err != syslog.ErrInvalidPriority {
+		t.Errorf("want %v, have %v", syslog.ErrInvalidPriority, err)
+	}
+}
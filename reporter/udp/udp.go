@@ -0,0 +1,233 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package udp implements a reporter sending spans as UDP datagrams, for sidecar
+agents listening on a local UDP port. Every span is sent in its own datagram
+directly from Send, trading delivery guarantees for near-zero latency in the
+application: spans are lost without notice if no agent is listening.
+
+Spans exceeding the maximum datagram size are dropped unless chunking is
+enabled, in which case they are split into multiple datagrams. Each chunk
+starts with a 12 byte header, similar to GELF chunking, which the agent uses
+to reassemble the span:
+
+	bytes 0-1   magic bytes 0x1e 0x0f
+	bytes 2-9   message id, identical for all chunks of a span
+	byte  10    sequence number of the chunk, starting at 0
+	byte  11    total number of chunks
+
+Datagrams holding a complete span have no header.
+*/
+package udp
+
+import (
+	"errors"
+	"log"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter"
+)
+
+// defaults for the UDP reporter.
+const (
+	// DefaultMaxDatagramSize fits the largest datagram sent over the loopback
+	// interface of common operating systems.
+	DefaultMaxDatagramSize = 65000
+	// MaxChunks is the maximum number of chunks a span can be split into.
+	MaxChunks = 128
+
+	chunkHeaderSize = 12
+	minDatagramSize = chunkHeaderSize + 1
+	maxDatagramSize = 65507
+)
+
+// ChunkMagic holds the magic bytes prefixed to chunked datagrams.
+var ChunkMagic = [2]byte{0x1e, 0x0f}
+
+// Errors returned or reported by the UDP reporter.
+var (
+	ErrInvalidDatagramSize = errors.New("udp: invalid max datagram size")
+	ErrSpanTooLarge        = errors.New("udp: span exceeds max datagram size")
+	ErrClosed              = errors.New("udp: reporter closed")
+)
+
+// udpReporter implements Reporter by sending spans as UDP datagrams.
+type udpReporter struct {
+	messageID reporter.Counter
+
+	conn            net.Conn
+	maxDatagramSize int
+	chunking        bool
+	logger          *log.Logger
+	serializer      reporter.SpanSerializer
+	onDrop          func(model.SpanModel, error)
+
+	mtx    sync.RWMutex
+	closed bool
+}
+
+// ReporterOption sets a parameter for the udpReporter
+type ReporterOption func(r *udpReporter)
+
+// Logger sets the logger used to report errors in the collection
+// process.
+func Logger(logger *log.Logger) ReporterOption {
+	return func(r *udpReporter) {
+		r.logger = logger
+	}
+}
+
+// MaxDatagramSize sets the maximum size of the datagrams sent, including the
+// chunk header if chunking is enabled. Default is 65000 which is suitable for
+// agents on localhost; use 1472 or less for agents reached over a network with
+// a regular MTU of 1500 bytes.
+func MaxDatagramSize(n int) ReporterOption {
+	return func(r *udpReporter) {
+		r.maxDatagramSize = n
+	}
+}
+
+// Chunking when enabled splits spans exceeding the maximum datagram size into
+// up to MaxChunks datagrams instead of dropping them. The agent needs to
+// support reassembling chunks, see the package documentation for the format.
+func Chunking(enabled bool) ReporterOption {
+	return func(r *udpReporter) {
+		r.chunking = enabled
+	}
+}
+
+// Serializer sets the serialization function to use for sending span data to
+// Zipkin.
+func Serializer(serializer reporter.SpanSerializer) ReporterOption {
+	return func(r *udpReporter) {
+		if serializer != nil {
+			r.serializer = serializer
+		}
+	}
+}
+
+// OnDrop registers a callback function which is invoked for every span the
+// reporter fails to send, together with the reason, e.g. on serialization
+// failures, spans too large to send or when writing the datagram fails.
+func OnDrop(fn func(span model.SpanModel, reason error)) ReporterOption {
+	return func(r *udpReporter) {
+		r.onDrop = fn
+	}
+}
+
+// NewReporter returns a new UDP Reporter sending spans to the agent at address
+// of the form "host:port", e.g. "127.0.0.1:9411".
+func NewReporter(address string, options ...ReporterOption) (reporter.Reporter, error) {
+	r := &udpReporter{
+		maxDatagramSize: DefaultMaxDatagramSize,
+		logger:          log.New(os.Stderr, "", log.LstdFlags),
+		serializer:      reporter.JSONSerializer{},
+	}
+
+	for _, option := range options {
+		option(r)
+	}
+
+	if r.maxDatagramSize < minDatagramSize || r.maxDatagramSize > maxDatagramSize {
+		return nil, ErrInvalidDatagramSize
+	}
+
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	r.conn = conn
+
+	return r, nil
+}
+
+// Send serializes the span and writes it to the agent. It does not block on
+// the agent, errors result in the span being dropped.
+func (r *udpReporter) Send(s model.SpanModel) {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	if r.closed {
+		r.failed(s, ErrClosed)
+		return
+	}
+
+	b, err := r.serializer.Serialize([]*model.SpanModel{&s})
+	if err != nil {
+		r.logger.Printf("failed when marshalling the span: %s\n", err.Error())
+		r.failed(s, err)
+		return
+	}
+
+	if len(b) <= r.maxDatagramSize {
+		_, err = r.conn.Write(b)
+	} else {
+		err = r.writeChunks(b)
+	}
+	if err != nil {
+		if err != ErrSpanTooLarge {
+			r.logger.Printf("failed to send the span: %s\n", err.Error())
+		}
+		r.failed(s, err)
+	}
+}
+
+// writeChunks splits b into chunks and writes them as separate datagrams.
+func (r *udpReporter) writeChunks(b []byte) error {
+	size := r.maxDatagramSize - chunkHeaderSize
+	count := (len(b) + size - 1) / size
+	if !r.chunking || count > MaxChunks {
+		return ErrSpanTooLarge
+	}
+
+	id := r.messageID.Inc()
+	datagram := make([]byte, r.maxDatagramSize)
+	datagram[0], datagram[1] = ChunkMagic[0], ChunkMagic[1]
+	for i := 0; i < 8; i++ {
+		datagram[2+i] = byte(id >> uint(56-8*i))
+	}
+	datagram[11] = byte(count)
+
+	for seq := 0; seq < count; seq++ {
+		datagram[10] = byte(seq)
+		n := copy(datagram[chunkHeaderSize:], b[seq*size:])
+		if _, err := r.conn.Write(datagram[:chunkHeaderSize+n]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *udpReporter) failed(s model.SpanModel, err error) {
+	if r.onDrop != nil {
+		r.onDrop(s, err)
+	}
+}
+
+// Close closes the connection to the agent. Spans sent after closing are
+// dropped.
+func (r *udpReporter) Close() error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	return r.conn.Close()
+}
@@ -0,0 +1,189 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package udp_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter/udp"
+)
+
+func newSpan(name string) model.SpanModel {
+	return model.SpanModel{
+		SpanContext: model.SpanContext{
+			TraceID: model.TraceID{Low: 123},
+			ID:      model.ID(456),
+		},
+		Name:      name,
+		Timestamp: time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC),
+		Duration:  time.Millisecond,
+	}
+}
+
+func listen(t *testing.T) *net.UDPConn {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("unable to listen: %+v", err)
+	}
+	return conn
+}
+
+func read(t *testing.T, conn *net.UDPConn) []byte {
+	buf := make([]byte, 65535)
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("unable to read datagram: %+v", err)
+	}
+	return buf[:n]
+}
+
+func decode(t *testing.T, b []byte) model.SpanModel {
+	var spans []model.SpanModel
+	if err := json.Unmarshal(b, &spans); err != nil {
+		t.Fatalf("unable to decode datagram: %+v", err)
+	}
+	if want, have := 1, len(spans); want != have {
+		t.Fatalf("spans per datagram want %d, have %d", want, have)
+	}
+	return spans[0]
+}
+
+func TestUDPReporter(t *testing.T) {
+	conn := listen(t)
+	defer conn.Close()
+
+	rep, err := udp.NewReporter(conn.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	defer rep.Close()
+
+	for _, name := range []string{"first", "second"} {
+		rep.Send(newSpan(name))
+		if want, have := name, decode(t, read(t, conn)).Name; want != have {
+			t.Errorf("span name want %q, have %q", want, have)
+		}
+	}
+}
+
+func TestUDPSpanTooLarge(t *testing.T) {
+	conn := listen(t)
+	defer conn.Close()
+
+	var reason error
+	rep, err := udp.NewReporter(
+		conn.LocalAddr().String(),
+		udp.MaxDatagramSize(512),
+		udp.OnDrop(func(_ model.SpanModel, err error) { reason = err }),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	defer rep.Close()
+
+	rep.Send(newSpan(strings.Repeat("a", 1024)))
+	if want, have := udp.ErrSpanTooLarge, reason; want != have {
+		t.Errorf("drop reason want %v, have %v", want, have)
+	}
+}
+
+func TestUDPChunking(t *testing.T) {
+	conn := listen(t)
+	defer conn.Close()
+
+	rep, err := udp.NewReporter(
+		conn.LocalAddr().String(),
+		udp.MaxDatagramSize(100),
+		udp.Chunking(true),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	defer rep.Close()
+
+	name := strings.Repeat("a", 1024)
+	rep.Send(newSpan(name))
+
+	var (
+		payload bytes.Buffer
+		id      uint64
+		count   int
+	)
+	for seq := 0; seq == 0 || seq < count; seq++ {
+		b := read(t, conn)
+		if len(b) > 100 {
+			t.Fatalf("datagram size %d exceeds max", len(b))
+		}
+		if b[0] != udp.ChunkMagic[0] || b[1] != udp.ChunkMagic[1] {
+			t.Fatalf("chunk %d misses magic bytes", seq)
+		}
+		if seq == 0 {
+			id, count = binary.BigEndian.Uint64(b[2:10]), int(b[11])
+		}
+		if want, have := id, binary.BigEndian.Uint64(b[2:10]); want != have {
+			t.Errorf("message id want %d, have %d", want, have)
+		}
+		if want, have := seq, int(b[10]); want != have {
+			t.Errorf("sequence number want %d, have %d", want, have)
+		}
+		payload.Write(b[12:])
+	}
+
+	if want, have := name, decode(t, payload.Bytes()).Name; want != have {
+		t.Errorf("span name want %q, have %q", want, have)
+	}
+}
+
+func TestUDPInvalidDatagramSize(t *testing.T) {
+	for _, size := range []int{0, 12, 65508} {
+		if _, err := udp.NewReporter("127.0.0.1:9411", udp.MaxDatagramSize(size)); err != udp.ErrInvalidDatagramSize {
+			t.Errorf("size %d want %v, have %v", size, udp.ErrInvalidDatagramSize, err)
+		}
+	}
+}
+
+func TestUDPClose(t *testing.T) {
+	conn := listen(t)
+	defer conn.Close()
+
+	var reason error
+	rep, err := udp.NewReporter(
+		conn.LocalAddr().String(),
+		udp.OnDrop(func(_ model.SpanModel, err error) { reason = err }),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+
+	if err = rep.Close(); err != nil {
+		t.Errorf("unexpected error: %+v", err)
+	}
+	if err = rep.Close(); err != nil {
+		t.Errorf("closing again: unexpected error: %+v", err)
+	}
+
+	rep.Send(newSpan("closed"))
+	if want, have := udp.ErrClosed, reason; want != have {
+		t.Errorf("drop reason want %v, have %v", want, have)
+	}
+}
@@ -0,0 +1,26 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !go1.16
+// +build !go1.16
+
+package zipkin
+
+import "runtime"
+
+// readRuntimeSnapshot only reads the goroutine count as garbage collection
+// statistics can not be read without stopping the world before Go 1.16.
+func readRuntimeSnapshot() runtimeSnapshot {
+	return runtimeSnapshot{goroutines: int64(runtime.NumGoroutine())}
+}
@@ -0,0 +1,59 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.16
+// +build go1.16
+
+package zipkin
+
+import (
+	"runtime/metrics"
+	"time"
+)
+
+var runtimeSamples = []metrics.Sample{
+	{Name: "/gc/cycles/total:gc-cycles"},
+	{Name: "/gc/pauses:seconds"},
+	{Name: "/sched/goroutines:goroutines"},
+}
+
+// readRuntimeSnapshot reads the runtime statistics using runtime/metrics,
+// which does not stop the world.
+func readRuntimeSnapshot() runtimeSnapshot {
+	samples := make([]metrics.Sample, len(runtimeSamples))
+	copy(samples, runtimeSamples)
+	metrics.Read(samples)
+
+	var s runtimeSnapshot
+	if samples[0].Value.Kind() == metrics.KindUint64 {
+		s.gcCycles = samples[0].Value.Uint64()
+		s.gc = true
+	}
+	if samples[1].Value.Kind() == metrics.KindFloat64Histogram {
+		// the histogram only holds bucketed pauses; sum up the lower bucket
+		// boundaries for a conservative estimate of the total pause time
+		h := samples[1].Value.Float64Histogram()
+		var total float64
+		for i, count := range h.Counts {
+			if lower := h.Buckets[i]; lower > 0 {
+				total += float64(count) * lower
+			}
+		}
+		s.gcPause = time.Duration(total * float64(time.Second))
+	}
+	if samples[2].Value.Kind() == metrics.KindUint64 {
+		s.goroutines = int64(samples[2].Value.Uint64())
+	}
+	return s
+}
@@ -0,0 +1,46 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zipkin
+
+import (
+	"strconv"
+	"time"
+)
+
+// Tags set on long spans by WithRuntimeMetrics.
+const (
+	TagRuntimeGCPause         Tag = "runtime.gc.pause"
+	TagRuntimeGCCycles        Tag = "runtime.gc.cycles"
+	TagRuntimeGoroutinesDelta Tag = "runtime.goroutines.delta"
+)
+
+// runtimeSnapshot holds the runtime statistics at a point in time.
+type runtimeSnapshot struct {
+	gc         bool // garbage collection statistics available
+	gcCycles   uint64
+	gcPause    time.Duration
+	goroutines int64
+}
+
+// tagRuntimeDeltas tags the span with the runtime statistics observed since
+// start.
+func tagRuntimeDeltas(tags map[string]string, start runtimeSnapshot) {
+	end := readRuntimeSnapshot()
+	if start.gc && end.gc {
+		tags[string(TagRuntimeGCCycles)] = strconv.FormatUint(end.gcCycles-start.gcCycles, 10)
+		tags[string(TagRuntimeGCPause)] = (end.gcPause - start.gcPause).String()
+	}
+	tags[string(TagRuntimeGoroutinesDelta)] = strconv.FormatInt(end.goroutines-start.goroutines, 10)
+}
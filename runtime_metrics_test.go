@@ -0,0 +1,59 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zipkin
+
+import (
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/openzipkin/zipkin-go/reporter/recorder"
+)
+
+func TestWithRuntimeMetrics(t *testing.T) {
+	rec := recorder.NewReporter()
+	defer rec.Close()
+
+	tr, _ := NewTracer(rec, WithRuntimeMetrics(time.Millisecond))
+
+	long := tr.StartSpan("long")
+	runtime.GC()
+	long.FinishedWithDuration(time.Second)
+
+	short := tr.StartSpan("short")
+	short.FinishedWithDuration(time.Microsecond)
+
+	spans := rec.Flush()
+	if want, have := 2, len(spans); want != have {
+		t.Fatalf("reported spans want %d, have %d", want, have)
+	}
+
+	if _, found := spans[0].Tags[string(TagRuntimeGoroutinesDelta)]; !found {
+		t.Errorf("expected tag %s on long span", TagRuntimeGoroutinesDelta)
+	}
+	if cycles, found := spans[0].Tags[string(TagRuntimeGCCycles)]; found {
+		if n, err := strconv.Atoi(cycles); err != nil || n < 1 {
+			t.Errorf("gc cycles want at least 1, have %q", cycles)
+		}
+		if _, err := time.ParseDuration(spans[0].Tags[string(TagRuntimeGCPause)]); err != nil {
+			t.Errorf("invalid gc pause tag: %v", err)
+		}
+	}
+
+	if want, have := 0, len(spans[1].Tags); want != have {
+		t.Errorf("short span tags want %d, have %d", want, have)
+	}
+}
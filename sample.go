@@ -69,6 +69,41 @@ func NewBoundarySampler(rate float64, salt int64) (Sampler, error) {
 	}, nil
 }
 
+// NewTraceIDSampler returns a Sampler deciding on a hash of the trace id
+// against rate. Unlike NewBoundarySampler it has no salt, so all services
+// using it at the same rate sample the same traces, even if the sampling
+// decision is not propagated, e.g. by proxies dropping the sampled header.
+// Traces sampled at a rate are also sampled at every higher rate. Trace ids
+// do not need to be random as they are hashed.
+func NewTraceIDSampler(rate float64) (Sampler, error) {
+	if rate == 0.0 {
+		return NeverSample, nil
+	}
+	if rate == 1.0 {
+		return AlwaysSample, nil
+	}
+	if rate < 0 || rate > 1 {
+		return nil, fmt.Errorf("rate should be between 0.0 and 1: was %f", rate)
+	}
+
+	// compare the 53 most significant bits of the hash, which is the
+	// precision of rate
+	boundary := uint64(rate * (1 << 53))
+	return func(id uint64) bool {
+		return mix64(id)>>11 < boundary
+	}, nil
+}
+
+// mix64 is the finalizer of SplitMix64, distributing sequential ids uniformly.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
 // NewCountingSampler is appropriate for low-traffic instrumentation or
 // those who do not provision random trace ids. It is not appropriate for
 // collectors as the sampling decision isn't idempotent (consistent based
@@ -64,6 +64,50 @@ func TestBoundarySampler(t *testing.T) {
 	}
 }
 
+func TestTraceIDSampler(t *testing.T) {
+	for _, rate := range []float64{-0.1, 1.001} {
+		_, have := zipkin.NewTraceIDSampler(rate)
+		want := fmt.Errorf("rate should be between 0.0 and 1: was %f", rate)
+		if have == nil || want.Error() != have.Error() {
+			t.Errorf("rate %f, want error %+v, got %+v", rate, want, have)
+		}
+	}
+
+	never, _ := zipkin.NewTraceIDSampler(0)
+	always, _ := zipkin.NewTraceIDSampler(1)
+	if never(1) || !always(1) {
+		t.Errorf("unexpected decisions for rate 0.0 and 1.0")
+	}
+
+	low, _ := zipkin.NewTraceIDSampler(0.1)
+	high, _ := zipkin.NewTraceIDSampler(0.5)
+	other, _ := zipkin.NewTraceIDSampler(0.5)
+
+	var lowCount, highCount int
+	// sequential trace ids to verify they are hashed
+	for id := uint64(1); id <= 100000; id++ {
+		sampledLow, sampledHigh := low(id), high(id)
+		if want, have := sampledHigh, other(id); want != have {
+			t.Fatalf("id %d: samplers with same rate disagree", id)
+		}
+		if sampledLow && !sampledHigh {
+			t.Fatalf("id %d: sampled at rate 0.1 but not at rate 0.5", id)
+		}
+		if sampledLow {
+			lowCount++
+		}
+		if sampledHigh {
+			highCount++
+		}
+	}
+	if lowCount < 9500 || lowCount > 10500 {
+		t.Errorf("rate 0.1: want about 10000 sampled, have %d", lowCount)
+	}
+	if highCount < 49000 || highCount > 51000 {
+		t.Errorf("rate 0.5: want about 50000 sampled, have %d", highCount)
+	}
+}
+
 func TestCountingSampler(t *testing.T) {
 	{
 		_, have := zipkin.NewCountingSampler(0.009)
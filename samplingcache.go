@@ -0,0 +1,83 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zipkin
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/openzipkin/zipkin-go/model"
+)
+
+// samplingEntry is a memoized sampling decision.
+type samplingEntry struct {
+	traceID model.TraceID
+	sampled bool
+}
+
+// samplingCache memoizes the sampling decisions of the most recently seen
+// traces. Once full, the least recently used decisions are evicted.
+type samplingCache struct {
+	mtx     sync.Mutex
+	size    int
+	entries map[model.TraceID]*list.Element
+	lru     *list.List
+}
+
+func newSamplingCache(size int) *samplingCache {
+	return &samplingCache{
+		size:    size,
+		entries: make(map[model.TraceID]*list.Element, size),
+		lru:     list.New(),
+	}
+}
+
+// decide returns the memoized sampling decision of traceID, invoking sampler
+// and memoizing its decision if none is known.
+func (c *samplingCache) decide(traceID model.TraceID, sampler Sampler) bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if e, ok := c.entries[traceID]; ok {
+		c.lru.MoveToFront(e)
+		return e.Value.(*samplingEntry).sampled
+	}
+	// decide while holding the lock so concurrent spans of the trace agree
+	sampled := sampler(traceID.Low)
+	c.add(traceID, sampled)
+	return sampled
+}
+
+// record memoizes a sampling decision made upstream.
+func (c *samplingCache) record(traceID model.TraceID, sampled bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if e, ok := c.entries[traceID]; ok {
+		e.Value.(*samplingEntry).sampled = sampled
+		c.lru.MoveToFront(e)
+		return
+	}
+	c.add(traceID, sampled)
+}
+
+func (c *samplingCache) add(traceID model.TraceID, sampled bool) {
+	if c.lru.Len() >= c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*samplingEntry).traceID)
+	}
+	c.entries[traceID] = c.lru.PushFront(&samplingEntry{traceID: traceID, sampled: sampled})
+}
@@ -0,0 +1,143 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zipkin
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter"
+)
+
+// alternatingSampler returns alternating decisions, regardless of trace id.
+func alternatingSampler() (Sampler, *int) {
+	var (
+		mtx   sync.Mutex
+		calls int
+	)
+	return func(uint64) bool {
+		mtx.Lock()
+		defer mtx.Unlock()
+		calls++
+		return calls%2 == 1
+	}, &calls
+}
+
+func TestSamplingCache(t *testing.T) {
+	rep := reporter.NewNoopReporter()
+	defer rep.Close()
+
+	if _, err := NewTracer(rep, WithSamplingCache(0)); err != ErrInvalidSamplingCacheSize {
+		t.Errorf("tracer creation error want %+v, have %+v", ErrInvalidSamplingCacheSize, err)
+	}
+
+	sampler, calls := alternatingSampler()
+	tr, err := NewTracer(rep, WithSampler(sampler), WithSamplingCache(2))
+	if err != nil {
+		t.Fatalf("unexpected tracer creation failure: %+v", err)
+	}
+
+	consume := func(traceID uint64) bool {
+		span := tr.StartSpan("consume", Kind(model.Consumer), Parent(model.SpanContext{
+			TraceID: model.TraceID{Low: traceID},
+			ID:      model.ID(traceID),
+		}))
+		defer span.Finish()
+		return *span.Context().Sampled
+	}
+
+	// messages of a trace get the decision of the first message
+	if want, have := true, consume(1); want != have {
+		t.Errorf("sampled want %t, have %t", want, have)
+	}
+	if want, have := false, consume(2); want != have {
+		t.Errorf("sampled want %t, have %t", want, have)
+	}
+	for i := 0; i < 3; i++ {
+		if want, have := true, consume(1); want != have {
+			t.Errorf("sampled want %t, have %t", want, have)
+		}
+		if want, have := false, consume(2); want != have {
+			t.Errorf("sampled want %t, have %t", want, have)
+		}
+	}
+	if want, have := 2, *calls; want != have {
+		t.Errorf("sampler calls want %d, have %d", want, have)
+	}
+
+	// trace 3 evicts the least recently used trace 1
+	consume(3)
+	consume(2)
+	consume(1)
+	if want, have := 4, *calls; want != have {
+		t.Errorf("sampler calls want %d, have %d", want, have)
+	}
+
+	// decisions propagated from upstream are memoized
+	notSampled := false
+	tr.StartSpan("server", Kind(model.Server), Parent(model.SpanContext{
+		TraceID: model.TraceID{Low: 4},
+		ID:      4,
+		Sampled: &notSampled,
+	})).Finish()
+	if want, have := false, consume(4); want != have {
+		t.Errorf("sampled want %t, have %t", want, have)
+	}
+	if want, have := 4, *calls; want != have {
+		t.Errorf("sampler calls want %d, have %d", want, have)
+	}
+
+	// new traces are not memoized
+	tr.StartSpan("root").Finish()
+	if want, have := 5, *calls; want != have {
+		t.Errorf("sampler calls want %d, have %d", want, have)
+	}
+	if want, have := 2, tr.samplingCache.lru.Len(); want != have {
+		t.Errorf("memoized decisions want %d, have %d", want, have)
+	}
+}
+
+func TestSamplingCacheConcurrent(t *testing.T) {
+	rep := reporter.NewNoopReporter()
+	defer rep.Close()
+
+	sampler, _ := alternatingSampler()
+	tr, err := NewTracer(rep, WithSampler(sampler), WithSamplingCache(100))
+	if err != nil {
+		t.Fatalf("unexpected tracer creation failure: %+v", err)
+	}
+
+	var (
+		wg        sync.WaitGroup
+		mtx       sync.Mutex
+		decisions = make(map[bool]int)
+	)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			span := tr.StartSpan("consume", Parent(model.SpanContext{TraceID: model.TraceID{Low: 7}, ID: 7}))
+			mtx.Lock()
+			decisions[*span.Context().Sampled]++
+			mtx.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if want, have := 1, len(decisions); want != have {
+		t.Errorf("distinct decisions want %d, have %d", want, have)
+	}
+}
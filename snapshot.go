@@ -0,0 +1,74 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zipkin
+
+import (
+	"net"
+
+	"github.com/openzipkin/zipkin-go/model"
+)
+
+// Snapshot returns a copy of the current state of s, which may not have been
+// finished yet, e.g. for periodic progress reporting or debugging dumps. The
+// copy shares no memory with s so it is safe to inspect while s is modified by
+// other goroutines. The Duration of unfinished spans is zero. Snapshot returns
+// false if s does not record data, e.g. for a NoopSpan.
+func Snapshot(s Span) (model.SpanModel, bool) {
+	switch impl := s.(type) {
+	case *spanImpl:
+		return impl.snapshot(), true
+	case *teeSpan:
+		return Snapshot(impl.primary)
+	}
+	return model.SpanModel{}, false
+}
+
+// snapshot returns a deep copy of the span model including the trace tags.
+func (s *spanImpl) snapshot() model.SpanModel {
+	s.mtx.RLock()
+	span := s.SpanModel
+	span.Tags = copyTags(s.root().withTraceTags(s.Tags))
+	span.Annotations = append([]model.Annotation(nil), s.Annotations...)
+	if s.ParentID != nil {
+		parentID := *s.ParentID
+		span.ParentID = &parentID
+	}
+	if s.Sampled != nil {
+		sampled := *s.Sampled
+		span.Sampled = &sampled
+	}
+	span.LocalEndpoint = copyEndpoint(s.LocalEndpoint)
+	span.RemoteEndpoint = copyEndpoint(s.RemoteEndpoint)
+	s.mtx.RUnlock()
+	return span
+}
+
+func copyEndpoint(e *model.Endpoint) *model.Endpoint {
+	if e == nil {
+		return nil
+	}
+	c := *e
+	c.IPv4 = append(net.IP(nil), e.IPv4...)
+	c.IPv6 = append(net.IP(nil), e.IPv6...)
+	return &c
+}
+
+func copyTags(tags map[string]string) map[string]string {
+	c := make(map[string]string, len(tags))
+	for k, v := range tags {
+		c[k] = v
+	}
+	return c
+}
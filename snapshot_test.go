@@ -0,0 +1,63 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zipkin
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter/recorder"
+)
+
+func TestSnapshot(t *testing.T) {
+	ep, _ := NewEndpoint("svc", "127.0.0.1:80")
+	tr, _ := NewTracer(recorder.NewReporter(), WithLocalEndpoint(ep))
+
+	span, ctx := tr.StartSpanFromContext(context.Background(), "snapshot", Kind(model.Client))
+	span.Tag("key", "value")
+	span.Annotate(time.Now(), "event")
+	SetTraceTag(ctx, "tenant", "acme")
+
+	snapshot, ok := Snapshot(span)
+	if !ok {
+		t.Fatal("expected snapshot")
+	}
+
+	span.Tag("key", "changed")
+	span.Annotate(time.Now(), "later")
+	span.Finish()
+
+	if want, have := "value", snapshot.Tags["key"]; want != have {
+		t.Errorf("tag want %q, have %q", want, have)
+	}
+	if want, have := "acme", snapshot.Tags["tenant"]; want != have {
+		t.Errorf("trace tag want %q, have %q", want, have)
+	}
+	if want, have := 1, len(snapshot.Annotations); want != have {
+		t.Errorf("annotations want %d, have %d", want, have)
+	}
+	if want, have := time.Duration(0), snapshot.Duration; want != have {
+		t.Errorf("duration want %s, have %s", want, have)
+	}
+	if snapshot.LocalEndpoint == ep || !snapshot.LocalEndpoint.IPv4.Equal(ep.IPv4) {
+		t.Errorf("local endpoint want copy of %+v, have %+v", ep, snapshot.LocalEndpoint)
+	}
+
+	if _, ok = Snapshot(&NoopSpan{}); ok {
+		t.Error("expected no snapshot of noop span")
+	}
+}
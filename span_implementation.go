@@ -15,6 +15,9 @@
 package zipkin
 
 import (
+	"context"
+	"runtime/trace"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -28,13 +31,69 @@ type spanImpl struct {
 	tracer        *Tracer
 	mustCollect   int32 // used as atomic bool (1 = true, 0 = false)
 	flushOnFinish bool
+	finished      bool             // guarded by mtx, set once Finish has been called
+	debug         *bool            // explicit debug flag requested by the Debug option
+	shared        *bool            // explicit shared flag requested by the Shared option
+	localRoot     *spanImpl        // local root span of the trace, nil if this is the root
+	runtimeStart  *runtimeSnapshot // runtime statistics at start, see WithRuntimeMetrics
+	ctx           context.Context  // context watched for cancellation, see WithContextTags
+	task          *trace.Task      // execution trace task, see WithExecutionTrace
+	identity      bool             // ids taken over from another span, see NewTeeTracer
+	sampled       bool             // sampling decision of the tracer, referenced by Sampled
+	parentID      model.ID         // id of the parent span, referenced by ParentID
+	depth         int              // number of local ancestors, see WithMaxTraceDepth
+	truncated     int32            // number of child spans not created, see WithMaxTraceDepth
+
+	traceTagsMtx sync.Mutex
+	traceTags    map[string]string // tags set by SetTraceTag, held by local roots
+}
+
+// spanPool recycles the spans of unsampled traces by tracers returning
+// NoopSpans, which are released right after the sampling decision.
+var spanPool = sync.Pool{
+	New: func() interface{} { return &spanImpl{} },
+}
+
+// newSpanImpl returns a span named name of tracer t.
+func newSpanImpl(t *Tracer, name string) *spanImpl {
+	s := spanPool.Get().(*spanImpl)
+	tags := s.Tags
+	if tags == nil {
+		tags = make(map[string]string)
+	}
+	s.SpanModel = model.SpanModel{
+		Kind:          model.Undetermined,
+		Name:          name,
+		LocalEndpoint: t.localEndpoint,
+		Annotations:   make([]model.Annotation, 0),
+		Tags:          tags,
+	}
+	s.flushOnFinish = true
+	s.tracer = t
+	return s
+}
+
+// release returns a span which has not been handed out to the pool.
+func (s *spanImpl) release() {
+	tags := s.Tags
+	for k := range tags {
+		delete(tags, k)
+	}
+	*s = spanImpl{}
+	s.Tags = tags
+	spanPool.Put(s)
 }
 
 func (s *spanImpl) Context() model.SpanContext {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
 	return s.SpanContext
 }
 
 func (s *spanImpl) SetName(name string) {
+	if s.tracer.nameGuard != nil {
+		name = s.tracer.nameGuard.guard(name)
+	}
 	s.mtx.Lock()
 	s.Name = name
 	s.mtx.Unlock()
@@ -77,25 +136,113 @@ func (s *spanImpl) Tag(key, value string) {
 }
 
 func (s *spanImpl) Finish() {
-	if atomic.CompareAndSwapInt32(&s.mustCollect, 1, 0) {
-		s.Duration = time.Since(s.Timestamp)
-		if s.flushOnFinish {
-			s.tracer.reporter.Send(s.SpanModel)
-		}
-	}
+	s.finish(time.Since(s.Timestamp))
 }
 
 func (s *spanImpl) FinishedWithDuration(d time.Duration) {
-	if atomic.CompareAndSwapInt32(&s.mustCollect, 1, 0) {
+	s.finish(d)
+}
+
+func (s *spanImpl) finish(d time.Duration) {
+	s.mtx.Lock()
+	s.finished = true
+	collect := atomic.CompareAndSwapInt32(&s.mustCollect, 1, 0)
+	if collect {
 		s.Duration = d
-		if s.flushOnFinish {
-			s.tracer.reporter.Send(s.SpanModel)
+		if s.runtimeStart != nil && d >= s.tracer.runtimeMinDuration {
+			tagRuntimeDeltas(s.Tags, *s.runtimeStart)
+		}
+		if s.ctx != nil && s.tracer.contextTags {
+			if err := s.ctx.Err(); err != nil {
+				s.Tags[string(TagContextError)] = err.Error()
+			}
+		}
+		if n := atomic.LoadInt32(&s.truncated); n > 0 {
+			s.Tags[string(TagTruncatedSpans)] = strconv.Itoa(int(n))
+		}
+	}
+	span := s.SpanModel
+	s.mtx.Unlock()
+
+	if collect {
+		span.Tags = s.root().withTraceTags(span.Tags)
+		if s.task != nil {
+			s.task.End()
+		}
+	}
+	if collect && s.flushOnFinish {
+		if s.tracer.stats != nil {
+			s.tracer.stats.reported(&span)
 		}
+		s.tracer.reporter.Send(span)
+	}
+}
+
+// root returns the local root span of the trace.
+func (s *spanImpl) root() *spanImpl {
+	if s.localRoot != nil {
+		return s.localRoot
+	}
+	return s
+}
+
+// setTraceTag records a tag for all spans of the local trace finished after.
+func (s *spanImpl) setTraceTag(key, value string) {
+	s.traceTagsMtx.Lock()
+	if s.traceTags == nil {
+		s.traceTags = make(map[string]string)
+	}
+	s.traceTags[key] = value
+	s.traceTagsMtx.Unlock()
+}
+
+// withTraceTags returns a copy of tags including the trace tags. Tags set on
+// the span itself take precedence.
+func (s *spanImpl) withTraceTags(tags map[string]string) map[string]string {
+	s.traceTagsMtx.Lock()
+	defer s.traceTagsMtx.Unlock()
+
+	if len(s.traceTags) == 0 {
+		return tags
+	}
+	merged := make(map[string]string, len(tags)+len(s.traceTags))
+	for k, v := range s.traceTags {
+		merged[k] = v
+	}
+	for k, v := range tags {
+		merged[k] = v
+	}
+	return merged
+}
+
+// setSampled overrides the sampling decision of an unfinished span. Finished
+// spans keep their sampling decision so they are not reported twice.
+func (s *spanImpl) setSampled(sampled bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.finished {
+		return
+	}
+	if !sampled {
+		s.Debug = false
+	}
+	s.Sampled = &sampled
+
+	if sampled {
+		atomic.CompareAndSwapInt32(&s.mustCollect, 0, 1)
+	} else {
+		atomic.StoreInt32(&s.mustCollect, 0)
 	}
 }
 
 func (s *spanImpl) Flush() {
-	if s.SpanModel.Debug || (s.SpanModel.Sampled != nil && *s.SpanModel.Sampled) {
-		s.tracer.reporter.Send(s.SpanModel)
+	s.mtx.RLock()
+	span := s.SpanModel
+	s.mtx.RUnlock()
+
+	if span.Debug || (span.Sampled != nil && *span.Sampled) {
+		span.Tags = s.root().withTraceTags(span.Tags)
+		s.tracer.reporter.Send(span)
 	}
 }
@@ -15,6 +15,7 @@
 package zipkin
 
 import (
+	"context"
 	"time"
 
 	"github.com/openzipkin/zipkin-go/model"
@@ -34,21 +35,7 @@ func Kind(kind model.Kind) SpanOption {
 // Parent will use provided SpanContext as parent to the span being created.
 func Parent(sc model.SpanContext) SpanOption {
 	return func(t *Tracer, s *spanImpl) {
-		if sc.Err != nil {
-			// encountered an extraction error
-			switch t.extractFailurePolicy {
-			case ExtractFailurePolicyRestart:
-			case ExtractFailurePolicyError:
-				panic(s.SpanContext.Err)
-			case ExtractFailurePolicyTagAndRestart:
-				s.Tags["error.extract"] = sc.Err.Error()
-			default:
-				panic(ErrInvalidExtractFailurePolicy)
-			}
-			/* don't use provided SpanContext, but restart trace */
-			return
-		}
-		s.SpanContext = sc
+		s.setParent(t, sc)
 	}
 }
 
@@ -86,3 +73,88 @@ func FlushOnFinish(b bool) SpanOption {
 		s.flushOnFinish = b
 	}
 }
+
+// Debug explicitly sets the debug flag of the span being created, overriding
+// the debug flag of a propagated parent SpanContext. Debug spans are always
+// reported and the flag propagates downstream.
+func Debug(debug bool) SpanOption {
+	return func(t *Tracer, s *spanImpl) {
+		s.debug = &debug
+	}
+}
+
+// Shared explicitly sets whether the span being created shares its span id
+// with the parent span, overriding the WithSharedSpans tracer option. It has
+// no effect for root spans as only spans with a parent can be shared.
+func Shared(shared bool) SpanOption {
+	return func(t *Tracer, s *spanImpl) {
+		s.shared = &shared
+	}
+}
+
+// WatchContext sets the context the span is executed in. If enabled by the
+// WithContextTags tracer option, the span is tagged with the time remaining
+// until the deadline of ctx at start and with the error of ctx if it was
+// canceled or its deadline expired by the time the span finishes. Spans started
+// by StartSpanFromContext watch their context automatically.
+func WatchContext(ctx context.Context) SpanOption {
+	return func(t *Tracer, s *spanImpl) {
+		s.ctx = ctx
+	}
+}
+
+// identity makes the span being created take over the ids and the sampling
+// decision of sc, used by the tee tracer to record a span on two tracers. It
+// needs to be applied last and has no effect if sc is not valid.
+func identity(sc model.SpanContext, shared bool) SpanOption {
+	return func(t *Tracer, s *spanImpl) {
+		if !sc.IsValid() {
+			return
+		}
+		s.SpanContext = sc
+		s.Shared = shared
+		s.identity = true
+	}
+}
+
+// setParent uses sc as parent of the span being created, see Parent.
+func (s *spanImpl) setParent(t *Tracer, sc model.SpanContext) {
+	if sc.Err != nil {
+		// encountered an extraction error
+		switch t.extractFailurePolicy {
+		case ExtractFailurePolicyRestart:
+		case ExtractFailurePolicyError:
+			panic(s.SpanContext.Err)
+		case ExtractFailurePolicyTagAndRestart:
+			s.Tags["error.extract"] = sc.Err.Error()
+		default:
+			panic(ErrInvalidExtractFailurePolicy)
+		}
+		/* don't use provided SpanContext, but restart trace */
+		return
+	}
+	s.SpanContext = sc
+	s.SpanContext.Remote = false
+}
+
+// inheritTags copies the inheritable tags of the parent span, see
+// WithInheritableTags.
+func (s *spanImpl) inheritTags(t *Tracer, parent *spanImpl) {
+	parent.mtx.RLock()
+	defer parent.mtx.RUnlock()
+
+	for _, key := range t.inheritableTags {
+		if value, ok := parent.Tags[key]; ok {
+			s.Tags[key] = value
+		}
+	}
+}
+
+// overrideSampling enforces the sampling decision requested by ForceSample or
+// Suppress. It needs to be applied after the parent.
+func (s *spanImpl) overrideSampling(sampled bool) {
+	if !sampled {
+		s.Debug = false
+	}
+	s.Sampled = &sampled
+}
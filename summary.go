@@ -0,0 +1,147 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zipkin
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter"
+)
+
+// summaryTopNames is the number of span names listed by the span summary.
+const summaryTopNames = 10
+
+// nameStats holds the statistics of the reported spans of a name.
+type nameStats struct {
+	name     string
+	count    uint64
+	duration time.Duration
+}
+
+// spanStats collects the statistics logged by the span summary.
+type spanStats struct {
+	logger reporter.Logger
+
+	mtx     sync.Mutex
+	total   uint64
+	sampled uint64
+	dropped uint64
+	names   map[string]*nameStats
+}
+
+func newSpanStats(logger reporter.Logger) *spanStats {
+	return &spanStats{logger: logger, names: make(map[string]*nameStats)}
+}
+
+// started records a started span.
+func (s *spanStats) started(sampled bool) {
+	s.mtx.Lock()
+	s.total++
+	if sampled {
+		s.sampled++
+	}
+	s.mtx.Unlock()
+}
+
+// reported records a span handed to the reporter.
+func (s *spanStats) reported(span *model.SpanModel) {
+	s.mtx.Lock()
+	stats, ok := s.names[span.Name]
+	if !ok {
+		stats = &nameStats{name: span.Name}
+		s.names[span.Name] = stats
+	}
+	stats.count++
+	stats.duration += span.Duration
+	s.mtx.Unlock()
+}
+
+// drop records a span dropped by the reporter.
+func (s *spanStats) drop() {
+	s.mtx.Lock()
+	s.dropped++
+	s.mtx.Unlock()
+}
+
+// log logs the span summary.
+func (s *spanStats) log() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	var sampledPct float64
+	if s.total > 0 {
+		sampledPct = float64(s.sampled) * 100 / float64(s.total)
+	}
+	s.logger.Printf("span summary: %d spans, %d sampled (%.1f%%), %d dropped\n", s.total, s.sampled, sampledPct, s.dropped)
+	if len(s.names) == 0 {
+		return
+	}
+
+	names := make([]*nameStats, 0, len(s.names))
+	for _, stats := range s.names {
+		names = append(names, stats)
+	}
+
+	sort.Slice(names, func(i, j int) bool {
+		if names[i].count != names[j].count {
+			return names[i].count > names[j].count
+		}
+		return names[i].name < names[j].name
+	})
+	s.logger.Printf("top span names by count:\n")
+	s.logTop(names)
+
+	sort.Slice(names, func(i, j int) bool {
+		if names[i].duration != names[j].duration {
+			return names[i].duration > names[j].duration
+		}
+		return names[i].name < names[j].name
+	})
+	s.logger.Printf("top span names by duration:\n")
+	s.logTop(names)
+}
+
+func (s *spanStats) logTop(names []*nameStats) {
+	if len(names) > summaryTopNames {
+		names = names[:summaryTopNames]
+	}
+	for _, stats := range names {
+		s.logger.Printf("  %s: %d spans, %s total, %s avg\n",
+			stats.name, stats.count, stats.duration, stats.duration/time.Duration(stats.count))
+	}
+}
+
+// SpanDropped records a span the reporter failed to deliver in the span
+// summary enabled by WithSpanSummary. Its signature matches the drop callbacks
+// of the reporters, e.g. zipkinhttp.OnDrop.
+func (t *Tracer) SpanDropped(_ model.SpanModel, _ error) {
+	if t.stats != nil {
+		t.stats.drop()
+	}
+}
+
+// Close flushes the reporter like Flush and logs the span summary if enabled
+// by WithSpanSummary. It does not close the reporter, which is owned by the
+// caller of NewTracer, and returns the error of the flush.
+func (t *Tracer) Close() error {
+	err := t.Flush()
+	if t.stats != nil {
+		t.stats.log()
+	}
+	return err
+}
@@ -0,0 +1,96 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zipkin
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter"
+	"github.com/openzipkin/zipkin-go/reporter/recorder"
+)
+
+func TestSpanSummary(t *testing.T) {
+	var lines []string
+	logger := reporter.LoggerFunc(func(format string, v ...interface{}) {
+		lines = append(lines, strings.TrimSuffix(fmt.Sprintf(format, v...), "\n"))
+	})
+
+	sampled := true
+	tracer, err := NewTracer(recorder.NewReporter(), WithSpanSummary(logger), WithSampler(NeverSample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 12; i++ {
+		name := fmt.Sprintf("op-%02d", i)
+		for j := 0; j <= i; j++ {
+			tracer.StartSpan(name, Parent(model.SpanContext{
+				TraceID: model.TraceID{Low: 1},
+				ID:      1,
+				Sampled: &sampled,
+			})).FinishedWithDuration(time.Duration(12-i) * time.Second)
+		}
+	}
+	for i := 0; i < 22; i++ {
+		tracer.StartSpan("unsampled").Finish()
+	}
+	tracer.SpanDropped(model.SpanModel{}, errors.New("collector unavailable"))
+
+	if err := tracer.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{
+		"span summary: 100 spans, 78 sampled (78.0%), 1 dropped",
+		"top span names by count:",
+		"  op-11: 12 spans, 12s total, 1s avg",
+	}
+	if len(lines) < len(want) {
+		t.Fatalf("summary lines want at least %d, have %v", len(want), lines)
+	}
+	for i := range want {
+		if want, have := want[i], lines[i]; want != have {
+			t.Errorf("summary line %d want %q, have %q", i, want, have)
+		}
+	}
+
+	// 1 + 10 names by count + 1 + 10 names by duration
+	if want, have := 1+1+summaryTopNames+1+summaryTopNames, len(lines); want != have {
+		t.Fatalf("summary lines want %d, have %d", want, have)
+	}
+	if want, have := "top span names by duration:", lines[12]; want != have {
+		t.Errorf("summary line want %q, have %q", want, have)
+	}
+	// op-05 with 6 spans of 7s ties with op-06 with 7 spans of 6s
+	if want, have := "  op-05: 6 spans, 42s total, 7s avg", lines[13]; want != have {
+		t.Errorf("summary line want %q, have %q", want, have)
+	}
+}
+
+func TestSpanSummaryDisabled(t *testing.T) {
+	tracer, err := NewTracer(recorder.NewReporter())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracer.StartSpan("op").Finish()
+	tracer.SpanDropped(model.SpanModel{}, nil)
+	if err := tracer.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
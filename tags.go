@@ -29,6 +29,23 @@ const (
 	TagGRPCStatusCode   Tag = "grpc.status_code"
 	TagSQLQuery         Tag = "sql.query"
 	TagError            Tag = "error"
+	TagErrorExpected    Tag = "error.expected"
+
+	TagContextError             Tag = "context.error"
+	TagContextDeadlineRemaining Tag = "context.deadline.remaining"
+
+	// TagTraceIDDowngraded is set on client spans whose 128-bit trace id was
+	// propagated downstream as 64-bit trace id.
+	TagTraceIDDowngraded Tag = "b3.trace_id.downgraded"
+
+	// TagHTTPPreflights holds the number of CORS preflight requests received
+	// since the previous request tagged, see the Preflight option of the http
+	// middleware.
+	TagHTTPPreflights Tag = "http.preflights"
+
+	// TagTruncatedSpans holds the number of child spans which were not created
+	// as they exceeded the maximum trace depth, see WithMaxTraceDepth.
+	TagTruncatedSpans Tag = "trace.truncated_spans"
 )
 
 // Set a standard Tag with a payload on provided Span.
@@ -0,0 +1,112 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zipkin
+
+import (
+	"context"
+	"time"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/propagation"
+)
+
+// teeTracer records spans on two tracers.
+type teeTracer struct {
+	primary   TracerInterface
+	secondary TracerInterface
+}
+
+// NewTeeTracer returns a tracer recording every span on both the primary and
+// the secondary tracer, e.g. to run two tracing pipelines side by side during
+// a migration. The primary tracer decides the ids and the sampling of the
+// spans, which the secondary tracer takes over if it is a Tracer. Extract and
+// LocalEndpoint are served by the primary tracer.
+func NewTeeTracer(primary, secondary TracerInterface) TracerInterface {
+	return &teeTracer{primary: primary, secondary: secondary}
+}
+
+func (t *teeTracer) StartSpan(name string, options ...SpanOption) Span {
+	span := t.primary.StartSpan(name, options...)
+	return t.tee(span, name, options)
+}
+
+func (t *teeTracer) StartSpanFromContext(ctx context.Context, name string, options ...SpanOption) (Span, context.Context) {
+	span, ctx := t.primary.StartSpanFromContext(ctx, name, options...)
+	tee := t.tee(span, name, options)
+	return tee, NewContext(ctx, tee)
+}
+
+// tee starts the secondary span of the primary span.
+func (t *teeTracer) tee(primary Span, name string, options []SpanOption) Span {
+	var shared bool
+	if s, ok := primary.(*spanImpl); ok {
+		shared = s.Shared
+	}
+	opts := make([]SpanOption, 0, len(options)+1)
+	opts = append(opts, options...)
+	opts = append(opts, identity(primary.Context(), shared))
+	return &teeSpan{primary: primary, secondary: t.secondary.StartSpan(name, opts...)}
+}
+
+func (t *teeTracer) Extract(extractor propagation.Extractor) model.SpanContext {
+	return t.primary.Extract(extractor)
+}
+
+func (t *teeTracer) LocalEndpoint() *model.Endpoint {
+	return t.primary.LocalEndpoint()
+}
+
+// teeSpan records on the spans of both tracers of a teeTracer.
+type teeSpan struct {
+	primary   Span
+	secondary Span
+}
+
+func (s *teeSpan) Context() model.SpanContext { return s.primary.Context() }
+
+func (s *teeSpan) SetName(name string) {
+	s.primary.SetName(name)
+	s.secondary.SetName(name)
+}
+
+func (s *teeSpan) SetRemoteEndpoint(e *model.Endpoint) {
+	s.primary.SetRemoteEndpoint(e)
+	s.secondary.SetRemoteEndpoint(e)
+}
+
+func (s *teeSpan) Annotate(t time.Time, value string) {
+	s.primary.Annotate(t, value)
+	s.secondary.Annotate(t, value)
+}
+
+func (s *teeSpan) Tag(key, value string) {
+	s.primary.Tag(key, value)
+	s.secondary.Tag(key, value)
+}
+
+func (s *teeSpan) Finish() {
+	s.primary.Finish()
+	s.secondary.Finish()
+}
+
+func (s *teeSpan) FinishedWithDuration(duration time.Duration) {
+	s.primary.FinishedWithDuration(duration)
+	s.secondary.FinishedWithDuration(duration)
+}
+
+func (s *teeSpan) Flush() {
+	s.primary.Flush()
+	s.secondary.Flush()
+}
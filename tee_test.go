@@ -0,0 +1,87 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zipkin
+
+import (
+	"context"
+	"testing"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter/recorder"
+)
+
+func TestTeeTracer(t *testing.T) {
+	var (
+		recA, recB = recorder.NewReporter(), recorder.NewReporter()
+		trA, _     = NewTracer(recA)
+		trB, _     = NewTracer(recB)
+		tr         = NewTeeTracer(trA, trB)
+	)
+
+	parent, ctx := tr.StartSpanFromContext(context.Background(), "parent")
+	child, _ := tr.StartSpanFromContext(ctx, "child", Kind(model.Client))
+	child.Tag("key", "value")
+	child.Finish()
+	parent.Finish()
+
+	spansA, spansB := recA.Flush(), recB.Flush()
+	if want, have := 2, len(spansA); want != have {
+		t.Fatalf("primary spans want %d, have %d", want, have)
+	}
+	if want, have := 2, len(spansB); want != have {
+		t.Fatalf("secondary spans want %d, have %d", want, have)
+	}
+	for i := range spansA {
+		if want, have := spansA[i].SpanContext, spansB[i].SpanContext; want.TraceID != have.TraceID || want.ID != have.ID {
+			t.Errorf("span %d: context want %+v, have %+v", i, want, have)
+		}
+		if want, have := spansA[i].Name, spansB[i].Name; want != have {
+			t.Errorf("span %d: name want %q, have %q", i, want, have)
+		}
+	}
+	if spansB[0].ParentID == nil || *spansB[0].ParentID != spansA[1].ID {
+		t.Errorf("secondary child parent id want %s, have %v", spansA[1].ID, spansB[0].ParentID)
+	}
+	if want, have := "value", spansB[0].Tags["key"]; want != have {
+		t.Errorf("secondary tag want %q, have %q", want, have)
+	}
+	if want, have := model.Client, spansB[0].Kind; want != have {
+		t.Errorf("secondary kind want %q, have %q", want, have)
+	}
+}
+
+func TestTeeTracerSharedSpan(t *testing.T) {
+	var (
+		recA, recB = recorder.NewReporter(), recorder.NewReporter()
+		trA, _     = NewTracer(recA)
+		trB, _     = NewTracer(recB)
+		tr         = NewTeeTracer(trA, trB)
+		sampled    = true
+		parent     = model.SpanContext{TraceID: model.TraceID{Low: 1}, ID: 2, Sampled: &sampled}
+	)
+
+	tr.StartSpan("server", Kind(model.Server), Parent(parent)).Finish()
+
+	spansB := recB.Flush()
+	if want, have := 1, len(spansB); want != have {
+		t.Fatalf("secondary spans want %d, have %d", want, have)
+	}
+	if want, have := parent.ID, spansB[0].ID; want != have {
+		t.Errorf("secondary span id want %s, have %s", want, have)
+	}
+	if !spansB[0].Shared {
+		t.Error("expected secondary span to be shared")
+	}
+}
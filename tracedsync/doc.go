@@ -0,0 +1,32 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package tracedsync provides a mutex and a semaphore which record lock contention
+on the span found in the context, exposing time spent waiting for locks inside
+traces for performance investigations.
+
+Waits exceeding the configured threshold are recorded as a pair of annotations
+on the span in the context, marking the start and the end of the wait, or as a
+child span if a tracer is provided using the ChildSpans option.
+
+	var mu = tracedsync.NewMutex(tracedsync.Name("cache"), tracedsync.Threshold(time.Millisecond))
+
+	func lookup(ctx context.Context, key string) string {
+		mu.Lock(ctx)
+		defer mu.Unlock()
+		...
+	}
+*/
+package tracedsync
@@ -0,0 +1,148 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracedsync
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	zipkin "github.com/openzipkin/zipkin-go"
+)
+
+// TagWait holds the wait time on child spans created by the ChildSpans option.
+const TagWait zipkin.Tag = "lock.wait"
+
+const defaultName = "lock"
+
+// config holds the shared configuration of the traced primitives.
+type config struct {
+	name      string
+	threshold time.Duration
+	tracer    zipkin.TracerInterface
+}
+
+// Option allows one to configure the traced primitives.
+type Option func(*config)
+
+// Name sets the name of the lock used for annotations and child spans.
+// Defaults to "lock".
+func Name(name string) Option {
+	return func(c *config) {
+		if name != "" {
+			c.name = name
+		}
+	}
+}
+
+// Threshold sets the minimum wait time being recorded. By default every wait
+// is recorded, even if the lock was acquired without contention.
+func Threshold(d time.Duration) Option {
+	return func(c *config) {
+		c.threshold = d
+	}
+}
+
+// ChildSpans records waits as child spans of the span found in the context,
+// created with tracer, instead of annotations.
+func ChildSpans(tracer zipkin.TracerInterface) Option {
+	return func(c *config) {
+		c.tracer = tracer
+	}
+}
+
+func newConfig(options []Option) config {
+	c := config{name: defaultName}
+	for _, option := range options {
+		option(&c)
+	}
+	return c
+}
+
+// record records a wait started at start on the span found in ctx.
+func (c *config) record(ctx context.Context, start time.Time) {
+	end := time.Now()
+	wait := end.Sub(start)
+	if wait < c.threshold {
+		return
+	}
+	span := zipkin.SpanFromContext(ctx)
+	if span == nil {
+		return
+	}
+	if c.tracer != nil {
+		child, _ := c.tracer.StartSpanFromContext(ctx, c.name+" wait", zipkin.StartTime(start))
+		TagWait.Set(child, wait.String())
+		child.FinishedWithDuration(wait)
+		return
+	}
+	span.Annotate(start, c.name+".wait")
+	span.Annotate(end, c.name+".acquired")
+}
+
+// Mutex is a mutual exclusion lock recording the time spent waiting for the
+// lock on the span found in the context passed to Lock.
+type Mutex struct {
+	mu     sync.Mutex
+	config config
+}
+
+// NewMutex returns a new unlocked Mutex.
+func NewMutex(options ...Option) *Mutex {
+	return &Mutex{config: newConfig(options)}
+}
+
+// Lock locks m and records the wait on the span found in ctx.
+func (m *Mutex) Lock(ctx context.Context) {
+	start := time.Now()
+	m.mu.Lock()
+	m.config.record(ctx, start)
+}
+
+// Unlock unlocks m.
+func (m *Mutex) Unlock() {
+	m.mu.Unlock()
+}
+
+// Semaphore is a counting semaphore recording the time spent waiting for a
+// slot on the span found in the context passed to Acquire.
+type Semaphore struct {
+	slots  chan struct{}
+	config config
+}
+
+// NewSemaphore returns a new Semaphore allowing n concurrent holders.
+func NewSemaphore(n int, options ...Option) *Semaphore {
+	return &Semaphore{slots: make(chan struct{}, n), config: newConfig(options)}
+}
+
+// Acquire blocks until a slot is available or ctx is done and records the
+// wait on the span found in ctx. On success it returns nil, otherwise the
+// error of ctx is returned and no slot is held.
+func (s *Semaphore) Acquire(ctx context.Context) error {
+	start := time.Now()
+	select {
+	case s.slots <- struct{}{}:
+		s.config.record(ctx, start)
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Release releases a slot acquired by Acquire.
+func (s *Semaphore) Release() {
+	<-s.slots
+}
@@ -0,0 +1,100 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracedsync_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	zipkin "github.com/openzipkin/zipkin-go"
+	"github.com/openzipkin/zipkin-go/reporter/recorder"
+	"github.com/openzipkin/zipkin-go/tracedsync"
+)
+
+func TestMutex(t *testing.T) {
+	rec := recorder.NewReporter()
+	defer rec.Close()
+
+	tracer, _ := zipkin.NewTracer(rec)
+	mu := tracedsync.NewMutex(tracedsync.Name("cache"), tracedsync.Threshold(10*time.Millisecond))
+
+	span, ctx := tracer.StartSpanFromContext(context.Background(), "uncontended")
+	mu.Lock(ctx)
+	mu.Unlock()
+	span.Finish()
+
+	span, ctx = tracer.StartSpanFromContext(context.Background(), "contended")
+	mu.Lock(context.Background())
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		mu.Unlock()
+	}()
+	mu.Lock(ctx)
+	mu.Unlock()
+	span.Finish()
+
+	spans := rec.Flush()
+	if want, have := 2, len(spans); want != have {
+		t.Fatalf("reported spans want %d, have %d", want, have)
+	}
+	if want, have := 0, len(spans[0].Annotations); want != have {
+		t.Errorf("uncontended annotations want %d, have %d", want, have)
+	}
+	if want, have := 2, len(spans[1].Annotations); want != have {
+		t.Fatalf("contended annotations want %d, have %d", want, have)
+	}
+	if want, have := "cache.wait", spans[1].Annotations[0].Value; want != have {
+		t.Errorf("annotation want %q, have %q", want, have)
+	}
+	if want, have := "cache.acquired", spans[1].Annotations[1].Value; want != have {
+		t.Errorf("annotation want %q, have %q", want, have)
+	}
+}
+
+func TestSemaphoreChildSpans(t *testing.T) {
+	rec := recorder.NewReporter()
+	defer rec.Close()
+
+	tracer, _ := zipkin.NewTracer(rec)
+	sem := tracedsync.NewSemaphore(1, tracedsync.ChildSpans(tracer))
+
+	span, ctx := tracer.StartSpanFromContext(context.Background(), "parent")
+	if err := sem.Acquire(ctx); err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+
+	timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if want, have := context.DeadlineExceeded, sem.Acquire(timeout); want != have {
+		t.Errorf("acquire want %v, have %v", want, have)
+	}
+	sem.Release()
+	span.Finish()
+
+	spans := rec.Flush()
+	if want, have := 2, len(spans); want != have {
+		t.Fatalf("reported spans want %d, have %d", want, have)
+	}
+	if want, have := "lock wait", spans[0].Name; want != have {
+		t.Errorf("child span name want %q, have %q", want, have)
+	}
+	if spans[0].ParentID == nil || *spans[0].ParentID != spans[1].ID {
+		t.Errorf("child span parent want %s, have %v", spans[1].ID, spans[0].ParentID)
+	}
+	if _, found := spans[0].Tags[string(tracedsync.TagWait)]; !found {
+		t.Errorf("expected tag %s", tracedsync.TagWait)
+	}
+}
@@ -16,6 +16,7 @@ package zipkin
 
 import (
 	"context"
+	"runtime/trace"
 	"sync/atomic"
 	"time"
 
@@ -25,6 +26,27 @@ import (
 	"github.com/openzipkin/zipkin-go/reporter"
 )
 
+// TracerInterface describes the methods of Tracer used by instrumentation. The
+// middleware and helper packages accept a TracerInterface, so a Tracer can be
+// wrapped with decorators, e.g. for metrics or auditing, or replaced by a
+// NoopTracer.
+type TracerInterface interface {
+	// StartSpan creates and starts a span.
+	StartSpan(name string, options ...SpanOption) Span
+	// StartSpanFromContext creates and starts a span using the span found in
+	// context as parent and returns the span and a context holding it.
+	StartSpanFromContext(ctx context.Context, name string, options ...SpanOption) (Span, context.Context)
+	// Extract extracts a SpanContext using the provided Extractor function.
+	Extract(extractor propagation.Extractor) model.SpanContext
+	// LocalEndpoint returns a copy of the local endpoint of the tracer.
+	LocalEndpoint() *model.Endpoint
+}
+
+var (
+	_ TracerInterface = (*Tracer)(nil)
+	_ TracerInterface = NoopTracer{}
+)
+
 // Tracer is our Zipkin tracer implementation. It should be initialized using
 // the NewTracer method.
 type Tracer struct {
@@ -37,6 +59,17 @@ type Tracer struct {
 	noop                 int32 // used as atomic bool (1 = true, 0 = false)
 	sharedSpans          bool
 	unsampledNoop        bool
+	idTracker            *spanIDTracker
+	nameGuard            *spanNameGuard
+	verbose              func(sc model.SpanContext) bool
+	runtimeMetrics       bool
+	runtimeMinDuration   time.Duration
+	contextTags          bool
+	executionTrace       bool
+	inheritableTags      []string
+	stats                *spanStats
+	samplingCache        *samplingCache
+	maxDepth             int
 }
 
 // NewTracer returns a new Zipkin Tracer.
@@ -52,6 +85,7 @@ func NewTracer(rep reporter.Reporter, opts ...TracerOption) (*Tracer, error) {
 		noop:                 0,
 		sharedSpans:          true,
 		unsampledNoop:        false,
+		verbose:              debugOnly,
 	}
 
 	// if no reporter was provided we default to noop implementation.
@@ -73,75 +107,177 @@ func NewTracer(rep reporter.Reporter, opts ...TracerOption) (*Tracer, error) {
 // StartSpanFromContext creates and starts a span using the span found in
 // context as parent. If no parent span is found a root span is created.
 func (t *Tracer) StartSpanFromContext(ctx context.Context, name string, options ...SpanOption) (Span, context.Context) {
+	start := spanStart{ctx: ctx}
 	if parentSpan := SpanFromContext(ctx); parentSpan != nil {
-		options = append(options, Parent(parentSpan.Context()))
+		if ts, ok := parentSpan.(*truncatedSpan); ok {
+			// trace is already truncated at the maximum depth
+			ts.add()
+			return ts, ctx
+		}
+		s, ok := parentSpan.(*spanImpl)
+		if ok && t.maxDepth > 0 && s.depth+1 >= t.maxDepth {
+			ts := newTruncatedSpan(s)
+			return ts, NewContext(ctx, ts)
+		}
+		start.parent = parentSpan.Context()
+		start.hasParent = true
+		if ok {
+			start.root = s.root()
+			start.depth = s.depth + 1
+			if len(t.inheritableTags) > 0 {
+				start.inherit = s
+			}
+		}
+	}
+	start.sampled, start.overrideSampling = samplingOverrideFromContext(ctx)
+	span := t.startSpan(name, &start, options)
+	if start.noopCtx != nil {
+		start.noopCtx.Context = ctx
+		return span, start.noopCtx
+	}
+	if s, ok := span.(*spanImpl); ok && t.executionTrace && s.mustCollect == 1 && trace.IsEnabled() {
+		ctx = s.startTask(ctx)
 	}
-	span := t.StartSpan(name, options...)
 	return span, NewContext(ctx, span)
 }
 
+// spanStart holds the parent and settings found in the context of a span
+// started by StartSpanFromContext. They are applied like span options without
+// allocating closures.
+type spanStart struct {
+	ctx              context.Context
+	parent           model.SpanContext
+	hasParent        bool
+	root             *spanImpl // local root span of the parent, if any
+	depth            int
+	inherit          *spanImpl // parent to inherit tags from, see WithInheritableTags
+	sampled          bool
+	overrideSampling bool
+	noopCtx          *noopSpanContext // context holding the span if it is a NoopSpan
+}
+
 // StartSpan creates and starts a span.
 func (t *Tracer) StartSpan(name string, options ...SpanOption) Span {
+	return t.startSpan(name, nil, options)
+}
+
+// startSpan creates and starts a span, applying start after options if
+// started by StartSpanFromContext.
+func (t *Tracer) startSpan(name string, start *spanStart, options []SpanOption) Span {
 	if atomic.LoadInt32(&t.noop) == 1 {
-		return &noopSpan{}
-	}
-	s := &spanImpl{
-		SpanModel: model.SpanModel{
-			Kind:          model.Undetermined,
-			Name:          name,
-			LocalEndpoint: t.localEndpoint,
-			Annotations:   make([]model.Annotation, 0),
-			Tags:          make(map[string]string),
-		},
-		flushOnFinish: true,
-		tracer:        t,
+		return &NoopSpan{}
 	}
+	s := newSpanImpl(t, name)
 
 	// add default tracer tags to span
 	for k, v := range t.defaultTags {
 		s.Tag(k, v)
 	}
 
+	if start != nil && start.inherit != nil {
+		// applied first so explicit tags take precedence
+		s.inheritTags(t, start.inherit)
+	}
+
 	// handle provided functional options
 	for _, option := range options {
 		option(t, s)
 	}
 
-	if s.TraceID.Empty() {
+	if start != nil {
+		if start.hasParent {
+			s.setParent(t, start.parent)
+		}
+		if start.root != nil {
+			s.localRoot = start.root
+			s.depth = start.depth
+		}
+		if start.overrideSampling {
+			s.overrideSampling(start.sampled)
+		}
+		if t.contextTags {
+			s.ctx = start.ctx
+		}
+	}
+
+	if s.debug != nil {
+		s.SpanContext.Debug = *s.debug
+	}
+
+	root := false
+	if s.identity {
+		// ids of the span recorded by the primary tracer of a tee tracer
+	} else if s.TraceID.Empty() {
 		// create root span
+		root = true
 		s.SpanContext.TraceID = t.generate.TraceID()
 		s.SpanContext.ID = t.generate.SpanID(s.SpanContext.TraceID)
+		if t.idTracker != nil {
+			t.idTracker.track(s.SpanContext)
+		}
 	} else {
 		// valid parent context found
-		if t.sharedSpans && s.Kind == model.Server {
+		join := t.sharedSpans && s.Kind == model.Server
+		if s.shared != nil {
+			join = *s.shared
+		}
+		if join {
 			// join span
 			s.Shared = true
 		} else {
 			// regular child span
-			parentID := s.SpanContext.ID
-			s.SpanContext.ParentID = &parentID
-			s.SpanContext.ID = t.generate.SpanID(model.TraceID{})
+			parent := s.SpanContext
+			s.parentID = s.SpanContext.ID
+			s.SpanContext.ParentID = &s.parentID
+			if gen, ok := t.generate.(idgenerator.ParentAwareIDGenerator); ok {
+				s.SpanContext.ID = gen.ChildSpanID(parent)
+			} else {
+				s.SpanContext.ID = t.generate.SpanID(model.TraceID{})
+			}
+			if t.idTracker != nil {
+				t.idTracker.track(s.SpanContext)
+			}
 		}
 	}
 
 	if !s.SpanContext.Debug && s.Sampled == nil {
 		// deferred sampled context found, invoke sampler
-		sampled := t.sampler(s.SpanContext.TraceID.Low)
-		s.SpanContext.Sampled = &sampled
-		if sampled {
+		if t.samplingCache != nil && !root {
+			// new traces need no memoized decision, their spans inherit it
+			s.sampled = t.samplingCache.decide(s.SpanContext.TraceID, t.sampler)
+		} else {
+			s.sampled = t.sampler(s.SpanContext.TraceID.Low)
+		}
+		s.SpanContext.Sampled = &s.sampled
+		if s.sampled {
 			s.mustCollect = 1
 		}
 	} else {
 		if s.SpanContext.Debug || *s.Sampled {
 			s.mustCollect = 1
 		}
+		if t.samplingCache != nil && !root && s.localRoot == nil && !s.SpanContext.Debug {
+			// memoize decisions propagated to the process
+			t.samplingCache.record(s.SpanContext.TraceID, *s.Sampled)
+		}
+	}
+
+	if t.stats != nil {
+		t.stats.started(s.mustCollect == 1)
 	}
 
 	if t.unsampledNoop && s.mustCollect == 0 {
 		// trace not being sampled and noop requested
-		return &noopSpan{
-			SpanContext: s.SpanContext,
+		c := newNoopSpanContext(s)
+		if start != nil {
+			start.noopCtx = c
 		}
+		s.release()
+		return &c.span
+	}
+
+	if t.nameGuard != nil {
+		s.Name = t.nameGuard.guard(s.Name)
 	}
 
 	// add start time
@@ -149,6 +285,17 @@ func (t *Tracer) StartSpan(name string, options ...SpanOption) Span {
 		s.Timestamp = time.Now()
 	}
 
+	if t.contextTags && s.ctx != nil && s.mustCollect == 1 {
+		if deadline, ok := s.ctx.Deadline(); ok {
+			s.Tags[string(TagContextDeadlineRemaining)] = deadline.Sub(s.Timestamp).String()
+		}
+	}
+
+	if t.runtimeMetrics && s.mustCollect == 1 {
+		snapshot := readRuntimeSnapshot()
+		s.runtimeStart = &snapshot
+	}
+
 	return s
 }
 
@@ -162,6 +309,7 @@ func (t *Tracer) Extract(extractor propagation.Extractor) (sc model.SpanContext)
 		sc = *psc
 	}
 	sc.Err = err
+	sc.Remote = sc.IsValid()
 	return
 }
 
@@ -185,3 +333,13 @@ func (t *Tracer) LocalEndpoint() *model.Endpoint {
 	ep := *t.localEndpoint
 	return &ep
 }
+
+// Flush synchronously delivers the spans buffered by the reporter if it
+// implements reporter.Flusher, e.g. before a short-lived process exits. It
+// returns nil for other reporters.
+func (t *Tracer) Flush() error {
+	if f, ok := t.reporter.(reporter.Flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
@@ -16,15 +16,23 @@ package zipkin
 
 import (
 	"errors"
+	"log"
+	"os"
+	"time"
 
 	"github.com/openzipkin/zipkin-go/idgenerator"
 	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter"
 )
 
 // Tracer Option Errors
 var (
 	ErrInvalidEndpoint             = errors.New("requires valid local endpoint")
 	ErrInvalidExtractFailurePolicy = errors.New("invalid extract failure policy provided")
+	ErrInvalidTrackerSize          = errors.New("invalid span id tracker size provided")
+	ErrInvalidSpanNameLimit        = errors.New("invalid span name limit provided")
+	ErrInvalidSamplingCacheSize    = errors.New("invalid sampling cache size provided")
+	ErrInvalidMaxTraceDepth        = errors.New("invalid maximum trace depth provided")
 )
 
 // ExtractFailurePolicy deals with Extraction errors
@@ -136,3 +144,137 @@ func WithNoopTracer(tracerNoop bool) TracerOption {
 		return nil
 	}
 }
+
+// WithDuplicateSpanIDDetection enables a debug mode in which the tracer keeps
+// track of the last size span IDs it generated and invokes onDuplicate when an
+// ID is generated again. If onDuplicate is nil, duplicates are logged to the
+// standard logger. This helps catching broken custom ID generators and should
+// not be enabled in production as it adds locking to span creation.
+func WithDuplicateSpanIDDetection(size int, onDuplicate func(sc model.SpanContext)) TracerOption {
+	return func(o *Tracer) error {
+		if size < 1 {
+			return ErrInvalidTrackerSize
+		}
+		o.idTracker = newSpanIDTracker(size, onDuplicate)
+		return nil
+	}
+}
+
+// WithSpanNameLimit protects the span name index of Zipkin from unbounded
+// naming bugs, e.g. span names holding ids. The tracer keeps track of distinct
+// span names and once limit names are known, new names are rewritten to bucket
+// and onOverflow is invoked with the original name. If bucket is empty
+// DefaultOverflowSpanName is used and if onOverflow is nil, rewrites are logged
+// to the standard logger.
+func WithSpanNameLimit(limit int, bucket string, onOverflow func(name string)) TracerOption {
+	return func(o *Tracer) error {
+		if limit < 1 {
+			return ErrInvalidSpanNameLimit
+		}
+		o.nameGuard = newSpanNameGuard(limit, bucket, onOverflow)
+		return nil
+	}
+}
+
+// WithMaxTraceDepth guards against pathological recursion generating huge
+// traces. Spans started using StartSpanFromContext which would be nested more
+// than depth levels below the local root span of their trace are not created.
+// A span which records nothing is returned instead, carrying the context of the
+// deepest span, and the number of spans not created is recorded on the deepest
+// span using TagTruncatedSpans when it finishes. Depth is counted within the
+// process, starting at 1 for the local root span.
+func WithMaxTraceDepth(depth int) TracerOption {
+	return func(o *Tracer) error {
+		if depth < 1 {
+			return ErrInvalidMaxTraceDepth
+		}
+		o.maxDepth = depth
+		return nil
+	}
+}
+
+// WithVerboseRecording sets the function deciding for which spans verbose tags
+// and annotations, recorded using VerboseTag and VerboseAnnotate, are kept.
+// By default they are only kept for debug traces. This allows to e.g. record
+// verbose data for a fraction of the sampled traces based on their trace id.
+func WithVerboseRecording(fn func(sc model.SpanContext) bool) TracerOption {
+	return func(o *Tracer) error {
+		if fn == nil {
+			fn = debugOnly
+		}
+		o.verbose = fn
+		return nil
+	}
+}
+
+// WithRuntimeMetrics tags sampled spans lasting at least minDuration with the
+// garbage collection cycles, the approximate GC pause time and the change in
+// goroutine count observed during the span. This helps telling application
+// latency apart from runtime stalls. The statistics are read using
+// runtime/metrics at the start and end of every sampled span; GC statistics
+// require Go 1.16 or newer.
+func WithRuntimeMetrics(minDuration time.Duration) TracerOption {
+	return func(o *Tracer) error {
+		o.runtimeMetrics = true
+		o.runtimeMinDuration = minDuration
+		return nil
+	}
+}
+
+// WithContextTags tags spans with the time remaining until the deadline of
+// their context at start, and with the context error if their context was
+// canceled or its deadline expired by the time they finish, so timeout driven
+// failures are self-explanatory. It applies to spans started by
+// StartSpanFromContext and spans started with the WatchContext option.
+func WithContextTags(enabled bool) TracerOption {
+	return func(o *Tracer) error {
+		o.contextTags = enabled
+		return nil
+	}
+}
+
+// WithInheritableTags marks tags with the provided keys as inheritable. When
+// a span is started by StartSpanFromContext with a local parent span, the
+// inheritable tags of the parent are copied to the new span, e.g. to tag all
+// spans of a request with the tenant without tagging every child explicitly.
+// Tags set on the parent after the child has been started are not copied.
+// Tags passed to the new span using the Tags option take precedence.
+func WithInheritableTags(keys ...string) TracerOption {
+	return func(o *Tracer) error {
+		o.inheritableTags = append(o.inheritableTags, keys...)
+		return nil
+	}
+}
+
+// WithSpanSummary enables collecting span statistics which Close logs to
+// logger: the number of spans started, the share of sampled spans, the number
+// of spans dropped as recorded by SpanDropped and the top 10 span names by
+// count and by total duration, giving batch jobs and command line tools an
+// immediate tracing digest. A nil logger logs to stderr.
+func WithSpanSummary(logger reporter.Logger) TracerOption {
+	return func(o *Tracer) error {
+		if logger == nil {
+			logger = log.New(os.Stderr, "", log.LstdFlags)
+		}
+		o.stats = newSpanStats(logger)
+		return nil
+	}
+}
+
+// WithSamplingCache memoizes the sampling decisions of the last size traces
+// joined by the tracer, keyed by trace id. Spans of a trace started without a
+// propagated sampling decision, e.g. for multiple messages of one trace
+// consumed concurrently, reuse the decision memoized for the trace instead of
+// invoking the sampler again, so samplers which are not consistent by trace
+// id, like the counting sampler, make consistent decisions within a process.
+// Sampling decisions propagated from upstream are memoized as well. Once full,
+// the least recently used decisions are evicted.
+func WithSamplingCache(size int) TracerOption {
+	return func(o *Tracer) error {
+		if size < 1 {
+			return ErrInvalidSamplingCacheSize
+		}
+		o.samplingCache = newSamplingCache(size)
+		return nil
+	}
+}
@@ -16,6 +16,7 @@ package zipkin
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"reflect"
 	"testing"
@@ -24,6 +25,7 @@ import (
 	"github.com/openzipkin/zipkin-go/idgenerator"
 	"github.com/openzipkin/zipkin-go/model"
 	"github.com/openzipkin/zipkin-go/reporter"
+	"github.com/openzipkin/zipkin-go/reporter/recorder"
 )
 
 func TestTracerOptionLocalEndpoint(t *testing.T) {
@@ -256,7 +258,7 @@ func TestNoopTracer(t *testing.T) {
 
 	span = tr.StartSpan("test", Parent(pSC))
 
-	if want, have := reflect.TypeOf(&noopSpan{}), reflect.TypeOf(span); want != have {
+	if want, have := reflect.TypeOf(&NoopSpan{}), reflect.TypeOf(span); want != have {
 		t.Errorf("span implementation type want %+v, have %+v", want, have)
 	}
 
@@ -290,7 +292,7 @@ func TestNoopTracer(t *testing.T) {
 
 	span = tr.StartSpan("test", Parent(pSC))
 
-	if want, have := reflect.TypeOf(&noopSpan{}), reflect.TypeOf(span); want != have {
+	if want, have := reflect.TypeOf(&NoopSpan{}), reflect.TypeOf(span); want != have {
 		t.Errorf("span implementation type want %+v, have %+v", want, have)
 	}
 
@@ -327,7 +329,7 @@ func TestNoopSpan(t *testing.T) {
 
 	span := tr.StartSpan("test", Parent(pSC))
 
-	if want, have := reflect.TypeOf(&noopSpan{}), reflect.TypeOf(span); want != have {
+	if want, have := reflect.TypeOf(&NoopSpan{}), reflect.TypeOf(span); want != have {
 		t.Errorf("span implementation type want %+v, have %+v", want, have)
 	}
 
@@ -808,3 +810,247 @@ func TestLocalEndpoint(t *testing.T) {
 		t.Errorf("IPv6 endpoint want %+v, have %+v", want.IPv6, have.IPv6)
 	}
 }
+
+type prefixIDGenerator struct {
+	idgenerator.IDGenerator
+	parents []model.SpanContext
+}
+
+func (g *prefixIDGenerator) ChildSpanID(parent model.SpanContext) model.ID {
+	g.parents = append(g.parents, parent)
+	return model.ID(parent.TraceID.Low<<32 | uint64(len(g.parents)))
+}
+
+func TestParentAwareIDGenerator(t *testing.T) {
+	gen := &prefixIDGenerator{IDGenerator: idgenerator.NewRandom64()}
+
+	tr, err := NewTracer(reporter.NewNoopReporter(), WithIDGenerator(gen))
+	if err != nil {
+		t.Fatalf("unexpected tracer creation failure: %+v", err)
+	}
+
+	parentSC := model.SpanContext{
+		TraceID: model.TraceID{Low: 0xabc},
+		ID:      model.ID(5),
+	}
+
+	child := tr.StartSpan("child", Parent(parentSC))
+
+	if want, have := 1, len(gen.parents); want != have {
+		t.Fatalf("ChildSpanID calls want %d, have %d", want, have)
+	}
+	if want, have := parentSC.ID, gen.parents[0].ID; want != have {
+		t.Errorf("parent ID want %d, have %d", want, have)
+	}
+	if want, have := model.ID(0xabc<<32|1), child.Context().ID; want != have {
+		t.Errorf("child ID want %s, have %s", want, have)
+	}
+
+	// root spans are still generated using SpanID
+	tr.StartSpan("root")
+	if want, have := 1, len(gen.parents); want != have {
+		t.Errorf("ChildSpanID calls want %d, have %d", want, have)
+	}
+}
+
+func TestDebugAndSharedSpanOptions(t *testing.T) {
+	rec := recorder.NewReporter()
+	defer rec.Close()
+
+	tr, err := NewTracer(rec, WithSharedSpans(false))
+	if err != nil {
+		t.Fatalf("unable to create tracer instance: %+v", err)
+	}
+
+	sampled := false
+	pSC := model.SpanContext{
+		TraceID: model.TraceID{Low: 1},
+		ID:      model.ID(2),
+		Sampled: &sampled,
+	}
+
+	// explicit debug and shared flags
+	span := tr.StartSpan("test", Kind(model.Server), Debug(true), Shared(true), Parent(pSC))
+	span.Finish()
+
+	// explicit flags override the parent and tracer defaults
+	pSC.Debug = true
+	tr2, _ := NewTracer(rec, WithSharedSpans(true))
+	span2 := tr2.StartSpan("test", Kind(model.Server), Parent(pSC), Debug(false), Shared(false))
+
+	// shared flag has no effect on root spans
+	root := tr.StartSpan("root", Shared(true), Debug(true))
+	root.Finish()
+
+	spans := rec.Flush()
+	if want, have := 2, len(spans); want != have {
+		t.Fatalf("reported spans want %d, have %d", want, have)
+	}
+
+	if !spans[0].Debug || !spans[0].Shared {
+		t.Errorf("Debug and Shared want true, have %t and %t", spans[0].Debug, spans[0].Shared)
+	}
+	if want, have := pSC.ID, spans[0].ID; want != have {
+		t.Errorf("ID want %s, have %s", want, have)
+	}
+
+	sc2 := span2.Context()
+	if sc2.Debug || span2.(*spanImpl).Shared {
+		t.Errorf("Debug and Shared want false, have %t and %t", sc2.Debug, span2.(*spanImpl).Shared)
+	}
+	if sc2.ID == pSC.ID {
+		t.Error("expected child span with new ID")
+	}
+
+	if !spans[1].Debug {
+		t.Error("Debug want true, have false")
+	}
+	if spans[1].Shared {
+		t.Error("Shared want false, have true")
+	}
+
+	// flags survive serialization
+	b, err := json.Marshal(spans[0])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var have model.SpanModel
+	if err = json.Unmarshal(b, &have); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !have.Debug || !have.Shared {
+		t.Errorf("Debug and Shared want true, have %t and %t", have.Debug, have.Shared)
+	}
+}
+
+func TestExtractedContextIsRemote(t *testing.T) {
+	rep := reporter.NewNoopReporter()
+	defer rep.Close()
+
+	tr, err := NewTracer(rep)
+	if err != nil {
+		t.Fatalf("unable to create tracer instance: %+v", err)
+	}
+
+	sc := tr.Extract(func() (*model.SpanContext, error) {
+		return &model.SpanContext{TraceID: model.TraceID{Low: 1}, ID: 2}, nil
+	})
+	if !sc.IsRemote() {
+		t.Error("expected remote span context")
+	}
+
+	if tr.StartSpan("child", Parent(sc)).Context().IsRemote() {
+		t.Error("expected local span context")
+	}
+}
+
+func TestInheritableTags(t *testing.T) {
+	rec := recorder.NewReporter()
+	tr, err := NewTracer(rec, WithInheritableTags("tenant", "request.id"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	parent, ctx := tr.StartSpanFromContext(context.Background(), "parent")
+	parent.Tag("tenant", "acme")
+	parent.Tag("request.id", "42")
+	parent.Tag("local", "parent only")
+
+	child, ctx := tr.StartSpanFromContext(ctx, "child", Tags(map[string]string{"request.id": "override"}))
+	grandChild, _ := tr.StartSpanFromContext(ctx, "grandchild")
+	grandChild.Finish()
+	child.Finish()
+	parent.Finish()
+
+	// the grandchild inherits the overridden tag from the child
+	want := map[string]string{"tenant": "acme", "request.id": "override"}
+	for _, span := range rec.Flush()[:2] {
+		if have := span.Tags; !reflect.DeepEqual(want, have) {
+			t.Errorf("%s: tags want %v, have %v", span.Name, want, have)
+		}
+	}
+}
+
+type flushingReporter struct {
+	reporter.Reporter
+	flushes int
+}
+
+func (r *flushingReporter) Flush() error {
+	r.flushes++
+	return nil
+}
+
+func TestTracerFlush(t *testing.T) {
+	rep := &flushingReporter{Reporter: recorder.NewReporter()}
+	tr, err := NewTracer(rep)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err = tr.Flush(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if want, have := 1, rep.flushes; want != have {
+		t.Errorf("flushes want %d, have %d", want, have)
+	}
+
+	// reporters not implementing reporter.Flusher are ignored
+	tr, _ = NewTracer(recorder.NewReporter())
+	if err = tr.Flush(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestStartSpanFromContextUnsampledNoop(t *testing.T) {
+	tr, _ := NewTracer(recorder.NewReporter(), WithSampler(NeverSample), WithNoopSpan(true))
+
+	parent, ctx := tr.StartSpanFromContext(context.Background(), "parent")
+	if _, ok := parent.(*NoopSpan); !ok {
+		t.Fatalf("span want *NoopSpan, have %T", parent)
+	}
+	if want, have := parent, SpanFromContext(ctx); want != have {
+		t.Errorf("span from context want %+v, have %+v", want, have)
+	}
+
+	for i := 0; i < 3; i++ {
+		// spans are recycled, contexts of earlier spans may not change
+		child, _ := tr.StartSpanFromContext(ctx, "child")
+		sc := child.Context()
+		if sc.ParentID == nil || *sc.ParentID != parent.Context().ID {
+			t.Errorf("parent id want %s, have %v", parent.Context().ID, sc.ParentID)
+		}
+		if sc.Sampled == nil || *sc.Sampled {
+			t.Errorf("sampled want false, have %v", sc.Sampled)
+		}
+		if want, have := parent.Context().TraceID, sc.TraceID; want != have {
+			t.Errorf("trace id want %s, have %s", want, have)
+		}
+	}
+	if have := parent.Context().ParentID; have != nil {
+		t.Errorf("parent id want nil, have %s", have)
+	}
+}
+
+// TestStartSpanFromContextAllocs enforces the allocation budget of unsampled
+// spans, see the performance section of the README.
+func TestStartSpanFromContextAllocs(t *testing.T) {
+	tr, _ := NewTracer(recorder.NewReporter(), WithSampler(NeverSample), WithNoopSpan(true))
+	parent, ctx := tr.StartSpanFromContext(context.Background(), "parent")
+	defer parent.Finish()
+
+	for _, test := range []struct {
+		name string
+		ctx  context.Context
+	}{
+		{"root", context.Background()},
+		{"child", ctx},
+	} {
+		allocs := testing.AllocsPerRun(100, func() {
+			sp, _ := tr.StartSpanFromContext(test.ctx, "test", Kind(model.Client))
+			sp.Finish()
+		})
+		if want, have := 1.0, allocs; have > want {
+			t.Errorf("%s: allocs want at most %.0f, have %.0f", test.name, want, have)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zipkin
+
+import (
+	"time"
+
+	"github.com/openzipkin/zipkin-go/model"
+)
+
+// debugOnly is the default verbose recording policy keeping verbose data of
+// debug traces only.
+func debugOnly(sc model.SpanContext) bool {
+	return sc.Debug
+}
+
+// IsVerbose returns true if verbose tags and annotations are recorded for s,
+// see WithVerboseRecording. Use it to skip computing expensive verbose values.
+func IsVerbose(s Span) bool {
+	impl, ok := s.(*spanImpl)
+	return ok && impl.tracer.verbose(impl.Context())
+}
+
+// VerboseTag sets a tag on s only if verbose data is recorded for s, keeping
+// ordinary spans small while debug traces stay rich.
+func VerboseTag(s Span, key, value string) {
+	if IsVerbose(s) {
+		s.Tag(key, value)
+	}
+}
+
+// VerboseAnnotate adds an annotation to s only if verbose data is recorded for
+// s.
+func VerboseAnnotate(s Span, t time.Time, value string) {
+	if IsVerbose(s) {
+		s.Annotate(t, value)
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zipkin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter/recorder"
+)
+
+func TestVerboseRecording(t *testing.T) {
+	rec := recorder.NewReporter()
+	defer rec.Close()
+
+	tr, _ := NewTracer(rec)
+
+	span := tr.StartSpan("regular")
+	VerboseTag(span, "payload", "large")
+	VerboseAnnotate(span, time.Now(), "verbose")
+	span.Finish()
+
+	debug := tr.StartSpan("debug", Parent(model.SpanContext{
+		TraceID: model.TraceID{Low: 1},
+		ID:      model.ID(1),
+		Debug:   true,
+	}))
+	VerboseTag(debug, "payload", "large")
+	VerboseAnnotate(debug, time.Now(), "verbose")
+	debug.Finish()
+
+	spans := rec.Flush()
+	if want, have := 2, len(spans); want != have {
+		t.Fatalf("reported spans want %d, have %d", want, have)
+	}
+	if len(spans[0].Tags) != 0 || len(spans[0].Annotations) != 0 {
+		t.Errorf("expected no verbose data on regular span, have %+v %+v", spans[0].Tags, spans[0].Annotations)
+	}
+	if want, have := "large", spans[1].Tags["payload"]; want != have {
+		t.Errorf("verbose tag want %q, have %q", want, have)
+	}
+	if want, have := 1, len(spans[1].Annotations); want != have {
+		t.Errorf("verbose annotations want %d, have %d", want, have)
+	}
+
+	if IsVerbose(&NoopSpan{}) {
+		t.Error("expected noop span to not be verbose")
+	}
+}
+
+func TestWithVerboseRecording(t *testing.T) {
+	rec := recorder.NewReporter()
+	defer rec.Close()
+
+	tr, _ := NewTracer(rec, WithVerboseRecording(func(sc model.SpanContext) bool {
+		return sc.TraceID.Low%2 == 0
+	}))
+
+	for _, low := range []uint64{1, 2} {
+		span := tr.StartSpan("span", Parent(model.SpanContext{TraceID: model.TraceID{Low: low}, ID: model.ID(1)}))
+		if want, have := low%2 == 0, IsVerbose(span); want != have {
+			t.Errorf("trace %d verbose want %t, have %t", low, want, have)
+		}
+	}
+}
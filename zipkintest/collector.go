@@ -0,0 +1,303 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zipkintest
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openzipkin/zipkin-go/model"
+	zipkinproto "github.com/openzipkin/zipkin-go/proto/v2"
+)
+
+// content types accepted by the collector
+const (
+	contentTypeJSON  = "application/json"
+	contentTypeProto = "application/x-protobuf"
+)
+
+// Request holds the details of a request received by the Collector.
+type Request struct {
+	// ContentType is the media type of the request body.
+	ContentType string
+	// Spans is the number of spans in the request body.
+	Spans int
+	// StatusCode is the status code the collector responded with.
+	StatusCode int
+}
+
+// response is a scripted response of the collector.
+type response struct {
+	statusCode int
+	retryAfter time.Duration
+}
+
+// Collector is an in-process mock Zipkin collector implementing http.Handler.
+// It accepts POST requests of V2 JSON and proto3 encoded spans, optionally
+// gzip compressed, on any path and answers OPTIONS requests with the accepted
+// content types. It is safe for concurrent use.
+type Collector struct {
+	mtx      sync.Mutex
+	cond     *sync.Cond
+	spans    []model.SpanModel
+	requests []Request
+	scripted []response
+	failing  int
+	latency  time.Duration
+}
+
+// NewCollector returns a new Collector accepting all requests.
+func NewCollector() *Collector {
+	c := &Collector{}
+	c.cond = sync.NewCond(&c.mtx)
+	return c
+}
+
+// FailNext makes the collector respond to the next n requests with
+// statusCode, discarding their spans.
+func (c *Collector) FailNext(n int, statusCode int) {
+	c.script(n, response{statusCode: statusCode})
+}
+
+// RateLimitNext makes the collector respond to the next n requests with 429
+// Too Many Requests and a Retry-After header of retryAfter, discarding their
+// spans.
+func (c *Collector) RateLimitNext(n int, retryAfter time.Duration) {
+	c.script(n, response{statusCode: http.StatusTooManyRequests, retryAfter: retryAfter})
+}
+
+func (c *Collector) script(n int, res response) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	for i := 0; i < n; i++ {
+		c.scripted = append(c.scripted, res)
+	}
+}
+
+// Fail makes the collector respond to all requests with statusCode, after the
+// responses scripted by FailNext and RateLimitNext, until Fail is called with
+// 0.
+func (c *Collector) Fail(statusCode int) {
+	c.mtx.Lock()
+	c.failing = statusCode
+	c.mtx.Unlock()
+}
+
+// SetLatency delays the responses to requests by d.
+func (c *Collector) SetLatency(d time.Duration) {
+	c.mtx.Lock()
+	c.latency = d
+	c.mtx.Unlock()
+}
+
+// ServeHTTP implements http.Handler.
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Accept-Post", contentTypeJSON+", "+contentTypeProto)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	c.mtx.Lock()
+	latency := c.latency
+	c.mtx.Unlock()
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if contentType == "" {
+		contentType = contentTypeJSON
+	}
+	spans, statusCode := decode(r, contentType)
+
+	c.mtx.Lock()
+	res := response{statusCode: statusCode}
+	if statusCode == http.StatusAccepted {
+		if len(c.scripted) > 0 {
+			res, c.scripted = c.scripted[0], c.scripted[1:]
+		} else if c.failing != 0 {
+			res.statusCode = c.failing
+		}
+	}
+	if res.statusCode == http.StatusAccepted {
+		c.spans = append(c.spans, spans...)
+		c.cond.Broadcast()
+	}
+	c.requests = append(c.requests, Request{ContentType: contentType, Spans: len(spans), StatusCode: res.statusCode})
+	c.mtx.Unlock()
+
+	if res.retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int((res.retryAfter+time.Second-1)/time.Second)))
+	}
+	w.WriteHeader(res.statusCode)
+}
+
+// decode decodes the spans of the request body and returns the status code
+// to respond with if they are accepted or why they are rejected.
+func decode(r *http.Request, contentType string) ([]model.SpanModel, int) {
+	var body io.Reader = r.Body
+	switch r.Header.Get("Content-Encoding") {
+	case "":
+	case "gzip":
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return nil, http.StatusBadRequest
+		}
+		defer gz.Close()
+		body = gz
+	default:
+		return nil, http.StatusUnsupportedMediaType
+	}
+
+	switch contentType {
+	case contentTypeJSON:
+		var spans []model.SpanModel
+		if err := json.NewDecoder(body).Decode(&spans); err != nil {
+			return nil, http.StatusBadRequest
+		}
+		return spans, http.StatusAccepted
+	case contentTypeProto:
+		b, err := ioutil.ReadAll(body)
+		if err != nil {
+			return nil, http.StatusBadRequest
+		}
+		parsed, err := zipkinproto.ParseSpans(b, false)
+		if err != nil {
+			return nil, http.StatusBadRequest
+		}
+		spans := make([]model.SpanModel, len(parsed))
+		for i, span := range parsed {
+			spans[i] = *span
+		}
+		return spans, http.StatusAccepted
+	default:
+		return nil, http.StatusUnsupportedMediaType
+	}
+}
+
+// Spans returns the spans accepted so far.
+func (c *Collector) Spans() []model.SpanModel {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return append([]model.SpanModel(nil), c.spans...)
+}
+
+// Requests returns the span POST requests received so far, including rejected
+// requests.
+func (c *Collector) Requests() []Request {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return append([]Request(nil), c.requests...)
+}
+
+// Trace returns the spans accepted so far with traceID.
+func (c *Collector) Trace(traceID model.TraceID) []model.SpanModel {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	var spans []model.SpanModel
+	for _, span := range c.spans {
+		if span.TraceID == traceID {
+			spans = append(spans, span)
+		}
+	}
+	return spans
+}
+
+// Reset discards the spans and requests received and the simulated failures
+// and latency.
+func (c *Collector) Reset() {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.spans, c.requests, c.scripted = nil, nil, nil
+	c.failing, c.latency = 0, 0
+}
+
+// WaitForSpans waits until at least n spans are accepted or the timeout
+// expires and returns the spans accepted.
+func (c *Collector) WaitForSpans(n int, timeout time.Duration) []model.SpanModel {
+	timer := time.AfterFunc(timeout, func() {
+		c.mtx.Lock()
+		c.cond.Broadcast()
+		c.mtx.Unlock()
+	})
+	defer timer.Stop()
+
+	deadline := time.Now().Add(timeout)
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	for len(c.spans) < n && time.Now().Before(deadline) {
+		c.cond.Wait()
+	}
+	return append([]model.SpanModel(nil), c.spans...)
+}
+
+// AssertSpans waits up to timeout for n spans to be accepted and returns them.
+// It fails the test if a different number of spans is accepted.
+func (c *Collector) AssertSpans(t testing.TB, n int, timeout time.Duration) []model.SpanModel {
+	t.Helper()
+
+	spans := c.WaitForSpans(n, timeout)
+	if len(spans) != n {
+		t.Fatalf("spans want %d, have %d", n, len(spans))
+	}
+	return spans
+}
+
+// AssertSpan returns the accepted span named name. It fails the test if no or
+// multiple spans are named name.
+func (c *Collector) AssertSpan(t testing.TB, name string) model.SpanModel {
+	t.Helper()
+
+	var found []model.SpanModel
+	for _, span := range c.Spans() {
+		if span.Name == name {
+			found = append(found, span)
+		}
+	}
+	if len(found) != 1 {
+		t.Fatalf("spans named %q want 1, have %d", name, len(found))
+	}
+	return found[0]
+}
+
+// AssertRequests fails the test if the number of span POST requests received
+// differs from n and returns the requests.
+func (c *Collector) AssertRequests(t testing.TB, n int) []Request {
+	t.Helper()
+
+	requests := c.Requests()
+	if len(requests) != n {
+		t.Fatalf("requests want %d, have %d", n, len(requests))
+	}
+	return requests
+}
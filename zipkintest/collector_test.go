@@ -0,0 +1,199 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zipkintest_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/openzipkin/zipkin-go/model"
+	zipkinproto "github.com/openzipkin/zipkin-go/proto/v2"
+	zipkinhttp "github.com/openzipkin/zipkin-go/reporter/http"
+	"github.com/openzipkin/zipkin-go/zipkintest"
+)
+
+func newSpan(name string) model.SpanModel {
+	return model.SpanModel{
+		SpanContext: model.SpanContext{
+			TraceID: model.TraceID{Low: 1},
+			ID:      model.ID(len(name)),
+		},
+		Name:      name,
+		Timestamp: time.Now(),
+		Duration:  time.Millisecond,
+	}
+}
+
+func TestCollectorRetry(t *testing.T) {
+	c := zipkintest.NewCollector()
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	c.FailNext(1, http.StatusServiceUnavailable)
+	c.RateLimitNext(1, time.Second)
+
+	rep := zipkinhttp.NewReporter(
+		srv.URL,
+		zipkinhttp.BatchSize(2),
+		zipkinhttp.Retry(3, time.Millisecond, time.Millisecond),
+	)
+	rep.Send(newSpan("a"))
+	rep.Send(newSpan("bb"))
+
+	spans := c.AssertSpans(t, 2, 5*time.Second)
+	_ = rep.Close()
+
+	if want, have := "a", spans[0].Name; want != have {
+		t.Errorf("span name want %q, have %q", want, have)
+	}
+	c.AssertSpan(t, "bb")
+
+	requests := c.AssertRequests(t, 3)
+	for i, want := range []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusAccepted} {
+		if have := requests[i].StatusCode; want != have {
+			t.Errorf("request %d status code want %d, have %d", i, want, have)
+		}
+	}
+	if want, have := 2, requests[0].Spans; want != have {
+		t.Errorf("request spans want %d, have %d", want, have)
+	}
+	if want, have := 2, len(c.Trace(model.TraceID{Low: 1})); want != have {
+		t.Errorf("trace spans want %d, have %d", want, have)
+	}
+}
+
+func TestCollectorResponses(t *testing.T) {
+	c := zipkintest.NewCollector()
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	c.RateLimitNext(1, 1500*time.Millisecond)
+	res, err := http.Post(srv.URL, "application/json", bytes.NewBufferString("[]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if want, have := http.StatusTooManyRequests, res.StatusCode; want != have {
+		t.Errorf("status code want %d, have %d", want, have)
+	}
+	if want, have := "2", res.Header.Get("Retry-After"); want != have {
+		t.Errorf("Retry-After want %q, have %q", want, have)
+	}
+
+	c.Fail(http.StatusInternalServerError)
+	for i := 0; i < 2; i++ {
+		res, err = http.Post(srv.URL, "application/json", bytes.NewBufferString("[]"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if want, have := http.StatusInternalServerError, res.StatusCode; want != have {
+			t.Errorf("status code want %d, have %d", want, have)
+		}
+	}
+
+	c.Fail(0)
+	res, err = http.Post(srv.URL, "application/json", bytes.NewBufferString("{"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if want, have := http.StatusBadRequest, res.StatusCode; want != have {
+		t.Errorf("status code want %d, have %d", want, have)
+	}
+
+	res, err = http.Post(srv.URL, "text/plain", bytes.NewBufferString("[]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if want, have := http.StatusUnsupportedMediaType, res.StatusCode; want != have {
+		t.Errorf("status code want %d, have %d", want, have)
+	}
+
+	req, _ := http.NewRequest(http.MethodOptions, srv.URL, nil)
+	res, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if want, have := "application/json, application/x-protobuf", res.Header.Get("Accept-Post"); want != have {
+		t.Errorf("Accept-Post want %q, have %q", want, have)
+	}
+
+	c.AssertRequests(t, 5)
+	c.Reset()
+	c.AssertRequests(t, 0)
+}
+
+func TestCollectorProtoGzip(t *testing.T) {
+	c := zipkintest.NewCollector()
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	serializer := zipkinproto.SpanSerializer{}
+	b, err := serializer.Serialize([]*model.SpanModel{{
+		SpanContext: model.SpanContext{TraceID: model.TraceID{Low: 2}, ID: 3},
+		Name:        "proto",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body bytes.Buffer
+	gz := gzip.NewWriter(&body)
+	_, _ = gz.Write(b)
+	_ = gz.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/api/v2/spans", &body)
+	req.Header.Set("Content-Type", serializer.ContentType())
+	req.Header.Set("Content-Encoding", "gzip")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if want, have := http.StatusAccepted, res.StatusCode; want != have {
+		t.Fatalf("status code want %d, have %d", want, have)
+	}
+
+	if want, have := model.ID(3), c.AssertSpan(t, "proto").ID; want != have {
+		t.Errorf("span id want %s, have %s", want, have)
+	}
+}
+
+func TestCollectorLatency(t *testing.T) {
+	c := zipkintest.NewCollector()
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	c.SetLatency(50 * time.Millisecond)
+	start := time.Now()
+	res, err := http.Post(srv.URL, "application/json", bytes.NewBufferString("[]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("expected latency of at least 50ms, have %s", elapsed)
+	}
+
+	if want, have := 0, len(c.WaitForSpans(1, 10*time.Millisecond)); want != have {
+		t.Errorf("spans want %d, have %d", want, have)
+	}
+}
@@ -0,0 +1,36 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package zipkintest provides helpers for testing Zipkin instrumentation and
+reporters.
+
+Collector is a lightweight in-process mock of a Zipkin collector accepting V2
+JSON and proto3 spans. It stores the spans received, simulates failures,
+latency and rate limiting on demand and offers assertions on the spans
+received, so reporter retry and backpressure behavior can be tested without
+Docker:
+
+	c := zipkintest.NewCollector()
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	c.FailNext(2, http.StatusServiceUnavailable)
+	rep := zipkinhttp.NewReporter(srv.URL, zipkinhttp.Retry(3, 0, 0))
+	...
+	spans := c.AssertSpans(t, 10, time.Second)
+
+For end-to-end tests against a real Zipkin server see the integration module.
+*/
+package zipkintest
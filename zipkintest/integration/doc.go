@@ -0,0 +1,44 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package integration provides an end-to-end test harness running a Zipkin
+server in a Docker container using testcontainers-go. Tests send spans to the
+server with a real HTTP reporter and assert on the traces the server returns
+through its query API.
+
+	func TestCheckout(t *testing.T) {
+		ctx := context.Background()
+		z, err := integration.Start(ctx)
+		if err != nil {
+			t.Skipf("zipkin container not available: %v", err)
+		}
+		defer z.Terminate(ctx)
+
+		rep := z.NewReporter()
+		tracer, _ := zipkin.NewTracer(rep)
+		span := tracer.StartSpan("checkout")
+		span.Finish()
+		_ = rep.Close()
+
+		spans := z.AssertTrace(t, span.Context().TraceID, 1)
+		if spans[0].Name != "checkout" {
+			t.Errorf("unexpected span name %q", spans[0].Name)
+		}
+	}
+
+The harness lives in its own module so the zipkin-go module does not depend on
+testcontainers-go. It requires a Docker daemon reachable by testcontainers-go.
+*/
+package integration
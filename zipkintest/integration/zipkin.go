@@ -0,0 +1,262 @@
+// Copyright 2019 The OpenZipkin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter"
+	zipkinhttp "github.com/openzipkin/zipkin-go/reporter/http"
+)
+
+// defaults of the harness
+const (
+	DefaultImage       = "openzipkin/zipkin:3"
+	defaultWaitTimeout = 10 * time.Second
+	zipkinPort         = "9411/tcp"
+)
+
+// Zipkin is a Zipkin server running in a container.
+type Zipkin struct {
+	// URL is the base URL of the server, e.g. http://localhost:32768.
+	URL string
+
+	container   testcontainers.Container
+	client      *http.Client
+	waitTimeout time.Duration
+}
+
+type options struct {
+	image       string
+	env         map[string]string
+	waitTimeout time.Duration
+}
+
+// Option sets a parameter of the harness.
+type Option func(*options)
+
+// Image sets the Docker image of the Zipkin server. Defaults to DefaultImage.
+func Image(image string) Option {
+	return func(o *options) { o.image = image }
+}
+
+// Env sets environment variables of the Zipkin server, e.g. to configure a
+// storage backend.
+func Env(env map[string]string) Option {
+	return func(o *options) { o.env = env }
+}
+
+// WaitTimeout sets how long AssertTrace and AssertTraces wait for spans to
+// become queryable. Defaults to 10 seconds.
+func WaitTimeout(d time.Duration) Option {
+	return func(o *options) { o.waitTimeout = d }
+}
+
+// Start starts a Zipkin server container and waits until it is healthy.
+func Start(ctx context.Context, opts ...Option) (*Zipkin, error) {
+	o := options{image: DefaultImage, waitTimeout: defaultWaitTimeout}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	c, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        o.image,
+			Env:          o.env,
+			ExposedPorts: []string{zipkinPort},
+			WaitingFor:   wait.ForHTTP("/health").WithPort(zipkinPort),
+		},
+		Started: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	host, err := c.Host(ctx)
+	if err != nil {
+		_ = c.Terminate(ctx)
+		return nil, err
+	}
+	port, err := c.MappedPort(ctx, zipkinPort)
+	if err != nil {
+		_ = c.Terminate(ctx)
+		return nil, err
+	}
+
+	return &Zipkin{
+		URL:         "http://" + host + ":" + port.Port(),
+		container:   c,
+		client:      &http.Client{Timeout: 5 * time.Second},
+		waitTimeout: o.waitTimeout,
+	}, nil
+}
+
+// Terminate stops and removes the container.
+func (z *Zipkin) Terminate(ctx context.Context) error {
+	return z.container.Terminate(ctx)
+}
+
+// SpansURL returns the URL of the server's span collection endpoint.
+func (z *Zipkin) SpansURL() string {
+	return z.URL + "/api/v2/spans"
+}
+
+// NewReporter returns a HTTP reporter sending spans to the server. Close the
+// reporter, or Flush it, to deliver the spans before asserting on them.
+func (z *Zipkin) NewReporter(opts ...zipkinhttp.ReporterOption) reporter.Reporter {
+	return zipkinhttp.NewReporter(z.SpansURL(), opts...)
+}
+
+// Query holds the parameters of a trace search. Empty fields are not used.
+type Query struct {
+	ServiceName     string
+	SpanName        string
+	AnnotationQuery string // e.g. "http.method=GET and error"
+	Lookback        time.Duration
+	Limit           int
+}
+
+// Trace returns the spans of the trace with traceID. It returns no spans if
+// the trace is not found.
+func (z *Zipkin) Trace(ctx context.Context, traceID model.TraceID) ([]model.SpanModel, error) {
+	var spans []model.SpanModel
+	found, err := z.get(ctx, "/api/v2/trace/"+traceID.String(), nil, &spans)
+	if !found {
+		return nil, err
+	}
+	return spans, err
+}
+
+// Traces returns the traces matching q.
+func (z *Zipkin) Traces(ctx context.Context, q Query) ([][]model.SpanModel, error) {
+	params := url.Values{}
+	if q.ServiceName != "" {
+		params.Set("serviceName", q.ServiceName)
+	}
+	if q.SpanName != "" {
+		params.Set("spanName", q.SpanName)
+	}
+	if q.AnnotationQuery != "" {
+		params.Set("annotationQuery", q.AnnotationQuery)
+	}
+	if q.Lookback > 0 {
+		params.Set("lookback", strconv.FormatInt(int64(q.Lookback/time.Millisecond), 10))
+	}
+	if q.Limit > 0 {
+		params.Set("limit", strconv.Itoa(q.Limit))
+	}
+	var traces [][]model.SpanModel
+	_, err := z.get(ctx, "/api/v2/traces", params, &traces)
+	return traces, err
+}
+
+// AssertTrace waits until the trace with traceID holds n spans and returns
+// them. It fails the test if the trace does not reach n spans within the wait
+// timeout.
+func (z *Zipkin) AssertTrace(t testing.TB, traceID model.TraceID, n int) []model.SpanModel {
+	t.Helper()
+
+	var (
+		spans []model.SpanModel
+		err   error
+	)
+	z.poll(func(ctx context.Context) bool {
+		spans, err = z.Trace(ctx, traceID)
+		return err == nil && len(spans) >= n
+	})
+	if err != nil {
+		t.Fatalf("trace %s: %v", traceID, err)
+	}
+	if len(spans) != n {
+		t.Fatalf("trace %s: spans want %d, have %d", traceID, n, len(spans))
+	}
+	return spans
+}
+
+// AssertTraces waits until n traces match q and returns them. It fails the
+// test if the number of matching traces does not reach n within the wait
+// timeout.
+func (z *Zipkin) AssertTraces(t testing.TB, q Query, n int) [][]model.SpanModel {
+	t.Helper()
+
+	var (
+		traces [][]model.SpanModel
+		err    error
+	)
+	z.poll(func(ctx context.Context) bool {
+		traces, err = z.Traces(ctx, q)
+		return err == nil && len(traces) >= n
+	})
+	if err != nil {
+		t.Fatalf("traces %+v: %v", q, err)
+	}
+	if len(traces) != n {
+		t.Fatalf("traces %+v: traces want %d, have %d", q, n, len(traces))
+	}
+	return traces
+}
+
+// poll calls done until it returns true or the wait timeout expires, as the
+// server indexes spans asynchronously.
+func (z *Zipkin) poll(done func(ctx context.Context) bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), z.waitTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for !done(ctx) {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// get decodes the JSON response to a GET request of path into v and reports
+// whether the resource was found.
+func (z *Zipkin) get(ctx context.Context, path string, params url.Values, v interface{}) (bool, error) {
+	u := z.URL + path
+	if len(params) > 0 {
+		u += "?" + params.Encode()
+	}
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return false, err
+	}
+	res, err := z.client.Do(req.WithContext(reporter.NewUntracedContext(ctx)))
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		return false, nil
+	case res.StatusCode != http.StatusOK:
+		return false, fmt.Errorf("zipkin query %s failed with status code %d", path, res.StatusCode)
+	}
+	return true, json.NewDecoder(res.Body).Decode(v)
+}